@@ -52,11 +52,14 @@ func ParseBytes(path string, b []byte) (*ir.Module, error) {
 // for error reporting.
 func ParseString(path, content string) (*ir.Module, error) {
 	parseStart := time.Now()
+	// Rewrite opaque pointer types not yet supported by the grammar; see
+	// opaquePointerPlaceholder.
+	content, opaquePtrs := rewriteOpaquePointers(content)
 	tree, err := ast.Parse(path, content)
 	if err != nil {
 		return nil, errors.Wrapf(err, "unable to parse %q into an AST", path)
 	}
 	dbg.Println("parsing into AST took:", time.Since(parseStart))
 	root := ast.ToLlvmNode(tree.Root())
-	return translate(root.(*ast.Module))
+	return translate(root.(*ast.Module), opaquePtrs)
 }
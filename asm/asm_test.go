@@ -7,7 +7,9 @@ import (
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/llir/llvm/internal/osutil"
+	"github.com/llir/llvm/ir/types"
 )
 
 func TestParseFile(t *testing.T) {
@@ -41,6 +43,9 @@ func TestParseFile(t *testing.T) {
 		// global alignment.
 		{path: "testdata/global_align.ll"},
 
+		// opaque pointer types.
+		{path: "testdata/opaque_pointer.ll"},
+
 		// LLVM IR compatibility.
 		{path: "../testdata/llvm/test/Bitcode/compatibility.ll"},
 
@@ -85,8 +90,7 @@ func TestParseFile(t *testing.T) {
 
 		// Use of address space in function declaration and dereferenable
 		// parameter attribute.
-		// TODO: re-enable test case when opaque pointer type has been implemented (in LLVM 16.0).
-		//{path: "../testdata/llvm/test/Transforms/InstSimplify/compare.ll"},
+		{path: "../testdata/llvm/test/Transforms/InstSimplify/compare.ll"},
 
 		// Basic block labels.
 		{path: "../testdata/llvm/test/Assembler/block-labels.ll"},
@@ -96,8 +100,7 @@ func TestParseFile(t *testing.T) {
 
 		// Calling conventions.
 		{path: "../testdata/llvm/test/Bitcode/calling-conventions.3.2.ll"},
-		// TODO: re-enable test case when opaque pointer type has been implemented (in LLVM 16.0).
-		//{path: "../testdata/llvm/test/CodeGen/X86/tailccfp.ll"},
+		{path: "../testdata/llvm/test/CodeGen/X86/tailccfp.ll"},
 
 		// Parameter attributes.
 		{path: "../testdata/llvm/test/Bitcode/attributes.ll"},
@@ -350,8 +353,7 @@ func TestParseFile(t *testing.T) {
 		{path: "../testdata/llvm/test/Bitcode/disubrange.ll"},
 
 		// LLVM test/CodeGen.
-		// TODO: re-enable test case when opaque pointer type has been implemented (in LLVM 16.0).
-		//{path: "../testdata/llvm/test/CodeGen/X86/extractps.ll"},
+		{path: "../testdata/llvm/test/CodeGen/X86/extractps.ll"},
 
 		// LLVM test/DebugInfo/Generic.
 		{path: "../testdata/llvm/test/DebugInfo/Generic/constant-pointers.ll"},
@@ -575,3 +577,49 @@ func TestParseFile(t *testing.T) {
 		}
 	}
 }
+
+func TestRewriteOpaquePointers(t *testing.T) {
+	golden := []struct {
+		in string
+		// Expected output.
+		want string
+		// Expected address spaces of opaque pointer types, keyed by offset.
+		ptrs map[int]types.AddrSpace
+	}{
+		{
+			in:   "define ptr @f(i32 %x) {",
+			want: `define %"" @f(i32 %x) {`,
+			ptrs: map[int]types.AddrSpace{7: 0},
+		},
+		{
+			in:   "@x = global ptr addrspace(1) null",
+			want: `@x = global %""              null`,
+			ptrs: map[int]types.AddrSpace{12: 1},
+		},
+		// Line breaks are kept.
+		{
+			in:   "@x = global ptr addrspace(\n2) null",
+			want: "@x = global %\"\"           \n   null",
+			ptrs: map[int]types.AddrSpace{12: 2},
+		},
+		// Placeholder named types of the input are not opaque pointer types.
+		{
+			in:   `@x = global %"" zeroinitializer, align 4`,
+			want: `@x = global %"" zeroinitializer, align 4`,
+		},
+	}
+	for _, g := range golden {
+		got, ptrs := rewriteOpaquePointers(g.in)
+		if got != g.want {
+			t.Errorf("output mismatch of %q; expected %q, got %q", g.in, g.want, got)
+		}
+		if !cmp.Equal(g.ptrs, ptrs, cmpopts.EquateEmpty()) {
+			t.Errorf("opaque pointer types mismatch of %q; expected %v, got %v", g.in, g.ptrs, ptrs)
+		}
+	}
+	// Placeholder named types of the input do not resolve to opaque pointer
+	// types.
+	if _, err := ParseString("", "define void @f(ptr %p, %\"\" %x) {\n\tret void\n}\n"); err == nil {
+		t.Errorf("expected error for undefined named type of input")
+	}
+}
@@ -250,6 +250,9 @@ func (gen *generator) irBlockAddressConst(t types.Type, old *ast.BlockAddressCon
 		LocalIdent: blockIdent,
 	}
 	c := constant.NewBlockAddress(f, block)
+	if types.IsOpaquePointer(t) {
+		c.Typ = t
+	}
 	gen.todo = append(gen.todo, c)
 	if typ := c.Type(); !t.Equal(typ) {
 		return nil, errors.Errorf("blockaddress constant type mismatch; expected %q, got %q", typ, t)
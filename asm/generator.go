@@ -17,6 +17,11 @@ type generator struct {
 	old oldIndex
 	// index of IR top-level entities.
 	new newIndex
+	// Module uses opaque pointer types.
+	opaquePointers bool
+	// opaquePtrs maps from offset of placeholder named type to address space
+	// of opaque pointer type; see opaquePointerPlaceholder.
+	opaquePtrs map[int]types.AddrSpace
 
 	// TODO: add rw mutex to gen.todo for access to blockaddress constant.
 
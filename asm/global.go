@@ -67,6 +67,9 @@ func (gen *generator) newGlobal(ident ir.GlobalIdent, oldContentType ast.Type, o
 		return nil, errors.WithStack(err)
 	}
 	typ := types.NewPointer(contentType)
+	if gen.opaquePointers {
+		typ = types.NewOpaquePointer(0)
+	}
 	// (optional) Address space.
 	var addrSpace types.AddrSpace
 	if oldAddrSpace.IsValid() {
@@ -85,6 +88,9 @@ func (gen *generator) newIndirectSymbol(ident ir.GlobalIdent, old *ast.IndirectS
 		return nil, errors.WithStack(err)
 	}
 	typ := types.NewPointer(contentType)
+	if gen.opaquePointers {
+		typ = types.NewOpaquePointer(0)
+	}
 	// Infer address space of pointer type from indirect symbol as no explicit
 	// type/value pair is given for the indirect symbol when aliasee is a
 	// constant expression.
@@ -133,9 +139,9 @@ func (gen *generator) newIndirectSymbol(ident ir.GlobalIdent, old *ast.IndirectS
 	kind := old.IndirectSymbolKind().Text()
 	switch kind {
 	case "alias":
-		return &ir.Alias{GlobalIdent: ident, Typ: typ}, nil
+		return &ir.Alias{GlobalIdent: ident, Typ: typ, ContentType: contentType}, nil
 	case "ifunc":
-		return &ir.IFunc{GlobalIdent: ident, Typ: typ}, nil
+		return &ir.IFunc{GlobalIdent: ident, Typ: typ, ContentType: contentType}, nil
 	default:
 		panic(fmt.Errorf("support for indirect symbol kind %q not yet implemented", kind))
 	}
@@ -150,6 +156,9 @@ func (gen *generator) newFunc(ident ir.GlobalIdent, hdr ast.FuncHeader) (*ir.Fun
 		return nil, errors.WithStack(err)
 	}
 	typ := types.NewPointer(sig)
+	if gen.opaquePointers {
+		typ = types.NewOpaquePointer(0)
+	}
 	// (optional) Address space.
 	var addrSpace types.AddrSpace
 	if n, ok := hdr.AddrSpace(); ok {
@@ -22,6 +22,13 @@ func (fgen *funcGen) newAllocaInst(ident ir.LocalIdent, old *ast.AllocaInst) (*i
 		return nil, errors.WithStack(err)
 	}
 	inst := &ir.InstAlloca{LocalIdent: ident, ElemType: elemType}
+	// (optional) Address space; stored in inst.Typ.
+	if n, ok := old.AddrSpace(); ok {
+		inst.AddrSpace = irAddrSpace(n)
+	}
+	if fgen.gen.opaquePointers {
+		inst.Typ = types.NewOpaquePointer(inst.AddrSpace)
+	}
 	// Cache inst.Typ.
 	inst.Type()
 	return inst, nil
@@ -51,15 +58,13 @@ func (fgen *funcGen) newCmpXchgInst(ident ir.LocalIdent, old *ast.CmpXchgInst) (
 // newAtomicRMWInst returns a new IR atomicrmw instruction (without body but
 // with type) based on the given AST atomicrmw instruction.
 func (fgen *funcGen) newAtomicRMWInst(ident ir.LocalIdent, old *ast.AtomicRMWInst) (*ir.InstAtomicRMW, error) {
-	dstType, err := fgen.gen.irType(old.Dst().Typ())
+	// Note, the result type is given by the type of the operand, as the
+	// destination address may be of opaque pointer type.
+	typ, err := fgen.gen.irType(old.X().Typ())
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	dt, ok := dstType.(*types.PointerType)
-	if !ok {
-		panic(fmt.Errorf("invalid pointer type; expected *types.PointerType, got %T", dstType))
-	}
-	return &ir.InstAtomicRMW{LocalIdent: ident, Typ: dt.ElemType}, nil
+	return &ir.InstAtomicRMW{LocalIdent: ident, Typ: typ}, nil
 }
 
 // newGetElementPtrInst returns a new IR getelementptr instruction (without body
@@ -114,10 +119,7 @@ func (fgen *funcGen) irAllocaInst(new ir.Instruction, old *ast.AllocaInst) error
 	if n, ok := old.Align(); ok {
 		inst.Align = irAlign(n)
 	}
-	// (optional) Address space; stored in i.Typ.
-	if n, ok := old.AddrSpace(); ok {
-		inst.AddrSpace = irAddrSpace(n)
-	}
+	// (optional) Address space; handled in newAllocaInst.
 	// (optional) Metadata.
 	md, err := fgen.gen.irMetadataAttachments(old.Metadata())
 	if err != nil {
@@ -316,6 +316,11 @@ func (fgen *funcGen) irCallInst(new ir.Instruction, old *ast.CallInst) error {
 	}
 	// The callee type is always pointer to function type.
 	ptrToSig := types.NewPointer(sig)
+	if fgen.gen.opaquePointers {
+		// The function signature may not be derived from the type of callees
+		// of opaque pointer type.
+		inst.FuncType = sig
+	}
 	callee, err := fgen.irValue(ptrToSig, old.Callee())
 	if err != nil {
 		return errors.WithStack(err)
@@ -0,0 +1,105 @@
+package asm
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/llir/ll"
+	"github.com/llir/ll/ast"
+	"github.com/llir/llvm/ir/types"
+)
+
+// opaquePointerPlaceholder is the placeholder named type used to represent
+// opaque pointer types (e.g. `ptr` and `ptr addrspace(1)`) in the input of the
+// LLVM IR parser.
+//
+// The LLVM IR grammar of llir/ll does not yet support opaque pointer types, and
+// its generated parser tables may not be extended from this package. As a
+// work-around, each opaque pointer type of the input is overwritten in place by
+// the placeholder named type before parsing; the remaining bytes of the opaque
+// pointer type are overwritten by spaces (line breaks are kept), so that the
+// line and column of each token, and thus of each parse error, are unchanged.
+//
+// Placeholder named types are identified by their offset in the input and not
+// by their name, so the placeholder never resolves to an opaque pointer type
+// when written in the input.
+const opaquePointerPlaceholder = `%""`
+
+// rewriteOpaquePointers overwrites each opaque pointer type of the given LLVM
+// IR assembly with a placeholder named type; see opaquePointerPlaceholder. The
+// returned map is keyed by the offset of each placeholder named type and holds
+// the address space of the corresponding opaque pointer type. The map is nil if
+// the LLVM IR assembly contains no opaque pointer types.
+func rewriteOpaquePointers(content string) (string, map[int]types.AddrSpace) {
+	// Fast path; no opaque pointer types present.
+	if !strings.Contains(content, "ptr") {
+		return content, nil
+	}
+	// token is a lexical token with start and end offset.
+	type token struct {
+		kind       ll.Token
+		start, end int
+	}
+	var (
+		toks  []token
+		l     ll.Lexer
+		found bool
+	)
+	l.Init(content)
+	for {
+		kind := l.Next()
+		if kind == ll.EOI {
+			break
+		}
+		if kind == ll.PTR {
+			found = true
+		}
+		start, end := l.Pos()
+		toks = append(toks, token{kind: kind, start: start, end: end})
+	}
+	if !found {
+		return content, nil
+	}
+	buf := []byte(content)
+	ptrs := make(map[int]types.AddrSpace)
+	for i := 0; i < len(toks); i++ {
+		tok := toks[i]
+		if tok.kind != ll.PTR {
+			continue
+		}
+		// 'ptr' AddrSpaceopt
+		end := tok.end
+		addrSpace := types.AddrSpace(0)
+		if i+4 < len(toks) && toks[i+1].kind == ll.ADDRSPACE && toks[i+2].kind == ll.LPAREN && toks[i+3].kind == ll.INT_LIT_TOK && toks[i+4].kind == ll.RPAREN {
+			s := content[toks[i+3].start:toks[i+3].end]
+			x, err := strconv.ParseUint(s, 10, 64)
+			if err != nil {
+				// Leave invalid address spaces to the parser.
+				continue
+			}
+			addrSpace = types.AddrSpace(x)
+			end = toks[i+4].end
+			i += 4
+		}
+		// len("ptr") == len(opaquePointerPlaceholder)
+		copy(buf[tok.start:], opaquePointerPlaceholder)
+		for j := tok.start + len(opaquePointerPlaceholder); j < end; j++ {
+			if buf[j] != '\n' && buf[j] != '\r' {
+				buf[j] = ' '
+			}
+		}
+		ptrs[tok.start] = addrSpace
+	}
+	return string(buf), ptrs
+}
+
+// opaquePointerType returns the opaque pointer type corresponding to the given
+// AST named type. The boolean return value reports whether old is a placeholder
+// named type of an opaque pointer type.
+func opaquePointerType(old *ast.NamedType, ptrs map[int]types.AddrSpace) (*types.PointerType, bool) {
+	addrSpace, ok := ptrs[old.Offset()]
+	if !ok {
+		return nil, false
+	}
+	return types.NewOpaquePointer(addrSpace), true
+}
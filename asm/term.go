@@ -363,6 +363,11 @@ func (fgen *funcGen) irInvokeTerm(new ir.Terminator, old *ast.InvokeTerm) error
 	}
 	// The invokee type is always pointer to function type.
 	ptrToSig := types.NewPointer(sig)
+	if fgen.gen.opaquePointers {
+		// The function signature may not be derived from the type of invokees
+		// of opaque pointer type.
+		term.FuncType = sig
+	}
 	invokee, err := fgen.irValue(ptrToSig, old.Invokee())
 	if err != nil {
 		return errors.WithStack(err)
@@ -464,6 +469,11 @@ func (fgen *funcGen) irCallBrTerm(new ir.Terminator, old *ast.CallBrTerm) error
 	}
 	// The callee type is always pointer to function type.
 	ptrToSig := types.NewPointer(sig)
+	if fgen.gen.opaquePointers {
+		// The function signature may not be derived from the type of callees
+		// of opaque pointer type.
+		term.FuncType = sig
+	}
 	callee, err := fgen.irValue(ptrToSig, old.Callee())
 	if err != nil {
 		return errors.WithStack(err)
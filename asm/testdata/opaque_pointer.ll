@@ -0,0 +1,31 @@
+%struct.t = type { i32, ptr }
+
+@x = global i32 42
+@y = global ptr @x
+@z = addrspace(1) global ptr addrspace(1) null
+@ptrs = global [2 x ptr] [ptr @x, ptr @y]
+
+@a = alias i32, ptr @x
+
+declare ptr @malloc(i64 %size)
+
+declare i32 @printf(ptr %format, ...)
+
+define i32 @f(ptr %p, ptr addrspace(1) %q) {
+0:
+	%1 = alloca ptr
+	%2 = alloca i32, addrspace(5)
+	store ptr %p, ptr %1
+	%3 = load ptr, ptr %1
+	%4 = getelementptr %struct.t, ptr %3, i64 0, i32 1
+	%5 = load ptr, ptr %4
+	%6 = load i32, ptr %5
+	%7 = load i32, ptr addrspace(1) %q
+	%8 = call ptr @malloc(i64 8)
+	%9 = call i32 (ptr, ...) @printf(ptr %8, i32 %6)
+	%10 = atomicrmw add ptr @x, i32 1 seq_cst
+	%11 = getelementptr i32, ptr @x, i64 1
+	%12 = add i32 %6, %7
+	%13 = call ptr %p(i32 %12)
+	ret i32 %12
+}
@@ -97,9 +97,15 @@ import (
 	"github.com/pkg/errors"
 )
 
-// translate translates the given AST module into an equivalent IR module.
-func translate(old *ast.Module) (*ir.Module, error) {
+// translate translates the given AST module into an equivalent IR module. The
+// opaquePtrs parameter maps from offset of placeholder named type to address
+// space of opaque pointer type; see opaquePointerPlaceholder. If the module uses
+// opaque pointer types, global variables, functions, aliases, IFuncs and alloca
+// instructions are translated to values of opaque pointer type.
+func translate(old *ast.Module, opaquePtrs map[int]types.AddrSpace) (*ir.Module, error) {
 	gen := newGenerator()
+	gen.opaquePointers = len(opaquePtrs) > 0
+	gen.opaquePtrs = opaquePtrs
 	// 1. Index AST top-level entities.
 	indexStart := time.Now()
 	if err := gen.translateTargetDefs(old); err != nil {
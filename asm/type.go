@@ -41,7 +41,7 @@ func (gen *generator) createTypeDefs() error {
 	for typeName, old := range gen.old.typeDefs {
 		// track is used to identify self-referential named types.
 		track := make(map[string]bool)
-		t, err := newType(typeName, old.Typ(), gen.old.typeDefs, track)
+		t, err := newType(typeName, old.Typ(), gen.old.typeDefs, gen.opaquePtrs, track)
 		if err != nil {
 			return errors.WithStack(err)
 		}
@@ -67,7 +67,7 @@ func (gen *generator) createTypeDefs() error {
 //
 //	; struct type containing pointer to itself.
 //	%d = type { %d* }
-func newType(typeName string, old ast.LlvmNode, index map[string]*ast.TypeDef, track map[string]bool) (types.Type, error) {
+func newType(typeName string, old ast.LlvmNode, index map[string]*ast.TypeDef, ptrs map[int]types.AddrSpace, track map[string]bool) (types.Type, error) {
 	switch old := old.(type) {
 	case *ast.VoidType:
 		return &types.VoidType{TypeName: typeName}, nil
@@ -106,11 +106,15 @@ func newType(typeName string, old ast.LlvmNode, index map[string]*ast.TypeDef, t
 			sort.Strings(names)
 			return nil, errors.Errorf("invalid named type; self-referential with type name(s) %s", strings.Join(names, ", "))
 		}
+		// Opaque pointer type; see opaquePointerPlaceholder.
+		if _, ok := opaquePointerType(old, ptrs); ok {
+			return &types.PointerType{TypeName: typeName}, nil
+		}
 		track[typeName] = true
 		newIdent := localIdent(old.Name())
 		newName := getTypeName(newIdent)
 		newTyp := index[newName].Typ()
-		return newType(newName, newTyp, index, track)
+		return newType(newName, newTyp, index, ptrs, track)
 	default:
 		panic(fmt.Errorf("support for type %T not yet implemented", old))
 	}
@@ -509,6 +513,18 @@ func (gen *generator) irPackedStructType(t types.Type, old *ast.PackedStructType
 // irNamedType translates the AST named type into an equivalent IR type.
 func (gen *generator) irNamedType(t types.Type, old *ast.NamedType) (types.Type, error) {
 	// TODO: make use of t?
+	// Resolve opaque pointer type; see opaquePointerPlaceholder.
+	if ptr, ok := opaquePointerType(old, gen.opaquePtrs); ok {
+		if t == nil {
+			return ptr, nil
+		}
+		typ, ok := t.(*types.PointerType)
+		if !ok {
+			panic(fmt.Errorf("invalid IR type for AST opaque pointer type; expected *types.PointerType, got %T", t))
+		}
+		typ.AddrSpace = ptr.AddrSpace
+		return typ, nil
+	}
 	// Resolve named type.
 	ident := localIdent(old.Name())
 	name := getTypeName(ident)
@@ -0,0 +1,337 @@
+package bitcode
+
+import (
+	"github.com/llir/llvm/internal/bitc"
+	"github.com/llir/llvm/internal/bitstream"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/pkg/errors"
+)
+
+// attrGroup is an attribute group of a PARAMATTR_GROUP_BLOCK.
+//
+// Attribute groups are decoded on first use, as type attributes may refer to
+// types of the type table, which is stored after the attribute tables.
+type attrGroup struct {
+	// Attribute group ID.
+	id uint64
+	// Parameter index; either bitc.AttrIndexFunction, bitc.AttrIndexReturn or
+	// the parameter index plus bitc.AttrIndexFirstParam.
+	index uint64
+	// Encoded attributes.
+	ops []uint64
+
+	// Decoded function attributes, as an attribute group definition; nil if not
+	// yet decoded.
+	def *ir.AttrGroupDef
+	// Decoded return attributes.
+	retAttrs []ir.ReturnAttribute
+	// Decoded parameter attributes.
+	paramAttrs []ir.ParamAttribute
+	// Attribute group has been decoded.
+	decoded bool
+}
+
+// attrSet is an attribute set of a PARAMATTR_BLOCK, consisting of one attribute
+// group per function, return value or parameter.
+type attrSet []*attrGroup
+
+// parseParamAttrGroupBlock parses a PARAMATTR_GROUP_BLOCK.
+func (d *decoder) parseParamAttrGroupBlock() error {
+	if err := d.r.EnterBlock(); err != nil {
+		return errors.WithStack(err)
+	}
+	return d.parseRecords(func(record *bitstream.Record) error {
+		if record.Code != bitc.ParamAttrGrpCodeEntry {
+			return nil
+		}
+		// ENTRY: [grpid, idx, attr0, attr1, ...]
+		if len(record.Ops) < 2 {
+			return errors.Errorf("invalid attribute group entry; expected at least 2 operands, got %d", len(record.Ops))
+		}
+		g := &attrGroup{
+			id:    record.Ops[0],
+			index: record.Ops[1],
+			ops:   record.Ops[2:],
+		}
+		d.attrGroups[g.id] = g
+		return nil
+	})
+}
+
+// parseParamAttrBlock parses a PARAMATTR_BLOCK.
+func (d *decoder) parseParamAttrBlock() error {
+	if err := d.r.EnterBlock(); err != nil {
+		return errors.WithStack(err)
+	}
+	return d.parseRecords(func(record *bitstream.Record) error {
+		switch record.Code {
+		case bitc.ParamAttrCodeEntry:
+			// ENTRY: [attrgrp0, attrgrp1, ...]
+			var set attrSet
+			for _, id := range record.Ops {
+				g, ok := d.attrGroups[id]
+				if !ok {
+					return errors.Errorf("invalid attribute group ID %d", id)
+				}
+				set = append(set, g)
+			}
+			d.attrSets = append(d.attrSets, set)
+		case bitc.ParamAttrCodeEntryOld:
+			return errors.New("support for legacy attribute entries (PARAMATTR_CODE_ENTRY_OLD) not yet implemented")
+		}
+		return nil
+	})
+}
+
+// attrSetAt returns the attribute set with the given 1-based ID; or nil if id
+// is 0.
+func (d *decoder) attrSetAt(id uint64) (attrSet, error) {
+	if id == 0 {
+		return nil, nil
+	}
+	if id-1 >= uint64(len(d.attrSets)) {
+		return nil, errors.Errorf("invalid attribute set ID %d; exceeds number of attribute sets (%d)", id, len(d.attrSets))
+	}
+	set := d.attrSets[id-1]
+	for _, g := range set {
+		if err := d.decodeAttrGroup(g); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return set, nil
+}
+
+// attrs holds the decoded attributes of an attribute set.
+type attrs struct {
+	// Function attributes.
+	funcAttrs []ir.FuncAttribute
+	// Return attributes.
+	retAttrs []ir.ReturnAttribute
+	// Parameter attributes, indexed by parameter index.
+	paramAttrs map[int][]ir.ParamAttribute
+}
+
+// attrsOf returns the decoded attributes of the attribute set with the given
+// 1-based ID.
+func (d *decoder) attrsOf(id uint64) (*attrs, error) {
+	set, err := d.attrSetAt(id)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	as := &attrs{paramAttrs: make(map[int][]ir.ParamAttribute)}
+	for _, g := range set {
+		switch g.index {
+		case bitc.AttrIndexFunction:
+			as.funcAttrs = append(as.funcAttrs, g.def)
+		case bitc.AttrIndexReturn:
+			as.retAttrs = append(as.retAttrs, g.retAttrs...)
+		default:
+			i := int(g.index - bitc.AttrIndexFirstParam)
+			as.paramAttrs[i] = append(as.paramAttrs[i], g.paramAttrs...)
+		}
+	}
+	return as, nil
+}
+
+// decodeAttrGroup decodes the attributes of the given attribute group.
+func (d *decoder) decodeAttrGroup(g *attrGroup) error {
+	if g.decoded {
+		return nil
+	}
+	g.decoded = true
+	if g.index == bitc.AttrIndexFunction {
+		g.def = &ir.AttrGroupDef{ID: -1}
+	}
+	ops := g.ops
+	for len(ops) > 0 {
+		kind := ops[0]
+		ops = ops[1:]
+		switch kind {
+		case bitc.AttrEntryEnum, bitc.AttrEntryInt, bitc.AttrEntryType, bitc.AttrEntryTypeWithType:
+			if len(ops) < 1 {
+				return errors.Errorf("invalid attribute in attribute group %d; missing attribute kind", g.id)
+			}
+			attrKind := ops[0]
+			ops = ops[1:]
+			var val uint64
+			var typ types.Type
+			switch kind {
+			case bitc.AttrEntryInt:
+				if len(ops) < 1 {
+					return errors.Errorf("invalid integer attribute in attribute group %d; missing value", g.id)
+				}
+				val = ops[0]
+				ops = ops[1:]
+			case bitc.AttrEntryTypeWithType:
+				if len(ops) < 1 {
+					return errors.Errorf("invalid type attribute in attribute group %d; missing type", g.id)
+				}
+				t, err := d.typeAt(ops[0])
+				if err != nil {
+					return errors.WithStack(err)
+				}
+				typ = t
+				ops = ops[1:]
+			}
+			if err := g.addKindAttr(attrKind, val, typ); err != nil {
+				return errors.Wrapf(err, "invalid attribute in attribute group %d", g.id)
+			}
+		case bitc.AttrEntryString, bitc.AttrEntryKeyValue:
+			var key, val string
+			key, ops = readCString(ops)
+			if kind == bitc.AttrEntryKeyValue {
+				val, ops = readCString(ops)
+			}
+			g.addStringAttr(key, val, kind == bitc.AttrEntryKeyValue)
+		default:
+			return errors.Errorf("invalid attribute entry kind %d in attribute group %d", kind, g.id)
+		}
+	}
+	return nil
+}
+
+// addStringAttr adds the string attribute with the given key and optional
+// value to the attribute group.
+func (g *attrGroup) addStringAttr(key, val string, hasVal bool) {
+	// Note, ir.AttrString and ir.AttrPair implement the function, parameter and
+	// return attribute interfaces.
+	var attr ir.FuncAttribute = ir.AttrString(key)
+	if hasVal {
+		attr = ir.AttrPair{Key: key, Value: val}
+	}
+	switch g.index {
+	case bitc.AttrIndexFunction:
+		g.def.FuncAttrs = append(g.def.FuncAttrs, attr)
+	case bitc.AttrIndexReturn:
+		g.retAttrs = append(g.retAttrs, attr.(ir.ReturnAttribute))
+	default:
+		g.paramAttrs = append(g.paramAttrs, attr.(ir.ParamAttribute))
+	}
+}
+
+// addKindAttr adds the attribute of the given attribute kind to the attribute
+// group. The val and typ parameters hold the value of integer attributes and
+// the type of type attributes, respectively.
+func (g *attrGroup) addKindAttr(kind, val uint64, typ types.Type) error {
+	switch g.index {
+	case bitc.AttrIndexFunction:
+		attr, err := funcAttr(kind, val, typ)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		g.def.FuncAttrs = append(g.def.FuncAttrs, attr)
+	case bitc.AttrIndexReturn:
+		attr, err := returnAttr(kind, val)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		g.retAttrs = append(g.retAttrs, attr)
+	default:
+		attr, err := paramAttr(kind, val, typ)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		g.paramAttrs = append(g.paramAttrs, attr)
+	}
+	return nil
+}
+
+// funcAttr returns the function attribute of the given attribute kind.
+func funcAttr(kind, val uint64, typ types.Type) (ir.FuncAttribute, error) {
+	switch kind {
+	case bitc.AttrKindAlignment:
+		return ir.Align(val), nil
+	case bitc.AttrKindStackAlignment:
+		return ir.AlignStack(val), nil
+	case bitc.AttrKindAllocKind:
+		return ir.AllocKind{Kind: enum.AllocKind(val)}, nil
+	case bitc.AttrKindAllocSize:
+		// Note, a missing number of elements parameter index is encoded as
+		// 0xFFFFFFFF.
+		nElemsIndex := int(val & 0xFFFFFFFF)
+		if nElemsIndex == 0xFFFFFFFF {
+			nElemsIndex = -1
+		}
+		return ir.AllocSize{ElemSizeIndex: int(val >> 32), NElemsIndex: nElemsIndex}, nil
+	case bitc.AttrKindPreallocated:
+		return ir.Preallocated{Typ: typ}, nil
+	case bitc.AttrKindUWTable:
+		// Note, the kind of unwind table is only stored by LLVM 15.0 and later;
+		// 2 (async) is the default.
+		if val == uint64(enum.UnwindTableKindSync) {
+			return ir.UnwindTable{Kind: enum.UnwindTableKindSync}, nil
+		}
+		return ir.UnwindTable{Kind: enum.UnwindTableKindNone}, nil
+	case bitc.AttrKindVScaleRange:
+		return ir.VectorScaleRange{Min: int(val >> 32), Max: int(val & 0xFFFFFFFF)}, nil
+	}
+	for attr, k := range bitc.FuncAttrKinds {
+		if k == kind {
+			return attr, nil
+		}
+	}
+	return nil, errors.Errorf("unsupported function attribute kind %d", kind)
+}
+
+// paramAttr returns the parameter attribute of the given attribute kind.
+func paramAttr(kind, val uint64, typ types.Type) (ir.ParamAttribute, error) {
+	switch kind {
+	case bitc.AttrKindAlignment:
+		return ir.Align(val), nil
+	case bitc.AttrKindStackAlignment:
+		return ir.AlignStack(val), nil
+	case bitc.AttrKindByRef:
+		return ir.ByRef{Typ: typ}, nil
+	case bitc.AttrKindByVal:
+		return ir.Byval{Typ: typ}, nil
+	case bitc.AttrKindDereferenceable:
+		return ir.Dereferenceable{N: val}, nil
+	case bitc.AttrKindDereferenceableOrNull:
+		return ir.Dereferenceable{N: val, DerefOrNull: true}, nil
+	case bitc.AttrKindElementType:
+		return ir.ElementType{Typ: typ}, nil
+	case bitc.AttrKindInAlloca:
+		return ir.InAlloca{Typ: typ}, nil
+	case bitc.AttrKindPreallocated:
+		return ir.Preallocated{Typ: typ}, nil
+	case bitc.AttrKindStructRet:
+		return ir.SRet{Typ: typ}, nil
+	}
+	for attr, k := range bitc.ParamAttrKinds {
+		if k == kind {
+			return attr, nil
+		}
+	}
+	return nil, errors.Errorf("unsupported parameter attribute kind %d", kind)
+}
+
+// returnAttr returns the return attribute of the given attribute kind.
+func returnAttr(kind, val uint64) (ir.ReturnAttribute, error) {
+	switch kind {
+	case bitc.AttrKindAlignment:
+		return ir.Align(val), nil
+	case bitc.AttrKindDereferenceable:
+		return ir.Dereferenceable{N: val}, nil
+	case bitc.AttrKindDereferenceableOrNull:
+		return ir.Dereferenceable{N: val, DerefOrNull: true}, nil
+	}
+	for attr, k := range bitc.ReturnAttrKinds {
+		if k == kind {
+			return attr, nil
+		}
+	}
+	return nil, errors.Errorf("unsupported return attribute kind %d", kind)
+}
+
+// readCString reads a null-terminated string from the given operands, and
+// returns the remaining operands.
+func readCString(ops []uint64) (string, []uint64) {
+	for i, op := range ops {
+		if op == 0 {
+			return recordString(ops[:i]), ops[i+1:]
+		}
+	}
+	return recordString(ops), nil
+}
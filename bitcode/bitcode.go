@@ -0,0 +1,193 @@
+// Package bitcode implements a reader for LLVM IR bitcode files.
+//
+// References:
+//
+//	https://llvm.org/docs/BitCodeFormat.html
+package bitcode
+
+import (
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"log"
+	"time"
+
+	"github.com/llir/llvm/internal/bitc"
+	"github.com/llir/llvm/internal/bitstream"
+	"github.com/llir/llvm/ir"
+	"github.com/pkg/errors"
+)
+
+var (
+	// dbg is a logger which logs debug messages with "bitcode:" prefix to
+	// standard error.
+	dbg = log.New(ioutil.Discard, "", 0)
+	//dbg = log.New(os.Stderr, term.MagentaBold("bitcode:")+" ", 0)
+)
+
+// ParseFile parses the given LLVM IR bitcode file into an LLVM IR module.
+func ParseFile(path string) (*ir.Module, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return ParseBytes(path, buf)
+}
+
+// Parse parses the given LLVM IR bitcode file into an LLVM IR module, reading
+// from r. An optional path to the source file may be specified for error
+// reporting.
+func Parse(path string, r io.Reader) (*ir.Module, error) {
+	buf, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return ParseBytes(path, buf)
+}
+
+// ParseBytes parses the given LLVM IR bitcode file into an LLVM IR module,
+// reading from b. An optional path to the source file may be specified for
+// error reporting.
+func ParseBytes(path string, b []byte) (*ir.Module, error) {
+	parseStart := time.Now()
+	m, err := decode(b)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse %q", path)
+	}
+	dbg.Println("parsing bitcode took:", time.Since(parseStart))
+	return m, nil
+}
+
+// decode decodes the given LLVM IR bitcode file into an LLVM IR module.
+func decode(b []byte) (*ir.Module, error) {
+	b, err := stripWrapper(b)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(b) < len(bitc.Magic) || string(b[:len(bitc.Magic)]) != bitc.Magic {
+		return nil, errors.New("invalid bitcode file; missing magic number")
+	}
+	d := newDecoder(bitstream.NewReader(b[len(bitc.Magic):]))
+	// Top-level blocks.
+	hasModule := false
+	for !d.r.AtEnd() {
+		entry, err := d.r.Next()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if entry.Kind != bitstream.EntrySubBlock {
+			return nil, errors.Errorf("invalid top-level entry kind %d; expected sub-block", entry.Kind)
+		}
+		switch entry.BlockID {
+		case bitc.IdentificationBlockID:
+			if err := d.parseIdentificationBlock(); err != nil {
+				return nil, errors.WithStack(err)
+			}
+		case bitc.ModuleBlockID:
+			if hasModule {
+				return nil, errors.New("support for bitcode files containing multiple modules not yet implemented")
+			}
+			hasModule = true
+			if err := d.parseModuleBlock(); err != nil {
+				return nil, errors.WithStack(err)
+			}
+		case bitc.StrtabBlockID:
+			if err := d.parseStrtabBlock(); err != nil {
+				return nil, errors.WithStack(err)
+			}
+		default:
+			// Skip SYMTAB_BLOCK and unknown blocks.
+			if err := d.r.SkipBlock(); err != nil {
+				return nil, errors.WithStack(err)
+			}
+		}
+	}
+	if !hasModule {
+		return nil, errors.New("invalid bitcode file; missing module block")
+	}
+	if err := d.finish(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return d.m, nil
+}
+
+// stripWrapper returns the bitcode contained within the given bitcode wrapper,
+// or b itself if not wrapped.
+//
+// Bitcode wrapper header:
+//
+//	[magic, version, offset, size, cputype] (5 x uint32, little-endian)
+func stripWrapper(b []byte) ([]byte, error) {
+	const headerSize = 5 * 4
+	if len(b) < headerSize || binary.LittleEndian.Uint32(b) != bitc.WrapperMagic {
+		return b, nil
+	}
+	offset := uint64(binary.LittleEndian.Uint32(b[8:]))
+	size := uint64(binary.LittleEndian.Uint32(b[12:]))
+	if offset+size > uint64(len(b)) {
+		return nil, errors.Errorf("invalid bitcode wrapper; offset (%d) and size (%d) exceed end of file (%d)", offset, size, len(b))
+	}
+	return b[offset : offset+size], nil
+}
+
+// --- [ Identification block ] ------------------------------------------------
+
+// parseIdentificationBlock parses an IDENTIFICATION_BLOCK.
+func (d *decoder) parseIdentificationBlock() error {
+	if err := d.r.EnterBlock(); err != nil {
+		return errors.WithStack(err)
+	}
+	return d.parseRecords(func(record *bitstream.Record) error {
+		switch record.Code {
+		case bitc.IdentificationCodeString:
+			dbg.Println("producer:", recordString(record.Ops))
+		case bitc.IdentificationCodeEpoch:
+			if len(record.Ops) < 1 {
+				return errors.New("invalid EPOCH record; missing epoch")
+			}
+			// Only epoch 0 is defined.
+			if epoch := record.Ops[0]; epoch != 0 {
+				return errors.Errorf("unsupported bitcode epoch %d; expected 0", epoch)
+			}
+		}
+		return nil
+	})
+}
+
+// --- [ String table block ] --------------------------------------------------
+
+// parseStrtabBlock parses a STRTAB_BLOCK.
+func (d *decoder) parseStrtabBlock() error {
+	if err := d.r.EnterBlock(); err != nil {
+		return errors.WithStack(err)
+	}
+	return d.parseRecords(func(record *bitstream.Record) error {
+		if record.Code == bitc.StrtabBlob {
+			d.strtab = record.Blob
+		}
+		return nil
+	})
+}
+
+// parseRecords parses the records of the current block, invoking f for each
+// record. Sub-blocks are skipped.
+func (d *decoder) parseRecords(f func(record *bitstream.Record) error) error {
+	for {
+		entry, err := d.r.Next()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		switch entry.Kind {
+		case bitstream.EntryEndBlock:
+			return nil
+		case bitstream.EntrySubBlock:
+			if err := d.r.SkipBlock(); err != nil {
+				return errors.WithStack(err)
+			}
+		case bitstream.EntryRecord:
+			if err := f(entry.Record); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	}
+}
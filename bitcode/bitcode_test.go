@@ -0,0 +1,132 @@
+package bitcode
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/internal/osutil"
+	"github.com/llir/llvm/ir"
+)
+
+// golden holds the bitcode test cases. The bitcode files were produced by
+// llvm-as from the LLVM IR assembly files.
+var golden = []struct {
+	// Path to bitcode file.
+	path string
+	// Path to LLVM IR assembly file of the bitcode file.
+	llPath string
+}{
+	{path: "testdata/hexfloat.bc", llPath: "../asm/testdata/hexfloat.ll"},
+	{path: "testdata/hexint.bc", llPath: "../asm/testdata/hexint.ll"},
+	{path: "testdata/inst_aggregate.bc", llPath: "../asm/testdata/inst_aggregate.ll"},
+	{path: "testdata/inst_binary.bc", llPath: "../asm/testdata/inst_binary.ll"},
+	{path: "testdata/inst_bitwise.bc", llPath: "../asm/testdata/inst_bitwise.ll"},
+	{path: "testdata/inst_conversion.bc", llPath: "../asm/testdata/inst_conversion.ll"},
+	{path: "testdata/inst_memory.bc", llPath: "../asm/testdata/inst_memory.ll"},
+	{path: "testdata/inst_vector.bc", llPath: "../asm/testdata/inst_vector.ll"},
+	{path: "testdata/terminator.bc", llPath: "../asm/testdata/terminator.ll"},
+	{path: "testdata/rand.bc", llPath: "../asm/testdata/rand.ll"},
+
+	// function alignment.
+	{path: "testdata/func_align.bc", llPath: "../asm/testdata/func_align.ll"},
+
+	// global alignment.
+	{path: "testdata/global_align.bc", llPath: "../asm/testdata/global_align.ll"},
+
+	// opaque pointer types.
+	{path: "testdata/opaque_pointer.bc", llPath: "../asm/testdata/opaque_pointer.ll"},
+
+	// instructions and terminators, including forward references, exception
+	// handling and operand bundles.
+	{path: "testdata/instructions.bc", llPath: "testdata/instructions.ll"},
+
+	// specialized metadata, metadata attachments and function-local
+	// metadata.
+	{path: "testdata/metadata.bc", llPath: "testdata/metadata.ll"},
+}
+
+func TestParseFile(t *testing.T) {
+	for _, g := range golden {
+		m, err := ParseFile(g.path)
+		if err != nil {
+			t.Errorf("unable to parse %q; %+v", g.path, err)
+			continue
+		}
+		// Compare against the LLVM IR assembly of the bitcode file, or a golden
+		// file if the output differs.
+		goldenPath := strings.TrimSuffix(g.path, ".bc") + ".ll.golden"
+		want, err := readLL(g.llPath, goldenPath)
+		if err != nil {
+			t.Errorf("unable to read %q; %+v", g.llPath, err)
+			continue
+		}
+		// llvm-as records the path of the assembly file as the source filename
+		// of modules without one.
+		if m.SourceFilename == g.llPath {
+			m.SourceFilename = ""
+		}
+		got := m.String()
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("module %q mismatch (-want +got):\n%s", g.path, diff)
+			continue
+		}
+	}
+}
+
+// readLL returns the contents of the given golden file if present, and
+// otherwise the given LLVM IR assembly file as printed by the asm package.
+func readLL(llPath, goldenPath string) (string, error) {
+	if osutil.Exists(goldenPath) {
+		buf, err := ioutil.ReadFile(goldenPath)
+		if err != nil {
+			return "", err
+		}
+		return string(buf), nil
+	}
+	m, err := asm.ParseFile(llPath)
+	if err != nil {
+		return "", err
+	}
+	return m.String(), nil
+}
+
+func TestWriteBitcode(t *testing.T) {
+	for _, g := range golden {
+		m, err := ParseFile(g.path)
+		if err != nil {
+			t.Errorf("unable to parse %q; %+v", g.path, err)
+			continue
+		}
+		want := m.String()
+		var buf bytes.Buffer
+		opts := ir.BitcodeOptions{Stable: true}
+		if err := m.WriteBitcodeWithOptions(&buf, opts); err != nil {
+			t.Errorf("unable to write bitcode of %q; %+v", g.path, err)
+			continue
+		}
+		// Parse the written bitcode and compare against the original module.
+		m2, err := ParseBytes(g.path, buf.Bytes())
+		if err != nil {
+			t.Errorf("unable to parse bitcode written from %q; %+v", g.path, err)
+			continue
+		}
+		got := m2.String()
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Errorf("module %q mismatch (-want +got):\n%s", g.path, diff)
+			continue
+		}
+		// Byte-stable output.
+		var buf2 bytes.Buffer
+		if err := m2.WriteBitcodeWithOptions(&buf2, opts); err != nil {
+			t.Errorf("unable to write bitcode of %q; %+v", g.path, err)
+			continue
+		}
+		if !bytes.Equal(buf.Bytes(), buf2.Bytes()) {
+			t.Errorf("bitcode of %q not byte-stable", g.path)
+		}
+	}
+}
@@ -0,0 +1,650 @@
+package bitcode
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/llir/llvm/internal/bitc"
+	"github.com/llir/llvm/internal/bitstream"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/pkg/errors"
+)
+
+// cstEntry is an entry of a CONSTANTS_BLOCK, which has not yet been decoded.
+type cstEntry struct {
+	// Type of the constant (as specified by the preceding SETTYPE record).
+	typ types.Type
+	// Constant record.
+	record *bitstream.Record
+	// The constant is being decoded; used to detect cycles.
+	decoding bool
+}
+
+// cstDecoder decodes the constants of a CONSTANTS_BLOCK.
+type cstDecoder struct {
+	*decoder
+	// Value table, indexed by value ID.
+	values []value.Value
+	// Value ID of the first constant of the constants block.
+	start int
+	// Entries of the constants block, indexed by value ID - start.
+	entries []*cstEntry
+}
+
+// parseConstantsBlock parses a CONSTANTS_BLOCK, appending the constants to the
+// given value table.
+//
+// Constants may refer to constants defined later in the block. Therefore, the
+// records of the block are collected first and then decoded recursively.
+func (d *decoder) parseConstantsBlock(values []value.Value) ([]value.Value, error) {
+	if err := d.r.EnterBlock(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	c := &cstDecoder{decoder: d, start: len(values)}
+	var typ types.Type
+	err := d.parseRecords(func(record *bitstream.Record) error {
+		if record.Code == bitc.CstCodeSetType {
+			// SETTYPE: [typeid]
+			if len(record.Ops) < 1 {
+				return errors.New("invalid SETTYPE record; missing type")
+			}
+			t, err := d.typeAt(record.Ops[0])
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			typ = t
+			return nil
+		}
+		if typ == nil {
+			return errors.Errorf("invalid constant %s; missing type", describe(record))
+		}
+		c.entries = append(c.entries, &cstEntry{typ: typ, record: record})
+		values = append(values, nil)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	c.values = values
+	for i := range c.entries {
+		if _, err := c.valueAt(uint64(c.start + i)); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return c.values, nil
+}
+
+// valueAt returns the value with the given value ID, decoding the constant
+// entry of the value if not yet decoded.
+func (c *cstDecoder) valueAt(id uint64) (value.Value, error) {
+	if id >= uint64(len(c.values)) {
+		return nil, errors.Errorf("invalid value ID %d; exceeds number of values (%d)", id, len(c.values))
+	}
+	if v := c.values[id]; v != nil {
+		return v, nil
+	}
+	if id < uint64(c.start) {
+		return nil, errors.Errorf("invalid value ID %d; value not yet defined", id)
+	}
+	entry := c.entries[id-uint64(c.start)]
+	if entry.decoding {
+		return nil, errors.Errorf("invalid constant (value ID %d); cyclic constant reference", id)
+	}
+	entry.decoding = true
+	v, err := c.decodeConstant(entry.typ, entry.record)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to decode constant (value ID %d)", id)
+	}
+	c.values[id] = v
+	return v, nil
+}
+
+// constantAt returns the constant with the given value ID.
+func (c *cstDecoder) constantAt(id uint64) (constant.Constant, error) {
+	v, err := c.valueAt(id)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	cst, ok := v.(constant.Constant)
+	if !ok {
+		return nil, errors.Errorf("invalid value ID %d; expected constant, got %T", id, v)
+	}
+	return cst, nil
+}
+
+// constantsAt returns the constants with the given value IDs.
+func (c *cstDecoder) constantsAt(ids []uint64) ([]constant.Constant, error) {
+	csts := make([]constant.Constant, len(ids))
+	for i, id := range ids {
+		cst, err := c.constantAt(id)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		csts[i] = cst
+	}
+	return csts, nil
+}
+
+// decodeConstant decodes the constant of the given type and constant record.
+func (c *cstDecoder) decodeConstant(typ types.Type, record *bitstream.Record) (value.Value, error) {
+	ops := record.Ops
+	if n := minConstantOps(record.Code); len(ops) < n {
+		return nil, errors.Errorf("invalid constant %s; expected at least %d operands", describe(record), n)
+	}
+	switch record.Code {
+	case bitc.CstCodeNull:
+		return nullConstant(typ), nil
+	case bitc.CstCodeUndef:
+		return constant.NewUndef(typ), nil
+	case bitc.CstCodePoison:
+		return constant.NewPoison(typ), nil
+	case bitc.CstCodeInteger:
+		// INTEGER: [intval]
+		t, ok := typ.(*types.IntType)
+		if !ok {
+			return nil, errors.Errorf("invalid integer constant type; expected *types.IntType, got %T", typ)
+		}
+		x := decodeSigned(ops[0])
+		if t.BitSize == 1 {
+			return constant.NewBool(x != 0), nil
+		}
+		return constant.NewInt(t, x), nil
+	case bitc.CstCodeWideInteger:
+		// WIDE_INTEGER: [n x intval]
+		t, ok := typ.(*types.IntType)
+		if !ok {
+			return nil, errors.Errorf("invalid integer constant type; expected *types.IntType, got %T", typ)
+		}
+		words := make([]uint64, len(ops))
+		for i, op := range ops {
+			words[i] = uint64(decodeSigned(op))
+		}
+		return &constant.Int{Typ: t, X: wideInt(words, t.BitSize)}, nil
+	case bitc.CstCodeFloat:
+		// FLOAT: [fpval]
+		t, ok := typ.(*types.FloatType)
+		if !ok {
+			return nil, errors.Errorf("invalid floating-point constant type; expected *types.FloatType, got %T", typ)
+		}
+		return newFloat(t, ops)
+	case bitc.CstCodeAggregate:
+		// AGGREGATE: [n x value number]
+		elems, err := c.constantsAt(ops)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return newAggregate(typ, elems)
+	case bitc.CstCodeString, bitc.CstCodeCString:
+		// STRING: [values]
+		// CSTRING: [values]
+		buf := make([]byte, 0, len(ops)+1)
+		for _, op := range ops {
+			buf = append(buf, byte(op))
+		}
+		if record.Code == bitc.CstCodeCString {
+			buf = append(buf, 0)
+		}
+		return constant.NewCharArray(buf), nil
+	case bitc.CstCodeData:
+		// DATA: [n x elements]
+		return newData(typ, ops)
+	case bitc.CstCodeCEUnop:
+		// CE_UNOP: [opcode, opval]
+		x, err := c.constantAt(ops[1])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if ops[0] != bitc.UnopFNeg {
+			return nil, errors.Errorf("invalid unary operation %d", ops[0])
+		}
+		return constant.NewFNeg(x), nil
+	case bitc.CstCodeCEBinop:
+		// CE_BINOP: [opcode, opval, opval, flags]
+		x, err := c.constantAt(ops[1])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		y, err := c.constantAt(ops[2])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		var flags uint64
+		if len(ops) >= 4 {
+			flags = ops[3]
+		}
+		return newBinaryExpr(ops[0], x, y, flags)
+	case bitc.CstCodeCECast:
+		// CE_CAST: [opcode, opty, opval]
+		from, err := c.constantAt(ops[2])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return newCastExpr(ops[0], from, typ)
+	case bitc.CstCodeCEGEP, bitc.CstCodeCEInboundsGEP, bitc.CstCodeCEGEPWithInrangeIndex:
+		// CE_GEP: [pointee type, n x operands]
+		// CE_INBOUNDS_GEP: [pointee type, n x operands]
+		// CE_GEP_WITH_INRANGE_INDEX: [pointee type, flags, n x operands]
+		return c.decodeGEPExpr(record)
+	case bitc.CstCodeCESelect:
+		// CE_SELECT: [opval, opval, opval]
+		csts, err := c.constantsAt(ops[:3])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return constant.NewSelect(csts[0], csts[1], csts[2]), nil
+	case bitc.CstCodeCEExtractElt:
+		// CE_EXTRACTELT: [opty, opval, opty, opval]
+		x, err := c.constantAt(ops[1])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		index, err := c.constantAt(ops[3])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return constant.NewExtractElement(x, index), nil
+	case bitc.CstCodeCEInsertElt:
+		// CE_INSERTELT: [opval, opval, opty, opval]
+		x, err := c.constantAt(ops[0])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		elem, err := c.constantAt(ops[1])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		index, err := c.constantAt(ops[3])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return constant.NewInsertElement(x, elem, index), nil
+	case bitc.CstCodeCEShuffleVec:
+		// CE_SHUFFLEVEC: [opval, opval, opval]
+		csts, err := c.constantsAt(ops[:3])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return constant.NewShuffleVector(csts[0], csts[1], csts[2]), nil
+	case bitc.CstCodeCEShufVecEx:
+		// CE_SHUFVEC_EX: [opty, opval, opval, opval]
+		csts, err := c.constantsAt(ops[1:4])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return constant.NewShuffleVector(csts[0], csts[1], csts[2]), nil
+	case bitc.CstCodeCECmp:
+		// CE_CMP: [opty, opval, opval, pred]
+		x, err := c.constantAt(ops[1])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		y, err := c.constantAt(ops[2])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if types.IsFloatOrFloatVector(x.Type()) {
+			pred, err := decodeFPred(ops[3])
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			return constant.NewFCmp(pred, x, y), nil
+		}
+		pred, err := decodeIPred(ops[3])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return constant.NewICmp(pred, x, y), nil
+	case bitc.CstCodeBlockAddress:
+		// BLOCKADDRESS: [fnty, fnval, bb#]
+		v, err := c.valueAt(ops[1])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		f, ok := v.(*ir.Func)
+		if !ok {
+			return nil, errors.Errorf("invalid function in blockaddress constant; expected *ir.Func, got %T", v)
+		}
+		// Add dummy basic block; the basic block is resolved after decoding of
+		// function bodies.
+		cst := constant.NewBlockAddress(f, &ir.Block{})
+		if c.opaquePointers {
+			cst.Typ = types.NewOpaquePointer(0)
+		}
+		c.todo = append(c.todo, &blockAddress{c: cst, index: ops[2]})
+		return cst, nil
+	case bitc.CstCodeDSOLocalEquivalent:
+		// DSO_LOCAL_EQUIVALENT: [gvty, gv]
+		gv, err := c.constantAt(ops[1])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return constant.NewDSOLocalEquivalent(gv), nil
+	case bitc.CstCodeNoCFIValue:
+		// NO_CFI: [fty, f]
+		f, err := c.constantAt(ops[1])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return constant.NewNoCFI(f), nil
+	case bitc.CstCodeInlineAsmOld, bitc.CstCodeInlineAsmOld2, bitc.CstCodeInlineAsmOld3, bitc.CstCodeInlineAsm:
+		return c.decodeInlineAsm(typ, record)
+	default:
+		return nil, errors.Errorf("unknown constant %s", describe(record))
+	}
+}
+
+// decodeGEPExpr decodes the getelementptr constant expression of the given
+// constant record.
+func (c *cstDecoder) decodeGEPExpr(record *bitstream.Record) (*constant.ExprGetElementPtr, error) {
+	ops := record.Ops
+	var elemType types.Type
+	// Note, the pointee type is present if the number of operands is odd.
+	if record.Code == bitc.CstCodeCEGEPWithInrangeIndex || len(ops)%2 == 1 {
+		t, err := c.typeAt(ops[0])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		elemType = t
+		ops = ops[1:]
+	}
+	inBounds := record.Code == bitc.CstCodeCEInboundsGEP
+	inRangeIndex := -1
+	if record.Code == bitc.CstCodeCEGEPWithInrangeIndex {
+		if len(ops) < 1 {
+			return nil, errors.New("invalid CE_GEP_WITH_INRANGE_INDEX record; missing flags")
+		}
+		inBounds = ops[0]&1 != 0
+		inRangeIndex = int(ops[0] >> 1)
+		ops = ops[1:]
+	}
+	if len(ops) < 2 || len(ops)%2 != 0 {
+		return nil, errors.Errorf("invalid getelementptr constant expression %s", describe(record))
+	}
+	// Operands: [n x [opty, opval]]
+	var elems []constant.Constant
+	for i := 0; i < len(ops); i += 2 {
+		elem, err := c.constantAt(ops[i+1])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		elems = append(elems, elem)
+	}
+	src, indices := elems[0], elems[1:]
+	if elemType == nil {
+		t, ok := src.Type().(*types.PointerType)
+		if !ok || t.ElemType == nil {
+			return nil, errors.Errorf("invalid getelementptr source type; expected typed pointer, got %v", src.Type())
+		}
+		elemType = t.ElemType
+	}
+	if inRangeIndex >= 0 && inRangeIndex < len(indices) {
+		indices[inRangeIndex] = &constant.Index{Constant: indices[inRangeIndex], InRange: true}
+	}
+	e := constant.NewGetElementPtr(elemType, src, indices...)
+	e.InBounds = inBounds
+	return e, nil
+}
+
+// decodeInlineAsm decodes the inline assembler expression of the given
+// constant record.
+func (c *cstDecoder) decodeInlineAsm(typ types.Type, record *bitstream.Record) (*ir.InlineAsm, error) {
+	ops := record.Ops
+	if record.Code == bitc.CstCodeInlineAsm {
+		// INLINEASM: [fnty, flags, asmstr, conststr]
+		sig, err := c.funcTypeAt(ops[0])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		typ = c.newPointer(sig, 0)
+		ops = ops[1:]
+	}
+	// Flags: sideeffect | alignstack<<1 | asmdialect<<2 | unwind<<3
+	//
+	// Note, the unwind flag is not yet supported by *ir.InlineAsm.
+	if len(ops) < 2 {
+		return nil, errors.Errorf("invalid inline assembler expression %s", describe(record))
+	}
+	flags := ops[0]
+	asmStr, ops, err := readSizedString(ops[1:])
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	constraint, _, err := readSizedString(ops)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	asm := ir.NewInlineAsm(typ, asmStr, constraint)
+	asm.SideEffect = flags&1 != 0
+	asm.AlignStack = flags&2 != 0
+	if record.Code != bitc.CstCodeInlineAsmOld {
+		asm.IntelDialect = flags&4 != 0
+	}
+	return asm, nil
+}
+
+// ### [ Helper functions ] ####################################################
+
+// minConstantOps returns the minimum number of operands of constant records
+// with the given record code.
+func minConstantOps(code uint64) int {
+	switch code {
+	case bitc.CstCodeInteger, bitc.CstCodeFloat:
+		return 1
+	case bitc.CstCodeCEUnop, bitc.CstCodeDSOLocalEquivalent, bitc.CstCodeNoCFIValue:
+		return 2
+	case bitc.CstCodeCEBinop, bitc.CstCodeCECast, bitc.CstCodeCESelect, bitc.CstCodeCEShuffleVec, bitc.CstCodeBlockAddress:
+		return 3
+	case bitc.CstCodeCEExtractElt, bitc.CstCodeCEInsertElt, bitc.CstCodeCEShufVecEx, bitc.CstCodeCECmp:
+		return 4
+	default:
+		return 0
+	}
+}
+
+// nullConstant returns the null value of the given type.
+func nullConstant(typ types.Type) constant.Constant {
+	switch t := typ.(type) {
+	case *types.IntType:
+		if t.BitSize == 1 {
+			return constant.False
+		}
+		return constant.NewInt(t, 0)
+	case *types.FloatType:
+		return constant.NewFloat(t, 0)
+	case *types.PointerType:
+		return constant.NewNull(t)
+	case *types.TokenType:
+		return constant.None
+	default:
+		return constant.NewZeroInitializer(typ)
+	}
+}
+
+// wideInt returns the signed integer of the given bit size represented by the
+// given 64-bit words, in little-endian order.
+func wideInt(words []uint64, bitSize uint64) *big.Int {
+	x := new(big.Int)
+	for i := len(words) - 1; i >= 0; i-- {
+		x.Lsh(x, 64)
+		x.Or(x, new(big.Int).SetUint64(words[i]))
+	}
+	// Truncate to bit size and interpret as signed integer.
+	mask := new(big.Int).Lsh(big.NewInt(1), uint(bitSize))
+	x.Mod(x, mask)
+	if x.Bit(int(bitSize)-1) == 1 {
+		x.Sub(x, mask)
+	}
+	return x
+}
+
+// newFloat returns a new floating-point constant of the given type, based on
+// the bit representation of the constant.
+func newFloat(typ *types.FloatType, ops []uint64) (*constant.Float, error) {
+	var s string
+	switch typ.Kind {
+	case types.FloatKindHalf:
+		s = fmt.Sprintf("0xH%04X", uint16(ops[0]))
+	case types.FloatKindFloat:
+		// Single precision floating-point constants are represented using the
+		// hexadecimal form of double precision floating-point constants.
+		f := float64(math.Float32frombits(uint32(ops[0])))
+		s = fmt.Sprintf("0x%016X", math.Float64bits(f))
+	case types.FloatKindDouble:
+		s = fmt.Sprintf("0x%016X", ops[0])
+	case types.FloatKindX86_FP80, types.FloatKindFP128, types.FloatKindPPC_FP128:
+		if len(ops) < 2 {
+			return nil, errors.Errorf("invalid %v constant; expected 2 operands, got %d", typ, len(ops))
+		}
+		switch typ.Kind {
+		case types.FloatKindX86_FP80:
+			// Bits are stored as [se<<48 | mantissa>>16, mantissa&0xFFFF].
+			se := ops[0] >> 48
+			mantissa := ops[0]<<16 | ops[1]&0xFFFF
+			s = fmt.Sprintf("0xK%04X%016X", se, mantissa)
+		case types.FloatKindFP128:
+			s = fmt.Sprintf("0xL%016X%016X", ops[0], ops[1])
+		case types.FloatKindPPC_FP128:
+			s = fmt.Sprintf("0xM%016X%016X", ops[0], ops[1])
+		}
+	default:
+		return nil, errors.Errorf("support for floating-point kind %v not yet implemented", typ.Kind)
+	}
+	return constant.NewFloatFromString(typ, s)
+}
+
+// newAggregate returns a new aggregate constant of the given type and elements.
+func newAggregate(typ types.Type, elems []constant.Constant) (constant.Constant, error) {
+	switch t := typ.(type) {
+	case *types.StructType:
+		return constant.NewStruct(t, elems...), nil
+	case *types.ArrayType:
+		return constant.NewArray(t, elems...), nil
+	case *types.VectorType:
+		return constant.NewVector(t, elems...), nil
+	default:
+		return nil, errors.Errorf("invalid aggregate constant type %v", typ)
+	}
+}
+
+// newData returns a new array or vector constant of the given type, based on
+// the raw element values of a DATA record.
+func newData(typ types.Type, ops []uint64) (constant.Constant, error) {
+	var elemType types.Type
+	switch t := typ.(type) {
+	case *types.ArrayType:
+		elemType = t.ElemType
+	case *types.VectorType:
+		elemType = t.ElemType
+	default:
+		return nil, errors.Errorf("invalid data constant type; expected array or vector type, got %v", typ)
+	}
+	elems := make([]constant.Constant, len(ops))
+	for i, op := range ops {
+		switch elemType := elemType.(type) {
+		case *types.IntType:
+			// Sign-extend raw element value.
+			shift := 64 - elemType.BitSize
+			elems[i] = constant.NewInt(elemType, int64(op<<shift)>>shift)
+		case *types.FloatType:
+			elem, err := newFloat(elemType, []uint64{op})
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			elems[i] = elem
+		default:
+			return nil, errors.Errorf("invalid data constant element type; expected integer or floating-point type, got %v", elemType)
+		}
+	}
+	return newAggregate(typ, elems)
+}
+
+// newBinaryExpr returns a new binary constant expression based on the given
+// binary operation, operands and flags.
+func newBinaryExpr(opcode uint64, x, y constant.Constant, flags uint64) (constant.Constant, error) {
+	if types.IsFloatOrFloatVector(x.Type()) {
+		return nil, errors.Errorf("support for floating-point binary constant expression (opcode %d) not yet implemented", opcode)
+	}
+	switch opcode {
+	case bitc.BinopAdd:
+		e := constant.NewAdd(x, y)
+		e.OverflowFlags = decodeOverflowFlags(flags)
+		return e, nil
+	case bitc.BinopSub:
+		e := constant.NewSub(x, y)
+		e.OverflowFlags = decodeOverflowFlags(flags)
+		return e, nil
+	case bitc.BinopMul:
+		e := constant.NewMul(x, y)
+		e.OverflowFlags = decodeOverflowFlags(flags)
+		return e, nil
+	case bitc.BinopShl:
+		e := constant.NewShl(x, y)
+		e.OverflowFlags = decodeOverflowFlags(flags)
+		return e, nil
+	case bitc.BinopLShr:
+		e := constant.NewLShr(x, y)
+		e.Exact = flags&(1<<bitc.PEOExact) != 0
+		return e, nil
+	case bitc.BinopAShr:
+		e := constant.NewAShr(x, y)
+		e.Exact = flags&(1<<bitc.PEOExact) != 0
+		return e, nil
+	case bitc.BinopAnd:
+		return constant.NewAnd(x, y), nil
+	case bitc.BinopOr:
+		return constant.NewOr(x, y), nil
+	case bitc.BinopXor:
+		return constant.NewXor(x, y), nil
+	default:
+		return nil, errors.Errorf("support for binary constant expression (opcode %d) not yet implemented", opcode)
+	}
+}
+
+// newCastExpr returns a new conversion constant expression based on the given
+// cast operation, operand and target type.
+func newCastExpr(opcode uint64, from constant.Constant, to types.Type) (constant.Constant, error) {
+	switch opcode {
+	case bitc.CastTrunc:
+		return constant.NewTrunc(from, to), nil
+	case bitc.CastZExt:
+		return constant.NewZExt(from, to), nil
+	case bitc.CastSExt:
+		return constant.NewSExt(from, to), nil
+	case bitc.CastFPToUI:
+		return constant.NewFPToUI(from, to), nil
+	case bitc.CastFPToSI:
+		return constant.NewFPToSI(from, to), nil
+	case bitc.CastUIToFP:
+		return constant.NewUIToFP(from, to), nil
+	case bitc.CastSIToFP:
+		return constant.NewSIToFP(from, to), nil
+	case bitc.CastFPTrunc:
+		return constant.NewFPTrunc(from, to), nil
+	case bitc.CastFPExt:
+		return constant.NewFPExt(from, to), nil
+	case bitc.CastPtrToInt:
+		return constant.NewPtrToInt(from, to), nil
+	case bitc.CastIntToPtr:
+		return constant.NewIntToPtr(from, to), nil
+	case bitc.CastBitCast:
+		return constant.NewBitCast(from, to), nil
+	case bitc.CastAddrSpaceCast:
+		return constant.NewAddrSpaceCast(from, to), nil
+	default:
+		return nil, errors.Errorf("invalid cast operation %d", opcode)
+	}
+}
+
+// readSizedString reads a string prefixed by its size from the given operands,
+// and returns the remaining operands.
+func readSizedString(ops []uint64) (string, []uint64, error) {
+	if len(ops) < 1 || ops[0] > uint64(len(ops)-1) {
+		return "", nil, errors.New("invalid string; size exceeds number of operands")
+	}
+	n := ops[0]
+	return recordString(ops[1 : 1+n]), ops[1+n:], nil
+}
@@ -0,0 +1,285 @@
+package bitcode
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/llir/llvm/internal/bitstream"
+	"github.com/llir/llvm/internal/natsort"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/metadata"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/pkg/errors"
+)
+
+// decoder keeps track of top-level entities when decoding an LLVM IR module
+// from bitcode.
+type decoder struct {
+	// LLVM IR module being decoded.
+	m *ir.Module
+	// Bitstream reader.
+	r *bitstream.Reader
+	// Module version (MODULE_CODE_VERSION); version 1 and above use relative
+	// value IDs, version 2 and above store names in the string table.
+	version uint64
+	// String table (STRTAB_BLOCK).
+	strtab []byte
+	// Module uses opaque pointer types.
+	opaquePointers bool
+
+	// Type table, indexed by type ID.
+	types []types.Type
+	// Identified struct types of the type table.
+	identified map[*types.StructType]bool
+	// Module-level value table, indexed by value ID.
+	values []value.Value
+	// Section names, indexed by section ID - 1.
+	sections []string
+	// Garbage collector names, indexed by GC ID - 1.
+	gcNames []string
+	// Comdat definitions, indexed by comdat ID - 1.
+	comdats []*ir.ComdatDef
+	// Attribute groups, indexed by attribute group ID.
+	attrGroups map[uint64]*attrGroup
+	// Attribute sets, indexed by attribute set ID - 1.
+	attrSets []attrSet
+	// Metadata kinds, indexed by metadata kind ID.
+	mdKinds map[uint64]string
+	// Operand bundle tags, indexed by tag ID.
+	bundleTags []string
+	// Synchronization scope names, indexed by synchronization scope ID.
+	syncScopes []string
+	// Module-level metadata.
+	md *mdTable
+	// Named metadata definitions, in order of occurrence.
+	namedDefs []*metadata.NamedDef
+	// Uniqued non-distinct DILocation metadata nodes.
+	locs map[debugLoc]*metadata.DILocation
+	// Functions with bodies, in order of occurrence of function blocks.
+	funcBodies []*ir.Func
+
+	// Names of global values and comdats stored in the string table; resolved
+	// after the string table has been parsed.
+	strtabNames []strtabName
+	// Deferred resolution of module-level value references (e.g. global
+	// variable initializers); resolved after the module-level constants have
+	// been parsed.
+	pending []func() error
+	// Fix dummy basic blocks of blockaddress constants after decoding of
+	// function bodies.
+	todo []*blockAddress
+}
+
+// newDecoder returns a new decoder for decoding an LLVM IR module from the
+// given bitstream.
+func newDecoder(r *bitstream.Reader) *decoder {
+	d := &decoder{
+		m:          ir.NewModule(),
+		r:          r,
+		identified: make(map[*types.StructType]bool),
+		attrGroups: make(map[uint64]*attrGroup),
+		mdKinds:    make(map[uint64]string),
+		locs:       make(map[debugLoc]*metadata.DILocation),
+	}
+	d.md = d.newModuleMDTable()
+	return d
+}
+
+// strtabName is a name stored in the string table.
+type strtabName struct {
+	// Offset and size of name in string table.
+	offset, size uint64
+	// setName sets the name.
+	setName func(name string)
+}
+
+// blockAddress is a blockaddress constant with a dummy basic block, which
+// refers to the basic block with the given index.
+type blockAddress struct {
+	c *constant.BlockAddress
+	// Basic block index.
+	index uint64
+}
+
+// finish resolves deferred references and adds top-level definitions to the
+// IR module.
+func (d *decoder) finish() error {
+	// Resolve names stored in string table.
+	for _, name := range d.strtabNames {
+		if name.offset+name.size > uint64(len(d.strtab)) {
+			return errors.Errorf("invalid string table reference (offset %d, size %d); exceeds string table size %d", name.offset, name.size, len(d.strtab))
+		}
+		name.setName(string(d.strtab[name.offset : name.offset+name.size]))
+	}
+	// Fix basic block references in blockaddress constants.
+	for _, b := range d.todo {
+		f, ok := b.c.Func.(*ir.Func)
+		if !ok {
+			return errors.Errorf("invalid function in blockaddress constant; expected *ir.Func, got %T", b.c.Func)
+		}
+		if b.index >= uint64(len(f.Blocks)) {
+			return errors.Errorf("invalid basic block index %d in blockaddress constant of function %s", b.index, f.Ident())
+		}
+		b.c.Block = f.Blocks[b.index]
+	}
+	d.addTypeDefsToModule()
+	d.addComdatDefsToModule()
+	d.addAttrGroupDefsToModule()
+	if err := d.addMetadataDefsToModule(); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// addTypeDefsToModule adds IR type definitions to the IR module in natural
+// sorting order.
+func (d *decoder) addTypeDefsToModule() {
+	typeDefs := make(map[string]types.Type)
+	var typeNames []string
+	for _, t := range d.types {
+		if t == nil || len(t.Name()) == 0 {
+			continue
+		}
+		if _, ok := typeDefs[t.Name()]; ok {
+			continue
+		}
+		typeDefs[t.Name()] = t
+		typeNames = append(typeNames, t.Name())
+	}
+	natsort.Strings(typeNames)
+	for _, typeName := range typeNames {
+		d.m.TypeDefs = append(d.m.TypeDefs, typeDefs[typeName])
+	}
+}
+
+// addComdatDefsToModule adds IR comdat definitions to the IR module in natural
+// sorting order.
+func (d *decoder) addComdatDefsToModule() {
+	comdatDefs := append([]*ir.ComdatDef(nil), d.comdats...)
+	sort.SliceStable(comdatDefs, func(i, j int) bool {
+		return natsort.Less(comdatDefs[i].Name, comdatDefs[j].Name)
+	})
+	d.m.ComdatDefs = comdatDefs
+}
+
+// addAttrGroupDefsToModule assigns IDs to attribute group definitions in order
+// of first use and adds them to the IR module.
+func (d *decoder) addAttrGroupDefsToModule() {
+	var id int64
+	seen := make(map[*ir.AttrGroupDef]bool)
+	visit := func(attrs []ir.FuncAttribute) {
+		for _, attr := range attrs {
+			def, ok := attr.(*ir.AttrGroupDef)
+			if !ok || seen[def] {
+				continue
+			}
+			seen[def] = true
+			def.ID = id
+			id++
+			d.m.AttrGroupDefs = append(d.m.AttrGroupDefs, def)
+		}
+	}
+	for _, g := range d.m.Globals {
+		visit(g.FuncAttrs)
+	}
+	for _, f := range d.m.Funcs {
+		visit(f.FuncAttrs)
+	}
+	for _, f := range d.m.Funcs {
+		for _, block := range f.Blocks {
+			for _, inst := range block.Insts {
+				if inst, ok := inst.(*ir.InstCall); ok {
+					visit(inst.FuncAttrs)
+				}
+			}
+			switch term := block.Term.(type) {
+			case *ir.TermInvoke:
+				visit(term.FuncAttrs)
+			case *ir.TermCallBr:
+				visit(term.FuncAttrs)
+			}
+		}
+	}
+}
+
+// ### [ Helper functions ] ####################################################
+
+// typeAt returns the type with the given type ID.
+func (d *decoder) typeAt(id uint64) (types.Type, error) {
+	if id >= uint64(len(d.types)) || d.types[id] == nil {
+		return nil, errors.Errorf("invalid type ID %d; exceeds number of types (%d)", id, len(d.types))
+	}
+	return d.types[id], nil
+}
+
+// funcTypeAt returns the function type with the given type ID.
+func (d *decoder) funcTypeAt(id uint64) (*types.FuncType, error) {
+	t, err := d.typeAt(id)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	sig, ok := t.(*types.FuncType)
+	if !ok {
+		return nil, errors.Errorf("invalid type ID %d; expected function type, got %v", id, t)
+	}
+	return sig, nil
+}
+
+// newPointer returns a new pointer type to the given element type in the
+// specified address space, or an opaque pointer type if the module uses opaque
+// pointer types.
+func (d *decoder) newPointer(elemType types.Type, addrSpace types.AddrSpace) *types.PointerType {
+	if d.opaquePointers {
+		return types.NewOpaquePointer(addrSpace)
+	}
+	t := types.NewPointer(elemType)
+	t.AddrSpace = addrSpace
+	return t
+}
+
+// moduleValueAt returns the module-level value with the given value ID.
+func (d *decoder) moduleValueAt(id uint64) (value.Value, error) {
+	if id >= uint64(len(d.values)) || d.values[id] == nil {
+		return nil, errors.Errorf("invalid value ID %d; exceeds number of module-level values (%d)", id, len(d.values))
+	}
+	return d.values[id], nil
+}
+
+// moduleConstantAt returns the module-level constant with the given value ID.
+func (d *decoder) moduleConstantAt(id uint64) (constant.Constant, error) {
+	v, err := d.moduleValueAt(id)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	c, ok := v.(constant.Constant)
+	if !ok {
+		return nil, errors.Errorf("invalid value ID %d; expected constant, got %T", id, v)
+	}
+	return c, nil
+}
+
+// syncScopeName returns the name of the synchronization scope with the given
+// ID.
+func (d *decoder) syncScopeName(id uint64) (string, error) {
+	if len(d.syncScopes) == 0 {
+		// Default synchronization scope IDs: singlethread (0) and system (1).
+		switch id {
+		case 0:
+			return "singlethread", nil
+		case 1:
+			return "", nil
+		}
+	}
+	if id >= uint64(len(d.syncScopes)) {
+		return "", errors.Errorf("invalid synchronization scope ID %d", id)
+	}
+	return d.syncScopes[id], nil
+}
+
+// describe returns a short description of the given record for error
+// reporting.
+func describe(record *bitstream.Record) string {
+	return fmt.Sprintf("record (code %d, %d operands)", record.Code, len(record.Ops))
+}
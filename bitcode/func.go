@@ -0,0 +1,1890 @@
+package bitcode
+
+import (
+	"fmt"
+
+	"github.com/llir/llvm/internal/bitc"
+	"github.com/llir/llvm/internal/bitstream"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/metadata"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/pkg/errors"
+)
+
+// funcDecoder keeps track of function-local entities when decoding the body of
+// a function.
+type funcDecoder struct {
+	*decoder
+	// Function being decoded.
+	f *ir.Func
+	// Function-level value table, indexed by value ID; module-level values,
+	// followed by function parameters, function-level constants and
+	// instructions.
+	values []value.Value
+	// Placeholders of forward value references, indexed by value ID.
+	fwd map[uint64]*placeholder
+	// Function-level metadata.
+	md *mdTable
+	// Number of module-level metadata.
+	numModuleMDs int
+	// Basic blocks, indexed by basic block ID.
+	blocks []*ir.Block
+	// Index of the current basic block.
+	cur int
+	// Instructions and terminators, in order of occurrence.
+	insts []value.User
+	// Debug location of the last DEBUG_LOC record.
+	lastLoc *metadata.DILocation
+	// Pending operand bundles of the next call instruction.
+	bundles []*ir.OperandBundle
+}
+
+// placeholder is a forward reference to a function-local value, which is
+// replaced by the value after decoding of the function body.
+type placeholder struct {
+	// Value ID.
+	id uint64
+	// Value type.
+	typ types.Type
+}
+
+// String returns the LLVM syntax representation of the value as a type-value
+// pair.
+func (p *placeholder) String() string {
+	return fmt.Sprintf("%s %s", p.typ, p.Ident())
+}
+
+// Type returns the type of the value.
+func (p *placeholder) Type() types.Type {
+	return p.typ
+}
+
+// Ident returns the identifier associated with the value.
+func (p *placeholder) Ident() string {
+	return fmt.Sprintf("<forward reference %d>", p.id)
+}
+
+// --- [ Function block ] ------------------------------------------------------
+
+// parseFunctionBlock parses the FUNCTION_BLOCK of the given function.
+func (d *decoder) parseFunctionBlock(f *ir.Func) error {
+	if err := d.r.EnterBlock(); err != nil {
+		return errors.WithStack(err)
+	}
+	fd := &funcDecoder{
+		decoder:      d,
+		f:            f,
+		values:       append([]value.Value(nil), d.values...),
+		fwd:          make(map[uint64]*placeholder),
+		numModuleMDs: len(d.md.entries),
+	}
+	for _, param := range f.Params {
+		fd.values = append(fd.values, param)
+	}
+	fd.md = d.newFuncMDTable(fd.valueAt)
+	if err := fd.parseFunctionBody(); err != nil {
+		return errors.Wrapf(err, "unable to parse body of function %s", f.Ident())
+	}
+	return nil
+}
+
+// parseFunctionBody parses the records and sub-blocks of a FUNCTION_BLOCK.
+func (fd *funcDecoder) parseFunctionBody() error {
+	for {
+		entry, err := fd.r.Next()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		switch entry.Kind {
+		case bitstream.EntryEndBlock:
+			return fd.finish()
+		case bitstream.EntryRecord:
+			if err := fd.parseFunctionRecord(entry.Record); err != nil {
+				return errors.Wrapf(err, "unable to parse function %s", describe(entry.Record))
+			}
+		case bitstream.EntrySubBlock:
+			switch entry.BlockID {
+			case bitc.ConstantsBlockID:
+				fd.values, err = fd.parseConstantsBlock(fd.values)
+			case bitc.MetadataBlockID:
+				if err = fd.r.EnterBlock(); err == nil {
+					err = fd.md.parseMetadataBlock()
+				}
+			case bitc.MetadataAttachmentBlockID:
+				err = fd.parseMetadataAttachmentBlock()
+			case bitc.ValueSymtabBlockID:
+				err = fd.parseFuncVSTBlock()
+			default:
+				// Skip USELIST_BLOCK and unknown blocks.
+				err = fd.r.SkipBlock()
+			}
+			if err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	}
+}
+
+// finish resolves forward value references of the function body.
+func (fd *funcDecoder) finish() error {
+	for _, block := range fd.blocks {
+		if block.Term == nil {
+			return errors.Errorf("invalid basic block %s; missing terminator", block.Ident())
+		}
+	}
+	if len(fd.fwd) == 0 {
+		return nil
+	}
+	for id, p := range fd.fwd {
+		if id >= uint64(len(fd.values)) {
+			return errors.Errorf("invalid forward reference to value ID %d; value never defined", id)
+		}
+		if v := fd.values[id]; !v.Type().Equal(p.typ) {
+			return errors.Errorf("invalid forward reference to value ID %d; expected type %v, got %v", id, p.typ, v.Type())
+		}
+	}
+	for _, inst := range fd.insts {
+		for _, op := range inst.Operands() {
+			fd.replacePlaceholder(op)
+		}
+		for _, bundle := range operandBundlesOf(inst) {
+			for i := range bundle.Inputs {
+				fd.replacePlaceholder(&bundle.Inputs[i])
+			}
+		}
+	}
+	// Function-local metadata.
+	for _, entry := range fd.md.entries[fd.numModuleMDs:] {
+		if md, ok := entry.md.(*metadata.DIArgList); ok {
+			for i := range md.Fields {
+				fd.replacePlaceholder(&md.Fields[i])
+			}
+		}
+	}
+	return nil
+}
+
+// replacePlaceholder replaces the placeholder (if any) of the given operand by
+// the value of its forward reference.
+func (fd *funcDecoder) replacePlaceholder(op *value.Value) {
+	switch v := (*op).(type) {
+	case *placeholder:
+		*op = fd.values[v.id]
+	case *ir.Arg:
+		fd.replacePlaceholder(&v.Value)
+	case *metadata.Value:
+		if p, ok := v.Value.(*placeholder); ok {
+			v.Value = fd.values[p.id]
+		}
+	}
+}
+
+// parseFunctionRecord parses the given record of a FUNCTION_BLOCK.
+func (fd *funcDecoder) parseFunctionRecord(record *bitstream.Record) error {
+	ops := record.Ops
+	switch record.Code {
+	case bitc.FuncCodeDeclareBlocks:
+		// DECLAREBLOCKS: [n]
+		if len(ops) < 1 || len(fd.blocks) > 0 {
+			return errors.New("invalid DECLAREBLOCKS record")
+		}
+		for i := uint64(0); i < ops[0]; i++ {
+			fd.blocks = append(fd.blocks, fd.f.NewBlock(""))
+		}
+		return nil
+	case bitc.FuncCodeDebugLoc:
+		// DEBUG_LOC: [line, col, scope, inlined-at?, isImplicitCode?]
+		loc, err := fd.md.debugLoc(ops)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		fd.lastLoc = loc
+		return fd.attachDebugLoc(loc)
+	case bitc.FuncCodeDebugLocAgain:
+		// DEBUG_LOC_AGAIN: []
+		if fd.lastLoc == nil {
+			return errors.New("invalid DEBUG_LOC_AGAIN record; no previous debug location")
+		}
+		return fd.attachDebugLoc(fd.lastLoc)
+	case bitc.FuncCodeOperandBundle:
+		// OPERAND_BUNDLE: [tag, n x [value, type?]]
+		if len(ops) < 1 || ops[0] >= uint64(len(fd.bundleTags)) {
+			return errors.New("invalid OPERAND_BUNDLE record; invalid operand bundle tag")
+		}
+		bundle := &ir.OperandBundle{Tag: fd.bundleTags[ops[0]]}
+		r := fd.newInstReader(ops[1:])
+		for r.more() {
+			input, err := r.valueTypePair()
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			bundle.Inputs = append(bundle.Inputs, input)
+		}
+		fd.bundles = append(fd.bundles, bundle)
+		return nil
+	case bitc.FuncCodeBlockAddrUsers:
+		// Ignore BLOCKADDR_USERS, used for lazy function materialization.
+		return nil
+	}
+	if n := minInstOps(record.Code); len(ops) < n {
+		return errors.Errorf("invalid instruction %s; expected at least %d operands", describe(record), n)
+	}
+	if fd.cur >= len(fd.blocks) {
+		return errors.New("invalid instruction; no basic block left")
+	}
+	r := fd.newInstReader(ops)
+	v, err := r.decodeInst(record.Code)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	block := fd.blocks[fd.cur]
+	switch v := v.(type) {
+	case ir.Instruction:
+		block.Insts = append(block.Insts, v)
+	case ir.Terminator:
+		block.Term = v
+		fd.cur++
+	}
+	fd.insts = append(fd.insts, v)
+	// Instructions of non-void type are added to the value table.
+	if val, ok := v.(value.Value); ok && !val.Type().Equal(types.Void) {
+		fd.values = append(fd.values, val)
+	}
+	return nil
+}
+
+// attachDebugLoc attaches the given debug location to the last instruction.
+func (fd *funcDecoder) attachDebugLoc(loc *metadata.DILocation) error {
+	if len(fd.insts) == 0 {
+		return errors.New("invalid debug location; no instruction")
+	}
+	mds := metadataOf(fd.insts[len(fd.insts)-1])
+	*mds = append(*mds, &metadata.Attachment{Name: "dbg", Node: loc})
+	return nil
+}
+
+// --- [ Metadata attachment block ] -------------------------------------------
+
+// parseMetadataAttachmentBlock parses a METADATA_ATTACHMENT block.
+func (fd *funcDecoder) parseMetadataAttachmentBlock() error {
+	if err := fd.r.EnterBlock(); err != nil {
+		return errors.WithStack(err)
+	}
+	return fd.parseRecords(func(record *bitstream.Record) error {
+		if record.Code != bitc.MetadataAttachment {
+			return nil
+		}
+		// ATTACHMENT: [instid?, n x [id, mdnode]]
+		ops := record.Ops
+		if len(ops)%2 == 0 {
+			// Function attachment.
+			mds, err := fd.md.attachments(ops)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			fd.f.Metadata = append(fd.f.Metadata, mds...)
+			return nil
+		}
+		if ops[0] >= uint64(len(fd.insts)) {
+			return errors.Errorf("invalid instruction ID %d of metadata attachment", ops[0])
+		}
+		mds, err := fd.md.attachments(ops[1:])
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		inst := metadataOf(fd.insts[ops[0]])
+		*inst = append(*inst, mds...)
+		return nil
+	})
+}
+
+// --- [ Value symbol table ] --------------------------------------------------
+
+// parseFuncVSTBlock parses a function-level VALUE_SYMTAB block.
+func (fd *funcDecoder) parseFuncVSTBlock() error {
+	if err := fd.r.EnterBlock(); err != nil {
+		return errors.WithStack(err)
+	}
+	return fd.parseRecords(func(record *bitstream.Record) error {
+		ops := record.Ops
+		switch record.Code {
+		case bitc.VSTCodeEntry:
+			// VST_ENTRY: [valueid, namechar x N]
+			if len(ops) < 1 || ops[0] >= uint64(len(fd.values)) {
+				return errors.New("invalid VST_ENTRY record; invalid value ID")
+			}
+			v, ok := fd.values[ops[0]].(value.Named)
+			if !ok {
+				return errors.Errorf("invalid VST_ENTRY record; expected named value, got %T", fd.values[ops[0]])
+			}
+			v.SetName(recordString(ops[1:]))
+		case bitc.VSTCodeBBEntry:
+			// VST_BBENTRY: [bbid, namechar x N]
+			if len(ops) < 1 || ops[0] >= uint64(len(fd.blocks)) {
+				return errors.New("invalid VST_BBENTRY record; invalid basic block ID")
+			}
+			fd.blocks[ops[0]].SetName(recordString(ops[1:]))
+		}
+		return nil
+	})
+}
+
+// --- [ Instructions ] --------------------------------------------------------
+
+// instReader reads the operands of an instruction record.
+type instReader struct {
+	*funcDecoder
+	// Record operands.
+	ops []uint64
+	// Index of the next operand.
+	pos int
+}
+
+// newInstReader returns a new reader of the given instruction record operands.
+func (fd *funcDecoder) newInstReader(ops []uint64) *instReader {
+	return &instReader{funcDecoder: fd, ops: ops}
+}
+
+// decodeInst decodes the instruction (or terminator) of the given record code.
+func (r *instReader) decodeInst(code uint64) (value.User, error) {
+	switch code {
+	// Unary and binary instructions.
+	case bitc.FuncCodeInstUnop:
+		return r.decodeUnop()
+	case bitc.FuncCodeInstBinop:
+		return r.decodeBinop()
+	case bitc.FuncCodeInstCast:
+		return r.decodeCast()
+	// Vector instructions.
+	case bitc.FuncCodeInstExtractElt:
+		return r.decodeExtractElement()
+	case bitc.FuncCodeInstInsertElt:
+		return r.decodeInsertElement()
+	case bitc.FuncCodeInstShuffleVec:
+		return r.decodeShuffleVector()
+	// Aggregate instructions.
+	case bitc.FuncCodeInstExtractVal:
+		return r.decodeExtractValue()
+	case bitc.FuncCodeInstInsertVal:
+		return r.decodeInsertValue()
+	// Memory instructions.
+	case bitc.FuncCodeInstAlloca:
+		return r.decodeAlloca()
+	case bitc.FuncCodeInstLoad, bitc.FuncCodeInstLoadAtomic:
+		return r.decodeLoad(code == bitc.FuncCodeInstLoadAtomic)
+	case bitc.FuncCodeInstStore, bitc.FuncCodeInstStoreOld, bitc.FuncCodeInstStoreAtomic, bitc.FuncCodeInstStoreAtomicOld:
+		return r.decodeStore(code)
+	case bitc.FuncCodeInstFence:
+		return r.decodeFence()
+	case bitc.FuncCodeInstCmpXchg, bitc.FuncCodeInstCmpXchgOld:
+		return r.decodeCmpXchg(code == bitc.FuncCodeInstCmpXchgOld)
+	case bitc.FuncCodeInstAtomicRMW, bitc.FuncCodeInstAtomicRMWOld:
+		return r.decodeAtomicRMW(code == bitc.FuncCodeInstAtomicRMWOld)
+	case bitc.FuncCodeInstGEP, bitc.FuncCodeInstGEPOld, bitc.FuncCodeInstInboundsGEPOld:
+		return r.decodeGetElementPtr(code)
+	// Other instructions.
+	case bitc.FuncCodeInstCmp, bitc.FuncCodeInstCmp2:
+		return r.decodeCmp()
+	case bitc.FuncCodeInstPhi:
+		return r.decodePhi()
+	case bitc.FuncCodeInstSelect, bitc.FuncCodeInstVSelect:
+		return r.decodeSelect(code == bitc.FuncCodeInstVSelect)
+	case bitc.FuncCodeInstFreeze:
+		x, err := r.valueTypePair()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return ir.NewInstFreeze(x), nil
+	case bitc.FuncCodeInstCall:
+		return r.decodeCall()
+	case bitc.FuncCodeInstVAArg:
+		return r.decodeVAArg()
+	case bitc.FuncCodeInstLandingPad, bitc.FuncCodeInstLandingPadOld:
+		return r.decodeLandingPad(code == bitc.FuncCodeInstLandingPadOld)
+	case bitc.FuncCodeInstCatchPad, bitc.FuncCodeInstCleanupPad:
+		return r.decodePad(code == bitc.FuncCodeInstCatchPad)
+	// Terminators.
+	case bitc.FuncCodeInstRet:
+		return r.decodeRet()
+	case bitc.FuncCodeInstBr:
+		return r.decodeBr()
+	case bitc.FuncCodeInstSwitch:
+		return r.decodeSwitch()
+	case bitc.FuncCodeInstIndirectBr:
+		return r.decodeIndirectBr()
+	case bitc.FuncCodeInstInvoke:
+		return r.decodeInvoke()
+	case bitc.FuncCodeInstCallBr:
+		return r.decodeCallBr()
+	case bitc.FuncCodeInstResume:
+		x, err := r.valueTypePair()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return ir.NewResume(x), nil
+	case bitc.FuncCodeInstCatchSwitch:
+		return r.decodeCatchSwitch()
+	case bitc.FuncCodeInstCatchRet:
+		return r.decodeCatchRet()
+	case bitc.FuncCodeInstCleanupRet:
+		return r.decodeCleanupRet()
+	case bitc.FuncCodeInstUnreachable:
+		return ir.NewUnreachable(), nil
+	default:
+		return nil, errors.Errorf("unknown instruction (code %d)", code)
+	}
+}
+
+// ~~~ [ Unary and binary instructions ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// decodeUnop decodes an INST_UNOP record.
+func (r *instReader) decodeUnop() (value.User, error) {
+	// INST_UNOP: [opval, opcode, flags?]
+	x, err := r.valueTypePair()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	opcode, err := r.next()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if opcode != bitc.UnopFNeg {
+		return nil, errors.Errorf("invalid unary operation %d", opcode)
+	}
+	inst := ir.NewFNeg(x)
+	if r.more() {
+		inst.FastMathFlags = decodeFastMathFlags(r.ops[r.pos])
+	}
+	return inst, nil
+}
+
+// decodeBinop decodes an INST_BINOP record.
+func (r *instReader) decodeBinop() (value.User, error) {
+	// INST_BINOP: [opval, opval, opcode, flags?]
+	x, err := r.valueTypePair()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	y, err := r.value(x.Type())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	opcode, err := r.next()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var flags uint64
+	if r.more() {
+		flags = r.ops[r.pos]
+	}
+	return newBinaryInst(opcode, x, y, flags)
+}
+
+// decodeCast decodes an INST_CAST record.
+func (r *instReader) decodeCast() (value.User, error) {
+	// INST_CAST: [opval, destty, castopc]
+	from, err := r.valueTypePair()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	to, err := r.typ()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	opcode, err := r.next()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return newCastInst(opcode, from, to)
+}
+
+// ~~~ [ Vector instructions ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// decodeExtractElement decodes an INST_EXTRACTELT record.
+func (r *instReader) decodeExtractElement() (value.User, error) {
+	// INST_EXTRACTELT: [opval, opval]
+	x, err := r.valueTypePair()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	index, err := r.valueTypePair()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return ir.NewExtractElement(x, index), nil
+}
+
+// decodeInsertElement decodes an INST_INSERTELT record.
+func (r *instReader) decodeInsertElement() (value.User, error) {
+	// INST_INSERTELT: [opval, opval, opval]
+	x, err := r.valueTypePair()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	t, ok := x.Type().(*types.VectorType)
+	if !ok {
+		return nil, errors.Errorf("invalid vector operand type of insertelement; expected *types.VectorType, got %T", x.Type())
+	}
+	elem, err := r.value(t.ElemType)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	index, err := r.valueTypePair()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return ir.NewInsertElement(x, elem, index), nil
+}
+
+// decodeShuffleVector decodes an INST_SHUFFLEVEC record.
+func (r *instReader) decodeShuffleVector() (value.User, error) {
+	// INST_SHUFFLEVEC: [opval, opval, opval]
+	x, err := r.valueTypePair()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	y, err := r.value(x.Type())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	mask, err := r.valueTypePair()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return ir.NewShuffleVector(x, y, mask), nil
+}
+
+// ~~~ [ Aggregate instructions ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// decodeExtractValue decodes an INST_EXTRACTVAL record.
+func (r *instReader) decodeExtractValue() (value.User, error) {
+	// INST_EXTRACTVAL: [opval, n x indices]
+	x, err := r.valueTypePair()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	indices := r.rest()
+	if len(indices) == 0 {
+		return nil, errors.New("invalid extractvalue instruction; missing indices")
+	}
+	return ir.NewExtractValue(x, indices...), nil
+}
+
+// decodeInsertValue decodes an INST_INSERTVAL record.
+func (r *instReader) decodeInsertValue() (value.User, error) {
+	// INST_INSERTVAL: [opval, opval, n x indices]
+	x, err := r.valueTypePair()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	elem, err := r.valueTypePair()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	indices := r.rest()
+	if len(indices) == 0 {
+		return nil, errors.New("invalid insertvalue instruction; missing indices")
+	}
+	return ir.NewInsertValue(x, elem, indices...), nil
+}
+
+// ~~~ [ Memory instructions ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Bits of the alignment record of alloca instructions.
+const (
+	allocaInAlloca     = 1 << 5
+	allocaExplicitType = 1 << 6
+	allocaSwiftError   = 1 << 7
+)
+
+// decodeAlloca decodes an INST_ALLOCA record.
+func (r *instReader) decodeAlloca() (value.User, error) {
+	// INST_ALLOCA: [instty, opty, op, align, addrspace?]
+	elemType, err := r.typeAt(r.ops[0])
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	sizeType, err := r.typeAt(r.ops[1])
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	size, err := r.valueAt(r.ops[2], sizeType)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	rec := r.ops[3]
+	if rec&allocaExplicitType == 0 {
+		t, ok := elemType.(*types.PointerType)
+		if !ok || t.ElemType == nil {
+			return nil, errors.Errorf("invalid alloca type; expected typed pointer type, got %v", elemType)
+		}
+		elemType = t.ElemType
+	}
+	inst := &ir.InstAlloca{ElemType: elemType}
+	// Alignment exponent stored in bits 0-4 and 8-10.
+	if inst.Align, err = decodeAlign(rec&0x1F | (rec>>8&0x7)<<5); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	inst.InAlloca = rec&allocaInAlloca != 0
+	inst.SwiftError = rec&allocaSwiftError != 0
+	if len(r.ops) > 4 {
+		inst.AddrSpace = types.AddrSpace(r.ops[4])
+	}
+	// The number of elements is omitted if it is the i32 constant 1.
+	if c, ok := size.(*constant.Int); !ok || !c.Typ.Equal(types.I32) || c.X.Int64() != 1 {
+		inst.NElems = size
+	}
+	inst.Typ = r.newPointer(elemType, inst.AddrSpace)
+	return inst, nil
+}
+
+// decodeLoad decodes an INST_LOAD or INST_LOADATOMIC record.
+func (r *instReader) decodeLoad(atomic bool) (value.User, error) {
+	// INST_LOAD: [opval, ty?, align, vol]
+	//
+	// INST_LOADATOMIC: [opval, ty?, align, vol, ordering, ssid]
+	src, err := r.valueTypePair()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	n := 2
+	if atomic {
+		n = 4
+	}
+	var elemType types.Type
+	if len(r.ops)-r.pos == n+1 {
+		if elemType, err = r.typ(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	} else if elemType, err = pointeeType(src); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(r.ops)-r.pos != n {
+		return nil, errors.New("invalid load instruction; invalid number of operands")
+	}
+	inst := ir.NewLoad(elemType, src)
+	if inst.Align, err = decodeAlign(r.ops[r.pos]); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	inst.Volatile = r.ops[r.pos+1] != 0
+	if atomic {
+		inst.Atomic = true
+		if inst.Ordering, err = decodeOrdering(r.ops[r.pos+2]); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if inst.SyncScope, err = r.syncScopeName(r.ops[r.pos+3]); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return inst, nil
+}
+
+// decodeStore decodes an INST_STORE, INST_STORE_OLD, INST_STOREATOMIC or
+// INST_STOREATOMIC_OLD record.
+func (r *instReader) decodeStore(code uint64) (value.User, error) {
+	// INST_STORE: [ptrty, ptr, valty, val, align, vol]
+	//
+	// INST_STOREATOMIC: [ptrty, ptr, valty, val, align, vol, ordering, ssid]
+	dst, err := r.valueTypePair()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var src value.Value
+	switch code {
+	case bitc.FuncCodeInstStoreOld, bitc.FuncCodeInstStoreAtomicOld:
+		// The value type is the element type of the pointer.
+		elemType, err := pointeeType(dst)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		src, err = r.value(elemType)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	default:
+		if src, err = r.valueTypePair(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	atomic := code == bitc.FuncCodeInstStoreAtomic || code == bitc.FuncCodeInstStoreAtomicOld
+	n := 2
+	if atomic {
+		n = 4
+	}
+	if len(r.ops)-r.pos != n {
+		return nil, errors.New("invalid store instruction; invalid number of operands")
+	}
+	inst := &ir.InstStore{Src: src, Dst: dst}
+	if inst.Align, err = decodeAlign(r.ops[r.pos]); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	inst.Volatile = r.ops[r.pos+1] != 0
+	if atomic {
+		inst.Atomic = true
+		if inst.Ordering, err = decodeOrdering(r.ops[r.pos+2]); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if inst.SyncScope, err = r.syncScopeName(r.ops[r.pos+3]); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return inst, nil
+}
+
+// decodeFence decodes an INST_FENCE record.
+func (r *instReader) decodeFence() (value.User, error) {
+	// INST_FENCE: [ordering, ssid]
+	ordering, err := decodeOrdering(r.ops[0])
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	inst := ir.NewFence(ordering)
+	if inst.SyncScope, err = r.syncScopeName(r.ops[1]); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return inst, nil
+}
+
+// decodeCmpXchg decodes an INST_CMPXCHG or INST_CMPXCHG_OLD record.
+func (r *instReader) decodeCmpXchg(old bool) (value.User, error) {
+	// INST_CMPXCHG: [ptrty, ptr, cmpty, cmp, val, vol, success_ordering, ssid,
+	// failure_ordering, weak, align?]
+	//
+	// INST_CMPXCHG_OLD: [ptrty, ptr, cmp, val, vol, success_ordering, ssid,
+	// failure_ordering?, weak?]
+	ptr, err := r.valueTypePair()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var cmp value.Value
+	if old {
+		elemType, err := pointeeType(ptr)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		cmp, err = r.value(elemType)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	} else if cmp, err = r.valueTypePair(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	new, err := r.value(cmp.Type())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ops := r.rest()
+	if len(ops) < 3 {
+		return nil, errors.New("invalid cmpxchg instruction; invalid number of operands")
+	}
+	successOrdering, err := decodeOrdering(ops[1])
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	syncScope, err := r.syncScopeName(ops[2])
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	failureOrdering := failureOrderingOf(successOrdering)
+	if len(ops) > 3 {
+		if failureOrdering, err = decodeOrdering(ops[3]); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	inst := ir.NewCmpXchg(ptr, cmp, new, successOrdering, failureOrdering)
+	inst.Volatile = ops[0] != 0
+	inst.SyncScope = syncScope
+	if len(ops) > 4 {
+		inst.Weak = ops[4] != 0
+	}
+	return inst, nil
+}
+
+// decodeAtomicRMW decodes an INST_ATOMICRMW or INST_ATOMICRMW_OLD record.
+func (r *instReader) decodeAtomicRMW(old bool) (value.User, error) {
+	// INST_ATOMICRMW: [ptrty, ptr, valty, val, op, vol, ordering, ssid, align?]
+	//
+	// INST_ATOMICRMW_OLD: [ptrty, ptr, val, op, vol, ordering, ssid, align?]
+	dst, err := r.valueTypePair()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var x value.Value
+	if old {
+		elemType, err := pointeeType(dst)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		x, err = r.value(elemType)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+	} else if x, err = r.valueTypePair(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ops := r.rest()
+	if len(ops) < 4 {
+		return nil, errors.New("invalid atomicrmw instruction; invalid number of operands")
+	}
+	op, err := decodeAtomicOp(ops[0])
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ordering, err := decodeOrdering(ops[2])
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	inst := ir.NewAtomicRMW(op, dst, x, ordering)
+	inst.Volatile = ops[1] != 0
+	if inst.SyncScope, err = r.syncScopeName(ops[3]); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return inst, nil
+}
+
+// decodeGetElementPtr decodes an INST_GEP, INST_GEP_OLD or
+// INST_INBOUNDS_GEP_OLD record.
+func (r *instReader) decodeGetElementPtr(code uint64) (value.User, error) {
+	// INST_GEP: [inbounds, ty, n x operands]
+	//
+	// INST_GEP_OLD: [n x operands]
+	//
+	// INST_INBOUNDS_GEP_OLD: [n x operands]
+	inBounds := code == bitc.FuncCodeInstInboundsGEPOld
+	var elemType types.Type
+	if code == bitc.FuncCodeInstGEP {
+		flag, err := r.next()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		inBounds = flag != 0
+		if elemType, err = r.typ(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	src, err := r.valueTypePair()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if elemType == nil {
+		if elemType, err = pointeeType(src); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	var indices []value.Value
+	for r.more() {
+		index, err := r.valueTypePair()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		indices = append(indices, index)
+	}
+	inst := ir.NewGetElementPtr(elemType, src, indices...)
+	inst.InBounds = inBounds
+	return inst, nil
+}
+
+// ~~~ [ Other instructions ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// decodeCmp decodes an INST_CMP or INST_CMP2 record.
+func (r *instReader) decodeCmp() (value.User, error) {
+	// INST_CMP2: [opty, opval, opval, pred, flags?]
+	x, err := r.valueTypePair()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	y, err := r.value(x.Type())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	pred, err := r.next()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if types.IsFloatOrFloatVector(x.Type()) {
+		p, err := decodeFPred(pred)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		inst := ir.NewFCmp(p, x, y)
+		if r.more() {
+			inst.FastMathFlags = decodeFastMathFlags(r.ops[r.pos])
+		}
+		return inst, nil
+	}
+	p, err := decodeIPred(pred)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return ir.NewICmp(p, x, y), nil
+}
+
+// decodePhi decodes an INST_PHI record.
+func (r *instReader) decodePhi() (value.User, error) {
+	// INST_PHI: [ty, n x [val, bb], flags?]
+	typ, err := r.typ()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ops := r.rest()
+	inst := &ir.InstPhi{Typ: typ}
+	// Fast-math flags are present if the number of operands is odd.
+	if len(ops)%2 == 1 {
+		inst.FastMathFlags = decodeFastMathFlags(ops[len(ops)-1])
+		ops = ops[:len(ops)-1]
+	}
+	for i := 0; i < len(ops); i += 2 {
+		// Incoming values are encoded as signed relative value IDs.
+		id := uint64(uint32(int64(len(r.values)) - decodeSigned(ops[i])))
+		if r.version < 1 {
+			id = ops[i]
+		}
+		x, err := r.fnValueAt(id, typ)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		pred, err := r.blockAt(ops[i+1])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		inst.Incs = append(inst.Incs, ir.NewIncoming(x, pred))
+	}
+	return inst, nil
+}
+
+// decodeSelect decodes an INST_SELECT or INST_VSELECT record.
+func (r *instReader) decodeSelect(vselect bool) (value.User, error) {
+	// INST_SELECT: [opval, opval, opval]
+	//
+	// INST_VSELECT: [ty, opval, opval, predty, pred, flags?]
+	valueTrue, err := r.valueTypePair()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	valueFalse, err := r.value(valueTrue.Type())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var cond value.Value
+	if vselect {
+		cond, err = r.valueTypePair()
+	} else {
+		cond, err = r.value(types.I1)
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	inst := ir.NewSelect(cond, valueTrue, valueFalse)
+	if r.more() {
+		inst.FastMathFlags = decodeFastMathFlags(r.ops[r.pos])
+	}
+	return inst, nil
+}
+
+// decodeCall decodes an INST_CALL record.
+func (r *instReader) decodeCall() (value.User, error) {
+	// INST_CALL: [paramattrs, cc, fmf?, fnty?, fnid, args...]
+	attrsID, err := r.next()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ccInfo, err := r.next()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var fmf uint64
+	if ccInfo>>bitc.CallFMF&1 != 0 {
+		if fmf, err = r.next(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	callee, sig, err := r.callee(ccInfo>>bitc.CallExplicitType&1 != 0)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	as, err := r.attrsOf(attrsID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	args, err := r.args(sig, as)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	inst := &ir.InstCall{Callee: callee, Args: args, Typ: sig.RetType}
+	if r.opaquePointers {
+		inst.FuncType = sig
+	}
+	switch {
+	case ccInfo>>bitc.CallMustTail&1 != 0:
+		inst.Tail = enum.TailMustTail
+	case ccInfo>>bitc.CallNoTail&1 != 0:
+		inst.Tail = enum.TailNoTail
+	case ccInfo>>bitc.CallTail&1 != 0:
+		inst.Tail = enum.TailTail
+	}
+	inst.FastMathFlags = decodeFastMathFlags(fmf)
+	inst.CallingConv = decodeCallingConv(ccInfo >> bitc.CallCConv & 0x3FF)
+	inst.ReturnAttrs = as.retAttrs
+	inst.FuncAttrs = as.funcAttrs
+	inst.OperandBundles = r.takeBundles()
+	return inst, nil
+}
+
+// decodeVAArg decodes an INST_VAARG record.
+func (r *instReader) decodeVAArg() (value.User, error) {
+	// INST_VAARG: [valistty, valist, instty]
+	argListType, err := r.typ()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	argList, err := r.value(argListType)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	argType, err := r.typ()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return ir.NewVAArg(argList, argType), nil
+}
+
+// decodeLandingPad decodes an INST_LANDINGPAD or INST_LANDINGPAD_OLD record.
+func (r *instReader) decodeLandingPad(old bool) (value.User, error) {
+	// INST_LANDINGPAD: [ty, iscleanup, n x [clausety, val]]
+	//
+	// INST_LANDINGPAD_OLD: [ty, persfn, iscleanup, n x [clausety, val]]
+	resultType, err := r.typ()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if old {
+		// Personality functions are stored in function records.
+		if _, err := r.valueTypePair(); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	cleanup, err := r.next()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	n, err := r.next()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	inst := ir.NewLandingPad(resultType)
+	inst.Cleanup = cleanup != 0
+	for i := uint64(0); i < n; i++ {
+		clauseType, err := r.next()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		x, err := r.valueTypePair()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		switch clauseType {
+		case 0:
+			inst.Clauses = append(inst.Clauses, ir.NewClause(enum.ClauseTypeCatch, x))
+		case 1:
+			inst.Clauses = append(inst.Clauses, ir.NewClause(enum.ClauseTypeFilter, x))
+		default:
+			return nil, errors.Errorf("invalid landingpad clause type %d", clauseType)
+		}
+	}
+	return inst, nil
+}
+
+// decodePad decodes an INST_CATCHPAD or INST_CLEANUPPAD record.
+func (r *instReader) decodePad(catch bool) (value.User, error) {
+	// INST_CATCHPAD: [parentpad, n, n x [ty, val]]
+	//
+	// INST_CLEANUPPAD: [parentpad, n, n x [ty, val]]
+	parentPad, err := r.value(types.Token)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	n, err := r.next()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var args []value.Value
+	for i := uint64(0); i < n; i++ {
+		arg, err := r.valueTypePair()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		args = append(args, arg)
+	}
+	if catch {
+		return &ir.InstCatchPad{CatchSwitch: parentPad, Args: args}, nil
+	}
+	return &ir.InstCleanupPad{ParentPad: parentPad, Args: args}, nil
+}
+
+// ~~~ [ Terminators ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// decodeRet decodes an INST_RET record.
+func (r *instReader) decodeRet() (value.User, error) {
+	// INST_RET: [opty, opval?]
+	if !r.more() {
+		return ir.NewRet(nil), nil
+	}
+	x, err := r.valueTypePair()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return ir.NewRet(x), nil
+}
+
+// decodeBr decodes an INST_BR record.
+func (r *instReader) decodeBr() (value.User, error) {
+	// INST_BR: [bb#, bb#, cond] or [bb#]
+	target, err := r.block()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if !r.more() {
+		return ir.NewBr(target), nil
+	}
+	targetFalse, err := r.block()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	cond, err := r.value(types.I1)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return ir.NewCondBr(cond, target, targetFalse), nil
+}
+
+// decodeSwitch decodes an INST_SWITCH record.
+func (r *instReader) decodeSwitch() (value.User, error) {
+	// INST_SWITCH: [opty, op, default, n x [value, bb]]
+	typ, err := r.typ()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	x, err := r.value(typ)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	targetDefault, err := r.block()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ops := r.rest()
+	if len(ops)%2 != 0 {
+		return nil, errors.New("invalid switch terminator; invalid number of operands")
+	}
+	term := ir.NewSwitch(x, targetDefault)
+	for i := 0; i < len(ops); i += 2 {
+		// Case values are encoded as absolute value IDs.
+		v, err := r.fnValueAt(ops[i], typ)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		c, ok := v.(constant.Constant)
+		if !ok {
+			return nil, errors.Errorf("invalid switch case value; expected constant, got %T", v)
+		}
+		target, err := r.blockAt(ops[i+1])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		term.Cases = append(term.Cases, ir.NewCase(c, target))
+	}
+	return term, nil
+}
+
+// decodeIndirectBr decodes an INST_INDIRECTBR record.
+func (r *instReader) decodeIndirectBr() (value.User, error) {
+	// INST_INDIRECTBR: [opty, op, n x bb]
+	typ, err := r.typ()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	addr, err := r.value(typ)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var targets []*ir.Block
+	for r.more() {
+		target, err := r.block()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		targets = append(targets, target)
+	}
+	return ir.NewIndirectBr(addr, targets...), nil
+}
+
+// decodeInvoke decodes an INST_INVOKE record.
+func (r *instReader) decodeInvoke() (value.User, error) {
+	// INST_INVOKE: [attrs, cc, normbb, unwindbb, fnty?, fnid, args...]
+	const explicitType = 13
+	attrsID, err := r.next()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ccInfo, err := r.next()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	normal, err := r.block()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	unwind, err := r.block()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	callee, sig, err := r.callee(ccInfo>>explicitType&1 != 0)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	as, err := r.attrsOf(attrsID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	args, err := r.args(sig, as)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	term := &ir.TermInvoke{Invokee: callee, Args: args, NormalRetTarget: normal, ExceptionRetTarget: unwind, Typ: sig.RetType}
+	if r.opaquePointers {
+		term.FuncType = sig
+	}
+	term.CallingConv = decodeCallingConv(ccInfo & 0x3FF)
+	term.ReturnAttrs = as.retAttrs
+	term.FuncAttrs = as.funcAttrs
+	term.OperandBundles = r.takeBundles()
+	return term, nil
+}
+
+// decodeCallBr decodes an INST_CALLBR record.
+func (r *instReader) decodeCallBr() (value.User, error) {
+	// INST_CALLBR: [attrs, cc, normbb, n, n x otherbb, fnty?, fnid, args...]
+	attrsID, err := r.next()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ccInfo, err := r.next()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	normal, err := r.block()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	n, err := r.next()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var others []value.Value
+	for i := uint64(0); i < n; i++ {
+		other, err := r.block()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		others = append(others, other)
+	}
+	callee, sig, err := r.callee(ccInfo>>bitc.CallExplicitType&1 != 0)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	as, err := r.attrsOf(attrsID)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	args, err := r.args(sig, as)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	term := &ir.TermCallBr{Callee: callee, Args: args, NormalRetTarget: normal, OtherRetTargets: others, Typ: sig.RetType}
+	if r.opaquePointers {
+		term.FuncType = sig
+	}
+	term.CallingConv = decodeCallingConv(ccInfo >> bitc.CallCConv & 0x3FF)
+	term.ReturnAttrs = as.retAttrs
+	term.FuncAttrs = as.funcAttrs
+	term.OperandBundles = r.takeBundles()
+	return term, nil
+}
+
+// decodeCatchSwitch decodes an INST_CATCHSWITCH record.
+func (r *instReader) decodeCatchSwitch() (value.User, error) {
+	// INST_CATCHSWITCH: [parentpad, n, n x bb, unwindbb?]
+	parentPad, err := r.value(types.Token)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	n, err := r.next()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	term := &ir.TermCatchSwitch{ParentPad: parentPad}
+	for i := uint64(0); i < n; i++ {
+		handler, err := r.block()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		term.Handlers = append(term.Handlers, handler)
+	}
+	if r.more() {
+		unwind, err := r.block()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		term.DefaultUnwindTarget = unwind
+	}
+	return term, nil
+}
+
+// decodeCatchRet decodes an INST_CATCHRET record.
+func (r *instReader) decodeCatchRet() (value.User, error) {
+	// INST_CATCHRET: [catchpad, bb]
+	catchPad, err := r.value(types.Token)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	target, err := r.block()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &ir.TermCatchRet{CatchPad: catchPad, Target: target}, nil
+}
+
+// decodeCleanupRet decodes an INST_CLEANUPRET record.
+func (r *instReader) decodeCleanupRet() (value.User, error) {
+	// INST_CLEANUPRET: [cleanuppad, bb?]
+	cleanupPad, err := r.value(types.Token)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	term := &ir.TermCleanupRet{CleanupPad: cleanupPad}
+	if r.more() {
+		unwind, err := r.block()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		term.UnwindTarget = unwind
+	}
+	return term, nil
+}
+
+// ### [ Helper functions ] ####################################################
+
+// next returns the next operand of the record.
+func (r *instReader) next() (uint64, error) {
+	if r.pos >= len(r.ops) {
+		return 0, errors.Errorf("invalid instruction record; missing operand %d", r.pos)
+	}
+	op := r.ops[r.pos]
+	r.pos++
+	return op, nil
+}
+
+// more reports whether there are operands left to read.
+func (r *instReader) more() bool {
+	return r.pos < len(r.ops)
+}
+
+// rest returns the remaining operands of the record.
+func (r *instReader) rest() []uint64 {
+	ops := r.ops[r.pos:]
+	r.pos = len(r.ops)
+	return ops
+}
+
+// typ reads a type ID and returns the corresponding type.
+func (r *instReader) typ() (types.Type, error) {
+	id, err := r.next()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return r.typeAt(id)
+}
+
+// block reads a basic block ID and returns the corresponding basic block.
+func (r *instReader) block() (*ir.Block, error) {
+	id, err := r.next()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return r.blockAt(id)
+}
+
+// valueID reads a value ID, which is encoded relative to the ID of the next
+// instruction in modules of version 1 and above.
+func (r *instReader) valueID() (uint64, error) {
+	op, err := r.next()
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if r.version < 1 {
+		return op, nil
+	}
+	return uint64(uint32(len(r.values)) - uint32(op)), nil
+}
+
+// valueTypePair reads a value ID and returns the corresponding value. The type
+// of the value is present in the record for forward references.
+func (r *instReader) valueTypePair() (value.Value, error) {
+	id, err := r.valueID()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if id < uint64(len(r.values)) {
+		return r.values[id], nil
+	}
+	typ, err := r.typ()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return r.valueAt(id, typ)
+}
+
+// value reads a value ID and returns the corresponding value of the given type.
+func (r *instReader) value(typ types.Type) (value.Value, error) {
+	id, err := r.valueID()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return r.fnValueAt(id, typ)
+}
+
+// callee reads the function type (if explicit) and callee of a call
+// instruction, and returns the callee and its function signature.
+func (r *instReader) callee(explicitType bool) (value.Value, *types.FuncType, error) {
+	var sig *types.FuncType
+	if explicitType {
+		id, err := r.next()
+		if err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+		if sig, err = r.funcTypeAt(id); err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+	}
+	callee, err := r.valueTypePair()
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	if sig == nil {
+		elemType, err := pointeeType(callee)
+		if err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+		var ok bool
+		if sig, ok = elemType.(*types.FuncType); !ok {
+			return nil, nil, errors.Errorf("invalid callee type; expected pointer to function type, got %v", callee.Type())
+		}
+	}
+	return callee, sig, nil
+}
+
+// args reads the function arguments of a call instruction with the given
+// function signature and attributes.
+func (r *instReader) args(sig *types.FuncType, as *attrs) ([]value.Value, error) {
+	var args []value.Value
+	for _, paramType := range sig.Params {
+		var arg value.Value
+		var err error
+		if _, ok := paramType.(*types.LabelType); ok {
+			arg, err = r.block()
+		} else {
+			arg, err = r.value(paramType)
+		}
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		args = append(args, arg)
+	}
+	if sig.Variadic {
+		for r.more() {
+			arg, err := r.valueTypePair()
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			args = append(args, arg)
+		}
+	}
+	if r.more() {
+		return nil, errors.Errorf("invalid number of function arguments; expected %d, got more", len(sig.Params))
+	}
+	for i, arg := range args {
+		if attrs := as.paramAttrs[i]; len(attrs) > 0 {
+			args[i] = &ir.Arg{Value: arg, Attrs: attrs}
+		}
+	}
+	return args, nil
+}
+
+// takeBundles returns and clears the pending operand bundles.
+func (fd *funcDecoder) takeBundles() []*ir.OperandBundle {
+	bundles := fd.bundles
+	fd.bundles = nil
+	return bundles
+}
+
+// blockAt returns the basic block with the given basic block ID.
+func (fd *funcDecoder) blockAt(id uint64) (*ir.Block, error) {
+	if id >= uint64(len(fd.blocks)) {
+		return nil, errors.Errorf("invalid basic block ID %d; exceeds number of basic blocks (%d)", id, len(fd.blocks))
+	}
+	return fd.blocks[id], nil
+}
+
+// fnValueAt returns the function-level value with the given value ID and type.
+// The value ID refers to metadata if the type is the metadata type.
+func (fd *funcDecoder) fnValueAt(id uint64, typ types.Type) (value.Value, error) {
+	if _, ok := typ.(*types.MetadataType); ok {
+		md, err := fd.md.mdAt(id)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return &metadata.Value{Value: md}, nil
+	}
+	return fd.valueAt(id, typ)
+}
+
+// valueAt returns the function-level value with the given value ID and type. A
+// placeholder is returned for forward references.
+func (fd *funcDecoder) valueAt(id uint64, typ types.Type) (value.Value, error) {
+	if id < uint64(len(fd.values)) {
+		v := fd.values[id]
+		if !v.Type().Equal(typ) {
+			return nil, errors.Errorf("invalid type of value ID %d; expected %v, got %v", id, typ, v.Type())
+		}
+		return v, nil
+	}
+	if p, ok := fd.fwd[id]; ok {
+		if !p.typ.Equal(typ) {
+			return nil, errors.Errorf("invalid type of forward reference to value ID %d; expected %v, got %v", id, typ, p.typ)
+		}
+		return p, nil
+	}
+	p := &placeholder{id: id, typ: typ}
+	fd.fwd[id] = p
+	return p, nil
+}
+
+// pointeeType returns the element type of the given value of typed pointer
+// type.
+func pointeeType(v value.Value) (types.Type, error) {
+	t, ok := v.Type().(*types.PointerType)
+	if !ok || t.ElemType == nil {
+		return nil, errors.Errorf("invalid value type; expected typed pointer type, got %v", v.Type())
+	}
+	return t.ElemType, nil
+}
+
+// failureOrderingOf returns the strongest failure ordering permitted for the
+// given success ordering of a cmpxchg instruction.
+func failureOrderingOf(successOrdering enum.AtomicOrdering) enum.AtomicOrdering {
+	switch successOrdering {
+	case enum.AtomicOrderingRelease:
+		return enum.AtomicOrderingMonotonic
+	case enum.AtomicOrderingAcquireRelease:
+		return enum.AtomicOrderingAcquire
+	}
+	return successOrdering
+}
+
+// minInstOps returns the minimum number of operands of instruction records
+// with the given record code.
+func minInstOps(code uint64) int {
+	switch code {
+	case bitc.FuncCodeInstRet, bitc.FuncCodeInstUnreachable:
+		return 0
+	case bitc.FuncCodeInstBr, bitc.FuncCodeInstResume, bitc.FuncCodeInstFreeze, bitc.FuncCodeInstCleanupRet:
+		return 1
+	case bitc.FuncCodeInstUnop, bitc.FuncCodeInstExtractVal, bitc.FuncCodeInstExtractElt, bitc.FuncCodeInstFence, bitc.FuncCodeInstCatchRet, bitc.FuncCodeInstCatchSwitch, bitc.FuncCodeInstCatchPad, bitc.FuncCodeInstCleanupPad:
+		return 2
+	case bitc.FuncCodeInstBinop, bitc.FuncCodeInstCast, bitc.FuncCodeInstCmp, bitc.FuncCodeInstCmp2, bitc.FuncCodeInstSelect, bitc.FuncCodeInstVSelect, bitc.FuncCodeInstSwitch, bitc.FuncCodeInstVAArg, bitc.FuncCodeInstInsertVal, bitc.FuncCodeInstInsertElt, bitc.FuncCodeInstShuffleVec, bitc.FuncCodeInstLandingPad, bitc.FuncCodeInstLoad, bitc.FuncCodeInstPhi:
+		return 3
+	case bitc.FuncCodeInstAlloca, bitc.FuncCodeInstInvoke, bitc.FuncCodeInstCallBr:
+		return 4
+	}
+	return 0
+}
+
+// newBinaryInst returns a new binary instruction based on the given binary
+// operation, operands and flags.
+func newBinaryInst(opcode uint64, x, y value.Value, flags uint64) (value.User, error) {
+	if types.IsFloatOrFloatVector(x.Type()) {
+		fmf := decodeFastMathFlags(flags)
+		switch opcode {
+		case bitc.BinopAdd:
+			inst := ir.NewFAdd(x, y)
+			inst.FastMathFlags = fmf
+			return inst, nil
+		case bitc.BinopSub:
+			inst := ir.NewFSub(x, y)
+			inst.FastMathFlags = fmf
+			return inst, nil
+		case bitc.BinopMul:
+			inst := ir.NewFMul(x, y)
+			inst.FastMathFlags = fmf
+			return inst, nil
+		case bitc.BinopSDiv:
+			inst := ir.NewFDiv(x, y)
+			inst.FastMathFlags = fmf
+			return inst, nil
+		case bitc.BinopSRem:
+			inst := ir.NewFRem(x, y)
+			inst.FastMathFlags = fmf
+			return inst, nil
+		default:
+			return nil, errors.Errorf("invalid floating-point binary operation %d", opcode)
+		}
+	}
+	exact := flags&(1<<bitc.PEOExact) != 0
+	switch opcode {
+	case bitc.BinopAdd:
+		inst := ir.NewAdd(x, y)
+		inst.OverflowFlags = decodeOverflowFlags(flags)
+		return inst, nil
+	case bitc.BinopSub:
+		inst := ir.NewSub(x, y)
+		inst.OverflowFlags = decodeOverflowFlags(flags)
+		return inst, nil
+	case bitc.BinopMul:
+		inst := ir.NewMul(x, y)
+		inst.OverflowFlags = decodeOverflowFlags(flags)
+		return inst, nil
+	case bitc.BinopUDiv:
+		inst := ir.NewUDiv(x, y)
+		inst.Exact = exact
+		return inst, nil
+	case bitc.BinopSDiv:
+		inst := ir.NewSDiv(x, y)
+		inst.Exact = exact
+		return inst, nil
+	case bitc.BinopURem:
+		return ir.NewURem(x, y), nil
+	case bitc.BinopSRem:
+		return ir.NewSRem(x, y), nil
+	case bitc.BinopShl:
+		inst := ir.NewShl(x, y)
+		inst.OverflowFlags = decodeOverflowFlags(flags)
+		return inst, nil
+	case bitc.BinopLShr:
+		inst := ir.NewLShr(x, y)
+		inst.Exact = exact
+		return inst, nil
+	case bitc.BinopAShr:
+		inst := ir.NewAShr(x, y)
+		inst.Exact = exact
+		return inst, nil
+	case bitc.BinopAnd:
+		return ir.NewAnd(x, y), nil
+	case bitc.BinopOr:
+		return ir.NewOr(x, y), nil
+	case bitc.BinopXor:
+		return ir.NewXor(x, y), nil
+	default:
+		return nil, errors.Errorf("invalid binary operation %d", opcode)
+	}
+}
+
+// newCastInst returns a new conversion instruction based on the given cast
+// operation, operand and target type.
+func newCastInst(opcode uint64, from value.Value, to types.Type) (value.User, error) {
+	switch opcode {
+	case bitc.CastTrunc:
+		return ir.NewTrunc(from, to), nil
+	case bitc.CastZExt:
+		return ir.NewZExt(from, to), nil
+	case bitc.CastSExt:
+		return ir.NewSExt(from, to), nil
+	case bitc.CastFPToUI:
+		return ir.NewFPToUI(from, to), nil
+	case bitc.CastFPToSI:
+		return ir.NewFPToSI(from, to), nil
+	case bitc.CastUIToFP:
+		return ir.NewUIToFP(from, to), nil
+	case bitc.CastSIToFP:
+		return ir.NewSIToFP(from, to), nil
+	case bitc.CastFPTrunc:
+		return ir.NewFPTrunc(from, to), nil
+	case bitc.CastFPExt:
+		return ir.NewFPExt(from, to), nil
+	case bitc.CastPtrToInt:
+		return ir.NewPtrToInt(from, to), nil
+	case bitc.CastIntToPtr:
+		return ir.NewIntToPtr(from, to), nil
+	case bitc.CastBitCast:
+		return ir.NewBitCast(from, to), nil
+	case bitc.CastAddrSpaceCast:
+		return ir.NewAddrSpaceCast(from, to), nil
+	default:
+		return nil, errors.Errorf("invalid cast operation %d", opcode)
+	}
+}
+
+// operandBundlesOf returns the operand bundles of the given instruction.
+func operandBundlesOf(inst value.User) []*ir.OperandBundle {
+	switch inst := inst.(type) {
+	case *ir.InstCall:
+		return inst.OperandBundles
+	case *ir.TermInvoke:
+		return inst.OperandBundles
+	case *ir.TermCallBr:
+		return inst.OperandBundles
+	}
+	return nil
+}
+
+// metadataOf returns a pointer to the metadata attachments of the given
+// instruction or terminator.
+func metadataOf(inst value.User) *ir.Metadata {
+	switch inst := inst.(type) {
+	// Unary instructions.
+	case *ir.InstFNeg:
+		return &inst.Metadata
+	// Binary instructions.
+	case *ir.InstAdd:
+		return &inst.Metadata
+	case *ir.InstFAdd:
+		return &inst.Metadata
+	case *ir.InstSub:
+		return &inst.Metadata
+	case *ir.InstFSub:
+		return &inst.Metadata
+	case *ir.InstMul:
+		return &inst.Metadata
+	case *ir.InstFMul:
+		return &inst.Metadata
+	case *ir.InstUDiv:
+		return &inst.Metadata
+	case *ir.InstSDiv:
+		return &inst.Metadata
+	case *ir.InstFDiv:
+		return &inst.Metadata
+	case *ir.InstURem:
+		return &inst.Metadata
+	case *ir.InstSRem:
+		return &inst.Metadata
+	case *ir.InstFRem:
+		return &inst.Metadata
+	// Bitwise instructions.
+	case *ir.InstShl:
+		return &inst.Metadata
+	case *ir.InstLShr:
+		return &inst.Metadata
+	case *ir.InstAShr:
+		return &inst.Metadata
+	case *ir.InstAnd:
+		return &inst.Metadata
+	case *ir.InstOr:
+		return &inst.Metadata
+	case *ir.InstXor:
+		return &inst.Metadata
+	// Vector instructions.
+	case *ir.InstExtractElement:
+		return &inst.Metadata
+	case *ir.InstInsertElement:
+		return &inst.Metadata
+	case *ir.InstShuffleVector:
+		return &inst.Metadata
+	// Aggregate instructions.
+	case *ir.InstExtractValue:
+		return &inst.Metadata
+	case *ir.InstInsertValue:
+		return &inst.Metadata
+	// Memory instructions.
+	case *ir.InstAlloca:
+		return &inst.Metadata
+	case *ir.InstLoad:
+		return &inst.Metadata
+	case *ir.InstStore:
+		return &inst.Metadata
+	case *ir.InstFence:
+		return &inst.Metadata
+	case *ir.InstCmpXchg:
+		return &inst.Metadata
+	case *ir.InstAtomicRMW:
+		return &inst.Metadata
+	case *ir.InstGetElementPtr:
+		return &inst.Metadata
+	// Conversion instructions.
+	case *ir.InstTrunc:
+		return &inst.Metadata
+	case *ir.InstZExt:
+		return &inst.Metadata
+	case *ir.InstSExt:
+		return &inst.Metadata
+	case *ir.InstFPTrunc:
+		return &inst.Metadata
+	case *ir.InstFPExt:
+		return &inst.Metadata
+	case *ir.InstFPToUI:
+		return &inst.Metadata
+	case *ir.InstFPToSI:
+		return &inst.Metadata
+	case *ir.InstUIToFP:
+		return &inst.Metadata
+	case *ir.InstSIToFP:
+		return &inst.Metadata
+	case *ir.InstPtrToInt:
+		return &inst.Metadata
+	case *ir.InstIntToPtr:
+		return &inst.Metadata
+	case *ir.InstBitCast:
+		return &inst.Metadata
+	case *ir.InstAddrSpaceCast:
+		return &inst.Metadata
+	// Other instructions.
+	case *ir.InstICmp:
+		return &inst.Metadata
+	case *ir.InstFCmp:
+		return &inst.Metadata
+	case *ir.InstPhi:
+		return &inst.Metadata
+	case *ir.InstSelect:
+		return &inst.Metadata
+	case *ir.InstFreeze:
+		return &inst.Metadata
+	case *ir.InstCall:
+		return &inst.Metadata
+	case *ir.InstVAArg:
+		return &inst.Metadata
+	case *ir.InstLandingPad:
+		return &inst.Metadata
+	case *ir.InstCatchPad:
+		return &inst.Metadata
+	case *ir.InstCleanupPad:
+		return &inst.Metadata
+	// Terminators.
+	case *ir.TermRet:
+		return &inst.Metadata
+	case *ir.TermBr:
+		return &inst.Metadata
+	case *ir.TermCondBr:
+		return &inst.Metadata
+	case *ir.TermSwitch:
+		return &inst.Metadata
+	case *ir.TermIndirectBr:
+		return &inst.Metadata
+	case *ir.TermInvoke:
+		return &inst.Metadata
+	case *ir.TermCallBr:
+		return &inst.Metadata
+	case *ir.TermResume:
+		return &inst.Metadata
+	case *ir.TermCatchSwitch:
+		return &inst.Metadata
+	case *ir.TermCatchRet:
+		return &inst.Metadata
+	case *ir.TermCleanupRet:
+		return &inst.Metadata
+	case *ir.TermUnreachable:
+		return &inst.Metadata
+	default:
+		panic(fmt.Errorf("support for instruction %T not yet implemented", inst))
+	}
+}
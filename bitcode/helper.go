@@ -0,0 +1,337 @@
+package bitcode
+
+import (
+	"github.com/llir/llvm/internal/bitc"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/pkg/errors"
+)
+
+// recordString returns the string represented by the given record operands,
+// one character per operand.
+func recordString(ops []uint64) string {
+	buf := make([]byte, len(ops))
+	for i, op := range ops {
+		buf[i] = byte(op)
+	}
+	return string(buf)
+}
+
+// decodeSigned decodes the given sign-rotated integer value; the sign is stored
+// in the least significant bit.
+func decodeSigned(v uint64) int64 {
+	if v&1 == 0 {
+		return int64(v >> 1)
+	}
+	if v == 1 {
+		// -0 is used to encode the minimum signed 64-bit integer.
+		return -1 << 63
+	}
+	return -int64(v >> 1)
+}
+
+// decodeAlign decodes the given alignment, which is encoded as log2(align)+1;
+// zero if not present.
+func decodeAlign(v uint64) (ir.Align, error) {
+	if v == 0 {
+		return 0, nil
+	}
+	if v > 33 {
+		return 0, errors.Errorf("invalid encoded alignment %d; exceeds maximum alignment (1<<32)", v)
+	}
+	return ir.Align(1 << (v - 1)), nil
+}
+
+// decodeLinkage decodes the given linkage. The isDecl parameter reports whether
+// the linkage belongs to a global variable declaration, for which external
+// linkage is explicit.
+func decodeLinkage(v uint64, isDecl bool) enum.Linkage {
+	switch v {
+	case 0, 5, 6, 15:
+		// Note, 5 (dllimport) and 6 (dllexport) are obsolete external linkages.
+		if isDecl {
+			return enum.LinkageExternal
+		}
+		return enum.LinkageNone
+	case 2:
+		return enum.LinkageAppending
+	case 3:
+		return enum.LinkageInternal
+	case 7:
+		return enum.LinkageExternWeak
+	case 8:
+		return enum.LinkageCommon
+	case 9, 13, 14:
+		// Note, 13 (linker_private) and 14 (linker_private_weak) are obsolete
+		// private linkages.
+		return enum.LinkagePrivate
+	case 12:
+		return enum.LinkageAvailableExternally
+	case 1, 16:
+		return enum.LinkageWeak
+	case 10, 17:
+		return enum.LinkageWeakODR
+	case 4, 18:
+		return enum.LinkageLinkOnce
+	case 11, 19:
+		return enum.LinkageLinkOnceODR
+	default:
+		// Unknown linkages are treated as external.
+		if isDecl {
+			return enum.LinkageExternal
+		}
+		return enum.LinkageNone
+	}
+}
+
+// decodePreemption decodes the given dso_local flag of a global value with the
+// specified linkage and visibility. The dso_local flag is left implicit for
+// global values which are always local to the module.
+func decodePreemption(v uint64, linkage enum.Linkage, visibility enum.Visibility) enum.Preemption {
+	if v == 0 {
+		return enum.PreemptionNone
+	}
+	implicit := bitc.IsLocalLinkage(linkage) || (visibility != enum.VisibilityNone && linkage != enum.LinkageExternWeak)
+	if implicit {
+		return enum.PreemptionNone
+	}
+	return enum.PreemptionDSOLocal
+}
+
+// decodeVisibility decodes the given visibility.
+func decodeVisibility(v uint64) enum.Visibility {
+	switch v {
+	case 1:
+		return enum.VisibilityHidden
+	case 2:
+		return enum.VisibilityProtected
+	default:
+		return enum.VisibilityNone
+	}
+}
+
+// decodeDLLStorageClass decodes the given DLL storage class.
+func decodeDLLStorageClass(v uint64) enum.DLLStorageClass {
+	switch v {
+	case 1:
+		return enum.DLLStorageClassDLLImport
+	case 2:
+		return enum.DLLStorageClassDLLExport
+	default:
+		return enum.DLLStorageClassNone
+	}
+}
+
+// decodeTLSModel decodes the given thread local storage model.
+func decodeTLSModel(v uint64) enum.TLSModel {
+	switch v {
+	case 1:
+		return enum.TLSModelGeneric
+	case 2:
+		return enum.TLSModelLocalDynamic
+	case 3:
+		return enum.TLSModelInitialExec
+	case 4:
+		return enum.TLSModelLocalExec
+	default:
+		return enum.TLSModelNone
+	}
+}
+
+// decodeUnnamedAddr decodes the given unnamed_addr kind.
+func decodeUnnamedAddr(v uint64) enum.UnnamedAddr {
+	switch v {
+	case 1:
+		return enum.UnnamedAddrUnnamedAddr
+	case 2:
+		return enum.UnnamedAddrLocalUnnamedAddr
+	default:
+		return enum.UnnamedAddrNone
+	}
+}
+
+// decodeCallingConv decodes the given calling convention.
+func decodeCallingConv(v uint64) enum.CallingConv {
+	// Note, the C calling convention is 0 in bitcode and implicit in LLVM IR
+	// assembly.
+	if v == 0 {
+		return enum.CallingConvNone
+	}
+	return enum.CallingConv(v)
+}
+
+// decodeSelectionKind decodes the given comdat selection kind.
+func decodeSelectionKind(v uint64) (enum.SelectionKind, error) {
+	switch v {
+	case bitc.ComdatSelectionKindAny:
+		return enum.SelectionKindAny, nil
+	case bitc.ComdatSelectionKindExactMatch:
+		return enum.SelectionKindExactMatch, nil
+	case bitc.ComdatSelectionKindLargest:
+		return enum.SelectionKindLargest, nil
+	case bitc.ComdatSelectionKindNoDuplicates:
+		return enum.SelectionKindNoDeduplicate, nil
+	case bitc.ComdatSelectionKindSameSize:
+		return enum.SelectionKindSameSize, nil
+	default:
+		return 0, errors.Errorf("invalid comdat selection kind %d", v)
+	}
+}
+
+// decodeOrdering decodes the given atomic ordering.
+func decodeOrdering(v uint64) (enum.AtomicOrdering, error) {
+	switch v {
+	case bitc.OrderingNotAtomic:
+		return enum.AtomicOrderingNone, nil
+	case bitc.OrderingUnordered:
+		return enum.AtomicOrderingUnordered, nil
+	case bitc.OrderingMonotonic:
+		return enum.AtomicOrderingMonotonic, nil
+	case bitc.OrderingAcquire:
+		return enum.AtomicOrderingAcquire, nil
+	case bitc.OrderingRelease:
+		return enum.AtomicOrderingRelease, nil
+	case bitc.OrderingAcqRel:
+		return enum.AtomicOrderingAcquireRelease, nil
+	case bitc.OrderingSeqCst:
+		return enum.AtomicOrderingSequentiallyConsistent, nil
+	default:
+		return 0, errors.Errorf("invalid atomic ordering %d", v)
+	}
+}
+
+// decodeAtomicOp decodes the given atomicrmw binary operation.
+func decodeAtomicOp(v uint64) (enum.AtomicOp, error) {
+	switch v {
+	case bitc.RMWXchg:
+		return enum.AtomicOpXChg, nil
+	case bitc.RMWAdd:
+		return enum.AtomicOpAdd, nil
+	case bitc.RMWSub:
+		return enum.AtomicOpSub, nil
+	case bitc.RMWAnd:
+		return enum.AtomicOpAnd, nil
+	case bitc.RMWNand:
+		return enum.AtomicOpNAnd, nil
+	case bitc.RMWOr:
+		return enum.AtomicOpOr, nil
+	case bitc.RMWXor:
+		return enum.AtomicOpXor, nil
+	case bitc.RMWMax:
+		return enum.AtomicOpMax, nil
+	case bitc.RMWMin:
+		return enum.AtomicOpMin, nil
+	case bitc.RMWUMax:
+		return enum.AtomicOpUMax, nil
+	case bitc.RMWUMin:
+		return enum.AtomicOpUMin, nil
+	case bitc.RMWFAdd:
+		return enum.AtomicOpFAdd, nil
+	case bitc.RMWFSub:
+		return enum.AtomicOpFSub, nil
+	case bitc.RMWFMax:
+		return enum.AtomicOpFMax, nil
+	case bitc.RMWFMin:
+		return enum.AtomicOpFMin, nil
+	default:
+		return 0, errors.Errorf("invalid atomicrmw operation %d", v)
+	}
+}
+
+// decodeIPred decodes the given integer comparison predicate.
+func decodeIPred(v uint64) (enum.IPred, error) {
+	// Predicate values of llvm::CmpInst::Predicate.
+	switch v {
+	case 32:
+		return enum.IPredEQ, nil
+	case 33:
+		return enum.IPredNE, nil
+	case 34:
+		return enum.IPredUGT, nil
+	case 35:
+		return enum.IPredUGE, nil
+	case 36:
+		return enum.IPredULT, nil
+	case 37:
+		return enum.IPredULE, nil
+	case 38:
+		return enum.IPredSGT, nil
+	case 39:
+		return enum.IPredSGE, nil
+	case 40:
+		return enum.IPredSLT, nil
+	case 41:
+		return enum.IPredSLE, nil
+	default:
+		return 0, errors.Errorf("invalid integer comparison predicate %d", v)
+	}
+}
+
+// decodeFPred decodes the given floating-point comparison predicate.
+func decodeFPred(v uint64) (enum.FPred, error) {
+	// Predicate values of llvm::CmpInst::Predicate.
+	preds := []enum.FPred{
+		enum.FPredFalse,
+		enum.FPredOEQ,
+		enum.FPredOGT,
+		enum.FPredOGE,
+		enum.FPredOLT,
+		enum.FPredOLE,
+		enum.FPredONE,
+		enum.FPredORD,
+		enum.FPredUNO,
+		enum.FPredUEQ,
+		enum.FPredUGT,
+		enum.FPredUGE,
+		enum.FPredULT,
+		enum.FPredULE,
+		enum.FPredUNE,
+		enum.FPredTrue,
+	}
+	if v >= uint64(len(preds)) {
+		return 0, errors.Errorf("invalid floating-point comparison predicate %d", v)
+	}
+	return preds[v], nil
+}
+
+// decodeFastMathFlags decodes the given fast-math flags.
+func decodeFastMathFlags(v uint64) []enum.FastMathFlag {
+	const all = bitc.FMFAllowReassoc | bitc.FMFNoNaNs | bitc.FMFNoInfs | bitc.FMFNoSignedZeros | bitc.FMFAllowReciprocal | bitc.FMFAllowContract | bitc.FMFApproxFunc
+	if v&bitc.FMFUnsafeAlgebra != 0 || v&all == all {
+		return []enum.FastMathFlag{enum.FastMathFlagFast}
+	}
+	// Note, flags are listed in the order used by LLVM IR assembly.
+	masks := []struct {
+		mask uint64
+		flag enum.FastMathFlag
+	}{
+		{mask: bitc.FMFAllowReassoc, flag: enum.FastMathFlagReassoc},
+		{mask: bitc.FMFNoNaNs, flag: enum.FastMathFlagNNaN},
+		{mask: bitc.FMFNoInfs, flag: enum.FastMathFlagNInf},
+		{mask: bitc.FMFNoSignedZeros, flag: enum.FastMathFlagNSZ},
+		{mask: bitc.FMFAllowReciprocal, flag: enum.FastMathFlagARcp},
+		{mask: bitc.FMFAllowContract, flag: enum.FastMathFlagContract},
+		{mask: bitc.FMFApproxFunc, flag: enum.FastMathFlagAFn},
+	}
+	var flags []enum.FastMathFlag
+	for _, m := range masks {
+		if v&m.mask != 0 {
+			flags = append(flags, m.flag)
+		}
+	}
+	return flags
+}
+
+// decodeOverflowFlags decodes the given overflow flags of an overflowing binary
+// operation.
+func decodeOverflowFlags(v uint64) []enum.OverflowFlag {
+	// Note, flags are listed in the order used by LLVM IR assembly.
+	var flags []enum.OverflowFlag
+	if v&(1<<bitc.OBONoUnsignedWrap) != 0 {
+		flags = append(flags, enum.OverflowFlagNUW)
+	}
+	if v&(1<<bitc.OBONoSignedWrap) != 0 {
+		flags = append(flags, enum.OverflowFlagNSW)
+	}
+	return flags
+}
@@ -0,0 +1,1649 @@
+package bitcode
+
+import (
+	"strings"
+
+	"github.com/llir/llvm/internal/bitc"
+	"github.com/llir/llvm/internal/bitstream"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/metadata"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/pkg/errors"
+)
+
+// mdEntry is an entry of a metadata table.
+type mdEntry struct {
+	// Metadata record; nil for metadata strings.
+	record *bitstream.Record
+	// Decoded metadata; nil if not yet decoded.
+	md metadata.Field
+	// The metadata is being decoded; used to detect cycles.
+	decoding bool
+}
+
+// mdTable is a metadata table, which decodes metadata records on demand.
+//
+// Metadata nodes may refer to metadata nodes defined later in the table, and
+// may form cycles. Therefore, the records of metadata nodes are decoded
+// lazily, and metadata nodes are registered in the table before their operands
+// are decoded.
+type mdTable struct {
+	*decoder
+	// Metadata entries, indexed by metadata ID.
+	entries []*mdEntry
+	// valueAt returns the value with the given value ID and type, as referred to
+	// by VALUE records.
+	valueAt func(id uint64, typ types.Type) (value.Value, error)
+}
+
+// newModuleMDTable returns a new module-level metadata table.
+func (d *decoder) newModuleMDTable() *mdTable {
+	valueAt := func(id uint64, typ types.Type) (value.Value, error) {
+		v, err := d.moduleValueAt(id)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if !v.Type().Equal(typ) {
+			return nil, errors.Errorf("invalid type of value ID %d; expected %v, got %v", id, typ, v.Type())
+		}
+		return v, nil
+	}
+	return &mdTable{decoder: d, valueAt: valueAt}
+}
+
+// newFuncMDTable returns a new function-level metadata table, which extends the
+// module-level metadata table. The valueAt function resolves the function-local
+// values of VALUE records.
+func (d *decoder) newFuncMDTable(valueAt func(id uint64, typ types.Type) (value.Value, error)) *mdTable {
+	entries := make([]*mdEntry, len(d.md.entries))
+	copy(entries, d.md.entries)
+	return &mdTable{decoder: d, entries: entries, valueAt: valueAt}
+}
+
+// debugLoc is the uniquing key of non-distinct DILocation metadata nodes.
+type debugLoc struct {
+	line, column   int64
+	scope          metadata.Field
+	inlinedAt      *metadata.DILocation
+	isImplicitCode bool
+}
+
+// --- [ Metadata kind block ] -------------------------------------------------
+
+// parseMetadataKindBlock parses a METADATA_KIND_BLOCK.
+func (d *decoder) parseMetadataKindBlock() error {
+	if err := d.r.EnterBlock(); err != nil {
+		return errors.WithStack(err)
+	}
+	return d.parseRecords(func(record *bitstream.Record) error {
+		if record.Code == bitc.MetadataKindKind {
+			return d.parseMetadataKind(record.Ops)
+		}
+		return nil
+	})
+}
+
+// parseMetadataKind parses a KIND record.
+func (d *decoder) parseMetadataKind(ops []uint64) error {
+	// KIND: [n x [id, name]]
+	if len(ops) < 1 {
+		return errors.New("invalid KIND record; missing metadata kind ID")
+	}
+	d.mdKinds[ops[0]] = recordString(ops[1:])
+	return nil
+}
+
+// mdKind returns the name of the metadata kind with the given ID.
+func (d *decoder) mdKind(id uint64) (string, error) {
+	name, ok := d.mdKinds[id]
+	if !ok {
+		return "", errors.Errorf("invalid metadata kind ID %d", id)
+	}
+	return name, nil
+}
+
+// --- [ Metadata block ] ------------------------------------------------------
+
+// parseModuleMetadataBlock parses a module-level METADATA_BLOCK.
+func (d *decoder) parseModuleMetadataBlock() error {
+	if err := d.r.EnterBlock(); err != nil {
+		return errors.WithStack(err)
+	}
+	return d.md.parseMetadataBlock()
+}
+
+// parseMetadataBlock parses the records of a METADATA_BLOCK, after the block
+// has been entered. The metadata of the block is decoded once the end of the
+// block has been reached.
+func (t *mdTable) parseMetadataBlock() error {
+	start := len(t.entries)
+	// Name of the next named metadata definition (NAME).
+	var name string
+	var namedNodes []*bitstream.Record
+	var namedDefs []*metadata.NamedDef
+	var globalAttachments []*bitstream.Record
+	err := t.parseRecords(func(record *bitstream.Record) error {
+		ops := record.Ops
+		switch record.Code {
+		case bitc.MetadataStrings:
+			return t.parseStrings(record)
+		case bitc.MetadataStringOld:
+			// STRING_OLD: [values]
+			md := &metadata.String{Value: recordString(ops)}
+			t.entries = append(t.entries, &mdEntry{md: md})
+		case bitc.MetadataName:
+			// NAME: [values]
+			name = recordString(ops)
+		case bitc.MetadataNamedNode:
+			// NAMED_NODE: [n x mdnodes]
+			def := &metadata.NamedDef{Name: name}
+			namedDefs = append(namedDefs, def)
+			namedNodes = append(namedNodes, record)
+			name = ""
+		case bitc.MetadataKind:
+			return t.parseMetadataKind(ops)
+		case bitc.MetadataGlobalDeclAttachment:
+			globalAttachments = append(globalAttachments, record)
+		case bitc.MetadataIndexOffset, bitc.MetadataIndex:
+			// Ignore index used for lazy loading.
+		case bitc.MetadataOldNode, bitc.MetadataOldFnNode:
+			return errors.Errorf("support for legacy metadata nodes (code %d) not yet implemented", record.Code)
+		default:
+			t.entries = append(t.entries, &mdEntry{record: record})
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	// Decode metadata of block.
+	for id := start; id < len(t.entries); id++ {
+		if _, err := t.mdAt(uint64(id)); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	// Named metadata definitions.
+	for i, def := range namedDefs {
+		for _, id := range namedNodes[i].Ops {
+			md, err := t.mdAt(id)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			node, ok := md.(metadata.Node)
+			if !ok {
+				return errors.Errorf("invalid node of named metadata definition %q; expected metadata.Node, got %T", def.Name, md)
+			}
+			def.Nodes = append(def.Nodes, node)
+		}
+		if _, ok := t.m.NamedMetadataDefs[def.Name]; !ok {
+			t.namedDefs = append(t.namedDefs, def)
+		}
+		t.m.NamedMetadataDefs[def.Name] = def
+	}
+	// Metadata attachments of global variables and function declarations.
+	for _, record := range globalAttachments {
+		// GLOBAL_DECL_ATTACHMENT: [valueid, n x [id, mdnode]]
+		if len(record.Ops)%2 != 1 {
+			return errors.Errorf("invalid GLOBAL_DECL_ATTACHMENT record; expected odd number of operands, got %d", len(record.Ops))
+		}
+		v, err := t.moduleValueAt(record.Ops[0])
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		mds, err := t.attachments(record.Ops[1:])
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		switch v := v.(type) {
+		case *ir.Global:
+			v.Metadata = append(v.Metadata, mds...)
+		case *ir.Func:
+			v.Metadata = append(v.Metadata, mds...)
+		default:
+			return errors.Errorf("invalid metadata attachment; expected *ir.Global or *ir.Func, got %T", v)
+		}
+	}
+	return nil
+}
+
+// parseStrings parses a STRINGS record.
+func (t *mdTable) parseStrings(record *bitstream.Record) error {
+	// STRINGS: [count, offset] blob([lengths][chars])
+	if len(record.Ops) < 2 {
+		return errors.Errorf("invalid STRINGS record; expected 2 operands, got %d", len(record.Ops))
+	}
+	count, offset := record.Ops[0], record.Ops[1]
+	if offset > uint64(len(record.Blob)) {
+		return errors.Errorf("invalid STRINGS record; offset %d exceeds blob size %d", offset, len(record.Blob))
+	}
+	lengths := bitstream.NewReader(record.Blob[:offset])
+	chars := record.Blob[offset:]
+	for i := uint64(0); i < count; i++ {
+		n, err := lengths.ReadVBR(6)
+		if err != nil {
+			return errors.Wrap(err, "unable to read length of metadata string")
+		}
+		if n > uint64(len(chars)) {
+			return errors.Errorf("invalid STRINGS record; metadata string length %d exceeds blob", n)
+		}
+		md := &metadata.String{Value: string(chars[:n])}
+		t.entries = append(t.entries, &mdEntry{md: md})
+		chars = chars[n:]
+	}
+	return nil
+}
+
+// attachments returns the metadata attachments of the given [n x [id, mdnode]]
+// operands.
+func (t *mdTable) attachments(ops []uint64) ([]*metadata.Attachment, error) {
+	if len(ops)%2 != 0 {
+		return nil, errors.Errorf("invalid metadata attachments; expected even number of operands, got %d", len(ops))
+	}
+	var mds []*metadata.Attachment
+	for i := 0; i < len(ops); i += 2 {
+		name, err := t.mdKind(ops[i])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		md, err := t.mdAt(ops[i+1])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		node, ok := md.(metadata.MDNode)
+		if !ok {
+			return nil, errors.Errorf("invalid node of metadata attachment %q; expected metadata.MDNode, got %T", name, md)
+		}
+		mds = append(mds, &metadata.Attachment{Name: name, Node: node})
+	}
+	return mds, nil
+}
+
+// --- [ Metadata decoding ] ---------------------------------------------------
+
+// mdAt returns the metadata with the given metadata ID, decoding the metadata
+// record of the entry if not yet decoded.
+func (t *mdTable) mdAt(id uint64) (metadata.Field, error) {
+	if id >= uint64(len(t.entries)) {
+		return nil, errors.Errorf("invalid metadata ID %d; exceeds number of metadata (%d)", id, len(t.entries))
+	}
+	entry := t.entries[id]
+	if entry.md != nil {
+		return entry.md, nil
+	}
+	if entry.decoding {
+		return nil, errors.Errorf("invalid metadata (metadata ID %d); cyclic metadata reference", id)
+	}
+	entry.decoding = true
+	record := entry.record
+	if n := minMetadataOps(record.Code); len(record.Ops) < n {
+		return nil, errors.Errorf("invalid metadata %s; expected at least %d operands", describe(record), n)
+	}
+	switch record.Code {
+	case bitc.MetadataValue:
+		// VALUE: [ty, val]
+		v, err := t.decodeValue(record.Ops)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to decode metadata (metadata ID %d)", id)
+		}
+		entry.md = v
+		return v, nil
+	case bitc.MetadataArgList:
+		// ARG_LIST: [n x md num]
+		md := &metadata.DIArgList{}
+		for _, op := range record.Ops {
+			field, err := t.mdAt(op)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			v, ok := field.(value.Value)
+			if !ok {
+				return nil, errors.Errorf("invalid DIArgList field; expected value.Value, got %T", field)
+			}
+			md.Fields = append(md.Fields, v)
+		}
+		entry.md = md
+		return md, nil
+	}
+	node := newMDNode(record)
+	if node == nil {
+		return nil, errors.Errorf("unknown metadata %s", describe(record))
+	}
+	// Register node before decoding its operands, to handle cyclic references.
+	entry.md = node
+	if err := t.decodeMDNode(node, record); err != nil {
+		return nil, errors.Wrapf(err, "unable to decode metadata (metadata ID %d)", id)
+	}
+	if loc, ok := node.(*metadata.DILocation); ok && !loc.Distinct {
+		key := debugLoc{line: loc.Line, column: loc.Column, scope: loc.Scope, inlinedAt: loc.InlinedAt, isImplicitCode: loc.IsImplicitCode}
+		if _, ok := t.locs[key]; !ok {
+			t.locs[key] = loc
+		}
+	}
+	return node, nil
+}
+
+// decodeValue decodes the operands of a VALUE record.
+func (t *mdTable) decodeValue(ops []uint64) (value.Value, error) {
+	// VALUE: [ty, val]
+	typ, err := t.typeAt(ops[0])
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	switch typ.(type) {
+	case *types.VoidType, *types.MetadataType, *types.LabelType:
+		return nil, errors.Errorf("invalid type of metadata value; %v", typ)
+	}
+	return t.valueAt(ops[1], typ)
+}
+
+// newMDNode returns a new metadata node (without operands) of the kind
+// specified by the given metadata record; or nil if the record is not a
+// metadata node.
+func newMDNode(record *bitstream.Record) metadata.Definition {
+	var node metadata.Definition
+	switch record.Code {
+	case bitc.MetadataNode:
+		return &metadata.Tuple{MetadataID: -1}
+	case bitc.MetadataDistinctNode:
+		return &metadata.Tuple{MetadataID: -1, Distinct: true}
+	case bitc.MetadataLocation:
+		node = &metadata.DILocation{MetadataID: -1}
+	case bitc.MetadataGenericDebug:
+		node = &metadata.GenericDINode{MetadataID: -1}
+	case bitc.MetadataSubrange, bitc.MetadataGenericSubrange:
+		node = &metadata.DISubrange{MetadataID: -1}
+	case bitc.MetadataEnumerator:
+		node = &metadata.DIEnumerator{MetadataID: -1}
+	case bitc.MetadataBasicType:
+		node = &metadata.DIBasicType{MetadataID: -1}
+	case bitc.MetadataStringType:
+		node = &metadata.DIStringType{MetadataID: -1}
+	case bitc.MetadataFile:
+		node = &metadata.DIFile{MetadataID: -1}
+	case bitc.MetadataDerivedType:
+		node = &metadata.DIDerivedType{MetadataID: -1}
+	case bitc.MetadataCompositeType:
+		node = &metadata.DICompositeType{MetadataID: -1}
+	case bitc.MetadataSubroutineType:
+		node = &metadata.DISubroutineType{MetadataID: -1}
+	case bitc.MetadataCompileUnit:
+		node = &metadata.DICompileUnit{MetadataID: -1}
+	case bitc.MetadataSubprogram:
+		node = &metadata.DISubprogram{MetadataID: -1}
+	case bitc.MetadataLexicalBlock:
+		node = &metadata.DILexicalBlock{MetadataID: -1}
+	case bitc.MetadataLexicalBlockFile:
+		node = &metadata.DILexicalBlockFile{MetadataID: -1}
+	case bitc.MetadataCommonBlock:
+		node = &metadata.DICommonBlock{MetadataID: -1}
+	case bitc.MetadataNamespace:
+		node = &metadata.DINamespace{MetadataID: -1}
+	case bitc.MetadataMacro:
+		node = &metadata.DIMacro{MetadataID: -1}
+	case bitc.MetadataMacroFile:
+		node = &metadata.DIMacroFile{MetadataID: -1}
+	case bitc.MetadataModule:
+		node = &metadata.DIModule{MetadataID: -1}
+	case bitc.MetadataTemplateType:
+		node = &metadata.DITemplateTypeParameter{MetadataID: -1}
+	case bitc.MetadataTemplateValue:
+		node = &metadata.DITemplateValueParameter{MetadataID: -1}
+	case bitc.MetadataGlobalVar:
+		node = &metadata.DIGlobalVariable{MetadataID: -1}
+	case bitc.MetadataLocalVar:
+		node = &metadata.DILocalVariable{MetadataID: -1}
+	case bitc.MetadataLabel:
+		node = &metadata.DILabel{MetadataID: -1}
+	case bitc.MetadataExpression:
+		node = &metadata.DIExpression{MetadataID: -1}
+	case bitc.MetadataGlobalVarExpr:
+		node = &metadata.DIGlobalVariableExpression{MetadataID: -1}
+	case bitc.MetadataObjCProperty:
+		node = &metadata.DIObjCProperty{MetadataID: -1}
+	case bitc.MetadataImportedEntity:
+		node = &metadata.DIImportedEntity{MetadataID: -1}
+	default:
+		return nil
+	}
+	// The distinct flag is stored in the least significant bit of the first
+	// operand of specialized metadata nodes.
+	node.SetDistinct(len(record.Ops) > 0 && record.Ops[0]&1 != 0)
+	return node
+}
+
+// decodeMDNode decodes the operands of the given metadata record into node.
+func (t *mdTable) decodeMDNode(node metadata.Definition, record *bitstream.Record) error {
+	ops := record.Ops
+	switch node := node.(type) {
+	case *metadata.Tuple:
+		return t.decodeTuple(node, ops)
+	case *metadata.DILocation:
+		return t.decodeDILocation(node, ops)
+	case *metadata.GenericDINode:
+		return t.decodeGenericDINode(node, ops)
+	case *metadata.DISubrange:
+		return t.decodeDISubrange(node, record.Code, ops)
+	case *metadata.DIEnumerator:
+		return t.decodeDIEnumerator(node, ops)
+	case *metadata.DIBasicType:
+		return t.decodeDIBasicType(node, ops)
+	case *metadata.DIStringType:
+		return t.decodeDIStringType(node, ops)
+	case *metadata.DIFile:
+		return t.decodeDIFile(node, ops)
+	case *metadata.DIDerivedType:
+		return t.decodeDIDerivedType(node, ops)
+	case *metadata.DICompositeType:
+		return t.decodeDICompositeType(node, ops)
+	case *metadata.DISubroutineType:
+		return t.decodeDISubroutineType(node, ops)
+	case *metadata.DICompileUnit:
+		return t.decodeDICompileUnit(node, ops)
+	case *metadata.DISubprogram:
+		return t.decodeDISubprogram(node, ops)
+	case *metadata.DILexicalBlock:
+		return t.decodeDILexicalBlock(node, ops)
+	case *metadata.DILexicalBlockFile:
+		return t.decodeDILexicalBlockFile(node, ops)
+	case *metadata.DICommonBlock:
+		return t.decodeDICommonBlock(node, ops)
+	case *metadata.DINamespace:
+		return t.decodeDINamespace(node, ops)
+	case *metadata.DIMacro:
+		return t.decodeDIMacro(node, ops)
+	case *metadata.DIMacroFile:
+		return t.decodeDIMacroFile(node, ops)
+	case *metadata.DIModule:
+		return t.decodeDIModule(node, ops)
+	case *metadata.DITemplateTypeParameter:
+		return t.decodeDITemplateTypeParameter(node, ops)
+	case *metadata.DITemplateValueParameter:
+		return t.decodeDITemplateValueParameter(node, ops)
+	case *metadata.DIGlobalVariable:
+		return t.decodeDIGlobalVariable(node, ops)
+	case *metadata.DILocalVariable:
+		return t.decodeDILocalVariable(node, ops)
+	case *metadata.DILabel:
+		return t.decodeDILabel(node, ops)
+	case *metadata.DIExpression:
+		return t.decodeDIExpression(node, ops)
+	case *metadata.DIGlobalVariableExpression:
+		return t.decodeDIGlobalVariableExpression(node, ops)
+	case *metadata.DIObjCProperty:
+		return t.decodeDIObjCProperty(node, ops)
+	case *metadata.DIImportedEntity:
+		return t.decodeDIImportedEntity(node, ops)
+	default:
+		panic(errors.Errorf("support for metadata node %T not yet implemented", node))
+	}
+}
+
+// --- [ Metadata tuple ] ------------------------------------------------------
+
+// decodeTuple decodes the operands of a NODE or DISTINCT_NODE record.
+func (t *mdTable) decodeTuple(md *metadata.Tuple, ops []uint64) error {
+	// NODE: [n x md num]
+	md.Fields = make([]metadata.Field, len(ops))
+	for i, op := range ops {
+		field, err := t.node(op)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if field == nil {
+			field = metadata.Null
+		}
+		md.Fields[i] = field
+	}
+	return nil
+}
+
+// --- [ Specialized metadata nodes ] ------------------------------------------
+
+// decodeDILocation decodes the operands of a LOCATION record.
+func (t *mdTable) decodeDILocation(md *metadata.DILocation, ops []uint64) error {
+	// LOCATION: [distinct, line, col, scope, inlined-at?, isImplicitCode]
+	md.Line = int64(ops[1])
+	md.Column = int64(ops[2])
+	scope, err := t.mdAt(ops[3])
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	md.Scope = scope
+	if md.InlinedAt, err = t.diLocation(ops[4]); err != nil {
+		return errors.WithStack(err)
+	}
+	if len(ops) > 5 {
+		md.IsImplicitCode = ops[5] != 0
+	}
+	return nil
+}
+
+// decodeGenericDINode decodes the operands of a GENERIC_DEBUG record.
+func (t *mdTable) decodeGenericDINode(md *metadata.GenericDINode, ops []uint64) error {
+	// GENERIC_DEBUG: [distinct, tag, vers, header, n x md num]
+	if ops[2] != 0 {
+		return errors.Errorf("invalid GENERIC_DEBUG record; unsupported version %d", ops[2])
+	}
+	md.Tag = enum.DwarfTag(ops[1])
+	var err error
+	if md.Header, err = t.str(ops[3]); err != nil {
+		return errors.WithStack(err)
+	}
+	for _, op := range ops[4:] {
+		field, err := t.node(op)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if field == nil {
+			field = metadata.Null
+		}
+		md.Operands = append(md.Operands, field)
+	}
+	return nil
+}
+
+// decodeDISubrange decodes the operands of a SUBRANGE or GENERIC_SUBRANGE
+// record.
+func (t *mdTable) decodeDISubrange(md *metadata.DISubrange, code uint64, ops []uint64) error {
+	// SUBRANGE: [distinct | version << 1, count, lo, up, stride]
+	//
+	// GENERIC_SUBRANGE: [distinct, count, lo, up, stride]
+	version := ops[0] >> 1
+	if code == bitc.MetadataGenericSubrange {
+		version = 2
+	}
+	var err error
+	switch version {
+	case 0:
+		// Count and lower bound stored as integers.
+		md.Count = metadata.IntLit(int64(ops[1]))
+		md.LowerBound = metadata.IntLit(decodeSigned(ops[2]))
+		return nil
+	case 1:
+		// Lower bound stored as integer.
+		if md.Count, err = t.fieldOrInt(ops[1]); err != nil {
+			return errors.WithStack(err)
+		}
+		md.LowerBound = metadata.IntLit(decodeSigned(ops[2]))
+		return nil
+	case 2:
+		if len(ops) < 5 {
+			return errors.Errorf("invalid SUBRANGE record; expected 5 operands, got %d", len(ops))
+		}
+		if md.Count, err = t.fieldOrInt(ops[1]); err != nil {
+			return errors.WithStack(err)
+		}
+		if md.LowerBound, err = t.fieldOrInt(ops[2]); err != nil {
+			return errors.WithStack(err)
+		}
+		if md.UpperBound, err = t.fieldOrInt(ops[3]); err != nil {
+			return errors.WithStack(err)
+		}
+		if md.Stride, err = t.fieldOrInt(ops[4]); err != nil {
+			return errors.WithStack(err)
+		}
+		return nil
+	default:
+		return errors.Errorf("invalid SUBRANGE record; unsupported version %d", version)
+	}
+}
+
+// decodeDIEnumerator decodes the operands of an ENUMERATOR record.
+func (t *mdTable) decodeDIEnumerator(md *metadata.DIEnumerator, ops []uint64) error {
+	// ENUMERATOR: [isBigInt | isUnsigned | distinct, bitwidth, name, n x value]
+	//
+	// Legacy: [isUnsigned | distinct, value, name]
+	const isBigInt = 1 << 2
+	md.IsUnsigned = ops[0]&(1<<1) != 0
+	var err error
+	if md.Name, err = t.str(ops[2]); err != nil {
+		return errors.WithStack(err)
+	}
+	if ops[0]&isBigInt == 0 {
+		md.Value = decodeSigned(ops[1])
+		return nil
+	}
+	words := ops[3:]
+	if len(words) == 0 {
+		return errors.New("invalid ENUMERATOR record; missing value")
+	}
+	x := wideInt(words, ops[1])
+	if md.IsUnsigned {
+		md.Value = int64(x.Uint64())
+	} else {
+		md.Value = x.Int64()
+	}
+	return nil
+}
+
+// decodeDIBasicType decodes the operands of a BASIC_TYPE record.
+func (t *mdTable) decodeDIBasicType(md *metadata.DIBasicType, ops []uint64) error {
+	// BASIC_TYPE: [distinct, tag, name, size, align, encoding, flags]
+	//
+	// DW_TAG_base_type is the default tag of DIBasicType, and is therefore
+	// omitted in LLVM IR assembly.
+	if tag := enum.DwarfTag(ops[1]); tag != enum.DwarfTagBaseType {
+		md.Tag = tag
+	}
+	var err error
+	if md.Name, err = t.str(ops[2]); err != nil {
+		return errors.WithStack(err)
+	}
+	md.Size = ops[3]
+	md.Align = ops[4]
+	md.Encoding = enum.DwarfAttEncoding(ops[5])
+	if len(ops) > 6 {
+		md.Flags = enum.DIFlag(ops[6])
+	}
+	return nil
+}
+
+// decodeDIStringType decodes the operands of a STRING_TYPE record.
+func (t *mdTable) decodeDIStringType(md *metadata.DIStringType, ops []uint64) error {
+	// STRING_TYPE: [distinct, tag, name, stringLength, stringLengthExp,
+	// stringLocationExp?, size, align, encoding]
+	md.Tag = enum.DwarfTag(ops[1])
+	var err error
+	if md.Name, err = t.str(ops[2]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.StringLength, err = t.node(ops[3]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.StringLengthExpression, err = t.node(ops[4]); err != nil {
+		return errors.WithStack(err)
+	}
+	// The string location expression was added at a later time.
+	offset := 5
+	if len(ops) > 8 {
+		if md.StringLocationExpression, err = t.node(ops[5]); err != nil {
+			return errors.WithStack(err)
+		}
+		offset = 6
+	}
+	md.Size = ops[offset]
+	md.Align = ops[offset+1]
+	md.Encoding = enum.DwarfAttEncoding(ops[offset+2])
+	return nil
+}
+
+// decodeDIFile decodes the operands of a FILE record.
+func (t *mdTable) decodeDIFile(md *metadata.DIFile, ops []uint64) error {
+	// FILE: [distinct, filename, directory, checksumkind, checksum, source]
+	var err error
+	if md.Filename, err = t.str(ops[1]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.Directory, err = t.str(ops[2]); err != nil {
+		return errors.WithStack(err)
+	}
+	if len(ops) > 4 && ops[3] != 0 {
+		md.Checksumkind = enum.ChecksumKind(ops[3])
+		if md.Checksum, err = t.str(ops[4]); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if len(ops) > 5 {
+		if md.Source, err = t.str(ops[5]); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// decodeDIDerivedType decodes the operands of a DERIVED_TYPE record.
+func (t *mdTable) decodeDIDerivedType(md *metadata.DIDerivedType, ops []uint64) error {
+	// DERIVED_TYPE: [distinct, tag, name, file, line, scope, baseType, size,
+	// align, offset, flags, extraData, dwarfAddressSpace?, annotations?]
+	md.Tag = enum.DwarfTag(ops[1])
+	var err error
+	if md.Name, err = t.str(ops[2]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.File, err = t.diFile(ops[3]); err != nil {
+		return errors.WithStack(err)
+	}
+	md.Line = int64(ops[4])
+	if md.Scope, err = t.node(ops[5]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.BaseType, err = t.node(ops[6]); err != nil {
+		return errors.WithStack(err)
+	}
+	md.Size = ops[7]
+	md.Align = ops[8]
+	md.Offset = ops[9]
+	md.Flags = enum.DIFlag(ops[10])
+	if md.ExtraData, err = t.node(ops[11]); err != nil {
+		return errors.WithStack(err)
+	}
+	// The DWARF address space is stored as address space + 1; zero if not
+	// present.
+	if len(ops) > 12 && ops[12] != 0 {
+		md.DwarfAddressSpace = ops[12] - 1
+	}
+	if len(ops) > 13 {
+		if md.Annotations, err = t.node(ops[13]); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// decodeDICompositeType decodes the operands of a COMPOSITE_TYPE record.
+func (t *mdTable) decodeDICompositeType(md *metadata.DICompositeType, ops []uint64) error {
+	// COMPOSITE_TYPE: [distinct, tag, name, file, line, scope, baseType, size,
+	// align, offset, flags, elements, runtimeLang, vtableHolder,
+	// templateParams, identifier, discriminator?, dataLocation?, associated?,
+	// allocated?, rank?, annotations?]
+	md.Tag = enum.DwarfTag(ops[1])
+	var err error
+	if md.Name, err = t.str(ops[2]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.File, err = t.diFile(ops[3]); err != nil {
+		return errors.WithStack(err)
+	}
+	md.Line = int64(ops[4])
+	if md.Scope, err = t.node(ops[5]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.BaseType, err = t.node(ops[6]); err != nil {
+		return errors.WithStack(err)
+	}
+	md.Size = ops[7]
+	md.Align = ops[8]
+	md.Offset = ops[9]
+	md.Flags = enum.DIFlag(ops[10])
+	if md.Elements, err = t.tuple(ops[11]); err != nil {
+		return errors.WithStack(err)
+	}
+	md.RuntimeLang = enum.DwarfLang(ops[12])
+	if md.VtableHolder, err = t.node(ops[13]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.TemplateParams, err = t.tuple(ops[14]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.Identifier, err = t.str(ops[15]); err != nil {
+		return errors.WithStack(err)
+	}
+	optional := []*metadata.Field{&md.Discriminator, &md.DataLocation, &md.Associated, &md.Allocated}
+	for i, field := range optional {
+		if len(ops) <= 16+i {
+			break
+		}
+		if *field, err = t.node(ops[16+i]); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if len(ops) > 20 {
+		if md.Rank, err = t.fieldOrInt(ops[20]); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if len(ops) > 21 {
+		if md.Annotations, err = t.node(ops[21]); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// decodeDISubroutineType decodes the operands of a SUBROUTINE_TYPE record.
+func (t *mdTable) decodeDISubroutineType(md *metadata.DISubroutineType, ops []uint64) error {
+	// SUBROUTINE_TYPE: [distinct, flags, types, cc]
+	md.Flags = enum.DIFlag(ops[1])
+	var err error
+	if md.Types, err = t.tuple(ops[2]); err != nil {
+		return errors.WithStack(err)
+	}
+	if len(ops) > 3 {
+		md.CC = enum.DwarfCC(ops[3])
+	}
+	return nil
+}
+
+// decodeDICompileUnit decodes the operands of a COMPILE_UNIT record.
+func (t *mdTable) decodeDICompileUnit(md *metadata.DICompileUnit, ops []uint64) error {
+	// COMPILE_UNIT: [distinct, lang, file, producer, isOptimized, flags,
+	// runtimeVersion, splitDebugFilename, emissionKind, enums, retainedTypes,
+	// subprograms, globals, imports, dwoId?, macros?, splitDebugInlining?,
+	// debugInfoForProfiling?, nameTableKind?, rangesBaseAddress?, sysroot?,
+	// sdk?]
+	md.Language = enum.DwarfLang(ops[1])
+	var err error
+	if md.File, err = t.diFile(ops[2]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.Producer, err = t.str(ops[3]); err != nil {
+		return errors.WithStack(err)
+	}
+	md.IsOptimized = ops[4] != 0
+	if md.Flags, err = t.str(ops[5]); err != nil {
+		return errors.WithStack(err)
+	}
+	md.RuntimeVersion = ops[6]
+	if md.SplitDebugFilename, err = t.str(ops[7]); err != nil {
+		return errors.WithStack(err)
+	}
+	md.EmissionKind = enum.EmissionKind(ops[8])
+	if md.Enums, err = t.tuple(ops[9]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.RetainedTypes, err = t.tuple(ops[10]); err != nil {
+		return errors.WithStack(err)
+	}
+	// Note, ops[11] holds the legacy list of subprograms.
+	if md.Globals, err = t.tuple(ops[12]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.Imports, err = t.tuple(ops[13]); err != nil {
+		return errors.WithStack(err)
+	}
+	if len(ops) > 14 {
+		md.DwoID = ops[14]
+	}
+	if len(ops) > 15 {
+		if md.Macros, err = t.tuple(ops[15]); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	// Split debug inlining defaults to true.
+	md.SplitDebugInlining = len(ops) <= 16 || ops[16] != 0
+	if len(ops) > 17 {
+		md.DebugInfoForProfiling = ops[17] != 0
+	}
+	if len(ops) > 18 {
+		md.NameTableKind = enum.NameTableKind(ops[18])
+	}
+	if len(ops) > 19 {
+		md.RangesBaseAddress = ops[19] != 0
+	}
+	if len(ops) > 20 {
+		if md.Sysroot, err = t.str(ops[20]); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if len(ops) > 21 {
+		if md.SDK, err = t.str(ops[21]); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// decodeDISubprogram decodes the operands of a SUBPROGRAM record.
+func (t *mdTable) decodeDISubprogram(md *metadata.DISubprogram, ops []uint64) error {
+	// SUBPROGRAM: [distinct | hasUnit | hasSPFlags, scope, name, linkageName,
+	// file, line, type, scopeLine, containingType, spFlags, virtualIndex,
+	// flags, unit, templateParams, declaration, retainedNodes,
+	// thisAdjustment, thrownTypes, annotations?]
+	const (
+		hasUnit    = 1 << 1
+		hasSPFlags = 1 << 2
+	)
+	if ops[0]&hasSPFlags == 0 || ops[0]&hasUnit == 0 {
+		return errors.New("support for legacy SUBPROGRAM records not yet implemented")
+	}
+	if len(ops) < 18 {
+		return errors.Errorf("invalid SUBPROGRAM record; expected at least 18 operands, got %d", len(ops))
+	}
+	var err error
+	if md.Scope, err = t.node(ops[1]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.Name, err = t.str(ops[2]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.LinkageName, err = t.str(ops[3]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.File, err = t.diFile(ops[4]); err != nil {
+		return errors.WithStack(err)
+	}
+	md.Line = int64(ops[5])
+	if md.Type, err = t.node(ops[6]); err != nil {
+		return errors.WithStack(err)
+	}
+	md.ScopeLine = int64(ops[7])
+	if md.ContainingType, err = t.node(ops[8]); err != nil {
+		return errors.WithStack(err)
+	}
+	md.SPFlags = enum.DISPFlag(ops[9])
+	md.VirtualIndex = ops[10]
+	md.Flags = enum.DIFlag(ops[11])
+	unit, err := t.node(ops[12])
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if unit != nil {
+		cu, ok := unit.(*metadata.DICompileUnit)
+		if !ok {
+			return errors.Errorf("invalid unit of DISubprogram; expected *metadata.DICompileUnit, got %T", unit)
+		}
+		md.Unit = cu
+	}
+	if md.TemplateParams, err = t.tuple(ops[13]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.Declaration, err = t.node(ops[14]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.RetainedNodes, err = t.tuple(ops[15]); err != nil {
+		return errors.WithStack(err)
+	}
+	md.ThisAdjustment = int64(ops[16])
+	if md.ThrownTypes, err = t.tuple(ops[17]); err != nil {
+		return errors.WithStack(err)
+	}
+	if len(ops) > 18 {
+		if md.Annotations, err = t.node(ops[18]); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// decodeDILexicalBlock decodes the operands of a LEXICAL_BLOCK record.
+func (t *mdTable) decodeDILexicalBlock(md *metadata.DILexicalBlock, ops []uint64) error {
+	// LEXICAL_BLOCK: [distinct, scope, file, line, column]
+	var err error
+	if md.Scope, err = t.node(ops[1]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.File, err = t.diFile(ops[2]); err != nil {
+		return errors.WithStack(err)
+	}
+	md.Line = int64(ops[3])
+	md.Column = int64(ops[4])
+	return nil
+}
+
+// decodeDILexicalBlockFile decodes the operands of a LEXICAL_BLOCK_FILE record.
+func (t *mdTable) decodeDILexicalBlockFile(md *metadata.DILexicalBlockFile, ops []uint64) error {
+	// LEXICAL_BLOCK_FILE: [distinct, scope, file, discriminator]
+	var err error
+	if md.Scope, err = t.node(ops[1]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.File, err = t.diFile(ops[2]); err != nil {
+		return errors.WithStack(err)
+	}
+	md.Discriminator = ops[3]
+	return nil
+}
+
+// decodeDICommonBlock decodes the operands of a COMMON_BLOCK record.
+func (t *mdTable) decodeDICommonBlock(md *metadata.DICommonBlock, ops []uint64) error {
+	// COMMON_BLOCK: [distinct, scope, declaration, name, file, line]
+	var err error
+	if md.Scope, err = t.node(ops[1]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.Declaration, err = t.node(ops[2]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.Name, err = t.str(ops[3]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.File, err = t.diFile(ops[4]); err != nil {
+		return errors.WithStack(err)
+	}
+	md.Line = int64(ops[5])
+	return nil
+}
+
+// decodeDINamespace decodes the operands of a NAMESPACE record.
+func (t *mdTable) decodeDINamespace(md *metadata.DINamespace, ops []uint64) error {
+	// NAMESPACE: [distinct | exportSymbols, scope, name]
+	//
+	// Legacy: [distinct | exportSymbols, scope, file, name, line]
+	md.ExportSymbols = ops[0]&(1<<1) != 0
+	var err error
+	if md.Scope, err = t.node(ops[1]); err != nil {
+		return errors.WithStack(err)
+	}
+	name := ops[2]
+	if len(ops) == 5 {
+		name = ops[3]
+	}
+	if md.Name, err = t.str(name); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// decodeDIMacro decodes the operands of a MACRO record.
+func (t *mdTable) decodeDIMacro(md *metadata.DIMacro, ops []uint64) error {
+	// MACRO: [distinct, macinfo, line, name, value]
+	md.Type = enum.DwarfMacinfo(ops[1])
+	md.Line = int64(ops[2])
+	var err error
+	if md.Name, err = t.str(ops[3]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.Value, err = t.str(ops[4]); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// decodeDIMacroFile decodes the operands of a MACRO_FILE record.
+func (t *mdTable) decodeDIMacroFile(md *metadata.DIMacroFile, ops []uint64) error {
+	// MACRO_FILE: [distinct, macinfo, line, file, elements]
+	md.Type = enum.DwarfMacinfo(ops[1])
+	md.Line = int64(ops[2])
+	var err error
+	if md.File, err = t.diFile(ops[3]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.Nodes, err = t.tuple(ops[4]); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// decodeDIModule decodes the operands of a MODULE record.
+func (t *mdTable) decodeDIModule(md *metadata.DIModule, ops []uint64) error {
+	// MODULE: [distinct, file?, scope, name, configMacros, includePath,
+	// apinotes, line?, isDecl?]
+	if len(ops) > 9 {
+		return errors.Errorf("invalid MODULE record; expected at most 9 operands, got %d", len(ops))
+	}
+	var err error
+	offset := 1
+	if len(ops) >= 8 {
+		if md.File, err = t.node(ops[1]); err != nil {
+			return errors.WithStack(err)
+		}
+		offset = 2
+	}
+	if md.Scope, err = t.node(ops[offset]); err != nil {
+		return errors.WithStack(err)
+	}
+	strs := []*string{&md.Name, &md.ConfigMacros, &md.IncludePath, &md.APINotes}
+	for i, s := range strs {
+		if *s, err = t.str(ops[offset+1+i]); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if len(ops) > 7 {
+		md.Line = int64(ops[7])
+	}
+	if len(ops) > 8 {
+		md.IsDecl = ops[8] != 0
+	}
+	return nil
+}
+
+// decodeDITemplateTypeParameter decodes the operands of a TEMPLATE_TYPE record.
+func (t *mdTable) decodeDITemplateTypeParameter(md *metadata.DITemplateTypeParameter, ops []uint64) error {
+	// TEMPLATE_TYPE: [distinct, name, type, isDefault?]
+	var err error
+	if md.Name, err = t.str(ops[1]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.Type, err = t.node(ops[2]); err != nil {
+		return errors.WithStack(err)
+	}
+	if len(ops) > 3 {
+		md.Defaulted = ops[3] != 0
+	}
+	return nil
+}
+
+// decodeDITemplateValueParameter decodes the operands of a TEMPLATE_VALUE
+// record.
+func (t *mdTable) decodeDITemplateValueParameter(md *metadata.DITemplateValueParameter, ops []uint64) error {
+	// TEMPLATE_VALUE: [distinct, tag, name, type, isDefault?, value]
+	md.Tag = enum.DwarfTag(ops[1])
+	var err error
+	if md.Name, err = t.str(ops[2]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.Type, err = t.node(ops[3]); err != nil {
+		return errors.WithStack(err)
+	}
+	val := ops[4]
+	if len(ops) > 5 {
+		md.Defaulted = ops[4] != 0
+		val = ops[5]
+	}
+	if md.Value, err = t.node(val); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// decodeDIGlobalVariable decodes the operands of a GLOBAL_VAR record.
+func (t *mdTable) decodeDIGlobalVariable(md *metadata.DIGlobalVariable, ops []uint64) error {
+	// GLOBAL_VAR: [distinct | version, scope, name, linkageName, file, line,
+	// type, isLocal, isDefinition, declaration, templateParams, align,
+	// annotations?]
+	if version := ops[0] >> 1; version == 0 {
+		return errors.New("support for legacy GLOBAL_VAR records not yet implemented")
+	}
+	var err error
+	if md.Scope, err = t.node(ops[1]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.Name, err = t.str(ops[2]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.LinkageName, err = t.str(ops[3]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.File, err = t.diFile(ops[4]); err != nil {
+		return errors.WithStack(err)
+	}
+	md.Line = int64(ops[5])
+	if md.Type, err = t.node(ops[6]); err != nil {
+		return errors.WithStack(err)
+	}
+	md.IsLocal = ops[7] != 0
+	md.IsDefinition = ops[8] != 0
+	if md.Declaration, err = t.node(ops[9]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.TemplateParams, err = t.tuple(ops[10]); err != nil {
+		return errors.WithStack(err)
+	}
+	if len(ops) > 11 {
+		md.Align = ops[11]
+	}
+	if len(ops) > 12 {
+		if md.Annotations, err = t.node(ops[12]); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// decodeDILocalVariable decodes the operands of a LOCAL_VAR record.
+func (t *mdTable) decodeDILocalVariable(md *metadata.DILocalVariable, ops []uint64) error {
+	// LOCAL_VAR: [distinct | hasAlignment, scope, name, file, line, type, arg,
+	// flags, align, annotations?]
+	const hasAlignment = 1 << 1
+	if ops[0]&hasAlignment == 0 {
+		return errors.New("support for legacy LOCAL_VAR records not yet implemented")
+	}
+	if len(ops) < 9 {
+		return errors.Errorf("invalid LOCAL_VAR record; expected at least 9 operands, got %d", len(ops))
+	}
+	var err error
+	if md.Scope, err = t.node(ops[1]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.Name, err = t.str(ops[2]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.File, err = t.diFile(ops[3]); err != nil {
+		return errors.WithStack(err)
+	}
+	md.Line = int64(ops[4])
+	if md.Type, err = t.node(ops[5]); err != nil {
+		return errors.WithStack(err)
+	}
+	md.Arg = ops[6]
+	md.Flags = enum.DIFlag(ops[7])
+	md.Align = ops[8]
+	if len(ops) > 9 {
+		if md.Annotations, err = t.node(ops[9]); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// decodeDILabel decodes the operands of a LABEL record.
+func (t *mdTable) decodeDILabel(md *metadata.DILabel, ops []uint64) error {
+	// LABEL: [distinct, scope, name, file, line]
+	var err error
+	if md.Scope, err = t.node(ops[1]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.Name, err = t.str(ops[2]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.File, err = t.diFile(ops[3]); err != nil {
+		return errors.WithStack(err)
+	}
+	md.Line = int64(ops[4])
+	return nil
+}
+
+// decodeDIExpression decodes the operands of an EXPRESSION record.
+func (t *mdTable) decodeDIExpression(md *metadata.DIExpression, ops []uint64) error {
+	// EXPRESSION: [distinct | version, n x element]
+	elems := ops[1:]
+	for len(elems) > 0 {
+		op := enum.DwarfOp(elems[0])
+		md.Fields = append(md.Fields, op)
+		n := dwarfOpNumArgs(op)
+		if n > len(elems)-1 {
+			return errors.Errorf("invalid DIExpression; missing arguments of %v", op)
+		}
+		for i, arg := range elems[1 : 1+n] {
+			if op == enum.DwarfOpLLVMConvert && i == 1 {
+				md.Fields = append(md.Fields, enum.DwarfAttEncoding(arg))
+				continue
+			}
+			md.Fields = append(md.Fields, metadata.UintLit(arg))
+		}
+		elems = elems[1+n:]
+	}
+	return nil
+}
+
+// decodeDIGlobalVariableExpression decodes the operands of a GLOBAL_VAR_EXPR
+// record.
+func (t *mdTable) decodeDIGlobalVariableExpression(md *metadata.DIGlobalVariableExpression, ops []uint64) error {
+	// GLOBAL_VAR_EXPR: [distinct, var, expr]
+	v, err := t.mdAt(ops[1] - 1)
+	if ops[1] == 0 || err != nil {
+		return errors.Errorf("invalid variable of DIGlobalVariableExpression (metadata ID %d)", ops[1])
+	}
+	gv, ok := v.(*metadata.DIGlobalVariable)
+	if !ok {
+		return errors.Errorf("invalid variable of DIGlobalVariableExpression; expected *metadata.DIGlobalVariable, got %T", v)
+	}
+	md.Var = gv
+	expr, err := t.node(ops[2])
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if expr != nil {
+		e, ok := expr.(*metadata.DIExpression)
+		if !ok {
+			return errors.Errorf("invalid expression of DIGlobalVariableExpression; expected *metadata.DIExpression, got %T", expr)
+		}
+		md.Expr = e
+	}
+	return nil
+}
+
+// decodeDIObjCProperty decodes the operands of an OBJC_PROPERTY record.
+func (t *mdTable) decodeDIObjCProperty(md *metadata.DIObjCProperty, ops []uint64) error {
+	// OBJC_PROPERTY: [distinct, name, file, line, getter, setter, attributes,
+	// type]
+	var err error
+	if md.Name, err = t.str(ops[1]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.File, err = t.diFile(ops[2]); err != nil {
+		return errors.WithStack(err)
+	}
+	md.Line = int64(ops[3])
+	if md.Getter, err = t.str(ops[4]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.Setter, err = t.str(ops[5]); err != nil {
+		return errors.WithStack(err)
+	}
+	md.Attributes = ops[6]
+	if md.Type, err = t.node(ops[7]); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// decodeDIImportedEntity decodes the operands of an IMPORTED_ENTITY record.
+func (t *mdTable) decodeDIImportedEntity(md *metadata.DIImportedEntity, ops []uint64) error {
+	// IMPORTED_ENTITY: [distinct, tag, scope, entity, line, name, file?,
+	// elements?]
+	md.Tag = enum.DwarfTag(ops[1])
+	var err error
+	if md.Scope, err = t.node(ops[2]); err != nil {
+		return errors.WithStack(err)
+	}
+	if md.Entity, err = t.node(ops[3]); err != nil {
+		return errors.WithStack(err)
+	}
+	md.Line = int64(ops[4])
+	if md.Name, err = t.str(ops[5]); err != nil {
+		return errors.WithStack(err)
+	}
+	if len(ops) > 6 {
+		if md.File, err = t.diFile(ops[6]); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if len(ops) > 7 {
+		if md.Elements, err = t.tuple(ops[7]); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// --- [ Debug locations ] -----------------------------------------------------
+
+// debugLoc returns the uniqued DILocation metadata node of a DEBUG_LOC record.
+func (t *mdTable) debugLoc(ops []uint64) (*metadata.DILocation, error) {
+	// DEBUG_LOC: [line, col, scope, inlined-at?, isImplicitCode?]
+	if len(ops) < 4 {
+		return nil, errors.Errorf("invalid DEBUG_LOC record; expected at least 4 operands, got %d", len(ops))
+	}
+	scope, err := t.node(ops[2])
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if scope == nil {
+		return nil, errors.New("invalid DEBUG_LOC record; missing scope")
+	}
+	inlinedAt, err := t.diLocation(ops[3])
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	key := debugLoc{line: int64(ops[0]), column: int64(ops[1]), scope: scope, inlinedAt: inlinedAt}
+	if len(ops) > 4 {
+		key.isImplicitCode = ops[4] != 0
+	}
+	if loc, ok := t.locs[key]; ok {
+		return loc, nil
+	}
+	loc := &metadata.DILocation{
+		MetadataID:     -1,
+		Line:           key.line,
+		Column:         key.column,
+		Scope:          key.scope,
+		InlinedAt:      key.inlinedAt,
+		IsImplicitCode: key.isImplicitCode,
+	}
+	t.locs[key] = loc
+	return loc, nil
+}
+
+// --- [ Metadata definitions ] ------------------------------------------------
+
+// addMetadataDefsToModule assigns IDs to metadata nodes in the order used by
+// LLVM when printing modules, and adds them to the IR module as metadata
+// definitions.
+//
+// Metadata nodes are numbered in preorder, starting from the metadata
+// attachments of global variables, followed by the nodes of named metadata
+// definitions, and the metadata attachments and metadata operands of
+// intrinsic calls of each function. DIExpression and DIArgList nodes are
+// printed inline and not numbered.
+func (d *decoder) addMetadataDefsToModule() error {
+	var id int64
+	seen := make(map[metadata.Definition]bool)
+	var visit func(md metadata.Field)
+	visit = func(md metadata.Field) {
+		if isNilField(md) {
+			return
+		}
+		def, ok := md.(metadata.Definition)
+		if !ok || seen[def] {
+			return
+		}
+		if _, ok := def.(*metadata.DIExpression); ok {
+			return
+		}
+		seen[def] = true
+		def.SetID(id)
+		id++
+		d.m.MetadataDefs = append(d.m.MetadataDefs, def)
+		for _, op := range mdOperands(def) {
+			visit(op)
+		}
+	}
+	visitAttachments := func(v interface{}) {
+		a, ok := v.(metadata.Attacher)
+		if !ok {
+			return
+		}
+		for _, md := range a.MDAttachments() {
+			if field, ok := md.Node.(metadata.Field); ok {
+				visit(field)
+			}
+		}
+	}
+	for _, g := range d.m.Globals {
+		visitAttachments(g)
+	}
+	for _, def := range d.namedDefs {
+		for _, node := range def.Nodes {
+			if field, ok := node.(metadata.Field); ok {
+				visit(field)
+			}
+		}
+	}
+	for _, f := range d.m.Funcs {
+		visitAttachments(f)
+		for _, block := range f.Blocks {
+			for _, inst := range block.Insts {
+				if call, ok := inst.(*ir.InstCall); ok && isIntrinsic(call.Callee) {
+					for _, arg := range call.Args {
+						if arg, ok := arg.(*metadata.Value); ok {
+							visit(arg.Value)
+						}
+					}
+				}
+				visitAttachments(inst)
+			}
+			visitAttachments(block.Term)
+		}
+	}
+	return nil
+}
+
+// mdOperands returns the operands of the given metadata node, in the order used
+// by LLVM when numbering metadata nodes.
+func mdOperands(node metadata.Definition) []metadata.Field {
+	switch md := node.(type) {
+	case *metadata.Tuple:
+		return md.Fields
+	case *metadata.DILocation:
+		return []metadata.Field{md.Scope, md.InlinedAt}
+	case *metadata.GenericDINode:
+		return md.Operands
+	case *metadata.DISubrange:
+		return []metadata.Field{md.Count, md.LowerBound, md.UpperBound, md.Stride}
+	case *metadata.DIStringType:
+		return []metadata.Field{md.StringLength, md.StringLengthExpression, md.StringLocationExpression}
+	case *metadata.DIDerivedType:
+		return []metadata.Field{md.File, md.Scope, md.BaseType, md.ExtraData, md.Annotations}
+	case *metadata.DICompositeType:
+		return []metadata.Field{md.File, md.Scope, md.BaseType, md.Elements, md.VtableHolder, md.TemplateParams, md.Discriminator, md.DataLocation, md.Associated, md.Allocated, md.Rank, md.Annotations}
+	case *metadata.DISubroutineType:
+		return []metadata.Field{md.Types}
+	case *metadata.DICompileUnit:
+		return []metadata.Field{md.File, md.Enums, md.RetainedTypes, md.Globals, md.Imports, md.Macros}
+	case *metadata.DISubprogram:
+		return []metadata.Field{md.File, md.Scope, md.Type, md.Unit, md.Declaration, md.RetainedNodes, md.ContainingType, md.TemplateParams, md.ThrownTypes, md.Annotations}
+	case *metadata.DILexicalBlock:
+		return []metadata.Field{md.File, md.Scope}
+	case *metadata.DILexicalBlockFile:
+		return []metadata.Field{md.File, md.Scope}
+	case *metadata.DICommonBlock:
+		return []metadata.Field{md.Scope, md.Declaration, md.File}
+	case *metadata.DINamespace:
+		return []metadata.Field{md.Scope}
+	case *metadata.DIMacroFile:
+		return []metadata.Field{md.File, md.Nodes}
+	case *metadata.DIModule:
+		return []metadata.Field{md.File, md.Scope}
+	case *metadata.DITemplateTypeParameter:
+		return []metadata.Field{md.Type}
+	case *metadata.DITemplateValueParameter:
+		return []metadata.Field{md.Type, md.Value}
+	case *metadata.DIGlobalVariable:
+		return []metadata.Field{md.Scope, md.File, md.Type, md.Declaration, md.TemplateParams, md.Annotations}
+	case *metadata.DILocalVariable:
+		return []metadata.Field{md.Scope, md.File, md.Type, md.Annotations}
+	case *metadata.DILabel:
+		return []metadata.Field{md.Scope, md.File}
+	case *metadata.DIGlobalVariableExpression:
+		return []metadata.Field{md.Var}
+	case *metadata.DIObjCProperty:
+		return []metadata.Field{md.File, md.Type}
+	case *metadata.DIImportedEntity:
+		return []metadata.Field{md.Scope, md.Entity, md.File, md.Elements}
+	}
+	// DIBasicType, DIEnumerator, DIExpression, DIFile and DIMacro have no
+	// metadata node operands.
+	return nil
+}
+
+// ### [ Helper functions ] ####################################################
+
+// node returns the metadata with the given metadata ID + 1; or nil if op is 0.
+func (t *mdTable) node(op uint64) (metadata.Field, error) {
+	if op == 0 {
+		return nil, nil
+	}
+	return t.mdAt(op - 1)
+}
+
+// str returns the metadata string with the given metadata ID + 1; or an empty
+// string if op is 0.
+func (t *mdTable) str(op uint64) (string, error) {
+	md, err := t.node(op)
+	if err != nil || md == nil {
+		return "", errors.WithStack(err)
+	}
+	s, ok := md.(*metadata.String)
+	if !ok {
+		return "", errors.Errorf("invalid metadata string (metadata ID %d); expected *metadata.String, got %T", op-1, md)
+	}
+	return s.Value, nil
+}
+
+// diFile returns the DIFile metadata node with the given metadata ID + 1; or
+// nil if op is 0.
+func (t *mdTable) diFile(op uint64) (*metadata.DIFile, error) {
+	md, err := t.node(op)
+	if err != nil || md == nil {
+		return nil, errors.WithStack(err)
+	}
+	file, ok := md.(*metadata.DIFile)
+	if !ok {
+		return nil, errors.Errorf("invalid file (metadata ID %d); expected *metadata.DIFile, got %T", op-1, md)
+	}
+	return file, nil
+}
+
+// diLocation returns the DILocation metadata node with the given metadata ID +
+// 1; or nil if op is 0.
+func (t *mdTable) diLocation(op uint64) (*metadata.DILocation, error) {
+	md, err := t.node(op)
+	if err != nil || md == nil {
+		return nil, errors.WithStack(err)
+	}
+	loc, ok := md.(*metadata.DILocation)
+	if !ok {
+		return nil, errors.Errorf("invalid location (metadata ID %d); expected *metadata.DILocation, got %T", op-1, md)
+	}
+	return loc, nil
+}
+
+// tuple returns the metadata tuple with the given metadata ID + 1; or nil if op
+// is 0.
+func (t *mdTable) tuple(op uint64) (*metadata.Tuple, error) {
+	md, err := t.node(op)
+	if err != nil || md == nil {
+		return nil, errors.WithStack(err)
+	}
+	tuple, ok := md.(*metadata.Tuple)
+	if !ok {
+		return nil, errors.Errorf("invalid tuple (metadata ID %d); expected *metadata.Tuple, got %T", op-1, md)
+	}
+	return tuple, nil
+}
+
+// fieldOrInt returns the metadata with the given metadata ID + 1, where integer
+// constants are represented as integer literals; or nil if op is 0.
+func (t *mdTable) fieldOrInt(op uint64) (metadata.FieldOrInt, error) {
+	md, err := t.node(op)
+	if err != nil || md == nil {
+		return nil, errors.WithStack(err)
+	}
+	if c, ok := md.(*constant.Int); ok {
+		return metadata.IntLit(c.X.Int64()), nil
+	}
+	return md, nil
+}
+
+// minMetadataOps returns the minimum number of operands of metadata records
+// with the given record code.
+func minMetadataOps(code uint64) int {
+	switch code {
+	case bitc.MetadataValue, bitc.MetadataSubroutineType:
+		return 2 + btoi(code == bitc.MetadataSubroutineType)
+	case bitc.MetadataExpression:
+		return 1
+	case bitc.MetadataSubrange, bitc.MetadataEnumerator, bitc.MetadataFile, bitc.MetadataNamespace, bitc.MetadataTemplateType, bitc.MetadataGlobalVarExpr:
+		return 3
+	case bitc.MetadataGenericDebug, bitc.MetadataLexicalBlockFile:
+		return 4
+	case bitc.MetadataLocation, bitc.MetadataGenericSubrange, bitc.MetadataLexicalBlock, bitc.MetadataMacro, bitc.MetadataMacroFile, bitc.MetadataModule, bitc.MetadataTemplateValue, bitc.MetadataLabel:
+		return 5
+	case bitc.MetadataBasicType, bitc.MetadataCommonBlock, bitc.MetadataImportedEntity:
+		return 6
+	case bitc.MetadataStringType, bitc.MetadataLocalVar, bitc.MetadataObjCProperty:
+		return 8
+	case bitc.MetadataGlobalVar:
+		return 11
+	case bitc.MetadataDerivedType:
+		return 12
+	case bitc.MetadataCompileUnit:
+		return 14
+	case bitc.MetadataCompositeType:
+		return 16
+	case bitc.MetadataSubprogram:
+		return 1
+	}
+	return 0
+}
+
+// btoi returns 1 if b is true, and 0 otherwise.
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// dwarfOpNumArgs returns the number of arguments of the given DWARF expression
+// operation.
+func dwarfOpNumArgs(op enum.DwarfOp) int {
+	switch op {
+	case enum.DwarfOpLLVMConvert, enum.DwarfOpLLVMFragment, enum.DwarfOpBregx:
+		return 2
+	case enum.DwarfOpConstu, enum.DwarfOpConsts, enum.DwarfOpDerefSize, enum.DwarfOpPlusUconst, enum.DwarfOpLLVMTagOffset, enum.DwarfOpLLVMEntryValue, enum.DwarfOpLLVMArg, enum.DwarfOpRegx:
+		return 1
+	}
+	return 0
+}
+
+// isNilField reports whether the given metadata field is nil or a nil pointer.
+func isNilField(md metadata.Field) bool {
+	switch md := md.(type) {
+	case nil:
+		return true
+	case *metadata.Tuple:
+		return md == nil
+	case *metadata.DIFile:
+		return md == nil
+	case *metadata.DICompileUnit:
+		return md == nil
+	case *metadata.DILocation:
+		return md == nil
+	case *metadata.DIGlobalVariable:
+		return md == nil
+	case *metadata.DIExpression:
+		return md == nil
+	}
+	return false
+}
+
+// isIntrinsic reports whether the given callee is an intrinsic function.
+func isIntrinsic(callee value.Value) bool {
+	f, ok := callee.(*ir.Func)
+	return ok && strings.HasPrefix(f.Name(), "llvm.")
+}
@@ -0,0 +1,562 @@
+package bitcode
+
+import (
+	"strings"
+
+	"github.com/llir/llvm/internal/bitc"
+	"github.com/llir/llvm/internal/bitstream"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/pkg/errors"
+)
+
+// parseModuleBlock parses a MODULE_BLOCK.
+func (d *decoder) parseModuleBlock() error {
+	if err := d.r.EnterBlock(); err != nil {
+		return errors.WithStack(err)
+	}
+	// Index of the next function with body.
+	nextBody := 0
+	for {
+		entry, err := d.r.Next()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		switch entry.Kind {
+		case bitstream.EntryEndBlock:
+			return d.resolvePending()
+		case bitstream.EntryRecord:
+			if err := d.parseModuleRecord(entry.Record); err != nil {
+				return errors.Wrapf(err, "unable to parse module %s", describe(entry.Record))
+			}
+		case bitstream.EntrySubBlock:
+			switch entry.BlockID {
+			case bitc.ParamAttrGroupBlockID:
+				err = d.parseParamAttrGroupBlock()
+			case bitc.ParamAttrBlockID:
+				err = d.parseParamAttrBlock()
+			case bitc.TypeBlockIDNew:
+				err = d.parseTypeBlock()
+			case bitc.ConstantsBlockID:
+				d.values, err = d.parseConstantsBlock(d.values)
+			case bitc.MetadataKindBlockID:
+				err = d.parseMetadataKindBlock()
+			case bitc.MetadataBlockID:
+				if err = d.resolvePending(); err == nil {
+					err = d.parseModuleMetadataBlock()
+				}
+			case bitc.OperandBundleTagsBlockID:
+				err = d.parseOperandBundleTagsBlock()
+			case bitc.SyncScopeNamesBlockID:
+				err = d.parseSyncScopeNamesBlock()
+			case bitc.ValueSymtabBlockID:
+				err = d.parseModuleVSTBlock()
+			case bitc.FunctionBlockID:
+				if nextBody >= len(d.funcBodies) {
+					return errors.New("invalid function block; no function with body left")
+				}
+				f := d.funcBodies[nextBody]
+				nextBody++
+				if err = d.resolvePending(); err == nil {
+					err = d.parseFunctionBlock(f)
+				}
+			default:
+				// Skip USELIST_BLOCK and unknown blocks.
+				err = d.r.SkipBlock()
+			}
+			if err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	}
+}
+
+// resolvePending resolves deferred module-level value references.
+func (d *decoder) resolvePending() error {
+	for _, f := range d.pending {
+		if err := f(); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	d.pending = nil
+	return nil
+}
+
+// parseModuleRecord parses the given record of a MODULE_BLOCK.
+func (d *decoder) parseModuleRecord(record *bitstream.Record) error {
+	ops := record.Ops
+	switch record.Code {
+	case bitc.ModuleCodeVersion:
+		// VERSION: [version#]
+		if len(ops) < 1 {
+			return errors.New("missing version")
+		}
+		d.version = ops[0]
+		if d.version > 2 {
+			return errors.Errorf("unsupported module version %d", d.version)
+		}
+	case bitc.ModuleCodeTriple:
+		// TRIPLE: [strchr x N]
+		d.m.TargetTriple = recordString(ops)
+	case bitc.ModuleCodeDatalayout:
+		// DATALAYOUT: [strchr x N]
+		d.m.DataLayout = recordString(ops)
+	case bitc.ModuleCodeSourceFilename:
+		// SOURCE_FILENAME: [namechar x N]
+		d.m.SourceFilename = recordString(ops)
+	case bitc.ModuleCodeAsm:
+		// ASM: [strchr x N]
+		asm := strings.TrimSuffix(recordString(ops), "\n")
+		if len(asm) > 0 {
+			d.m.ModuleAsms = append(d.m.ModuleAsms, strings.Split(asm, "\n")...)
+		}
+	case bitc.ModuleCodeSectionName:
+		// SECTIONNAME: [strchr x N]
+		d.sections = append(d.sections, recordString(ops))
+	case bitc.ModuleCodeGCName:
+		// GCNAME: [strchr x N]
+		d.gcNames = append(d.gcNames, recordString(ops))
+	case bitc.ModuleCodeComdat:
+		return d.parseComdat(ops)
+	case bitc.ModuleCodeGlobalVar:
+		return d.parseGlobalVar(ops)
+	case bitc.ModuleCodeFunction:
+		return d.parseFunction(ops)
+	case bitc.ModuleCodeAlias, bitc.ModuleCodeIFunc:
+		return d.parseIndirectSymbol(record.Code, ops)
+	case bitc.ModuleCodeAliasOld:
+		return errors.New("support for legacy aliases (MODULE_CODE_ALIAS_OLD) not yet implemented")
+	}
+	// Ignore DEPLIB, VSTOFFSET, HASH and unknown records.
+	return nil
+}
+
+// parseName parses the name of a global value or comdat stored in the string
+// table (module version 2 and above). The remaining operands are returned.
+func (d *decoder) parseName(ops []uint64, setName func(name string)) ([]uint64, error) {
+	if d.version < 2 {
+		// Names are stored in the value symbol table.
+		return ops, nil
+	}
+	if len(ops) < 2 {
+		return nil, errors.New("missing string table offset and size of name")
+	}
+	d.strtabNames = append(d.strtabNames, strtabName{offset: ops[0], size: ops[1], setName: setName})
+	return ops[2:], nil
+}
+
+// parseComdat parses a COMDAT record.
+func (d *decoder) parseComdat(ops []uint64) error {
+	// COMDAT: [strtab_offset, strtab_size, selection_kind]
+	// COMDAT: [selection_kind, name_size, namechar x N] (module version < 2)
+	c := &ir.ComdatDef{}
+	ops, err := d.parseName(ops, func(name string) { c.Name = name })
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if len(ops) < 1 {
+		return errors.New("missing selection kind")
+	}
+	kind, err := decodeSelectionKind(ops[0])
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	c.Kind = kind
+	if d.version < 2 {
+		name, _, err := readSizedString(ops[1:])
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		c.Name = name
+	}
+	d.comdats = append(d.comdats, c)
+	return nil
+}
+
+// parseGlobalVar parses a GLOBALVAR record.
+func (d *decoder) parseGlobalVar(ops []uint64) error {
+	// GLOBALVAR: [strtab_offset, strtab_size, pointer type, isconst|explicitType<<1|addrspace<<2,
+	//             initid, linkage, alignment, section, visibility, threadlocal,
+	//             unnamed_addr, externally_initialized, dllstorageclass, comdat,
+	//             attributes, dso_local, partition_offset, partition_size]
+	g := &ir.Global{}
+	ops, err := d.parseName(ops, g.SetName)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if len(ops) < 6 {
+		return errors.Errorf("invalid number of operands; expected at least 6, got %d", len(ops))
+	}
+	contentType, err := d.typeAt(ops[0])
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	g.Immutable = ops[1]&1 != 0
+	if ops[1]&2 != 0 {
+		// Explicit content type.
+		g.AddrSpace = types.AddrSpace(ops[1] >> 2)
+	} else {
+		t, ok := contentType.(*types.PointerType)
+		if !ok || t.ElemType == nil {
+			return errors.Errorf("invalid global variable type; expected typed pointer type, got %v", contentType)
+		}
+		contentType = t.ElemType
+		g.AddrSpace = t.AddrSpace
+	}
+	g.ContentType = contentType
+	g.Typ = d.newPointer(contentType, g.AddrSpace)
+	initID := ops[2]
+	g.Linkage = decodeLinkage(ops[3], initID == 0)
+	if g.Align, err = decodeAlign(ops[4]); err != nil {
+		return errors.WithStack(err)
+	}
+	if g.Section, err = d.sectionName(ops[5]); err != nil {
+		return errors.WithStack(err)
+	}
+	if len(ops) > 6 && !bitc.IsLocalLinkage(g.Linkage) {
+		g.Visibility = decodeVisibility(ops[6])
+	}
+	if len(ops) > 7 {
+		g.TLSModel = decodeTLSModel(ops[7])
+	}
+	if len(ops) > 8 {
+		g.UnnamedAddr = decodeUnnamedAddr(ops[8])
+	}
+	if len(ops) > 9 {
+		g.ExternallyInitialized = ops[9] != 0
+	}
+	if len(ops) > 10 {
+		g.DLLStorageClass = decodeDLLStorageClass(ops[10])
+	}
+	if len(ops) > 11 {
+		if g.Comdat, err = d.comdatAt(ops[11]); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if len(ops) > 12 {
+		as, err := d.attrsOf(ops[12])
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		g.FuncAttrs = as.funcAttrs
+	}
+	if len(ops) > 13 {
+		g.Preemption = decodePreemption(ops[13], g.Linkage, g.Visibility)
+	}
+	if len(ops) > 15 {
+		g.Partition = d.strtabString(ops[14], ops[15], func(s string) { g.Partition = s })
+	}
+	if initID != 0 {
+		d.pending = append(d.pending, func() error {
+			init, err := d.moduleConstantAt(initID - 1)
+			if err != nil {
+				return errors.Wrap(err, "invalid global variable initializer")
+			}
+			g.Init = init
+			return nil
+		})
+	}
+	d.m.Globals = append(d.m.Globals, g)
+	d.values = append(d.values, g)
+	return nil
+}
+
+// parseFunction parses a FUNCTION record.
+func (d *decoder) parseFunction(ops []uint64) error {
+	// FUNCTION: [strtab_offset, strtab_size, type, callingconv, isproto,
+	//            linkage, paramattrs, alignment, section, visibility, gc,
+	//            unnamed_addr, prologuedata, dllstorageclass, comdat,
+	//            prefixdata, personalityfn, dso_local, addrspace,
+	//            partition_offset, partition_size]
+	f := &ir.Func{Parent: d.m}
+	ops, err := d.parseName(ops, f.SetName)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if len(ops) < 8 {
+		return errors.Errorf("invalid number of operands; expected at least 8, got %d", len(ops))
+	}
+	t, err := d.typeAt(ops[0])
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if ptr, ok := t.(*types.PointerType); ok && ptr.ElemType != nil {
+		// Pointer to function type (module version < 1).
+		t = ptr.ElemType
+	}
+	sig, ok := t.(*types.FuncType)
+	if !ok {
+		return errors.Errorf("invalid function type; expected *types.FuncType, got %T", t)
+	}
+	f.Sig = sig
+	for _, paramType := range sig.Params {
+		f.Params = append(f.Params, ir.NewParam("", paramType))
+	}
+	f.CallingConv = decodeCallingConv(ops[1])
+	isProto := ops[2] != 0
+	f.Linkage = decodeLinkage(ops[3], false)
+	as, err := d.attrsOf(ops[4])
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	f.FuncAttrs = as.funcAttrs
+	f.ReturnAttrs = as.retAttrs
+	for i, param := range f.Params {
+		param.Attrs = as.paramAttrs[i]
+	}
+	if f.Align, err = decodeAlign(ops[5]); err != nil {
+		return errors.WithStack(err)
+	}
+	if f.Section, err = d.sectionName(ops[6]); err != nil {
+		return errors.WithStack(err)
+	}
+	if !bitc.IsLocalLinkage(f.Linkage) {
+		f.Visibility = decodeVisibility(ops[7])
+	}
+	if len(ops) > 8 && ops[8] != 0 {
+		if ops[8] > uint64(len(d.gcNames)) {
+			return errors.Errorf("invalid garbage collector ID %d", ops[8])
+		}
+		f.GC = d.gcNames[ops[8]-1]
+	}
+	if len(ops) > 9 {
+		f.UnnamedAddr = decodeUnnamedAddr(ops[9])
+	}
+	if len(ops) > 10 && ops[10] != 0 {
+		d.deferConstant(ops[10]-1, func(c constant.Constant) { f.Prologue = c })
+	}
+	if len(ops) > 11 {
+		f.DLLStorageClass = decodeDLLStorageClass(ops[11])
+	}
+	if len(ops) > 12 {
+		if f.Comdat, err = d.comdatAt(ops[12]); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if len(ops) > 13 && ops[13] != 0 {
+		d.deferConstant(ops[13]-1, func(c constant.Constant) { f.Prefix = c })
+	}
+	if len(ops) > 14 && ops[14] != 0 {
+		d.deferConstant(ops[14]-1, func(c constant.Constant) { f.Personality = c })
+	}
+	if len(ops) > 15 {
+		f.Preemption = decodePreemption(ops[15], f.Linkage, f.Visibility)
+	}
+	if len(ops) > 16 {
+		f.AddrSpace = types.AddrSpace(ops[16])
+	}
+	f.Typ = d.newPointer(sig, f.AddrSpace)
+	if len(ops) > 18 {
+		f.Partition = d.strtabString(ops[17], ops[18], func(s string) { f.Partition = s })
+	}
+	if !isProto {
+		d.funcBodies = append(d.funcBodies, f)
+	}
+	d.m.Funcs = append(d.m.Funcs, f)
+	d.values = append(d.values, f)
+	return nil
+}
+
+// parseIndirectSymbol parses an ALIAS or IFUNC record.
+func (d *decoder) parseIndirectSymbol(code uint64, ops []uint64) error {
+	// ALIAS: [strtab_offset, strtab_size, alias value type, addrspace,
+	//         aliasee val#, linkage, visibility, dllstorageclass, threadlocal,
+	//         unnamed_addr, dso_local, partition_offset, partition_size]
+	// IFUNC: [strtab_offset, strtab_size, ifunc value type, addrspace,
+	//         resolver val#, linkage, visibility, dso_local, partition_offset,
+	//         partition_size]
+	alias, ifunc := &ir.Alias{}, &ir.IFunc{}
+	setName := alias.SetName
+	if code == bitc.ModuleCodeIFunc {
+		setName = ifunc.SetName
+	}
+	ops, err := d.parseName(ops, setName)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if len(ops) < 4 {
+		return errors.Errorf("invalid number of operands; expected at least 4, got %d", len(ops))
+	}
+	contentType, err := d.typeAt(ops[0])
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	typ := d.newPointer(contentType, types.AddrSpace(ops[1]))
+	symbolID := ops[2]
+	linkage := decodeLinkage(ops[3], false)
+	ops = ops[4:]
+	var visibility enum.Visibility
+	if len(ops) > 0 {
+		if !bitc.IsLocalLinkage(linkage) {
+			visibility = decodeVisibility(ops[0])
+		}
+		ops = ops[1:]
+	}
+	if code == bitc.ModuleCodeAlias {
+		if len(ops) > 0 {
+			alias.DLLStorageClass = decodeDLLStorageClass(ops[0])
+			ops = ops[1:]
+		}
+		if len(ops) > 0 {
+			alias.TLSModel = decodeTLSModel(ops[0])
+			ops = ops[1:]
+		}
+		if len(ops) > 0 {
+			alias.UnnamedAddr = decodeUnnamedAddr(ops[0])
+			ops = ops[1:]
+		}
+	}
+	var preemption enum.Preemption
+	if len(ops) > 0 {
+		preemption = decodePreemption(ops[0], linkage, visibility)
+		ops = ops[1:]
+	}
+	if code == bitc.ModuleCodeIFunc {
+		ifunc.Typ = typ
+		ifunc.ContentType = contentType
+		ifunc.Linkage = linkage
+		ifunc.Preemption = preemption
+		ifunc.Visibility = visibility
+		if len(ops) > 1 {
+			ifunc.Partition = d.strtabString(ops[0], ops[1], func(s string) { ifunc.Partition = s })
+		}
+		d.deferConstant(symbolID, func(c constant.Constant) { ifunc.Resolver = c })
+		d.m.IFuncs = append(d.m.IFuncs, ifunc)
+		d.values = append(d.values, ifunc)
+		return nil
+	}
+	alias.Typ = typ
+	alias.ContentType = contentType
+	alias.Linkage = linkage
+	alias.Preemption = preemption
+	alias.Visibility = visibility
+	if len(ops) > 1 {
+		alias.Partition = d.strtabString(ops[0], ops[1], func(s string) { alias.Partition = s })
+	}
+	d.deferConstant(symbolID, func(c constant.Constant) { alias.Aliasee = c })
+	d.m.Aliases = append(d.m.Aliases, alias)
+	d.values = append(d.values, alias)
+	return nil
+}
+
+// parseModuleVSTBlock parses a module-level VALUE_SYMTAB_BLOCK.
+func (d *decoder) parseModuleVSTBlock() error {
+	if err := d.r.EnterBlock(); err != nil {
+		return errors.WithStack(err)
+	}
+	return d.parseRecords(func(record *bitstream.Record) error {
+		// Note, names of global values are stored in the string table for module
+		// version 2 and above, in which case the value symbol table only holds
+		// function offsets.
+		if d.version >= 2 {
+			return nil
+		}
+		var id uint64
+		var name string
+		switch record.Code {
+		case bitc.VSTCodeEntry:
+			// ENTRY: [valueid, namechar x N]
+			if len(record.Ops) < 1 {
+				return errors.New("invalid ENTRY record; missing value ID")
+			}
+			id, name = record.Ops[0], recordString(record.Ops[1:])
+		case bitc.VSTCodeFnEntry:
+			// FNENTRY: [valueid, offset, namechar x N]
+			if len(record.Ops) < 2 {
+				return errors.New("invalid FNENTRY record; missing value ID or offset")
+			}
+			id, name = record.Ops[0], recordString(record.Ops[2:])
+		default:
+			return nil
+		}
+		v, err := d.moduleValueAt(id)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		named, ok := v.(interface{ SetName(name string) })
+		if !ok {
+			return errors.Errorf("invalid value symbol table entry; unable to set name of %T", v)
+		}
+		named.SetName(name)
+		return nil
+	})
+}
+
+// parseOperandBundleTagsBlock parses an OPERAND_BUNDLE_TAGS_BLOCK.
+func (d *decoder) parseOperandBundleTagsBlock() error {
+	if err := d.r.EnterBlock(); err != nil {
+		return errors.WithStack(err)
+	}
+	return d.parseRecords(func(record *bitstream.Record) error {
+		if record.Code == bitc.OperandBundleTagCode {
+			// OPERAND_BUNDLE_TAG: [strchr x N]
+			d.bundleTags = append(d.bundleTags, recordString(record.Ops))
+		}
+		return nil
+	})
+}
+
+// parseSyncScopeNamesBlock parses a SYNC_SCOPE_NAMES_BLOCK.
+func (d *decoder) parseSyncScopeNamesBlock() error {
+	if err := d.r.EnterBlock(); err != nil {
+		return errors.WithStack(err)
+	}
+	return d.parseRecords(func(record *bitstream.Record) error {
+		if record.Code == bitc.SyncScopeName {
+			// SYNC_SCOPE_NAME: [strchr x N]
+			d.syncScopes = append(d.syncScopes, recordString(record.Ops))
+		}
+		return nil
+	})
+}
+
+// ### [ Helper functions ] ####################################################
+
+// sectionName returns the name of the section with the given 1-based section
+// ID; or an empty string if id is 0.
+func (d *decoder) sectionName(id uint64) (string, error) {
+	if id == 0 {
+		return "", nil
+	}
+	if id > uint64(len(d.sections)) {
+		return "", errors.Errorf("invalid section ID %d; exceeds number of sections (%d)", id, len(d.sections))
+	}
+	return d.sections[id-1], nil
+}
+
+// comdatAt returns the comdat with the given 1-based comdat ID; or nil if id is
+// 0.
+func (d *decoder) comdatAt(id uint64) (*ir.ComdatDef, error) {
+	if id == 0 {
+		return nil, nil
+	}
+	if id > uint64(len(d.comdats)) {
+		return nil, errors.Errorf("invalid comdat ID %d; exceeds number of comdats (%d)", id, len(d.comdats))
+	}
+	return d.comdats[id-1], nil
+}
+
+// strtabString registers the string of the string table at the given offset
+// and size, to be set by setString once the string table has been parsed. The
+// empty string is returned for convenience.
+func (d *decoder) strtabString(offset, size uint64, setString func(s string)) string {
+	if size > 0 {
+		d.strtabNames = append(d.strtabNames, strtabName{offset: offset, size: size, setName: setString})
+	}
+	return ""
+}
+
+// deferConstant resolves the module-level constant with the given value ID
+// once the module-level constants have been decoded, and passes it to set.
+func (d *decoder) deferConstant(id uint64, set func(c constant.Constant)) {
+	d.pending = append(d.pending, func() error {
+		c, err := d.moduleConstantAt(id)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		set(c)
+		return nil
+	})
+}
@@ -0,0 +1,138 @@
+; ModuleID = 'instructions.bc'
+source_filename = "instructions.ll"
+
+%T = type { i32, [2 x float] }
+
+@g = global i32 0
+@h = global [2 x i32] zeroinitializer
+@addrs = global [2 x i8*] [i8* blockaddress(@ibr, %a), i8* blockaddress(@ibr, %b)]
+
+declare i32 @__gxx_personality_v0(...)
+
+declare void @may_throw(i32)
+
+declare i32 @vfn(i32, ...)
+
+; Function Attrs: nofree nosync nounwind willreturn
+declare void @llvm.va_start(i8*) #0
+
+; Function Attrs: nofree nosync nounwind willreturn
+declare void @llvm.va_end(i8*) #0
+
+declare i32 @__CxxFrameHandler3(...)
+
+; Function Attrs: nounwind
+declare void @nounwind_fn(i32* nocapture readonly, i8 zeroext) #1
+
+define i32 @loop(i32 %n) {
+entry:
+  br label %header
+
+header:                                           ; preds = %body, %entry
+  %i = phi i32 [ 0, %entry ], [ %next, %body ]
+  %acc = phi fast float [ 0.000000e+00, %entry ], [ %sum, %body ]
+  %cmp = icmp slt i32 %i, %n
+  br i1 %cmp, label %body, label %exit
+
+body:                                             ; preds = %header
+  %f = sitofp i32 %i to float
+  %sum = fadd nnan ninf float %acc, %f
+  %next = add nuw nsw i32 %i, 1
+  br label %header
+
+exit:                                             ; preds = %header
+  %fc = fcmp fast olt float %acc, 1.000000e+00
+  %sel = select i1 %fc, i32 %i, i32 -1
+  %fr = freeze i32 %sel
+  %neg = fneg float %acc
+  %ex = udiv exact i32 %fr, 2
+  ret i32 %ex
+}
+
+define void @eh(i32 %x) personality i32 (...)* @__gxx_personality_v0 {
+entry:
+  invoke void @may_throw(i32 %x)
+          to label %cont unwind label %lpad
+
+cont:                                             ; preds = %entry
+  switch i32 %x, label %def [
+    i32 0, label %def
+    i32 7, label %cont2
+  ]
+
+cont2:                                            ; preds = %cont
+  ret void
+
+def:                                              ; preds = %cont, %cont
+  unreachable
+
+lpad:                                             ; preds = %entry
+  %lp = landingpad { i8*, i32 }
+          cleanup
+          catch i8* null
+          filter [1 x i8*] zeroinitializer
+  resume { i8*, i32 } %lp
+}
+
+define void @winEH() personality i32 (...)* @__CxxFrameHandler3 {
+entry:
+  invoke void @may_throw(i32 1)
+          to label %exit unwind label %dispatch
+
+dispatch:                                         ; preds = %entry
+  %cs = catchswitch within none [label %handler] unwind label %cleanup
+
+handler:                                          ; preds = %dispatch
+  %cp = catchpad within %cs [i8* null, i32 64, i8* null]
+  catchret from %cp to label %exit
+
+cleanup:                                          ; preds = %dispatch
+  %cl = cleanuppad within none []
+  cleanupret from %cl unwind to caller
+
+exit:                                             ; preds = %handler, %entry
+  ret void
+}
+
+define i32 @ibr(i32 %i) {
+entry:
+  %p = getelementptr inbounds [2 x i8*], [2 x i8*]* @addrs, i32 0, i32 %i
+  %addr = load i8*, i8** %p, align 8
+  indirectbr i8* %addr, [label %a, label %b]
+
+a:                                                ; preds = %entry
+  ret i32 1
+
+b:                                                ; preds = %entry
+  ret i32 2
+}
+
+define i32 @mem(i32* %p, %T* %t, i32 %n, ...) {
+  %ap = alloca i8*, align 8
+  %arr = alloca i32, i32 %n, align 16
+  %ap1 = bitcast i8** %ap to i8*
+  call void @llvm.va_start(i8* %ap1)
+  %va = va_arg i8** %ap, i32
+  call void @llvm.va_end(i8* %ap1)
+  %v = load volatile i32, i32* %p, align 4
+  %la = load atomic i32, i32* %p seq_cst, align 4
+  store atomic i32 %v, i32* %p release, align 4
+  %cx = cmpxchg weak volatile i32* %p, i32 %v, i32 %la syncscope("singlethread") acq_rel monotonic, align 4
+  %ok = extractvalue { i32, i1 } %cx, 1
+  %rmw = atomicrmw xchg i32* %p, i32 5 acquire, align 4
+  fence syncscope("singlethread") seq_cst
+  %fp = getelementptr %T, %T* %t, i64 0, i32 1, i64 1
+  %fl = load float, float* %fp, align 4
+  %agg = insertvalue %T undef, i32 %v, 0
+  %vec = insertelement <2 x i32> undef, i32 %rmw, i32 0
+  %shuf = shufflevector <2 x i32> %vec, <2 x i32> undef, <2 x i32> zeroinitializer
+  %elt = extractelement <2 x i32> %shuf, i32 1
+  %call = tail call i32 (i32, ...) @vfn(i32 %elt, i32 %va, float %fl)
+  call void @nounwind_fn(i32* nonnull %p, i8 zeroext 1) #2 [ "deopt"(i32 %v), "gc-live"(i32* %p) ]
+  %r = musttail call i32 (i32*, %T*, i32, ...) @mem(i32* %p, %T* %t, i32 %call, ...)
+  ret i32 %r
+}
+
+attributes #0 = { nofree nosync nounwind willreturn }
+attributes #1 = { nounwind }
+attributes #2 = { cold }
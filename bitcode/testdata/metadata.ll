@@ -0,0 +1,65 @@
+; ModuleID = 'metadata.bc'
+source_filename = "metadata.c"
+
+%struct.point = type { i32, i32 }
+
+@g = global i32 42, align 4, !dbg !0
+@p = global %struct.point zeroinitializer, align 4, !dbg !6
+
+define i32 @add(i32 %a, i32 %b) !dbg !16 {
+entry:
+  %a.addr = alloca i32, align 4
+  store i32 %a, i32* %a.addr, align 4
+  call void @llvm.dbg.declare(metadata i32* %a.addr, metadata !19, metadata !DIExpression()), !dbg !20
+  call void @llvm.dbg.value(metadata i32 %b, metadata !21, metadata !DIExpression(DW_OP_plus_uconst, 4, DW_OP_stack_value)), !dbg !20
+  %0 = load i32, i32* %a.addr, align 4, !dbg !22, !tbaa !24
+  %add = add nsw i32 %0, %b, !dbg !22
+  call void @llvm.dbg.value(metadata !DIArgList(i32 %add, i32 %b), metadata !21, metadata !DIExpression(DW_OP_LLVM_arg, 0, DW_OP_LLVM_arg, 1, DW_OP_plus, DW_OP_stack_value)), !dbg !20
+  br label %exit, !dbg !22
+
+exit:                                             ; preds = %entry
+  %r = phi i32 [ %add, %entry ]
+  ret i32 %r, !dbg !28
+}
+
+; Function Attrs: nofree nosync nounwind readnone speculatable willreturn
+declare void @llvm.dbg.declare(metadata, metadata, metadata) #0
+
+; Function Attrs: nofree nosync nounwind readnone speculatable willreturn
+declare void @llvm.dbg.value(metadata, metadata, metadata) #0
+
+attributes #0 = { nofree nosync nounwind readnone speculatable willreturn }
+
+!llvm.dbg.cu = !{!2}
+!llvm.module.flags = !{!13, !14}
+!llvm.ident = !{!15}
+
+!0 = !DIGlobalVariableExpression(var: !1, expr: !DIExpression())
+!1 = distinct !DIGlobalVariable(name: "g", scope: !2, file: !3, line: 1, type: !11, isLocal: false, isDefinition: true)
+!2 = distinct !DICompileUnit(language: DW_LANG_C99, file: !3, producer: "clang", isOptimized: false, runtimeVersion: 0, emissionKind: FullDebug, enums: !4, globals: !5, splitDebugInlining: false, nameTableKind: None)
+!3 = !DIFile(filename: "metadata.c", directory: "/tmp", checksumkind: CSK_MD5, checksum: "0123456789abcdef0123456789abcdef")
+!4 = !{}
+!5 = !{!0, !6}
+!6 = !DIGlobalVariableExpression(var: !7, expr: !DIExpression())
+!7 = distinct !DIGlobalVariable(name: "p", scope: !2, file: !3, line: 2, type: !8, isLocal: false, isDefinition: true)
+!8 = distinct !DICompositeType(tag: DW_TAG_structure_type, name: "point", file: !3, line: 2, size: 64, elements: !9)
+!9 = !{!10, !12}
+!10 = !DIDerivedType(tag: DW_TAG_member, name: "x", scope: !8, file: !3, line: 2, baseType: !11, size: 32)
+!11 = !DIBasicType(name: "int", size: 32, encoding: DW_ATE_signed)
+!12 = !DIDerivedType(tag: DW_TAG_member, name: "y", scope: !8, file: !3, line: 2, baseType: !11, size: 32, offset: 32)
+!13 = !{i32 7, !"Dwarf Version", i32 5}
+!14 = !{i32 2, !"Debug Info Version", i32 3}
+!15 = !{!"clang version 14.0.6"}
+!16 = distinct !DISubprogram(name: "add", scope: !3, file: !3, line: 3, type: !17, scopeLine: 3, flags: DIFlagPrototyped, spFlags: DISPFlagDefinition, unit: !2, retainedNodes: !4)
+!17 = !DISubroutineType(types: !18)
+!18 = !{!11, !11, !11}
+!19 = !DILocalVariable(name: "a", arg: 1, scope: !16, file: !3, line: 3, type: !11)
+!20 = !DILocation(line: 3, column: 13, scope: !16)
+!21 = !DILocalVariable(name: "b", arg: 2, scope: !16, file: !3, line: 3, type: !11)
+!22 = !DILocation(line: 4, column: 11, scope: !23)
+!23 = distinct !DILexicalBlock(scope: !16, file: !3, line: 4, column: 3)
+!24 = !{!25, !25, i64 0}
+!25 = !{!"int", !26, i64 0}
+!26 = !{!"omnipotent char", !27, i64 0}
+!27 = !{!"Simple C TBAA"}
+!28 = !DILocation(line: 5, column: 2, scope: !16)
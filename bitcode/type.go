@@ -0,0 +1,262 @@
+package bitcode
+
+import (
+	"strconv"
+
+	"github.com/llir/llvm/internal/bitc"
+	"github.com/llir/llvm/internal/bitstream"
+	"github.com/llir/llvm/ir/types"
+	"github.com/pkg/errors"
+)
+
+// parseTypeBlock parses a TYPE_BLOCK_NEW.
+func (d *decoder) parseTypeBlock() error {
+	if err := d.r.EnterBlock(); err != nil {
+		return errors.WithStack(err)
+	}
+	// Number of type table entries decoded so far.
+	n := 0
+	// Name of the next identified struct type (STRUCT_NAME).
+	var structName string
+	err := d.parseRecords(func(record *bitstream.Record) error {
+		if record.Code == bitc.TypeCodeNumEntry {
+			// NUMENTRY: [numentries]
+			if len(record.Ops) < 1 {
+				return errors.New("invalid NUMENTRY record; missing number of entries")
+			}
+			if record.Ops[0] > uint64(len(d.types)) {
+				d.types = append(d.types, make([]types.Type, int(record.Ops[0])-len(d.types))...)
+			}
+			return nil
+		}
+		if record.Code == bitc.TypeCodeStructName {
+			// STRUCT_NAME: [strchr x N]
+			structName = recordString(record.Ops)
+			return nil
+		}
+		if n >= len(d.types) {
+			return errors.Errorf("invalid type table; number of types exceeds NUMENTRY (%d)", len(d.types))
+		}
+		t, err := d.parseType(record, n, structName)
+		if err != nil {
+			return errors.Wrapf(err, "unable to decode type %d", n)
+		}
+		switch record.Code {
+		case bitc.TypeCodeStructNamed, bitc.TypeCodeOpaque:
+			structName = ""
+		}
+		d.types[n] = t
+		n++
+		return nil
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if n != len(d.types) {
+		return errors.Errorf("invalid type table; expected %d types, got %d", len(d.types), n)
+	}
+	// Assign numeric names to unnamed identified struct types.
+	id := 0
+	for _, t := range d.types {
+		if t, ok := t.(*types.StructType); ok && t.TypeName == "" && d.identified[t] {
+			t.TypeName = strconv.Itoa(id)
+			id++
+		}
+	}
+	return nil
+}
+
+// parseType parses the type of the given type record, with the specified type
+// ID. The structName parameter specifies the name of identified struct types.
+func (d *decoder) parseType(record *bitstream.Record, id int, structName string) (types.Type, error) {
+	ops := record.Ops
+	switch record.Code {
+	case bitc.TypeCodeVoid:
+		return types.Void, nil
+	case bitc.TypeCodeHalf:
+		return types.Half, nil
+	case bitc.TypeCodeFloat:
+		return types.Float, nil
+	case bitc.TypeCodeDouble:
+		return types.Double, nil
+	case bitc.TypeCodeX86FP80:
+		return types.X86_FP80, nil
+	case bitc.TypeCodeFP128:
+		return types.FP128, nil
+	case bitc.TypeCodePPCFP128:
+		return types.PPC_FP128, nil
+	case bitc.TypeCodeLabel:
+		return types.Label, nil
+	case bitc.TypeCodeMetadata:
+		return types.Metadata, nil
+	case bitc.TypeCodeX86MMX:
+		return types.MMX, nil
+	case bitc.TypeCodeToken:
+		return types.Token, nil
+	case bitc.TypeCodeInteger:
+		// INTEGER: [width]
+		if len(ops) < 1 {
+			return nil, errors.New("invalid INTEGER record; missing bit width")
+		}
+		return types.NewInt(ops[0]), nil
+	case bitc.TypeCodePointer:
+		// POINTER: [pointee type, address space]
+		if len(ops) < 1 {
+			return nil, errors.New("invalid POINTER record; missing pointee type")
+		}
+		elemType, err := d.typeRef(ops[0], id)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		t := types.NewPointer(elemType)
+		if len(ops) >= 2 {
+			t.AddrSpace = types.AddrSpace(ops[1])
+		}
+		return t, nil
+	case bitc.TypeCodeOpaquePointer:
+		// OPAQUE_POINTER: [address space]
+		d.opaquePointers = true
+		var addrSpace types.AddrSpace
+		if len(ops) >= 1 {
+			addrSpace = types.AddrSpace(ops[0])
+		}
+		return types.NewOpaquePointer(addrSpace), nil
+	case bitc.TypeCodeFunction:
+		// FUNCTION: [vararg, retty, paramty x N]
+		if len(ops) < 2 {
+			return nil, errors.Errorf("invalid FUNCTION record; expected at least 2 operands, got %d", len(ops))
+		}
+		return d.funcType(ops[0] != 0, ops[1], ops[2:], id)
+	case bitc.TypeCodeFunctionOld:
+		// FUNCTION: [vararg, attrid, retty, paramty x N]
+		if len(ops) < 3 {
+			return nil, errors.Errorf("invalid FUNCTION record; expected at least 3 operands, got %d", len(ops))
+		}
+		return d.funcType(ops[0] != 0, ops[2], ops[3:], id)
+	case bitc.TypeCodeArray:
+		// ARRAY: [numelts, eltty]
+		if len(ops) < 2 {
+			return nil, errors.Errorf("invalid ARRAY record; expected 2 operands, got %d", len(ops))
+		}
+		elemType, err := d.typeRef(ops[1], id)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return types.NewArray(ops[0], elemType), nil
+	case bitc.TypeCodeVector:
+		// VECTOR: [numelts, eltty, scalable]
+		if len(ops) < 2 {
+			return nil, errors.Errorf("invalid VECTOR record; expected at least 2 operands, got %d", len(ops))
+		}
+		elemType, err := d.typeRef(ops[1], id)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		t := types.NewVector(ops[0], elemType)
+		if len(ops) >= 3 {
+			t.Scalable = ops[2] != 0
+		}
+		return t, nil
+	case bitc.TypeCodeStructAnon:
+		// STRUCT_ANON: [ispacked, eltty x N]
+		if len(ops) < 1 {
+			return nil, errors.New("invalid STRUCT_ANON record; missing packed flag")
+		}
+		fields, err := d.typeRefs(ops[1:], id)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		t := types.NewStruct(fields...)
+		t.Packed = ops[0] != 0
+		return t, nil
+	case bitc.TypeCodeStructNamed:
+		// STRUCT_NAMED: [ispacked, eltty x N]
+		if len(ops) < 1 {
+			return nil, errors.New("invalid STRUCT_NAMED record; missing packed flag")
+		}
+		t := d.identifiedStruct(id, structName)
+		fields, err := d.typeRefs(ops[1:], id)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		t.Fields = fields
+		t.Packed = ops[0] != 0
+		t.Opaque = false
+		return t, nil
+	case bitc.TypeCodeOpaque:
+		// OPAQUE: []
+		t := d.identifiedStruct(id, structName)
+		t.Opaque = true
+		return t, nil
+	case bitc.TypeCodeBFloat:
+		return nil, errors.New("support for bfloat type not yet implemented")
+	case bitc.TypeCodeX86AMX:
+		return nil, errors.New("support for x86_amx type not yet implemented")
+	case bitc.TypeCodeTargetType:
+		return nil, errors.New("support for target extension types not yet implemented")
+	default:
+		return nil, errors.Errorf("unknown type code %d", record.Code)
+	}
+}
+
+// funcType returns the function type with the given return type and parameter
+// type IDs.
+func (d *decoder) funcType(variadic bool, retTypeID uint64, paramTypeIDs []uint64, id int) (*types.FuncType, error) {
+	retType, err := d.typeRef(retTypeID, id)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	params, err := d.typeRefs(paramTypeIDs, id)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	t := types.NewFunc(retType, params...)
+	t.Variadic = variadic
+	return t, nil
+}
+
+// identifiedStruct returns the identified struct type with the given type ID,
+// which may have been created by a forward reference.
+func (d *decoder) identifiedStruct(id int, name string) *types.StructType {
+	t, ok := d.types[id].(*types.StructType)
+	if !ok {
+		t = &types.StructType{}
+		d.identified[t] = true
+	}
+	t.TypeName = name
+	return t
+}
+
+// typeRefs returns the types with the given type IDs, referenced from the type
+// with the specified ID.
+func (d *decoder) typeRefs(ids []uint64, id int) ([]types.Type, error) {
+	ts := make([]types.Type, len(ids))
+	for i, typeID := range ids {
+		t, err := d.typeRef(typeID, id)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		ts[i] = t
+	}
+	return ts, nil
+}
+
+// typeRef returns the type with the given type ID, referenced from the type
+// with the specified ID. Forward references are only valid for identified
+// struct types, for which a placeholder is created.
+func (d *decoder) typeRef(typeID uint64, id int) (types.Type, error) {
+	if typeID >= uint64(len(d.types)) {
+		return nil, errors.Errorf("invalid type ID %d; exceeds number of types (%d)", typeID, len(d.types))
+	}
+	if t := d.types[typeID]; t != nil {
+		return t, nil
+	}
+	if int(typeID) < id {
+		return nil, errors.Errorf("invalid type ID %d; type not yet defined", typeID)
+	}
+	// Forward reference to identified struct type.
+	t := &types.StructType{}
+	d.identified[t] = true
+	d.types[typeID] = t
+	return t, nil
+}
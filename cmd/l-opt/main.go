@@ -0,0 +1,294 @@
+// The l-opt tool runs pipelines of transformation passes over LLVM IR modules.
+//
+// Usage:
+//
+//	l-opt [OPTION]... FILE.ll
+//
+// The pass pipeline is specified as a comma-separated list of pass names (see
+// -print-passes); e.g.
+//
+//	l-opt -passes mem2reg,dce,simplifycfg -o out.ll in.ll
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/pass"
+	// Register transformation passes.
+	_ "github.com/llir/llvm/ir/transform"
+	"github.com/llir/llvm/ir/verify"
+	"github.com/pkg/errors"
+)
+
+func usage() {
+	const use = `
+Run pipelines of transformation passes over LLVM IR modules.
+
+Usage:
+
+	l-opt [OPTION]... FILE.ll
+
+Flags:
+`
+	fmt.Fprint(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	var (
+		// Pass pipeline.
+		passes = flag.String("passes", "", "comma-separated pass pipeline (e.g. mem2reg,dce)")
+		// Output file.
+		output = flag.String("o", "-", "output file")
+		// Verify the module after each pass.
+		verifyEach = flag.Bool("verify-each", false, "verify the module after each pass")
+		// Print the module after each pass.
+		printAfterAll = flag.Bool("print-after-all", false, "print the module to standard error after each pass")
+		// Print execution time of each pass.
+		timePasses = flag.Bool("time-passes", false, "print execution time of each pass")
+		// Print statistics.
+		stats = flag.Bool("stats", false, "print statistics of passes")
+		// Print registered passes.
+		printPasses = flag.Bool("print-passes", false, "print the names of the registered passes")
+		// Profiling.
+		cpuprofile = flag.String("cpuprofile", "", "write cpu profile to file")
+		memprofile = flag.String("memprofile", "", "write mem profile to file")
+	)
+	flag.Usage = usage
+	flag.Parse()
+	if *printPasses {
+		for _, name := range pass.Names() {
+			fmt.Println(name)
+		}
+		return
+	}
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	llPath := flag.Arg(0)
+	opts := options{
+		passes:        *passes,
+		output:        *output,
+		verifyEach:    *verifyEach,
+		printAfterAll: *printAfterAll,
+		timePasses:    *timePasses,
+		stats:         *stats,
+	}
+
+	if *cpuprofile != "" {
+		fd, err := os.Create(*cpuprofile)
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		pprof.StartCPUProfile(fd)
+		defer pprof.StopCPUProfile()
+	}
+	if *memprofile != "" {
+		runtime.MemProfileRate = 1
+	}
+
+	if err := opt(llPath, opts); err != nil {
+		log.Fatalf("%+v", err)
+	}
+
+	if *memprofile != "" {
+		fd, err := os.Create(*memprofile)
+		if err != nil {
+			log.Fatalf("%+v", err)
+		}
+		runtime.GC()
+		if err := pprof.WriteHeapProfile(fd); err != nil {
+			log.Fatalf("WriteHeapProfile: %v", err)
+		}
+	}
+}
+
+// options specifies the behaviour of l-opt.
+type options struct {
+	// Pass pipeline specification.
+	passes string
+	// Output file; or "-" for standard output.
+	output string
+	// Verify the module after each pass.
+	verifyEach bool
+	// Print the module to standard error after each pass.
+	printAfterAll bool
+	// Print execution time of each pass to standard error.
+	timePasses bool
+	// Print statistics of passes to standard error.
+	stats bool
+}
+
+// opt runs the pass pipeline over the given LLVM IR module, and writes the
+// transformed module to the output file.
+func opt(llPath string, opts options) error {
+	m, err := asm.ParseFile(llPath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	pm, err := pass.NewPipeline(opts.passes)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	timer := newPassTimer()
+	var start time.Time
+	pm.BeforePass = func(p pass.Pass, m *ir.Module) {
+		start = time.Now()
+	}
+	pm.AfterPass = func(p pass.Pass, m *ir.Module) error {
+		timer.add(p.Name(), time.Since(start))
+		if opts.printAfterAll {
+			fmt.Fprintf(os.Stderr, "; *** IR Dump After %s ***\n", p.Name())
+			if _, err := m.WriteTo(os.Stderr); err != nil {
+				return errors.WithStack(err)
+			}
+			fmt.Fprintln(os.Stderr)
+		}
+		if opts.verifyEach {
+			if err := verifyModule(m); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := pm.Run(m); err != nil {
+		return errors.WithStack(err)
+	}
+	if opts.timePasses {
+		timer.report(os.Stderr)
+	}
+	if opts.stats {
+		reportStats(os.Stderr)
+	}
+	// Verify the output module.
+	if err := verifyModule(m); err != nil {
+		return errors.WithStack(err)
+	}
+	return writeModule(m, opts.output)
+}
+
+// verifyModule verifies the given module, and returns an error listing the
+// verification errors if invalid.
+func verifyModule(m *ir.Module) error {
+	errs := verify.Module(m)
+	if len(errs) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return errors.Errorf("invalid module:\n%s", strings.Join(msgs, "\n"))
+}
+
+// writeModule writes the given module to the output file; or to standard
+// output if output is "-".
+func writeModule(m *ir.Module, output string) error {
+	if output == "-" {
+		w := bufio.NewWriter(os.Stdout)
+		if _, err := m.WriteTo(w); err != nil {
+			return errors.WithStack(err)
+		}
+		return errors.WithStack(w.Flush())
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	if _, err := m.WriteTo(w); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := w.Flush(); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(f.Close())
+}
+
+// --- [ Reports ] -------------------------------------------------------------
+
+// passTimer accumulates the execution time of passes.
+type passTimer struct {
+	// Pass names in order of first execution.
+	names []string
+	// Accumulated execution time of each pass.
+	times map[string]time.Duration
+}
+
+// newPassTimer returns a new pass timer.
+func newPassTimer() *passTimer {
+	return &passTimer{times: make(map[string]time.Duration)}
+}
+
+// add adds the given execution time of the named pass.
+func (t *passTimer) add(name string, d time.Duration) {
+	if _, ok := t.times[name]; !ok {
+		t.names = append(t.names, name)
+	}
+	t.times[name] += d
+}
+
+// report writes the pass execution timing report to w, in order of decreasing
+// execution time.
+func (t *passTimer) report(w io.Writer) {
+	var total time.Duration
+	for _, d := range t.times {
+		total += d
+	}
+	names := append([]string(nil), t.names...)
+	sort.SliceStable(names, func(i, j int) bool {
+		return t.times[names[i]] > t.times[names[j]]
+	})
+	writeHeader(w, "Pass execution timing report")
+	fmt.Fprintf(w, "  Total Execution Time: %.4f seconds\n\n", total.Seconds())
+	fmt.Fprintf(w, "   ---Wall Time---  --- Name ---\n")
+	for _, name := range names {
+		d := t.times[name]
+		fmt.Fprintf(w, "   %.4f (%5.1f%%)  %s\n", d.Seconds(), percent(d, total), name)
+	}
+	fmt.Fprintf(w, "   %.4f (100.0%%)  Total\n\n", total.Seconds())
+}
+
+// percent returns d as a percentage of total.
+func percent(d, total time.Duration) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(d) / float64(total)
+}
+
+// reportStats writes the statistics of passes to w.
+func reportStats(w io.Writer) {
+	writeHeader(w, "Statistics Collected")
+	for _, s := range pass.Statistics() {
+		fmt.Fprintf(w, "%5d %s - %s\n", s.Value(), s.Pass, s.Desc)
+	}
+	fmt.Fprintln(w)
+}
+
+// writeHeader writes a report header with the given title to w.
+func writeHeader(w io.Writer, title string) {
+	const line = "===-------------------------------------------------------------------------==="
+	title = "... " + title + " ..."
+	pad := (len(line) - len(title)) / 2
+	if pad < 0 {
+		pad = 0
+	}
+	fmt.Fprintln(w, line)
+	fmt.Fprintf(w, "%s%s\n", strings.Repeat(" ", pad), title)
+	fmt.Fprintln(w, line)
+}
@@ -0,0 +1,74 @@
+// The l-run tool runs LLVM IR modules using the interpreter.
+//
+// Usage:
+//
+//	l-run [OPTION]... FILE.ll [ARG]...
+//
+// The @main function of the module is called with the command line arguments
+// following FILE.ll, and the exit status code of the program is the return
+// value of @main. A subset of the C standard library (printf, puts, malloc,
+// free, memcpy, memset, strlen, ...) is provided by the interpreter.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/interp"
+	"github.com/pkg/errors"
+)
+
+func usage() {
+	const use = `
+Run LLVM IR modules using the interpreter.
+
+Usage:
+
+	l-run [OPTION]... FILE.ll [ARG]...
+
+Flags:
+`
+	fmt.Fprint(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	llPath := flag.Arg(0)
+	args := flag.Args()
+	code, err := run(llPath, args)
+	if err != nil {
+		log.Fatalf("%+v", err)
+	}
+	os.Exit(code)
+}
+
+// run runs the @main function of the given LLVM IR module with the given
+// command line arguments, and returns the exit status code of the program.
+func run(llPath string, args []string) (int, error) {
+	m, err := asm.ParseFile(llPath)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	in, err := interp.New(m)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	stdout := bufio.NewWriter(os.Stdout)
+	defer stdout.Flush()
+	in.AddLibc(stdout, os.Stderr)
+	code, err := in.RunMain(args)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return code, nil
+}
@@ -0,0 +1,697 @@
+// Package bitc defines the block IDs and record codes of LLVM IR bitcode files.
+//
+// References:
+//
+//	https://github.com/llvm/llvm-project/blob/main/llvm/include/llvm/Bitcode/LLVMBitCodes.h
+package bitc
+
+// Magic is the magic number of LLVM IR bitcode files ('BC' 0xC0DE).
+const Magic = "BC\xC0\xDE"
+
+// WrapperMagic is the magic number of the bitcode wrapper header, as stored in
+// little-endian byte order.
+const WrapperMagic = 0x0B17C0DE
+
+// === [ Block IDs ] ===========================================================
+
+// Block IDs.
+const (
+	ModuleBlockID                  = 8
+	ParamAttrBlockID               = 9
+	ParamAttrGroupBlockID          = 10
+	ConstantsBlockID               = 11
+	FunctionBlockID                = 12
+	IdentificationBlockID          = 13
+	ValueSymtabBlockID             = 14
+	MetadataBlockID                = 15
+	MetadataAttachmentBlockID      = 16
+	TypeBlockIDNew                 = 17
+	UseListBlockID                 = 18
+	ModuleStrtabBlockID            = 19
+	GlobalValSummaryBlockID        = 20
+	OperandBundleTagsBlockID       = 21
+	MetadataKindBlockID            = 22
+	StrtabBlockID                  = 23
+	FullLTOGlobalValSummaryBlockID = 24
+	SymtabBlockID                  = 25
+	SyncScopeNamesBlockID          = 26
+)
+
+// === [ Record codes ] ========================================================
+
+// IDENTIFICATION_BLOCK record codes.
+const (
+	// IDENTIFICATION: [strchr x N]
+	IdentificationCodeString = 1
+	// EPOCH: [epoch#]
+	IdentificationCodeEpoch = 2
+)
+
+// MODULE_BLOCK record codes.
+const (
+	// VERSION: [version#]
+	ModuleCodeVersion = 1
+	// TRIPLE: [strchr x N]
+	ModuleCodeTriple = 2
+	// DATALAYOUT: [strchr x N]
+	ModuleCodeDatalayout = 3
+	// ASM: [strchr x N]
+	ModuleCodeAsm = 4
+	// SECTIONNAME: [strchr x N]
+	ModuleCodeSectionName = 5
+	// DEPLIB: [strchr x N]
+	ModuleCodeDepLib = 6
+	// GLOBALVAR: [pointer type, isconst, initid, linkage, alignment, section,
+	// visibility, threadlocal, unnamed_addr, externally_initialized,
+	// dllstorageclass, comdat, attributes, DSO_Local]
+	ModuleCodeGlobalVar = 7
+	// FUNCTION: [type, callingconv, isproto, linkage, paramattrs, alignment,
+	// section, visibility, gc, unnamed_addr, prologuedata, dllstorageclass,
+	// comdat, prefixdata, personalityfn, DSO_Local, addrspace]
+	ModuleCodeFunction = 8
+	// ALIAS_OLD: [alias type, aliasee val#, linkage, visibility]
+	ModuleCodeAliasOld = 9
+	// GCNAME: [strchr x N]
+	ModuleCodeGCName = 11
+	// COMDAT: [selection_kind, name]
+	ModuleCodeComdat = 12
+	// VSTOFFSET: [offset]
+	ModuleCodeVSTOffset = 13
+	// ALIAS: [alias value type, addrspace, aliasee val#, linkage, visibility]
+	ModuleCodeAlias = 14
+	// METADATA_VALUES_UNUSED
+	ModuleCodeMetadataValuesUnused = 15
+	// SOURCE_FILENAME: [namechar x N]
+	ModuleCodeSourceFilename = 16
+	// HASH: [5*i32]
+	ModuleCodeHash = 17
+	// IFUNC: [ifunc value type, addrspace, resolver val#, linkage, visibility]
+	ModuleCodeIFunc = 18
+)
+
+// PARAMATTR_BLOCK and PARAMATTR_GROUP_BLOCK record codes.
+const (
+	// ENTRY: [paramidx0, attr0, paramidx1, attr1...]
+	ParamAttrCodeEntryOld = 1
+	// ENTRY: [attrgrp0, attrgrp1, ...]
+	ParamAttrCodeEntry = 2
+	// ENTRY: [grpid, idx, attr0, attr1, ...]
+	ParamAttrGrpCodeEntry = 3
+)
+
+// TYPE_BLOCK_ID_NEW record codes.
+const (
+	// NUMENTRY: [numentries]
+	TypeCodeNumEntry = 1
+	// VOID
+	TypeCodeVoid = 2
+	// FLOAT
+	TypeCodeFloat = 3
+	// DOUBLE
+	TypeCodeDouble = 4
+	// LABEL
+	TypeCodeLabel = 5
+	// OPAQUE
+	TypeCodeOpaque = 6
+	// INTEGER: [width]
+	TypeCodeInteger = 7
+	// POINTER: [pointee type, address space]
+	TypeCodePointer = 8
+	// FUNCTION_OLD: [vararg, attrid, retty, paramty x N]
+	TypeCodeFunctionOld = 9
+	// HALF
+	TypeCodeHalf = 10
+	// ARRAY: [numelts, eltty]
+	TypeCodeArray = 11
+	// VECTOR: [numelts, eltty, scalable]
+	TypeCodeVector = 12
+	// X86 LONG DOUBLE
+	TypeCodeX86FP80 = 13
+	// LONG DOUBLE (112 bit mantissa)
+	TypeCodeFP128 = 14
+	// PPC LONG DOUBLE (2 doubles)
+	TypeCodePPCFP128 = 15
+	// METADATA
+	TypeCodeMetadata = 16
+	// X86 MMX
+	TypeCodeX86MMX = 17
+	// STRUCT_ANON: [ispacked, eltty x N]
+	TypeCodeStructAnon = 18
+	// STRUCT_NAME: [strchr x N]
+	TypeCodeStructName = 19
+	// STRUCT_NAMED: [ispacked, eltty x N]
+	TypeCodeStructNamed = 20
+	// FUNCTION: [vararg, retty, paramty x N]
+	TypeCodeFunction = 21
+	// TOKEN
+	TypeCodeToken = 22
+	// BRAIN FLOATING POINT
+	TypeCodeBFloat = 23
+	// X86 AMX
+	TypeCodeX86AMX = 24
+	// OPAQUE_POINTER: [addrspace]
+	TypeCodeOpaquePointer = 25
+	// TARGET_TYPE
+	TypeCodeTargetType = 26
+)
+
+// VALUE_SYMTAB_BLOCK record codes.
+const (
+	// VST_ENTRY: [valueid, namechar x N]
+	VSTCodeEntry = 1
+	// VST_BBENTRY: [bbid, namechar x N]
+	VSTCodeBBEntry = 2
+	// VST_FNENTRY: [valueid, offset, namechar x N]
+	VSTCodeFnEntry = 3
+	// VST_COMBINED_ENTRY: [valueid, refguid]
+	VSTCodeCombinedEntry = 5
+)
+
+// METADATA_BLOCK record codes.
+const (
+	// MDSTRING_OLD: [values]
+	MetadataStringOld = 1
+	// VALUE: [type num, value num]
+	MetadataValue = 2
+	// NODE: [n x md num]
+	MetadataNode = 3
+	// STRING: [values]
+	MetadataName = 4
+	// DISTINCT_NODE: [n x md num]
+	MetadataDistinctNode = 5
+	// [n x [id, name]]
+	MetadataKind = 6
+	// [distinct, line, col, scope, inlined-at?, isImplicitCode]
+	MetadataLocation = 7
+	// OLD_NODE: [n x (type num, value num)]
+	MetadataOldNode = 8
+	// OLD_FN_NODE: [n x (type num, value num)]
+	MetadataOldFnNode = 9
+	// NAMED_NODE: [n x mdnodes]
+	MetadataNamedNode = 10
+	// [m x [value, [n x [id, mdnode]]]
+	MetadataAttachment = 11
+	// [distinct, tag, vers, header, n x md num]
+	MetadataGenericDebug = 12
+	// [distinct, count, lo]
+	MetadataSubrange = 13
+	// [isUnsigned|distinct, value, name]
+	MetadataEnumerator = 14
+	// [distinct, tag, name, size, align, enc]
+	MetadataBasicType = 15
+	// [distinct, filename, directory, checksumkind, checksum]
+	MetadataFile = 16
+	// [distinct, ...]
+	MetadataDerivedType = 17
+	// [distinct, ...]
+	MetadataCompositeType = 18
+	// [distinct, flags, types, cc]
+	MetadataSubroutineType = 19
+	// [distinct, ...]
+	MetadataCompileUnit = 20
+	// [distinct, ...]
+	MetadataSubprogram = 21
+	// [distinct, scope, file, line, column]
+	MetadataLexicalBlock = 22
+	// [distinct, scope, file, discriminator]
+	MetadataLexicalBlockFile = 23
+	// [distinct, scope, file, name, line, exportSymbols]
+	MetadataNamespace = 24
+	// [distinct, scope, name, type, ...]
+	MetadataTemplateType = 25
+	// [distinct, scope, name, type, value, ...]
+	MetadataTemplateValue = 26
+	// [distinct, ...]
+	MetadataGlobalVar = 27
+	// [distinct, ...]
+	MetadataLocalVar = 28
+	// [distinct, n x element]
+	MetadataExpression = 29
+	// [distinct, name, file, line, ...]
+	MetadataObjCProperty = 30
+	// [distinct, tag, scope, entity, line, name]
+	MetadataImportedEntity = 31
+	// [distinct, scope, name, ...]
+	MetadataModule = 32
+	// [distinct, macinfo, line, name, value]
+	MetadataMacro = 33
+	// [distinct, macinfo, line, file, ...]
+	MetadataMacroFile = 34
+	// [count, offset] blob([lengths][chars])
+	MetadataStrings = 35
+	// [valueid, n x [id, mdnode]]
+	MetadataGlobalDeclAttachment = 36
+	// [distinct, var, expr]
+	MetadataGlobalVarExpr = 37
+	// [offset]
+	MetadataIndexOffset = 38
+	// [bitpos]
+	MetadataIndex = 39
+	// [distinct, scope, name, file, line]
+	MetadataLabel = 40
+	// [distinct, name, size, align,...]
+	MetadataStringType = 41
+	// [distinct, scope, name, file, line]
+	MetadataCommonBlock = 44
+	// [distinct, count, lo, up, stride]
+	MetadataGenericSubrange = 45
+	// [n x [type num, value num]]
+	MetadataArgList = 46
+	// [distinct, ...]
+	MetadataAssignID = 47
+)
+
+// METADATA_KIND_BLOCK record codes.
+const (
+	// [n x [id, name]]
+	MetadataKindKind = 6
+)
+
+// OPERAND_BUNDLE_TAGS_BLOCK record codes.
+const (
+	// TAG: [strchr x N]
+	OperandBundleTagCode = 1
+)
+
+// SYNC_SCOPE_NAMES_BLOCK record codes.
+const (
+	// NAME: [strchr x N]
+	SyncScopeName = 1
+)
+
+// STRTAB_BLOCK record codes.
+const (
+	// BLOB: [blob]
+	StrtabBlob = 1
+)
+
+// CONSTANTS_BLOCK record codes.
+const (
+	// SETTYPE: [typeid]
+	CstCodeSetType = 1
+	// NULL
+	CstCodeNull = 2
+	// UNDEF
+	CstCodeUndef = 3
+	// INTEGER: [intval]
+	CstCodeInteger = 4
+	// WIDE_INTEGER: [n x intval]
+	CstCodeWideInteger = 5
+	// FLOAT: [fpval]
+	CstCodeFloat = 6
+	// AGGREGATE: [n x value number]
+	CstCodeAggregate = 7
+	// STRING: [values]
+	CstCodeString = 8
+	// CSTRING: [values]
+	CstCodeCString = 9
+	// CE_BINOP: [opcode, opval, opval]
+	CstCodeCEBinop = 10
+	// CE_CAST: [opcode, opty, opval]
+	CstCodeCECast = 11
+	// CE_GEP: [n x operands]
+	CstCodeCEGEP = 12
+	// CE_SELECT: [opval, opval, opval]
+	CstCodeCESelect = 13
+	// CE_EXTRACTELT: [opty, opval, opty, opval]
+	CstCodeCEExtractElt = 14
+	// CE_INSERTELT: [opval, opval, opty, opval]
+	CstCodeCEInsertElt = 15
+	// CE_SHUFFLEVEC: [opval, opval, opval]
+	CstCodeCEShuffleVec = 16
+	// CE_CMP: [opty, opval, opval, pred]
+	CstCodeCECmp = 17
+	// INLINEASM: [sideeffect|alignstack, asmstr, conststr]
+	CstCodeInlineAsmOld = 18
+	// SHUFVEC_EX: [opty, opval, opval, opval]
+	CstCodeCEShufVecEx = 19
+	// INBOUNDS_GEP: [n x operands]
+	CstCodeCEInboundsGEP = 20
+	// BLOCKADDRESS: [fnty, fnval, bb#]
+	CstCodeBlockAddress = 21
+	// DATA: [n x elements]
+	CstCodeData = 22
+	// INLINEASM: [sideeffect|alignstack|asmdialect, asmstr, conststr]
+	CstCodeInlineAsmOld2 = 23
+	// [opty, flags, n x operands]
+	CstCodeCEGEPWithInrangeIndex = 24
+	// CE_UNOP: [opcode, opval]
+	CstCodeCEUnop = 25
+	// POISON
+	CstCodePoison = 26
+	// DSO_LOCAL_EQUIVALENT: [gvty, gv]
+	CstCodeDSOLocalEquivalent = 27
+	// INLINEASM: [sideeffect|alignstack|asmdialect|unwind, asmstr, conststr]
+	CstCodeInlineAsmOld3 = 28
+	// NO_CFI: [fty, f]
+	CstCodeNoCFIValue = 29
+	// INLINEASM: [fnty, sideeffect|alignstack|asmdialect|unwind, asmstr,
+	// conststr]
+	CstCodeInlineAsm = 30
+)
+
+// FUNCTION_BLOCK record codes.
+const (
+	// DECLAREBLOCKS: [n]
+	FuncCodeDeclareBlocks = 1
+	// BINOP: [opcode, ty, opval, opval]
+	FuncCodeInstBinop = 2
+	// CAST: [opcode, ty, opty, opval]
+	FuncCodeInstCast = 3
+	// GEP: [n x operands]
+	FuncCodeInstGEPOld = 4
+	// SELECT: [ty, opval, opval, opval]
+	FuncCodeInstSelect = 5
+	// EXTRACTELT: [opty, opval, opval]
+	FuncCodeInstExtractElt = 6
+	// INSERTELT: [ty, opval, opval, opval]
+	FuncCodeInstInsertElt = 7
+	// SHUFFLEVEC: [ty, opval, opval, opval]
+	FuncCodeInstShuffleVec = 8
+	// CMP: [opty, opval, opval, pred]
+	FuncCodeInstCmp = 9
+	// RET: [opty, opval<both optional>]
+	FuncCodeInstRet = 10
+	// BR: [bb#, bb#, cond] or [bb#]
+	FuncCodeInstBr = 11
+	// SWITCH: [opty, op0, op1, ...]
+	FuncCodeInstSwitch = 12
+	// INVOKE: [attr, fnty, op0, op1, ...]
+	FuncCodeInstInvoke = 13
+	// UNREACHABLE
+	FuncCodeInstUnreachable = 15
+	// PHI: [ty, val0, bb0, ...]
+	FuncCodeInstPhi = 16
+	// ALLOCA: [instty, opty, op, align]
+	FuncCodeInstAlloca = 19
+	// LOAD: [opty, op, align, vol]
+	FuncCodeInstLoad = 20
+	// VAARG: [valistty, valist, instty]
+	FuncCodeInstVAArg = 23
+	// STORE: [ptrty, ptr, val, align, vol]
+	FuncCodeInstStoreOld = 24
+	// EXTRACTVAL: [n x operands]
+	FuncCodeInstExtractVal = 26
+	// INSERTVAL: [n x operands]
+	FuncCodeInstInsertVal = 27
+	// CMP2: [opty, opval, opval, pred]
+	FuncCodeInstCmp2 = 28
+	// VSELECT: [ty, opval, opval, predty, pred]
+	FuncCodeInstVSelect = 29
+	// INBOUNDS_GEP: [n x operands]
+	FuncCodeInstInboundsGEPOld = 30
+	// INDIRECTBR: [opty, op0, op1, ...]
+	FuncCodeInstIndirectBr = 31
+	// DEBUG_LOC_AGAIN
+	FuncCodeDebugLocAgain = 33
+	// CALL: [attr, cc, fnty, fnid, args...]
+	FuncCodeInstCall = 34
+	// DEBUG_LOC: [Line, Col, ScopeVal, IAVal]
+	FuncCodeDebugLoc = 35
+	// FENCE: [ordering, synchscope]
+	FuncCodeInstFence = 36
+	// CMPXCHG: [ptrty, ptr, cmp, val, vol, ordering, synchscope,
+	// failure_ordering?, weak?]
+	FuncCodeInstCmpXchgOld = 37
+	// ATOMICRMW: [ptrty, ptr, val, operation, align, vol, ordering,
+	// synchscope]
+	FuncCodeInstAtomicRMWOld = 38
+	// RESUME: [opval]
+	FuncCodeInstResume = 39
+	// LANDINGPAD: [ty, val, val, num, id0, val0...]
+	FuncCodeInstLandingPadOld = 40
+	// LOAD: [opty, op, align, vol, ordering, synchscope]
+	FuncCodeInstLoadAtomic = 41
+	// STORE: [ptrty, ptr, val, align, vol, ordering, synchscope]
+	FuncCodeInstStoreAtomicOld = 42
+	// GEP: [inbounds, n x operands]
+	FuncCodeInstGEP = 43
+	// STORE: [ptrty, ptr, valty, val, align, vol]
+	FuncCodeInstStore = 44
+	// STORE: [ptrty, ptr, val, align, vol]
+	FuncCodeInstStoreAtomic = 45
+	// CMPXCHG: [ptrty, ptr, cmp, val, vol, success_ordering, synchscope,
+	// failure_ordering, weak]
+	FuncCodeInstCmpXchg = 46
+	// LANDINGPAD: [ty, val, num, id0, val0...]
+	FuncCodeInstLandingPad = 47
+	// CLEANUPRET: [val] or [val, bb#]
+	FuncCodeInstCleanupRet = 48
+	// CATCHRET: [val, bb#]
+	FuncCodeInstCatchRet = 49
+	// CATCHPAD: [bb#, bb#, num, args...]
+	FuncCodeInstCatchPad = 50
+	// CLEANUPPAD: [num, args...]
+	FuncCodeInstCleanupPad = 51
+	// CATCHSWITCH: [num, args...] or [num, args..., bb]
+	FuncCodeInstCatchSwitch = 52
+	// OPERAND_BUNDLE: [tag#, value...]
+	FuncCodeOperandBundle = 55
+	// UNOP: [opcode, ty, opval]
+	FuncCodeInstUnop = 56
+	// CALLBR: [attr, cc, norm, transfs, fnty, fnid, args]
+	FuncCodeInstCallBr = 57
+	// FREEZE: [opty, opval]
+	FuncCodeInstFreeze = 58
+	// ATOMICRMW: [ptrty, ptr, valty, val, operation, align, vol, ordering,
+	// synchscope]
+	FuncCodeInstAtomicRMW = 59
+	// BLOCKADDR_USERS: [value...]
+	FuncCodeBlockAddrUsers = 60
+)
+
+// USELIST_BLOCK record codes.
+const (
+	// DEFAULT: [index..., value-id]
+	UseListCodeDefault = 1
+	// BB: [index..., bb-id]
+	UseListCodeBB = 2
+)
+
+// === [ Operation codes ] =====================================================
+
+// Cast operation codes.
+const (
+	CastTrunc         = 0
+	CastZExt          = 1
+	CastSExt          = 2
+	CastFPToUI        = 3
+	CastFPToSI        = 4
+	CastUIToFP        = 5
+	CastSIToFP        = 6
+	CastFPTrunc       = 7
+	CastFPExt         = 8
+	CastPtrToInt      = 9
+	CastIntToPtr      = 10
+	CastBitCast       = 11
+	CastAddrSpaceCast = 12
+)
+
+// Unary operation codes.
+const (
+	UnopFNeg = 0
+)
+
+// Binary operation codes; floating-point operations share the codes of the
+// corresponding integer operations.
+const (
+	BinopAdd  = 0
+	BinopSub  = 1
+	BinopMul  = 2
+	BinopUDiv = 3
+	// SDIV and FDIV.
+	BinopSDiv = 4
+	BinopURem = 5
+	// SREM and FREM.
+	BinopSRem = 6
+	BinopShl  = 7
+	BinopLShr = 8
+	BinopAShr = 9
+	BinopAnd  = 10
+	BinopOr   = 11
+	BinopXor  = 12
+)
+
+// Overflowing binary operation flags.
+const (
+	OBONoUnsignedWrap = 0
+	OBONoSignedWrap   = 1
+)
+
+// Possibly exact operation flags.
+const (
+	PEOExact = 0
+)
+
+// Atomic read-modify-write operation codes.
+const (
+	RMWXchg = 0
+	RMWAdd  = 1
+	RMWSub  = 2
+	RMWAnd  = 3
+	RMWNand = 4
+	RMWOr   = 5
+	RMWXor  = 6
+	RMWMax  = 7
+	RMWMin  = 8
+	RMWUMax = 9
+	RMWUMin = 10
+	RMWFAdd = 11
+	RMWFSub = 12
+	RMWFMax = 13
+	RMWFMin = 14
+)
+
+// Atomic orderings.
+const (
+	OrderingNotAtomic = 0
+	OrderingUnordered = 1
+	OrderingMonotonic = 2
+	OrderingAcquire   = 3
+	OrderingRelease   = 4
+	OrderingAcqRel    = 5
+	OrderingSeqCst    = 6
+)
+
+// Call instruction flags (CALL record).
+const (
+	CallTail         = 0
+	CallCConv        = 1
+	CallMustTail     = 14
+	CallExplicitType = 15
+	CallNoTail       = 16
+	CallFMF          = 17
+)
+
+// Fast-math flags.
+const (
+	FMFUnsafeAlgebra   = 1 << 0
+	FMFNoNaNs          = 1 << 1
+	FMFNoInfs          = 1 << 2
+	FMFNoSignedZeros   = 1 << 3
+	FMFAllowReciprocal = 1 << 4
+	FMFAllowContract   = 1 << 5
+	FMFApproxFunc      = 1 << 6
+	FMFAllowReassoc    = 1 << 7
+)
+
+// Comdat selection kinds.
+const (
+	ComdatSelectionKindAny          = 1
+	ComdatSelectionKindExactMatch   = 2
+	ComdatSelectionKindLargest      = 3
+	ComdatSelectionKindNoDuplicates = 4
+	ComdatSelectionKindSameSize     = 5
+)
+
+// Attribute group entry kinds (PARAMATTR_GRP_CODE_ENTRY).
+const (
+	// Enum attribute: [0, kind]
+	AttrEntryEnum = 0
+	// Integer attribute: [1, kind, value]
+	AttrEntryInt = 1
+	// String attribute: [3, key..., 0]
+	AttrEntryString = 3
+	// Key-value string attribute: [4, key..., 0, value..., 0]
+	AttrEntryKeyValue = 4
+	// Type attribute without type: [5, kind]
+	AttrEntryType = 5
+	// Type attribute with type: [6, kind, typeid]
+	AttrEntryTypeWithType = 6
+)
+
+// Attribute group parameter indices (PARAMATTR_GRP_CODE_ENTRY); parameters are
+// indexed from AttrIndexFirstParam.
+const (
+	AttrIndexReturn     = 0
+	AttrIndexFirstParam = 1
+	AttrIndexFunction   = 0xFFFFFFFF
+)
+
+// Attribute kinds.
+const (
+	AttrKindAlignment                       = 1
+	AttrKindAlwaysInline                    = 2
+	AttrKindByVal                           = 3
+	AttrKindInlineHint                      = 4
+	AttrKindInReg                           = 5
+	AttrKindMinSize                         = 6
+	AttrKindNaked                           = 7
+	AttrKindNest                            = 8
+	AttrKindNoAlias                         = 9
+	AttrKindNoBuiltin                       = 10
+	AttrKindNoCapture                       = 11
+	AttrKindNoDuplicate                     = 12
+	AttrKindNoImplicitFloat                 = 13
+	AttrKindNoInline                        = 14
+	AttrKindNonLazyBind                     = 15
+	AttrKindNoRedZone                       = 16
+	AttrKindNoReturn                        = 17
+	AttrKindNoUnwind                        = 18
+	AttrKindOptimizeForSize                 = 19
+	AttrKindReadNone                        = 20
+	AttrKindReadOnly                        = 21
+	AttrKindReturned                        = 22
+	AttrKindReturnsTwice                    = 23
+	AttrKindSExt                            = 24
+	AttrKindStackAlignment                  = 25
+	AttrKindStackProtect                    = 26
+	AttrKindStackProtectReq                 = 27
+	AttrKindStackProtectStrong              = 28
+	AttrKindStructRet                       = 29
+	AttrKindSanitizeAddress                 = 30
+	AttrKindSanitizeThread                  = 31
+	AttrKindSanitizeMemory                  = 32
+	AttrKindUWTable                         = 33
+	AttrKindZExt                            = 34
+	AttrKindBuiltin                         = 35
+	AttrKindCold                            = 36
+	AttrKindOptimizeNone                    = 37
+	AttrKindInAlloca                        = 38
+	AttrKindNonNull                         = 39
+	AttrKindJumpTable                       = 40
+	AttrKindDereferenceable                 = 41
+	AttrKindDereferenceableOrNull           = 42
+	AttrKindConvergent                      = 43
+	AttrKindSafeStack                       = 44
+	AttrKindArgMemOnly                      = 45
+	AttrKindSwiftSelf                       = 46
+	AttrKindSwiftError                      = 47
+	AttrKindNoRecurse                       = 48
+	AttrKindInaccessibleMemOnly             = 49
+	AttrKindInaccessibleMemOrArgMemOnly     = 50
+	AttrKindAllocSize                       = 51
+	AttrKindWriteOnly                       = 52
+	AttrKindSpeculatable                    = 53
+	AttrKindStrictFP                        = 54
+	AttrKindSanitizeHWAddress               = 55
+	AttrKindNoCFCheck                       = 56
+	AttrKindOptForFuzzing                   = 57
+	AttrKindShadowCallStack                 = 58
+	AttrKindSpeculativeLoadHardening        = 59
+	AttrKindImmArg                          = 60
+	AttrKindWillReturn                      = 61
+	AttrKindNoFree                          = 62
+	AttrKindNoSync                          = 63
+	AttrKindSanitizeMemTag                  = 64
+	AttrKindPreallocated                    = 65
+	AttrKindNoMerge                         = 66
+	AttrKindNullPointerIsValid              = 67
+	AttrKindNoUndef                         = 68
+	AttrKindByRef                           = 69
+	AttrKindMustProgress                    = 70
+	AttrKindNoCallback                      = 71
+	AttrKindHot                             = 72
+	AttrKindNoProfile                       = 73
+	AttrKindVScaleRange                     = 74
+	AttrKindSwiftAsync                      = 75
+	AttrKindNoSanitizeCoverage              = 76
+	AttrKindElementType                     = 77
+	AttrKindDisableSanitizerInstrumentation = 78
+	AttrKindNoSanitizeBounds                = 79
+	AttrKindAllocAlign                      = 80
+	AttrKindAllocatedPointer                = 81
+	AttrKindAllocKind                       = 82
+	AttrKindPresplitCoroutine               = 83
+	AttrKindFnRetThunkExtern                = 84
+)
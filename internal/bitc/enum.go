@@ -0,0 +1,102 @@
+package bitc
+
+import "github.com/llir/llvm/ir/enum"
+
+// FuncAttrKinds maps from enum function attributes to attribute kinds.
+var FuncAttrKinds = map[enum.FuncAttr]uint64{
+	enum.FuncAttrAlwaysInline:                    AttrKindAlwaysInline,
+	enum.FuncAttrArgMemOnly:                      AttrKindArgMemOnly,
+	enum.FuncAttrBuiltin:                         AttrKindBuiltin,
+	enum.FuncAttrCold:                            AttrKindCold,
+	enum.FuncAttrConvergent:                      AttrKindConvergent,
+	enum.FuncAttrDisableSanitizerInstrumentation: AttrKindDisableSanitizerInstrumentation,
+	enum.FuncAttrFnRetThunkExtern:                AttrKindFnRetThunkExtern,
+	enum.FuncAttrHot:                             AttrKindHot,
+	enum.FuncAttrInaccessibleMemOnly:             AttrKindInaccessibleMemOnly,
+	enum.FuncAttrInaccessibleMemOrArgMemOnly:     AttrKindInaccessibleMemOrArgMemOnly,
+	enum.FuncAttrInlineHint:                      AttrKindInlineHint,
+	enum.FuncAttrJumpTable:                       AttrKindJumpTable,
+	enum.FuncAttrMinSize:                         AttrKindMinSize,
+	enum.FuncAttrMustProgress:                    AttrKindMustProgress,
+	enum.FuncAttrNaked:                           AttrKindNaked,
+	enum.FuncAttrNoBuiltin:                       AttrKindNoBuiltin,
+	enum.FuncAttrNoCFCheck:                       AttrKindNoCFCheck,
+	enum.FuncAttrNoCallback:                      AttrKindNoCallback,
+	enum.FuncAttrNoDuplicate:                     AttrKindNoDuplicate,
+	enum.FuncAttrNoFree:                          AttrKindNoFree,
+	enum.FuncAttrNoImplicitFloat:                 AttrKindNoImplicitFloat,
+	enum.FuncAttrNoInline:                        AttrKindNoInline,
+	enum.FuncAttrNoMerge:                         AttrKindNoMerge,
+	enum.FuncAttrNoProfile:                       AttrKindNoProfile,
+	enum.FuncAttrNoRecurse:                       AttrKindNoRecurse,
+	enum.FuncAttrNoRedZone:                       AttrKindNoRedZone,
+	enum.FuncAttrNoReturn:                        AttrKindNoReturn,
+	enum.FuncAttrNoSanitizeBounds:                AttrKindNoSanitizeBounds,
+	enum.FuncAttrNoSanitizeCoverage:              AttrKindNoSanitizeCoverage,
+	enum.FuncAttrNoSync:                          AttrKindNoSync,
+	enum.FuncAttrNoUnwind:                        AttrKindNoUnwind,
+	enum.FuncAttrNonLazyBind:                     AttrKindNonLazyBind,
+	enum.FuncAttrNullPointerIsValid:              AttrKindNullPointerIsValid,
+	enum.FuncAttrOptForFuzzing:                   AttrKindOptForFuzzing,
+	enum.FuncAttrOptNone:                         AttrKindOptimizeNone,
+	enum.FuncAttrOptSize:                         AttrKindOptimizeForSize,
+	enum.FuncAttrPreSplitCoroutine:               AttrKindPresplitCoroutine,
+	enum.FuncAttrReadNone:                        AttrKindReadNone,
+	enum.FuncAttrReadOnly:                        AttrKindReadOnly,
+	enum.FuncAttrReturnsTwice:                    AttrKindReturnsTwice,
+	enum.FuncAttrSSP:                             AttrKindStackProtect,
+	enum.FuncAttrSSPReq:                          AttrKindStackProtectReq,
+	enum.FuncAttrSSPStrong:                       AttrKindStackProtectStrong,
+	enum.FuncAttrSafeStack:                       AttrKindSafeStack,
+	enum.FuncAttrSanitizeAddress:                 AttrKindSanitizeAddress,
+	enum.FuncAttrSanitizeHWAddress:               AttrKindSanitizeHWAddress,
+	enum.FuncAttrSanitizeMemTag:                  AttrKindSanitizeMemTag,
+	enum.FuncAttrSanitizeMemory:                  AttrKindSanitizeMemory,
+	enum.FuncAttrSanitizeThread:                  AttrKindSanitizeThread,
+	enum.FuncAttrShadowCallStack:                 AttrKindShadowCallStack,
+	enum.FuncAttrSpeculatable:                    AttrKindSpeculatable,
+	enum.FuncAttrSpeculativeLoadHardening:        AttrKindSpeculativeLoadHardening,
+	enum.FuncAttrStrictFP:                        AttrKindStrictFP,
+	enum.FuncAttrUwtable:                         AttrKindUWTable,
+	enum.FuncAttrWillReturn:                      AttrKindWillReturn,
+	enum.FuncAttrWriteOnly:                       AttrKindWriteOnly,
+}
+
+// ParamAttrKinds maps from enum parameter attributes to attribute kinds.
+var ParamAttrKinds = map[enum.ParamAttr]uint64{
+	enum.ParamAttrAllocAlign: AttrKindAllocAlign,
+	enum.ParamAttrAllocPtr:   AttrKindAllocatedPointer,
+	enum.ParamAttrImmArg:     AttrKindImmArg,
+	enum.ParamAttrInReg:      AttrKindInReg,
+	enum.ParamAttrNest:       AttrKindNest,
+	enum.ParamAttrNoAlias:    AttrKindNoAlias,
+	enum.ParamAttrNoCapture:  AttrKindNoCapture,
+	enum.ParamAttrNoFree:     AttrKindNoFree,
+	enum.ParamAttrNonNull:    AttrKindNonNull,
+	enum.ParamAttrNoUndef:    AttrKindNoUndef,
+	enum.ParamAttrReadNone:   AttrKindReadNone,
+	enum.ParamAttrReadOnly:   AttrKindReadOnly,
+	enum.ParamAttrReturned:   AttrKindReturned,
+	enum.ParamAttrSignExt:    AttrKindSExt,
+	enum.ParamAttrSwiftAsync: AttrKindSwiftAsync,
+	enum.ParamAttrSwiftError: AttrKindSwiftError,
+	enum.ParamAttrSwiftSelf:  AttrKindSwiftSelf,
+	enum.ParamAttrWriteOnly:  AttrKindWriteOnly,
+	enum.ParamAttrZeroExt:    AttrKindZExt,
+}
+
+// ReturnAttrKinds maps from enum return attributes to attribute kinds.
+var ReturnAttrKinds = map[enum.ReturnAttr]uint64{
+	enum.ReturnAttrInReg:   AttrKindInReg,
+	enum.ReturnAttrNoAlias: AttrKindNoAlias,
+	enum.ReturnAttrNonNull: AttrKindNonNull,
+	enum.ReturnAttrNoUndef: AttrKindNoUndef,
+	enum.ReturnAttrSignExt: AttrKindSExt,
+	enum.ReturnAttrZeroExt: AttrKindZExt,
+}
+
+// IsLocalLinkage reports whether the given linkage is local to the module.
+// Global values with local linkage are implicitly dso_local.
+func IsLocalLinkage(linkage enum.Linkage) bool {
+	return linkage == enum.LinkageInternal || linkage == enum.LinkagePrivate
+}
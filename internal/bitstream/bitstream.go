@@ -0,0 +1,131 @@
+// Package bitstream implements a reader and writer of the LLVM bitstream
+// container format, as used by LLVM IR bitcode files.
+//
+// References:
+//
+//	https://llvm.org/docs/BitCodeFormat.html
+package bitstream
+
+// Builtin abbreviation IDs.
+const (
+	// END_BLOCK: align32
+	AbbrevIDEndBlock = 0
+	// ENTER_SUBBLOCK: [blockid vbr8, newabbrevlen vbr4, align32, blocklen32]
+	AbbrevIDEnterSubblock = 1
+	// DEFINE_ABBREV: [numabbrevops vbr5, abbrevop...]
+	AbbrevIDDefineAbbrev = 2
+	// UNABBREV_RECORD: [code vbr6, numops vbr6, op vbr6...]
+	AbbrevIDUnabbrevRecord = 3
+	// First application defined abbreviation ID.
+	AbbrevIDFirstApplication = 4
+)
+
+// BlockInfoBlockID is the block ID of the standard BLOCKINFO block.
+const BlockInfoBlockID = 0
+
+// Record codes of the BLOCKINFO block.
+const (
+	// SETBID: [blockid]
+	BlockInfoCodeSetBID = 1
+	// BLOCKNAME: [name...]
+	BlockInfoCodeBlockName = 2
+	// SETRECORDNAME: [id, name...]
+	BlockInfoCodeSetRecordName = 3
+)
+
+// Width of abbreviation IDs at the top level of a bitstream.
+const topLevelAbbrevWidth = 2
+
+// --- [ Abbreviations ] -------------------------------------------------------
+
+// Encoding is the encoding of an abbreviation operand.
+type Encoding uint8
+
+// Abbreviation operand encodings.
+const (
+	// Literal value.
+	EncodingLiteral Encoding = 0
+	// Fixed-width value.
+	EncodingFixed Encoding = 1
+	// Variable-width value.
+	EncodingVBR Encoding = 2
+	// Array of values, encoded using the following operand.
+	EncodingArray Encoding = 3
+	// 6-bit character.
+	EncodingChar6 Encoding = 4
+	// Blob of bytes.
+	EncodingBlob Encoding = 5
+)
+
+// AbbrevOp is an abbreviation operand.
+type AbbrevOp struct {
+	// Operand encoding.
+	Encoding Encoding
+	// Literal value (EncodingLiteral) or bit width (EncodingFixed and
+	// EncodingVBR).
+	Value uint64
+}
+
+// Literal returns a literal abbreviation operand.
+func Literal(v uint64) AbbrevOp {
+	return AbbrevOp{Encoding: EncodingLiteral, Value: v}
+}
+
+// Fixed returns a fixed-width abbreviation operand of the given bit width.
+func Fixed(width uint64) AbbrevOp {
+	return AbbrevOp{Encoding: EncodingFixed, Value: width}
+}
+
+// VBR returns a variable-width abbreviation operand of the given chunk width.
+func VBR(width uint64) AbbrevOp {
+	return AbbrevOp{Encoding: EncodingVBR, Value: width}
+}
+
+// Array returns an array abbreviation operand; the element encoding is given
+// by the following operand.
+func Array() AbbrevOp {
+	return AbbrevOp{Encoding: EncodingArray}
+}
+
+// Char6 returns a 6-bit character abbreviation operand.
+func Char6() AbbrevOp {
+	return AbbrevOp{Encoding: EncodingChar6}
+}
+
+// Blob returns a blob abbreviation operand.
+func Blob() AbbrevOp {
+	return AbbrevOp{Encoding: EncodingBlob}
+}
+
+// Abbrev is an abbreviation, which specifies the encoding of records.
+type Abbrev struct {
+	// Abbreviation operands; the first operand encodes the record code.
+	Ops []AbbrevOp
+}
+
+// NewAbbrev returns a new abbreviation based on the given operands.
+func NewAbbrev(ops ...AbbrevOp) *Abbrev {
+	return &Abbrev{Ops: ops}
+}
+
+// --- [ Records ] -------------------------------------------------------------
+
+// Record is a data record of a bitstream block.
+type Record struct {
+	// Record code.
+	Code uint64
+	// Record operands.
+	Ops []uint64
+	// (optional) Blob data; nil if not present.
+	Blob []byte
+}
+
+// ### [ Helper functions ] ####################################################
+
+// char6Table maps from 6-bit characters to ASCII characters.
+const char6Table = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789._"
+
+// decodeChar6 decodes the given 6-bit character.
+func decodeChar6(v uint64) uint64 {
+	return uint64(char6Table[v&0x3F])
+}
@@ -0,0 +1,394 @@
+package bitstream
+
+import (
+	"github.com/pkg/errors"
+)
+
+// EntryKind specifies the kind of a bitstream entry.
+type EntryKind uint8
+
+// Bitstream entry kinds.
+const (
+	// End of current block.
+	EntryEndBlock EntryKind = iota + 1
+	// Start of sub-block; the caller must invoke either EnterBlock or SkipBlock.
+	EntrySubBlock
+	// Data record.
+	EntryRecord
+)
+
+// Entry is a bitstream entry.
+type Entry struct {
+	// Entry kind.
+	Kind EntryKind
+	// Block ID of sub-block (EntrySubBlock).
+	BlockID uint64
+	// Data record (EntryRecord).
+	Record *Record
+}
+
+// Reader is a reader of the LLVM bitstream container format.
+type Reader struct {
+	// Bitstream contents.
+	buf []byte
+	// Current bit position.
+	pos uint64
+	// Width of abbreviation IDs in the current block.
+	abbrevWidth uint64
+	// Abbreviations of the current block.
+	abbrevs []*Abbrev
+	// Stack of enclosing blocks.
+	blocks []blockScope
+	// Abbreviations defined in the BLOCKINFO block, indexed by block ID.
+	blockInfo map[uint64][]*Abbrev
+	// Width of abbreviation IDs of the pending sub-block.
+	subAbbrevWidth uint64
+	// Block ID of the pending sub-block.
+	subBlockID uint64
+}
+
+// blockScope records the state of an enclosing block.
+type blockScope struct {
+	// Width of abbreviation IDs in the block.
+	abbrevWidth uint64
+	// Abbreviations of the block.
+	abbrevs []*Abbrev
+}
+
+// NewReader returns a new bitstream reader reading from the given buffer,
+// which contains the bitstream after the magic number.
+func NewReader(buf []byte) *Reader {
+	return &Reader{
+		buf:         buf,
+		abbrevWidth: topLevelAbbrevWidth,
+		blockInfo:   make(map[uint64][]*Abbrev),
+	}
+}
+
+// AtEnd reports whether the end of the bitstream has been reached.
+func (r *Reader) AtEnd() bool {
+	// Note, the bitstream is padded to a multiple of 32 bits.
+	return r.pos+32 > uint64(len(r.buf))*8
+}
+
+// Next returns the next entry of the current block. BLOCKINFO blocks are
+// handled transparently by the reader.
+func (r *Reader) Next() (Entry, error) {
+	for {
+		abbrevID, err := r.ReadFixed(r.abbrevWidth)
+		if err != nil {
+			return Entry{}, errors.WithStack(err)
+		}
+		switch abbrevID {
+		case AbbrevIDEndBlock:
+			if err := r.endBlock(); err != nil {
+				return Entry{}, errors.WithStack(err)
+			}
+			return Entry{Kind: EntryEndBlock}, nil
+		case AbbrevIDEnterSubblock:
+			blockID, err := r.ReadVBR(8)
+			if err != nil {
+				return Entry{}, errors.WithStack(err)
+			}
+			r.subAbbrevWidth, err = r.ReadVBR(4)
+			if err != nil {
+				return Entry{}, errors.WithStack(err)
+			}
+			r.subBlockID = blockID
+			if blockID == BlockInfoBlockID {
+				if err := r.readBlockInfo(); err != nil {
+					return Entry{}, errors.WithStack(err)
+				}
+				continue
+			}
+			return Entry{Kind: EntrySubBlock, BlockID: blockID}, nil
+		case AbbrevIDDefineAbbrev:
+			abbrev, err := r.readAbbrev()
+			if err != nil {
+				return Entry{}, errors.WithStack(err)
+			}
+			r.abbrevs = append(r.abbrevs, abbrev)
+		default:
+			record, err := r.readRecord(abbrevID)
+			if err != nil {
+				return Entry{}, errors.WithStack(err)
+			}
+			return Entry{Kind: EntryRecord, Record: record}, nil
+		}
+	}
+}
+
+// EnterBlock enters the sub-block returned by the preceding invocation of Next.
+func (r *Reader) EnterBlock() error {
+	r.align32()
+	// Block length in 32-bit words.
+	if _, err := r.ReadFixed(32); err != nil {
+		return errors.WithStack(err)
+	}
+	r.blocks = append(r.blocks, blockScope{abbrevWidth: r.abbrevWidth, abbrevs: r.abbrevs})
+	r.abbrevWidth = r.subAbbrevWidth
+	r.abbrevs = append([]*Abbrev(nil), r.blockInfo[r.subBlockID]...)
+	return nil
+}
+
+// SkipBlock skips the sub-block returned by the preceding invocation of Next.
+func (r *Reader) SkipBlock() error {
+	r.align32()
+	// Block length in 32-bit words.
+	n, err := r.ReadFixed(32)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	end := r.pos + n*32
+	if end > uint64(len(r.buf))*8 {
+		return errors.Errorf("invalid block length %d; exceeds end of bitstream", n)
+	}
+	r.pos = end
+	return nil
+}
+
+// endBlock leaves the current block.
+func (r *Reader) endBlock() error {
+	r.align32()
+	if len(r.blocks) == 0 {
+		return errors.New("invalid END_BLOCK; not inside of block")
+	}
+	top := r.blocks[len(r.blocks)-1]
+	r.blocks = r.blocks[:len(r.blocks)-1]
+	r.abbrevWidth = top.abbrevWidth
+	r.abbrevs = top.abbrevs
+	return nil
+}
+
+// readBlockInfo reads the contents of a BLOCKINFO block.
+func (r *Reader) readBlockInfo() error {
+	if err := r.EnterBlock(); err != nil {
+		return errors.WithStack(err)
+	}
+	// Block ID of the current SETBID record; or -1 if not present.
+	curBlockID := int64(-1)
+	for {
+		abbrevID, err := r.ReadFixed(r.abbrevWidth)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		switch abbrevID {
+		case AbbrevIDEndBlock:
+			return r.endBlock()
+		case AbbrevIDEnterSubblock:
+			return errors.New("invalid sub-block in BLOCKINFO block")
+		case AbbrevIDDefineAbbrev:
+			abbrev, err := r.readAbbrev()
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			if curBlockID == -1 {
+				return errors.New("invalid abbreviation in BLOCKINFO block; missing SETBID record")
+			}
+			id := uint64(curBlockID)
+			r.blockInfo[id] = append(r.blockInfo[id], abbrev)
+		default:
+			record, err := r.readRecord(abbrevID)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			if record.Code == BlockInfoCodeSetBID {
+				if len(record.Ops) < 1 {
+					return errors.New("invalid SETBID record; missing block ID")
+				}
+				curBlockID = int64(record.Ops[0])
+			}
+			// Ignore BLOCKNAME and SETRECORDNAME records.
+		}
+	}
+}
+
+// readAbbrev reads an abbreviation definition.
+func (r *Reader) readAbbrev() (*Abbrev, error) {
+	n, err := r.ReadVBR(5)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	abbrev := &Abbrev{}
+	for i := uint64(0); i < n; i++ {
+		isLiteral, err := r.ReadFixed(1)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if isLiteral == 1 {
+			v, err := r.ReadVBR(8)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			abbrev.Ops = append(abbrev.Ops, Literal(v))
+			continue
+		}
+		enc, err := r.ReadFixed(3)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		op := AbbrevOp{Encoding: Encoding(enc)}
+		switch op.Encoding {
+		case EncodingFixed, EncodingVBR:
+			if op.Value, err = r.ReadVBR(5); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			if op.Value > 64 {
+				return nil, errors.Errorf("invalid abbreviation operand width %d", op.Value)
+			}
+		case EncodingArray, EncodingChar6, EncodingBlob:
+			// no value.
+		default:
+			return nil, errors.Errorf("invalid abbreviation operand encoding %d", enc)
+		}
+		abbrev.Ops = append(abbrev.Ops, op)
+	}
+	return abbrev, nil
+}
+
+// readRecord reads a data record encoded with the given abbreviation ID.
+func (r *Reader) readRecord(abbrevID uint64) (*Record, error) {
+	if abbrevID == AbbrevIDUnabbrevRecord {
+		code, err := r.ReadVBR(6)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		n, err := r.ReadVBR(6)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		record := &Record{Code: code, Ops: make([]uint64, 0, n)}
+		for i := uint64(0); i < n; i++ {
+			v, err := r.ReadVBR(6)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			record.Ops = append(record.Ops, v)
+		}
+		return record, nil
+	}
+	i := abbrevID - AbbrevIDFirstApplication
+	if i >= uint64(len(r.abbrevs)) {
+		return nil, errors.Errorf("invalid abbreviation ID %d", abbrevID)
+	}
+	abbrev := r.abbrevs[i]
+	var vals []uint64
+	var blob []byte
+	for j := 0; j < len(abbrev.Ops); j++ {
+		op := abbrev.Ops[j]
+		switch op.Encoding {
+		case EncodingArray:
+			if j+1 >= len(abbrev.Ops) {
+				return nil, errors.New("invalid array abbreviation operand; missing element encoding")
+			}
+			elemOp := abbrev.Ops[j+1]
+			j++
+			n, err := r.ReadVBR(6)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			for k := uint64(0); k < n; k++ {
+				v, err := r.readScalar(elemOp)
+				if err != nil {
+					return nil, errors.WithStack(err)
+				}
+				vals = append(vals, v)
+			}
+		case EncodingBlob:
+			n, err := r.ReadVBR(6)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			r.align32()
+			start := r.pos / 8
+			end := start + n
+			if end > uint64(len(r.buf)) {
+				return nil, errors.Errorf("invalid blob length %d; exceeds end of bitstream", n)
+			}
+			blob = r.buf[start:end]
+			r.pos = end * 8
+			r.align32()
+		default:
+			v, err := r.readScalar(op)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			vals = append(vals, v)
+		}
+	}
+	if len(vals) == 0 {
+		return nil, errors.New("invalid abbreviated record; missing record code")
+	}
+	return &Record{Code: vals[0], Ops: vals[1:], Blob: blob}, nil
+}
+
+// readScalar reads a scalar value encoded using the given abbreviation
+// operand.
+func (r *Reader) readScalar(op AbbrevOp) (uint64, error) {
+	switch op.Encoding {
+	case EncodingLiteral:
+		return op.Value, nil
+	case EncodingFixed:
+		return r.ReadFixed(op.Value)
+	case EncodingVBR:
+		return r.ReadVBR(op.Value)
+	case EncodingChar6:
+		v, err := r.ReadFixed(6)
+		if err != nil {
+			return 0, errors.WithStack(err)
+		}
+		return decodeChar6(v), nil
+	default:
+		return 0, errors.Errorf("invalid scalar abbreviation operand encoding %d", op.Encoding)
+	}
+}
+
+// ReadFixed reads a fixed-width value of the given bit width.
+func (r *Reader) ReadFixed(width uint64) (uint64, error) {
+	if r.pos+width > uint64(len(r.buf))*8 {
+		return 0, errors.Errorf("unexpected end of bitstream at bit offset %d", r.pos)
+	}
+	var v uint64
+	for i := uint64(0); i < width; {
+		bytePos, bitPos := r.pos/8, r.pos%8
+		// Number of bits to read from the current byte.
+		n := 8 - bitPos
+		if n > width-i {
+			n = width - i
+		}
+		bits := uint64(r.buf[bytePos]>>bitPos) & (1<<n - 1)
+		v |= bits << i
+		i += n
+		r.pos += n
+	}
+	return v, nil
+}
+
+// ReadVBR reads a variable-width value of the given chunk width.
+func (r *Reader) ReadVBR(width uint64) (uint64, error) {
+	if width < 2 {
+		return 0, errors.Errorf("invalid VBR chunk width %d", width)
+	}
+	hiMask := uint64(1) << (width - 1)
+	var v uint64
+	for shift := uint64(0); ; shift += width - 1 {
+		if shift >= 64 {
+			return 0, errors.New("invalid VBR value; exceeds 64 bits")
+		}
+		chunk, err := r.ReadFixed(width)
+		if err != nil {
+			return 0, errors.WithStack(err)
+		}
+		v |= (chunk &^ hiMask) << shift
+		if chunk&hiMask == 0 {
+			return v, nil
+		}
+	}
+}
+
+// align32 skips to the next multiple of 32 bits.
+func (r *Reader) align32() {
+	if rem := r.pos % 32; rem != 0 {
+		r.pos += 32 - rem
+	}
+}
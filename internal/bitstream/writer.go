@@ -0,0 +1,363 @@
+package bitstream
+
+import (
+	"fmt"
+)
+
+// Writer is a writer of the LLVM bitstream container format.
+type Writer struct {
+	// Bitstream contents.
+	buf []byte
+	// Current bit position.
+	pos uint64
+	// Width of abbreviation IDs in the current block.
+	abbrevWidth uint64
+	// Abbreviations of the current block.
+	abbrevs []*Abbrev
+	// Stack of enclosing blocks.
+	blocks []writerScope
+	// Abbreviations defined in the BLOCKINFO block, indexed by block ID.
+	blockInfo map[uint64][]*Abbrev
+	// Block ID of the current SETBID record of the BLOCKINFO block; or -1 if
+	// not inside of a BLOCKINFO block.
+	curBlockInfoID int64
+}
+
+// writerScope records the state of an enclosing block.
+type writerScope struct {
+	// Width of abbreviation IDs in the block.
+	abbrevWidth uint64
+	// Abbreviations of the block.
+	abbrevs []*Abbrev
+	// Bit position of the block length word of the sub-block.
+	lenPos uint64
+}
+
+// NewWriter returns a new bitstream writer. The magic number is not written
+// by the bitstream writer.
+func NewWriter() *Writer {
+	return &Writer{
+		abbrevWidth:    topLevelAbbrevWidth,
+		blockInfo:      make(map[uint64][]*Abbrev),
+		curBlockInfoID: -1,
+	}
+}
+
+// Bytes returns the contents of the bitstream, padded to a multiple of 32
+// bits.
+func (w *Writer) Bytes() []byte {
+	if len(w.blocks) != 0 {
+		panic(fmt.Errorf("unterminated block at bit offset %d", w.pos))
+	}
+	w.align32()
+	return w.buf[:w.pos/8]
+}
+
+// BitNo returns the current bit position of the writer.
+func (w *Writer) BitNo() uint64 {
+	return w.pos
+}
+
+// EnterBlock enters a new sub-block with the given block ID, using
+// abbreviation IDs of the given bit width.
+func (w *Writer) EnterBlock(blockID, abbrevWidth uint64) {
+	w.WriteFixed(AbbrevIDEnterSubblock, w.abbrevWidth)
+	w.WriteVBR(blockID, 8)
+	w.WriteVBR(abbrevWidth, 4)
+	w.align32()
+	// Placeholder for block length in 32-bit words; backpatched by ExitBlock.
+	lenPos := w.pos
+	w.WriteFixed(0, 32)
+	w.blocks = append(w.blocks, writerScope{abbrevWidth: w.abbrevWidth, abbrevs: w.abbrevs, lenPos: lenPos})
+	w.abbrevWidth = abbrevWidth
+	w.abbrevs = append([]*Abbrev(nil), w.blockInfo[blockID]...)
+}
+
+// ExitBlock leaves the current block.
+func (w *Writer) ExitBlock() {
+	if len(w.blocks) == 0 {
+		panic("invalid END_BLOCK; not inside of block")
+	}
+	w.WriteFixed(AbbrevIDEndBlock, w.abbrevWidth)
+	w.align32()
+	top := w.blocks[len(w.blocks)-1]
+	w.blocks = w.blocks[:len(w.blocks)-1]
+	// Block length in 32-bit words, excluding the length word.
+	n := (w.pos - top.lenPos - 32) / 32
+	w.BackpatchWord(top.lenPos, uint32(n))
+	w.abbrevWidth = top.abbrevWidth
+	w.abbrevs = top.abbrevs
+}
+
+// EnterBlockInfoBlock enters a BLOCKINFO block. Abbreviations of the
+// BLOCKINFO block are defined using EmitBlockInfoAbbrev.
+func (w *Writer) EnterBlockInfoBlock() {
+	w.EnterBlock(BlockInfoBlockID, 2)
+}
+
+// ExitBlockInfoBlock leaves the current BLOCKINFO block.
+func (w *Writer) ExitBlockInfoBlock() {
+	w.ExitBlock()
+	w.curBlockInfoID = -1
+}
+
+// EmitBlockInfoAbbrev defines the given abbreviation for all blocks with the
+// given block ID, and returns its abbreviation ID. It must be invoked within a
+// BLOCKINFO block.
+func (w *Writer) EmitBlockInfoAbbrev(blockID uint64, abbrev *Abbrev) uint64 {
+	if w.curBlockInfoID != int64(blockID) {
+		w.EmitRecord(BlockInfoCodeSetBID, []uint64{blockID}, AbbrevIDUnabbrevRecord)
+		w.curBlockInfoID = int64(blockID)
+	}
+	w.writeAbbrev(abbrev)
+	w.blockInfo[blockID] = append(w.blockInfo[blockID], abbrev)
+	return AbbrevIDFirstApplication + uint64(len(w.blockInfo[blockID])-1)
+}
+
+// EmitAbbrev defines the given abbreviation for the current block, and returns
+// its abbreviation ID.
+func (w *Writer) EmitAbbrev(abbrev *Abbrev) uint64 {
+	w.writeAbbrev(abbrev)
+	w.abbrevs = append(w.abbrevs, abbrev)
+	return AbbrevIDFirstApplication + uint64(len(w.abbrevs)-1)
+}
+
+// writeAbbrev writes the given abbreviation definition.
+func (w *Writer) writeAbbrev(abbrev *Abbrev) {
+	w.WriteFixed(AbbrevIDDefineAbbrev, w.abbrevWidth)
+	w.WriteVBR(uint64(len(abbrev.Ops)), 5)
+	for _, op := range abbrev.Ops {
+		if op.Encoding == EncodingLiteral {
+			w.WriteFixed(1, 1)
+			w.WriteVBR(op.Value, 8)
+			continue
+		}
+		w.WriteFixed(0, 1)
+		w.WriteFixed(uint64(op.Encoding), 3)
+		switch op.Encoding {
+		case EncodingFixed, EncodingVBR:
+			w.WriteVBR(op.Value, 5)
+		}
+	}
+}
+
+// EmitRecord writes a data record with the given record code and operands,
+// encoded using the given abbreviation ID.
+func (w *Writer) EmitRecord(code uint64, ops []uint64, abbrevID uint64) {
+	w.EmitRecordWithBlob(code, ops, nil, abbrevID)
+}
+
+// EmitRecordWithBlob writes a data record with the given record code,
+// operands and blob, encoded using the given abbreviation ID. The abbreviation
+// must contain a blob operand if blob is non-nil.
+func (w *Writer) EmitRecordWithBlob(code uint64, ops []uint64, blob []byte, abbrevID uint64) {
+	if abbrevID == AbbrevIDUnabbrevRecord {
+		if blob != nil {
+			panic(fmt.Errorf("invalid blob of unabbreviated record with code %d", code))
+		}
+		w.WriteFixed(AbbrevIDUnabbrevRecord, w.abbrevWidth)
+		w.WriteVBR(code, 6)
+		w.WriteVBR(uint64(len(ops)), 6)
+		for _, op := range ops {
+			w.WriteVBR(op, 6)
+		}
+		return
+	}
+	i := abbrevID - AbbrevIDFirstApplication
+	if i >= uint64(len(w.abbrevs)) {
+		panic(fmt.Errorf("invalid abbreviation ID %d", abbrevID))
+	}
+	abbrev := w.abbrevs[i]
+	w.WriteFixed(abbrevID, w.abbrevWidth)
+	vals := append([]uint64{code}, ops...)
+	for j := 0; j < len(abbrev.Ops); j++ {
+		op := abbrev.Ops[j]
+		switch op.Encoding {
+		case EncodingArray:
+			elemOp := abbrev.Ops[j+1]
+			j++
+			w.WriteVBR(uint64(len(vals)), 6)
+			for _, v := range vals {
+				w.writeScalar(elemOp, v)
+			}
+			vals = nil
+		case EncodingBlob:
+			w.WriteVBR(uint64(len(blob)), 6)
+			w.align32()
+			w.grow(uint64(len(blob)) * 8)
+			copy(w.buf[w.pos/8:], blob)
+			w.pos += uint64(len(blob)) * 8
+			w.align32()
+		default:
+			if len(vals) == 0 {
+				panic(fmt.Errorf("too few operands for abbreviation ID %d of record with code %d", abbrevID, code))
+			}
+			w.writeScalar(op, vals[0])
+			vals = vals[1:]
+		}
+	}
+	if len(vals) != 0 {
+		panic(fmt.Errorf("too many operands for abbreviation ID %d of record with code %d", abbrevID, code))
+	}
+}
+
+// writeScalar writes a scalar value encoded using the given abbreviation
+// operand.
+func (w *Writer) writeScalar(op AbbrevOp, v uint64) {
+	switch op.Encoding {
+	case EncodingLiteral:
+		if v != op.Value {
+			panic(fmt.Errorf("mismatch between literal abbreviation operand %d and value %d", op.Value, v))
+		}
+	case EncodingFixed:
+		w.WriteFixed(v, op.Value)
+	case EncodingVBR:
+		w.WriteVBR(v, op.Value)
+	case EncodingChar6:
+		w.WriteFixed(encodeChar6(v), 6)
+	default:
+		panic(fmt.Errorf("invalid scalar abbreviation operand encoding %d", op.Encoding))
+	}
+}
+
+// CanEncode reports whether the record with the given record code and operands
+// is representable using the abbreviation. Blob operands of the abbreviation
+// are not taken into account.
+func (a *Abbrev) CanEncode(code uint64, ops []uint64) bool {
+	vals := append([]uint64{code}, ops...)
+	for i := 0; i < len(a.Ops); i++ {
+		op := a.Ops[i]
+		switch op.Encoding {
+		case EncodingArray:
+			elemOp := a.Ops[i+1]
+			i++
+			for _, v := range vals {
+				if !canEncodeScalar(elemOp, v) {
+					return false
+				}
+			}
+			vals = nil
+		case EncodingBlob:
+			// Blob contents are not part of the record operands.
+		default:
+			if len(vals) == 0 || !canEncodeScalar(op, vals[0]) {
+				return false
+			}
+			vals = vals[1:]
+		}
+	}
+	return len(vals) == 0
+}
+
+// canEncodeScalar reports whether the given value is representable using the
+// scalar abbreviation operand.
+func canEncodeScalar(op AbbrevOp, v uint64) bool {
+	switch op.Encoding {
+	case EncodingLiteral:
+		return v == op.Value
+	case EncodingFixed:
+		return op.Value >= 64 || v < 1<<op.Value
+	case EncodingVBR:
+		return true
+	case EncodingChar6:
+		return v < 256 && isChar6(byte(v))
+	default:
+		return false
+	}
+}
+
+// WriteFixed writes a fixed-width value of the given bit width.
+func (w *Writer) WriteFixed(v, width uint64) {
+	w.grow(width)
+	for i := uint64(0); i < width; {
+		bytePos, bitPos := w.pos/8, w.pos%8
+		// Number of bits to write to the current byte.
+		n := 8 - bitPos
+		if n > width-i {
+			n = width - i
+		}
+		bits := (v >> i) & (1<<n - 1)
+		w.buf[bytePos] |= byte(bits << bitPos)
+		i += n
+		w.pos += n
+	}
+}
+
+// WriteVBR writes a variable-width value of the given chunk width.
+func (w *Writer) WriteVBR(v, width uint64) {
+	hiMask := uint64(1) << (width - 1)
+	for v >= hiMask {
+		w.WriteFixed(v&(hiMask-1)|hiMask, width)
+		v >>= width - 1
+	}
+	w.WriteFixed(v, width)
+}
+
+// BackpatchWord overwrites the 32-bit word at the given bit position with v.
+func (w *Writer) BackpatchWord(bitPos uint64, v uint32) {
+	pos := w.pos
+	w.pos = bitPos
+	// Clear bits before writing.
+	for i := uint64(0); i < 32; i++ {
+		p := bitPos + i
+		w.buf[p/8] &^= 1 << (p % 8)
+	}
+	w.WriteFixed(uint64(v), 32)
+	w.pos = pos
+}
+
+// grow ensures there is room for n more bits in the buffer.
+func (w *Writer) grow(n uint64) {
+	need := (w.pos + n + 7) / 8
+	for uint64(len(w.buf)) < need {
+		w.buf = append(w.buf, 0)
+	}
+}
+
+// align32 pads the bitstream with zeros to the next multiple of 32 bits.
+func (w *Writer) align32() {
+	if rem := w.pos % 32; rem != 0 {
+		w.grow(32 - rem)
+		w.pos += 32 - rem
+	}
+}
+
+// ### [ Helper functions ] ####################################################
+
+// IsChar6 reports whether the given string only contains characters
+// representable as 6-bit characters.
+func IsChar6(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if !isChar6(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// isChar6 reports whether the given character is representable as a 6-bit
+// character.
+func isChar6(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '.', c == '_':
+		return true
+	}
+	return false
+}
+
+// encodeChar6 encodes the given ASCII character as a 6-bit character.
+func encodeChar6(c uint64) uint64 {
+	switch {
+	case 'a' <= c && c <= 'z':
+		return c - 'a'
+	case 'A' <= c && c <= 'Z':
+		return c - 'A' + 26
+	case '0' <= c && c <= '9':
+		return c - '0' + 52
+	case c == '.':
+		return 62
+	case c == '_':
+		return 63
+	}
+	panic(fmt.Errorf("invalid 6-bit character %q", rune(c)))
+}
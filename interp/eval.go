@@ -0,0 +1,420 @@
+package interp
+
+import (
+	"math/big"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/pkg/errors"
+)
+
+// minusOne is the integer -1, with all bits set.
+var minusOne = big.NewInt(-1)
+
+// evalConst returns the runtime value of the given constant.
+func (in *Interpreter) evalConst(c constant.Constant) (constant.Constant, error) {
+	switch c := c.(type) {
+	case *constant.Int, *constant.Float, *constant.CharArray, *constant.NoneToken, *Pointer:
+		return c, nil
+	case *constant.Null:
+		return NewPointer(c.Typ, 0), nil
+	case *constant.Undef, *constant.Poison, *constant.ZeroInitializer:
+		return zeroValue(c.Type()), nil
+	case *constant.Vector:
+		elems, err := in.evalConsts(c.Elems)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return &constant.Vector{Typ: c.Typ, Elems: elems}, nil
+	case *constant.Array:
+		elems, err := in.evalConsts(c.Elems)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return &constant.Array{Typ: c.Typ, Elems: elems}, nil
+	case *constant.Struct:
+		fields, err := in.evalConsts(c.Fields)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return &constant.Struct{Typ: c.Typ, Fields: fields}, nil
+	case *ir.Global, *ir.Func, *ir.IFunc:
+		a, ok := in.addrs[c]
+		if !ok {
+			return nil, errors.Errorf("unable to locate address of %s", c.Ident())
+		}
+		return NewPointer(c.Type().(*types.PointerType), a), nil
+	case *ir.Alias:
+		x, err := in.evalConst(c.Aliasee)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		a, err := addr(x)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return NewPointer(c.Typ, a), nil
+	case constant.Expression:
+		return in.evalExpr(c)
+	}
+	return nil, errors.Errorf("support for constant %T not yet implemented", c)
+}
+
+// evalConsts returns the runtime values of the given constants.
+func (in *Interpreter) evalConsts(cs []constant.Constant) ([]constant.Constant, error) {
+	xs := make([]constant.Constant, len(cs))
+	for i, c := range cs {
+		x, err := in.evalConst(c)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		xs[i] = x
+	}
+	return xs, nil
+}
+
+// evalExpr returns the runtime value of the given constant expression.
+func (in *Interpreter) evalExpr(expr constant.Expression) (constant.Constant, error) {
+	// Evaluate operands of constant expression.
+	var ops []constant.Constant
+	switch e := expr.(type) {
+	case *constant.ExprFNeg:
+		ops = []constant.Constant{e.X}
+	case *constant.ExprAdd:
+		ops = []constant.Constant{e.X, e.Y}
+	case *constant.ExprSub:
+		ops = []constant.Constant{e.X, e.Y}
+	case *constant.ExprMul:
+		ops = []constant.Constant{e.X, e.Y}
+	case *constant.ExprShl:
+		ops = []constant.Constant{e.X, e.Y}
+	case *constant.ExprLShr:
+		ops = []constant.Constant{e.X, e.Y}
+	case *constant.ExprAShr:
+		ops = []constant.Constant{e.X, e.Y}
+	case *constant.ExprAnd:
+		ops = []constant.Constant{e.X, e.Y}
+	case *constant.ExprOr:
+		ops = []constant.Constant{e.X, e.Y}
+	case *constant.ExprXor:
+		ops = []constant.Constant{e.X, e.Y}
+	case *constant.ExprExtractElement:
+		ops = []constant.Constant{e.X, e.Index}
+	case *constant.ExprInsertElement:
+		ops = []constant.Constant{e.X, e.Elem, e.Index}
+	case *constant.ExprShuffleVector:
+		ops = []constant.Constant{e.X, e.Y, e.Mask}
+	case *constant.ExprGetElementPtr:
+		ops = []constant.Constant{e.Src}
+		for _, index := range e.Indices {
+			if i, ok := index.(*constant.Index); ok {
+				index = i.Constant
+			}
+			ops = append(ops, index)
+		}
+	case *constant.ExprICmp:
+		ops = []constant.Constant{e.X, e.Y}
+	case *constant.ExprFCmp:
+		ops = []constant.Constant{e.X, e.Y}
+	case *constant.ExprSelect:
+		ops = []constant.Constant{e.Cond, e.X, e.Y}
+	default:
+		from, ok := convFrom(expr)
+		if !ok {
+			return nil, errors.Errorf("support for constant expression %T not yet implemented", expr)
+		}
+		ops = []constant.Constant{from}
+	}
+	xs, err := in.evalConsts(ops)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// Rebuild constant expression with evaluated operands, without poison
+	// generating flags.
+	var r constant.Constant
+	switch e := expr.(type) {
+	case *constant.ExprFNeg:
+		r = constant.NewFNeg(xs[0])
+	case *constant.ExprAdd:
+		r = constant.NewAdd(xs[0], xs[1])
+	case *constant.ExprSub:
+		r = constant.NewSub(xs[0], xs[1])
+	case *constant.ExprMul:
+		r = constant.NewMul(xs[0], xs[1])
+	case *constant.ExprShl:
+		r = constant.NewShl(xs[0], xs[1])
+	case *constant.ExprLShr:
+		r = constant.NewLShr(xs[0], xs[1])
+	case *constant.ExprAShr:
+		r = constant.NewAShr(xs[0], xs[1])
+	case *constant.ExprAnd:
+		r = constant.NewAnd(xs[0], xs[1])
+	case *constant.ExprOr:
+		r = constant.NewOr(xs[0], xs[1])
+	case *constant.ExprXor:
+		r = constant.NewXor(xs[0], xs[1])
+	case *constant.ExprExtractElement:
+		r = constant.NewExtractElement(xs[0], xs[1])
+	case *constant.ExprInsertElement:
+		r = constant.NewInsertElement(xs[0], xs[1], xs[2])
+	case *constant.ExprShuffleVector:
+		r = constant.NewShuffleVector(xs[0], xs[1], xs[2])
+	case *constant.ExprGetElementPtr:
+		return in.gep(e.ElemType, xs[0], xs[1:], e.Type())
+	case *constant.ExprTrunc:
+		r = constant.NewTrunc(xs[0], e.To)
+	case *constant.ExprZExt:
+		r = constant.NewZExt(xs[0], e.To)
+	case *constant.ExprSExt:
+		r = constant.NewSExt(xs[0], e.To)
+	case *constant.ExprFPTrunc:
+		r = constant.NewFPTrunc(xs[0], e.To)
+	case *constant.ExprFPExt:
+		r = constant.NewFPExt(xs[0], e.To)
+	case *constant.ExprFPToUI:
+		r = constant.NewFPToUI(xs[0], e.To)
+	case *constant.ExprFPToSI:
+		r = constant.NewFPToSI(xs[0], e.To)
+	case *constant.ExprUIToFP:
+		r = constant.NewUIToFP(xs[0], e.To)
+	case *constant.ExprSIToFP:
+		r = constant.NewSIToFP(xs[0], e.To)
+	case *constant.ExprPtrToInt:
+		r = constant.NewPtrToInt(xs[0], e.To)
+	case *constant.ExprIntToPtr:
+		r = constant.NewIntToPtr(xs[0], e.To)
+	case *constant.ExprBitCast:
+		r = constant.NewBitCast(xs[0], e.To)
+	case *constant.ExprAddrSpaceCast:
+		r = constant.NewAddrSpaceCast(xs[0], e.To)
+	case *constant.ExprICmp:
+		r = constant.NewICmp(e.Pred, xs[0], xs[1])
+	case *constant.ExprFCmp:
+		r = constant.NewFCmp(e.Pred, xs[0], xs[1])
+	case *constant.ExprSelect:
+		r = constant.NewSelect(xs[0], xs[1], xs[2])
+	}
+	return in.apply(r)
+}
+
+// convFrom returns the operand of the given conversion expression, and a
+// boolean indicating success.
+func convFrom(expr constant.Expression) (constant.Constant, bool) {
+	switch e := expr.(type) {
+	case *constant.ExprTrunc:
+		return e.From, true
+	case *constant.ExprZExt:
+		return e.From, true
+	case *constant.ExprSExt:
+		return e.From, true
+	case *constant.ExprFPTrunc:
+		return e.From, true
+	case *constant.ExprFPExt:
+		return e.From, true
+	case *constant.ExprFPToUI:
+		return e.From, true
+	case *constant.ExprFPToSI:
+		return e.From, true
+	case *constant.ExprUIToFP:
+		return e.From, true
+	case *constant.ExprSIToFP:
+		return e.From, true
+	case *constant.ExprPtrToInt:
+		return e.From, true
+	case *constant.ExprIntToPtr:
+		return e.From, true
+	case *constant.ExprBitCast:
+		return e.From, true
+	case *constant.ExprAddrSpaceCast:
+		return e.From, true
+	}
+	return nil, false
+}
+
+// apply returns the runtime value of the given constant expression, the
+// operands of which are runtime values. Expressions involving memory addresses
+// are evaluated using the data layout, and remaining expressions are evaluated
+// by constant folding. Non-expression constants are returned as runtime values.
+func (in *Interpreter) apply(c constant.Constant) (constant.Constant, error) {
+	switch e := c.(type) {
+	case *constant.ExprPtrToInt:
+		return mapLanes(e.From, e.To, func(from constant.Constant, to types.Type) (constant.Constant, error) {
+			a, err := addr(from)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			return newInt(to.(*types.IntType), new(big.Int).SetUint64(a)), nil
+		})
+	case *constant.ExprIntToPtr:
+		return mapLanes(e.From, e.To, func(from constant.Constant, to types.Type) (constant.Constant, error) {
+			x, ok := from.(*constant.Int)
+			if !ok {
+				return nil, errors.Errorf("invalid integer value %v", from)
+			}
+			pt := to.(*types.PointerType)
+			a := newInt(types.NewInt(8*in.layout.PointerSize(pt.AddrSpace)), x.X)
+			return NewPointer(pt, unsigned(a).Uint64()), nil
+		})
+	case *constant.ExprAddrSpaceCast:
+		return mapLanes(e.From, e.To, func(from constant.Constant, to types.Type) (constant.Constant, error) {
+			a, err := addr(from)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			return NewPointer(to.(*types.PointerType), a), nil
+		})
+	case *constant.ExprBitCast:
+		// Reinterpret the in-memory representation of the value.
+		buf, err := in.layout.encode(e.From.Type(), e.From)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return in.layout.decode(e.To, buf)
+	case *constant.ExprICmp:
+		x, err := in.ptrToInt(e.X)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		y, err := in.ptrToInt(e.Y)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		c = constant.NewICmp(e.Pred, x, y)
+	}
+	r := constant.Fold(c)
+	if _, ok := r.(constant.Expression); ok {
+		return nil, errors.Errorf("unable to evaluate %v", c)
+	}
+	return concrete(r), nil
+}
+
+// ptrToInt converts the given pointer (or vector of pointers) runtime value to
+// an integer (or vector of integers) of pointer size. Other values are returned
+// unchanged.
+func (in *Interpreter) ptrToInt(c constant.Constant) (constant.Constant, error) {
+	var to types.Type
+	switch t := c.Type().(type) {
+	case *types.PointerType:
+		to = types.NewInt(8 * in.layout.PointerSize(t.AddrSpace))
+	case *types.VectorType:
+		pt, ok := t.ElemType.(*types.PointerType)
+		if !ok {
+			return c, nil
+		}
+		to = types.NewVector(t.Len, types.NewInt(8*in.layout.PointerSize(pt.AddrSpace)))
+	default:
+		return c, nil
+	}
+	return in.apply(constant.NewPtrToInt(c, to))
+}
+
+// gep returns the address computed by a getelementptr instruction or constant
+// expression with the given element type, source address, indices and result
+// type.
+func (in *Interpreter) gep(elemType types.Type, src constant.Constant, indices []constant.Constant, typ types.Type) (constant.Constant, error) {
+	vt, ok := typ.(*types.VectorType)
+	if !ok {
+		return in.gepScalar(elemType, src, indices, typ.(*types.PointerType))
+	}
+	// Vector of addresses; scalar operands are used for every element.
+	lane := func(c constant.Constant, i int) (constant.Constant, error) {
+		if _, ok := c.Type().(*types.VectorType); !ok {
+			return c, nil
+		}
+		es, err := elems(c)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return es[i], nil
+	}
+	es := make([]constant.Constant, vt.Len)
+	for i := range es {
+		s, err := lane(src, i)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		is := make([]constant.Constant, len(indices))
+		for j, index := range indices {
+			if is[j], err = lane(index, i); err != nil {
+				return nil, errors.WithStack(err)
+			}
+		}
+		if es[i], err = in.gepScalar(elemType, s, is, vt.ElemType.(*types.PointerType)); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return &constant.Vector{Typ: vt, Elems: es}, nil
+}
+
+// gepScalar returns the address computed by a getelementptr with scalar
+// operands.
+func (in *Interpreter) gepScalar(elemType types.Type, src constant.Constant, indices []constant.Constant, typ *types.PointerType) (constant.Constant, error) {
+	base, err := addr(src)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	offset := int64(0)
+	t := elemType
+	for i, index := range indices {
+		x, ok := index.(*constant.Int)
+		if !ok {
+			return nil, errors.Errorf("invalid getelementptr index %v", index)
+		}
+		idx := signed(x).Int64()
+		if i == 0 {
+			offset += idx * int64(in.layout.AllocSizeOf(elemType))
+			continue
+		}
+		switch tt := t.(type) {
+		case *types.StructType:
+			if idx < 0 || idx >= int64(len(tt.Fields)) {
+				return nil, errors.Errorf("invalid struct field index %d of %v", idx, tt)
+			}
+			offset += int64(in.layout.StructLayout(tt).Offsets[idx])
+			t = tt.Fields[idx]
+		case *types.ArrayType:
+			offset += idx * int64(in.layout.AllocSizeOf(tt.ElemType))
+			t = tt.ElemType
+		case *types.VectorType:
+			offset += idx * int64(in.layout.AllocSizeOf(tt.ElemType))
+			t = tt.ElemType
+		default:
+			return nil, errors.Errorf("invalid getelementptr indexing into type %v", t)
+		}
+	}
+	a := newInt(types.NewInt(8*in.layout.PointerSize(typ.AddrSpace)), new(big.Int).SetUint64(base+uint64(offset)))
+	return NewPointer(typ, unsigned(a).Uint64()), nil
+}
+
+// mapLanes applies f to each element of the given vector runtime value (with
+// the element type of to), or to the given scalar runtime value.
+func mapLanes(x constant.Constant, to types.Type, f func(x constant.Constant, to types.Type) (constant.Constant, error)) (constant.Constant, error) {
+	vt, ok := to.(*types.VectorType)
+	if !ok {
+		return f(x, to)
+	}
+	es, err := elems(x)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	rs := make([]constant.Constant, len(es))
+	for i, elem := range es {
+		if rs[i], err = f(elem, vt.ElemType); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return &constant.Vector{Typ: vt, Elems: rs}, nil
+}
+
+// result returns the runtime value of the given folded constant, or an error if
+// the constant could not be evaluated.
+func result(c constant.Constant) (constant.Constant, error) {
+	if c == nil {
+		return nil, errors.Errorf("unable to evaluate instruction")
+	}
+	if _, ok := c.(constant.Expression); ok {
+		return nil, errors.Errorf("unable to evaluate %v", c)
+	}
+	return concrete(c), nil
+}
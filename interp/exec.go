@@ -0,0 +1,660 @@
+package interp
+
+import (
+	"bytes"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/pkg/errors"
+)
+
+// frame is the call frame of an interpreted function.
+type frame struct {
+	// Interpreter.
+	in *Interpreter
+	// Function being interpreted.
+	f *ir.Func
+	// Runtime values of local variables (parameters and instruction results).
+	locals map[value.Value]constant.Constant
+	// Stack allocations of the frame, freed on return.
+	allocas []*allocation
+	// Exception value of the landing pad being executed; or nil if none.
+	exc constant.Constant
+}
+
+// run executes the function of the call frame, and returns its return value;
+// or nil if the function returns void.
+func (fr *frame) run() (constant.Constant, error) {
+	var pred *ir.Block
+	block := fr.f.Blocks[0]
+	for {
+		if err := fr.enter(block, pred); err != nil {
+			return nil, errors.WithMessage(err, block.Ident())
+		}
+		for _, inst := range block.Insts {
+			if _, ok := inst.(*ir.InstPhi); ok {
+				continue
+			}
+			x, err := fr.exec(inst)
+			if err != nil {
+				if _, ok := err.(*Exception); ok {
+					return nil, err
+				}
+				return nil, errors.WithMessage(err, inst.LLString())
+			}
+			if x != nil {
+				fr.locals[inst.(value.Value)] = x
+			}
+		}
+		next, ret, err := fr.term(block.Term)
+		if err != nil {
+			if _, ok := err.(*Exception); ok {
+				return nil, err
+			}
+			return nil, errors.WithMessage(err, block.Term.LLString())
+		}
+		if next == nil {
+			return ret, nil
+		}
+		pred, block = block, next
+	}
+}
+
+// enter evaluates the phi instructions of the given basic block, entered from
+// the predecessor basic block pred. All phi instructions are evaluated before
+// any of their results are assigned.
+func (fr *frame) enter(block, pred *ir.Block) error {
+	var phis []*ir.InstPhi
+	var vals []constant.Constant
+	for _, inst := range block.Insts {
+		phi, ok := inst.(*ir.InstPhi)
+		if !ok {
+			break
+		}
+		found := false
+		for _, inc := range phi.Incs {
+			if inc.Pred != pred {
+				continue
+			}
+			x, err := fr.eval(inc.X)
+			if err != nil {
+				return errors.WithMessage(err, phi.LLString())
+			}
+			phis = append(phis, phi)
+			vals = append(vals, x)
+			found = true
+			break
+		}
+		if !found {
+			return errors.Errorf("%s: missing incoming value for predecessor %v", phi.LLString(), pred)
+		}
+	}
+	for i, phi := range phis {
+		fr.locals[phi] = vals[i]
+	}
+	return nil
+}
+
+// release frees the stack allocations of the call frame.
+func (fr *frame) release() {
+	for _, a := range fr.allocas {
+		fr.in.Mem.release(a)
+	}
+}
+
+// eval returns the runtime value of the given operand.
+func (fr *frame) eval(v value.Value) (constant.Constant, error) {
+	if x, ok := fr.locals[v]; ok {
+		return x, nil
+	}
+	switch v := v.(type) {
+	case *ir.Arg:
+		return fr.eval(v.Value)
+	case constant.Constant:
+		return fr.in.evalConst(v)
+	}
+	return nil, errors.Errorf("unable to evaluate %v; undefined value", v.Ident())
+}
+
+// evalAll returns the runtime values of the given operands.
+func (fr *frame) evalAll(vs ...value.Value) ([]constant.Constant, error) {
+	xs := make([]constant.Constant, len(vs))
+	for i, v := range vs {
+		x, err := fr.eval(v)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		xs[i] = x
+	}
+	return xs, nil
+}
+
+// === [ Instructions ] ========================================================
+
+// exec executes the given instruction, and returns its result; or nil if the
+// instruction produces no result.
+func (fr *frame) exec(inst ir.Instruction) (constant.Constant, error) {
+	in := fr.in
+	switch inst := inst.(type) {
+	// Unary instructions.
+	case *ir.InstFNeg:
+		x, err := fr.eval(inst.X)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return in.apply(constant.NewFNeg(x))
+	// Binary instructions.
+	case *ir.InstAdd:
+		return fr.binary(inst.X, inst.Y, func(x, y constant.Constant) constant.Constant { return constant.NewAdd(x, y) })
+	case *ir.InstSub:
+		return fr.binary(inst.X, inst.Y, func(x, y constant.Constant) constant.Constant { return constant.NewSub(x, y) })
+	case *ir.InstMul:
+		return fr.binary(inst.X, inst.Y, func(x, y constant.Constant) constant.Constant { return constant.NewMul(x, y) })
+	case *ir.InstFAdd:
+		return fr.binary(inst.X, inst.Y, constant.FoldFAdd)
+	case *ir.InstFSub:
+		return fr.binary(inst.X, inst.Y, constant.FoldFSub)
+	case *ir.InstFMul:
+		return fr.binary(inst.X, inst.Y, constant.FoldFMul)
+	case *ir.InstFDiv:
+		return fr.binary(inst.X, inst.Y, constant.FoldFDiv)
+	case *ir.InstFRem:
+		return fr.binary(inst.X, inst.Y, constant.FoldFRem)
+	case *ir.InstUDiv:
+		return fr.division(inst.X, inst.Y, func(x, y constant.Constant) constant.Constant { return constant.FoldUDiv(x, y, false) })
+	case *ir.InstSDiv:
+		return fr.division(inst.X, inst.Y, func(x, y constant.Constant) constant.Constant { return constant.FoldSDiv(x, y, false) })
+	case *ir.InstURem:
+		return fr.division(inst.X, inst.Y, constant.FoldURem)
+	case *ir.InstSRem:
+		return fr.division(inst.X, inst.Y, constant.FoldSRem)
+	// Bitwise instructions.
+	case *ir.InstShl:
+		return fr.binary(inst.X, inst.Y, func(x, y constant.Constant) constant.Constant { return constant.NewShl(x, y) })
+	case *ir.InstLShr:
+		return fr.binary(inst.X, inst.Y, func(x, y constant.Constant) constant.Constant { return constant.NewLShr(x, y) })
+	case *ir.InstAShr:
+		return fr.binary(inst.X, inst.Y, func(x, y constant.Constant) constant.Constant { return constant.NewAShr(x, y) })
+	case *ir.InstAnd:
+		return fr.binary(inst.X, inst.Y, func(x, y constant.Constant) constant.Constant { return constant.NewAnd(x, y) })
+	case *ir.InstOr:
+		return fr.binary(inst.X, inst.Y, func(x, y constant.Constant) constant.Constant { return constant.NewOr(x, y) })
+	case *ir.InstXor:
+		return fr.binary(inst.X, inst.Y, func(x, y constant.Constant) constant.Constant { return constant.NewXor(x, y) })
+	// Vector instructions.
+	case *ir.InstExtractElement:
+		ops, err := fr.evalAll(inst.X, inst.Index)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return in.apply(constant.NewExtractElement(ops[0], ops[1]))
+	case *ir.InstInsertElement:
+		ops, err := fr.evalAll(inst.X, inst.Elem, inst.Index)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return in.apply(constant.NewInsertElement(ops[0], ops[1], ops[2]))
+	case *ir.InstShuffleVector:
+		ops, err := fr.evalAll(inst.X, inst.Y, inst.Mask)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return in.apply(constant.NewShuffleVector(ops[0], ops[1], ops[2]))
+	// Aggregate instructions.
+	case *ir.InstExtractValue:
+		x, err := fr.eval(inst.X)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return result(constant.FoldExtractValue(x, inst.Indices))
+	case *ir.InstInsertValue:
+		ops, err := fr.evalAll(inst.X, inst.Elem)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return result(constant.FoldInsertValue(ops[0], ops[1], inst.Indices))
+	// Memory instructions.
+	case *ir.InstAlloca:
+		return fr.alloca(inst)
+	case *ir.InstLoad:
+		src, err := fr.addr(inst.Src)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return in.Load(src, inst.ElemType)
+	case *ir.InstStore:
+		ops, err := fr.evalAll(inst.Src, inst.Dst)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		dst, err := addr(ops[1])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return nil, in.Store(dst, ops[0])
+	case *ir.InstFence:
+		// Execution is single-threaded.
+		return nil, nil
+	case *ir.InstCmpXchg:
+		return fr.cmpXchg(inst)
+	case *ir.InstAtomicRMW:
+		return fr.atomicRMW(inst)
+	case *ir.InstGetElementPtr:
+		ops, err := fr.evalAll(append([]value.Value{inst.Src}, inst.Indices...)...)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return in.gep(inst.ElemType, ops[0], ops[1:], inst.Typ)
+	// Conversion instructions.
+	case *ir.InstTrunc:
+		return fr.conv(inst.From, func(from constant.Constant) constant.Constant { return constant.NewTrunc(from, inst.To) })
+	case *ir.InstZExt:
+		return fr.conv(inst.From, func(from constant.Constant) constant.Constant { return constant.NewZExt(from, inst.To) })
+	case *ir.InstSExt:
+		return fr.conv(inst.From, func(from constant.Constant) constant.Constant { return constant.NewSExt(from, inst.To) })
+	case *ir.InstFPTrunc:
+		return fr.conv(inst.From, func(from constant.Constant) constant.Constant { return constant.NewFPTrunc(from, inst.To) })
+	case *ir.InstFPExt:
+		return fr.conv(inst.From, func(from constant.Constant) constant.Constant { return constant.NewFPExt(from, inst.To) })
+	case *ir.InstFPToUI:
+		return fr.conv(inst.From, func(from constant.Constant) constant.Constant { return constant.NewFPToUI(from, inst.To) })
+	case *ir.InstFPToSI:
+		return fr.conv(inst.From, func(from constant.Constant) constant.Constant { return constant.NewFPToSI(from, inst.To) })
+	case *ir.InstUIToFP:
+		return fr.conv(inst.From, func(from constant.Constant) constant.Constant { return constant.NewUIToFP(from, inst.To) })
+	case *ir.InstSIToFP:
+		return fr.conv(inst.From, func(from constant.Constant) constant.Constant { return constant.NewSIToFP(from, inst.To) })
+	case *ir.InstPtrToInt:
+		return fr.conv(inst.From, func(from constant.Constant) constant.Constant { return constant.NewPtrToInt(from, inst.To) })
+	case *ir.InstIntToPtr:
+		return fr.conv(inst.From, func(from constant.Constant) constant.Constant { return constant.NewIntToPtr(from, inst.To) })
+	case *ir.InstBitCast:
+		return fr.conv(inst.From, func(from constant.Constant) constant.Constant { return constant.NewBitCast(from, inst.To) })
+	case *ir.InstAddrSpaceCast:
+		return fr.conv(inst.From, func(from constant.Constant) constant.Constant { return constant.NewAddrSpaceCast(from, inst.To) })
+	// Other instructions.
+	case *ir.InstICmp:
+		return fr.binary(inst.X, inst.Y, func(x, y constant.Constant) constant.Constant { return constant.NewICmp(inst.Pred, x, y) })
+	case *ir.InstFCmp:
+		return fr.binary(inst.X, inst.Y, func(x, y constant.Constant) constant.Constant { return constant.NewFCmp(inst.Pred, x, y) })
+	case *ir.InstSelect:
+		ops, err := fr.evalAll(inst.Cond, inst.ValueTrue, inst.ValueFalse)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return in.apply(constant.NewSelect(ops[0], ops[1], ops[2]))
+	case *ir.InstFreeze:
+		// Undef and poison values are represented by zero values.
+		return fr.eval(inst.X)
+	case *ir.InstCall:
+		return fr.call(inst.Callee, inst.Args)
+	case *ir.InstLandingPad:
+		if fr.exc == nil {
+			return nil, errors.Errorf("landing pad executed without exception")
+		}
+		return fr.exc, nil
+	}
+	return nil, errors.Errorf("support for instruction %T not yet implemented", inst)
+}
+
+// binary evaluates the operands x and y, and returns the result of applying the
+// given operation to them. The operation returns either the folded result or a
+// constant expression evaluated by apply.
+func (fr *frame) binary(x, y value.Value, op func(x, y constant.Constant) constant.Constant) (constant.Constant, error) {
+	ops, err := fr.evalAll(x, y)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	r := op(ops[0], ops[1])
+	if r == nil {
+		return nil, errors.Errorf("unable to evaluate operation on %v and %v", ops[0], ops[1])
+	}
+	return fr.in.apply(r)
+}
+
+// division evaluates the operands x and y, and returns the result of the given
+// integer division or remainder operation.
+func (fr *frame) division(x, y value.Value, op func(x, y constant.Constant) constant.Constant) (constant.Constant, error) {
+	ops, err := fr.evalAll(x, y)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if hasZero(ops[1]) {
+		return nil, errors.Errorf("integer division by zero")
+	}
+	r := op(ops[0], ops[1])
+	if r == nil {
+		return nil, errors.Errorf("unable to evaluate division of %v by %v", ops[0], ops[1])
+	}
+	if hasPoison(r) {
+		return nil, errors.Errorf("integer overflow in division of %v by %v", ops[0], ops[1])
+	}
+	return concrete(r), nil
+}
+
+// conv evaluates the operand from, and returns the result of the given
+// conversion.
+func (fr *frame) conv(from value.Value, op func(from constant.Constant) constant.Constant) (constant.Constant, error) {
+	x, err := fr.eval(from)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return fr.in.apply(op(x))
+}
+
+// addr evaluates the given pointer operand, and returns its memory address.
+func (fr *frame) addr(v value.Value) (uint64, error) {
+	x, err := fr.eval(v)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return addr(x)
+}
+
+// alloca executes the given alloca instruction.
+func (fr *frame) alloca(inst *ir.InstAlloca) (constant.Constant, error) {
+	in := fr.in
+	n := uint64(1)
+	if inst.NElems != nil {
+		x, err := fr.eval(inst.NElems)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if n, err = intArg(x); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	align := uint64(inst.Align)
+	if a := in.layout.ABIAlignOf(inst.ElemType); a > align {
+		align = a
+	}
+	a := in.Mem.alloc(n*in.layout.AllocSizeOf(inst.ElemType), align, allocStack)
+	fr.allocas = append(fr.allocas, a)
+	return NewPointer(inst.Typ, a.addr), nil
+}
+
+// copyByval copies the value of the given type pointed to by the byval
+// argument arg to a stack allocation of the call frame, and returns a pointer
+// to the copy.
+func (fr *frame) copyByval(arg constant.Constant, typ types.Type) (constant.Constant, error) {
+	in := fr.in
+	src, err := addr(arg)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	size := in.layout.AllocSizeOf(typ)
+	a := in.Mem.alloc(size, in.layout.ABIAlignOf(typ), allocStack)
+	fr.allocas = append(fr.allocas, a)
+	if size > 0 {
+		buf, err := in.Mem.Read(src, size)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if err := in.Mem.Write(a.addr, buf); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return NewPointer(arg.Type().(*types.PointerType), a.addr), nil
+}
+
+// cmpXchg executes the given cmpxchg instruction.
+func (fr *frame) cmpXchg(inst *ir.InstCmpXchg) (constant.Constant, error) {
+	in := fr.in
+	ops, err := fr.evalAll(inst.Ptr, inst.Cmp, inst.New)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ptr, err := addr(ops[0])
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	old, err := in.Load(ptr, ops[1].Type())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	a, err := in.layout.encode(old.Type(), old)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	b, err := in.layout.encode(ops[1].Type(), ops[1])
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	success := bytes.Equal(a, b)
+	if success {
+		if err := in.Store(ptr, ops[2]); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	flag := constant.False
+	if success {
+		flag = constant.True
+	}
+	return &constant.Struct{Typ: inst.Typ, Fields: []constant.Constant{old, flag}}, nil
+}
+
+// atomicRMW executes the given atomicrmw instruction.
+func (fr *frame) atomicRMW(inst *ir.InstAtomicRMW) (constant.Constant, error) {
+	in := fr.in
+	ops, err := fr.evalAll(inst.Dst, inst.X)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	dst, err := addr(ops[0])
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	x := ops[1]
+	old, err := in.Load(dst, x.Type())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var r constant.Constant
+	switch inst.Op {
+	case enum.AtomicOpXChg:
+		r = x
+	case enum.AtomicOpAdd:
+		r = constant.NewAdd(old, x)
+	case enum.AtomicOpSub:
+		r = constant.NewSub(old, x)
+	case enum.AtomicOpAnd:
+		r = constant.NewAnd(old, x)
+	case enum.AtomicOpNAnd:
+		r = constant.NewXor(constant.NewAnd(old, x), newInt(x.Type().(*types.IntType), minusOne))
+	case enum.AtomicOpOr:
+		r = constant.NewOr(old, x)
+	case enum.AtomicOpXor:
+		r = constant.NewXor(old, x)
+	case enum.AtomicOpMax:
+		r = constant.NewSelect(constant.NewICmp(enum.IPredSGT, old, x), old, x)
+	case enum.AtomicOpMin:
+		r = constant.NewSelect(constant.NewICmp(enum.IPredSLT, old, x), old, x)
+	case enum.AtomicOpUMax:
+		r = constant.NewSelect(constant.NewICmp(enum.IPredUGT, old, x), old, x)
+	case enum.AtomicOpUMin:
+		r = constant.NewSelect(constant.NewICmp(enum.IPredULT, old, x), old, x)
+	case enum.AtomicOpFAdd:
+		r = constant.FoldFAdd(old, x)
+	case enum.AtomicOpFSub:
+		r = constant.FoldFSub(old, x)
+	case enum.AtomicOpFMax:
+		r = constant.NewSelect(constant.NewFCmp(enum.FPredOGT, old, x), old, x)
+	case enum.AtomicOpFMin:
+		r = constant.NewSelect(constant.NewFCmp(enum.FPredOLT, old, x), old, x)
+	default:
+		return nil, errors.Errorf("support for atomicrmw operation %v not yet implemented", inst.Op)
+	}
+	if r == nil {
+		return nil, errors.Errorf("unable to evaluate atomicrmw %v on %v and %v", inst.Op, old, x)
+	}
+	if r, err = in.apply(r); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := in.Store(dst, r); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return old, nil
+}
+
+// call calls the given callee with the given arguments, and returns the return
+// value; or nil if the callee returns void.
+func (fr *frame) call(callee value.Value, args []value.Value) (constant.Constant, error) {
+	f, ok := callee.(*ir.Func)
+	if !ok {
+		if _, ok := callee.(*ir.InlineAsm); ok {
+			return nil, errors.Errorf("support for inline assembly not yet implemented")
+		}
+		x, err := fr.eval(callee)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if f, err = fr.in.funcOf(x); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	xs := make([]constant.Constant, len(args))
+	for i, arg := range args {
+		if a, ok := arg.(*ir.Arg); ok {
+			arg = a.Value
+		}
+		if _, ok := arg.Type().(*types.MetadataType); ok {
+			// Metadata arguments of intrinsics (e.g. llvm.dbg.value) have no
+			// runtime value.
+			continue
+		}
+		x, err := fr.eval(arg)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		xs[i] = x
+	}
+	return fr.in.Call(f, xs...)
+}
+
+// === [ Terminators ] =========================================================
+
+// term executes the given terminator, and returns the successor basic block;
+// or nil and the return value if the terminator returns from the function.
+func (fr *frame) term(term ir.Terminator) (next *ir.Block, ret constant.Constant, err error) {
+	switch term := term.(type) {
+	case *ir.TermRet:
+		if term.X == nil {
+			return nil, nil, nil
+		}
+		x, err := fr.eval(term.X)
+		return nil, x, err
+	case *ir.TermBr:
+		return term.Target.(*ir.Block), nil, nil
+	case *ir.TermCondBr:
+		x, err := fr.eval(term.Cond)
+		if err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+		cond, err := intArg(x)
+		if err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+		if cond != 0 {
+			return term.TargetTrue.(*ir.Block), nil, nil
+		}
+		return term.TargetFalse.(*ir.Block), nil, nil
+	case *ir.TermSwitch:
+		x, err := fr.eval(term.X)
+		if err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+		v, ok := x.(*constant.Int)
+		if !ok {
+			return nil, nil, errors.Errorf("invalid switch value %v", x)
+		}
+		for _, c := range term.Cases {
+			y, err := fr.eval(c.X)
+			if err != nil {
+				return nil, nil, errors.WithStack(err)
+			}
+			if w, ok := y.(*constant.Int); ok && unsigned(v).Cmp(unsigned(w)) == 0 {
+				return c.Target.(*ir.Block), nil, nil
+			}
+		}
+		return term.TargetDefault.(*ir.Block), nil, nil
+	case *ir.TermInvoke:
+		x, err := fr.call(term.Invokee, term.Args)
+		if err != nil {
+			if exc, ok := err.(*Exception); ok {
+				fr.exc = exc.Value
+				return term.ExceptionRetTarget.(*ir.Block), nil, nil
+			}
+			return nil, nil, errors.WithStack(err)
+		}
+		if x != nil {
+			fr.locals[term] = x
+		}
+		return term.NormalRetTarget.(*ir.Block), nil, nil
+	case *ir.TermResume:
+		x, err := fr.eval(term.X)
+		if err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+		return nil, nil, &Exception{Value: x}
+	case *ir.TermUnreachable:
+		return nil, nil, errors.Errorf("unreachable executed")
+	}
+	return nil, nil, errors.Errorf("support for terminator %T not yet implemented", term)
+}
+
+// ### [ Helper functions ] ####################################################
+
+// hasZero reports whether the given integer runtime value, or any element of
+// the given integer vector, is zero.
+func hasZero(c constant.Constant) bool {
+	switch c := c.(type) {
+	case *constant.Int:
+		return c.X.Sign() == 0
+	case *constant.Vector:
+		for _, elem := range c.Elems {
+			if hasZero(elem) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasPoison reports whether the given constant, or any element of the given
+// vector constant, is poison.
+func hasPoison(c constant.Constant) bool {
+	switch c := c.(type) {
+	case *constant.Poison:
+		return true
+	case *constant.Vector:
+		for _, elem := range c.Elems {
+			if hasPoison(elem) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// byvalType returns the type of the value passed by value to the given
+// parameter, and reports whether the parameter has the byval attribute. The
+// type is given by the byval attribute, or the element type of the parameter
+// pointer type.
+func byvalType(param *ir.Param) (types.Type, bool) {
+	for _, attr := range param.Attrs {
+		b, ok := attr.(ir.Byval)
+		if !ok {
+			continue
+		}
+		if b.Typ != nil {
+			return b.Typ, true
+		}
+		if t, ok := param.Typ.(*types.PointerType); ok && t.ElemType != nil {
+			return t.ElemType, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,270 @@
+// Package interp implements an interpreter of LLVM IR modules.
+//
+// The interpreter executes the functions of a module using a byte-addressed
+// memory model, in which global variables, stack allocations and heap
+// allocations are laid out according to the data layout of the module.
+// Function declarations are resolved to host functions implemented in Go,
+// which are registered by name in the host function table of the
+// interpreter.
+package interp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/triple"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/pkg/errors"
+)
+
+// maxDepth is the maximum call depth of interpreted functions.
+const maxDepth = 10000
+
+// Interpreter is an interpreter of an LLVM IR module.
+type Interpreter struct {
+	// Module being interpreted.
+	Module *ir.Module
+	// Memory of the interpreter.
+	Mem *Memory
+	// Host functions by function name, called in place of function declarations
+	// of the module (e.g. printf, malloc).
+	Host map[string]HostFunc
+
+	// Data layout of the module.
+	layout *layout
+	// Memory address of each global variable and function.
+	addrs map[value.Value]uint64
+	// Function of each function address.
+	funcs map[uint64]*ir.Func
+	// Current call depth.
+	depth int
+}
+
+// HostFunc is a function implemented by the host, which is called with the
+// runtime values of the arguments of a call to the corresponding function
+// declaration. The returned value is ignored if the function returns void.
+type HostFunc func(in *Interpreter, args []constant.Constant) (constant.Constant, error)
+
+// Exception is the error returned when an exception is unwound out of an
+// interpreted function; either through a resume terminator or by a host
+// function returning an *Exception. Exceptions unwinding out of invoke
+// terminators transfer control to the unwind destination, where the
+// landingpad instruction evaluates to Value.
+type Exception struct {
+	// Exception value (e.g. { ptr, i32 } of a C++ exception).
+	Value constant.Constant
+}
+
+// Error returns a string representation of the exception.
+func (e *Exception) Error() string {
+	return fmt.Sprintf("unhandled exception %v", e.Value)
+}
+
+// New returns a new interpreter of the given module, with memory allocated and
+// initialized for the global variables of the module.
+func New(m *ir.Module) (*Interpreter, error) {
+	// Use the default data layout of the target if the module has no data
+	// layout.
+	dataLayout := m.DataLayout
+	if dataLayout == "" && m.TargetTriple != "" {
+		dataLayout = triple.Parse(triple.Normalize(m.TargetTriple)).DataLayout()
+	}
+	l, err := parseLayout(dataLayout)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	in := &Interpreter{
+		Module: m,
+		Mem:    newMemory(),
+		Host:   make(map[string]HostFunc),
+		layout: l,
+		addrs:  make(map[value.Value]uint64),
+		funcs:  make(map[uint64]*ir.Func),
+	}
+	if err := in.initGlobals(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return in, nil
+}
+
+// initGlobals allocates memory for the global variables and functions of the
+// module, and initializes the global variables.
+func (in *Interpreter) initGlobals() error {
+	m := in.Module
+	for _, f := range m.Funcs {
+		a := in.Mem.alloc(0, 1, allocFunc)
+		in.addrs[f] = a.addr
+		in.funcs[a.addr] = f
+	}
+	allocs := make(map[*ir.Global]*allocation)
+	for _, g := range m.Globals {
+		align := uint64(g.Align)
+		if a := in.layout.ABIAlignOf(g.ContentType); a > align {
+			align = a
+		}
+		a := in.Mem.alloc(in.layout.AllocSizeOf(g.ContentType), align, allocGlobal)
+		in.addrs[g] = a.addr
+		allocs[g] = a
+	}
+	for _, alias := range m.Aliases {
+		x, err := in.evalConst(alias.Aliasee)
+		if err != nil {
+			return errors.Wrapf(err, "unable to evaluate aliasee of %s", alias.Ident())
+		}
+		if in.addrs[alias], err = addr(x); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	for _, ifunc := range m.IFuncs {
+		resolver, err := in.evalConst(ifunc.Resolver)
+		if err != nil {
+			return errors.Wrapf(err, "unable to evaluate resolver of %s", ifunc.Ident())
+		}
+		f, err := in.funcOf(resolver)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		x, err := in.Call(f)
+		if err != nil {
+			return errors.Wrapf(err, "unable to resolve %s", ifunc.Ident())
+		}
+		if in.addrs[ifunc], err = addr(x); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	for _, g := range m.Globals {
+		a := allocs[g]
+		if g.Init != nil {
+			init, err := in.evalConst(g.Init)
+			if err != nil {
+				return errors.Wrapf(err, "unable to evaluate initializer of %s", g.Ident())
+			}
+			buf, err := in.layout.encode(g.ContentType, init)
+			if err != nil {
+				return errors.Wrapf(err, "unable to encode initializer of %s", g.Ident())
+			}
+			copy(a.data, buf)
+		}
+		a.readOnly = g.Immutable
+	}
+	return nil
+}
+
+// Call calls the given function with the given arguments, and returns the
+// return value of the function; or nil if the function returns void.
+func (in *Interpreter) Call(f *ir.Func, args ...constant.Constant) (constant.Constant, error) {
+	if len(args) < len(f.Sig.Params) || (len(args) > len(f.Sig.Params) && !f.Sig.Variadic) {
+		return nil, errors.Errorf("invalid number of arguments in call to %s; expected %d, got %d", f.Ident(), len(f.Sig.Params), len(args))
+	}
+	if len(f.Blocks) == 0 {
+		return in.callExternal(f, args)
+	}
+	if in.depth >= maxDepth {
+		return nil, errors.Errorf("call stack overflow in call to %s", f.Ident())
+	}
+	in.depth++
+	defer func() { in.depth-- }()
+	fr := &frame{in: in, f: f, locals: make(map[value.Value]constant.Constant)}
+	defer fr.release()
+	for i, param := range f.Params {
+		arg := args[i]
+		if typ, ok := byvalType(param); ok {
+			// Byval arguments are passed as a private copy of the pointed-to
+			// value.
+			var err error
+			if arg, err = fr.copyByval(arg, typ); err != nil {
+				return nil, errors.Wrapf(err, "unable to copy byval argument %s of %s", param.Ident(), f.Ident())
+			}
+		}
+		fr.locals[param] = arg
+	}
+	x, err := fr.run()
+	if err != nil {
+		if _, ok := err.(*Exception); ok {
+			return nil, err
+		}
+		return nil, errors.WithMessage(err, f.Ident())
+	}
+	return x, nil
+}
+
+// callExternal calls the given function declaration; either an intrinsic
+// function or a host function.
+func (in *Interpreter) callExternal(f *ir.Func, args []constant.Constant) (constant.Constant, error) {
+	name := f.Name()
+	if strings.HasPrefix(name, "llvm.") {
+		return in.callIntrinsic(f, args)
+	}
+	host, ok := in.Host[name]
+	if !ok {
+		return nil, errors.Errorf("call to undefined function %s", f.Ident())
+	}
+	x, err := host(in, args)
+	if err != nil {
+		if _, ok := err.(*Exception); ok {
+			return nil, err
+		}
+		return nil, errors.WithMessage(err, f.Ident())
+	}
+	if types.Equal(f.Sig.RetType, types.Void) {
+		return nil, nil
+	}
+	if x == nil {
+		return nil, errors.Errorf("missing return value of host function %s", f.Ident())
+	}
+	// Host functions may return pointers of any pointer type.
+	if p, ok := x.(*Pointer); ok {
+		if t, ok := f.Sig.RetType.(*types.PointerType); ok {
+			return NewPointer(t, p.Addr), nil
+		}
+	}
+	return concrete(x), nil
+}
+
+// Load loads a runtime value of the given type from memory at the given
+// address.
+func (in *Interpreter) Load(addr uint64, typ types.Type) (constant.Constant, error) {
+	buf, err := in.Mem.Read(addr, in.layout.SizeOf(typ))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return in.layout.decode(typ, buf)
+}
+
+// Store stores the given runtime value to memory at the given address.
+func (in *Interpreter) Store(addr uint64, x constant.Constant) error {
+	buf, err := in.layout.encode(x.Type(), x)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return in.Mem.Write(addr, buf)
+}
+
+// SizeOf returns the allocation size in bytes of the given type, according to
+// the data layout of the module.
+func (in *Interpreter) SizeOf(typ types.Type) uint64 {
+	return in.layout.AllocSizeOf(typ)
+}
+
+// AddrOf returns the memory address of the given global variable or function.
+func (in *Interpreter) AddrOf(v value.Value) (uint64, bool) {
+	a, ok := in.addrs[v]
+	return a, ok
+}
+
+// funcOf returns the function at the address of the given pointer runtime
+// value.
+func (in *Interpreter) funcOf(callee constant.Constant) (*ir.Func, error) {
+	a, err := addr(callee)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	f, ok := in.funcs[a]
+	if !ok {
+		return nil, errors.Errorf("call of non-function address 0x%X", a)
+	}
+	return f, nil
+}
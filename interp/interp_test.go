@@ -0,0 +1,457 @@
+package interp_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/interp"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestCall(t *testing.T) {
+	golden := []struct {
+		// Test name.
+		name string
+		// LLVM IR module containing the @main function.
+		src string
+		// Expected return value of @main.
+		want string
+	}{
+		{
+			name: "factorial",
+			src: `
+define i32 @fact(i32 %n) {
+entry:
+	%cmp = icmp sle i32 %n, 1
+	br i1 %cmp, label %base, label %rec
+
+base:
+	ret i32 1
+
+rec:
+	%m = sub i32 %n, 1
+	%r = call i32 @fact(i32 %m)
+	%x = mul i32 %n, %r
+	ret i32 %x
+}
+
+define i32 @main() {
+	%r = call i32 @fact(i32 10)
+	ret i32 %r
+}`,
+			want: "i32 3628800",
+		},
+		{
+			name: "loop",
+			src: `
+define i64 @main() {
+entry:
+	br label %loop
+
+loop:
+	%i = phi i64 [ 0, %entry ], [ %i.next, %loop ]
+	%sum = phi i64 [ 0, %entry ], [ %sum.next, %loop ]
+	%sum.next = add i64 %sum, %i
+	%i.next = add i64 %i, 1
+	%done = icmp eq i64 %i.next, 101
+	br i1 %done, label %exit, label %loop
+
+exit:
+	ret i64 %sum.next
+}`,
+			want: "i64 5050",
+		},
+		{
+			name: "memory",
+			src: `
+%pair = type { i8, i32 }
+
+@g = global [4 x i32] [i32 1, i32 2, i32 3, i32 4]
+@p = global ptr getelementptr ([4 x i32], ptr @g, i64 0, i64 2)
+
+define i32 @main() {
+	%s = alloca %pair
+	%f = getelementptr %pair, ptr %s, i32 0, i32 1
+	store i32 40, ptr %f
+	%q = load ptr, ptr @p
+	%x = load i32, ptr %q
+	%y = load i32, ptr %f
+	%z = add i32 %x, %y
+	%a = ptrtoint ptr %f to i64
+	%b = ptrtoint ptr %s to i64
+	%off = sub i64 %a, %b
+	%off32 = trunc i64 %off to i32
+	%r = add i32 %z, %off32
+	ret i32 %r
+}`,
+			want: "i32 47",
+		},
+		{
+			name: "bytes",
+			src: `
+target datalayout = "E"
+
+define i8 @main() {
+	%p = alloca i32
+	store i32 16909060, ptr %p
+	%b = getelementptr i8, ptr %p, i64 1
+	%x = load i8, ptr %b
+	ret i8 %x
+}`,
+			want: "i8 2",
+		},
+		{
+			name: "switch",
+			src: `
+define i32 @f(i32 %x) {
+entry:
+	switch i32 %x, label %default [
+		i32 1, label %one
+		i32 2, label %two
+	]
+
+one:
+	ret i32 10
+
+two:
+	ret i32 20
+
+default:
+	ret i32 0
+}
+
+define i32 @main() {
+	%a = call i32 @f(i32 1)
+	%b = call i32 @f(i32 2)
+	%c = call i32 @f(i32 3)
+	%ab = add i32 %a, %b
+	%r = add i32 %ab, %c
+	ret i32 %r
+}`,
+			want: "i32 30",
+		},
+		{
+			name: "vector",
+			src: `
+define i32 @main() {
+	%v = insertelement <4 x i32> zeroinitializer, i32 7, i32 1
+	%w = add <4 x i32> %v, <i32 1, i32 2, i32 3, i32 4>
+	%s = shufflevector <4 x i32> %w, <4 x i32> undef, <4 x i32> <i32 1, i32 1, i32 3, i32 0>
+	%p = alloca <4 x i32>
+	store <4 x i32> %s, ptr %p
+	%q = getelementptr i32, ptr %p, i64 2
+	%x = load i32, ptr %q
+	%y = extractelement <4 x i32> %s, i32 0
+	%r = mul i32 %x, %y
+	ret i32 %r
+}`,
+			want: "i32 36",
+		},
+		{
+			name: "float",
+			src: `
+define double @main() {
+	%x = sitofp i32 3 to double
+	%y = fmul double %x, 1.5
+	%z = call double @llvm.sqrt.f64(double 16.0)
+	%r = fadd double %y, %z
+	ret double %r
+}
+
+declare double @llvm.sqrt.f64(double)`,
+			want: "double 8.5",
+		},
+		{
+			name: "aggregate",
+			src: `
+define i32 @main() {
+	%s = insertvalue { i32, [2 x i32] } undef, i32 5, 1, 1
+	%t = insertvalue { i32, [2 x i32] } %s, i32 2, 0
+	%a = extractvalue { i32, [2 x i32] } %t, 1, 1
+	%b = extractvalue { i32, [2 x i32] } %t, 0
+	%r = sdiv i32 %a, %b
+	ret i32 %r
+}`,
+			want: "i32 2",
+		},
+		{
+			name: "indirect call",
+			src: `
+@fp = global ptr @twice
+
+define i32 @twice(i32 %x) {
+	%r = shl i32 %x, 1
+	ret i32 %r
+}
+
+define i32 @main() {
+	%f = load ptr, ptr @fp
+	%r = call i32 %f(i32 21)
+	ret i32 %r
+}`,
+			want: "i32 42",
+		},
+		{
+			name: "memcpy",
+			src: `
+@s = constant [6 x i8] c"hello\00"
+
+define i8 @main() {
+	%buf = alloca [6 x i8]
+	call void @llvm.memset.p0.i64(ptr %buf, i8 0, i64 6, i1 false)
+	call void @llvm.memcpy.p0.p0.i64(ptr %buf, ptr @s, i64 3, i1 false)
+	%p = getelementptr [6 x i8], ptr %buf, i64 0, i64 2
+	%x = load i8, ptr %p
+	ret i8 %x
+}
+
+declare void @llvm.memset.p0.i64(ptr, i8, i64, i1)
+declare void @llvm.memcpy.p0.p0.i64(ptr, ptr, i64, i1)`,
+			want: "i8 108",
+		},
+		{
+			name: "atomic",
+			src: `
+define i32 @main() {
+	%p = alloca i32
+	store i32 5, ptr %p
+	%old = atomicrmw add ptr %p, i32 3 seq_cst
+	%pair = cmpxchg ptr %p, i32 8, i32 100 seq_cst seq_cst
+	%ok = extractvalue { i32, i1 } %pair, 1
+	%v = load i32, ptr %p
+	%r = select i1 %ok, i32 %v, i32 %old
+	ret i32 %r
+}`,
+			want: "i32 100",
+		},
+		{
+			name: "byval",
+			src: `
+%S = type { i32 }
+
+define void @bump(ptr byval(%S) %s) {
+	%p = getelementptr %S, ptr %s, i32 0, i32 0
+	store i32 99, ptr %p
+	ret void
+}
+
+define i32 @main() {
+	%s = alloca %S
+	%p = getelementptr %S, ptr %s, i32 0, i32 0
+	store i32 7, ptr %p
+	call void @bump(ptr byval(%S) %s)
+	%v = load i32, ptr %p
+	ret i32 %v
+}`,
+			want: "i32 7",
+		},
+	}
+	for _, g := range golden {
+		m, err := asm.ParseString(g.name+".ll", g.src)
+		if err != nil {
+			t.Errorf("%q: unable to parse module; %+v", g.name, err)
+			continue
+		}
+		in, err := interp.New(m)
+		if err != nil {
+			t.Errorf("%q: unable to create interpreter; %+v", g.name, err)
+			continue
+		}
+		got, err := in.Call(findFunc(m, "main"))
+		if err != nil {
+			t.Errorf("%q: unable to call @main; %v", g.name, err)
+			continue
+		}
+		if got.String() != g.want {
+			t.Errorf("%q: return value mismatch; expected %q, got %q", g.name, g.want, got)
+		}
+	}
+}
+
+func TestHost(t *testing.T) {
+	const src = `
+declare ptr @malloc(i64)
+declare void @free(ptr)
+declare void @record(i32)
+
+define void @main() {
+	%p = call ptr @malloc(i64 4)
+	store i32 42, ptr %p
+	%x = load i32, ptr %p
+	call void @record(i32 %x)
+	call void @free(ptr %p)
+	ret void
+}`
+	m, err := asm.ParseString("host.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	in, err := interp.New(m)
+	if err != nil {
+		t.Fatalf("unable to create interpreter; %+v", err)
+	}
+	var recorded []string
+	in.Host["malloc"] = func(in *interp.Interpreter, args []constant.Constant) (constant.Constant, error) {
+		n := args[0].(*constant.Int).X.Uint64()
+		return interp.NewPointer(types.NewPointer(types.I8), in.Mem.Alloc(n, 16)), nil
+	}
+	in.Host["free"] = func(in *interp.Interpreter, args []constant.Constant) (constant.Constant, error) {
+		return nil, in.Mem.Free(args[0].(*interp.Pointer).Addr)
+	}
+	in.Host["record"] = func(in *interp.Interpreter, args []constant.Constant) (constant.Constant, error) {
+		recorded = append(recorded, args[0].String())
+		return nil, nil
+	}
+	if _, err := in.Call(findFunc(m, "main")); err != nil {
+		t.Fatalf("unable to call @main; %v", err)
+	}
+	if got, want := strings.Join(recorded, ","), "i32 42"; got != want {
+		t.Errorf("recorded values mismatch; expected %q, got %q", want, got)
+	}
+}
+
+func TestException(t *testing.T) {
+	const src = `
+declare void @throw(i32)
+
+define void @f(i32 %x) {
+	call void @throw(i32 %x)
+	ret void
+}
+
+define i32 @main() personality ptr null {
+entry:
+	invoke void @f(i32 7) to label %ok unwind label %lpad
+
+ok:
+	ret i32 0
+
+lpad:
+	%exc = landingpad { ptr, i32 } cleanup
+	%sel = extractvalue { ptr, i32 } %exc, 1
+	ret i32 %sel
+}`
+	m, err := asm.ParseString("exception.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	in, err := interp.New(m)
+	if err != nil {
+		t.Fatalf("unable to create interpreter; %+v", err)
+	}
+	excType := types.NewStruct(types.NewPointer(types.I8), types.I32)
+	in.Host["throw"] = func(in *interp.Interpreter, args []constant.Constant) (constant.Constant, error) {
+		return nil, &interp.Exception{Value: constant.NewStruct(excType, constant.NewNull(types.NewPointer(types.I8)), args[0])}
+	}
+	got, err := in.Call(findFunc(m, "main"))
+	if err != nil {
+		t.Fatalf("unable to call @main; %v", err)
+	}
+	if want := "i32 7"; got.String() != want {
+		t.Errorf("return value mismatch; expected %q, got %q", want, got)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	golden := []struct {
+		// Test name.
+		name string
+		// LLVM IR module containing the @main function.
+		src string
+		// Expected error message substring.
+		want string
+	}{
+		{
+			name: "division by zero",
+			src: `
+define i32 @main() {
+	%x = udiv i32 1, 0
+	ret i32 %x
+}`,
+			want: "@main: %x = udiv i32 1, 0: integer division by zero",
+		},
+		{
+			name: "out of bounds",
+			src: `
+define i32 @main() {
+	%p = alloca i32
+	%q = getelementptr i32, ptr %p, i64 1
+	%x = load i32, ptr %q
+	ret i32 %x
+}`,
+			want: "out of bounds",
+		},
+		{
+			name: "null",
+			src: `
+define void @main() {
+	store i32 0, ptr null
+	ret void
+}`,
+			want: "null pointer dereference",
+		},
+		{
+			name: "constant",
+			src: `
+@c = constant i32 1
+
+define void @main() {
+	store i32 0, ptr @c
+	ret void
+}`,
+			want: "read-only memory",
+		},
+		{
+			name: "undefined function",
+			src: `
+declare void @missing()
+
+define void @main() {
+	call void @missing()
+	ret void
+}`,
+			want: "call to undefined function @missing",
+		},
+		{
+			name: "unreachable",
+			src: `
+define void @main() {
+	unreachable
+}`,
+			want: "unreachable executed",
+		},
+	}
+	for _, g := range golden {
+		m, err := asm.ParseString(g.name+".ll", g.src)
+		if err != nil {
+			t.Errorf("%q: unable to parse module; %+v", g.name, err)
+			continue
+		}
+		in, err := interp.New(m)
+		if err != nil {
+			t.Errorf("%q: unable to create interpreter; %+v", g.name, err)
+			continue
+		}
+		_, err = in.Call(findFunc(m, "main"))
+		if err == nil {
+			t.Errorf("%q: expected error, got nil", g.name)
+			continue
+		}
+		if !strings.Contains(err.Error(), g.want) {
+			t.Errorf("%q: error mismatch; expected %q, got %q", g.name, g.want, err)
+		}
+	}
+}
+
+// findFunc returns the function with the given name in m.
+func findFunc(m *ir.Module, name string) *ir.Func {
+	for _, f := range m.Funcs {
+		if f.Name() == name {
+			return f
+		}
+	}
+	panic("unable to locate function " + name)
+}
@@ -0,0 +1,183 @@
+package interp
+
+import (
+	"math"
+	"strings"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/pkg/errors"
+)
+
+// callIntrinsic calls the given intrinsic function with the given arguments.
+func (in *Interpreter) callIntrinsic(f *ir.Func, args []constant.Constant) (constant.Constant, error) {
+	switch name := intrinsicName(f.Name()); name {
+	// Memory intrinsics.
+	case "llvm.memcpy", "llvm.memmove":
+		// memcpy(dst, src, len, isvolatile)
+		dst, src, n, err := memArgs(args)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if n == 0 {
+			return nil, nil
+		}
+		buf, err := in.Mem.Read(src, n)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return nil, in.Mem.Write(dst, buf)
+	case "llvm.memset":
+		// memset(dst, val, len, isvolatile)
+		dst, err := addr(args[0])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		val, err := intArg(args[1])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		n, err := intArg(args[2])
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if n == 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n)
+		for i := range buf {
+			buf[i] = byte(val)
+		}
+		return nil, in.Mem.Write(dst, buf)
+	// Intrinsics without effect on execution.
+	case "llvm.lifetime.start", "llvm.lifetime.end", "llvm.dbg.declare", "llvm.dbg.value", "llvm.dbg.addr", "llvm.dbg.label", "llvm.assume", "llvm.donothing", "llvm.experimental.noalias.scope.decl", "llvm.invariant.end":
+		return nil, nil
+	case "llvm.expect", "llvm.invariant.start", "llvm.launder.invariant.group", "llvm.strip.invariant.group", "llvm.ssa.copy":
+		if types.Equal(f.Sig.RetType, args[0].Type()) {
+			return args[0], nil
+		}
+		return zeroValue(f.Sig.RetType), nil
+	case "llvm.trap", "llvm.debugtrap":
+		return nil, errors.Errorf("trap")
+	// Integer intrinsics.
+	case "llvm.smax":
+		return in.apply(constant.NewSelect(constant.NewICmp(enum.IPredSGT, args[0], args[1]), args[0], args[1]))
+	case "llvm.smin":
+		return in.apply(constant.NewSelect(constant.NewICmp(enum.IPredSLT, args[0], args[1]), args[0], args[1]))
+	case "llvm.umax":
+		return in.apply(constant.NewSelect(constant.NewICmp(enum.IPredUGT, args[0], args[1]), args[0], args[1]))
+	case "llvm.umin":
+		return in.apply(constant.NewSelect(constant.NewICmp(enum.IPredULT, args[0], args[1]), args[0], args[1]))
+	case "llvm.abs":
+		// abs(x, is_int_min_poison)
+		x := args[0]
+		zero := zeroValue(x.Type())
+		return in.apply(constant.NewSelect(constant.NewICmp(enum.IPredSLT, x, zero), constant.NewSub(zero, x), x))
+	// Floating-point intrinsics.
+	case "llvm.fabs", "llvm.sqrt", "llvm.floor", "llvm.ceil", "llvm.trunc", "llvm.round", "llvm.rint", "llvm.nearbyint", "llvm.sin", "llvm.cos", "llvm.exp", "llvm.log":
+		return mapLanes(args[0], args[0].Type(), func(x constant.Constant, to types.Type) (constant.Constant, error) {
+			return mathFunc(name, x)
+		})
+	}
+	return nil, errors.Errorf("support for intrinsic %s not yet implemented", f.Ident())
+}
+
+// intrinsicName returns the name of the given intrinsic function, without type
+// suffixes of overloaded intrinsics (e.g. "llvm.memcpy" of
+// "llvm.memcpy.p0.p0.i64").
+func intrinsicName(name string) string {
+	for _, prefix := range intrinsics {
+		if name == prefix || strings.HasPrefix(name, prefix+".") {
+			return prefix
+		}
+	}
+	return name
+}
+
+// intrinsics specifies the names of supported overloaded intrinsics; longer
+// names precede their prefixes.
+var intrinsics = []string{
+	"llvm.memcpy",
+	"llvm.memmove",
+	"llvm.memset",
+	"llvm.lifetime.start",
+	"llvm.lifetime.end",
+	"llvm.invariant.start",
+	"llvm.invariant.end",
+	"llvm.launder.invariant.group",
+	"llvm.strip.invariant.group",
+	"llvm.ssa.copy",
+	"llvm.expect",
+	"llvm.smax",
+	"llvm.smin",
+	"llvm.umax",
+	"llvm.umin",
+	"llvm.abs",
+	"llvm.fabs",
+	"llvm.sqrt",
+	"llvm.floor",
+	"llvm.ceil",
+	"llvm.trunc",
+	"llvm.round",
+	"llvm.rint",
+	"llvm.nearbyint",
+	"llvm.sin",
+	"llvm.cos",
+	"llvm.exp",
+	"llvm.log",
+}
+
+// memArgs returns the destination address, source address and length
+// arguments of a memcpy or memmove intrinsic.
+func memArgs(args []constant.Constant) (dst, src, n uint64, err error) {
+	if dst, err = addr(args[0]); err != nil {
+		return 0, 0, 0, errors.WithStack(err)
+	}
+	if src, err = addr(args[1]); err != nil {
+		return 0, 0, 0, errors.WithStack(err)
+	}
+	if n, err = intArg(args[2]); err != nil {
+		return 0, 0, 0, errors.WithStack(err)
+	}
+	return dst, src, n, nil
+}
+
+// mathFunc returns the result of the given floating-point math intrinsic
+// applied to x.
+func mathFunc(name string, x constant.Constant) (constant.Constant, error) {
+	f, ok := x.(*constant.Float)
+	if !ok {
+		return nil, errors.Errorf("invalid floating-point value %v", x)
+	}
+	y, err := floatValue(f)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	switch name {
+	case "llvm.fabs":
+		y = math.Abs(y)
+	case "llvm.sqrt":
+		y = math.Sqrt(y)
+	case "llvm.floor":
+		y = math.Floor(y)
+	case "llvm.ceil":
+		y = math.Ceil(y)
+	case "llvm.trunc":
+		y = math.Trunc(y)
+	case "llvm.round":
+		y = math.Round(y)
+	case "llvm.rint", "llvm.nearbyint":
+		y = math.RoundToEven(y)
+	case "llvm.sin":
+		y = math.Sin(y)
+	case "llvm.cos":
+		y = math.Cos(y)
+	case "llvm.exp":
+		y = math.Exp(y)
+	case "llvm.log":
+		y = math.Log(y)
+	}
+	return newFloat(f.Typ, y)
+}
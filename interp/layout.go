@@ -0,0 +1,22 @@
+package interp
+
+import (
+	"github.com/llir/llvm/ir/datalayout"
+	"github.com/pkg/errors"
+)
+
+// layout specifies the sizes and alignments of types in memory, as given by
+// the data layout string of a module, and implements the encoding of runtime
+// values in memory.
+type layout struct {
+	*datalayout.DataLayout
+}
+
+// parseLayout parses the given data layout string.
+func parseLayout(s string) (*layout, error) {
+	dl, err := datalayout.Parse(s)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &layout{DataLayout: dl}, nil
+}
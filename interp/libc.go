@@ -0,0 +1,419 @@
+package interp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/pkg/errors"
+)
+
+// Exit is the error returned when an interpreted program terminates through a
+// call to exit or abort.
+type Exit struct {
+	// Exit status code.
+	Code int
+}
+
+// Error returns a string representation of the program termination.
+func (e *Exit) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// bytePtr is the pointer type of values returned by libc host functions.
+var bytePtr = types.NewPointer(types.I8)
+
+// AddLibc registers host functions for a subset of the C standard library,
+// writing standard output and standard error to the given writers. If the
+// module declares the stdout and stderr global variables, they are initialized
+// to refer to the respective output streams, for use with fprintf and fputs.
+//
+// The following functions are provided.
+//
+//   - output: printf, fprintf, puts, fputs, putchar
+//   - memory allocation: malloc, calloc, realloc, free
+//   - memory and strings: memcpy, memmove, memset, memcmp, strlen, strcmp
+//   - program termination: exit, abort
+func (in *Interpreter) AddLibc(stdout, stderr io.Writer) {
+	streams := make(map[uint64]io.Writer)
+	for _, g := range in.Module.Globals {
+		var w io.Writer
+		switch g.Name() {
+		case "stdout":
+			w = stdout
+		case "stderr":
+			w = stderr
+		default:
+			continue
+		}
+		if !types.IsPointer(g.ContentType) {
+			continue
+		}
+		stream := in.Mem.alloc(1, 1, allocHeap).addr
+		streams[stream] = w
+		if err := in.Store(in.addrs[g], NewPointer(g.ContentType.(*types.PointerType), stream)); err != nil {
+			panic(fmt.Errorf("unable to initialize %s; %v", g.Ident(), err))
+		}
+	}
+	// stream returns the output stream of the given FILE pointer.
+	stream := func(c constant.Constant) (io.Writer, error) {
+		a, err := addr(c)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		w, ok := streams[a]
+		if !ok {
+			return nil, errors.Errorf("invalid output stream 0x%X", a)
+		}
+		return w, nil
+	}
+	host := map[string]HostFunc{
+		// Output.
+		"printf": func(in *Interpreter, args []constant.Constant) (constant.Constant, error) {
+			return in.fprintf(stdout, args)
+		},
+		"fprintf": func(in *Interpreter, args []constant.Constant) (constant.Constant, error) {
+			w, err := stream(args[0])
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			return in.fprintf(w, args[1:])
+		},
+		"puts": func(in *Interpreter, args []constant.Constant) (constant.Constant, error) {
+			s, err := in.cString(args[0])
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			return write(stdout, s+"\n")
+		},
+		"fputs": func(in *Interpreter, args []constant.Constant) (constant.Constant, error) {
+			s, err := in.cString(args[0])
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			w, err := stream(args[1])
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			return write(w, s)
+		},
+		"putchar": func(in *Interpreter, args []constant.Constant) (constant.Constant, error) {
+			c, err := intArg(args[0])
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			if _, err := stdout.Write([]byte{byte(c)}); err != nil {
+				return nil, errors.WithStack(err)
+			}
+			return newInt(types.I32, big.NewInt(int64(byte(c)))), nil
+		},
+		// Memory allocation.
+		"malloc": func(in *Interpreter, args []constant.Constant) (constant.Constant, error) {
+			n, err := intArg(args[0])
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			return NewPointer(bytePtr, in.Mem.Alloc(n, 16)), nil
+		},
+		"calloc": func(in *Interpreter, args []constant.Constant) (constant.Constant, error) {
+			n, err := intArg(args[0])
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			size, err := intArg(args[1])
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			return NewPointer(bytePtr, in.Mem.Alloc(n*size, 16)), nil
+		},
+		"realloc": func(in *Interpreter, args []constant.Constant) (constant.Constant, error) {
+			old, err := addr(args[0])
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			n, err := intArg(args[1])
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			p := in.Mem.Alloc(n, 16)
+			if old != 0 {
+				a := in.Mem.lookup(old)
+				if a == nil || a.addr != old || a.kind != allocHeap || a.freed {
+					return nil, errors.Errorf("invalid realloc of address 0x%X", old)
+				}
+				buf := a.data
+				if uint64(len(buf)) > n {
+					buf = buf[:n]
+				}
+				if err := in.Mem.Write(p, buf); err != nil {
+					return nil, errors.WithStack(err)
+				}
+				if err := in.Mem.Free(old); err != nil {
+					return nil, errors.WithStack(err)
+				}
+			}
+			return NewPointer(bytePtr, p), nil
+		},
+		"free": func(in *Interpreter, args []constant.Constant) (constant.Constant, error) {
+			a, err := addr(args[0])
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			return nil, in.Mem.Free(a)
+		},
+		// Memory and strings.
+		"memcpy":  libcMemcpy,
+		"memmove": libcMemcpy,
+		"memset": func(in *Interpreter, args []constant.Constant) (constant.Constant, error) {
+			dst, err := addr(args[0])
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			c, err := intArg(args[1])
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			n, err := intArg(args[2])
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			if n > 0 {
+				if err := in.Mem.Write(dst, bytes.Repeat([]byte{byte(c)}, int(n))); err != nil {
+					return nil, errors.WithStack(err)
+				}
+			}
+			return args[0], nil
+		},
+		"memcmp": func(in *Interpreter, args []constant.Constant) (constant.Constant, error) {
+			a, b, n, err := memArgs(args)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			x, err := in.Mem.Read(a, n)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			y, err := in.Mem.Read(b, n)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			return newInt(types.I32, big.NewInt(int64(bytes.Compare(x, y)))), nil
+		},
+		"strlen": func(in *Interpreter, args []constant.Constant) (constant.Constant, error) {
+			s, err := in.cString(args[0])
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			return newInt(types.I64, big.NewInt(int64(len(s)))), nil
+		},
+		"strcmp": func(in *Interpreter, args []constant.Constant) (constant.Constant, error) {
+			x, err := in.cString(args[0])
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			y, err := in.cString(args[1])
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			return newInt(types.I32, big.NewInt(int64(strings.Compare(x, y)))), nil
+		},
+		// Program termination.
+		"exit": func(in *Interpreter, args []constant.Constant) (constant.Constant, error) {
+			code, err := intArg(args[0])
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			return nil, &Exit{Code: int(int32(code))}
+		},
+		"abort": func(in *Interpreter, args []constant.Constant) (constant.Constant, error) {
+			return nil, &Exit{Code: 134}
+		},
+	}
+	for name, f := range host {
+		in.Host[name] = f
+	}
+}
+
+// libcMemcpy implements memcpy and memmove.
+func libcMemcpy(in *Interpreter, args []constant.Constant) (constant.Constant, error) {
+	dst, src, n, err := memArgs(args)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if n > 0 {
+		buf, err := in.Mem.Read(src, n)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if err := in.Mem.Write(dst, buf); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return args[0], nil
+}
+
+// write writes s to w, and returns the number of bytes written as an i32
+// runtime value.
+func write(w io.Writer, s string) (constant.Constant, error) {
+	n, err := io.WriteString(w, s)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return newInt(types.I32, big.NewInt(int64(n))), nil
+}
+
+// cString returns the NUL-terminated string at the address of the given
+// pointer runtime value.
+func (in *Interpreter) cString(c constant.Constant) (string, error) {
+	a, err := addr(c)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return in.Mem.ReadCString(a)
+}
+
+// fprintf writes the output of the printf format string and arguments of args
+// to w, and returns the number of bytes written.
+func (in *Interpreter) fprintf(w io.Writer, args []constant.Constant) (constant.Constant, error) {
+	format, err := in.cString(args[0])
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	s, err := in.sprintf(format, args[1:])
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return write(w, s)
+}
+
+// sprintf returns the output of the given printf format string and arguments.
+//
+// Conversion specifications have the form %[flags][width][.precision][length]
+// conversion, with the conversions d, i, u, o, x, X, c, s, p, f, F, e, E, g, G
+// and %.
+func (in *Interpreter) sprintf(format string, args []constant.Constant) (string, error) {
+	var out strings.Builder
+	next := func() (constant.Constant, error) {
+		if len(args) == 0 {
+			return nil, errors.Errorf("missing printf argument for format %q", format)
+		}
+		arg := args[0]
+		args = args[1:]
+		return arg, nil
+	}
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			out.WriteByte(format[i])
+			continue
+		}
+		// Parse conversion specification.
+		spec := "%"
+		j := i + 1
+		for j < len(format) && strings.IndexByte("-+ #0", format[j]) != -1 {
+			spec += format[j : j+1]
+			j++
+		}
+		// Width and precision; '*' takes the value from the arguments.
+		for _, prefix := range []string{"", "."} {
+			if prefix != "" {
+				if j >= len(format) || format[j] != '.' {
+					break
+				}
+				spec += "."
+				j++
+			}
+			if j < len(format) && format[j] == '*' {
+				arg, err := next()
+				if err != nil {
+					return "", errors.WithStack(err)
+				}
+				x, ok := arg.(*constant.Int)
+				if !ok {
+					return "", errors.Errorf("invalid printf width or precision %v", arg)
+				}
+				spec += signed(x).String()
+				j++
+				continue
+			}
+			for j < len(format) && '0' <= format[j] && format[j] <= '9' {
+				spec += format[j : j+1]
+				j++
+			}
+		}
+		// Length modifier.
+		length := ""
+		for j < len(format) && strings.IndexByte("hlzjtLq", format[j]) != -1 {
+			length += format[j : j+1]
+			j++
+		}
+		if j >= len(format) {
+			return "", errors.Errorf("incomplete printf conversion specification in format %q", format)
+		}
+		conv := format[j]
+		i = j
+		if conv == '%' {
+			out.WriteByte('%')
+			continue
+		}
+		arg, err := next()
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		switch conv {
+		case 'd', 'i', 'u', 'o', 'x', 'X', 'c':
+			x, ok := arg.(*constant.Int)
+			if !ok {
+				return "", errors.Errorf("invalid printf integer argument %v", arg)
+			}
+			switch length {
+			case "hh":
+				x = newInt(types.I8, x.X)
+			case "h":
+				x = newInt(types.I16, x.X)
+			}
+			switch conv {
+			case 'd', 'i':
+				fmt.Fprintf(&out, spec+"d", signed(x))
+			case 'u':
+				fmt.Fprintf(&out, spec+"d", unsigned(x))
+			case 'c':
+				fmt.Fprintf(&out, spec+"c", rune(byte(unsigned(x).Uint64())))
+			default:
+				fmt.Fprintf(&out, spec+string(conv), unsigned(x))
+			}
+		case 's':
+			s, err := in.cString(arg)
+			if err != nil {
+				return "", errors.WithStack(err)
+			}
+			fmt.Fprintf(&out, spec+"s", s)
+		case 'p':
+			a, err := addr(arg)
+			if err != nil {
+				return "", errors.WithStack(err)
+			}
+			fmt.Fprintf(&out, "0x%x", a)
+		case 'f', 'F', 'e', 'E', 'g', 'G':
+			f, ok := arg.(*constant.Float)
+			if !ok {
+				return "", errors.Errorf("invalid printf floating-point argument %v", arg)
+			}
+			x, err := floatValue(f)
+			if err != nil {
+				return "", errors.WithStack(err)
+			}
+			if (conv == 'g' || conv == 'G') && !strings.Contains(spec, ".") {
+				// Default precision of C.
+				spec += ".6"
+			}
+			fmt.Fprintf(&out, spec+string(conv), x)
+		default:
+			return "", errors.Errorf("support for printf conversion %%%c not yet implemented", conv)
+		}
+	}
+	return out.String(), nil
+}
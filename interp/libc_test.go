@@ -0,0 +1,147 @@
+package interp_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/interp"
+)
+
+func TestRunMain(t *testing.T) {
+	golden := []struct {
+		// Test name.
+		name string
+		// LLVM IR module containing the @main function.
+		src string
+		// Command line arguments.
+		args []string
+		// Expected standard output.
+		stdout string
+		// Expected standard error.
+		stderr string
+		// Expected exit status code.
+		code int
+	}{
+		{
+			name: "printf",
+			src: `
+@fmt = private constant [36 x i8] c"%d %5.2f %-4s| %x %c %s %05u %e %%\0A\00"
+@s = private constant [4 x i8] c"abc\00"
+@t = private constant [3 x i8] c"xy\00"
+
+declare i32 @printf(ptr, ...)
+
+define i32 @main() {
+	%n = call i32 (ptr, ...) @printf(ptr @fmt, i32 -12, double 3.14159, ptr @t, i32 255, i32 65, ptr @s, i32 42, double 1234.5)
+	ret i32 0
+}`,
+			stdout: "-12  3.14 xy  | ff A abc 00042 1.234500e+03 %\n",
+		},
+		{
+			name: "puts",
+			src: `
+@s = private constant [6 x i8] c"hello\00"
+@stderr = external global ptr
+@e = private constant [5 x i8] c"oops\00"
+
+declare i32 @puts(ptr)
+declare i32 @putchar(i32)
+declare i32 @fputs(ptr, ptr)
+declare i64 @strlen(ptr)
+
+define i32 @main() {
+	call i32 @puts(ptr @s)
+	call i32 @putchar(i32 33)
+	%stderr = load ptr, ptr @stderr
+	call i32 @fputs(ptr @e, ptr %stderr)
+	%n = call i64 @strlen(ptr @s)
+	%r = trunc i64 %n to i32
+	ret i32 %r
+}`,
+			stdout: "hello\n!",
+			stderr: "oops",
+			code:   5,
+		},
+		{
+			name: "heap",
+			src: `
+declare ptr @malloc(i64)
+declare ptr @realloc(ptr, i64)
+declare void @free(ptr)
+declare ptr @memset(ptr, i32, i64)
+declare ptr @memcpy(ptr, ptr, i64)
+declare i32 @memcmp(ptr, ptr, i64)
+
+define i32 @main() {
+	%p = call ptr @malloc(i64 8)
+	call ptr @memset(ptr %p, i32 7, i64 8)
+	%q = call ptr @realloc(ptr %p, i64 16)
+	%r = call ptr @malloc(i64 8)
+	call ptr @memcpy(ptr %r, ptr %q, i64 8)
+	%cmp = call i32 @memcmp(ptr %q, ptr %r, i64 8)
+	%x = load i8, ptr %r
+	%y = zext i8 %x to i32
+	%z = add i32 %y, %cmp
+	call void @free(ptr %q)
+	call void @free(ptr %r)
+	ret i32 %z
+}`,
+			code: 7,
+		},
+		{
+			name: "argv",
+			src: `
+declare i32 @puts(ptr)
+
+define i32 @main(i32 %argc, ptr %argv) {
+	%p = getelementptr ptr, ptr %argv, i32 1
+	%arg = load ptr, ptr %p
+	call i32 @puts(ptr %arg)
+	ret i32 %argc
+}`,
+			args:   []string{"prog", "foo", "bar"},
+			stdout: "foo\n",
+			code:   3,
+		},
+		{
+			name: "exit",
+			src: `
+declare void @exit(i32)
+
+define i32 @main() {
+	call void @exit(i32 3)
+	unreachable
+}`,
+			code: 3,
+		},
+	}
+	for _, g := range golden {
+		m, err := asm.ParseString(g.name+".ll", g.src)
+		if err != nil {
+			t.Errorf("%q: unable to parse module; %+v", g.name, err)
+			continue
+		}
+		in, err := interp.New(m)
+		if err != nil {
+			t.Errorf("%q: unable to create interpreter; %+v", g.name, err)
+			continue
+		}
+		stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+		in.AddLibc(stdout, stderr)
+		code, err := in.RunMain(g.args)
+		if err != nil {
+			t.Errorf("%q: unable to run @main; %v", g.name, err)
+			continue
+		}
+		if got := stdout.String(); got != g.stdout {
+			t.Errorf("%q: standard output mismatch; expected %q, got %q", g.name, g.stdout, got)
+		}
+		if got := stderr.String(); got != g.stderr {
+			t.Errorf("%q: standard error mismatch; expected %q, got %q", g.name, g.stderr, got)
+		}
+		if code != g.code {
+			t.Errorf("%q: exit status code mismatch; expected %d, got %d", g.name, g.code, code)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package interp
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/pkg/errors"
+)
+
+// RunMain runs the @main function of the module to completion with the given
+// command line arguments (including the program name), and returns the exit
+// status code of the program.
+//
+// The @main function may take no parameters, or argc and argv parameters. The
+// exit status code is the return value of @main, or the status code passed to
+// exit if the program terminates through a call to exit or abort.
+func (in *Interpreter) RunMain(args []string) (int, error) {
+	var main *ir.Func
+	for _, f := range in.Module.Funcs {
+		if f.Name() == "main" && len(f.Blocks) > 0 {
+			main = f
+			break
+		}
+	}
+	if main == nil {
+		return 0, errors.New("unable to locate definition of @main")
+	}
+	var params []constant.Constant
+	if len(main.Params) >= 2 {
+		argc, ok := main.Params[0].Typ.(*types.IntType)
+		if !ok {
+			return 0, errors.Errorf("invalid argc parameter type of @main; expected integer type, got %v", main.Params[0].Typ)
+		}
+		argvType, ok := main.Params[1].Typ.(*types.PointerType)
+		if !ok {
+			return 0, errors.Errorf("invalid argv parameter type of @main; expected pointer type, got %v", main.Params[1].Typ)
+		}
+		argv, err := in.newArgv(args)
+		if err != nil {
+			return 0, errors.WithStack(err)
+		}
+		params = append(params, constant.NewInt(argc, int64(len(args))), NewPointer(argvType, argv))
+		// Additional parameters (e.g. envp) are null.
+		for _, param := range main.Params[2:] {
+			params = append(params, zeroValue(param.Typ))
+		}
+	} else if len(main.Params) == 1 {
+		return 0, errors.Errorf("invalid number of parameters of @main; expected 0 or 2, got 1")
+	}
+	x, err := in.Call(main, params...)
+	if err != nil {
+		if exit, ok := errors.Cause(err).(*Exit); ok {
+			return exit.Code, nil
+		}
+		return 0, errors.WithStack(err)
+	}
+	if x, ok := x.(*constant.Int); ok {
+		return int(signed(x).Int64()), nil
+	}
+	return 0, nil
+}
+
+// newArgv allocates a NULL-terminated array of pointers to NUL-terminated
+// copies of the given strings, and returns the address of the array.
+func (in *Interpreter) newArgv(args []string) (uint64, error) {
+	ptrSize := in.layout.PointerSize(0)
+	argv := in.Mem.Alloc(uint64(len(args)+1)*ptrSize, ptrSize)
+	for i, arg := range args {
+		s := in.Mem.Alloc(uint64(len(arg)+1), 1)
+		if err := in.Mem.Write(s, append([]byte(arg), 0)); err != nil {
+			return 0, errors.WithStack(err)
+		}
+		if err := in.Store(argv+uint64(i)*ptrSize, NewPointer(bytePtr, s)); err != nil {
+			return 0, errors.WithStack(err)
+		}
+	}
+	return argv, nil
+}
@@ -0,0 +1,188 @@
+package interp
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/llir/llvm/ir/datalayout"
+	"github.com/pkg/errors"
+)
+
+// Memory is the byte-addressed memory of an interpreter.
+//
+// Memory is partitioned into allocations (global variables, stack allocations,
+// heap allocations and functions), which are placed at increasing addresses
+// separated by unmapped guard bytes. Accesses outside of live allocations
+// result in errors. Address 0 is never mapped.
+type Memory struct {
+	// Allocations ordered by address.
+	allocs []*allocation
+	// Next free address.
+	next uint64
+}
+
+// allocation is a contiguous range of allocated memory.
+type allocation struct {
+	// Start address.
+	addr uint64
+	// Contents.
+	data []byte
+	// Allocation kind.
+	kind allocKind
+	// Allocation has been freed.
+	freed bool
+	// Allocation is read-only (e.g. constant global variable).
+	readOnly bool
+}
+
+// allocKind specifies the kind of an allocation.
+type allocKind uint8
+
+// Allocation kinds.
+const (
+	allocGlobal allocKind = iota + 1
+	allocStack
+	allocHeap
+	allocFunc
+)
+
+// String returns a string representation of the allocation kind.
+func (kind allocKind) String() string {
+	switch kind {
+	case allocGlobal:
+		return "global"
+	case allocStack:
+		return "stack"
+	case allocHeap:
+		return "heap"
+	case allocFunc:
+		return "function"
+	}
+	return "unknown"
+}
+
+// Guard bytes between consecutive allocations, and the start address of the
+// first allocation.
+const guardSize = 16
+
+// newMemory returns a new empty memory.
+func newMemory() *Memory {
+	return &Memory{next: 0x1000}
+}
+
+// Alloc allocates size bytes of zero-initialized heap memory with the given
+// alignment, and returns the address of the allocated memory.
+func (m *Memory) Alloc(size, align uint64) uint64 {
+	return m.alloc(size, align, allocHeap).addr
+}
+
+// Free frees the heap memory allocated at the given address. Freeing address 0
+// is a no-op.
+func (m *Memory) Free(addr uint64) error {
+	if addr == 0 {
+		return nil
+	}
+	a := m.lookup(addr)
+	switch {
+	case a == nil || a.addr != addr:
+		return errors.Errorf("invalid free of address 0x%X; not the start of an allocation", addr)
+	case a.kind != allocHeap:
+		return errors.Errorf("invalid free of address 0x%X; not a heap allocation (%v)", addr, a.kind)
+	case a.freed:
+		return errors.Errorf("double free of address 0x%X", addr)
+	}
+	m.release(a)
+	return nil
+}
+
+// Read returns a copy of the n bytes of memory at the given address.
+func (m *Memory) Read(addr, n uint64) ([]byte, error) {
+	a, err := m.access(addr, n)
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid read")
+	}
+	off := addr - a.addr
+	buf := make([]byte, n)
+	copy(buf, a.data[off:off+n])
+	return buf, nil
+}
+
+// Write writes the given bytes to memory at the given address.
+func (m *Memory) Write(addr uint64, buf []byte) error {
+	a, err := m.access(addr, uint64(len(buf)))
+	if err != nil {
+		return errors.WithMessage(err, "invalid write")
+	}
+	if a.readOnly {
+		return errors.Errorf("invalid write of %d bytes at address 0x%X; read-only memory", len(buf), addr)
+	}
+	copy(a.data[addr-a.addr:], buf)
+	return nil
+}
+
+// ReadCString returns the NUL-terminated string stored in memory at the given
+// address.
+func (m *Memory) ReadCString(addr uint64) (string, error) {
+	a, err := m.access(addr, 1)
+	if err != nil {
+		return "", errors.WithMessage(err, "invalid read")
+	}
+	data := a.data[addr-a.addr:]
+	n := bytes.IndexByte(data, 0)
+	if n == -1 {
+		return "", errors.Errorf("invalid read of string at address 0x%X; missing NUL terminator", addr)
+	}
+	return string(data[:n]), nil
+}
+
+// alloc allocates size bytes of zero-initialized memory of the given kind and
+// alignment.
+func (m *Memory) alloc(size, align uint64, kind allocKind) *allocation {
+	addr := datalayout.AlignTo(m.next, align)
+	a := &allocation{addr: addr, data: make([]byte, size), kind: kind}
+	m.allocs = append(m.allocs, a)
+	// Allocations of size 0 still receive a unique address.
+	if size == 0 {
+		size = 1
+	}
+	m.next = addr + size + guardSize
+	return a
+}
+
+// release marks the given allocation as freed.
+func (m *Memory) release(a *allocation) {
+	a.freed = true
+	a.data = nil
+}
+
+// lookup returns the allocation containing the given address; or nil if no
+// allocation starts at or below the address.
+func (m *Memory) lookup(addr uint64) *allocation {
+	i := sort.Search(len(m.allocs), func(i int) bool {
+		return m.allocs[i].addr > addr
+	})
+	if i == 0 {
+		return nil
+	}
+	return m.allocs[i-1]
+}
+
+// access returns the allocation containing the n bytes at the given address,
+// or an error if the bytes are not within a single live allocation.
+func (m *Memory) access(addr, n uint64) (*allocation, error) {
+	if addr == 0 {
+		return nil, errors.Errorf("null pointer dereference")
+	}
+	a := m.lookup(addr)
+	switch {
+	case a == nil:
+		return nil, errors.Errorf("address 0x%X not allocated", addr)
+	case a.freed:
+		return nil, errors.Errorf("use of freed %v memory at address 0x%X", a.kind, addr)
+	case a.kind == allocFunc:
+		return nil, errors.Errorf("access of function memory at address 0x%X", addr)
+	case addr-a.addr+n > uint64(len(a.data)) || addr+n < addr:
+		return nil, errors.Errorf("access of %d bytes at address 0x%X out of bounds of %v allocation at 0x%X of size %d", n, addr, a.kind, a.addr, len(a.data))
+	}
+	return a, nil
+}
@@ -0,0 +1,460 @@
+package interp
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/mewmew/float/binary16"
+	"github.com/pkg/errors"
+)
+
+// === [ Runtime values ] ======================================================
+
+// Runtime values of the interpreter are represented by constants:
+//
+//   - integers by *constant.Int
+//   - floating-point values by *constant.Float
+//   - pointers by *interp.Pointer
+//   - vectors by *constant.Vector
+//   - structures by *constant.Struct (or *constant.ZeroInitializer)
+//   - arrays by *constant.Array or *constant.CharArray (or
+//     *constant.ZeroInitializer)
+//
+// Undef and poison values are represented by the zero value of their type.
+
+// Pointer is a runtime pointer value, holding a memory address.
+type Pointer struct {
+	// Pointer type.
+	Typ *types.PointerType
+	// Memory address.
+	Addr uint64
+}
+
+// NewPointer returns a new pointer value of the given type and address.
+func NewPointer(typ *types.PointerType, addr uint64) *Pointer {
+	return &Pointer{Typ: typ, Addr: addr}
+}
+
+// String returns the LLVM syntax representation of the pointer as a type-value
+// pair.
+func (p *Pointer) String() string {
+	return fmt.Sprintf("%s %s", p.Type(), p.Ident())
+}
+
+// Type returns the type of the pointer.
+func (p *Pointer) Type() types.Type {
+	return p.Typ
+}
+
+// Ident returns the identifier associated with the pointer.
+func (p *Pointer) Ident() string {
+	if p.Addr == 0 {
+		return "null"
+	}
+	return fmt.Sprintf("inttoptr (i64 %d to %s)", p.Addr, p.Typ)
+}
+
+// IsConstant ensures that only constants can be assigned to the
+// constant.Constant interface.
+func (*Pointer) IsConstant() {}
+
+// --- [ Value normalization ] -------------------------------------------------
+
+// concrete returns the runtime value of the given folded constant, replacing
+// undef, poison and null values by concrete zero values.
+func concrete(c constant.Constant) constant.Constant {
+	switch c := c.(type) {
+	case *constant.Int, *constant.Float, *Pointer, *constant.CharArray:
+		return c
+	case *constant.Null:
+		return NewPointer(c.Typ, 0)
+	case *constant.Undef, *constant.Poison, *constant.ZeroInitializer:
+		return zeroValue(c.Type())
+	case *constant.Vector:
+		elems := make([]constant.Constant, len(c.Elems))
+		for i, elem := range c.Elems {
+			elems[i] = concrete(elem)
+		}
+		return &constant.Vector{Typ: c.Typ, Elems: elems}
+	case *constant.Array:
+		elems := make([]constant.Constant, len(c.Elems))
+		for i, elem := range c.Elems {
+			elems[i] = concrete(elem)
+		}
+		return &constant.Array{Typ: c.Typ, Elems: elems}
+	case *constant.Struct:
+		fields := make([]constant.Constant, len(c.Fields))
+		for i, field := range c.Fields {
+			fields[i] = concrete(field)
+		}
+		return &constant.Struct{Typ: c.Typ, Fields: fields}
+	}
+	return c
+}
+
+// zeroValue returns the zero runtime value of the given type.
+func zeroValue(typ types.Type) constant.Constant {
+	switch t := typ.(type) {
+	case *types.IntType:
+		return constant.NewInt(t, 0)
+	case *types.FloatType:
+		return constant.NewFloat(t, 0)
+	case *types.PointerType:
+		return NewPointer(t, 0)
+	case *types.VectorType:
+		elems := make([]constant.Constant, t.Len)
+		for i := range elems {
+			elems[i] = zeroValue(t.ElemType)
+		}
+		return &constant.Vector{Typ: t, Elems: elems}
+	}
+	return constant.NewZeroInitializer(typ)
+}
+
+// elems returns the elements of the given vector, array or struct runtime
+// value.
+func elems(c constant.Constant) ([]constant.Constant, error) {
+	switch c := c.(type) {
+	case *constant.Vector:
+		return c.Elems, nil
+	case *constant.Array:
+		return c.Elems, nil
+	case *constant.Struct:
+		return c.Fields, nil
+	case *constant.CharArray:
+		elems := make([]constant.Constant, len(c.X))
+		for i, b := range c.X {
+			elems[i] = constant.NewInt(types.I8, int64(b))
+		}
+		return elems, nil
+	case *constant.ZeroInitializer:
+		switch t := c.Typ.(type) {
+		case *types.StructType:
+			elems := make([]constant.Constant, len(t.Fields))
+			for i, field := range t.Fields {
+				elems[i] = zeroValue(field)
+			}
+			return elems, nil
+		case *types.ArrayType:
+			elems := make([]constant.Constant, t.Len)
+			for i := range elems {
+				elems[i] = zeroValue(t.ElemType)
+			}
+			return elems, nil
+		}
+	}
+	return nil, errors.Errorf("invalid aggregate value %v", c)
+}
+
+// --- [ Value accessors ] -----------------------------------------------------
+
+// unsigned returns the value of the given integer as an unsigned integer of its
+// bit size.
+func unsigned(c *constant.Int) *big.Int {
+	x := new(big.Int).Set(c.X)
+	if x.Sign() < 0 {
+		x.Add(x, new(big.Int).Lsh(big.NewInt(1), uint(c.Typ.BitSize)))
+	}
+	return x
+}
+
+// signed returns the value of the given integer as a signed integer of its bit
+// size.
+func signed(c *constant.Int) *big.Int {
+	x := unsigned(c)
+	if c.Typ.BitSize > 0 && x.Bit(int(c.Typ.BitSize-1)) == 1 {
+		x.Sub(x, new(big.Int).Lsh(big.NewInt(1), uint(c.Typ.BitSize)))
+	}
+	return x
+}
+
+// newInt returns a new integer of the given type, truncating x to the bit size
+// of the type.
+func newInt(typ *types.IntType, x *big.Int) *constant.Int {
+	mask := new(big.Int).Lsh(big.NewInt(1), uint(typ.BitSize))
+	mask.Sub(mask, big.NewInt(1))
+	v := new(big.Int).And(x, mask)
+	if typ.BitSize > 1 && v.Bit(int(typ.BitSize-1)) == 1 {
+		v.Sub(v, new(big.Int).Add(mask, big.NewInt(1)))
+	}
+	return &constant.Int{Typ: typ, X: v}
+}
+
+// intArg returns the value of the given integer runtime value as an unsigned
+// 64-bit integer.
+func intArg(c constant.Constant) (uint64, error) {
+	i, ok := c.(*constant.Int)
+	if !ok {
+		return 0, errors.Errorf("invalid integer value %v", c)
+	}
+	return unsigned(i).Uint64(), nil
+}
+
+// addr returns the memory address of the given pointer runtime value.
+func addr(c constant.Constant) (uint64, error) {
+	switch c := c.(type) {
+	case *Pointer:
+		return c.Addr, nil
+	case *constant.Null:
+		return 0, nil
+	}
+	return 0, errors.Errorf("invalid pointer value %v", c)
+}
+
+// floatValue returns the value of the given floating-point runtime value.
+func floatValue(c *constant.Float) (float64, error) {
+	switch c.Typ.Kind {
+	case types.FloatKindHalf, types.FloatKindFloat, types.FloatKindDouble:
+	default:
+		return 0, errors.Errorf("support for floating-point type %v not yet implemented", c.Typ)
+	}
+	if c.NaN {
+		if c.X != nil && c.X.Signbit() {
+			return math.Copysign(math.NaN(), -1), nil
+		}
+		return math.NaN(), nil
+	}
+	x, _ := c.X.Float64()
+	return x, nil
+}
+
+// newFloat returns a new floating-point runtime value of the given type,
+// rounding x to the precision of the type.
+func newFloat(typ *types.FloatType, x float64) (*constant.Float, error) {
+	bits, err := floatToBits(typ, x)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return newFloatFromBits(typ, bits)
+}
+
+// floatBitsValue returns the bits of the given floating-point runtime value.
+func floatBitsValue(c *constant.Float) (uint64, error) {
+	x, err := floatValue(c)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return floatToBits(c.Typ, x)
+}
+
+// floatToBits returns the bits of x in the representation of the given
+// floating-point type.
+func floatToBits(typ *types.FloatType, x float64) (uint64, error) {
+	switch typ.Kind {
+	case types.FloatKindHalf:
+		h, _ := binary16.NewFromFloat64(x)
+		return uint64(h.Bits()), nil
+	case types.FloatKindFloat:
+		return uint64(math.Float32bits(float32(x))), nil
+	case types.FloatKindDouble:
+		return math.Float64bits(x), nil
+	}
+	return 0, errors.Errorf("support for floating-point type %v not yet implemented", typ)
+}
+
+// newFloatFromBits returns a new floating-point runtime value of the given type
+// and bits.
+func newFloatFromBits(typ *types.FloatType, bits uint64) (*constant.Float, error) {
+	switch typ.Kind {
+	case types.FloatKindHalf:
+		x, _ := binary16.NewFromBits(uint16(bits)).Float64()
+		return constant.NewFloat(typ, x), nil
+	case types.FloatKindFloat:
+		return constant.NewFloat(typ, float64(math.Float32frombits(uint32(bits)))), nil
+	case types.FloatKindDouble:
+		return constant.NewFloat(typ, math.Float64frombits(bits)), nil
+	}
+	return nil, errors.Errorf("support for floating-point type %v not yet implemented", typ)
+}
+
+// === [ Value encoding ] ======================================================
+
+// encode returns the in-memory representation of the given runtime value of
+// type typ, as stored by a store instruction.
+func (l *layout) encode(typ types.Type, c constant.Constant) ([]byte, error) {
+	buf := make([]byte, l.SizeOf(typ))
+	switch c.(type) {
+	case *constant.ZeroInitializer, *constant.Undef, *constant.Poison:
+		return buf, nil
+	}
+	switch t := typ.(type) {
+	case *types.IntType:
+		i, ok := c.(*constant.Int)
+		if !ok {
+			return nil, errors.Errorf("invalid integer value %v", c)
+		}
+		l.putUint(buf, unsigned(i))
+	case *types.FloatType:
+		f, ok := c.(*constant.Float)
+		if !ok {
+			return nil, errors.Errorf("invalid floating-point value %v", c)
+		}
+		bits, err := floatBitsValue(f)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		l.putUint(buf, new(big.Int).SetUint64(bits))
+	case *types.PointerType:
+		a, err := addr(c)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		l.putUint(buf, new(big.Int).SetUint64(a))
+	case *types.VectorType:
+		es, err := elems(c)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		bits := l.BitSizeOf(t.ElemType)
+		if bits%8 == 0 {
+			for i, elem := range es {
+				b, err := l.encode(t.ElemType, elem)
+				if err != nil {
+					return nil, errors.WithStack(err)
+				}
+				copy(buf[uint64(i)*bits/8:], b)
+			}
+			break
+		}
+		// Vectors of non-byte sized integers are bit-packed, with the first
+		// element in the least significant bits.
+		x := new(big.Int)
+		for i, elem := range es {
+			e, ok := elem.(*constant.Int)
+			if !ok {
+				return nil, errors.Errorf("invalid integer value %v", elem)
+			}
+			x.Or(x, new(big.Int).Lsh(unsigned(e), uint(uint64(i)*bits)))
+		}
+		l.putUint(buf, x)
+	case *types.ArrayType:
+		if s, ok := c.(*constant.CharArray); ok {
+			copy(buf, s.X)
+			break
+		}
+		es, err := elems(c)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		stride := l.AllocSizeOf(t.ElemType)
+		for i, elem := range es {
+			b, err := l.encode(t.ElemType, elem)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			copy(buf[uint64(i)*stride:], b)
+		}
+	case *types.StructType:
+		es, err := elems(c)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		sl := l.StructLayout(t)
+		for i, field := range es {
+			b, err := l.encode(t.Fields[i], field)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			copy(buf[sl.Offsets[i]:], b)
+		}
+	default:
+		return nil, errors.Errorf("support for type %v not yet implemented", typ)
+	}
+	return buf, nil
+}
+
+// decode returns the runtime value of type typ with the given in-memory
+// representation.
+func (l *layout) decode(typ types.Type, buf []byte) (constant.Constant, error) {
+	switch t := typ.(type) {
+	case *types.IntType:
+		return newInt(t, l.getUint(buf)), nil
+	case *types.FloatType:
+		return newFloatFromBits(t, l.getUint(buf).Uint64())
+	case *types.PointerType:
+		return NewPointer(t, l.getUint(buf).Uint64()), nil
+	case *types.VectorType:
+		es := make([]constant.Constant, t.Len)
+		bits := l.BitSizeOf(t.ElemType)
+		if bits%8 == 0 {
+			size := bits / 8
+			for i := range es {
+				elem, err := l.decode(t.ElemType, buf[uint64(i)*size:uint64(i+1)*size])
+				if err != nil {
+					return nil, errors.WithStack(err)
+				}
+				es[i] = elem
+			}
+		} else {
+			it, ok := t.ElemType.(*types.IntType)
+			if !ok {
+				return nil, errors.Errorf("invalid vector element type %v", t.ElemType)
+			}
+			x := l.getUint(buf)
+			for i := range es {
+				es[i] = newInt(it, new(big.Int).Rsh(x, uint(uint64(i)*bits)))
+			}
+		}
+		return &constant.Vector{Typ: t, Elems: es}, nil
+	case *types.ArrayType:
+		es := make([]constant.Constant, t.Len)
+		stride := l.AllocSizeOf(t.ElemType)
+		size := l.SizeOf(t.ElemType)
+		for i := range es {
+			off := uint64(i) * stride
+			elem, err := l.decode(t.ElemType, buf[off:off+size])
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			es[i] = elem
+		}
+		return &constant.Array{Typ: t, Elems: es}, nil
+	case *types.StructType:
+		sl := l.StructLayout(t)
+		fields := make([]constant.Constant, len(t.Fields))
+		for i, ft := range t.Fields {
+			off := sl.Offsets[i]
+			field, err := l.decode(ft, buf[off:off+l.SizeOf(ft)])
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			fields[i] = field
+		}
+		return &constant.Struct{Typ: t, Fields: fields}, nil
+	}
+	return nil, errors.Errorf("support for type %v not yet implemented", typ)
+}
+
+// putUint stores the unsigned integer x in buf using the byte order of the data
+// layout, truncating x to the size of buf.
+func (l *layout) putUint(buf []byte, x *big.Int) {
+	b := x.Bytes() // big-endian
+	for i := range buf {
+		var v byte
+		if i < len(b) {
+			v = b[len(b)-1-i]
+		}
+		if l.BigEndian {
+			buf[len(buf)-1-i] = v
+		} else {
+			buf[i] = v
+		}
+	}
+}
+
+// getUint returns the unsigned integer stored in buf using the byte order of
+// the data layout.
+func (l *layout) getUint(buf []byte) *big.Int {
+	b := make([]byte, len(buf)) // big-endian
+	for i := range buf {
+		if l.BigEndian {
+			b[i] = buf[i]
+		} else {
+			b[i] = buf[len(buf)-1-i]
+		}
+	}
+	return new(big.Int).SetBytes(b)
+}
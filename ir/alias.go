@@ -20,6 +20,9 @@ type Alias struct {
 
 	// Pointer type of aliasee.
 	Typ *types.PointerType
+	// (optional) Content type; or nil to use the element type of Typ. Required
+	// if Typ is an opaque pointer type.
+	ContentType types.Type
 	// (optional) Linkage; zero value if not present.
 	Linkage enum.Linkage
 	// (optional) Preemption; zero value if not present.
@@ -88,7 +91,7 @@ func (a *Alias) LLString() string {
 		fmt.Fprintf(buf, " %s", a.UnnamedAddr)
 	}
 	buf.WriteString(" alias")
-	fmt.Fprintf(buf, " %s, ", a.Typ.ElemType)
+	fmt.Fprintf(buf, " %s, ", a.contentType())
 	if expr, ok := a.Aliasee.(constant.Expression); ok {
 		buf.WriteString(expr.Ident())
 	} else {
@@ -99,3 +102,11 @@ func (a *Alias) LLString() string {
 	}
 	return buf.String()
 }
+
+// contentType returns the content type of the alias.
+func (a *Alias) contentType() types.Type {
+	if a.ContentType != nil {
+		return a.ContentType
+	}
+	return a.Typ.ElemType
+}
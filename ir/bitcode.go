@@ -0,0 +1,983 @@
+package ir
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/llir/llvm/internal/bitc"
+	"github.com/llir/llvm/internal/bitstream"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/metadata"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/pkg/errors"
+)
+
+// === [ LLVM IR bitcode ] =====================================================
+
+// BitcodeOptions specifies the options used when writing LLVM IR bitcode.
+type BitcodeOptions struct {
+	// Byte-stable output; the producer string of the identification block omits
+	// the version of the Go toolchain, so that the output only depends on the
+	// contents of the module.
+	Stable bool
+}
+
+// WriteBitcode writes the module to w in LLVM IR bitcode format.
+//
+// The output is deterministic; writing the same module twice produces the same
+// bytes. Use WriteBitcodeWithOptions with BitcodeOptions.Stable to also make
+// the output independent of the version of the Go toolchain.
+func (m *Module) WriteBitcode(w io.Writer) error {
+	return m.WriteBitcodeWithOptions(w, BitcodeOptions{})
+}
+
+// WriteBitcodeWithOptions writes the module to w in LLVM IR bitcode format,
+// using the given options.
+func (m *Module) WriteBitcodeWithOptions(w io.Writer, opts BitcodeOptions) error {
+	e := newEncoder(m, opts)
+	if _, err := w.Write(e.encode()); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// encoder is an LLVM IR bitcode encoder.
+//
+// The module is encoded in two passes. The first pass populates the tables
+// which are extended on demand while writing (types, attributes, metadata
+// kinds, operand bundle tags and sync scopes), and the second pass writes the
+// final output, at which point the tables are complete.
+type encoder struct {
+	// Module being encoded.
+	m *Module
+	// Encoder options.
+	opts BitcodeOptions
+	// Bitstream writer of the current pass.
+	bs *bitstream.Writer
+	// Final pass; tables may no longer be extended.
+	final bool
+	// String table of the current pass.
+	strtab []byte
+	// Standard abbreviations, defined in the BLOCKINFO block.
+	abbrevs bcAbbrevs
+
+	// Type table.
+	types []types.Type
+	// Type IDs, indexed by type key.
+	typeIDs map[string]uint64
+	// Identified struct types being enumerated, indexed by type key.
+	visitingTypes map[string]bool
+	// Bit width of type IDs.
+	typeBits uint64
+
+	// Attribute groups; each as [idx, attr0, attr1, ...].
+	attrGroups [][]uint64
+	// 1-based attribute group IDs, indexed by attribute group key.
+	attrGroupIDs map[string]uint64
+	// Attribute sets; each as a list of attribute group IDs.
+	attrSets [][]uint64
+	// 1-based attribute set IDs, indexed by attribute set key.
+	attrSetIDs map[string]uint64
+
+	// Metadata kind names, indexed by metadata kind ID.
+	mdKinds []string
+	// Metadata kind IDs, indexed by metadata kind name.
+	mdKindIDs map[string]uint64
+	// Operand bundle tags, indexed by operand bundle tag ID.
+	bundleTags []string
+	// Operand bundle tag IDs, indexed by operand bundle tag.
+	bundleTagIDs map[string]uint64
+	// Sync scope names, indexed by sync scope ID.
+	syncScopes []string
+	// Sync scope IDs, indexed by sync scope name.
+	syncScopeIDs map[string]uint64
+
+	// Section names.
+	sections []string
+	// 1-based section IDs, indexed by section name.
+	sectionIDs map[string]uint64
+	// Garbage collector names.
+	gcNames []string
+	// 1-based garbage collector IDs, indexed by garbage collector name.
+	gcIDs map[string]uint64
+	// Comdat definitions.
+	comdats []*ComdatDef
+	// 1-based comdat IDs, indexed by comdat definition.
+	comdatIDs map[*ComdatDef]uint64
+
+	// Module-level values; global values followed by module-level constants.
+	values []value.Value
+	// Module-level value IDs, indexed by value key.
+	valueIDs map[interface{}]uint64
+	// Number of global values.
+	numGlobalValues int
+
+	// Module-level metadata strings.
+	mdStrings []string
+	// Module-level metadata string IDs, indexed by string.
+	mdStringIDs map[string]uint64
+	// Module-level metadata nodes and values.
+	mdNodes []metadata.Field
+	// Indices into mdNodes, indexed by metadata key.
+	mdNodeIDs map[interface{}]uint64
+	// Enumerate metadata operands instead of writing metadata records.
+	enumeratingMD bool
+	// Metadata nodes being enumerated, indexed by metadata key.
+	visitingMD map[interface{}]bool
+
+	// Function being encoded; nil if not within a function.
+	fn *funcEncoder
+}
+
+// newEncoder returns a new LLVM IR bitcode encoder for the given module.
+func newEncoder(m *Module, opts BitcodeOptions) *encoder {
+	return &encoder{
+		m:             m,
+		opts:          opts,
+		typeIDs:       make(map[string]uint64),
+		visitingTypes: make(map[string]bool),
+		attrGroupIDs:  make(map[string]uint64),
+		attrSetIDs:    make(map[string]uint64),
+		mdKindIDs:     make(map[string]uint64),
+		bundleTagIDs:  make(map[string]uint64),
+		syncScopeIDs:  make(map[string]uint64),
+		sectionIDs:    make(map[string]uint64),
+		gcIDs:         make(map[string]uint64),
+		comdatIDs:     make(map[*ComdatDef]uint64),
+		valueIDs:      make(map[interface{}]uint64),
+		mdStringIDs:   make(map[string]uint64),
+		mdNodeIDs:     make(map[interface{}]uint64),
+		visitingMD:    make(map[interface{}]bool),
+	}
+}
+
+// encode encodes the module in LLVM IR bitcode format.
+func (e *encoder) encode() []byte {
+	for _, kind := range fixedMDKinds {
+		e.mdKindID(kind)
+	}
+	for _, tag := range fixedBundleTags {
+		e.bundleTagID(tag)
+	}
+	for _, name := range fixedSyncScopes {
+		e.syncScopeID(name)
+	}
+	e.enumerateModule()
+	// The output of the first pass is discarded.
+	e.writePass()
+	e.final = true
+	return append([]byte(bitc.Magic), e.writePass()...)
+}
+
+// writePass writes the identification, module and string table blocks of the
+// module, and returns the output of the pass.
+func (e *encoder) writePass() []byte {
+	e.bs = bitstream.NewWriter()
+	e.strtab = nil
+	// Use a conservative type ID width in the first pass, as the size of the
+	// type table is not yet known.
+	e.typeBits = 32
+	if e.final {
+		e.typeBits = uint64(bits.Len64(uint64(len(e.types))))
+		if e.typeBits == 0 {
+			e.typeBits = 1
+		}
+	}
+	e.writeIdentificationBlock()
+	e.writeModuleBlock()
+	e.writeStrtabBlock()
+	return e.bs.Bytes()
+}
+
+// --- [ Enumeration ] ---------------------------------------------------------
+
+// enumerateModule enumerates the comdats, section names, garbage collector
+// names, global values, module-level constants and module-level metadata of
+// the module.
+func (e *encoder) enumerateModule() {
+	m := e.m
+	// Enumerate type definitions first, to preserve the order of numbered
+	// identified struct types.
+	for _, t := range m.TypeDefs {
+		e.typeID(t)
+	}
+	// Comdats.
+	for _, c := range m.ComdatDefs {
+		e.addComdat(c)
+	}
+	for _, g := range m.Globals {
+		e.addComdat(g.Comdat)
+	}
+	for _, f := range m.Funcs {
+		e.addComdat(f.Comdat)
+	}
+	// Section names and garbage collector names.
+	for _, g := range m.Globals {
+		addString(&e.sections, e.sectionIDs, g.Section)
+	}
+	for _, f := range m.Funcs {
+		addString(&e.sections, e.sectionIDs, f.Section)
+		addString(&e.gcNames, e.gcIDs, f.GC)
+	}
+	// Global values.
+	for _, g := range m.Globals {
+		e.addValue(g)
+	}
+	for _, f := range m.Funcs {
+		e.addValue(f)
+	}
+	for _, a := range m.Aliases {
+		e.addValue(a)
+	}
+	for _, i := range m.IFuncs {
+		e.addValue(i)
+	}
+	e.numGlobalValues = len(e.values)
+	// Module-level constants.
+	for _, g := range m.Globals {
+		if g.Init != nil {
+			e.enumerateConstant(g.Init)
+		}
+	}
+	for _, a := range m.Aliases {
+		e.enumerateConstant(a.Aliasee)
+	}
+	for _, i := range m.IFuncs {
+		e.enumerateConstant(i.Resolver)
+	}
+	for _, f := range m.Funcs {
+		for _, c := range []constant.Constant{f.Prologue, f.Prefix, f.Personality} {
+			if c != nil {
+				e.enumerateConstant(c)
+			}
+		}
+	}
+	// Module-level metadata.
+	e.enumerateModuleMetadata()
+}
+
+// addComdat adds the given comdat to the comdat table, if not already present.
+func (e *encoder) addComdat(c *ComdatDef) {
+	if c == nil {
+		return
+	}
+	if _, ok := e.comdatIDs[c]; ok {
+		return
+	}
+	e.comdats = append(e.comdats, c)
+	e.comdatIDs[c] = uint64(len(e.comdats))
+}
+
+// addString adds the given string to the string list, if not already present,
+// and returns its 1-based ID; or 0 if s is empty.
+func addString(list *[]string, ids map[string]uint64, s string) uint64 {
+	if len(s) == 0 {
+		return 0
+	}
+	if id, ok := ids[s]; ok {
+		return id
+	}
+	*list = append(*list, s)
+	id := uint64(len(*list))
+	ids[s] = id
+	return id
+}
+
+// addValue adds the given module-level value to the value table.
+func (e *encoder) addValue(v value.Value) {
+	e.valueIDs[valueKey(v)] = uint64(len(e.values))
+	e.values = append(e.values, v)
+}
+
+// enumerateConstant enumerates the given module-level constant and its
+// operands.
+func (e *encoder) enumerateConstant(c constant.Constant) {
+	if index, ok := c.(*constant.Index); ok {
+		c = index.Constant
+	}
+	if _, ok := e.valueIDs[valueKey(c)]; ok {
+		return
+	}
+	if isGlobalValue(c) {
+		panic(fmt.Errorf("unable to locate global value %s in module", c.Ident()))
+	}
+	for _, op := range constantOperands(c) {
+		e.enumerateConstant(op)
+	}
+	e.addValue(c)
+}
+
+// valueID returns the absolute value ID of the given value.
+func (e *encoder) valueID(v value.Value) uint64 {
+	key := valueKey(v)
+	if e.fn != nil {
+		if id, ok := e.fn.valueIDs[key]; ok {
+			return id
+		}
+	}
+	if id, ok := e.valueIDs[key]; ok {
+		return id
+	}
+	panic(fmt.Errorf("unable to locate value %s", v.Ident()))
+}
+
+// cstKey is the uniquing key of scalar constants.
+type cstKey string
+
+// valueKey returns the uniquing key of the given value in value tables.
+func valueKey(v value.Value) interface{} {
+	switch v := v.(type) {
+	case *Arg:
+		return valueKey(v.Value)
+	case *constant.Index:
+		return valueKey(v.Constant)
+	case *constant.Int, *constant.Float, *constant.Null, *constant.Undef, *constant.Poison, *constant.ZeroInitializer, *constant.NoneToken:
+		// Scalar constants are uniqued by value.
+		return cstKey(v.String())
+	}
+	return v
+}
+
+// isGlobalValue reports whether the given value is a global value.
+func isGlobalValue(v value.Value) bool {
+	switch v.(type) {
+	case *Global, *Func, *Alias, *IFunc:
+		return true
+	}
+	return false
+}
+
+// constantOperands returns the constant operands of the given constant.
+func constantOperands(c constant.Constant) []constant.Constant {
+	switch c := c.(type) {
+	case *constant.Struct:
+		return c.Fields
+	case *constant.Array:
+		return c.Elems
+	case *constant.Vector:
+		return c.Elems
+	case *constant.BlockAddress:
+		return []constant.Constant{c.Func}
+	case *constant.DSOLocalEquivalent:
+		return []constant.Constant{c.Func}
+	case *constant.NoCFI:
+		return []constant.Constant{c.Func}
+	case *constant.Index:
+		return []constant.Constant{c.Constant}
+	case constant.Expression:
+		var ops []constant.Constant
+		for _, op := range exprOperands(c) {
+			ops = append(ops, *op)
+		}
+		return ops
+	}
+	return nil
+}
+
+// --- [ Identification block ] ------------------------------------------------
+
+// writeIdentificationBlock writes the IDENTIFICATION_BLOCK.
+func (e *encoder) writeIdentificationBlock() {
+	e.bs.EnterBlock(bitc.IdentificationBlockID, 5)
+	// STRING: [strchr x N]
+	producer := "llir/llvm"
+	if !e.opts.Stable {
+		producer += " (" + runtime.Version() + ")"
+	}
+	stringAbbrev := e.defineAbbrev(bitstream.Literal(bitc.IdentificationCodeString), bitstream.Array(), bitstream.Fixed(8))
+	e.emit(bitc.IdentificationCodeString, stringOps(producer), stringAbbrev)
+	// EPOCH: [epoch#]
+	epochAbbrev := e.defineAbbrev(bitstream.Literal(bitc.IdentificationCodeEpoch), bitstream.VBR(6))
+	e.emit(bitc.IdentificationCodeEpoch, []uint64{0}, epochAbbrev)
+	e.bs.ExitBlock()
+}
+
+// --- [ Module block ] --------------------------------------------------------
+
+// writeModuleBlock writes the MODULE_BLOCK.
+func (e *encoder) writeModuleBlock() {
+	e.bs.EnterBlock(bitc.ModuleBlockID, 3)
+	// VERSION: [version#]
+	//
+	// Version 2 stores names of global values and comdats in the string table,
+	// and uses relative value IDs within functions.
+	e.emit(bitc.ModuleCodeVersion, []uint64{2})
+	e.writeBlockInfoBlock()
+	e.writeTypeBlock()
+	e.writeAttrGroupBlock()
+	e.writeAttrBlock()
+	e.writeComdats()
+	e.writeModuleInfo()
+	e.writeConstantsBlock(e.values[e.numGlobalValues:])
+	e.writeMetadataKindBlock()
+	e.writeModuleMetadataBlock()
+	e.writeOperandBundleTagsBlock()
+	e.writeSyncScopeNamesBlock()
+	for _, f := range e.m.Funcs {
+		if len(f.Blocks) > 0 {
+			e.writeFunctionBlock(f)
+		}
+	}
+	e.bs.ExitBlock()
+}
+
+// writeComdats writes the COMDAT records of the module.
+func (e *encoder) writeComdats() {
+	for _, c := range e.comdats {
+		// COMDAT: [strtab_offset, strtab_size, selection_kind]
+		ops := e.nameOps(c.Name)
+		ops = append(ops, encodeSelectionKind(c.Kind))
+		e.emit(bitc.ModuleCodeComdat, ops)
+	}
+}
+
+// writeModuleInfo writes the target triple, data layout, module-level inline
+// assembly, section names, garbage collector names and global values of the
+// module.
+func (e *encoder) writeModuleInfo() {
+	m := e.m
+	if len(m.TargetTriple) > 0 {
+		// TRIPLE: [strchr x N]
+		e.emit(bitc.ModuleCodeTriple, stringOps(m.TargetTriple))
+	}
+	if len(m.DataLayout) > 0 {
+		// DATALAYOUT: [strchr x N]
+		e.emit(bitc.ModuleCodeDatalayout, stringOps(m.DataLayout))
+	}
+	if len(m.ModuleAsms) > 0 {
+		// ASM: [strchr x N]
+		e.emit(bitc.ModuleCodeAsm, stringOps(strings.Join(m.ModuleAsms, "\n")))
+	}
+	for _, section := range e.sections {
+		// SECTIONNAME: [strchr x N]
+		e.emit(bitc.ModuleCodeSectionName, stringOps(section))
+	}
+	for _, gc := range e.gcNames {
+		// GCNAME: [strchr x N]
+		e.emit(bitc.ModuleCodeGCName, stringOps(gc))
+	}
+	for _, g := range m.Globals {
+		e.writeGlobalVar(g)
+	}
+	for _, f := range m.Funcs {
+		e.writeFunction(f)
+	}
+	for _, a := range m.Aliases {
+		e.writeAlias(a)
+	}
+	for _, i := range m.IFuncs {
+		e.writeIFunc(i)
+	}
+	if len(m.SourceFilename) > 0 {
+		// SOURCE_FILENAME: [namechar x N]
+		e.emit(bitc.ModuleCodeSourceFilename, stringOps(m.SourceFilename))
+	}
+}
+
+// writeGlobalVar writes the GLOBALVAR record of the given global variable.
+func (e *encoder) writeGlobalVar(g *Global) {
+	// GLOBALVAR: [strtab_offset, strtab_size, pointer type, isconst|explicitType<<1|addrspace<<2,
+	//             initid, linkage, alignment, section, visibility, threadlocal,
+	//             unnamed_addr, externally_initialized, dllstorageclass, comdat,
+	//             attributes, dso_local, partition_offset, partition_size]
+	addrSpace := pointerAddrSpace(g.Type())
+	ops := e.globalNameOps(g.GlobalIdent)
+	ops = append(ops, e.typeID(g.ContentType))
+	ops = append(ops, btou(g.Immutable)|1<<1|uint64(addrSpace)<<2)
+	var initID uint64
+	if g.Init != nil {
+		initID = e.valueID(g.Init) + 1
+	}
+	ops = append(ops, initID)
+	ops = append(ops, encodeLinkage(g.Linkage))
+	ops = append(ops, encodeAlign(g.Align))
+	ops = append(ops, e.sectionIDs[g.Section])
+	ops = append(ops, encodeVisibility(g.Visibility))
+	ops = append(ops, encodeTLSModel(g.TLSModel))
+	ops = append(ops, encodeUnnamedAddr(g.UnnamedAddr))
+	ops = append(ops, btou(g.ExternallyInitialized))
+	ops = append(ops, encodeDLLStorageClass(g.DLLStorageClass))
+	ops = append(ops, e.comdatIDs[g.Comdat])
+	ops = append(ops, e.attrSetID(g.FuncAttrs, nil, nil))
+	ops = append(ops, encodeDSOLocal(g.Preemption, g.Linkage, g.Visibility))
+	ops = append(ops, e.strtabOps(g.Partition)...)
+	e.emit(bitc.ModuleCodeGlobalVar, ops)
+}
+
+// writeFunction writes the FUNCTION record of the given function.
+func (e *encoder) writeFunction(f *Func) {
+	// FUNCTION: [strtab_offset, strtab_size, type, callingconv, isproto,
+	//            linkage, paramattrs, alignment, section, visibility, gc,
+	//            unnamed_addr, prologuedata, dllstorageclass, comdat,
+	//            prefixdata, personalityfn, dso_local, addrspace,
+	//            partition_offset, partition_size]
+	ops := e.globalNameOps(f.GlobalIdent)
+	ops = append(ops, e.typeID(f.Sig))
+	ops = append(ops, encodeCallingConv(f.CallingConv))
+	ops = append(ops, btou(len(f.Blocks) == 0))
+	ops = append(ops, encodeLinkage(f.Linkage))
+	var paramAttrs [][]ParamAttribute
+	for _, param := range f.Params {
+		paramAttrs = append(paramAttrs, param.Attrs)
+	}
+	ops = append(ops, e.attrSetID(f.FuncAttrs, f.ReturnAttrs, paramAttrs))
+	ops = append(ops, encodeAlign(f.Align))
+	ops = append(ops, e.sectionIDs[f.Section])
+	ops = append(ops, encodeVisibility(f.Visibility))
+	ops = append(ops, e.gcIDs[f.GC])
+	ops = append(ops, encodeUnnamedAddr(f.UnnamedAddr))
+	ops = append(ops, e.optValueID(f.Prologue))
+	ops = append(ops, encodeDLLStorageClass(f.DLLStorageClass))
+	ops = append(ops, e.comdatIDs[f.Comdat])
+	ops = append(ops, e.optValueID(f.Prefix))
+	ops = append(ops, e.optValueID(f.Personality))
+	ops = append(ops, encodeDSOLocal(f.Preemption, f.Linkage, f.Visibility))
+	ops = append(ops, uint64(pointerAddrSpace(f.Type())))
+	ops = append(ops, e.strtabOps(f.Partition)...)
+	e.emit(bitc.ModuleCodeFunction, ops)
+}
+
+// writeAlias writes the ALIAS record of the given alias.
+func (e *encoder) writeAlias(a *Alias) {
+	// ALIAS: [strtab_offset, strtab_size, alias value type, addrspace,
+	//         aliasee val#, linkage, visibility, dllstorageclass, threadlocal,
+	//         unnamed_addr, dso_local, partition_offset, partition_size]
+	ops := e.globalNameOps(a.GlobalIdent)
+	ops = append(ops, e.typeID(contentType(a.ContentType, a.Type())))
+	ops = append(ops, uint64(pointerAddrSpace(a.Type())))
+	ops = append(ops, e.valueID(a.Aliasee))
+	ops = append(ops, encodeLinkage(a.Linkage))
+	ops = append(ops, encodeVisibility(a.Visibility))
+	ops = append(ops, encodeDLLStorageClass(a.DLLStorageClass))
+	ops = append(ops, encodeTLSModel(a.TLSModel))
+	ops = append(ops, encodeUnnamedAddr(a.UnnamedAddr))
+	ops = append(ops, encodeDSOLocal(a.Preemption, a.Linkage, a.Visibility))
+	ops = append(ops, e.strtabOps(a.Partition)...)
+	e.emit(bitc.ModuleCodeAlias, ops)
+}
+
+// writeIFunc writes the IFUNC record of the given IFunc.
+func (e *encoder) writeIFunc(i *IFunc) {
+	// IFUNC: [strtab_offset, strtab_size, ifunc value type, addrspace,
+	//         resolver val#, linkage, visibility, dso_local, partition_offset,
+	//         partition_size]
+	ops := e.globalNameOps(i.GlobalIdent)
+	ops = append(ops, e.typeID(contentType(i.ContentType, i.Type())))
+	ops = append(ops, uint64(pointerAddrSpace(i.Type())))
+	ops = append(ops, e.valueID(i.Resolver))
+	ops = append(ops, encodeLinkage(i.Linkage))
+	ops = append(ops, encodeVisibility(i.Visibility))
+	ops = append(ops, encodeDSOLocal(i.Preemption, i.Linkage, i.Visibility))
+	ops = append(ops, e.strtabOps(i.Partition)...)
+	e.emit(bitc.ModuleCodeIFunc, ops)
+}
+
+// optValueID returns the absolute value ID + 1 of the given constant; or 0 if
+// c is nil.
+func (e *encoder) optValueID(c constant.Constant) uint64 {
+	if c == nil {
+		return 0
+	}
+	return e.valueID(c) + 1
+}
+
+// globalNameOps returns the string table offset and size of the name of the
+// given global value; unnamed global values have an empty name.
+func (e *encoder) globalNameOps(ident GlobalIdent) []uint64 {
+	return e.nameOps(ident.GlobalName)
+}
+
+// nameOps returns the string table offset and size of the given name.
+func (e *encoder) nameOps(name string) []uint64 {
+	return e.strtabOps(name)
+}
+
+// strtabOps adds the given string to the string table, and returns its string
+// table offset and size.
+func (e *encoder) strtabOps(s string) []uint64 {
+	if len(s) == 0 {
+		return []uint64{0, 0}
+	}
+	offset := uint64(len(e.strtab))
+	e.strtab = append(e.strtab, s...)
+	return []uint64{offset, uint64(len(s))}
+}
+
+// --- [ Operand bundle tags block ] -------------------------------------------
+
+// fixedBundleTags specifies the operand bundle tags with fixed IDs.
+var fixedBundleTags = []string{
+	"deopt",
+	"funclet",
+	"gc-transition",
+	"cfguardtarget",
+	"preallocated",
+	"gc-live",
+	"clang.arc.attachedcall",
+}
+
+// bundleTagID returns the ID of the given operand bundle tag.
+func (e *encoder) bundleTagID(tag string) uint64 {
+	if id, ok := e.bundleTagIDs[tag]; ok {
+		return id
+	}
+	if e.final {
+		panic(fmt.Errorf("unable to locate operand bundle tag %q", tag))
+	}
+	id := uint64(len(e.bundleTags))
+	e.bundleTags = append(e.bundleTags, tag)
+	e.bundleTagIDs[tag] = id
+	return id
+}
+
+// writeOperandBundleTagsBlock writes the OPERAND_BUNDLE_TAGS_BLOCK.
+func (e *encoder) writeOperandBundleTagsBlock() {
+	e.bs.EnterBlock(bitc.OperandBundleTagsBlockID, 3)
+	for _, tag := range e.bundleTags {
+		// OPERAND_BUNDLE_TAG: [strchr x N]
+		e.emit(bitc.OperandBundleTagCode, stringOps(tag))
+	}
+	e.bs.ExitBlock()
+}
+
+// --- [ Sync scope names block ] ----------------------------------------------
+
+// fixedSyncScopes specifies the sync scope names with fixed IDs; the system
+// scope has an empty name.
+var fixedSyncScopes = []string{"singlethread", ""}
+
+// syncScopeID returns the ID of the given sync scope.
+func (e *encoder) syncScopeID(name string) uint64 {
+	if id, ok := e.syncScopeIDs[name]; ok {
+		return id
+	}
+	if e.final {
+		panic(fmt.Errorf("unable to locate sync scope %q", name))
+	}
+	id := uint64(len(e.syncScopes))
+	e.syncScopes = append(e.syncScopes, name)
+	e.syncScopeIDs[name] = id
+	return id
+}
+
+// writeSyncScopeNamesBlock writes the SYNC_SCOPE_NAMES_BLOCK.
+func (e *encoder) writeSyncScopeNamesBlock() {
+	e.bs.EnterBlock(bitc.SyncScopeNamesBlockID, 2)
+	for _, name := range e.syncScopes {
+		// SYNC_SCOPE_NAME: [strchr x N]
+		e.emit(bitc.SyncScopeName, stringOps(name))
+	}
+	e.bs.ExitBlock()
+}
+
+// --- [ String table block ] --------------------------------------------------
+
+// writeStrtabBlock writes the STRTAB_BLOCK.
+func (e *encoder) writeStrtabBlock() {
+	e.bs.EnterBlock(bitc.StrtabBlockID, 3)
+	// BLOB: [blob]
+	abbrev := e.defineAbbrev(bitstream.Literal(bitc.StrtabBlob), bitstream.Blob())
+	e.bs.EmitRecordWithBlob(bitc.StrtabBlob, nil, e.strtab, abbrev.id)
+	e.bs.ExitBlock()
+}
+
+// --- [ Abbreviations ] -------------------------------------------------------
+
+// bcAbbrev is an abbreviation and its abbreviation ID.
+type bcAbbrev struct {
+	// Abbreviation ID.
+	id uint64
+	// Abbreviation.
+	abbrev *bitstream.Abbrev
+}
+
+// bcAbbrevs holds the standard abbreviations defined in the BLOCKINFO block.
+type bcAbbrevs struct {
+	// VALUE_SYMTAB_BLOCK abbreviations.
+	vstEntry8, vstEntry7, vstEntry6, vstBBEntry6 *bcAbbrev
+	// CONSTANTS_BLOCK abbreviations.
+	cstSetType, cstInteger, cstCE, cstNull *bcAbbrev
+	// FUNCTION_BLOCK abbreviations.
+	fnLoad, fnUnop, fnUnopFlags, fnBinop, fnBinopFlags, fnCast, fnRetVoid, fnRetVal, fnUnreachable, fnGEP *bcAbbrev
+}
+
+// writeBlockInfoBlock writes the BLOCKINFO block, which defines the standard
+// abbreviations of value symbol tables, constants blocks and function blocks.
+func (e *encoder) writeBlockInfoBlock() {
+	e.bs.EnterBlockInfoBlock()
+	a := &e.abbrevs
+	typeOp := bitstream.Fixed(e.typeBits)
+	// VST_ENTRY: [valueid, namechar x N]
+	a.vstEntry8 = e.blockInfoAbbrev(bitc.ValueSymtabBlockID, bitstream.Literal(bitc.VSTCodeEntry), bitstream.VBR(8), bitstream.Array(), bitstream.Fixed(8))
+	a.vstEntry7 = e.blockInfoAbbrev(bitc.ValueSymtabBlockID, bitstream.Literal(bitc.VSTCodeEntry), bitstream.VBR(8), bitstream.Array(), bitstream.Fixed(7))
+	a.vstEntry6 = e.blockInfoAbbrev(bitc.ValueSymtabBlockID, bitstream.Literal(bitc.VSTCodeEntry), bitstream.VBR(8), bitstream.Array(), bitstream.Char6())
+	// VST_BBENTRY: [bbid, namechar x N]
+	a.vstBBEntry6 = e.blockInfoAbbrev(bitc.ValueSymtabBlockID, bitstream.Literal(bitc.VSTCodeBBEntry), bitstream.VBR(8), bitstream.Array(), bitstream.Char6())
+	// SETTYPE: [typeid]
+	a.cstSetType = e.blockInfoAbbrev(bitc.ConstantsBlockID, bitstream.Literal(bitc.CstCodeSetType), typeOp)
+	// INTEGER: [intval]
+	a.cstInteger = e.blockInfoAbbrev(bitc.ConstantsBlockID, bitstream.Literal(bitc.CstCodeInteger), bitstream.VBR(8))
+	// CE_CAST: [opcode, opty, opval]
+	a.cstCE = e.blockInfoAbbrev(bitc.ConstantsBlockID, bitstream.Literal(bitc.CstCodeCECast), bitstream.Fixed(4), typeOp, bitstream.VBR(8))
+	// NULL: []
+	a.cstNull = e.blockInfoAbbrev(bitc.ConstantsBlockID, bitstream.Literal(bitc.CstCodeNull))
+	// INST_LOAD: [op, ty, align, vol]
+	a.fnLoad = e.blockInfoAbbrev(bitc.FunctionBlockID, bitstream.Literal(bitc.FuncCodeInstLoad), bitstream.VBR(6), typeOp, bitstream.VBR(4), bitstream.Fixed(1))
+	// INST_UNOP: [opval, opcode]
+	a.fnUnop = e.blockInfoAbbrev(bitc.FunctionBlockID, bitstream.Literal(bitc.FuncCodeInstUnop), bitstream.VBR(6), bitstream.Fixed(4))
+	// INST_UNOP: [opval, opcode, flags]
+	a.fnUnopFlags = e.blockInfoAbbrev(bitc.FunctionBlockID, bitstream.Literal(bitc.FuncCodeInstUnop), bitstream.VBR(6), bitstream.Fixed(4), bitstream.Fixed(8))
+	// INST_BINOP: [opval, opval, opcode]
+	a.fnBinop = e.blockInfoAbbrev(bitc.FunctionBlockID, bitstream.Literal(bitc.FuncCodeInstBinop), bitstream.VBR(6), bitstream.VBR(6), bitstream.Fixed(4))
+	// INST_BINOP: [opval, opval, opcode, flags]
+	a.fnBinopFlags = e.blockInfoAbbrev(bitc.FunctionBlockID, bitstream.Literal(bitc.FuncCodeInstBinop), bitstream.VBR(6), bitstream.VBR(6), bitstream.Fixed(4), bitstream.Fixed(8))
+	// INST_CAST: [opval, destty, castopc]
+	a.fnCast = e.blockInfoAbbrev(bitc.FunctionBlockID, bitstream.Literal(bitc.FuncCodeInstCast), bitstream.VBR(6), typeOp, bitstream.Fixed(4))
+	// INST_RET: []
+	a.fnRetVoid = e.blockInfoAbbrev(bitc.FunctionBlockID, bitstream.Literal(bitc.FuncCodeInstRet))
+	// INST_RET: [opval]
+	a.fnRetVal = e.blockInfoAbbrev(bitc.FunctionBlockID, bitstream.Literal(bitc.FuncCodeInstRet), bitstream.VBR(6))
+	// INST_UNREACHABLE: []
+	a.fnUnreachable = e.blockInfoAbbrev(bitc.FunctionBlockID, bitstream.Literal(bitc.FuncCodeInstUnreachable))
+	// INST_GEP: [inbounds, ty, n x operands]
+	a.fnGEP = e.blockInfoAbbrev(bitc.FunctionBlockID, bitstream.Literal(bitc.FuncCodeInstGEP), bitstream.Fixed(1), typeOp, bitstream.Array(), bitstream.VBR(6))
+	e.bs.ExitBlockInfoBlock()
+}
+
+// blockInfoAbbrev defines the abbreviation of the given operands for the
+// specified block in the BLOCKINFO block.
+func (e *encoder) blockInfoAbbrev(blockID uint64, ops ...bitstream.AbbrevOp) *bcAbbrev {
+	abbrev := bitstream.NewAbbrev(ops...)
+	id := e.bs.EmitBlockInfoAbbrev(blockID, abbrev)
+	return &bcAbbrev{id: id, abbrev: abbrev}
+}
+
+// defineAbbrev defines the abbreviation of the given operands in the current
+// block.
+func (e *encoder) defineAbbrev(ops ...bitstream.AbbrevOp) *bcAbbrev {
+	abbrev := bitstream.NewAbbrev(ops...)
+	id := e.bs.EmitAbbrev(abbrev)
+	return &bcAbbrev{id: id, abbrev: abbrev}
+}
+
+// emit writes the record of the given record code and operands, using the
+// first of the given abbreviations able to encode the record; or unabbreviated
+// if none applies.
+func (e *encoder) emit(code uint64, ops []uint64, abbrevs ...*bcAbbrev) {
+	for _, abbrev := range abbrevs {
+		if abbrev.abbrev.CanEncode(code, ops) {
+			e.bs.EmitRecord(code, ops, abbrev.id)
+			return
+		}
+	}
+	e.bs.EmitRecord(code, ops, bitstream.AbbrevIDUnabbrevRecord)
+}
+
+// ### [ Helper functions ] ####################################################
+
+// stringOps returns the record operands of the given string, one character per
+// operand.
+func stringOps(s string) []uint64 {
+	ops := make([]uint64, len(s))
+	for i := 0; i < len(s); i++ {
+		ops[i] = uint64(s[i])
+	}
+	return ops
+}
+
+// btou returns 1 if b is true, and 0 otherwise.
+func btou(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// encodeSigned encodes the given signed integer using sign rotation; the sign
+// is stored in the least significant bit.
+func encodeSigned(v int64) uint64 {
+	if v >= 0 {
+		return uint64(v) << 1
+	}
+	// Note, -0 is used to encode the minimum signed 64-bit integer.
+	return uint64(-v)<<1 | 1
+}
+
+// encodeAlign encodes the given alignment as log2(align)+1; or 0 if not
+// present.
+func encodeAlign(align Align) uint64 {
+	if align == 0 {
+		return 0
+	}
+	return uint64(bits.TrailingZeros64(uint64(align))) + 1
+}
+
+// pointerAddrSpace returns the address space of the given pointer type.
+func pointerAddrSpace(t types.Type) types.AddrSpace {
+	if t, ok := t.(*types.PointerType); ok {
+		return t.AddrSpace
+	}
+	return 0
+}
+
+// contentType returns the given content type of an alias or IFunc if present,
+// and otherwise the element type of the pointer type.
+func contentType(t types.Type, ptr types.Type) types.Type {
+	if t != nil {
+		return t
+	}
+	if ptr, ok := ptr.(*types.PointerType); ok && ptr.ElemType != nil {
+		return ptr.ElemType
+	}
+	panic(fmt.Errorf("unable to determine content type of pointer type %v", ptr))
+}
+
+// encodeLinkage encodes the given linkage.
+func encodeLinkage(linkage enum.Linkage) uint64 {
+	switch linkage {
+	case enum.LinkageAppending:
+		return 2
+	case enum.LinkageInternal:
+		return 3
+	case enum.LinkageExternWeak:
+		return 7
+	case enum.LinkageCommon:
+		return 8
+	case enum.LinkagePrivate:
+		return 9
+	case enum.LinkageAvailableExternally:
+		return 12
+	case enum.LinkageWeak:
+		return 16
+	case enum.LinkageWeakODR:
+		return 17
+	case enum.LinkageLinkOnce:
+		return 18
+	case enum.LinkageLinkOnceODR:
+		return 19
+	default:
+		// External linkage.
+		return 0
+	}
+}
+
+// encodeDSOLocal encodes the dso_local flag of a global value with the given
+// preemption, linkage and visibility. Global values with local linkage or
+// non-default visibility are implicitly dso_local.
+func encodeDSOLocal(preemption enum.Preemption, linkage enum.Linkage, visibility enum.Visibility) uint64 {
+	implicit := bitc.IsLocalLinkage(linkage) || (visibility != enum.VisibilityNone && visibility != enum.VisibilityDefault && linkage != enum.LinkageExternWeak)
+	return btou(preemption == enum.PreemptionDSOLocal || implicit)
+}
+
+// encodeVisibility encodes the given visibility.
+func encodeVisibility(visibility enum.Visibility) uint64 {
+	switch visibility {
+	case enum.VisibilityHidden:
+		return 1
+	case enum.VisibilityProtected:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// encodeDLLStorageClass encodes the given DLL storage class.
+func encodeDLLStorageClass(class enum.DLLStorageClass) uint64 {
+	switch class {
+	case enum.DLLStorageClassDLLImport:
+		return 1
+	case enum.DLLStorageClassDLLExport:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// encodeTLSModel encodes the given thread local storage model.
+func encodeTLSModel(model enum.TLSModel) uint64 {
+	switch model {
+	case enum.TLSModelGeneric:
+		return 1
+	case enum.TLSModelLocalDynamic:
+		return 2
+	case enum.TLSModelInitialExec:
+		return 3
+	case enum.TLSModelLocalExec:
+		return 4
+	default:
+		return 0
+	}
+}
+
+// encodeUnnamedAddr encodes the given unnamed_addr kind.
+func encodeUnnamedAddr(unnamedAddr enum.UnnamedAddr) uint64 {
+	switch unnamedAddr {
+	case enum.UnnamedAddrUnnamedAddr:
+		return 1
+	case enum.UnnamedAddrLocalUnnamedAddr:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// encodeCallingConv encodes the given calling convention.
+func encodeCallingConv(cc enum.CallingConv) uint64 {
+	// Note, the C calling convention is 0 in bitcode and implicit in LLVM IR
+	// assembly.
+	if cc == enum.CallingConvC {
+		return 0
+	}
+	return uint64(cc)
+}
+
+// encodeSelectionKind encodes the given comdat selection kind.
+func encodeSelectionKind(kind enum.SelectionKind) uint64 {
+	switch kind {
+	case enum.SelectionKindExactMatch:
+		return bitc.ComdatSelectionKindExactMatch
+	case enum.SelectionKindLargest:
+		return bitc.ComdatSelectionKindLargest
+	case enum.SelectionKindNoDeduplicate:
+		return bitc.ComdatSelectionKindNoDuplicates
+	case enum.SelectionKindSameSize:
+		return bitc.ComdatSelectionKindSameSize
+	default:
+		return bitc.ComdatSelectionKindAny
+	}
+}
+
+// sortedNamedMetadataDefs returns the named metadata definitions of the module,
+// sorted by the lowest metadata ID of their nodes and then by name. The order
+// of named metadata definitions is thereby preserved for modules with numbered
+// metadata definitions (e.g. parsed modules), and deterministic otherwise.
+func (e *encoder) sortedNamedMetadataDefs() []*metadata.NamedDef {
+	defs := make([]*metadata.NamedDef, 0, len(e.m.NamedMetadataDefs))
+	for _, def := range e.m.NamedMetadataDefs {
+		defs = append(defs, def)
+	}
+	firstID := func(def *metadata.NamedDef) int64 {
+		min := int64(math.MaxInt64)
+		for _, node := range def.Nodes {
+			if d, ok := node.(metadata.Definition); ok && d.ID() >= 0 && d.ID() < min {
+				min = d.ID()
+			}
+		}
+		return min
+	}
+	sort.Slice(defs, func(i, j int) bool {
+		a, b := firstID(defs[i]), firstID(defs[j])
+		if a != b {
+			return a < b
+		}
+		return defs[i].Name < defs[j].Name
+	})
+	return defs
+}
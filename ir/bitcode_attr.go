@@ -0,0 +1,250 @@
+package ir
+
+import (
+	"fmt"
+
+	"github.com/llir/llvm/internal/bitc"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+)
+
+// --- [ Attribute blocks ] ----------------------------------------------------
+
+// attrSetID returns the 1-based ID of the attribute set of the given function,
+// return and parameter attributes, adding it to the attribute set table if not
+// already present; or 0 if no attributes are present.
+func (e *encoder) attrSetID(funcAttrs []FuncAttribute, retAttrs []ReturnAttribute, paramAttrs [][]ParamAttribute) uint64 {
+	var grpIDs []uint64
+	if ops := e.funcAttrOps(funcAttrs); len(ops) > 0 {
+		grpIDs = append(grpIDs, e.attrGroupID(bitc.AttrIndexFunction, ops))
+	}
+	if ops := e.returnAttrOps(retAttrs); len(ops) > 0 {
+		grpIDs = append(grpIDs, e.attrGroupID(bitc.AttrIndexReturn, ops))
+	}
+	for i, attrs := range paramAttrs {
+		if ops := e.paramAttrOps(attrs); len(ops) > 0 {
+			grpIDs = append(grpIDs, e.attrGroupID(bitc.AttrIndexFirstParam+uint64(i), ops))
+		}
+	}
+	if len(grpIDs) == 0 {
+		return 0
+	}
+	key := fmt.Sprint(grpIDs)
+	if id, ok := e.attrSetIDs[key]; ok {
+		return id
+	}
+	if e.final {
+		panic(fmt.Errorf("unable to locate attribute set %v", grpIDs))
+	}
+	e.attrSets = append(e.attrSets, grpIDs)
+	id := uint64(len(e.attrSets))
+	e.attrSetIDs[key] = id
+	return id
+}
+
+// attrGroupID returns the 1-based ID of the attribute group of the given
+// parameter index and encoded attributes, adding it to the attribute group
+// table if not already present.
+func (e *encoder) attrGroupID(index uint64, ops []uint64) uint64 {
+	group := append([]uint64{index}, ops...)
+	key := fmt.Sprint(group)
+	if id, ok := e.attrGroupIDs[key]; ok {
+		return id
+	}
+	if e.final {
+		panic(fmt.Errorf("unable to locate attribute group %v", group))
+	}
+	e.attrGroups = append(e.attrGroups, group)
+	id := uint64(len(e.attrGroups))
+	e.attrGroupIDs[key] = id
+	return id
+}
+
+// writeAttrGroupBlock writes the PARAMATTR_GROUP_BLOCK of the attribute group
+// table.
+func (e *encoder) writeAttrGroupBlock() {
+	if len(e.attrGroups) == 0 {
+		return
+	}
+	e.bs.EnterBlock(bitc.ParamAttrGroupBlockID, 3)
+	for i, group := range e.attrGroups {
+		// ENTRY: [grpid, idx, attr0, attr1, ...]
+		ops := append([]uint64{uint64(i + 1)}, group...)
+		e.emit(bitc.ParamAttrGrpCodeEntry, ops)
+	}
+	e.bs.ExitBlock()
+}
+
+// writeAttrBlock writes the PARAMATTR_BLOCK of the attribute set table.
+func (e *encoder) writeAttrBlock() {
+	if len(e.attrSets) == 0 {
+		return
+	}
+	e.bs.EnterBlock(bitc.ParamAttrBlockID, 3)
+	for _, set := range e.attrSets {
+		// ENTRY: [attrgrp0, attrgrp1, ...]
+		e.emit(bitc.ParamAttrCodeEntry, set)
+	}
+	e.bs.ExitBlock()
+}
+
+// funcAttrOps returns the encoded attribute group entries of the given function
+// attributes.
+func (e *encoder) funcAttrOps(attrs []FuncAttribute) []uint64 {
+	var ops []uint64
+	for _, attr := range attrs {
+		switch attr := attr.(type) {
+		case *AttrGroupDef:
+			ops = append(ops, e.funcAttrOps(attr.FuncAttrs)...)
+		case AttrString:
+			ops = append(ops, stringAttrOps(string(attr))...)
+		case AttrPair:
+			ops = append(ops, keyValueAttrOps(attr.Key, attr.Value)...)
+		case Align:
+			ops = append(ops, bitc.AttrEntryInt, bitc.AttrKindAlignment, uint64(attr))
+		case AlignStack:
+			ops = append(ops, bitc.AttrEntryInt, bitc.AttrKindStackAlignment, uint64(attr))
+		case AllocKind:
+			ops = append(ops, bitc.AttrEntryInt, bitc.AttrKindAllocKind, uint64(attr.Kind))
+		case AllocSize:
+			// Note, a missing number of elements parameter index is encoded as
+			// 0xFFFFFFFF.
+			nElemsIndex := uint64(0xFFFFFFFF)
+			if attr.NElemsIndex != -1 {
+				nElemsIndex = uint64(attr.NElemsIndex)
+			}
+			ops = append(ops, bitc.AttrEntryInt, bitc.AttrKindAllocSize, uint64(attr.ElemSizeIndex)<<32|nElemsIndex)
+		case Preallocated:
+			ops = append(ops, e.typeAttrOps(bitc.AttrKindPreallocated, attr.Typ)...)
+		case UnwindTable:
+			if attr.Kind == enum.UnwindTableKindNone {
+				ops = append(ops, bitc.AttrEntryEnum, bitc.AttrKindUWTable)
+				break
+			}
+			ops = append(ops, bitc.AttrEntryInt, bitc.AttrKindUWTable, uint64(attr.Kind))
+		case VectorScaleRange:
+			// Note, a missing minimum is encoded as the maximum.
+			min := attr.Min
+			if min == -1 {
+				min = attr.Max
+			}
+			ops = append(ops, bitc.AttrEntryInt, bitc.AttrKindVScaleRange, uint64(min)<<32|uint64(attr.Max))
+		case enum.FuncAttr:
+			kind, ok := bitc.FuncAttrKinds[attr]
+			if !ok {
+				panic(fmt.Errorf("support for function attribute %v not yet implemented", attr))
+			}
+			ops = append(ops, bitc.AttrEntryEnum, kind)
+		default:
+			panic(fmt.Errorf("support for function attribute %T not yet implemented", attr))
+		}
+	}
+	return ops
+}
+
+// paramAttrOps returns the encoded attribute group entries of the given
+// parameter attributes.
+func (e *encoder) paramAttrOps(attrs []ParamAttribute) []uint64 {
+	var ops []uint64
+	for _, attr := range attrs {
+		switch attr := attr.(type) {
+		case AttrString:
+			ops = append(ops, stringAttrOps(string(attr))...)
+		case AttrPair:
+			ops = append(ops, keyValueAttrOps(attr.Key, attr.Value)...)
+		case Align:
+			ops = append(ops, bitc.AttrEntryInt, bitc.AttrKindAlignment, uint64(attr))
+		case AlignStack:
+			ops = append(ops, bitc.AttrEntryInt, bitc.AttrKindStackAlignment, uint64(attr))
+		case Dereferenceable:
+			ops = append(ops, dereferenceableAttrOps(attr)...)
+		case ByRef:
+			ops = append(ops, e.typeAttrOps(bitc.AttrKindByRef, attr.Typ)...)
+		case Byval:
+			ops = append(ops, e.typeAttrOps(bitc.AttrKindByVal, attr.Typ)...)
+		case ElementType:
+			ops = append(ops, e.typeAttrOps(bitc.AttrKindElementType, attr.Typ)...)
+		case InAlloca:
+			ops = append(ops, e.typeAttrOps(bitc.AttrKindInAlloca, attr.Typ)...)
+		case Preallocated:
+			ops = append(ops, e.typeAttrOps(bitc.AttrKindPreallocated, attr.Typ)...)
+		case SRet:
+			ops = append(ops, e.typeAttrOps(bitc.AttrKindStructRet, attr.Typ)...)
+		case enum.ParamAttr:
+			kind, ok := bitc.ParamAttrKinds[attr]
+			if !ok {
+				panic(fmt.Errorf("support for parameter attribute %v not yet implemented", attr))
+			}
+			ops = append(ops, bitc.AttrEntryEnum, kind)
+		default:
+			panic(fmt.Errorf("support for parameter attribute %T not yet implemented", attr))
+		}
+	}
+	return ops
+}
+
+// returnAttrOps returns the encoded attribute group entries of the given return
+// attributes.
+func (e *encoder) returnAttrOps(attrs []ReturnAttribute) []uint64 {
+	var ops []uint64
+	for _, attr := range attrs {
+		switch attr := attr.(type) {
+		case AttrString:
+			ops = append(ops, stringAttrOps(string(attr))...)
+		case AttrPair:
+			ops = append(ops, keyValueAttrOps(attr.Key, attr.Value)...)
+		case Align:
+			ops = append(ops, bitc.AttrEntryInt, bitc.AttrKindAlignment, uint64(attr))
+		case Dereferenceable:
+			ops = append(ops, dereferenceableAttrOps(attr)...)
+		case enum.ReturnAttr:
+			kind, ok := bitc.ReturnAttrKinds[attr]
+			if !ok {
+				panic(fmt.Errorf("support for return attribute %v not yet implemented", attr))
+			}
+			ops = append(ops, bitc.AttrEntryEnum, kind)
+		default:
+			panic(fmt.Errorf("support for return attribute %T not yet implemented", attr))
+		}
+	}
+	return ops
+}
+
+// typeAttrOps returns the encoded attribute group entry of the type attribute
+// of the given attribute kind and optional type.
+func (e *encoder) typeAttrOps(kind uint64, typ types.Type) []uint64 {
+	if typ == nil {
+		return []uint64{bitc.AttrEntryEnum, kind}
+	}
+	return []uint64{bitc.AttrEntryTypeWithType, kind, e.typeID(typ)}
+}
+
+// ### [ Helper functions ] ####################################################
+
+// dereferenceableAttrOps returns the encoded attribute group entry of the given
+// dereferenceable attribute.
+func dereferenceableAttrOps(attr Dereferenceable) []uint64 {
+	kind := uint64(bitc.AttrKindDereferenceable)
+	if attr.DerefOrNull {
+		kind = bitc.AttrKindDereferenceableOrNull
+	}
+	return []uint64{bitc.AttrEntryInt, kind, attr.N}
+}
+
+// stringAttrOps returns the encoded attribute group entry of the given string
+// attribute.
+func stringAttrOps(key string) []uint64 {
+	ops := []uint64{bitc.AttrEntryString}
+	ops = append(ops, stringOps(key)...)
+	return append(ops, 0)
+}
+
+// keyValueAttrOps returns the encoded attribute group entry of the given
+// key-value string attribute.
+func keyValueAttrOps(key, val string) []uint64 {
+	ops := []uint64{bitc.AttrEntryKeyValue}
+	ops = append(ops, stringOps(key)...)
+	ops = append(ops, 0)
+	ops = append(ops, stringOps(val)...)
+	return append(ops, 0)
+}
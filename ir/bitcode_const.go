@@ -0,0 +1,484 @@
+package ir
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+
+	"github.com/llir/llvm/internal/bitc"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/mewmew/float/binary128"
+	"github.com/mewmew/float/binary16"
+	"github.com/mewmew/float/float128ppc"
+	"github.com/mewmew/float/float80x86"
+)
+
+// --- [ Constants block ] -----------------------------------------------------
+
+// writeConstantsBlock writes the CONSTANTS_BLOCK of the given constants.
+func (e *encoder) writeConstantsBlock(csts []value.Value) {
+	if len(csts) == 0 {
+		return
+	}
+	e.bs.EnterBlock(bitc.ConstantsBlockID, 4)
+	var lastType types.Type
+	for i, c := range csts {
+		if i == 0 || !c.Type().Equal(lastType) {
+			// SETTYPE: [typeid]
+			lastType = c.Type()
+			e.emit(bitc.CstCodeSetType, []uint64{e.typeID(lastType)}, e.abbrevs.cstSetType)
+		}
+		e.writeConstant(c)
+	}
+	e.bs.ExitBlock()
+}
+
+// writeConstant writes the constant record of the given constant, or inline
+// assembler expression.
+func (e *encoder) writeConstant(c value.Value) {
+	switch c := c.(type) {
+	case *constant.Int:
+		if c.X.Sign() == 0 {
+			e.emit(bitc.CstCodeNull, nil, e.abbrevs.cstNull)
+			return
+		}
+		if c.Typ.BitSize <= 64 {
+			// INTEGER: [intval]
+			e.emit(bitc.CstCodeInteger, []uint64{encodeSigned(intValue(c))}, e.abbrevs.cstInteger)
+			return
+		}
+		// WIDE_INTEGER: [n x intval]
+		var ops []uint64
+		for _, word := range intWords(c) {
+			ops = append(ops, encodeSigned(int64(word)))
+		}
+		e.emit(bitc.CstCodeWideInteger, ops)
+	case *constant.Float:
+		if !c.NaN && c.X.Sign() == 0 && !c.X.Signbit() {
+			e.emit(bitc.CstCodeNull, nil, e.abbrevs.cstNull)
+			return
+		}
+		// FLOAT: [fpval]
+		e.emit(bitc.CstCodeFloat, floatOps(c))
+	case *constant.Null, *constant.ZeroInitializer, *constant.NoneToken:
+		e.emit(bitc.CstCodeNull, nil, e.abbrevs.cstNull)
+	case *constant.Undef:
+		e.emit(bitc.CstCodeUndef, nil)
+	case *constant.Poison:
+		e.emit(bitc.CstCodePoison, nil)
+	case *constant.Struct:
+		// AGGREGATE: [n x value number]
+		e.emit(bitc.CstCodeAggregate, e.constantIDs(c.Fields))
+	case *constant.Array:
+		// AGGREGATE: [n x value number]
+		e.emit(bitc.CstCodeAggregate, e.constantIDs(c.Elems))
+	case *constant.Vector:
+		// AGGREGATE: [n x value number]
+		e.emit(bitc.CstCodeAggregate, e.constantIDs(c.Elems))
+	case *constant.CharArray:
+		if n := len(c.X); n > 0 && c.X[n-1] == 0 && !containsZero(c.X[:n-1]) {
+			// CSTRING: [values]
+			e.emit(bitc.CstCodeCString, bytesOps(c.X[:n-1]))
+			return
+		}
+		// STRING: [values]
+		e.emit(bitc.CstCodeString, bytesOps(c.X))
+	case *constant.BlockAddress:
+		// BLOCKADDRESS: [fnty, fnval, bb#]
+		f, ok := c.Func.(*Func)
+		if !ok {
+			panic(fmt.Errorf("invalid function of blockaddress constant; expected *ir.Func, got %T", c.Func))
+		}
+		e.emit(bitc.CstCodeBlockAddress, []uint64{e.typeID(f.Type()), e.valueID(f), blockIndex(f, c.Block)})
+	case *constant.DSOLocalEquivalent:
+		// DSO_LOCAL_EQUIVALENT: [gvty, gv]
+		e.emit(bitc.CstCodeDSOLocalEquivalent, []uint64{e.typeID(c.Func.Type()), e.valueID(c.Func)})
+	case *constant.NoCFI:
+		// NO_CFI: [fty, f]
+		e.emit(bitc.CstCodeNoCFIValue, []uint64{e.typeID(c.Func.Type()), e.valueID(c.Func)})
+	case *InlineAsm:
+		e.writeInlineAsm(c)
+	case constant.Expression:
+		e.writeExpr(c)
+	default:
+		panic(fmt.Errorf("support for constant %T not yet implemented", c))
+	}
+}
+
+// writeInlineAsm writes the INLINEASM record of the given inline assembler
+// expression.
+func (e *encoder) writeInlineAsm(asm *InlineAsm) {
+	// INLINEASM: [fnty, sideeffect|alignstack|asmdialect|unwind, asmstr, conststr]
+	var sig *types.FuncType
+	if e.fn != nil {
+		sig = e.fn.asmSigs[asm]
+	}
+	if sig == nil {
+		t, ok := asm.Typ.(*types.PointerType)
+		if !ok || t.ElemType == nil {
+			panic(fmt.Errorf("unable to determine function type of inline assembler expression %q", asm.Asm))
+		}
+		sig = t.ElemType.(*types.FuncType)
+	}
+	flags := btou(asm.SideEffect) | btou(asm.AlignStack)<<1 | btou(asm.IntelDialect)<<2
+	ops := []uint64{e.typeID(sig), flags}
+	ops = append(ops, uint64(len(asm.Asm)))
+	ops = append(ops, stringOps(asm.Asm)...)
+	ops = append(ops, uint64(len(asm.Constraint)))
+	ops = append(ops, stringOps(asm.Constraint)...)
+	e.emit(bitc.CstCodeInlineAsm, ops)
+}
+
+// writeExpr writes the constant record of the given constant expression.
+func (e *encoder) writeExpr(c constant.Expression) {
+	switch c := c.(type) {
+	case *constant.ExprFNeg:
+		// CE_UNOP: [opcode, opval]
+		e.emit(bitc.CstCodeCEUnop, []uint64{bitc.UnopFNeg, e.valueID(c.X)})
+	// Binary expressions.
+	case *constant.ExprAdd:
+		e.writeBinaryExpr(bitc.BinopAdd, c.X, c.Y, encodeOverflowFlags(c.OverflowFlags))
+	case *constant.ExprSub:
+		e.writeBinaryExpr(bitc.BinopSub, c.X, c.Y, encodeOverflowFlags(c.OverflowFlags))
+	case *constant.ExprMul:
+		e.writeBinaryExpr(bitc.BinopMul, c.X, c.Y, encodeOverflowFlags(c.OverflowFlags))
+	// Bitwise expressions.
+	case *constant.ExprShl:
+		e.writeBinaryExpr(bitc.BinopShl, c.X, c.Y, encodeOverflowFlags(c.OverflowFlags))
+	case *constant.ExprLShr:
+		e.writeBinaryExpr(bitc.BinopLShr, c.X, c.Y, btou(c.Exact)<<bitc.PEOExact)
+	case *constant.ExprAShr:
+		e.writeBinaryExpr(bitc.BinopAShr, c.X, c.Y, btou(c.Exact)<<bitc.PEOExact)
+	case *constant.ExprAnd:
+		e.writeBinaryExpr(bitc.BinopAnd, c.X, c.Y, 0)
+	case *constant.ExprOr:
+		e.writeBinaryExpr(bitc.BinopOr, c.X, c.Y, 0)
+	case *constant.ExprXor:
+		e.writeBinaryExpr(bitc.BinopXor, c.X, c.Y, 0)
+	// Vector expressions.
+	case *constant.ExprExtractElement:
+		// CE_EXTRACTELT: [opty, opval, opty, opval]
+		e.emit(bitc.CstCodeCEExtractElt, []uint64{e.typeID(c.X.Type()), e.valueID(c.X), e.typeID(c.Index.Type()), e.valueID(c.Index)})
+	case *constant.ExprInsertElement:
+		// CE_INSERTELT: [opval, opval, opty, opval]
+		e.emit(bitc.CstCodeCEInsertElt, []uint64{e.valueID(c.X), e.valueID(c.Elem), e.typeID(c.Index.Type()), e.valueID(c.Index)})
+	case *constant.ExprShuffleVector:
+		// CE_SHUFFLEVEC: [opval, opval, opval]
+		e.emit(bitc.CstCodeCEShuffleVec, []uint64{e.valueID(c.X), e.valueID(c.Y), e.valueID(c.Mask)})
+	// Memory expressions.
+	case *constant.ExprGetElementPtr:
+		e.writeGEPExpr(c)
+	// Conversion expressions.
+	case *constant.ExprTrunc:
+		e.writeCastExpr(bitc.CastTrunc, c.From)
+	case *constant.ExprZExt:
+		e.writeCastExpr(bitc.CastZExt, c.From)
+	case *constant.ExprSExt:
+		e.writeCastExpr(bitc.CastSExt, c.From)
+	case *constant.ExprFPTrunc:
+		e.writeCastExpr(bitc.CastFPTrunc, c.From)
+	case *constant.ExprFPExt:
+		e.writeCastExpr(bitc.CastFPExt, c.From)
+	case *constant.ExprFPToUI:
+		e.writeCastExpr(bitc.CastFPToUI, c.From)
+	case *constant.ExprFPToSI:
+		e.writeCastExpr(bitc.CastFPToSI, c.From)
+	case *constant.ExprUIToFP:
+		e.writeCastExpr(bitc.CastUIToFP, c.From)
+	case *constant.ExprSIToFP:
+		e.writeCastExpr(bitc.CastSIToFP, c.From)
+	case *constant.ExprPtrToInt:
+		e.writeCastExpr(bitc.CastPtrToInt, c.From)
+	case *constant.ExprIntToPtr:
+		e.writeCastExpr(bitc.CastIntToPtr, c.From)
+	case *constant.ExprBitCast:
+		e.writeCastExpr(bitc.CastBitCast, c.From)
+	case *constant.ExprAddrSpaceCast:
+		e.writeCastExpr(bitc.CastAddrSpaceCast, c.From)
+	// Other expressions.
+	case *constant.ExprICmp:
+		// CE_CMP: [opty, opval, opval, pred]
+		e.emit(bitc.CstCodeCECmp, []uint64{e.typeID(c.X.Type()), e.valueID(c.X), e.valueID(c.Y), encodeIPred(c.Pred)})
+	case *constant.ExprFCmp:
+		// CE_CMP: [opty, opval, opval, pred]
+		e.emit(bitc.CstCodeCECmp, []uint64{e.typeID(c.X.Type()), e.valueID(c.X), e.valueID(c.Y), encodeFPred(c.Pred)})
+	case *constant.ExprSelect:
+		// CE_SELECT: [opval, opval, opval]
+		e.emit(bitc.CstCodeCESelect, []uint64{e.valueID(c.Cond), e.valueID(c.X), e.valueID(c.Y)})
+	default:
+		panic(fmt.Errorf("support for constant expression %T not yet implemented", c))
+	}
+}
+
+// writeBinaryExpr writes the CE_BINOP record of the given binary operation,
+// operands and flags.
+func (e *encoder) writeBinaryExpr(opcode uint64, x, y constant.Constant, flags uint64) {
+	// CE_BINOP: [opcode, opval, opval, flags]
+	ops := []uint64{opcode, e.valueID(x), e.valueID(y)}
+	if flags != 0 {
+		ops = append(ops, flags)
+	}
+	e.emit(bitc.CstCodeCEBinop, ops)
+}
+
+// writeCastExpr writes the CE_CAST record of the given cast operation and
+// operand. The target type is given by the preceding SETTYPE record.
+func (e *encoder) writeCastExpr(opcode uint64, from constant.Constant) {
+	// CE_CAST: [opcode, opty, opval]
+	e.emit(bitc.CstCodeCECast, []uint64{opcode, e.typeID(from.Type()), e.valueID(from)}, e.abbrevs.cstCE)
+}
+
+// writeGEPExpr writes the getelementptr record of the given constant
+// expression.
+func (e *encoder) writeGEPExpr(c *constant.ExprGetElementPtr) {
+	// CE_GEP: [pointee type, n x operands]
+	// CE_INBOUNDS_GEP: [pointee type, n x operands]
+	// CE_GEP_WITH_INRANGE_INDEX: [pointee type, flags, n x operands]
+	code := uint64(bitc.CstCodeCEGEP)
+	ops := []uint64{e.typeID(c.ElemType)}
+	inRangeIndex := -1
+	for i, index := range c.Indices {
+		if index, ok := index.(*constant.Index); ok && index.InRange {
+			inRangeIndex = i
+			break
+		}
+	}
+	switch {
+	case inRangeIndex >= 0:
+		code = bitc.CstCodeCEGEPWithInrangeIndex
+		ops = append(ops, uint64(inRangeIndex)<<1|btou(c.InBounds))
+	case c.InBounds:
+		code = bitc.CstCodeCEInboundsGEP
+	}
+	ops = append(ops, e.typeID(c.Src.Type()), e.valueID(c.Src))
+	for _, index := range c.Indices {
+		ops = append(ops, e.typeID(index.Type()), e.valueID(index))
+	}
+	e.emit(code, ops)
+}
+
+// constantIDs returns the absolute value IDs of the given constants.
+func (e *encoder) constantIDs(csts []constant.Constant) []uint64 {
+	ids := make([]uint64, len(csts))
+	for i, c := range csts {
+		ids[i] = e.valueID(c)
+	}
+	return ids
+}
+
+// ### [ Helper functions ] ####################################################
+
+// intValue returns the value of the given integer constant of at most 64 bits,
+// sign-extended from the bit size of the integer type.
+func intValue(c *constant.Int) int64 {
+	words := intWords(c)
+	shift := 64 - c.Typ.BitSize
+	return int64(words[0]<<shift) >> shift
+}
+
+// intWords returns the two's complement representation of the given integer
+// constant as 64-bit words in little-endian order, truncated to the active
+// words of the value.
+func intWords(c *constant.Int) []uint64 {
+	// Truncate to bit size.
+	mask := new(big.Int).Lsh(big.NewInt(1), uint(c.Typ.BitSize))
+	x := new(big.Int).Mod(c.X, mask)
+	var words []uint64
+	word := new(big.Int)
+	wordMask := new(big.Int).SetUint64(math.MaxUint64)
+	for x.Sign() != 0 {
+		words = append(words, word.And(x, wordMask).Uint64())
+		x.Rsh(x, 64)
+	}
+	if len(words) == 0 {
+		words = append(words, 0)
+	}
+	return words
+}
+
+// floatOps returns the record operands of the bit representation of the given
+// floating-point constant.
+func floatOps(c *constant.Float) []uint64 {
+	neg := c.X != nil && c.X.Signbit()
+	switch c.Typ.Kind {
+	case types.FloatKindHalf:
+		if c.NaN {
+			if neg {
+				return []uint64{uint64(binary16.NegNaN.Bits())}
+			}
+			return []uint64{uint64(binary16.NaN.Bits())}
+		}
+		f, _ := binary16.NewFromBig(c.X)
+		return []uint64{uint64(f.Bits())}
+	case types.FloatKindFloat:
+		if c.NaN {
+			return []uint64{0x7FC00000 | btou(neg)<<31}
+		}
+		f, _ := c.X.Float32()
+		return []uint64{uint64(math.Float32bits(f))}
+	case types.FloatKindDouble:
+		if c.NaN {
+			return []uint64{0x7FF8000000000000 | btou(neg)<<63}
+		}
+		f, _ := c.X.Float64()
+		return []uint64{math.Float64bits(f)}
+	case types.FloatKindX86_FP80:
+		var se uint16
+		var m uint64
+		switch {
+		case c.NaN && neg:
+			se, m = float80x86.NegNaN.Bits()
+		case c.NaN:
+			se, m = float80x86.NaN.Bits()
+		default:
+			f, _ := float80x86.NewFromBig(c.X)
+			se, m = f.Bits()
+		}
+		// Bits are stored as [se<<48 | mantissa>>16, mantissa&0xFFFF].
+		return []uint64{uint64(se)<<48 | m>>16, m & 0xFFFF}
+	case types.FloatKindFP128:
+		var a, b uint64
+		switch {
+		case c.NaN && neg:
+			a, b = binary128.NegNaN.Bits()
+		case c.NaN:
+			a, b = binary128.NaN.Bits()
+		default:
+			f, _ := binary128.NewFromBig(c.X)
+			a, b = f.Bits()
+		}
+		return []uint64{a, b}
+	case types.FloatKindPPC_FP128:
+		var a, b uint64
+		switch {
+		case c.NaN && neg:
+			a, b = float128ppc.NegNaN.Bits()
+		case c.NaN:
+			a, b = float128ppc.NaN.Bits()
+		default:
+			f, _ := float128ppc.NewFromBig(c.X)
+			a, b = f.Bits()
+		}
+		return []uint64{a, b}
+	default:
+		panic(fmt.Errorf("support for floating-point kind %v not yet implemented", c.Typ.Kind))
+	}
+}
+
+// blockIndex returns the index of the given basic block in the function.
+func blockIndex(f *Func, block value.Named) uint64 {
+	for i, b := range f.Blocks {
+		if b == block {
+			return uint64(i)
+		}
+	}
+	panic(fmt.Errorf("unable to locate basic block %s in function %s", block.Ident(), f.Ident()))
+}
+
+// bytesOps returns the record operands of the given bytes, one byte per
+// operand.
+func bytesOps(b []byte) []uint64 {
+	ops := make([]uint64, len(b))
+	for i, c := range b {
+		ops[i] = uint64(c)
+	}
+	return ops
+}
+
+// containsZero reports whether the given bytes contain a zero byte.
+func containsZero(b []byte) bool {
+	for _, c := range b {
+		if c == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeOverflowFlags encodes the given overflow flags of an overflowing binary
+// operation.
+func encodeOverflowFlags(flags []enum.OverflowFlag) uint64 {
+	var v uint64
+	for _, flag := range flags {
+		switch flag {
+		case enum.OverflowFlagNUW:
+			v |= 1 << bitc.OBONoUnsignedWrap
+		case enum.OverflowFlagNSW:
+			v |= 1 << bitc.OBONoSignedWrap
+		}
+	}
+	return v
+}
+
+// encodeIPred encodes the given integer comparison predicate.
+func encodeIPred(pred enum.IPred) uint64 {
+	// Predicate values of llvm::CmpInst::Predicate.
+	switch pred {
+	case enum.IPredEQ:
+		return 32
+	case enum.IPredNE:
+		return 33
+	case enum.IPredUGT:
+		return 34
+	case enum.IPredUGE:
+		return 35
+	case enum.IPredULT:
+		return 36
+	case enum.IPredULE:
+		return 37
+	case enum.IPredSGT:
+		return 38
+	case enum.IPredSGE:
+		return 39
+	case enum.IPredSLT:
+		return 40
+	case enum.IPredSLE:
+		return 41
+	default:
+		panic(fmt.Errorf("support for integer comparison predicate %v not yet implemented", pred))
+	}
+}
+
+// encodeFPred encodes the given floating-point comparison predicate.
+func encodeFPred(pred enum.FPred) uint64 {
+	// Predicate values of llvm::CmpInst::Predicate.
+	switch pred {
+	case enum.FPredFalse:
+		return 0
+	case enum.FPredOEQ:
+		return 1
+	case enum.FPredOGT:
+		return 2
+	case enum.FPredOGE:
+		return 3
+	case enum.FPredOLT:
+		return 4
+	case enum.FPredOLE:
+		return 5
+	case enum.FPredONE:
+		return 6
+	case enum.FPredORD:
+		return 7
+	case enum.FPredUNO:
+		return 8
+	case enum.FPredUEQ:
+		return 9
+	case enum.FPredUGT:
+		return 10
+	case enum.FPredUGE:
+		return 11
+	case enum.FPredULT:
+		return 12
+	case enum.FPredULE:
+		return 13
+	case enum.FPredUNE:
+		return 14
+	case enum.FPredTrue:
+		return 15
+	default:
+		panic(fmt.Errorf("support for floating-point comparison predicate %v not yet implemented", pred))
+	}
+}
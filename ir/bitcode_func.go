@@ -0,0 +1,775 @@
+package ir
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/llir/llvm/internal/bitc"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/metadata"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// --- [ Function block ] ------------------------------------------------------
+
+// funcEncoder holds the function-level state of the function being encoded.
+type funcEncoder struct {
+	// Function being encoded.
+	f *Func
+	// Function-level value IDs (parameters, constants and instructions), indexed
+	// by value key.
+	valueIDs map[interface{}]uint64
+	// Function-level constants, including inline assembler expressions.
+	consts []value.Value
+	// Value ID of the next instruction.
+	instID uint64
+	// Basic block IDs, indexed by basic block.
+	blockIDs map[*Block]uint64
+	// Function signatures of inline assembler expressions used as callees.
+	asmSigs map[*InlineAsm]*types.FuncType
+	// Function-level metadata.
+	mds []metadata.Field
+	// Function-level metadata IDs, indexed by metadata key.
+	mdIDs map[interface{}]uint64
+	// Debug location of the last DEBUG_LOC record.
+	lastLoc *metadata.DILocation
+}
+
+// writeFunctionBlock writes the FUNCTION_BLOCK of the given function
+// definition.
+func (e *encoder) writeFunctionBlock(f *Func) {
+	e.fn = &funcEncoder{
+		f:        f,
+		valueIDs: make(map[interface{}]uint64),
+		blockIDs: make(map[*Block]uint64),
+		asmSigs:  make(map[*InlineAsm]*types.FuncType),
+		mdIDs:    make(map[interface{}]uint64),
+	}
+	defer func() { e.fn = nil }()
+	e.enumerateFunction(f)
+	e.bs.EnterBlock(bitc.FunctionBlockID, 4)
+	// DECLAREBLOCKS: [n]
+	e.emit(bitc.FuncCodeDeclareBlocks, []uint64{uint64(len(f.Blocks))})
+	e.writeConstantsBlock(e.fn.consts)
+	e.writeFuncMetadataBlock()
+	for _, block := range f.Blocks {
+		for _, inst := range instsOf(block) {
+			e.writeInst(inst)
+			e.writeDebugLoc(inst)
+			if v, ok := inst.(value.Value); ok && !v.Type().Equal(types.Void) {
+				e.fn.instID++
+			}
+		}
+	}
+	e.writeFuncVSTBlock(f)
+	e.writeMetadataAttachmentBlock(f)
+	e.bs.ExitBlock()
+}
+
+// enumerateFunction enumerates the basic blocks, parameters, function-level
+// constants, instructions and function-level metadata of the given function.
+func (e *encoder) enumerateFunction(f *Func) {
+	fn := e.fn
+	for i, block := range f.Blocks {
+		fn.blockIDs[block] = uint64(i)
+	}
+	// Value IDs of parameters follow the module-level values.
+	id := uint64(len(e.values))
+	for _, param := range f.Params {
+		fn.valueIDs[valueKey(param)] = id
+		id++
+	}
+	// Function-level constants.
+	for _, block := range f.Blocks {
+		for _, inst := range instsOf(block) {
+			user := inst.(value.User)
+			if call, ok := inst.(*InstCall); ok {
+				if asm, ok := call.Callee.(*InlineAsm); ok {
+					fn.asmSigs[asm] = call.Sig()
+				}
+			}
+			if alloca, ok := inst.(*InstAlloca); ok && alloca.NElems == nil {
+				// The number of elements of alloca instructions is always stored.
+				e.enumerateFuncConstant(constant.NewInt(types.I32, 1))
+			}
+			for _, op := range user.Operands() {
+				e.enumerateFuncValue(*op)
+			}
+			for _, bundle := range operandBundles(user) {
+				for _, input := range bundle.Inputs {
+					e.enumerateFuncValue(input)
+				}
+			}
+		}
+	}
+	id += uint64(len(fn.consts))
+	// Instructions of non-void type.
+	fn.instID = id
+	for _, block := range f.Blocks {
+		for _, inst := range instsOf(block) {
+			if v, ok := inst.(value.Value); ok && !v.Type().Equal(types.Void) {
+				fn.valueIDs[valueKey(v)] = id
+				id++
+			}
+		}
+	}
+	e.enumerateFuncMetadata(f)
+}
+
+// enumerateFuncValue enumerates the given operand value of an instruction, if
+// it is a constant or inline assembler expression not present in the
+// module-level value table.
+func (e *encoder) enumerateFuncValue(v value.Value) {
+	switch v := unwrapArg(v).(type) {
+	case nil, *metadata.Value:
+		// Metadata operands are enumerated separately.
+	case *InlineAsm:
+		if _, ok := e.fn.valueIDs[valueKey(v)]; !ok {
+			e.addFuncConst(v)
+		}
+	case constant.Constant:
+		e.enumerateFuncConstant(v)
+	}
+}
+
+// enumerateFuncConstant enumerates the given constant and its operands, adding
+// those not present in the module-level value table to the function-level
+// constants.
+func (e *encoder) enumerateFuncConstant(c constant.Constant) {
+	if index, ok := c.(*constant.Index); ok {
+		c = index.Constant
+	}
+	key := valueKey(c)
+	if _, ok := e.valueIDs[key]; ok {
+		return
+	}
+	if _, ok := e.fn.valueIDs[key]; ok {
+		return
+	}
+	if isGlobalValue(c) {
+		panic(fmt.Errorf("unable to locate global value %s in module", c.Ident()))
+	}
+	for _, op := range constantOperands(c) {
+		e.enumerateFuncConstant(op)
+	}
+	e.addFuncConst(c)
+}
+
+// addFuncConst adds the given constant or inline assembler expression to the
+// function-level constants.
+func (e *encoder) addFuncConst(c value.Value) {
+	fn := e.fn
+	fn.valueIDs[valueKey(c)] = uint64(len(e.values)+len(fn.f.Params)) + uint64(len(fn.consts))
+	fn.consts = append(fn.consts, c)
+}
+
+// ~~~ [ Instructions ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// writeInst writes the instruction record of the given instruction or
+// terminator.
+func (e *encoder) writeInst(inst interface{}) {
+	a := &e.abbrevs
+	switch inst := inst.(type) {
+	// Unary instructions.
+	case *InstFNeg:
+		// UNOP: [opval, opcode, flags?]
+		ops := append(e.pair(inst.X), bitc.UnopFNeg)
+		if fmf := encodeFastMathFlags(inst.FastMathFlags); fmf != 0 {
+			ops = append(ops, fmf)
+		}
+		e.emit(bitc.FuncCodeInstUnop, ops, a.fnUnop, a.fnUnopFlags)
+	// Binary and bitwise instructions.
+	case *InstAdd:
+		e.writeBinop(inst.X, inst.Y, bitc.BinopAdd, encodeOverflowFlags(inst.OverflowFlags))
+	case *InstFAdd:
+		e.writeBinop(inst.X, inst.Y, bitc.BinopAdd, encodeFastMathFlags(inst.FastMathFlags))
+	case *InstSub:
+		e.writeBinop(inst.X, inst.Y, bitc.BinopSub, encodeOverflowFlags(inst.OverflowFlags))
+	case *InstFSub:
+		e.writeBinop(inst.X, inst.Y, bitc.BinopSub, encodeFastMathFlags(inst.FastMathFlags))
+	case *InstMul:
+		e.writeBinop(inst.X, inst.Y, bitc.BinopMul, encodeOverflowFlags(inst.OverflowFlags))
+	case *InstFMul:
+		e.writeBinop(inst.X, inst.Y, bitc.BinopMul, encodeFastMathFlags(inst.FastMathFlags))
+	case *InstUDiv:
+		e.writeBinop(inst.X, inst.Y, bitc.BinopUDiv, btou(inst.Exact)<<bitc.PEOExact)
+	case *InstSDiv:
+		e.writeBinop(inst.X, inst.Y, bitc.BinopSDiv, btou(inst.Exact)<<bitc.PEOExact)
+	case *InstFDiv:
+		e.writeBinop(inst.X, inst.Y, bitc.BinopSDiv, encodeFastMathFlags(inst.FastMathFlags))
+	case *InstURem:
+		e.writeBinop(inst.X, inst.Y, bitc.BinopURem, 0)
+	case *InstSRem:
+		e.writeBinop(inst.X, inst.Y, bitc.BinopSRem, 0)
+	case *InstFRem:
+		e.writeBinop(inst.X, inst.Y, bitc.BinopSRem, encodeFastMathFlags(inst.FastMathFlags))
+	case *InstShl:
+		e.writeBinop(inst.X, inst.Y, bitc.BinopShl, encodeOverflowFlags(inst.OverflowFlags))
+	case *InstLShr:
+		e.writeBinop(inst.X, inst.Y, bitc.BinopLShr, btou(inst.Exact)<<bitc.PEOExact)
+	case *InstAShr:
+		e.writeBinop(inst.X, inst.Y, bitc.BinopAShr, btou(inst.Exact)<<bitc.PEOExact)
+	case *InstAnd:
+		e.writeBinop(inst.X, inst.Y, bitc.BinopAnd, 0)
+	case *InstOr:
+		e.writeBinop(inst.X, inst.Y, bitc.BinopOr, 0)
+	case *InstXor:
+		e.writeBinop(inst.X, inst.Y, bitc.BinopXor, 0)
+	// Vector instructions.
+	case *InstExtractElement:
+		// EXTRACTELT: [opty, opval, opty, opval]
+		ops := append(e.pair(inst.X), e.pair(inst.Index)...)
+		e.emit(bitc.FuncCodeInstExtractElt, ops)
+	case *InstInsertElement:
+		// INSERTELT: [opty, opval, opval, opty, opval]
+		ops := append(e.pair(inst.X), e.rel(inst.Elem))
+		ops = append(ops, e.pair(inst.Index)...)
+		e.emit(bitc.FuncCodeInstInsertElt, ops)
+	case *InstShuffleVector:
+		// SHUFFLEVEC: [opty, opval, opval, opty, opval]
+		ops := append(e.pair(inst.X), e.rel(inst.Y))
+		ops = append(ops, e.pair(inst.Mask)...)
+		e.emit(bitc.FuncCodeInstShuffleVec, ops)
+	// Aggregate instructions.
+	case *InstExtractValue:
+		// EXTRACTVAL: [opty, opval, n x indices]
+		ops := append(e.pair(inst.X), inst.Indices...)
+		e.emit(bitc.FuncCodeInstExtractVal, ops)
+	case *InstInsertValue:
+		// INSERTVAL: [opty, opval, opty, opval, n x indices]
+		ops := append(e.pair(inst.X), e.pair(inst.Elem)...)
+		ops = append(ops, inst.Indices...)
+		e.emit(bitc.FuncCodeInstInsertVal, ops)
+	// Memory instructions.
+	case *InstAlloca:
+		e.writeAlloca(inst)
+	case *InstLoad:
+		// LOAD: [opty, op, ty, align, vol]
+		ops := append(e.pair(inst.Src), e.typeID(inst.ElemType), encodeAlign(inst.Align), btou(inst.Volatile))
+		if !inst.Atomic {
+			e.emit(bitc.FuncCodeInstLoad, ops, a.fnLoad)
+			return
+		}
+		// LOADATOMIC: [opty, op, ty, align, vol, ordering, ssid]
+		ops = append(ops, encodeOrdering(inst.Ordering), e.syncScopeID(inst.SyncScope))
+		e.emit(bitc.FuncCodeInstLoadAtomic, ops)
+	case *InstStore:
+		// STORE: [ptrty, ptr, valty, val, align, vol]
+		ops := append(e.pair(inst.Dst), e.pair(inst.Src)...)
+		ops = append(ops, encodeAlign(inst.Align), btou(inst.Volatile))
+		if !inst.Atomic {
+			e.emit(bitc.FuncCodeInstStore, ops)
+			return
+		}
+		// STOREATOMIC: [ptrty, ptr, valty, val, align, vol, ordering, ssid]
+		ops = append(ops, encodeOrdering(inst.Ordering), e.syncScopeID(inst.SyncScope))
+		e.emit(bitc.FuncCodeInstStoreAtomic, ops)
+	case *InstFence:
+		// FENCE: [ordering, ssid]
+		e.emit(bitc.FuncCodeInstFence, []uint64{encodeOrdering(inst.Ordering), e.syncScopeID(inst.SyncScope)})
+	case *InstCmpXchg:
+		// CMPXCHG: [ptrty, ptr, cmpty, cmp, new, vol, success_ordering, ssid,
+		//           failure_ordering, weak]
+		ops := append(e.pair(inst.Ptr), e.pair(inst.Cmp)...)
+		ops = append(ops, e.rel(inst.New), btou(inst.Volatile), encodeOrdering(inst.SuccessOrdering), e.syncScopeID(inst.SyncScope), encodeOrdering(inst.FailureOrdering), btou(inst.Weak))
+		e.emit(bitc.FuncCodeInstCmpXchg, ops)
+	case *InstAtomicRMW:
+		// ATOMICRMW: [ptrty, ptr, valty, val, op, vol, ordering, ssid]
+		ops := append(e.pair(inst.Dst), e.pair(inst.X)...)
+		ops = append(ops, encodeAtomicOp(inst.Op), btou(inst.Volatile), encodeOrdering(inst.Ordering), e.syncScopeID(inst.SyncScope))
+		e.emit(bitc.FuncCodeInstAtomicRMW, ops)
+	case *InstGetElementPtr:
+		// GEP: [inbounds, ty, n x operands]
+		ops := []uint64{btou(inst.InBounds), e.typeID(inst.ElemType)}
+		ops = append(ops, e.pair(inst.Src)...)
+		for _, index := range inst.Indices {
+			ops = append(ops, e.pair(index)...)
+		}
+		e.emit(bitc.FuncCodeInstGEP, ops, a.fnGEP)
+	// Conversion instructions.
+	case *InstTrunc:
+		e.writeCast(inst.From, inst.To, bitc.CastTrunc)
+	case *InstZExt:
+		e.writeCast(inst.From, inst.To, bitc.CastZExt)
+	case *InstSExt:
+		e.writeCast(inst.From, inst.To, bitc.CastSExt)
+	case *InstFPTrunc:
+		e.writeCast(inst.From, inst.To, bitc.CastFPTrunc)
+	case *InstFPExt:
+		e.writeCast(inst.From, inst.To, bitc.CastFPExt)
+	case *InstFPToUI:
+		e.writeCast(inst.From, inst.To, bitc.CastFPToUI)
+	case *InstFPToSI:
+		e.writeCast(inst.From, inst.To, bitc.CastFPToSI)
+	case *InstUIToFP:
+		e.writeCast(inst.From, inst.To, bitc.CastUIToFP)
+	case *InstSIToFP:
+		e.writeCast(inst.From, inst.To, bitc.CastSIToFP)
+	case *InstPtrToInt:
+		e.writeCast(inst.From, inst.To, bitc.CastPtrToInt)
+	case *InstIntToPtr:
+		e.writeCast(inst.From, inst.To, bitc.CastIntToPtr)
+	case *InstBitCast:
+		e.writeCast(inst.From, inst.To, bitc.CastBitCast)
+	case *InstAddrSpaceCast:
+		e.writeCast(inst.From, inst.To, bitc.CastAddrSpaceCast)
+	// Other instructions.
+	case *InstICmp:
+		// CMP2: [opty, opval, opval, pred]
+		ops := append(e.pair(inst.X), e.rel(inst.Y), encodeIPred(inst.Pred))
+		e.emit(bitc.FuncCodeInstCmp2, ops)
+	case *InstFCmp:
+		// CMP2: [opty, opval, opval, pred, flags?]
+		ops := append(e.pair(inst.X), e.rel(inst.Y), encodeFPred(inst.Pred))
+		if fmf := encodeFastMathFlags(inst.FastMathFlags); fmf != 0 {
+			ops = append(ops, fmf)
+		}
+		e.emit(bitc.FuncCodeInstCmp2, ops)
+	case *InstPhi:
+		// PHI: [ty, n x [val, bb], flags?]
+		ops := []uint64{e.typeID(inst.Typ)}
+		for _, inc := range inst.Incs {
+			// Incoming values are encoded as signed relative value IDs, as they
+			// may be forward references.
+			ops = append(ops, encodeSigned(int64(e.fn.instID)-int64(e.fnValueID(inst.Typ, inc.X))), e.bbID(inc.Pred))
+		}
+		if fmf := encodeFastMathFlags(inst.FastMathFlags); fmf != 0 {
+			ops = append(ops, fmf)
+		}
+		e.emit(bitc.FuncCodeInstPhi, ops)
+	case *InstSelect:
+		// VSELECT: [ty, opval, opval, predty, pred, flags?]
+		ops := append(e.pair(inst.ValueTrue), e.rel(inst.ValueFalse))
+		ops = append(ops, e.pair(inst.Cond)...)
+		if fmf := encodeFastMathFlags(inst.FastMathFlags); fmf != 0 {
+			ops = append(ops, fmf)
+		}
+		e.emit(bitc.FuncCodeInstVSelect, ops)
+	case *InstFreeze:
+		// FREEZE: [opty, opval]
+		e.emit(bitc.FuncCodeInstFreeze, e.pair(inst.X))
+	case *InstCall:
+		e.writeOperandBundles(inst.OperandBundles)
+		// CALL: [paramattrs, cc, fmf?, fnty, fnid, args...]
+		sig := inst.Sig()
+		ccInfo := encodeCallingConv(inst.CallingConv)<<bitc.CallCConv | 1<<bitc.CallExplicitType
+		switch inst.Tail {
+		case enum.TailTail:
+			ccInfo |= 1 << bitc.CallTail
+		case enum.TailMustTail:
+			ccInfo |= 1<<bitc.CallTail | 1<<bitc.CallMustTail
+		case enum.TailNoTail:
+			ccInfo |= 1 << bitc.CallNoTail
+		}
+		fmf := encodeFastMathFlags(inst.FastMathFlags)
+		if fmf != 0 {
+			ccInfo |= 1 << bitc.CallFMF
+		}
+		ops := []uint64{e.callAttrSetID(inst.FuncAttrs, inst.ReturnAttrs, inst.Args), ccInfo}
+		if fmf != 0 {
+			ops = append(ops, fmf)
+		}
+		ops = append(ops, e.typeID(sig))
+		ops = append(ops, e.pair(inst.Callee)...)
+		ops = append(ops, e.argOps(sig, inst.Args)...)
+		e.emit(bitc.FuncCodeInstCall, ops)
+	case *InstVAArg:
+		// VAARG: [valistty, valist, instty]
+		e.emit(bitc.FuncCodeInstVAArg, []uint64{e.typeID(inst.ArgList.Type()), e.rel(inst.ArgList), e.typeID(inst.ArgType)})
+	case *InstLandingPad:
+		// LANDINGPAD: [ty, iscleanup, n x [clausety, val]]
+		ops := []uint64{e.typeID(inst.ResultType), btou(inst.Cleanup), uint64(len(inst.Clauses))}
+		for _, clause := range inst.Clauses {
+			ops = append(ops, btou(clause.Type == enum.ClauseTypeFilter))
+			ops = append(ops, e.pair(clause.X)...)
+		}
+		e.emit(bitc.FuncCodeInstLandingPad, ops)
+	case *InstCatchPad:
+		// CATCHPAD: [parentpad, n, n x [ty, val]]
+		e.emit(bitc.FuncCodeInstCatchPad, e.padOps(inst.CatchSwitch, inst.Args))
+	case *InstCleanupPad:
+		// CLEANUPPAD: [parentpad, n, n x [ty, val]]
+		e.emit(bitc.FuncCodeInstCleanupPad, e.padOps(inst.ParentPad, inst.Args))
+	// Terminators.
+	case *TermRet:
+		// RET: [opty, opval]
+		if inst.X == nil {
+			e.emit(bitc.FuncCodeInstRet, nil, a.fnRetVoid)
+			return
+		}
+		e.emit(bitc.FuncCodeInstRet, e.pair(inst.X), a.fnRetVal)
+	case *TermBr:
+		// BR: [bb]
+		e.emit(bitc.FuncCodeInstBr, []uint64{e.bbID(inst.Target)})
+	case *TermCondBr:
+		// BR: [bb, bb, cond]
+		e.emit(bitc.FuncCodeInstBr, []uint64{e.bbID(inst.TargetTrue), e.bbID(inst.TargetFalse), e.rel(inst.Cond)})
+	case *TermSwitch:
+		// SWITCH: [opty, op, default, n x [value, bb]]
+		ops := []uint64{e.typeID(inst.X.Type()), e.rel(inst.X), e.bbID(inst.TargetDefault)}
+		for _, c := range inst.Cases {
+			// Case values are encoded as absolute value IDs.
+			ops = append(ops, e.valueID(c.X), e.bbID(c.Target))
+		}
+		e.emit(bitc.FuncCodeInstSwitch, ops)
+	case *TermIndirectBr:
+		// INDIRECTBR: [opty, op, n x bb]
+		ops := []uint64{e.typeID(inst.Addr.Type()), e.rel(inst.Addr)}
+		for _, target := range inst.ValidTargets {
+			ops = append(ops, e.bbID(target))
+		}
+		e.emit(bitc.FuncCodeInstIndirectBr, ops)
+	case *TermInvoke:
+		e.writeOperandBundles(inst.OperandBundles)
+		// INVOKE: [attrs, cc, normbb, unwindbb, fnty, fnid, args...]
+		const explicitType = 13
+		sig := inst.Sig()
+		ops := []uint64{
+			e.callAttrSetID(inst.FuncAttrs, inst.ReturnAttrs, inst.Args),
+			encodeCallingConv(inst.CallingConv) | 1<<explicitType,
+			e.bbID(inst.NormalRetTarget),
+			e.bbID(inst.ExceptionRetTarget),
+			e.typeID(sig),
+		}
+		ops = append(ops, e.pair(inst.Invokee)...)
+		ops = append(ops, e.argOps(sig, inst.Args)...)
+		e.emit(bitc.FuncCodeInstInvoke, ops)
+	case *TermCallBr:
+		e.writeOperandBundles(inst.OperandBundles)
+		// CALLBR: [attrs, cc, normbb, n, n x otherbb, fnty, fnid, args...]
+		sig := inst.Sig()
+		ops := []uint64{
+			e.callAttrSetID(inst.FuncAttrs, inst.ReturnAttrs, inst.Args),
+			encodeCallingConv(inst.CallingConv)<<bitc.CallCConv | 1<<bitc.CallExplicitType,
+			e.bbID(inst.NormalRetTarget),
+			uint64(len(inst.OtherRetTargets)),
+		}
+		for _, target := range inst.OtherRetTargets {
+			ops = append(ops, e.bbID(target))
+		}
+		ops = append(ops, e.typeID(sig))
+		ops = append(ops, e.pair(inst.Callee)...)
+		ops = append(ops, e.argOps(sig, inst.Args)...)
+		e.emit(bitc.FuncCodeInstCallBr, ops)
+	case *TermResume:
+		// RESUME: [opty, opval]
+		e.emit(bitc.FuncCodeInstResume, e.pair(inst.X))
+	case *TermCatchSwitch:
+		// CATCHSWITCH: [parentpad, n, n x bb, unwindbb?]
+		ops := []uint64{e.rel(inst.ParentPad), uint64(len(inst.Handlers))}
+		for _, handler := range inst.Handlers {
+			ops = append(ops, e.bbID(handler))
+		}
+		if inst.DefaultUnwindTarget != nil {
+			ops = append(ops, e.bbID(inst.DefaultUnwindTarget))
+		}
+		e.emit(bitc.FuncCodeInstCatchSwitch, ops)
+	case *TermCatchRet:
+		// CATCHRET: [catchpad, bb]
+		e.emit(bitc.FuncCodeInstCatchRet, []uint64{e.rel(inst.CatchPad), e.bbID(inst.Target)})
+	case *TermCleanupRet:
+		// CLEANUPRET: [cleanuppad, bb?]
+		ops := []uint64{e.rel(inst.CleanupPad)}
+		if inst.UnwindTarget != nil {
+			ops = append(ops, e.bbID(inst.UnwindTarget))
+		}
+		e.emit(bitc.FuncCodeInstCleanupRet, ops)
+	case *TermUnreachable:
+		// UNREACHABLE: []
+		e.emit(bitc.FuncCodeInstUnreachable, nil, a.fnUnreachable)
+	default:
+		panic(fmt.Errorf("support for instruction %T not yet implemented", inst))
+	}
+}
+
+// writeBinop writes the BINOP record of a binary instruction.
+func (e *encoder) writeBinop(x, y value.Value, opcode, flags uint64) {
+	// BINOP: [opval, opval, opcode, flags?]
+	ops := append(e.pair(x), e.rel(y), opcode)
+	if flags != 0 {
+		ops = append(ops, flags)
+	}
+	e.emit(bitc.FuncCodeInstBinop, ops, e.abbrevs.fnBinop, e.abbrevs.fnBinopFlags)
+}
+
+// writeCast writes the CAST record of a conversion instruction.
+func (e *encoder) writeCast(from value.Value, to types.Type, opcode uint64) {
+	// CAST: [opval, opty, destty, castopc]
+	ops := append(e.pair(from), e.typeID(to), opcode)
+	e.emit(bitc.FuncCodeInstCast, ops, e.abbrevs.fnCast)
+}
+
+// writeAlloca writes the ALLOCA record of the given alloca instruction.
+func (e *encoder) writeAlloca(inst *InstAlloca) {
+	// ALLOCA: [instty, opty, op, align, addrspace?]
+	var size value.Value = constant.NewInt(types.I32, 1)
+	if inst.NElems != nil {
+		size = inst.NElems
+	}
+	// Alignment exponent stored in bits 0-4 and 8-10.
+	align := encodeAlign(inst.Align)
+	rec := align&0x1F | align>>5<<8
+	rec |= btou(inst.InAlloca) << 5
+	rec |= 1 << 6 // explicit type
+	rec |= btou(inst.SwiftError) << 7
+	ops := []uint64{e.typeID(inst.ElemType), e.typeID(size.Type()), e.valueID(size), rec}
+	if inst.AddrSpace != 0 {
+		ops = append(ops, uint64(inst.AddrSpace))
+	}
+	e.emit(bitc.FuncCodeInstAlloca, ops)
+}
+
+// writeOperandBundles writes the OPERAND_BUNDLE records of the given operand
+// bundles, which precede the record of the call instruction.
+func (e *encoder) writeOperandBundles(bundles []*OperandBundle) {
+	for _, bundle := range bundles {
+		// OPERAND_BUNDLE: [tag, n x [value, type?]]
+		ops := []uint64{e.bundleTagID(bundle.Tag)}
+		for _, input := range bundle.Inputs {
+			ops = append(ops, e.pair(input)...)
+		}
+		e.emit(bitc.FuncCodeOperandBundle, ops)
+	}
+}
+
+// callAttrSetID returns the attribute set ID of the given call attributes, with
+// parameter attributes of the given arguments.
+func (e *encoder) callAttrSetID(funcAttrs []FuncAttribute, retAttrs []ReturnAttribute, args []value.Value) uint64 {
+	var paramAttrs [][]ParamAttribute
+	for _, arg := range args {
+		var attrs []ParamAttribute
+		if arg, ok := arg.(*Arg); ok {
+			attrs = arg.Attrs
+		}
+		paramAttrs = append(paramAttrs, attrs)
+	}
+	return e.attrSetID(funcAttrs, retAttrs, paramAttrs)
+}
+
+// argOps returns the operands of the arguments of a call instruction with the
+// given function signature.
+func (e *encoder) argOps(sig *types.FuncType, args []value.Value) []uint64 {
+	var ops []uint64
+	for i, arg := range args {
+		arg = unwrapArg(arg)
+		if i >= len(sig.Params) {
+			// Variadic arguments are stored with type.
+			ops = append(ops, e.pair(arg)...)
+			continue
+		}
+		if _, ok := sig.Params[i].(*types.LabelType); ok {
+			ops = append(ops, e.bbID(arg))
+			continue
+		}
+		ops = append(ops, e.rel(arg))
+	}
+	return ops
+}
+
+// padOps returns the [parentpad, n, n x [ty, val]] operands of a catchpad or
+// cleanuppad instruction.
+func (e *encoder) padOps(parentPad value.Value, args []value.Value) []uint64 {
+	ops := []uint64{e.rel(parentPad), uint64(len(args))}
+	for _, arg := range args {
+		ops = append(ops, e.pair(arg)...)
+	}
+	return ops
+}
+
+// ~~~ [ Value symbol table ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// writeFuncVSTBlock writes the function-level VALUE_SYMTAB_BLOCK of the given
+// function, which holds the names of parameters, basic blocks and
+// instructions.
+func (e *encoder) writeFuncVSTBlock(f *Func) {
+	a := &e.abbrevs
+	type entry struct {
+		code, id uint64
+		name     string
+	}
+	var entries []entry
+	for _, param := range f.Params {
+		if name, ok := localName(param); ok {
+			entries = append(entries, entry{code: bitc.VSTCodeEntry, id: e.valueID(param), name: name})
+		}
+	}
+	for _, block := range f.Blocks {
+		if name, ok := localName(block); ok {
+			entries = append(entries, entry{code: bitc.VSTCodeBBEntry, id: e.bbID(block), name: name})
+		}
+		for _, inst := range instsOf(block) {
+			if n, ok := inst.(namedVar); ok {
+				if name, ok := localName(n); ok {
+					entries = append(entries, entry{code: bitc.VSTCodeEntry, id: e.valueID(n), name: name})
+				}
+			}
+		}
+	}
+	if len(entries) == 0 {
+		return
+	}
+	e.bs.EnterBlock(bitc.ValueSymtabBlockID, 4)
+	for _, entry := range entries {
+		ops := append([]uint64{entry.id}, stringOps(entry.name)...)
+		if entry.code == bitc.VSTCodeBBEntry {
+			// VST_BBENTRY: [bbid, namechar x N]
+			e.emit(bitc.VSTCodeBBEntry, ops, a.vstBBEntry6)
+			continue
+		}
+		// VST_ENTRY: [valueid, namechar x N]
+		e.emit(bitc.VSTCodeEntry, ops, a.vstEntry6, a.vstEntry7, a.vstEntry8)
+	}
+	e.bs.ExitBlock()
+}
+
+// ### [ Helper functions ] ####################################################
+
+// rel returns the value ID of the given value relative to the ID of the next
+// instruction. Metadata values are referred to by metadata ID.
+func (e *encoder) rel(v value.Value) uint64 {
+	return uint64(uint32(e.fn.instID) - uint32(e.fnValueID(v.Type(), v)))
+}
+
+// pair returns the relative value ID of the given value, followed by its type
+// if the value is a forward reference.
+func (e *encoder) pair(v value.Value) []uint64 {
+	id := e.fnValueID(v.Type(), v)
+	ops := []uint64{uint64(uint32(e.fn.instID) - uint32(id))}
+	if id >= e.fn.instID {
+		ops = append(ops, e.typeID(v.Type()))
+	}
+	return ops
+}
+
+// fnValueID returns the absolute value ID of the given value of the specified
+// type; or the metadata ID if the type is the metadata type.
+func (e *encoder) fnValueID(typ types.Type, v value.Value) uint64 {
+	v = unwrapArg(v)
+	if md, ok := v.(*metadata.Value); ok {
+		return e.mdID(md)
+	}
+	if _, ok := typ.(*types.MetadataType); ok {
+		if md, ok := v.(metadata.Field); ok {
+			return e.mdID(md)
+		}
+	}
+	return e.valueID(v)
+}
+
+// bbID returns the basic block ID of the given basic block.
+func (e *encoder) bbID(v value.Value) uint64 {
+	block, ok := v.(*Block)
+	if !ok {
+		panic(fmt.Errorf("invalid basic block; expected *ir.Block, got %T", v))
+	}
+	id, ok := e.fn.blockIDs[block]
+	if !ok {
+		panic(fmt.Errorf("unable to locate basic block %s in function %s", block.Ident(), e.fn.f.Ident()))
+	}
+	return id
+}
+
+// localName returns the name of the given local variable, and a boolean
+// indicating whether it is named.
+func localName(n namedVar) (string, bool) {
+	if n.IsUnnamed() {
+		return "", false
+	}
+	name := n.Name()
+	// Numeric names are quoted to distinguish them from local IDs.
+	if strings.HasPrefix(name, `"`) {
+		if s, err := strconv.Unquote(name); err == nil {
+			return s, true
+		}
+	}
+	return name, true
+}
+
+// encodeFastMathFlags encodes the given fast-math flags.
+func encodeFastMathFlags(flags []enum.FastMathFlag) uint64 {
+	var v uint64
+	for _, flag := range flags {
+		switch flag {
+		case enum.FastMathFlagFast:
+			v |= bitc.FMFAllowReassoc | bitc.FMFNoNaNs | bitc.FMFNoInfs | bitc.FMFNoSignedZeros | bitc.FMFAllowReciprocal | bitc.FMFAllowContract | bitc.FMFApproxFunc
+		case enum.FastMathFlagReassoc:
+			v |= bitc.FMFAllowReassoc
+		case enum.FastMathFlagNNaN:
+			v |= bitc.FMFNoNaNs
+		case enum.FastMathFlagNInf:
+			v |= bitc.FMFNoInfs
+		case enum.FastMathFlagNSZ:
+			v |= bitc.FMFNoSignedZeros
+		case enum.FastMathFlagARcp:
+			v |= bitc.FMFAllowReciprocal
+		case enum.FastMathFlagContract:
+			v |= bitc.FMFAllowContract
+		case enum.FastMathFlagAFn:
+			v |= bitc.FMFApproxFunc
+		}
+	}
+	return v
+}
+
+// encodeOrdering encodes the given atomic ordering.
+func encodeOrdering(ordering enum.AtomicOrdering) uint64 {
+	switch ordering {
+	case enum.AtomicOrderingNone:
+		return bitc.OrderingNotAtomic
+	case enum.AtomicOrderingUnordered:
+		return bitc.OrderingUnordered
+	case enum.AtomicOrderingMonotonic:
+		return bitc.OrderingMonotonic
+	case enum.AtomicOrderingAcquire:
+		return bitc.OrderingAcquire
+	case enum.AtomicOrderingRelease:
+		return bitc.OrderingRelease
+	case enum.AtomicOrderingAcquireRelease:
+		return bitc.OrderingAcqRel
+	case enum.AtomicOrderingSequentiallyConsistent:
+		return bitc.OrderingSeqCst
+	default:
+		panic(fmt.Errorf("support for atomic ordering %v not yet implemented", ordering))
+	}
+}
+
+// encodeAtomicOp encodes the given atomicrmw binary operation.
+func encodeAtomicOp(op enum.AtomicOp) uint64 {
+	switch op {
+	case enum.AtomicOpXChg:
+		return bitc.RMWXchg
+	case enum.AtomicOpAdd:
+		return bitc.RMWAdd
+	case enum.AtomicOpSub:
+		return bitc.RMWSub
+	case enum.AtomicOpAnd:
+		return bitc.RMWAnd
+	case enum.AtomicOpNAnd:
+		return bitc.RMWNand
+	case enum.AtomicOpOr:
+		return bitc.RMWOr
+	case enum.AtomicOpXor:
+		return bitc.RMWXor
+	case enum.AtomicOpMax:
+		return bitc.RMWMax
+	case enum.AtomicOpMin:
+		return bitc.RMWMin
+	case enum.AtomicOpUMax:
+		return bitc.RMWUMax
+	case enum.AtomicOpUMin:
+		return bitc.RMWUMin
+	case enum.AtomicOpFAdd:
+		return bitc.RMWFAdd
+	case enum.AtomicOpFSub:
+		return bitc.RMWFSub
+	case enum.AtomicOpFMax:
+		return bitc.RMWFMax
+	case enum.AtomicOpFMin:
+		return bitc.RMWFMin
+	default:
+		panic(fmt.Errorf("support for atomicrmw binary operation %v not yet implemented", op))
+	}
+}
@@ -0,0 +1,1011 @@
+package ir
+
+import (
+	"fmt"
+
+	"github.com/llir/llvm/internal/bitc"
+	"github.com/llir/llvm/internal/bitstream"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/metadata"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// --- [ Metadata kind block ] -------------------------------------------------
+
+// fixedMDKinds specifies the metadata kinds with fixed IDs.
+var fixedMDKinds = []string{
+	"dbg",
+	"tbaa",
+	"prof",
+	"fpmath",
+	"range",
+	"tbaa.struct",
+	"invariant.load",
+	"alias.scope",
+	"noalias",
+	"nontemporal",
+	"llvm.mem.parallel_loop_access",
+	"nonnull",
+	"dereferenceable",
+	"dereferenceable_or_null",
+	"make.implicit",
+	"unpredictable",
+	"invariant.group",
+	"align",
+	"llvm.loop",
+	"type",
+	"section_prefix",
+	"absolute_symbol",
+	"associated",
+	"callees",
+	"irr_loop",
+	"llvm.access.group",
+	"callback",
+	"llvm.preserve.access.index",
+	"vcall_visibility",
+	"noundef",
+	"annotation",
+}
+
+// mdKindID returns the ID of the given metadata kind.
+func (e *encoder) mdKindID(name string) uint64 {
+	if id, ok := e.mdKindIDs[name]; ok {
+		return id
+	}
+	if e.final {
+		panic(fmt.Errorf("unable to locate metadata kind %q", name))
+	}
+	id := uint64(len(e.mdKinds))
+	e.mdKinds = append(e.mdKinds, name)
+	e.mdKindIDs[name] = id
+	return id
+}
+
+// writeMetadataKindBlock writes the METADATA_KIND_BLOCK.
+func (e *encoder) writeMetadataKindBlock() {
+	e.bs.EnterBlock(bitc.MetadataKindBlockID, 3)
+	for id, name := range e.mdKinds {
+		// KIND: [n x [id, name]]
+		ops := append([]uint64{uint64(id)}, stringOps(name)...)
+		e.emit(bitc.MetadataKindKind, ops)
+	}
+	e.bs.ExitBlock()
+}
+
+// --- [ Metadata enumeration ] ------------------------------------------------
+
+// enumerateModuleMetadata enumerates the module-level metadata of the module;
+// that is, the metadata of named metadata definitions, of metadata attachments,
+// and of metadata operands of call instructions, except for function-local
+// metadata.
+//
+// Metadata strings precede other metadata in the metadata table, and metadata
+// nodes follow their operands, except for cyclic references.
+func (e *encoder) enumerateModuleMetadata() {
+	e.enumeratingMD = true
+	defer func() {
+		e.enumeratingMD = false
+	}()
+	for _, def := range e.sortedNamedMetadataDefs() {
+		for _, node := range def.Nodes {
+			e.mdID(mdField(node))
+		}
+	}
+	for _, g := range e.m.Globals {
+		e.enumerateAttachments(g.Metadata)
+	}
+	for _, f := range e.m.Funcs {
+		e.enumerateAttachments(f.Metadata)
+		for _, block := range f.Blocks {
+			for _, inst := range instsOf(block) {
+				e.enumerateInstMetadata(inst)
+			}
+		}
+	}
+}
+
+// enumerateInstMetadata enumerates the module-level metadata of the given
+// instruction or terminator.
+func (e *encoder) enumerateInstMetadata(inst interface{}) {
+	for _, arg := range callArgs(inst) {
+		md, ok := unwrapArg(arg).(*metadata.Value)
+		if !ok {
+			continue
+		}
+		switch v := md.Value.(type) {
+		case *metadata.DIArgList:
+			// Function-local metadata; only constant operands are module-level
+			// metadata.
+			for _, field := range v.Fields {
+				if !isLocalMD(field) {
+					e.mdID(field)
+				}
+			}
+		default:
+			if !isLocalMD(v) {
+				e.mdID(v)
+			}
+		}
+	}
+	if a, ok := inst.(metadata.Attacher); ok {
+		mds := a.MDAttachments()
+		if loc, ok := debugLocOf(mds); ok {
+			// Debug locations are stored in DEBUG_LOC records; only their
+			// operands are metadata.
+			e.mdRef(loc.Scope)
+			e.mdRef(loc.InlinedAt)
+			mds = mds[1:]
+		}
+		e.enumerateAttachments(mds)
+	}
+}
+
+// enumerateAttachments enumerates the metadata kinds and metadata nodes of the
+// given metadata attachments.
+func (e *encoder) enumerateAttachments(mds []*metadata.Attachment) {
+	for _, md := range mds {
+		e.mdKindID(md.Name)
+		e.mdID(mdField(md.Node))
+	}
+}
+
+// enumerateMD adds the given metadata and its operands to the module-level
+// metadata table.
+func (e *encoder) enumerateMD(md metadata.Field) {
+	key := mdKey(md)
+	if e.visitingMD[key] {
+		// Cyclic reference; the metadata ID is resolved by the final pass.
+		return
+	}
+	e.visitingMD[key] = true
+	defer delete(e.visitingMD, key)
+	if v, ok := md.(value.Value); ok {
+		c, ok := v.(constant.Constant)
+		if !ok {
+			panic(fmt.Errorf("invalid module-level metadata; function-local value %s", v.Ident()))
+		}
+		e.enumerateConstant(c)
+	}
+	// Enumerate operands.
+	e.mdRecord(md)
+	e.mdNodeIDs[key] = uint64(len(e.mdNodes))
+	e.mdNodes = append(e.mdNodes, md)
+}
+
+// enumerateFuncMetadata enumerates the function-local metadata of the function
+// being encoded; that is, metadata values of function-local values and
+// DIArgList metadata nodes.
+func (e *encoder) enumerateFuncMetadata(f *Func) {
+	for _, block := range f.Blocks {
+		for _, inst := range instsOf(block) {
+			for _, arg := range callArgs(inst) {
+				if md, ok := unwrapArg(arg).(*metadata.Value); ok {
+					e.addFuncMD(md.Value)
+				}
+			}
+		}
+	}
+}
+
+// addFuncMD adds the given metadata to the function-level metadata table, if
+// function-local.
+func (e *encoder) addFuncMD(md metadata.Field) {
+	if !isLocalMD(md) {
+		return
+	}
+	if list, ok := md.(*metadata.DIArgList); ok {
+		for _, field := range list.Fields {
+			e.addFuncMD(field)
+		}
+	}
+	key := mdKey(md)
+	if _, ok := e.fn.mdIDs[key]; ok {
+		return
+	}
+	e.fn.mdIDs[key] = e.numModuleMDs() + uint64(len(e.fn.mds))
+	e.fn.mds = append(e.fn.mds, md)
+}
+
+// numModuleMDs returns the number of module-level metadata.
+func (e *encoder) numModuleMDs() uint64 {
+	return uint64(len(e.mdStrings) + len(e.mdNodes))
+}
+
+// --- [ Metadata block ] ------------------------------------------------------
+
+// writeModuleMetadataBlock writes the module-level METADATA_BLOCK.
+func (e *encoder) writeModuleMetadataBlock() {
+	var declAttachments [][]uint64
+	for _, g := range e.m.Globals {
+		if len(g.Metadata) > 0 {
+			ops := append([]uint64{e.valueID(g)}, e.attachmentOps(g.Metadata)...)
+			declAttachments = append(declAttachments, ops)
+		}
+	}
+	for _, f := range e.m.Funcs {
+		// Metadata attachments of function definitions are stored in the
+		// METADATA_ATTACHMENT block of the function.
+		if len(f.Blocks) == 0 && len(f.Metadata) > 0 {
+			ops := append([]uint64{e.valueID(f)}, e.attachmentOps(f.Metadata)...)
+			declAttachments = append(declAttachments, ops)
+		}
+	}
+	namedDefs := e.sortedNamedMetadataDefs()
+	if e.numModuleMDs() == 0 && len(namedDefs) == 0 && len(declAttachments) == 0 {
+		return
+	}
+	e.bs.EnterBlock(bitc.MetadataBlockID, 3)
+	e.writeMetadataStrings()
+	// LOCATION: [distinct, line, col, scope, inlined-at?, isImplicitCode]
+	locAbbrev := e.defineAbbrev(bitstream.Literal(bitc.MetadataLocation), bitstream.Fixed(1), bitstream.VBR(6), bitstream.VBR(8), bitstream.VBR(6), bitstream.VBR(6), bitstream.Fixed(1))
+	for _, md := range e.mdNodes {
+		code, ops := e.mdRecord(md)
+		e.emit(code, ops, locAbbrev)
+	}
+	// NAME: [values]
+	nameAbbrev := e.defineAbbrev(bitstream.Literal(bitc.MetadataName), bitstream.Array(), bitstream.Fixed(8))
+	for _, def := range namedDefs {
+		e.emit(bitc.MetadataName, stringOps(def.Name), nameAbbrev)
+		// NAMED_NODE: [n x mdnodes]
+		var ops []uint64
+		for _, node := range def.Nodes {
+			ops = append(ops, e.mdID(mdField(node)))
+		}
+		e.emit(bitc.MetadataNamedNode, ops)
+	}
+	for _, ops := range declAttachments {
+		// GLOBAL_DECL_ATTACHMENT: [valueid, n x [id, mdnode]]
+		e.emit(bitc.MetadataGlobalDeclAttachment, ops)
+	}
+	e.bs.ExitBlock()
+}
+
+// writeMetadataStrings writes the STRINGS record of the module-level metadata
+// strings.
+func (e *encoder) writeMetadataStrings() {
+	if len(e.mdStrings) == 0 {
+		return
+	}
+	// STRINGS: [count, offset] blob([lengths][chars])
+	abbrev := e.defineAbbrev(bitstream.Literal(bitc.MetadataStrings), bitstream.VBR(6), bitstream.VBR(6), bitstream.Blob())
+	lengths := bitstream.NewWriter()
+	var chars []byte
+	for _, s := range e.mdStrings {
+		lengths.WriteVBR(uint64(len(s)), 6)
+		chars = append(chars, s...)
+	}
+	blob := lengths.Bytes()
+	offset := uint64(len(blob))
+	blob = append(blob, chars...)
+	ops := []uint64{uint64(len(e.mdStrings)), offset}
+	e.bs.EmitRecordWithBlob(bitc.MetadataStrings, ops, blob, abbrev.id)
+}
+
+// writeFuncMetadataBlock writes the function-level METADATA_BLOCK of the
+// function being encoded.
+func (e *encoder) writeFuncMetadataBlock() {
+	if len(e.fn.mds) == 0 {
+		return
+	}
+	e.bs.EnterBlock(bitc.MetadataBlockID, 3)
+	for _, md := range e.fn.mds {
+		code, ops := e.mdRecord(md)
+		e.emit(code, ops)
+	}
+	e.bs.ExitBlock()
+}
+
+// writeMetadataAttachmentBlock writes the METADATA_ATTACHMENT block of the
+// given function.
+func (e *encoder) writeMetadataAttachmentBlock(f *Func) {
+	var records [][]uint64
+	if len(f.Metadata) > 0 {
+		records = append(records, e.attachmentOps(f.Metadata))
+	}
+	var index uint64
+	for _, block := range f.Blocks {
+		for _, inst := range instsOf(block) {
+			if a, ok := inst.(metadata.Attacher); ok {
+				mds := a.MDAttachments()
+				if _, ok := debugLocOf(mds); ok {
+					mds = mds[1:]
+				}
+				if len(mds) > 0 {
+					ops := append([]uint64{index}, e.attachmentOps(mds)...)
+					records = append(records, ops)
+				}
+			}
+			index++
+		}
+	}
+	if len(records) == 0 {
+		return
+	}
+	e.bs.EnterBlock(bitc.MetadataAttachmentBlockID, 3)
+	for _, ops := range records {
+		// ATTACHMENT: [instid?, n x [id, mdnode]]
+		e.emit(bitc.MetadataAttachment, ops)
+	}
+	e.bs.ExitBlock()
+}
+
+// attachmentOps returns the [n x [id, mdnode]] operands of the given metadata
+// attachments.
+func (e *encoder) attachmentOps(mds []*metadata.Attachment) []uint64 {
+	var ops []uint64
+	for _, md := range mds {
+		ops = append(ops, e.mdKindID(md.Name), e.mdID(mdField(md.Node)))
+	}
+	return ops
+}
+
+// writeDebugLoc writes the DEBUG_LOC or DEBUG_LOC_AGAIN record of the debug
+// location of the given instruction, if any.
+func (e *encoder) writeDebugLoc(inst interface{}) {
+	a, ok := inst.(metadata.Attacher)
+	if !ok {
+		return
+	}
+	loc, ok := debugLocOf(a.MDAttachments())
+	if !ok {
+		return
+	}
+	if loc == e.fn.lastLoc {
+		// DEBUG_LOC_AGAIN: []
+		e.emit(bitc.FuncCodeDebugLocAgain, nil)
+		return
+	}
+	// DEBUG_LOC: [line, col, scope, inlined-at?, isImplicitCode?]
+	ops := []uint64{
+		uint64(loc.Line),
+		uint64(loc.Column),
+		e.mdRef(loc.Scope),
+		e.mdRef(loc.InlinedAt),
+		btou(loc.IsImplicitCode),
+	}
+	e.emit(bitc.FuncCodeDebugLoc, ops)
+	e.fn.lastLoc = loc
+}
+
+// --- [ Metadata records ] ----------------------------------------------------
+
+// mdID returns the metadata ID of the given metadata. While enumerating
+// metadata, the metadata is added to the metadata table if not already
+// present, and 0 is returned.
+func (e *encoder) mdID(md metadata.Field) uint64 {
+	if v, ok := md.(*metadata.Value); ok {
+		md = v.Value
+	}
+	if s, ok := md.(*metadata.String); ok {
+		return e.mdStringID(s.Value)
+	}
+	key := mdKey(md)
+	if e.fn != nil {
+		if id, ok := e.fn.mdIDs[key]; ok {
+			return id
+		}
+	}
+	if id, ok := e.mdNodeIDs[key]; ok {
+		return uint64(len(e.mdStrings)) + id
+	}
+	if !e.enumeratingMD {
+		panic(fmt.Errorf("unable to locate metadata %v", md))
+	}
+	e.enumerateMD(md)
+	return 0
+}
+
+// mdStringID returns the metadata ID of the given metadata string. While
+// enumerating metadata, the string is added to the metadata table if not
+// already present, and 0 is returned.
+func (e *encoder) mdStringID(s string) uint64 {
+	if id, ok := e.mdStringIDs[s]; ok {
+		return id
+	}
+	if !e.enumeratingMD {
+		panic(fmt.Errorf("unable to locate metadata string %q", s))
+	}
+	e.mdStringIDs[s] = uint64(len(e.mdStrings))
+	e.mdStrings = append(e.mdStrings, s)
+	return 0
+}
+
+// mdRef returns the metadata ID + 1 of the given optional metadata; or 0 if md
+// is nil.
+func (e *encoder) mdRef(md metadata.Field) uint64 {
+	if isNilMD(md) {
+		return 0
+	}
+	return e.mdID(md) + 1
+}
+
+// mdStr returns the metadata ID + 1 of the given optional metadata string; or 0
+// if s is empty.
+func (e *encoder) mdStr(s string) uint64 {
+	if len(s) == 0 {
+		return 0
+	}
+	return e.mdStringID(s) + 1
+}
+
+// mdFieldOrInt returns the metadata ID + 1 of the given optional metadata field
+// or integer; or 0 if md is nil. Integers are stored as metadata values of
+// 64-bit integer constants.
+func (e *encoder) mdFieldOrInt(md metadata.FieldOrInt) uint64 {
+	if i, ok := md.(metadata.IntLit); ok {
+		return e.mdRef(constant.NewInt(types.I64, int64(i)))
+	}
+	return e.mdRef(md)
+}
+
+// mdRecord returns the record code and operands of the given metadata.
+func (e *encoder) mdRecord(md metadata.Field) (code uint64, ops []uint64) {
+	switch md := md.(type) {
+	case *metadata.DIArgList:
+		// ARG_LIST: [n x md num]
+		for _, field := range md.Fields {
+			ops = append(ops, e.mdID(field))
+		}
+		return bitc.MetadataArgList, ops
+	case value.Value:
+		// VALUE: [ty, val]
+		return bitc.MetadataValue, []uint64{e.typeID(md.Type()), e.valueID(md)}
+	case *metadata.Tuple:
+		// NODE: [n x md num]
+		for _, field := range md.Fields {
+			ops = append(ops, e.mdRef(field))
+		}
+		if md.Distinct {
+			return bitc.MetadataDistinctNode, ops
+		}
+		return bitc.MetadataNode, ops
+	case *metadata.DILocation:
+		// LOCATION: [distinct, line, col, scope, inlined-at?, isImplicitCode]
+		ops = []uint64{
+			btou(md.Distinct),
+			uint64(md.Line),
+			uint64(md.Column),
+			e.mdID(md.Scope),
+			e.mdRef(md.InlinedAt),
+			btou(md.IsImplicitCode),
+		}
+		return bitc.MetadataLocation, ops
+	case *metadata.GenericDINode:
+		// GENERIC_DEBUG: [distinct, tag, vers, header, n x md num]
+		ops = []uint64{btou(md.Distinct), uint64(md.Tag), 0, e.mdStr(md.Header)}
+		for _, field := range md.Operands {
+			ops = append(ops, e.mdRef(field))
+		}
+		return bitc.MetadataGenericDebug, ops
+	case *metadata.DISubrange:
+		// SUBRANGE: [distinct | version << 1, count, lo, up, stride]
+		const version = 2 << 1
+		ops = []uint64{
+			btou(md.Distinct) | version,
+			e.mdFieldOrInt(md.Count),
+			e.mdFieldOrInt(md.LowerBound),
+			e.mdFieldOrInt(md.UpperBound),
+			e.mdFieldOrInt(md.Stride),
+		}
+		return bitc.MetadataSubrange, ops
+	case *metadata.DIEnumerator:
+		// ENUMERATOR: [isUnsigned | distinct, value, name]
+		ops = []uint64{
+			btou(md.Distinct) | btou(md.IsUnsigned)<<1,
+			encodeSigned(md.Value),
+			e.mdStr(md.Name),
+		}
+		return bitc.MetadataEnumerator, ops
+	case *metadata.DIBasicType:
+		// BASIC_TYPE: [distinct, tag, name, size, align, encoding, flags]
+		tag := md.Tag
+		if tag == 0 {
+			tag = enum.DwarfTagBaseType
+		}
+		ops = []uint64{
+			btou(md.Distinct),
+			uint64(tag),
+			e.mdStr(md.Name),
+			md.Size,
+			md.Align,
+			uint64(md.Encoding),
+			uint64(md.Flags),
+		}
+		return bitc.MetadataBasicType, ops
+	case *metadata.DIStringType:
+		// STRING_TYPE: [distinct, tag, name, stringLength, stringLengthExp,
+		// stringLocationExp, size, align, encoding]
+		ops = []uint64{
+			btou(md.Distinct),
+			uint64(md.Tag),
+			e.mdStr(md.Name),
+			e.mdRef(md.StringLength),
+			e.mdRef(md.StringLengthExpression),
+			e.mdRef(md.StringLocationExpression),
+			md.Size,
+			md.Align,
+			uint64(md.Encoding),
+		}
+		return bitc.MetadataStringType, ops
+	case *metadata.DIFile:
+		// FILE: [distinct, filename, directory, checksumkind, checksum, source?]
+		var checksumKind, checksum uint64
+		if md.Checksumkind != 0 && len(md.Checksum) > 0 {
+			checksumKind = uint64(md.Checksumkind)
+			checksum = e.mdStr(md.Checksum)
+		}
+		ops = []uint64{
+			btou(md.Distinct),
+			e.mdStr(md.Filename),
+			e.mdStr(md.Directory),
+			checksumKind,
+			checksum,
+		}
+		// Note, the source operand is omitted if not present, as LLVM treats a
+		// present source operand as embedded source, even if empty.
+		if len(md.Source) > 0 {
+			ops = append(ops, e.mdStr(md.Source))
+		}
+		return bitc.MetadataFile, ops
+	case *metadata.DIDerivedType:
+		// DERIVED_TYPE: [distinct, tag, name, file, line, scope, baseType, size,
+		// align, offset, flags, extraData, dwarfAddressSpace, annotations]
+		//
+		// The DWARF address space is stored as address space + 1; zero if not
+		// present.
+		var addrSpace uint64
+		if md.DwarfAddressSpace != 0 {
+			addrSpace = md.DwarfAddressSpace + 1
+		}
+		ops = []uint64{
+			btou(md.Distinct),
+			uint64(md.Tag),
+			e.mdStr(md.Name),
+			e.mdRef(md.File),
+			uint64(md.Line),
+			e.mdRef(md.Scope),
+			e.mdRef(md.BaseType),
+			md.Size,
+			md.Align,
+			md.Offset,
+			uint64(md.Flags),
+			e.mdRef(md.ExtraData),
+			addrSpace,
+			e.mdRef(md.Annotations),
+		}
+		return bitc.MetadataDerivedType, ops
+	case *metadata.DICompositeType:
+		// COMPOSITE_TYPE: [distinct, tag, name, file, line, scope, baseType,
+		// size, align, offset, flags, elements, runtimeLang, vtableHolder,
+		// templateParams, identifier, discriminator, dataLocation, associated,
+		// allocated, rank, annotations]
+		ops = []uint64{
+			btou(md.Distinct),
+			uint64(md.Tag),
+			e.mdStr(md.Name),
+			e.mdRef(md.File),
+			uint64(md.Line),
+			e.mdRef(md.Scope),
+			e.mdRef(md.BaseType),
+			md.Size,
+			md.Align,
+			md.Offset,
+			uint64(md.Flags),
+			e.mdRef(md.Elements),
+			uint64(md.RuntimeLang),
+			e.mdRef(md.VtableHolder),
+			e.mdRef(md.TemplateParams),
+			e.mdStr(md.Identifier),
+			e.mdRef(md.Discriminator),
+			e.mdRef(md.DataLocation),
+			e.mdRef(md.Associated),
+			e.mdRef(md.Allocated),
+			e.mdFieldOrInt(md.Rank),
+			e.mdRef(md.Annotations),
+		}
+		return bitc.MetadataCompositeType, ops
+	case *metadata.DISubroutineType:
+		// SUBROUTINE_TYPE: [distinct | hasNoOldTypeRefs, flags, types, cc]
+		const hasNoOldTypeRefs = 1 << 1
+		ops = []uint64{
+			btou(md.Distinct) | hasNoOldTypeRefs,
+			uint64(md.Flags),
+			e.mdRef(md.Types),
+			uint64(md.CC),
+		}
+		return bitc.MetadataSubroutineType, ops
+	case *metadata.DICompileUnit:
+		// COMPILE_UNIT: [distinct, lang, file, producer, isOptimized, flags,
+		// runtimeVersion, splitDebugFilename, emissionKind, enums,
+		// retainedTypes, subprograms, globals, imports, dwoId, macros,
+		// splitDebugInlining, debugInfoForProfiling, nameTableKind,
+		// rangesBaseAddress, sysroot, sdk]
+		ops = []uint64{
+			btou(md.Distinct),
+			uint64(md.Language),
+			e.mdRef(md.File),
+			e.mdStr(md.Producer),
+			btou(md.IsOptimized),
+			e.mdStr(md.Flags),
+			md.RuntimeVersion,
+			e.mdStr(md.SplitDebugFilename),
+			uint64(md.EmissionKind),
+			e.mdRef(md.Enums),
+			e.mdRef(md.RetainedTypes),
+			0, // legacy list of subprograms.
+			e.mdRef(md.Globals),
+			e.mdRef(md.Imports),
+			md.DwoID,
+			e.mdRef(md.Macros),
+			btou(md.SplitDebugInlining),
+			btou(md.DebugInfoForProfiling),
+			uint64(md.NameTableKind),
+			btou(md.RangesBaseAddress),
+			e.mdStr(md.Sysroot),
+			e.mdStr(md.SDK),
+		}
+		return bitc.MetadataCompileUnit, ops
+	case *metadata.DISubprogram:
+		// SUBPROGRAM: [distinct | hasUnit | hasSPFlags, scope, name,
+		// linkageName, file, line, type, scopeLine, containingType, spFlags,
+		// virtualIndex, flags, unit, templateParams, declaration,
+		// retainedNodes, thisAdjustment, thrownTypes, annotations,
+		// targetFuncName?]
+		const (
+			hasUnit    = 1 << 1
+			hasSPFlags = 1 << 2
+		)
+		ops = []uint64{
+			btou(md.Distinct) | hasUnit | hasSPFlags,
+			e.mdRef(md.Scope),
+			e.mdStr(md.Name),
+			e.mdStr(md.LinkageName),
+			e.mdRef(md.File),
+			uint64(md.Line),
+			e.mdRef(md.Type),
+			uint64(md.ScopeLine),
+			e.mdRef(md.ContainingType),
+			uint64(spFlagsOf(md)),
+			md.VirtualIndex,
+			uint64(md.Flags),
+			e.mdRef(md.Unit),
+			e.mdRef(md.TemplateParams),
+			e.mdRef(md.Declaration),
+			e.mdRef(md.RetainedNodes),
+			uint64(md.ThisAdjustment),
+			e.mdRef(md.ThrownTypes),
+			e.mdRef(md.Annotations),
+		}
+		if len(md.TargetFuncName) > 0 {
+			ops = append(ops, e.mdStr(md.TargetFuncName))
+		}
+		return bitc.MetadataSubprogram, ops
+	case *metadata.DILexicalBlock:
+		// LEXICAL_BLOCK: [distinct, scope, file, line, column]
+		ops = []uint64{
+			btou(md.Distinct),
+			e.mdRef(md.Scope),
+			e.mdRef(md.File),
+			uint64(md.Line),
+			uint64(md.Column),
+		}
+		return bitc.MetadataLexicalBlock, ops
+	case *metadata.DILexicalBlockFile:
+		// LEXICAL_BLOCK_FILE: [distinct, scope, file, discriminator]
+		ops = []uint64{
+			btou(md.Distinct),
+			e.mdRef(md.Scope),
+			e.mdRef(md.File),
+			md.Discriminator,
+		}
+		return bitc.MetadataLexicalBlockFile, ops
+	case *metadata.DICommonBlock:
+		// COMMON_BLOCK: [distinct, scope, declaration, name, file, line]
+		ops = []uint64{
+			btou(md.Distinct),
+			e.mdRef(md.Scope),
+			e.mdRef(md.Declaration),
+			e.mdStr(md.Name),
+			e.mdRef(md.File),
+			uint64(md.Line),
+		}
+		return bitc.MetadataCommonBlock, ops
+	case *metadata.DINamespace:
+		// NAMESPACE: [distinct | exportSymbols, scope, name]
+		ops = []uint64{
+			btou(md.Distinct) | btou(md.ExportSymbols)<<1,
+			e.mdRef(md.Scope),
+			e.mdStr(md.Name),
+		}
+		return bitc.MetadataNamespace, ops
+	case *metadata.DIMacro:
+		// MACRO: [distinct, macinfo, line, name, value]
+		ops = []uint64{
+			btou(md.Distinct),
+			uint64(md.Type),
+			uint64(md.Line),
+			e.mdStr(md.Name),
+			e.mdStr(md.Value),
+		}
+		return bitc.MetadataMacro, ops
+	case *metadata.DIMacroFile:
+		// MACRO_FILE: [distinct, macinfo, line, file, elements]
+		ops = []uint64{
+			btou(md.Distinct),
+			uint64(md.Type),
+			uint64(md.Line),
+			e.mdRef(md.File),
+			e.mdRef(md.Nodes),
+		}
+		return bitc.MetadataMacroFile, ops
+	case *metadata.DIModule:
+		// MODULE: [distinct, file, scope, name, configMacros, includePath,
+		// apinotes, line, isDecl]
+		ops = []uint64{
+			btou(md.Distinct),
+			e.mdRef(md.File),
+			e.mdRef(md.Scope),
+			e.mdStr(md.Name),
+			e.mdStr(md.ConfigMacros),
+			e.mdStr(md.IncludePath),
+			e.mdStr(md.APINotes),
+			uint64(md.Line),
+			btou(md.IsDecl),
+		}
+		return bitc.MetadataModule, ops
+	case *metadata.DITemplateTypeParameter:
+		// TEMPLATE_TYPE: [distinct, name, type, isDefault]
+		ops = []uint64{
+			btou(md.Distinct),
+			e.mdStr(md.Name),
+			e.mdRef(md.Type),
+			btou(md.Defaulted),
+		}
+		return bitc.MetadataTemplateType, ops
+	case *metadata.DITemplateValueParameter:
+		// TEMPLATE_VALUE: [distinct, tag, name, type, isDefault, value]
+		ops = []uint64{
+			btou(md.Distinct),
+			uint64(md.Tag),
+			e.mdStr(md.Name),
+			e.mdRef(md.Type),
+			btou(md.Defaulted),
+			e.mdRef(md.Value),
+		}
+		return bitc.MetadataTemplateValue, ops
+	case *metadata.DIGlobalVariable:
+		// GLOBAL_VAR: [distinct | version, scope, name, linkageName, file,
+		// line, type, isLocal, isDefinition, declaration, templateParams,
+		// align, annotations]
+		const version = 2 << 1
+		ops = []uint64{
+			btou(md.Distinct) | version,
+			e.mdRef(md.Scope),
+			e.mdStr(md.Name),
+			e.mdStr(md.LinkageName),
+			e.mdRef(md.File),
+			uint64(md.Line),
+			e.mdRef(md.Type),
+			btou(md.IsLocal),
+			btou(md.IsDefinition),
+			e.mdRef(md.Declaration),
+			e.mdRef(md.TemplateParams),
+			md.Align,
+			e.mdRef(md.Annotations),
+		}
+		return bitc.MetadataGlobalVar, ops
+	case *metadata.DILocalVariable:
+		// LOCAL_VAR: [distinct | hasAlignment, scope, name, file, line, type,
+		// arg, flags, align, annotations]
+		const hasAlignment = 1 << 1
+		ops = []uint64{
+			btou(md.Distinct) | hasAlignment,
+			e.mdRef(md.Scope),
+			e.mdStr(md.Name),
+			e.mdRef(md.File),
+			uint64(md.Line),
+			e.mdRef(md.Type),
+			md.Arg,
+			uint64(md.Flags),
+			md.Align,
+			e.mdRef(md.Annotations),
+		}
+		return bitc.MetadataLocalVar, ops
+	case *metadata.DILabel:
+		// LABEL: [distinct, scope, name, file, line]
+		ops = []uint64{
+			btou(md.Distinct),
+			e.mdRef(md.Scope),
+			e.mdStr(md.Name),
+			e.mdRef(md.File),
+			uint64(md.Line),
+		}
+		return bitc.MetadataLabel, ops
+	case *metadata.DIExpression:
+		// EXPRESSION: [distinct | version, n x element]
+		const version = 3 << 1
+		ops = []uint64{btou(md.Distinct) | version}
+		for _, field := range md.Fields {
+			ops = append(ops, diExpressionFieldOp(field))
+		}
+		return bitc.MetadataExpression, ops
+	case *metadata.DIGlobalVariableExpression:
+		// GLOBAL_VAR_EXPR: [distinct, var, expr]
+		ops = []uint64{
+			btou(md.Distinct),
+			e.mdRef(md.Var),
+			e.mdRef(md.Expr),
+		}
+		return bitc.MetadataGlobalVarExpr, ops
+	case *metadata.DIObjCProperty:
+		// OBJC_PROPERTY: [distinct, name, file, line, getter, setter,
+		// attributes, type]
+		ops = []uint64{
+			btou(md.Distinct),
+			e.mdStr(md.Name),
+			e.mdRef(md.File),
+			uint64(md.Line),
+			e.mdStr(md.Getter),
+			e.mdStr(md.Setter),
+			md.Attributes,
+			e.mdRef(md.Type),
+		}
+		return bitc.MetadataObjCProperty, ops
+	case *metadata.DIImportedEntity:
+		// IMPORTED_ENTITY: [distinct, tag, scope, entity, line, name, file,
+		// elements]
+		ops = []uint64{
+			btou(md.Distinct),
+			uint64(md.Tag),
+			e.mdRef(md.Scope),
+			e.mdRef(md.Entity),
+			uint64(md.Line),
+			e.mdStr(md.Name),
+			e.mdRef(md.File),
+			e.mdRef(md.Elements),
+		}
+		return bitc.MetadataImportedEntity, ops
+	default:
+		panic(fmt.Errorf("support for metadata %T not yet implemented", md))
+	}
+}
+
+// ### [ Helper functions ] ####################################################
+
+// mdKey returns the uniquing key of the given metadata in metadata tables.
+func mdKey(md metadata.Field) interface{} {
+	if v, ok := md.(value.Value); ok {
+		return valueKey(v)
+	}
+	return md
+}
+
+// mdField returns the given metadata node as a metadata field.
+func mdField(node interface{}) metadata.Field {
+	field, ok := node.(metadata.Field)
+	if !ok {
+		panic(fmt.Errorf("invalid metadata node; expected metadata.Field, got %T", node))
+	}
+	return field
+}
+
+// isNilMD reports whether the given metadata is nil, a nil pointer or a null
+// literal.
+func isNilMD(md metadata.Field) bool {
+	switch md := md.(type) {
+	case nil:
+		return true
+	case *metadata.NullLit:
+		return true
+	case *metadata.Tuple:
+		return md == nil
+	case *metadata.DIFile:
+		return md == nil
+	case *metadata.DICompileUnit:
+		return md == nil
+	case *metadata.DILocation:
+		return md == nil
+	case *metadata.DIGlobalVariable:
+		return md == nil
+	case *metadata.DIExpression:
+		return md == nil
+	}
+	return false
+}
+
+// isLocalMD reports whether the given metadata is function-local; that is, a
+// metadata value of a function-local value, or a DIArgList metadata node.
+func isLocalMD(md metadata.Field) bool {
+	switch md := md.(type) {
+	case *metadata.DIArgList:
+		return true
+	case *metadata.Value:
+		return false
+	case value.Value:
+		_, ok := md.(constant.Constant)
+		return !ok
+	}
+	return false
+}
+
+// debugLocOf returns the debug location of the given metadata attachments, as
+// stored in DEBUG_LOC records. A debug location is stored in a DEBUG_LOC record
+// if it is the first metadata attachment and is not distinct.
+func debugLocOf(mds []*metadata.Attachment) (*metadata.DILocation, bool) {
+	if len(mds) == 0 || mds[0].Name != "dbg" {
+		return nil, false
+	}
+	loc, ok := mds[0].Node.(*metadata.DILocation)
+	if !ok || loc == nil || loc.Distinct {
+		return nil, false
+	}
+	return loc, true
+}
+
+// spFlagsOf returns the subprogram specific flags of the given DISubprogram,
+// including the flags stored as separate fields.
+func spFlagsOf(md *metadata.DISubprogram) enum.DISPFlag {
+	flags := md.SPFlags | enum.DISPFlag(md.Virtuality)&enum.DISPFlagVirtuality
+	if md.IsLocal {
+		flags |= enum.DISPFlagLocalToUnit
+	}
+	if md.IsDefinition {
+		flags |= enum.DISPFlagDefinition
+	}
+	if md.IsOptimized {
+		flags |= enum.DISPFlagOptimized
+	}
+	return flags
+}
+
+// diExpressionFieldOp returns the record operand of the given DIExpression
+// field.
+func diExpressionFieldOp(field metadata.DIExpressionField) uint64 {
+	switch field := field.(type) {
+	case metadata.UintLit:
+		return uint64(field)
+	case enum.DwarfOp:
+		return uint64(field)
+	case enum.DwarfAttEncoding:
+		return uint64(field)
+	default:
+		panic(fmt.Errorf("support for DIExpression field %T not yet implemented", field))
+	}
+}
+
+// callArgs returns the function arguments of the given call instruction or
+// terminator; or nil if not a call.
+func callArgs(inst interface{}) []value.Value {
+	switch inst := inst.(type) {
+	case *InstCall:
+		return inst.Args
+	case *TermInvoke:
+		return inst.Args
+	case *TermCallBr:
+		return inst.Args
+	}
+	return nil
+}
+
+// unwrapArg returns the value of the given function argument, without
+// parameter attributes.
+func unwrapArg(arg value.Value) value.Value {
+	if a, ok := arg.(*Arg); ok {
+		return a.Value
+	}
+	return arg
+}
+
+// instsOf returns the instructions and terminator of the given basic block.
+func instsOf(block *Block) []interface{} {
+	insts := make([]interface{}, 0, len(block.Insts)+1)
+	for _, inst := range block.Insts {
+		insts = append(insts, inst)
+	}
+	if block.Term != nil {
+		insts = append(insts, block.Term)
+	}
+	return insts
+}
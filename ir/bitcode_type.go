@@ -0,0 +1,231 @@
+package ir
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/llir/llvm/internal/bitc"
+	"github.com/llir/llvm/internal/bitstream"
+	"github.com/llir/llvm/ir/types"
+)
+
+// --- [ Type block ] ----------------------------------------------------------
+
+// typeID returns the type ID of the given type, adding it and its subtypes to
+// the type table if not already present.
+func (e *encoder) typeID(t types.Type) uint64 {
+	key := typeKey(t)
+	if id, ok := e.typeIDs[key]; ok {
+		return id
+	}
+	if e.final {
+		panic(fmt.Errorf("unable to locate type %v in type table", t))
+	}
+	if isIdentifiedStruct(t) {
+		if e.visitingTypes[key] {
+			// Forward reference to identified struct type being enumerated; the
+			// type ID is resolved by the final pass.
+			return 0
+		}
+		e.visitingTypes[key] = true
+		defer delete(e.visitingTypes, key)
+	}
+	for _, sub := range subtypes(t) {
+		e.typeID(sub)
+	}
+	// Subtypes may refer back to t through a pointer type.
+	if id, ok := e.typeIDs[key]; ok {
+		return id
+	}
+	id := uint64(len(e.types))
+	e.types = append(e.types, t)
+	e.typeIDs[key] = id
+	return id
+}
+
+// writeTypeBlock writes the TYPE_BLOCK_NEW of the type table.
+func (e *encoder) writeTypeBlock() {
+	e.bs.EnterBlock(bitc.TypeBlockIDNew, 4)
+	typeOp := bitstream.Fixed(e.typeBits)
+	// POINTER: [pointee type, address space = 0]
+	ptrAbbrev := e.defineAbbrev(bitstream.Literal(bitc.TypeCodePointer), typeOp, bitstream.Literal(0))
+	// OPAQUE_POINTER: [address space = 0]
+	opaquePtrAbbrev := e.defineAbbrev(bitstream.Literal(bitc.TypeCodeOpaquePointer), bitstream.Literal(0))
+	// FUNCTION: [vararg, retty, paramty x N]
+	funcAbbrev := e.defineAbbrev(bitstream.Literal(bitc.TypeCodeFunction), bitstream.Fixed(1), bitstream.Array(), typeOp)
+	// STRUCT_ANON: [ispacked, eltty x N]
+	structAnonAbbrev := e.defineAbbrev(bitstream.Literal(bitc.TypeCodeStructAnon), bitstream.Fixed(1), bitstream.Array(), typeOp)
+	// STRUCT_NAME: [strchr x N]
+	structNameAbbrev := e.defineAbbrev(bitstream.Literal(bitc.TypeCodeStructName), bitstream.Array(), bitstream.Char6())
+	// STRUCT_NAMED: [ispacked, eltty x N]
+	structNamedAbbrev := e.defineAbbrev(bitstream.Literal(bitc.TypeCodeStructNamed), bitstream.Fixed(1), bitstream.Array(), typeOp)
+	// ARRAY: [numelts, eltty]
+	arrayAbbrev := e.defineAbbrev(bitstream.Literal(bitc.TypeCodeArray), bitstream.VBR(8), typeOp)
+
+	// NUMENTRY: [numentries]
+	e.emit(bitc.TypeCodeNumEntry, []uint64{uint64(len(e.types))})
+	omitNames := e.hasImplicitStructNames()
+	for _, t := range e.types {
+		switch t := t.(type) {
+		case *types.VoidType:
+			e.emit(bitc.TypeCodeVoid, nil)
+		case *types.FloatType:
+			e.emit(floatTypeCode(t.Kind), nil)
+		case *types.IntType:
+			// INTEGER: [width]
+			e.emit(bitc.TypeCodeInteger, []uint64{t.BitSize})
+		case *types.PointerType:
+			if t.ElemType == nil {
+				// OPAQUE_POINTER: [address space]
+				e.emit(bitc.TypeCodeOpaquePointer, []uint64{uint64(t.AddrSpace)}, opaquePtrAbbrev)
+				break
+			}
+			// POINTER: [pointee type, address space]
+			e.emit(bitc.TypeCodePointer, []uint64{e.typeID(t.ElemType), uint64(t.AddrSpace)}, ptrAbbrev)
+		case *types.FuncType:
+			// FUNCTION: [vararg, retty, paramty x N]
+			ops := []uint64{btou(t.Variadic), e.typeID(t.RetType)}
+			for _, param := range t.Params {
+				ops = append(ops, e.typeID(param))
+			}
+			e.emit(bitc.TypeCodeFunction, ops, funcAbbrev)
+		case *types.LabelType:
+			e.emit(bitc.TypeCodeLabel, nil)
+		case *types.MetadataType:
+			e.emit(bitc.TypeCodeMetadata, nil)
+		case *types.MMXType:
+			e.emit(bitc.TypeCodeX86MMX, nil)
+		case *types.TokenType:
+			e.emit(bitc.TypeCodeToken, nil)
+		case *types.ArrayType:
+			// ARRAY: [numelts, eltty]
+			e.emit(bitc.TypeCodeArray, []uint64{t.Len, e.typeID(t.ElemType)}, arrayAbbrev)
+		case *types.VectorType:
+			// VECTOR: [numelts, eltty, scalable]
+			ops := []uint64{t.Len, e.typeID(t.ElemType)}
+			if t.Scalable {
+				ops = append(ops, 1)
+			}
+			e.emit(bitc.TypeCodeVector, ops)
+		case *types.StructType:
+			if len(t.TypeName) == 0 {
+				// STRUCT_ANON: [ispacked, eltty x N]
+				e.emit(bitc.TypeCodeStructAnon, e.structOps(t), structAnonAbbrev)
+				break
+			}
+			if !(omitNames && isNumeric(t.TypeName)) {
+				// STRUCT_NAME: [strchr x N]
+				e.emit(bitc.TypeCodeStructName, stringOps(t.TypeName), structNameAbbrev)
+			}
+			if t.Opaque {
+				// OPAQUE: []
+				e.emit(bitc.TypeCodeOpaque, nil)
+				break
+			}
+			// STRUCT_NAMED: [ispacked, eltty x N]
+			e.emit(bitc.TypeCodeStructNamed, e.structOps(t), structNamedAbbrev)
+		default:
+			panic(fmt.Errorf("support for type %T not yet implemented", t))
+		}
+	}
+	e.bs.ExitBlock()
+}
+
+// structOps returns the record operands of the given struct type.
+func (e *encoder) structOps(t *types.StructType) []uint64 {
+	ops := []uint64{btou(t.Packed)}
+	for _, field := range t.Fields {
+		ops = append(ops, e.typeID(field))
+	}
+	return ops
+}
+
+// hasImplicitStructNames reports whether the names of numbered identified
+// struct types may be omitted from the type table; which is the case if the
+// numeric names are assigned in type table order, as done when decoding
+// unnamed struct types.
+func (e *encoder) hasImplicitStructNames() bool {
+	id := 0
+	for _, t := range e.types {
+		if t, ok := t.(*types.StructType); ok && isNumeric(t.TypeName) {
+			if t.TypeName != strconv.Itoa(id) {
+				return false
+			}
+			id++
+		}
+	}
+	return true
+}
+
+// ### [ Helper functions ] ####################################################
+
+// typeKey returns the uniquing key of the given type in the type table.
+// Identified struct types are uniqued by name, and other types by structural
+// identity.
+func typeKey(t types.Type) string {
+	if isIdentifiedStruct(t) {
+		return "%" + t.Name()
+	}
+	return t.LLString()
+}
+
+// isIdentifiedStruct reports whether the given type is an identified struct
+// type.
+func isIdentifiedStruct(t types.Type) bool {
+	if t, ok := t.(*types.StructType); ok {
+		return len(t.TypeName) > 0
+	}
+	return false
+}
+
+// subtypes returns the subtypes of the given type.
+func subtypes(t types.Type) []types.Type {
+	switch t := t.(type) {
+	case *types.PointerType:
+		if t.ElemType != nil {
+			return []types.Type{t.ElemType}
+		}
+	case *types.FuncType:
+		return append([]types.Type{t.RetType}, t.Params...)
+	case *types.ArrayType:
+		return []types.Type{t.ElemType}
+	case *types.VectorType:
+		return []types.Type{t.ElemType}
+	case *types.StructType:
+		return t.Fields
+	}
+	return nil
+}
+
+// floatTypeCode returns the type record code of the given floating-point kind.
+func floatTypeCode(kind types.FloatKind) uint64 {
+	switch kind {
+	case types.FloatKindHalf:
+		return bitc.TypeCodeHalf
+	case types.FloatKindFloat:
+		return bitc.TypeCodeFloat
+	case types.FloatKindDouble:
+		return bitc.TypeCodeDouble
+	case types.FloatKindFP128:
+		return bitc.TypeCodeFP128
+	case types.FloatKindX86_FP80:
+		return bitc.TypeCodeX86FP80
+	case types.FloatKindPPC_FP128:
+		return bitc.TypeCodePPCFP128
+	default:
+		panic(fmt.Errorf("support for floating-point kind %v not yet implemented", kind))
+	}
+}
+
+// isNumeric reports whether the given name consists only of decimal digits.
+func isNumeric(name string) bool {
+	if len(name) == 0 {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if name[i] < '0' || name[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -9,6 +9,8 @@ import (
 
 	"github.com/llir/llvm/internal/enc"
 	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/pkg/errors"
 )
 
 // === [ Basic blocks ] ========================================================
@@ -70,3 +72,50 @@ func (block *Block) LLString() string {
 	fmt.Fprintf(buf, "\t%s", block.Term.LLString())
 	return buf.String()
 }
+
+// Remove removes the given instruction from the basic block. An error is
+// returned if the instruction is not part of the basic block, or if the
+// instruction still has users within the parent function (or within the basic
+// block, if the basic block has no parent function).
+func (block *Block) Remove(inst Instruction) error {
+	index := -1
+	for i, v := range block.Insts {
+		if v == inst {
+			index = i
+			break
+		}
+	}
+	if index == -1 {
+		return errors.Errorf("unable to locate instruction %q in basic block %q", inst.LLString(), block.Ident())
+	}
+	if v, ok := inst.(value.Value); ok {
+		var uses []*Use
+		if block.Parent != nil {
+			uses = block.Parent.Users(v)
+		} else {
+			b := newUseBuilder()
+			for _, inst := range block.Insts {
+				b.addUser(inst)
+			}
+			if block.Term != nil {
+				b.addUser(block.Term)
+			}
+			uses = b.uses[v]
+		}
+		if len(uses) > 0 {
+			return errors.Errorf("unable to remove instruction %q of basic block %q; instruction still has %d users", inst.LLString(), block.Ident(), len(uses))
+		}
+	}
+	block.Insts = append(block.Insts[:index], block.Insts[index+1:]...)
+	if block.Parent != nil {
+		// Reassign IDs of unnamed local variables succeeding the removed
+		// instruction.
+		block.Parent.ResetIDs()
+		// The operands of the removed instruction are no longer used.
+		block.Parent.InvalidateUses()
+		if block.Parent.Parent != nil {
+			block.Parent.Parent.uses = nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,397 @@
+// Package callgraph implements call graphs of LLVM IR modules.
+//
+// The call graph of a module has one node per function of the module, and one
+// edge per possible callee of each call site (call instructions, invoke
+// terminators and callbr terminators). Callees are resolved through aliases,
+// pointer casts and IFuncs (using the functions returned by the resolver of
+// the IFunc). Call sites whose callee cannot be resolved statically are
+// indirect call sites; their possible callees are the address-taken functions
+// of the module with a matching function signature.
+//
+// The strongly connected components of the call graph are computed using the
+// algorithm of Tarjan, and are ordered bottom-up; i.e. callees before
+// callers.
+package callgraph
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// Graph is the call graph of a module.
+type Graph struct {
+	// Module of the call graph.
+	Module *ir.Module
+	// Nodes of the call graph, in the order of the functions of the module.
+	Nodes []*Node
+
+	// Node of each function.
+	nodes map[*ir.Func]*Node
+	// Strongly connected components in bottom-up order; computed on demand.
+	sccs []*SCC
+}
+
+// Node is a node of the call graph, representing a function.
+type Node struct {
+	// Function of the node.
+	Func *ir.Func
+	// Outgoing call edges, in the order of the call sites of the function.
+	Callees []*Edge
+	// Incoming call edges.
+	Callers []*Edge
+	// The address of the function is taken (i.e. the function is used other
+	// than as the callee of a call site, and may be the target of indirect
+	// calls).
+	AddressTaken bool
+
+	// Strongly connected component containing the node; computed on demand by
+	// Graph.SCCs.
+	scc *SCC
+}
+
+// Edge is an edge of the call graph, from a call site of the caller to a
+// possible callee.
+type Edge struct {
+	// Caller node.
+	Caller *Node
+	// Callee node.
+	Callee *Node
+	// Call site.
+	//
+	// Site has one of the following underlying types:
+	//
+	//   - [*ir.InstCall]
+	//   - [*ir.TermInvoke]
+	//   - [*ir.TermCallBr]
+	Site value.User
+	// The callee is a possible target of an indirect call site, or of a call
+	// through an IFunc.
+	Indirect bool
+}
+
+// SCC is a strongly connected component of the call graph.
+type SCC struct {
+	// Nodes of the strongly connected component.
+	Nodes []*Node
+}
+
+// New returns the call graph of the given module.
+func New(m *ir.Module) *Graph {
+	g := &Graph{
+		Module: m,
+		nodes:  make(map[*ir.Func]*Node),
+	}
+	for _, f := range m.Funcs {
+		n := &Node{Func: f}
+		g.Nodes = append(g.Nodes, n)
+		g.nodes[f] = n
+	}
+	for _, n := range g.Nodes {
+		n.AddressTaken = addressTaken(m, n.Func, make(map[value.Value]bool))
+	}
+	for _, caller := range g.Nodes {
+		for _, block := range caller.Func.Blocks {
+			for _, inst := range block.Insts {
+				if inst, ok := inst.(*ir.InstCall); ok {
+					g.addCallSite(caller, inst, inst.Callee, inst.Sig())
+				}
+			}
+			switch term := block.Term.(type) {
+			case *ir.TermInvoke:
+				g.addCallSite(caller, term, term.Invokee, term.Sig())
+			case *ir.TermCallBr:
+				g.addCallSite(caller, term, term.Callee, term.Sig())
+			}
+		}
+	}
+	return g
+}
+
+// Node returns the node of the given function; or nil if the function is not
+// part of the module of the call graph.
+func (g *Graph) Node(f *ir.Func) *Node {
+	return g.nodes[f]
+}
+
+// SCCs returns the strongly connected components of the call graph in
+// bottom-up order; i.e. each strongly connected component is preceded by the
+// strongly connected components of its callees (except for callees within the
+// same component).
+func (g *Graph) SCCs() []*SCC {
+	if g.sccs == nil {
+		g.sccs = tarjan(g.Nodes)
+		for _, scc := range g.sccs {
+			for _, n := range scc.Nodes {
+				n.scc = scc
+			}
+		}
+	}
+	return g.sccs
+}
+
+// PostOrder returns the nodes of the call graph in bottom-up order; i.e. the
+// nodes of the strongly connected components of SCCs in order.
+func (g *Graph) PostOrder() []*Node {
+	var nodes []*Node
+	for _, scc := range g.SCCs() {
+		nodes = append(nodes, scc.Nodes...)
+	}
+	return nodes
+}
+
+// IsRecursive reports whether the given function may call itself, either
+// directly or through other functions.
+func (g *Graph) IsRecursive(f *ir.Func) bool {
+	scc := g.SCCOf(f)
+	return scc != nil && scc.Recursive()
+}
+
+// SCCOf returns the strongly connected component containing the given
+// function; or nil if the function is not part of the module of the call
+// graph.
+func (g *Graph) SCCOf(f *ir.Func) *SCC {
+	n := g.Node(f)
+	if n == nil {
+		return nil
+	}
+	g.SCCs()
+	return n.scc
+}
+
+// String returns the call graph in Graphviz DOT format.
+func (g *Graph) String() string {
+	buf := &strings.Builder{}
+	buf.WriteString("digraph {\n")
+	for _, n := range g.Nodes {
+		fmt.Fprintf(buf, "\t%q\n", n.Func.Ident())
+		for _, e := range n.Callees {
+			if e.Indirect {
+				fmt.Fprintf(buf, "\t%q -> %q [style=dashed]\n", n.Func.Ident(), e.Callee.Func.Ident())
+			} else {
+				fmt.Fprintf(buf, "\t%q -> %q\n", n.Func.Ident(), e.Callee.Func.Ident())
+			}
+		}
+	}
+	buf.WriteString("}")
+	return buf.String()
+}
+
+// --- [ SCC ] -----------------------------------------------------------------
+
+// Recursive reports whether the strongly connected component contains a cycle
+// of the call graph; i.e. it has more than one node, or its node calls
+// itself.
+func (scc *SCC) Recursive() bool {
+	if len(scc.Nodes) > 1 {
+		return true
+	}
+	n := scc.Nodes[0]
+	for _, e := range n.Callees {
+		if e.Callee == n {
+			return true
+		}
+	}
+	return false
+}
+
+// ### [ Helper functions ] ####################################################
+
+// addCallSite adds the edges of the given call site of caller to the call
+// graph.
+func (g *Graph) addCallSite(caller *Node, site value.User, callee value.Value, sig *types.FuncType) {
+	addEdge := func(f *ir.Func, indirect bool) {
+		n, ok := g.nodes[f]
+		if !ok {
+			return
+		}
+		e := &Edge{Caller: caller, Callee: n, Site: site, Indirect: indirect}
+		caller.Callees = append(caller.Callees, e)
+		n.Callers = append(n.Callers, e)
+	}
+	if _, ok := callee.(*ir.InlineAsm); ok {
+		return
+	}
+	if f := resolve(callee); f != nil {
+		addEdge(f, false)
+		return
+	}
+	if ifunc, ok := stripCasts(callee).(*ir.IFunc); ok {
+		if targets, ok := resolverTargets(ifunc); ok {
+			for _, f := range targets {
+				addEdge(f, true)
+			}
+			return
+		}
+	}
+	// Indirect call site.
+	for _, n := range g.Nodes {
+		if n.AddressTaken && n.Func.Sig.Equal(sig) {
+			addEdge(n.Func, true)
+		}
+	}
+}
+
+// resolve returns the function of the given callee, resolving aliases and
+// pointer casts; or nil if the callee is not statically known to be a
+// function.
+func resolve(callee value.Value) *ir.Func {
+	// Guard against cyclic aliases.
+	for i := 0; i < 100; i++ {
+		switch v := stripCasts(callee).(type) {
+		case *ir.Func:
+			return v
+		case *ir.Alias:
+			callee = v.Aliasee
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// stripCasts returns the given value with bitcast and addrspacecast constant
+// expressions stripped.
+func stripCasts(v value.Value) value.Value {
+	for {
+		switch e := v.(type) {
+		case *constant.ExprBitCast:
+			v = e.From
+		case *constant.ExprAddrSpaceCast:
+			v = e.From
+		default:
+			return v
+		}
+	}
+}
+
+// resolverTargets returns the functions that may be returned by the resolver
+// of the given IFunc. The boolean return value indicates success; i.e. the
+// resolver is defined and each of its return values is a function.
+func resolverTargets(ifunc *ir.IFunc) ([]*ir.Func, bool) {
+	resolver := resolve(ifunc.Resolver)
+	if resolver == nil || len(resolver.Blocks) == 0 {
+		return nil, false
+	}
+	var targets []*ir.Func
+	seen := make(map[*ir.Func]bool)
+	for _, block := range resolver.Blocks {
+		ret, ok := block.Term.(*ir.TermRet)
+		if !ok {
+			continue
+		}
+		var xs []value.Value
+		if phi, ok := ret.X.(*ir.InstPhi); ok {
+			for _, inc := range phi.Incs {
+				xs = append(xs, inc.X)
+			}
+		} else {
+			xs = append(xs, ret.X)
+		}
+		for _, x := range xs {
+			f := resolve(x)
+			if f == nil {
+				return nil, false
+			}
+			if !seen[f] {
+				seen[f] = true
+				targets = append(targets, f)
+			}
+		}
+	}
+	return targets, true
+}
+
+// addressTaken reports whether the given value (function or alias) is used
+// other than as the callee of a call site. Uses as the resolver of an IFunc
+// are not considered to take the address of the resolver.
+func addressTaken(m *ir.Module, v value.Value, visited map[value.Value]bool) bool {
+	if visited[v] {
+		return false
+	}
+	visited[v] = true
+	for _, use := range m.Users(v) {
+		switch user := use.User.(type) {
+		case *ir.InstCall:
+			if use.Val == &user.Callee {
+				continue
+			}
+		case *ir.TermInvoke:
+			if use.Val == &user.Invokee {
+				continue
+			}
+		case *ir.TermCallBr:
+			if use.Val == &user.Callee {
+				continue
+			}
+		case *ir.IFunc:
+			continue
+		case *ir.Alias:
+			// The alias is used as a callee or resolver only; e.g. the function
+			// is called through the alias.
+			if !addressTaken(m, user, visited) {
+				continue
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// tarjan returns the strongly connected components of the call graph
+// reachable from the given nodes, in bottom-up order.
+func tarjan(nodes []*Node) []*SCC {
+	var (
+		sccs    []*SCC
+		index   = make(map[*Node]int)
+		lowlink = make(map[*Node]int)
+		onStack = make(map[*Node]bool)
+		stack   []*Node
+	)
+	var visit func(n *Node)
+	visit = func(n *Node) {
+		index[n] = len(index)
+		lowlink[n] = index[n]
+		stack = append(stack, n)
+		onStack[n] = true
+		for _, e := range n.Callees {
+			m := e.Callee
+			if _, ok := index[m]; !ok {
+				visit(m)
+				if lowlink[m] < lowlink[n] {
+					lowlink[n] = lowlink[m]
+				}
+			} else if onStack[m] && index[m] < lowlink[n] {
+				lowlink[n] = index[m]
+			}
+		}
+		if lowlink[n] != index[n] {
+			return
+		}
+		scc := &SCC{}
+		for {
+			m := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[m] = false
+			scc.Nodes = append(scc.Nodes, m)
+			if m == n {
+				break
+			}
+		}
+		// Nodes of the component in order of discovery.
+		for i, j := 0, len(scc.Nodes)-1; i < j; i, j = i+1, j-1 {
+			scc.Nodes[i], scc.Nodes[j] = scc.Nodes[j], scc.Nodes[i]
+		}
+		sccs = append(sccs, scc)
+	}
+	for _, n := range nodes {
+		if _, ok := index[n]; !ok {
+			visit(n)
+		}
+	}
+	return sccs
+}
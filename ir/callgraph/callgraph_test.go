@@ -0,0 +1,151 @@
+package callgraph_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/callgraph"
+)
+
+const src = `
+@table = global [1 x ptr] [ptr @handler]
+@g = alias void (), ptr @leaf
+@h = ifunc void (), ptr @resolver
+
+declare i32 @__gxx_personality_v0(...)
+
+define void @leaf() {
+	ret void
+}
+
+define void @handler(i32 %x) {
+	ret void
+}
+
+define void @other(i32 %x) {
+	call void @main()
+	ret void
+}
+
+define ptr @resolver() {
+	ret ptr @leaf
+}
+
+define void @even(i32 %n) {
+	call void @odd(i32 %n)
+	ret void
+}
+
+define void @odd(i32 %n) {
+	call void @even(i32 %n)
+	ret void
+}
+
+define void @self() {
+	call void @self()
+	ret void
+}
+
+define void @main() personality ptr @__gxx_personality_v0 {
+entry:
+	call void @g()
+	call void @h()
+	%fp = load ptr, ptr @table
+	call void %fp(i32 1)
+	invoke void @even(i32 2)
+		to label %next unwind label %lpad
+
+next:
+	callbr void @self()
+		to label %exit []
+
+lpad:
+	%lp = landingpad { ptr, i32 } cleanup
+	resume { ptr, i32 } %lp
+
+exit:
+	call void asm "nop", ""()
+	ret void
+}
+`
+
+func TestGraph(t *testing.T) {
+	m, err := asm.ParseString("callgraph.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	g := callgraph.New(m)
+	// Edges.
+	const want = `digraph {
+	"@__gxx_personality_v0"
+	"@leaf"
+	"@handler"
+	"@other"
+	"@other" -> "@main"
+	"@resolver"
+	"@even"
+	"@even" -> "@odd"
+	"@odd"
+	"@odd" -> "@even"
+	"@self"
+	"@self" -> "@self"
+	"@main"
+	"@main" -> "@leaf"
+	"@main" -> "@leaf" [style=dashed]
+	"@main" -> "@handler" [style=dashed]
+	"@main" -> "@even"
+	"@main" -> "@self"
+}`
+	if got := g.String(); got != want {
+		t.Errorf("call graph mismatch; expected:\n%s\ngot:\n%s", want, got)
+	}
+	// Address-taken functions.
+	var taken []string
+	for _, n := range g.Nodes {
+		if n.AddressTaken {
+			taken = append(taken, n.Func.Ident())
+		}
+	}
+	if got, want := strings.Join(taken, " "), "@__gxx_personality_v0 @leaf @handler"; got != want {
+		t.Errorf("address-taken functions mismatch; expected %q, got %q", want, got)
+	}
+	// Strongly connected components in bottom-up order.
+	var sccs []string
+	for _, scc := range g.SCCs() {
+		var names []string
+		for _, n := range scc.Nodes {
+			names = append(names, n.Func.Ident())
+		}
+		sccs = append(sccs, strings.Join(names, ","))
+	}
+	if got, want := strings.Join(sccs, " "), "@__gxx_personality_v0 @leaf @handler @even,@odd @self @main @other @resolver"; got != want {
+		t.Errorf("strongly connected components mismatch; expected %q, got %q", want, got)
+	}
+	// Recursion.
+	golden := []struct {
+		name string
+		want bool
+	}{
+		{name: "leaf", want: false},
+		{name: "even", want: true},
+		{name: "self", want: true},
+		{name: "main", want: false},
+		{name: "other", want: false},
+	}
+	for _, gold := range golden {
+		if got := g.IsRecursive(findFunc(m, gold.name)); got != gold.want {
+			t.Errorf("%q: recursion mismatch; expected %v, got %v", gold.name, gold.want, got)
+		}
+	}
+}
+
+func findFunc(m *ir.Module, name string) *ir.Func {
+	for _, f := range m.Funcs {
+		if f.Name() == name {
+			return f
+		}
+	}
+	return nil
+}
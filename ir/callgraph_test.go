@@ -8,6 +8,7 @@ import (
 
 	"github.com/llir/llvm/asm"
 	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/callgraph"
 )
 
 func Example_callgraph() {
@@ -41,29 +42,18 @@ func Example_callgraph() {
 func genCallgraph(m *ir.Module) string {
 	buf := &strings.Builder{}
 	buf.WriteString("digraph {\n")
+	g := callgraph.New(m)
 	// For each function of the module.
-	for _, f := range m.Funcs {
+	for _, n := range g.Nodes {
 		// Add caller node.
-		caller := f.Ident()
+		caller := n.Func.Ident()
 		fmt.Fprintf(buf, "\t%q\n", caller)
-		// For each basic block of the function.
-		for _, block := range f.Blocks {
-			// For each non-branching instruction of the basic block.
-			for _, inst := range block.Insts {
-				// Type switch on instruction to find call instructions.
-				switch inst := inst.(type) {
-				case *ir.InstCall:
-					callee := inst.Callee.Ident()
-					// Add edges from caller to callee.
-					fmt.Fprintf(buf, "\t%q -> %q\n", caller, callee)
-				}
-			}
-			// Terminator of basic block.
-			switch term := block.Term.(type) {
-			case *ir.TermRet:
-				// do something.
-				_ = term
-			}
+		// For each call site of the function (call instructions, invoke and
+		// callbr terminators), and each possible callee of the call site.
+		for _, e := range n.Callees {
+			callee := e.Callee.Func.Ident()
+			// Add edges from caller to callee.
+			fmt.Fprintf(buf, "\t%q -> %q\n", caller, callee)
 		}
 	}
 	buf.WriteString("}")
@@ -0,0 +1,941 @@
+package ir
+
+import (
+	"fmt"
+
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/metadata"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// === [ Cloning ] =============================================================
+
+// Clone returns a deep copy of the module, and a map from the values of the
+// module to their copies (global symbols, function parameters, basic blocks,
+// instructions, terminators and constants).
+//
+// Global variables, functions, aliases, IFuncs, comdats, attribute groups and
+// metadata tuples are copied, and all references are remapped to the copies.
+// Types and specialized metadata nodes (e.g. !DILocation) are shared between
+// the module and its copy.
+func (m *Module) Clone() (*Module, map[value.Value]value.Value) {
+	c := newCloner(make(map[value.Value]value.Value))
+	c.tuples = make(map[*metadata.Tuple]*metadata.Tuple)
+	new := &Module{
+		TypeDefs:       append([]types.Type(nil), m.TypeDefs...),
+		SourceFilename: m.SourceFilename,
+		DataLayout:     m.DataLayout,
+		TargetTriple:   m.TargetTriple,
+		ModuleAsms:     append([]string(nil), m.ModuleAsms...),
+	}
+	// Comdats and attribute groups.
+	for _, def := range m.ComdatDefs {
+		d := &ComdatDef{Name: def.Name, Kind: def.Kind}
+		c.comdats[def] = d
+		new.ComdatDefs = append(new.ComdatDefs, d)
+	}
+	for _, def := range m.AttrGroupDefs {
+		d := &AttrGroupDef{ID: def.ID}
+		c.attrGroups[def] = d
+		new.AttrGroupDefs = append(new.AttrGroupDefs, d)
+	}
+	for i, def := range m.AttrGroupDefs {
+		new.AttrGroupDefs[i].FuncAttrs = c.funcAttrs(def.FuncAttrs)
+	}
+	// Global symbols; create all symbols before remapping operands, as global
+	// symbols may refer to each other.
+	for _, g := range m.Globals {
+		ng := &Global{}
+		*ng = *g
+		ng.Comdat = c.comdat(g.Comdat)
+		ng.FuncAttrs = c.funcAttrs(g.FuncAttrs)
+		c.vmap[g] = ng
+		new.Globals = append(new.Globals, ng)
+	}
+	for _, f := range m.Funcs {
+		nf := c.funcShell(f)
+		nf.Parent = new
+		c.vmap[f] = nf
+		new.Funcs = append(new.Funcs, nf)
+	}
+	for _, alias := range m.Aliases {
+		na := &Alias{}
+		*na = *alias
+		c.vmap[alias] = na
+		new.Aliases = append(new.Aliases, na)
+	}
+	for _, ifunc := range m.IFuncs {
+		ni := &IFunc{}
+		*ni = *ifunc
+		c.vmap[ifunc] = ni
+		new.IFuncs = append(new.IFuncs, ni)
+	}
+	for _, g := range new.Globals {
+		if g.Init != nil {
+			g.Init = c.remapConst(g.Init)
+		}
+		g.Metadata = c.metadata(g.Metadata)
+	}
+	for _, alias := range new.Aliases {
+		alias.Aliasee = c.remapConst(alias.Aliasee)
+	}
+	for _, ifunc := range new.IFuncs {
+		ifunc.Resolver = c.remapConst(ifunc.Resolver)
+	}
+	for i, f := range m.Funcs {
+		c.funcBody(f, new.Funcs[i])
+	}
+	// Metadata.
+	for _, def := range m.MetadataDefs {
+		if tuple, ok := def.(*metadata.Tuple); ok {
+			def = c.tuple(tuple)
+		}
+		new.MetadataDefs = append(new.MetadataDefs, def)
+	}
+	if m.NamedMetadataDefs != nil {
+		new.NamedMetadataDefs = make(map[string]*metadata.NamedDef, len(m.NamedMetadataDefs))
+		for name, def := range m.NamedMetadataDefs {
+			nodes := make([]metadata.Node, len(def.Nodes))
+			for i, node := range def.Nodes {
+				if tuple, ok := node.(*metadata.Tuple); ok {
+					node = c.tuple(tuple)
+				}
+				nodes[i] = node
+			}
+			new.NamedMetadataDefs[name] = &metadata.NamedDef{Name: def.Name, Nodes: nodes}
+		}
+	}
+	// Use-list order directives.
+	new.UseListOrders = c.useListOrders(m.UseListOrders)
+	for _, u := range m.UseListOrderBBs {
+		b := &UseListOrderBB{
+			Func:    c.vmap[u.Func].(*Func),
+			Block:   c.vmap[u.Block].(*Block),
+			Indices: append([]uint64(nil), u.Indices...),
+		}
+		new.UseListOrderBBs = append(new.UseListOrderBBs, b)
+	}
+	return new, c.vmap
+}
+
+// CloneFunc returns a deep copy of the given function, and the map from the
+// values of the function to their copies (function parameters, basic blocks,
+// instructions, terminators and constants).
+//
+// The copy has the same parent module as f, but is not added to the list of
+// functions of the module. Values outside of the function (e.g. global
+// symbols, and recursive calls to f) refer to the original values, and
+// metadata nodes are shared with the original function.
+//
+// If valueMap is non-nil, it is used as the value map of the copy; uses of
+// values present in valueMap are remapped to the mapped values (e.g. to map
+// function parameters to call arguments when inlining), and the remaining
+// mappings are added to valueMap.
+func CloneFunc(f *Func, valueMap map[value.Value]value.Value) (*Func, map[value.Value]value.Value) {
+	if valueMap == nil {
+		valueMap = make(map[value.Value]value.Value)
+	}
+	c := newCloner(valueMap)
+	new := c.funcShell(f)
+	new.Parent = f.Parent
+	c.funcBody(f, new)
+	return new, c.vmap
+}
+
+// ### [ Helper functions ] ####################################################
+
+// cloner tracks the state of a deep copy.
+type cloner struct {
+	// Map from original values to their copies.
+	vmap map[value.Value]value.Value
+	// Map from original comdats to their copies; an original comdat is used if
+	// not present.
+	comdats map[*ComdatDef]*ComdatDef
+	// Map from original attribute groups to their copies; an original
+	// attribute group is used if not present.
+	attrGroups map[*AttrGroupDef]*AttrGroupDef
+	// Map from original metadata tuples to their copies; or nil if metadata is
+	// shared.
+	tuples map[*metadata.Tuple]*metadata.Tuple
+}
+
+// newCloner returns a new cloner based on the given value map.
+func newCloner(vmap map[value.Value]value.Value) *cloner {
+	return &cloner{
+		vmap:       vmap,
+		comdats:    make(map[*ComdatDef]*ComdatDef),
+		attrGroups: make(map[*AttrGroupDef]*AttrGroupDef),
+	}
+}
+
+// set maps the original value to its copy, unless already present in the
+// value map.
+func (c *cloner) set(old, new value.Value) {
+	if _, ok := c.vmap[old]; !ok {
+		c.vmap[old] = new
+	}
+}
+
+// --- [ Functions ] -----------------------------------------------------------
+
+// funcShell returns a copy of the function header of f, with copies of the
+// function parameters and empty basic blocks. The prefix, prologue,
+// personality, metadata and use-list orders are copied by funcBody.
+func (c *cloner) funcShell(f *Func) *Func {
+	new := &Func{
+		GlobalIdent:     f.GlobalIdent,
+		Sig:             f.Sig,
+		Typ:             f.Typ,
+		Linkage:         f.Linkage,
+		Preemption:      f.Preemption,
+		Visibility:      f.Visibility,
+		DLLStorageClass: f.DLLStorageClass,
+		CallingConv:     f.CallingConv,
+		ReturnAttrs:     append([]ReturnAttribute(nil), f.ReturnAttrs...),
+		UnnamedAddr:     f.UnnamedAddr,
+		AddrSpace:       f.AddrSpace,
+		FuncAttrs:       c.funcAttrs(f.FuncAttrs),
+		Section:         f.Section,
+		Partition:       f.Partition,
+		Comdat:          c.comdat(f.Comdat),
+		Align:           f.Align,
+		GC:              f.GC,
+	}
+	for _, param := range f.Params {
+		p := &Param{
+			LocalIdent: param.LocalIdent,
+			Typ:        param.Typ,
+			Attrs:      append([]ParamAttribute(nil), param.Attrs...),
+		}
+		c.set(param, p)
+		new.Params = append(new.Params, p)
+	}
+	for _, block := range f.Blocks {
+		b := &Block{LocalIdent: block.LocalIdent, Parent: new}
+		c.set(block, b)
+		new.Blocks = append(new.Blocks, b)
+	}
+	return new
+}
+
+// funcBody copies the instructions and terminators of the basic blocks of f
+// into the basic blocks of the function shell new, remapping their operands.
+func (c *cloner) funcBody(f, new *Func) {
+	if f.Prefix != nil {
+		new.Prefix = c.remapConst(f.Prefix)
+	}
+	if f.Prologue != nil {
+		new.Prologue = c.remapConst(f.Prologue)
+	}
+	if f.Personality != nil {
+		new.Personality = c.remapConst(f.Personality)
+	}
+	new.Metadata = c.metadata(f.Metadata)
+	// Copy instructions and terminators before remapping operands, as operands
+	// may refer to instructions of succeeding basic blocks.
+	var users []value.User
+	for i, block := range f.Blocks {
+		b := new.Blocks[i]
+		for _, inst := range block.Insts {
+			ni := c.cloneInst(inst)
+			if v, ok := inst.(value.Value); ok {
+				c.set(v, ni.(value.Value))
+			}
+			b.Insts = append(b.Insts, ni)
+			users = append(users, ni)
+		}
+		if block.Term != nil {
+			nt := c.cloneTerm(block.Term)
+			if v, ok := block.Term.(value.Value); ok {
+				c.set(v, nt.(value.Value))
+			}
+			b.Term = nt
+			users = append(users, nt)
+		}
+	}
+	for _, user := range users {
+		for _, op := range user.Operands() {
+			*op = c.remap(*op)
+		}
+		for _, bundle := range operandBundles(user) {
+			for i, input := range bundle.Inputs {
+				bundle.Inputs[i] = c.remap(input)
+			}
+		}
+	}
+	new.UseListOrders = c.useListOrders(f.UseListOrders)
+}
+
+// useListOrders returns a copy of the given use-list order directives.
+func (c *cloner) useListOrders(orders []*UseListOrder) []*UseListOrder {
+	var news []*UseListOrder
+	for _, u := range orders {
+		new := &UseListOrder{
+			Value:   c.remap(u.Value),
+			Indices: append([]uint64(nil), u.Indices...),
+		}
+		news = append(news, new)
+	}
+	return news
+}
+
+// --- [ Instructions ] --------------------------------------------------------
+
+// cloneInst returns a copy of the given instruction. Operands are remapped by
+// the caller.
+func (c *cloner) cloneInst(inst Instruction) Instruction {
+	switch inst := inst.(type) {
+	case *InstFNeg:
+		new := *inst
+		new.FastMathFlags = append([]enum.FastMathFlag(nil), inst.FastMathFlags...)
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstAdd:
+		new := *inst
+		new.OverflowFlags = append([]enum.OverflowFlag(nil), inst.OverflowFlags...)
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstFAdd:
+		new := *inst
+		new.FastMathFlags = append([]enum.FastMathFlag(nil), inst.FastMathFlags...)
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstSub:
+		new := *inst
+		new.OverflowFlags = append([]enum.OverflowFlag(nil), inst.OverflowFlags...)
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstFSub:
+		new := *inst
+		new.FastMathFlags = append([]enum.FastMathFlag(nil), inst.FastMathFlags...)
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstMul:
+		new := *inst
+		new.OverflowFlags = append([]enum.OverflowFlag(nil), inst.OverflowFlags...)
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstFMul:
+		new := *inst
+		new.FastMathFlags = append([]enum.FastMathFlag(nil), inst.FastMathFlags...)
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstUDiv:
+		new := *inst
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstSDiv:
+		new := *inst
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstFDiv:
+		new := *inst
+		new.FastMathFlags = append([]enum.FastMathFlag(nil), inst.FastMathFlags...)
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstURem:
+		new := *inst
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstSRem:
+		new := *inst
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstFRem:
+		new := *inst
+		new.FastMathFlags = append([]enum.FastMathFlag(nil), inst.FastMathFlags...)
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstShl:
+		new := *inst
+		new.OverflowFlags = append([]enum.OverflowFlag(nil), inst.OverflowFlags...)
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstLShr:
+		new := *inst
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstAShr:
+		new := *inst
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstAnd:
+		new := *inst
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstOr:
+		new := *inst
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstXor:
+		new := *inst
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstExtractElement:
+		new := *inst
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstInsertElement:
+		new := *inst
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstShuffleVector:
+		new := *inst
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstExtractValue:
+		new := *inst
+		new.Indices = append([]uint64(nil), inst.Indices...)
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstInsertValue:
+		new := *inst
+		new.Indices = append([]uint64(nil), inst.Indices...)
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstAlloca:
+		new := *inst
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstLoad:
+		new := *inst
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstStore:
+		new := *inst
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstFence:
+		new := *inst
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstCmpXchg:
+		new := *inst
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstAtomicRMW:
+		new := *inst
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstGetElementPtr:
+		new := *inst
+		new.Indices = append([]value.Value(nil), inst.Indices...)
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstTrunc:
+		new := *inst
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstZExt:
+		new := *inst
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstSExt:
+		new := *inst
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstFPTrunc:
+		new := *inst
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstFPExt:
+		new := *inst
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstFPToUI:
+		new := *inst
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstFPToSI:
+		new := *inst
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstUIToFP:
+		new := *inst
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstSIToFP:
+		new := *inst
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstPtrToInt:
+		new := *inst
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstIntToPtr:
+		new := *inst
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstBitCast:
+		new := *inst
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstAddrSpaceCast:
+		new := *inst
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstICmp:
+		new := *inst
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstFCmp:
+		new := *inst
+		new.FastMathFlags = append([]enum.FastMathFlag(nil), inst.FastMathFlags...)
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstPhi:
+		new := *inst
+		new.Incs = cloneIncs(inst.Incs)
+		new.FastMathFlags = append([]enum.FastMathFlag(nil), inst.FastMathFlags...)
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstSelect:
+		new := *inst
+		new.FastMathFlags = append([]enum.FastMathFlag(nil), inst.FastMathFlags...)
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstFreeze:
+		new := *inst
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstCall:
+		new := *inst
+		new.Args = append([]value.Value(nil), inst.Args...)
+		new.FastMathFlags = append([]enum.FastMathFlag(nil), inst.FastMathFlags...)
+		new.ReturnAttrs = append([]ReturnAttribute(nil), inst.ReturnAttrs...)
+		new.FuncAttrs = c.funcAttrs(inst.FuncAttrs)
+		new.OperandBundles = cloneOperandBundles(inst.OperandBundles)
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstVAArg:
+		new := *inst
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstLandingPad:
+		new := *inst
+		new.Clauses = cloneClauses(inst.Clauses)
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstCatchPad:
+		new := *inst
+		new.Args = append([]value.Value(nil), inst.Args...)
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	case *InstCleanupPad:
+		new := *inst
+		new.Args = append([]value.Value(nil), inst.Args...)
+		new.Metadata = c.metadata(inst.Metadata)
+		return &new
+	default:
+		panic(fmt.Errorf("support for instruction %T not yet implemented", inst))
+	}
+}
+
+// cloneTerm returns a copy of the given terminator. Operands are remapped by
+// the caller.
+func (c *cloner) cloneTerm(term Terminator) Terminator {
+	switch term := term.(type) {
+	case *TermRet:
+		new := *term
+		new.Metadata = c.metadata(term.Metadata)
+		return &new
+	case *TermBr:
+		new := *term
+		new.Successors = nil
+		new.Metadata = c.metadata(term.Metadata)
+		return &new
+	case *TermCondBr:
+		new := *term
+		new.Successors = nil
+		new.Metadata = c.metadata(term.Metadata)
+		return &new
+	case *TermSwitch:
+		new := *term
+		new.Cases = cloneCases(term.Cases)
+		new.Successors = nil
+		new.Metadata = c.metadata(term.Metadata)
+		return &new
+	case *TermIndirectBr:
+		new := *term
+		new.ValidTargets = append([]value.Value(nil), term.ValidTargets...)
+		new.Successors = nil
+		new.Metadata = c.metadata(term.Metadata)
+		return &new
+	case *TermInvoke:
+		new := *term
+		new.Args = append([]value.Value(nil), term.Args...)
+		new.Successors = nil
+		new.ReturnAttrs = append([]ReturnAttribute(nil), term.ReturnAttrs...)
+		new.FuncAttrs = c.funcAttrs(term.FuncAttrs)
+		new.OperandBundles = cloneOperandBundles(term.OperandBundles)
+		new.Metadata = c.metadata(term.Metadata)
+		return &new
+	case *TermCallBr:
+		new := *term
+		new.Args = append([]value.Value(nil), term.Args...)
+		new.OtherRetTargets = append([]value.Value(nil), term.OtherRetTargets...)
+		new.Successors = nil
+		new.ReturnAttrs = append([]ReturnAttribute(nil), term.ReturnAttrs...)
+		new.FuncAttrs = c.funcAttrs(term.FuncAttrs)
+		new.OperandBundles = cloneOperandBundles(term.OperandBundles)
+		new.Metadata = c.metadata(term.Metadata)
+		return &new
+	case *TermResume:
+		new := *term
+		new.Metadata = c.metadata(term.Metadata)
+		return &new
+	case *TermCatchSwitch:
+		new := *term
+		new.Handlers = append([]value.Value(nil), term.Handlers...)
+		new.Successors = nil
+		new.Metadata = c.metadata(term.Metadata)
+		return &new
+	case *TermCatchRet:
+		new := *term
+		new.Successors = nil
+		new.Metadata = c.metadata(term.Metadata)
+		return &new
+	case *TermCleanupRet:
+		new := *term
+		new.Successors = nil
+		new.Metadata = c.metadata(term.Metadata)
+		return &new
+	case *TermUnreachable:
+		new := *term
+		new.Metadata = c.metadata(term.Metadata)
+		return &new
+	default:
+		panic(fmt.Errorf("support for terminator %T not yet implemented", term))
+	}
+}
+
+// cloneIncs returns a copy of the given incoming values of a phi instruction.
+func cloneIncs(incs []*Incoming) []*Incoming {
+	var news []*Incoming
+	for _, inc := range incs {
+		news = append(news, &Incoming{X: inc.X, Pred: inc.Pred})
+	}
+	return news
+}
+
+// cloneClauses returns a copy of the given landingpad clauses.
+func cloneClauses(clauses []*Clause) []*Clause {
+	var news []*Clause
+	for _, clause := range clauses {
+		news = append(news, &Clause{Type: clause.Type, X: clause.X})
+	}
+	return news
+}
+
+// cloneCases returns a copy of the given switch cases.
+func cloneCases(cases []*Case) []*Case {
+	var news []*Case
+	for _, cas := range cases {
+		news = append(news, &Case{X: cas.X, Target: cas.Target})
+	}
+	return news
+}
+
+// cloneOperandBundles returns a copy of the given operand bundles.
+func cloneOperandBundles(bundles []*OperandBundle) []*OperandBundle {
+	var news []*OperandBundle
+	for _, bundle := range bundles {
+		new := &OperandBundle{
+			Tag:    bundle.Tag,
+			Inputs: append([]value.Value(nil), bundle.Inputs...),
+		}
+		news = append(news, new)
+	}
+	return news
+}
+
+// --- [ Attributes ] ----------------------------------------------------------
+
+// comdat returns the copy of the given comdat; or the comdat itself if not
+// copied.
+func (c *cloner) comdat(def *ComdatDef) *ComdatDef {
+	if new, ok := c.comdats[def]; ok {
+		return new
+	}
+	return def
+}
+
+// funcAttrs returns a copy of the given function attributes, with references
+// to attribute groups remapped to their copies.
+func (c *cloner) funcAttrs(attrs []FuncAttribute) []FuncAttribute {
+	if attrs == nil {
+		return nil
+	}
+	news := make([]FuncAttribute, len(attrs))
+	for i, attr := range attrs {
+		if def, ok := attr.(*AttrGroupDef); ok {
+			if new, ok := c.attrGroups[def]; ok {
+				attr = new
+			}
+		}
+		news[i] = attr
+	}
+	return news
+}
+
+// --- [ Values ] --------------------------------------------------------------
+
+// remap returns the copy of the given value. Values present in the value map
+// are mapped, function arguments and metadata values are copied with remapped
+// operands, constants are remapped by remapConst, and other values are left
+// as is.
+func (c *cloner) remap(v value.Value) value.Value {
+	if v == nil {
+		return nil
+	}
+	if new, ok := c.vmap[v]; ok {
+		return new
+	}
+	switch v := v.(type) {
+	case *Arg:
+		return &Arg{Value: c.remap(v.Value), Attrs: append([]ParamAttribute(nil), v.Attrs...)}
+	case *metadata.Value:
+		return &metadata.Value{Value: c.remapMetadata(v.Value)}
+	case constant.Constant:
+		return c.remapConst(v)
+	}
+	return v
+}
+
+// remapConst returns the copy of the given constant. Aggregate constants,
+// constant expressions, blockaddress, dso_local_equivalent and no_cfi
+// constants are copied with remapped operands; other constants (e.g. integer
+// constants) are immutable and shared. Copies are recorded in the value map,
+// so that constants shared by several users remain shared by the copies of
+// the users.
+func (c *cloner) remapConst(x constant.Constant) constant.Constant {
+	if new, ok := c.vmap[x]; ok {
+		return new.(constant.Constant)
+	}
+	var new constant.Constant
+	switch x := x.(type) {
+	// Aggregate constants.
+	case *constant.Struct:
+		new = &constant.Struct{Typ: x.Typ, Fields: c.remapConsts(x.Fields)}
+	case *constant.Array:
+		new = &constant.Array{Typ: x.Typ, Elems: c.remapConsts(x.Elems)}
+	case *constant.Vector:
+		new = &constant.Vector{Typ: x.Typ, Elems: c.remapConsts(x.Elems)}
+	// Function and basic block addresses.
+	case *constant.BlockAddress:
+		b := &constant.BlockAddress{Func: c.remapConst(x.Func), Typ: x.Typ}
+		if x.Block != nil {
+			b.Block = c.remap(x.Block).(value.Named)
+		}
+		new = b
+	case *constant.DSOLocalEquivalent:
+		new = &constant.DSOLocalEquivalent{Func: c.remapConst(x.Func)}
+	case *constant.NoCFI:
+		new = &constant.NoCFI{Func: c.remapConst(x.Func)}
+	// Constant expressions.
+	case constant.Expression:
+		expr := cloneExpr(x)
+		for _, slot := range exprOperands(expr) {
+			*slot = c.remapConst(*slot)
+		}
+		new = expr
+	default:
+		return x
+	}
+	c.vmap[x] = new
+	return new
+}
+
+// remapConsts returns a copy of the given constants, with each constant
+// remapped.
+func (c *cloner) remapConsts(xs []constant.Constant) []constant.Constant {
+	if xs == nil {
+		return nil
+	}
+	news := make([]constant.Constant, len(xs))
+	for i, x := range xs {
+		news[i] = c.remapConst(x)
+	}
+	return news
+}
+
+// cloneExpr returns a copy of the given constant expression. Operands are
+// remapped by the caller.
+func cloneExpr(expr constant.Expression) constant.Expression {
+	switch e := expr.(type) {
+	// Unary expressions.
+	case *constant.ExprFNeg:
+		new := *e
+		return &new
+	// Binary expressions.
+	case *constant.ExprAdd:
+		new := *e
+		new.OverflowFlags = append([]enum.OverflowFlag(nil), e.OverflowFlags...)
+		return &new
+	case *constant.ExprSub:
+		new := *e
+		new.OverflowFlags = append([]enum.OverflowFlag(nil), e.OverflowFlags...)
+		return &new
+	case *constant.ExprMul:
+		new := *e
+		new.OverflowFlags = append([]enum.OverflowFlag(nil), e.OverflowFlags...)
+		return &new
+	// Bitwise expressions.
+	case *constant.ExprShl:
+		new := *e
+		new.OverflowFlags = append([]enum.OverflowFlag(nil), e.OverflowFlags...)
+		return &new
+	case *constant.ExprLShr:
+		new := *e
+		return &new
+	case *constant.ExprAShr:
+		new := *e
+		return &new
+	case *constant.ExprAnd:
+		new := *e
+		return &new
+	case *constant.ExprOr:
+		new := *e
+		return &new
+	case *constant.ExprXor:
+		new := *e
+		return &new
+	// Vector expressions.
+	case *constant.ExprExtractElement:
+		new := *e
+		return &new
+	case *constant.ExprInsertElement:
+		new := *e
+		return &new
+	case *constant.ExprShuffleVector:
+		new := *e
+		return &new
+	// Memory expressions.
+	case *constant.ExprGetElementPtr:
+		new := *e
+		new.Indices = make([]constant.Constant, len(e.Indices))
+		for i, index := range e.Indices {
+			// Copy inrange indices.
+			if idx, ok := index.(*constant.Index); ok {
+				newIdx := *idx
+				index = &newIdx
+			}
+			new.Indices[i] = index
+		}
+		return &new
+	// Conversion expressions.
+	case *constant.ExprTrunc:
+		new := *e
+		return &new
+	case *constant.ExprZExt:
+		new := *e
+		return &new
+	case *constant.ExprSExt:
+		new := *e
+		return &new
+	case *constant.ExprFPTrunc:
+		new := *e
+		return &new
+	case *constant.ExprFPExt:
+		new := *e
+		return &new
+	case *constant.ExprFPToUI:
+		new := *e
+		return &new
+	case *constant.ExprFPToSI:
+		new := *e
+		return &new
+	case *constant.ExprUIToFP:
+		new := *e
+		return &new
+	case *constant.ExprSIToFP:
+		new := *e
+		return &new
+	case *constant.ExprPtrToInt:
+		new := *e
+		return &new
+	case *constant.ExprIntToPtr:
+		new := *e
+		return &new
+	case *constant.ExprBitCast:
+		new := *e
+		return &new
+	case *constant.ExprAddrSpaceCast:
+		new := *e
+		return &new
+	// Other expressions.
+	case *constant.ExprICmp:
+		new := *e
+		return &new
+	case *constant.ExprFCmp:
+		new := *e
+		return &new
+	case *constant.ExprSelect:
+		new := *e
+		return &new
+	default:
+		panic(fmt.Errorf("support for constant expression %T not yet implemented", expr))
+	}
+}
+
+// --- [ Metadata ] ------------------------------------------------------------
+
+// metadata returns a copy of the given metadata attachments.
+func (c *cloner) metadata(mds Metadata) Metadata {
+	var news Metadata
+	for _, md := range mds {
+		node := md.Node
+		if tuple, ok := node.(*metadata.Tuple); ok {
+			node = c.tuple(tuple)
+		}
+		news = append(news, &metadata.Attachment{Name: md.Name, Node: node})
+	}
+	return news
+}
+
+// remapMetadata returns the copy of the given metadata operand of a metadata
+// value (e.g. function-local metadata of a call argument).
+func (c *cloner) remapMetadata(md metadata.Metadata) metadata.Metadata {
+	switch md := md.(type) {
+	case value.Value:
+		return c.remap(md)
+	case *metadata.DIArgList:
+		fields := make([]value.Value, len(md.Fields))
+		for i, field := range md.Fields {
+			fields[i] = c.remap(field)
+		}
+		return &metadata.DIArgList{Fields: fields}
+	case *metadata.Tuple:
+		return c.tuple(md)
+	}
+	return md
+}
+
+// tuple returns the copy of the given metadata tuple; or the tuple itself if
+// metadata is shared.
+func (c *cloner) tuple(tuple *metadata.Tuple) *metadata.Tuple {
+	if c.tuples == nil {
+		return tuple
+	}
+	if new, ok := c.tuples[tuple]; ok {
+		return new
+	}
+	new := &metadata.Tuple{MetadataID: tuple.MetadataID, Distinct: tuple.Distinct}
+	// Record the copy before copying fields, as tuples may be self-referential.
+	c.tuples[tuple] = new
+	if tuple.Fields != nil {
+		new.Fields = make([]metadata.Field, len(tuple.Fields))
+		for i, field := range tuple.Fields {
+			switch f := field.(type) {
+			case value.Value:
+				field = c.remap(f)
+			case *metadata.Tuple:
+				field = c.tuple(f)
+			}
+			new.Fields[i] = field
+		}
+	}
+	return new
+}
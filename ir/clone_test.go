@@ -0,0 +1,183 @@
+package ir_test
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/metadata"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+const cloneSrc = `
+$c = comdat any
+
+@g = global i32 0, comdat($c)
+@addr = global ptr blockaddress(@f, %exit)
+@p = global ptr getelementptr inbounds ([2 x i32], ptr @arr, i64 0, inrange i64 1)
+@arr = global [2 x i32] zeroinitializer
+@a = alias i32, ptr @g
+
+declare void @h(i32)
+
+define i32 @f(i32 %x) #0 personality ptr @h !dbg !0 {
+entry:
+	%y = add nsw i32 %x, ptrtoint (ptr @g to i32)
+	call void @h(i32 %y) [ "deopt"(i32 %y) ], !dbg !1
+	switch i32 %y, label %exit [
+		i32 1, label %loop
+	]
+
+loop:
+	%i = phi i32 [ %y, %entry ], [ %j, %loop ]
+	%j = add i32 %i, 1
+	%cmp = icmp eq i32 %j, 10
+	br i1 %cmp, label %exit, label %loop
+
+exit:
+	%r = phi i32 [ %y, %entry ], [ %j, %loop ]
+	ret i32 %r
+}
+
+attributes #0 = { noinline }
+
+!llvm.used = !{!2}
+
+!0 = !{!"f"}
+!1 = !{!"call"}
+!2 = !{ptr @g}
+`
+
+func TestModuleClone(t *testing.T) {
+	m, err := asm.ParseString("clone.ll", cloneSrc)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	want := m.String()
+	clone, vmap := m.Clone()
+	if got := clone.String(); got != want {
+		t.Fatalf("clone output mismatch; expected\n%s\n\ngot\n%s", want, got)
+	}
+	// All global symbols, parameters, basic blocks and instructions are
+	// copied.
+	f, cf := m.Funcs[1], clone.Funcs[1]
+	if vmap[f] != cf || cf.Parent != clone {
+		t.Errorf("function %s not mapped to its copy", f.Ident())
+	}
+	for i, g := range m.Globals {
+		if g == clone.Globals[i] || vmap[g] != clone.Globals[i] {
+			t.Errorf("global variable %s not mapped to its copy", g.Ident())
+		}
+	}
+	if clone.Globals[0].Comdat == m.Globals[0].Comdat || clone.Globals[0].Comdat != clone.ComdatDefs[0] {
+		t.Errorf("comdat of %s not remapped", m.Globals[0].Ident())
+	}
+	if clone.Aliases[0].Aliasee != clone.Globals[0] {
+		t.Errorf("aliasee of %s not remapped", m.Aliases[0].Ident())
+	}
+	if cf.FuncAttrs[0] != clone.AttrGroupDefs[0] {
+		t.Errorf("attribute group of %s not remapped", f.Ident())
+	}
+	if cf.Params[0] == f.Params[0] || vmap[f.Params[0]] != cf.Params[0] {
+		t.Errorf("parameter %s not mapped to its copy", f.Params[0].Ident())
+	}
+	for i, block := range f.Blocks {
+		cblock := cf.Blocks[i]
+		if vmap[block] != cblock || cblock.Parent != cf {
+			t.Errorf("basic block %s not mapped to its copy", block.Ident())
+		}
+		for j, inst := range block.Insts {
+			if cblock.Insts[j] == inst {
+				t.Errorf("instruction %q of basic block %s not copied", inst.LLString(), block.Ident())
+			}
+		}
+	}
+	// Operands refer to the copies.
+	entry, loop, exit := cf.Blocks[0], cf.Blocks[1], cf.Blocks[2]
+	y := entry.Insts[0].(*ir.InstAdd)
+	if y.X != cf.Params[0] {
+		t.Errorf("operand of %s not remapped", y.Ident())
+	}
+	if e, ok := y.Y.(*constant.ExprPtrToInt); !ok || e == f.Blocks[0].Insts[0].(*ir.InstAdd).Y || e.From != clone.Globals[0] {
+		t.Errorf("constant expression operand of %s not copied", y.Ident())
+	}
+	call := entry.Insts[1].(*ir.InstCall)
+	if call.Callee != clone.Funcs[0] || call.Args[0] != y {
+		t.Errorf("operands of call not remapped")
+	}
+	if bundle := call.OperandBundles[0]; bundle == f.Blocks[0].Insts[1].(*ir.InstCall).OperandBundles[0] || bundle.Inputs[0] != y {
+		t.Errorf("operand bundle of call not copied")
+	}
+	sw := entry.Term.(*ir.TermSwitch)
+	if sw.TargetDefault != exit || sw.Cases[0].Target != loop {
+		t.Errorf("targets of switch not remapped")
+	}
+	if succs := sw.Succs(); len(succs) != 2 || succs[0] != exit || succs[1] != loop {
+		t.Errorf("successors of switch mismatch; got %v", succs)
+	}
+	phi := loop.Insts[0].(*ir.InstPhi)
+	if phi.Incs[0].Pred != entry || phi.Incs[1].Pred != loop || phi.Incs[1].X != loop.Insts[1].(value.Value) {
+		t.Errorf("incoming values of %s not remapped", phi.Ident())
+	}
+	ba := clone.Globals[1].Init.(*constant.BlockAddress)
+	if ba.Func != cf || ba.Block != exit {
+		t.Errorf("blockaddress not remapped")
+	}
+	if cf.Personality != clone.Funcs[0] {
+		t.Errorf("personality of %s not remapped", f.Ident())
+	}
+	// Metadata tuples are copied.
+	used := clone.NamedMetadataDefs["llvm.used"].Nodes[0].(*metadata.Tuple)
+	if used == m.NamedMetadataDefs["llvm.used"].Nodes[0] || used.Fields[0] != clone.Globals[0] {
+		t.Errorf("metadata tuple not remapped")
+	}
+	if cf.Metadata[0] == f.Metadata[0] || cf.Metadata[0].Node != clone.MetadataDefs[0] {
+		t.Errorf("metadata attachment of %s not copied", f.Ident())
+	}
+	// Mutating the copy leaves the original untouched.
+	y.X = constant.NewInt(types.I32, 1)
+	call.OperandBundles[0].Inputs[0] = constant.NewInt(types.I32, 2)
+	phi.Incs[0].X = constant.NewInt(types.I32, 3)
+	sw.Cases[0].X = constant.NewInt(types.I32, 4)
+	cf.Blocks = cf.Blocks[:1]
+	clone.Globals[0].Init = constant.NewInt(types.I32, 5)
+	if got := m.String(); got != want {
+		t.Errorf("original module modified; expected\n%s\n\ngot\n%s", want, got)
+	}
+}
+
+func TestCloneFunc(t *testing.T) {
+	m, err := asm.ParseString("clone.ll", cloneSrc)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	f := m.Funcs[1]
+	want := f.LLString()
+	// Map parameter to constant.
+	arg := constant.NewInt(types.I32, 42)
+	vmap := map[value.Value]value.Value{f.Params[0]: arg}
+	cf, got := ir.CloneFunc(f, vmap)
+	if cf.Parent != m || len(m.Funcs) != 2 {
+		t.Errorf("parent module of clone mismatch")
+	}
+	if got[f.Params[0]] != arg {
+		t.Errorf("pre-populated value map entry overwritten")
+	}
+	y := cf.Blocks[0].Insts[0].(*ir.InstAdd)
+	if y.X != arg {
+		t.Errorf("operand of %s not remapped to pre-populated value; got %v", y.Ident(), y.X)
+	}
+	// Global symbols and metadata are shared.
+	call := cf.Blocks[0].Insts[1].(*ir.InstCall)
+	if call.Callee != m.Funcs[0] || cf.Metadata[0].Node != f.Metadata[0].Node {
+		t.Errorf("global symbols or metadata of clone not shared")
+	}
+	if cf.Blocks[2].Term.(*ir.TermRet).X != cf.Blocks[2].Insts[0].(value.Value) {
+		t.Errorf("return value not remapped")
+	}
+	if got := f.LLString(); got != want {
+		t.Errorf("original function modified; expected\n%s\n\ngot\n%s", want, got)
+	}
+}
@@ -15,6 +15,12 @@ type BlockAddress struct {
 	Func Constant // *ir.Func
 	// Basic block to take address of.
 	Block value.Named // *ir.Block
+
+	// extra.
+
+	// (optional) Type of the constant; or nil to use i8* (e.g. ptr if opaque
+	// pointer types are used).
+	Typ types.Type
 }
 
 // NewBlockAddress returns a new blockaddress constant based on the given parent
@@ -31,6 +37,9 @@ func (c *BlockAddress) String() string {
 
 // Type returns the type of the constant.
 func (c *BlockAddress) Type() types.Type {
+	if c.Typ != nil {
+		return c.Typ
+	}
 	return types.I8Ptr
 }
 
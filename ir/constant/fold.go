@@ -0,0 +1,690 @@
+package constant
+
+import (
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+)
+
+// === [ Constant folding ] ====================================================
+
+// Fold returns the result of constant folding the given constant.
+//
+// Constant expressions are evaluated when their operands (after folding) are
+// integer, floating-point, null pointer, undef, poison, zeroinitializer or
+// aggregate constants, following the wrap, poison and undef semantics of LLVM
+// (e.g. add nsw on signed overflow yields poison, and shifting by an amount
+// greater than or equal to the bit width yields poison). Floating-point
+// arithmetic is evaluated for the half, float and double types.
+//
+// Constant expressions which cannot be evaluated (e.g. pointer arithmetic on
+// global variables) are returned with folded operands; aggregate constants are
+// returned with folded elements. Fold returns c itself if nothing was folded.
+func Fold(c Constant) Constant {
+	switch c := c.(type) {
+	case Expression:
+		return foldExpr(c)
+	case *Struct:
+		if fields, ok := foldList(c.Fields); ok {
+			return &Struct{Typ: c.Typ, Fields: fields}
+		}
+	case *Array:
+		if elems, ok := foldList(c.Elems); ok {
+			return &Array{Typ: c.Typ, Elems: elems}
+		}
+	case *Vector:
+		if elems, ok := foldList(c.Elems); ok {
+			return &Vector{Typ: c.Typ, Elems: elems}
+		}
+	}
+	return c
+}
+
+// foldList folds the given list of constants, and reports whether any constant
+// was folded.
+func foldList(cs []Constant) ([]Constant, bool) {
+	changed := false
+	folded := make([]Constant, len(cs))
+	for i, c := range cs {
+		folded[i] = Fold(c)
+		if folded[i] != c {
+			changed = true
+		}
+	}
+	return folded, changed
+}
+
+// foldExpr returns the result of constant folding the given constant
+// expression.
+func foldExpr(expr Expression) Constant {
+	switch e := expr.(type) {
+	// Unary expressions.
+	case *ExprFNeg:
+		x := Fold(e.X)
+		if r := lanewise(e.Type(), foldFNeg, x); r != nil {
+			return r
+		}
+		if x != e.X {
+			return &ExprFNeg{X: x, Typ: e.Typ}
+		}
+	// Binary expressions.
+	case *ExprAdd:
+		return foldIntBinaryExpr(e, opAdd, e.X, e.Y, e.OverflowFlags, false, func(x, y Constant) Constant {
+			return &ExprAdd{X: x, Y: y, Typ: e.Typ, OverflowFlags: e.OverflowFlags}
+		})
+	case *ExprSub:
+		return foldIntBinaryExpr(e, opSub, e.X, e.Y, e.OverflowFlags, false, func(x, y Constant) Constant {
+			return &ExprSub{X: x, Y: y, Typ: e.Typ, OverflowFlags: e.OverflowFlags}
+		})
+	case *ExprMul:
+		return foldIntBinaryExpr(e, opMul, e.X, e.Y, e.OverflowFlags, false, func(x, y Constant) Constant {
+			return &ExprMul{X: x, Y: y, Typ: e.Typ, OverflowFlags: e.OverflowFlags}
+		})
+	// Bitwise expressions.
+	case *ExprShl:
+		return foldIntBinaryExpr(e, opShl, e.X, e.Y, e.OverflowFlags, false, func(x, y Constant) Constant {
+			return &ExprShl{X: x, Y: y, Typ: e.Typ, OverflowFlags: e.OverflowFlags}
+		})
+	case *ExprLShr:
+		return foldIntBinaryExpr(e, opLShr, e.X, e.Y, nil, e.Exact, func(x, y Constant) Constant {
+			return &ExprLShr{X: x, Y: y, Typ: e.Typ, Exact: e.Exact}
+		})
+	case *ExprAShr:
+		return foldIntBinaryExpr(e, opAShr, e.X, e.Y, nil, e.Exact, func(x, y Constant) Constant {
+			return &ExprAShr{X: x, Y: y, Typ: e.Typ, Exact: e.Exact}
+		})
+	case *ExprAnd:
+		return foldIntBinaryExpr(e, opAnd, e.X, e.Y, nil, false, func(x, y Constant) Constant {
+			return &ExprAnd{X: x, Y: y, Typ: e.Typ}
+		})
+	case *ExprOr:
+		return foldIntBinaryExpr(e, opOr, e.X, e.Y, nil, false, func(x, y Constant) Constant {
+			return &ExprOr{X: x, Y: y, Typ: e.Typ}
+		})
+	case *ExprXor:
+		return foldIntBinaryExpr(e, opXor, e.X, e.Y, nil, false, func(x, y Constant) Constant {
+			return &ExprXor{X: x, Y: y, Typ: e.Typ}
+		})
+	// Vector expressions.
+	case *ExprExtractElement:
+		x, index := Fold(e.X), Fold(e.Index)
+		if r := foldExtractElement(x, index); r != nil {
+			return r
+		}
+		if x != e.X || index != e.Index {
+			return &ExprExtractElement{X: x, Index: index, Typ: e.Typ}
+		}
+	case *ExprInsertElement:
+		x, elem, index := Fold(e.X), Fold(e.Elem), Fold(e.Index)
+		if r := foldInsertElement(x, elem, index); r != nil {
+			return r
+		}
+		if x != e.X || elem != e.Elem || index != e.Index {
+			return &ExprInsertElement{X: x, Elem: elem, Index: index, Typ: e.Typ}
+		}
+	case *ExprShuffleVector:
+		x, y, mask := Fold(e.X), Fold(e.Y), Fold(e.Mask)
+		if r := foldShuffleVector(e.Type(), x, y, mask); r != nil {
+			return r
+		}
+		if x != e.X || y != e.Y || mask != e.Mask {
+			return &ExprShuffleVector{X: x, Y: y, Mask: mask, Typ: e.Typ}
+		}
+	// Memory expressions.
+	case *ExprGetElementPtr:
+		// Address computations depend on the data layout and are not
+		// evaluated; fold the operands.
+		src := Fold(e.Src)
+		indices, changed := foldList(e.Indices)
+		if src != e.Src || changed {
+			return &ExprGetElementPtr{ElemType: e.ElemType, Src: src, Indices: indices, Typ: e.Typ, InBounds: e.InBounds}
+		}
+	// Conversion expressions.
+	case *ExprTrunc:
+		return foldConvExpr(e, convTrunc, e.From, e.To, func(from Constant) Constant {
+			return &ExprTrunc{From: from, To: e.To}
+		})
+	case *ExprZExt:
+		return foldConvExpr(e, convZExt, e.From, e.To, func(from Constant) Constant {
+			return &ExprZExt{From: from, To: e.To}
+		})
+	case *ExprSExt:
+		return foldConvExpr(e, convSExt, e.From, e.To, func(from Constant) Constant {
+			return &ExprSExt{From: from, To: e.To}
+		})
+	case *ExprFPTrunc:
+		return foldConvExpr(e, convFPTrunc, e.From, e.To, func(from Constant) Constant {
+			return &ExprFPTrunc{From: from, To: e.To}
+		})
+	case *ExprFPExt:
+		return foldConvExpr(e, convFPExt, e.From, e.To, func(from Constant) Constant {
+			return &ExprFPExt{From: from, To: e.To}
+		})
+	case *ExprFPToUI:
+		return foldConvExpr(e, convFPToUI, e.From, e.To, func(from Constant) Constant {
+			return &ExprFPToUI{From: from, To: e.To}
+		})
+	case *ExprFPToSI:
+		return foldConvExpr(e, convFPToSI, e.From, e.To, func(from Constant) Constant {
+			return &ExprFPToSI{From: from, To: e.To}
+		})
+	case *ExprUIToFP:
+		return foldConvExpr(e, convUIToFP, e.From, e.To, func(from Constant) Constant {
+			return &ExprUIToFP{From: from, To: e.To}
+		})
+	case *ExprSIToFP:
+		return foldConvExpr(e, convSIToFP, e.From, e.To, func(from Constant) Constant {
+			return &ExprSIToFP{From: from, To: e.To}
+		})
+	case *ExprPtrToInt:
+		return foldConvExpr(e, convPtrToInt, e.From, e.To, func(from Constant) Constant {
+			return &ExprPtrToInt{From: from, To: e.To}
+		})
+	case *ExprIntToPtr:
+		return foldConvExpr(e, convIntToPtr, e.From, e.To, func(from Constant) Constant {
+			return &ExprIntToPtr{From: from, To: e.To}
+		})
+	case *ExprBitCast:
+		return foldConvExpr(e, convBitCast, e.From, e.To, func(from Constant) Constant {
+			return &ExprBitCast{From: from, To: e.To}
+		})
+	case *ExprAddrSpaceCast:
+		// The representation of null pointers may differ between address
+		// spaces; fold the operand.
+		if from := Fold(e.From); from != e.From {
+			return &ExprAddrSpaceCast{From: from, To: e.To}
+		}
+	// Other expressions.
+	case *ExprICmp:
+		x, y := Fold(e.X), Fold(e.Y)
+		fold := func(ops ...Constant) Constant {
+			return foldICmp(e.Pred, ops[0], ops[1])
+		}
+		if r := lanewise(e.Type(), fold, x, y); r != nil {
+			return r
+		}
+		if x != e.X || y != e.Y {
+			return &ExprICmp{Pred: e.Pred, X: x, Y: y, Typ: e.Typ}
+		}
+	case *ExprFCmp:
+		x, y := Fold(e.X), Fold(e.Y)
+		fold := func(ops ...Constant) Constant {
+			return foldFCmp(e.Pred, ops[0], ops[1])
+		}
+		if r := lanewise(e.Type(), fold, x, y); r != nil {
+			return r
+		}
+		if x != e.X || y != e.Y {
+			return &ExprFCmp{Pred: e.Pred, X: x, Y: y, Typ: e.Typ}
+		}
+	case *ExprSelect:
+		cond, x, y := Fold(e.Cond), Fold(e.X), Fold(e.Y)
+		if r := foldSelect(e.Type(), cond, x, y); r != nil {
+			return r
+		}
+		if cond != e.Cond || x != e.X || y != e.Y {
+			return &ExprSelect{Cond: cond, X: x, Y: y, Typ: e.Typ}
+		}
+	}
+	return expr
+}
+
+// foldIntBinaryExpr returns the result of constant folding the given integer
+// binary expression with operands x and y. The rebuild function returns a copy
+// of the expression with the given folded operands.
+func foldIntBinaryExpr(e Expression, op intOp, x, y Constant, flags []enum.OverflowFlag, exact bool, rebuild func(x, y Constant) Constant) Constant {
+	fx, fy := Fold(x), Fold(y)
+	nsw, nuw := hasFlag(flags, enum.OverflowFlagNSW), hasFlag(flags, enum.OverflowFlagNUW)
+	fold := func(ops ...Constant) Constant {
+		return foldIntBinary(op, ops[0], ops[1], nsw, nuw, exact)
+	}
+	if r := lanewise(e.Type(), fold, fx, fy); r != nil {
+		return r
+	}
+	if fx != x || fy != y {
+		return rebuild(fx, fy)
+	}
+	return e
+}
+
+// foldConvExpr returns the result of constant folding the given conversion
+// expression from the value from to the type to. The rebuild function returns a
+// copy of the expression with the given folded operand.
+func foldConvExpr(e Expression, op convOp, from Constant, to types.Type, rebuild func(from Constant) Constant) Constant {
+	f := Fold(from)
+	if r := foldConv(op, f, to); r != nil {
+		return r
+	}
+	if f != from {
+		return rebuild(f)
+	}
+	return e
+}
+
+// --- [ Folding of instructions without constant expressions ] ----------------
+
+// FoldUDiv returns the result of constant folding udiv with the given integer
+// scalar or vector operands; or nil if the operands cannot be evaluated.
+func FoldUDiv(x, y Constant, exact bool) Constant {
+	return foldBinary(opUDiv, x, y, exact)
+}
+
+// FoldSDiv returns the result of constant folding sdiv with the given integer
+// scalar or vector operands; or nil if the operands cannot be evaluated.
+func FoldSDiv(x, y Constant, exact bool) Constant {
+	return foldBinary(opSDiv, x, y, exact)
+}
+
+// FoldURem returns the result of constant folding urem with the given integer
+// scalar or vector operands; or nil if the operands cannot be evaluated.
+func FoldURem(x, y Constant) Constant {
+	return foldBinary(opURem, x, y, false)
+}
+
+// FoldSRem returns the result of constant folding srem with the given integer
+// scalar or vector operands; or nil if the operands cannot be evaluated.
+func FoldSRem(x, y Constant) Constant {
+	return foldBinary(opSRem, x, y, false)
+}
+
+// FoldFAdd returns the result of constant folding fadd with the given
+// floating-point scalar or vector operands; or nil if the operands cannot be
+// evaluated.
+func FoldFAdd(x, y Constant) Constant {
+	return foldFloatBinaryOp(fopAdd, x, y)
+}
+
+// FoldFSub returns the result of constant folding fsub with the given
+// floating-point scalar or vector operands; or nil if the operands cannot be
+// evaluated.
+func FoldFSub(x, y Constant) Constant {
+	return foldFloatBinaryOp(fopSub, x, y)
+}
+
+// FoldFMul returns the result of constant folding fmul with the given
+// floating-point scalar or vector operands; or nil if the operands cannot be
+// evaluated.
+func FoldFMul(x, y Constant) Constant {
+	return foldFloatBinaryOp(fopMul, x, y)
+}
+
+// FoldFDiv returns the result of constant folding fdiv with the given
+// floating-point scalar or vector operands; or nil if the operands cannot be
+// evaluated.
+func FoldFDiv(x, y Constant) Constant {
+	return foldFloatBinaryOp(fopDiv, x, y)
+}
+
+// FoldFRem returns the result of constant folding frem with the given
+// floating-point scalar or vector operands; or nil if the operands cannot be
+// evaluated.
+func FoldFRem(x, y Constant) Constant {
+	return foldFloatBinaryOp(fopRem, x, y)
+}
+
+// FoldExtractValue returns the result of constant folding extractvalue with the
+// given aggregate operand and element indices; or nil if the aggregate cannot be
+// evaluated.
+func FoldExtractValue(x Constant, indices []uint64) Constant {
+	x = Fold(x)
+	for _, index := range indices {
+		elems, ok := aggregateElems(x)
+		if !ok || index >= uint64(len(elems)) {
+			return nil
+		}
+		x = elems[index]
+	}
+	return x
+}
+
+// FoldInsertValue returns the result of constant folding insertvalue with the
+// given aggregate operand, element and element indices; or nil if the aggregate
+// cannot be evaluated.
+func FoldInsertValue(x, elem Constant, indices []uint64) Constant {
+	x, elem = Fold(x), Fold(elem)
+	if len(indices) == 0 {
+		return elem
+	}
+	elems, ok := aggregateElems(x)
+	if !ok || indices[0] >= uint64(len(elems)) {
+		return nil
+	}
+	inner := FoldInsertValue(elems[indices[0]], elem, indices[1:])
+	if inner == nil {
+		return nil
+	}
+	elems = append([]Constant(nil), elems...)
+	elems[indices[0]] = inner
+	switch t := x.Type().(type) {
+	case *types.StructType:
+		return &Struct{Typ: t, Fields: elems}
+	case *types.ArrayType:
+		return &Array{Typ: t, Elems: elems}
+	}
+	return nil
+}
+
+// foldBinary returns the result of constant folding the given integer binary
+// operation with integer scalar or vector operands.
+func foldBinary(op intOp, x, y Constant, exact bool) Constant {
+	x, y = Fold(x), Fold(y)
+	fold := func(ops ...Constant) Constant {
+		return foldIntBinary(op, ops[0], ops[1], false, false, exact)
+	}
+	return lanewise(x.Type(), fold, x, y)
+}
+
+// foldFloatBinaryOp returns the result of constant folding the given
+// floating-point binary operation with floating-point scalar or vector
+// operands.
+func foldFloatBinaryOp(op floatOp, x, y Constant) Constant {
+	x, y = Fold(x), Fold(y)
+	fold := func(ops ...Constant) Constant {
+		return foldFloatBinary(op, ops[0], ops[1])
+	}
+	return lanewise(x.Type(), fold, x, y)
+}
+
+// --- [ Vector and aggregate folding ] ----------------------------------------
+
+// lanewise applies the given scalar fold function to the operands; element-wise
+// if the result type is a vector type. Scalar operands are used for every
+// element of vector results (e.g. the condition of select). The result is nil
+// if any element cannot be evaluated.
+func lanewise(typ types.Type, fold func(ops ...Constant) Constant, ops ...Constant) Constant {
+	vt, ok := typ.(*types.VectorType)
+	if !ok {
+		return fold(ops...)
+	}
+	if vt.Scalable {
+		return nil
+	}
+	n := int(vt.Len)
+	opElems := make([][]Constant, len(ops))
+	for i, op := range ops {
+		if _, ok := op.Type().(*types.VectorType); !ok {
+			continue
+		}
+		elems, ok := vectorElems(op)
+		if !ok || len(elems) != n {
+			return nil
+		}
+		opElems[i] = elems
+	}
+	elems := make([]Constant, n)
+	laneOps := make([]Constant, len(ops))
+	for lane := 0; lane < n; lane++ {
+		for i, op := range ops {
+			if opElems[i] == nil {
+				laneOps[i] = op
+			} else {
+				laneOps[i] = opElems[i][lane]
+			}
+		}
+		elem := fold(laneOps...)
+		if elem == nil {
+			return nil
+		}
+		elems[lane] = elem
+	}
+	return newVector(vt, elems)
+}
+
+// newVector returns a new vector constant of the given type and elements; or
+// undef or poison if all elements are undef or poison respectively.
+func newVector(typ *types.VectorType, elems []Constant) Constant {
+	allUndef, allPoison := true, true
+	for _, elem := range elems {
+		if !isUndef(elem) {
+			allUndef = false
+		}
+		if !isPoison(elem) {
+			allPoison = false
+		}
+	}
+	switch {
+	case len(elems) > 0 && allPoison:
+		return NewPoison(typ)
+	case len(elems) > 0 && allUndef:
+		return NewUndef(typ)
+	}
+	return &Vector{Typ: typ, Elems: elems}
+}
+
+// vectorElems returns the elements of the given vector constant, and a boolean
+// indicating success.
+func vectorElems(c Constant) ([]Constant, bool) {
+	vt, ok := c.Type().(*types.VectorType)
+	if !ok || vt.Scalable {
+		return nil, false
+	}
+	switch c := c.(type) {
+	case *Vector:
+		return c.Elems, true
+	case *ZeroInitializer:
+		return repeat(zeroValue(vt.ElemType), vt.Len), true
+	case *Undef:
+		return repeat(NewUndef(vt.ElemType), vt.Len), true
+	case *Poison:
+		return repeat(NewPoison(vt.ElemType), vt.Len), true
+	}
+	return nil, false
+}
+
+// aggregateElems returns the elements of the given struct, array or vector
+// constant, and a boolean indicating success.
+func aggregateElems(c Constant) ([]Constant, bool) {
+	switch t := c.Type().(type) {
+	case *types.StructType:
+		switch c := c.(type) {
+		case *Struct:
+			return c.Fields, true
+		case *ZeroInitializer, *Undef, *Poison:
+			elems := make([]Constant, len(t.Fields))
+			for i, field := range t.Fields {
+				elems[i] = likeValue(c, field)
+			}
+			return elems, true
+		}
+	case *types.ArrayType:
+		switch c := c.(type) {
+		case *Array:
+			return c.Elems, true
+		case *CharArray:
+			elems := make([]Constant, len(c.X))
+			for i, b := range c.X {
+				elems[i] = NewInt(types.I8, int64(b))
+			}
+			return elems, true
+		case *ZeroInitializer, *Undef, *Poison:
+			return repeat(likeValue(c, t.ElemType), t.Len), true
+		}
+	case *types.VectorType:
+		return vectorElems(c)
+	}
+	return nil, false
+}
+
+// likeValue returns a zero, undef or poison value of the given type, based on
+// whether c is a zeroinitializer, undef or poison constant.
+func likeValue(c Constant, typ types.Type) Constant {
+	switch c.(type) {
+	case *Undef:
+		return NewUndef(typ)
+	case *Poison:
+		return NewPoison(typ)
+	}
+	return zeroValue(typ)
+}
+
+// repeat returns a list of n copies of the given constant.
+func repeat(c Constant, n uint64) []Constant {
+	cs := make([]Constant, n)
+	for i := range cs {
+		cs[i] = c
+	}
+	return cs
+}
+
+// foldExtractElement returns the result of constant folding extractelement;
+// or nil if the operands cannot be evaluated.
+func foldExtractElement(x, index Constant) Constant {
+	vt, ok := x.Type().(*types.VectorType)
+	if !ok || vt.Scalable {
+		return nil
+	}
+	if isUndef(index) || isPoison(index) {
+		return NewPoison(vt.ElemType)
+	}
+	i, ok := index.(*Int)
+	if !ok {
+		return nil
+	}
+	if !i.X.IsUint64() || i.X.Uint64() >= vt.Len {
+		// Out of bounds index.
+		return NewPoison(vt.ElemType)
+	}
+	elems, ok := vectorElems(x)
+	if !ok {
+		return nil
+	}
+	return elems[i.X.Uint64()]
+}
+
+// foldInsertElement returns the result of constant folding insertelement; or
+// nil if the operands cannot be evaluated.
+func foldInsertElement(x, elem, index Constant) Constant {
+	vt, ok := x.Type().(*types.VectorType)
+	if !ok || vt.Scalable {
+		return nil
+	}
+	if isUndef(index) || isPoison(index) {
+		return NewPoison(vt)
+	}
+	i, ok := index.(*Int)
+	if !ok {
+		return nil
+	}
+	if !i.X.IsUint64() || i.X.Uint64() >= vt.Len {
+		// Out of bounds index.
+		return NewPoison(vt)
+	}
+	elems, ok := vectorElems(x)
+	if !ok {
+		return nil
+	}
+	elems = append([]Constant(nil), elems...)
+	elems[i.X.Uint64()] = elem
+	return newVector(vt, elems)
+}
+
+// foldShuffleVector returns the result of constant folding shufflevector with
+// the given result type; or nil if the operands cannot be evaluated.
+func foldShuffleVector(typ types.Type, x, y, mask Constant) Constant {
+	vt, ok := typ.(*types.VectorType)
+	if !ok || vt.Scalable {
+		return nil
+	}
+	xs, ok := vectorElems(x)
+	if !ok {
+		return nil
+	}
+	ys, ok := vectorElems(y)
+	if !ok {
+		return nil
+	}
+	ms, ok := vectorElems(mask)
+	if !ok {
+		return nil
+	}
+	src := append(append([]Constant(nil), xs...), ys...)
+	elems := make([]Constant, len(ms))
+	for i, m := range ms {
+		switch m := m.(type) {
+		case *Undef:
+			elems[i] = NewUndef(vt.ElemType)
+		case *Poison:
+			elems[i] = NewPoison(vt.ElemType)
+		case *Int:
+			if !m.X.IsUint64() || m.X.Uint64() >= uint64(len(src)) {
+				return nil
+			}
+			elems[i] = src[m.X.Uint64()]
+		default:
+			return nil
+		}
+	}
+	return newVector(vt, elems)
+}
+
+// foldSelect returns the result of constant folding select with the given
+// result type; or nil if the operands cannot be evaluated.
+func foldSelect(typ types.Type, cond, x, y Constant) Constant {
+	if _, ok := cond.Type().(*types.VectorType); !ok {
+		return selectScalar(cond, x, y)
+	}
+	fold := func(ops ...Constant) Constant {
+		return selectScalar(ops[0], ops[1], ops[2])
+	}
+	return lanewise(typ, fold, cond, x, y)
+}
+
+// selectScalar returns the result of constant folding select with a scalar
+// condition; or nil if the condition cannot be evaluated.
+func selectScalar(cond, x, y Constant) Constant {
+	switch cond := cond.(type) {
+	case *Poison:
+		return NewPoison(x.Type())
+	case *Undef:
+		// Pick the undef operand if any.
+		if isUndef(x) {
+			return x
+		}
+		return y
+	case *Int:
+		if cond.X.Sign() != 0 {
+			return x
+		}
+		return y
+	}
+	if x == y {
+		return x
+	}
+	return nil
+}
+
+// ### [ Helper functions ] ####################################################
+
+// hasFlag reports whether the given overflow flag is present in flags.
+func hasFlag(flags []enum.OverflowFlag, flag enum.OverflowFlag) bool {
+	for _, f := range flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// isUndef reports whether c is an undef constant.
+func isUndef(c Constant) bool {
+	_, ok := c.(*Undef)
+	return ok
+}
+
+// isPoison reports whether c is a poison constant.
+func isPoison(c Constant) bool {
+	_, ok := c.(*Poison)
+	return ok
+}
+
+// zeroValue returns the zero value of the given type.
+func zeroValue(typ types.Type) Constant {
+	switch t := typ.(type) {
+	case *types.IntType:
+		return NewInt(t, 0)
+	case *types.FloatType:
+		return NewFloat(t, 0)
+	case *types.PointerType:
+		return NewNull(t)
+	}
+	return NewZeroInitializer(typ)
+}
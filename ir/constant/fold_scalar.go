@@ -0,0 +1,714 @@
+package constant
+
+import (
+	"math"
+	"math/big"
+
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/mewmew/float/binary16"
+)
+
+// --- [ Integer folding ] -----------------------------------------------------
+
+// intOp is an integer binary operation.
+type intOp uint8
+
+// Integer binary operations.
+const (
+	opAdd intOp = iota
+	opSub
+	opMul
+	opUDiv
+	opSDiv
+	opURem
+	opSRem
+	opShl
+	opLShr
+	opAShr
+	opAnd
+	opOr
+	opXor
+)
+
+// foldIntBinary returns the result of constant folding the given integer binary
+// operation with scalar operands; or nil if the operands cannot be evaluated.
+func foldIntBinary(op intOp, x, y Constant, nsw, nuw, exact bool) Constant {
+	typ, ok := x.Type().(*types.IntType)
+	if !ok {
+		return nil
+	}
+	if isPoison(x) || isPoison(y) {
+		return NewPoison(typ)
+	}
+	if isUndef(x) || isUndef(y) {
+		return foldIntUndef(op, typ, x, y)
+	}
+	a, ok := intValue(x)
+	if !ok {
+		return nil
+	}
+	b, ok := intValue(y)
+	if !ok {
+		return nil
+	}
+	n := uint(typ.BitSize)
+	ua, ub := unsigned(a, n), unsigned(b, n)
+	sa, sb := signed(a, n), signed(b, n)
+	r := &big.Int{}
+	switch op {
+	case opAdd:
+		r.Add(ua, ub)
+		if nuw && r.BitLen() > int(n) {
+			return NewPoison(typ)
+		}
+		if nsw && !inSignedRange(new(big.Int).Add(sa, sb), n) {
+			return NewPoison(typ)
+		}
+	case opSub:
+		r.Sub(ua, ub)
+		if nuw && r.Sign() < 0 {
+			return NewPoison(typ)
+		}
+		if nsw && !inSignedRange(new(big.Int).Sub(sa, sb), n) {
+			return NewPoison(typ)
+		}
+	case opMul:
+		r.Mul(ua, ub)
+		if nuw && r.BitLen() > int(n) {
+			return NewPoison(typ)
+		}
+		if nsw && !inSignedRange(new(big.Int).Mul(sa, sb), n) {
+			return NewPoison(typ)
+		}
+	case opUDiv, opURem:
+		if ub.Sign() == 0 {
+			// Division by zero.
+			return NewPoison(typ)
+		}
+		rem := &big.Int{}
+		r.QuoRem(ua, ub, rem)
+		if op == opURem {
+			r = rem
+		} else if exact && rem.Sign() != 0 {
+			return NewPoison(typ)
+		}
+	case opSDiv, opSRem:
+		if sb.Sign() == 0 {
+			// Division by zero.
+			return NewPoison(typ)
+		}
+		if sb.Cmp(big.NewInt(-1)) == 0 && sa.Cmp(minSigned(n)) == 0 {
+			// Signed overflow.
+			return NewPoison(typ)
+		}
+		rem := &big.Int{}
+		r.QuoRem(sa, sb, rem)
+		if op == opSRem {
+			r = rem
+		} else if exact && rem.Sign() != 0 {
+			return NewPoison(typ)
+		}
+	case opShl, opLShr, opAShr:
+		if !ub.IsUint64() || ub.Uint64() >= uint64(n) {
+			// Shift amount greater than or equal to the bit width.
+			return NewPoison(typ)
+		}
+		s := uint(ub.Uint64())
+		switch op {
+		case opShl:
+			r.Lsh(ua, s)
+			if nuw && r.BitLen() > int(n) {
+				return NewPoison(typ)
+			}
+			if nsw && new(big.Int).Rsh(signed(r, n), s).Cmp(sa) != 0 {
+				return NewPoison(typ)
+			}
+		case opLShr:
+			r.Rsh(ua, s)
+			if exact && new(big.Int).Lsh(r, s).Cmp(ua) != 0 {
+				return NewPoison(typ)
+			}
+		case opAShr:
+			r.Rsh(sa, s)
+			if exact && new(big.Int).Lsh(r, s).Cmp(sa) != 0 {
+				return NewPoison(typ)
+			}
+		}
+	case opAnd:
+		r.And(ua, ub)
+	case opOr:
+		r.Or(ua, ub)
+	case opXor:
+		r.Xor(ua, ub)
+	default:
+		panic("unreachable")
+	}
+	return newInt(typ, r)
+}
+
+// foldIntUndef returns the result of constant folding the given integer binary
+// operation with at least one undef scalar operand.
+func foldIntUndef(op intOp, typ *types.IntType, x, y Constant) Constant {
+	bothUndef := isUndef(x) && isUndef(y)
+	switch op {
+	case opAdd, opSub:
+		return NewUndef(typ)
+	case opXor:
+		if bothUndef {
+			// undef ^ undef = 0
+			return NewInt(typ, 0)
+		}
+		return NewUndef(typ)
+	case opAnd, opMul:
+		if bothUndef {
+			return NewUndef(typ)
+		}
+		// undef & X = 0; undef * X = 0
+		return NewInt(typ, 0)
+	case opOr:
+		if bothUndef {
+			return NewUndef(typ)
+		}
+		// undef | X = -1
+		return newInt(typ, big.NewInt(-1))
+	case opUDiv, opSDiv, opURem, opSRem:
+		if isUndef(y) {
+			// X / undef may be a division by zero.
+			return NewPoison(typ)
+		}
+		// undef / X = 0; undef % X = 0
+		return NewInt(typ, 0)
+	case opShl, opLShr, opAShr:
+		if isUndef(y) {
+			// X << undef may shift by more than the bit width.
+			return NewPoison(typ)
+		}
+		if op == opAShr {
+			return NewUndef(typ)
+		}
+		// undef << X = 0; undef >> X = 0
+		return NewInt(typ, 0)
+	}
+	return nil
+}
+
+// foldICmp returns the result of constant folding icmp with the given scalar
+// operands; or nil if the operands cannot be evaluated.
+func foldICmp(pred enum.IPred, x, y Constant) Constant {
+	if isPoison(x) || isPoison(y) {
+		return NewPoison(types.I1)
+	}
+	if isUndef(x) || isUndef(y) {
+		if pred == enum.IPredEQ || pred == enum.IPredNE || (isUndef(x) && isUndef(y)) {
+			return NewUndef(types.I1)
+		}
+		// Pick the undef operand equal to the other operand.
+		switch pred {
+		case enum.IPredSGE, enum.IPredSLE, enum.IPredUGE, enum.IPredULE:
+			return True
+		}
+		return False
+	}
+	// Null pointer comparisons.
+	_, xNull := x.(*Null)
+	_, yNull := y.(*Null)
+	if xNull && yNull {
+		switch pred {
+		case enum.IPredEQ, enum.IPredSGE, enum.IPredSLE, enum.IPredUGE, enum.IPredULE:
+			return True
+		}
+		return False
+	}
+	typ, ok := x.Type().(*types.IntType)
+	if !ok {
+		return nil
+	}
+	a, ok := intValue(x)
+	if !ok {
+		return nil
+	}
+	b, ok := intValue(y)
+	if !ok {
+		return nil
+	}
+	n := uint(typ.BitSize)
+	ucmp := unsigned(a, n).Cmp(unsigned(b, n))
+	scmp := signed(a, n).Cmp(signed(b, n))
+	var r bool
+	switch pred {
+	case enum.IPredEQ:
+		r = ucmp == 0
+	case enum.IPredNE:
+		r = ucmp != 0
+	case enum.IPredSGE:
+		r = scmp >= 0
+	case enum.IPredSGT:
+		r = scmp > 0
+	case enum.IPredSLE:
+		r = scmp <= 0
+	case enum.IPredSLT:
+		r = scmp < 0
+	case enum.IPredUGE:
+		r = ucmp >= 0
+	case enum.IPredUGT:
+		r = ucmp > 0
+	case enum.IPredULE:
+		r = ucmp <= 0
+	case enum.IPredULT:
+		r = ucmp < 0
+	default:
+		return nil
+	}
+	return NewBool(r)
+}
+
+// --- [ Floating-point folding ] ----------------------------------------------
+
+// floatOp is a floating-point binary operation.
+type floatOp uint8
+
+// Floating-point binary operations.
+const (
+	fopAdd floatOp = iota
+	fopSub
+	fopMul
+	fopDiv
+	fopRem
+)
+
+// foldFloatBinary returns the result of constant folding the given
+// floating-point binary operation with scalar operands; or nil if the operands
+// cannot be evaluated.
+func foldFloatBinary(op floatOp, x, y Constant) Constant {
+	typ, ok := x.Type().(*types.FloatType)
+	if !ok {
+		return nil
+	}
+	if isPoison(x) || isPoison(y) {
+		return NewPoison(typ)
+	}
+	if isUndef(x) || isUndef(y) {
+		if isUndef(x) && isUndef(y) {
+			return NewUndef(typ)
+		}
+		// Pick NaN for the undef operand.
+		return newFloat(typ, math.NaN())
+	}
+	a, ok := floatValue(x)
+	if !ok {
+		return nil
+	}
+	b, ok := floatValue(y)
+	if !ok {
+		return nil
+	}
+	var r float64
+	switch op {
+	case fopAdd:
+		r = a + b
+	case fopSub:
+		r = a - b
+	case fopMul:
+		r = a * b
+	case fopDiv:
+		r = a / b
+	case fopRem:
+		r = math.Mod(a, b)
+	default:
+		panic("unreachable")
+	}
+	return newFloat(typ, r)
+}
+
+// foldFNeg returns the result of constant folding fneg with the given scalar
+// operand; or nil if the operand cannot be evaluated.
+func foldFNeg(ops ...Constant) Constant {
+	x := ops[0]
+	typ, ok := x.Type().(*types.FloatType)
+	if !ok {
+		return nil
+	}
+	if isUndef(x) || isPoison(x) {
+		return x
+	}
+	a, ok := floatValue(x)
+	if !ok {
+		return nil
+	}
+	return newFloat(typ, -a)
+}
+
+// foldFCmp returns the result of constant folding fcmp with the given scalar
+// operands; or nil if the operands cannot be evaluated.
+func foldFCmp(pred enum.FPred, x, y Constant) Constant {
+	switch pred {
+	case enum.FPredFalse:
+		return False
+	case enum.FPredTrue:
+		return True
+	}
+	if isPoison(x) || isPoison(y) {
+		return NewPoison(types.I1)
+	}
+	if isUndef(x) || isUndef(y) {
+		// Pick NaN for the undef operand; unordered comparisons succeed and
+		// ordered comparisons fail.
+		switch pred {
+		case enum.FPredUEQ, enum.FPredUGE, enum.FPredUGT, enum.FPredULE, enum.FPredULT, enum.FPredUNE, enum.FPredUNO:
+			return True
+		}
+		return False
+	}
+	a, ok := floatValue(x)
+	if !ok {
+		return nil
+	}
+	b, ok := floatValue(y)
+	if !ok {
+		return nil
+	}
+	uno := math.IsNaN(a) || math.IsNaN(b)
+	var r bool
+	switch pred {
+	case enum.FPredOEQ:
+		r = !uno && a == b
+	case enum.FPredOGE:
+		r = !uno && a >= b
+	case enum.FPredOGT:
+		r = !uno && a > b
+	case enum.FPredOLE:
+		r = !uno && a <= b
+	case enum.FPredOLT:
+		r = !uno && a < b
+	case enum.FPredONE:
+		r = !uno && a != b
+	case enum.FPredORD:
+		r = !uno
+	case enum.FPredUEQ:
+		r = uno || a == b
+	case enum.FPredUGE:
+		r = uno || a >= b
+	case enum.FPredUGT:
+		r = uno || a > b
+	case enum.FPredULE:
+		r = uno || a <= b
+	case enum.FPredULT:
+		r = uno || a < b
+	case enum.FPredUNE:
+		r = uno || a != b
+	case enum.FPredUNO:
+		r = uno
+	default:
+		return nil
+	}
+	return NewBool(r)
+}
+
+// --- [ Conversion folding ] --------------------------------------------------
+
+// convOp is a conversion operation.
+type convOp uint8
+
+// Conversion operations.
+const (
+	convTrunc convOp = iota
+	convZExt
+	convSExt
+	convFPTrunc
+	convFPExt
+	convFPToUI
+	convFPToSI
+	convUIToFP
+	convSIToFP
+	convPtrToInt
+	convIntToPtr
+	convBitCast
+)
+
+// foldConv returns the result of constant folding the given conversion of the
+// scalar or vector value from to the type to; or nil if the value cannot be
+// evaluated.
+func foldConv(op convOp, from Constant, to types.Type) Constant {
+	if op == convBitCast {
+		if types.Equal(from.Type(), to) {
+			return from
+		}
+		if _, ok := to.(*types.VectorType); ok {
+			// Vector bit casts depend on the endianness of the data layout.
+			return nil
+		}
+	}
+	fold := func(ops ...Constant) Constant {
+		return foldConvScalar(op, ops[0], scalarType(to))
+	}
+	return lanewise(to, fold, from)
+}
+
+// foldConvScalar returns the result of constant folding the given conversion of
+// the scalar value from to the scalar type to; or nil if the value cannot be
+// evaluated.
+func foldConvScalar(op convOp, from Constant, to types.Type) Constant {
+	if isPoison(from) {
+		return NewPoison(to)
+	}
+	if isUndef(from) {
+		switch op {
+		case convZExt, convSExt, convUIToFP, convSIToFP:
+			// The result of zext and sext has equal top bits; pick zero. The
+			// result of uitofp and sitofp is bounded; pick zero.
+			return zeroValue(to)
+		}
+		return NewUndef(to)
+	}
+	switch op {
+	case convTrunc, convZExt, convSExt:
+		a, ok := intValue(from)
+		if !ok {
+			return nil
+		}
+		fromTyp := from.Type().(*types.IntType)
+		toTyp, ok := to.(*types.IntType)
+		if !ok {
+			return nil
+		}
+		n := uint(fromTyp.BitSize)
+		if op == convSExt {
+			return newInt(toTyp, signed(a, n))
+		}
+		return newInt(toTyp, unsigned(a, n))
+	case convFPTrunc, convFPExt:
+		a, ok := floatValue(from)
+		if !ok {
+			return nil
+		}
+		toTyp, ok := to.(*types.FloatType)
+		if !ok || !isSupportedFloat(toTyp) {
+			return nil
+		}
+		return newFloat(toTyp, a)
+	case convFPToUI, convFPToSI:
+		a, ok := floatValue(from)
+		if !ok {
+			return nil
+		}
+		toTyp, ok := to.(*types.IntType)
+		if !ok {
+			return nil
+		}
+		if math.IsNaN(a) || math.IsInf(a, 0) {
+			return NewPoison(toTyp)
+		}
+		r, _ := big.NewFloat(math.Trunc(a)).Int(nil)
+		n := uint(toTyp.BitSize)
+		if op == convFPToUI && (r.Sign() < 0 || r.BitLen() > int(n)) {
+			return NewPoison(toTyp)
+		}
+		if op == convFPToSI && !inSignedRange(r, n) {
+			return NewPoison(toTyp)
+		}
+		return newInt(toTyp, r)
+	case convUIToFP, convSIToFP:
+		a, ok := intValue(from)
+		if !ok {
+			return nil
+		}
+		toTyp, ok := to.(*types.FloatType)
+		if !ok || !isSupportedFloat(toTyp) {
+			return nil
+		}
+		n := uint(from.Type().(*types.IntType).BitSize)
+		x := unsigned(a, n)
+		if op == convSIToFP {
+			x = signed(a, n)
+		}
+		return roundFloat(toTyp, new(big.Float).SetInt(x))
+	case convPtrToInt:
+		if _, ok := from.(*Null); ok {
+			if toTyp, ok := to.(*types.IntType); ok {
+				return NewInt(toTyp, 0)
+			}
+		}
+	case convIntToPtr:
+		if a, ok := intValue(from); ok && a.Sign() == 0 {
+			if toTyp, ok := to.(*types.PointerType); ok && toTyp.AddrSpace == 0 {
+				return NewNull(toTyp)
+			}
+		}
+	case convBitCast:
+		return foldBitCast(from, to)
+	}
+	return nil
+}
+
+// foldBitCast returns the result of constant folding a bit cast between integer
+// and floating-point scalar types of equal size; or nil if the value cannot be
+// evaluated.
+func foldBitCast(from Constant, to types.Type) Constant {
+	switch to := to.(type) {
+	case *types.FloatType:
+		a, ok := intValue(from)
+		if !ok {
+			return nil
+		}
+		bits := unsigned(a, uint(from.Type().(*types.IntType).BitSize)).Uint64()
+		switch {
+		case to.Kind == types.FloatKindHalf && isIntType(from.Type(), 16):
+			x, _ := binary16.NewFromBits(uint16(bits)).Float64()
+			return newFloat(to, x)
+		case to.Kind == types.FloatKindFloat && isIntType(from.Type(), 32):
+			return newFloat(to, float64(math.Float32frombits(uint32(bits))))
+		case to.Kind == types.FloatKindDouble && isIntType(from.Type(), 64):
+			return newFloat(to, math.Float64frombits(bits))
+		}
+	case *types.IntType:
+		a, ok := floatValue(from)
+		if !ok {
+			return nil
+		}
+		var bits uint64
+		switch fromTyp := from.Type().(*types.FloatType); {
+		case fromTyp.Kind == types.FloatKindHalf && to.BitSize == 16:
+			h, _ := binary16.NewFromFloat64(a)
+			bits = uint64(h.Bits())
+		case fromTyp.Kind == types.FloatKindFloat && to.BitSize == 32:
+			bits = uint64(math.Float32bits(float32(a)))
+		case fromTyp.Kind == types.FloatKindDouble && to.BitSize == 64:
+			bits = math.Float64bits(a)
+		default:
+			return nil
+		}
+		return newInt(to, new(big.Int).SetUint64(bits))
+	}
+	return nil
+}
+
+// ### [ Helper functions ] ####################################################
+
+// intValue returns the value of the given integer constant, and a boolean
+// indicating success.
+func intValue(c Constant) (*big.Int, bool) {
+	switch c := c.(type) {
+	case *Int:
+		return c.X, true
+	case *ZeroInitializer:
+		if _, ok := c.Type().(*types.IntType); ok {
+			return &big.Int{}, true
+		}
+	}
+	return nil, false
+}
+
+// newInt returns a new integer constant of the given type, truncating x to the
+// bit width of the type. Integer constants are represented in signed form,
+// except for i1 which is represented as 0 or 1.
+func newInt(typ *types.IntType, x *big.Int) *Int {
+	n := uint(typ.BitSize)
+	if n == 1 {
+		return &Int{Typ: typ, X: unsigned(x, n)}
+	}
+	return &Int{Typ: typ, X: signed(x, n)}
+}
+
+// unsigned returns the unsigned interpretation of the n lowest bits of x.
+func unsigned(x *big.Int, n uint) *big.Int {
+	mask := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), n), big.NewInt(1))
+	return new(big.Int).And(x, mask)
+}
+
+// signed returns the two's complement signed interpretation of the n lowest
+// bits of x.
+func signed(x *big.Int, n uint) *big.Int {
+	u := unsigned(x, n)
+	if u.Bit(int(n)-1) == 1 {
+		u.Sub(u, new(big.Int).Lsh(big.NewInt(1), n))
+	}
+	return u
+}
+
+// minSigned returns the minimum signed integer of bit width n.
+func minSigned(n uint) *big.Int {
+	return new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), n-1))
+}
+
+// inSignedRange reports whether x is representable as a signed integer of bit
+// width n.
+func inSignedRange(x *big.Int, n uint) bool {
+	max := new(big.Int).Lsh(big.NewInt(1), n-1)
+	return x.Cmp(minSigned(n)) >= 0 && x.Cmp(max) < 0
+}
+
+// isIntType reports whether t is an integer type of bit width n.
+func isIntType(t types.Type, n uint64) bool {
+	it, ok := t.(*types.IntType)
+	return ok && it.BitSize == n
+}
+
+// scalarType returns the element type of the given vector type, or t itself if
+// not a vector type.
+func scalarType(t types.Type) types.Type {
+	if vt, ok := t.(*types.VectorType); ok {
+		return vt.ElemType
+	}
+	return t
+}
+
+// isSupportedFloat reports whether floating-point arithmetic is evaluated for
+// the given floating-point type.
+func isSupportedFloat(typ *types.FloatType) bool {
+	switch typ.Kind {
+	case types.FloatKindHalf, types.FloatKindFloat, types.FloatKindDouble:
+		return true
+	}
+	return false
+}
+
+// floatValue returns the value of the given floating-point constant, and a
+// boolean indicating success.
+func floatValue(c Constant) (float64, bool) {
+	switch c := c.(type) {
+	case *Float:
+		if !isSupportedFloat(c.Typ) {
+			return 0, false
+		}
+		if c.NaN {
+			if c.X.Signbit() {
+				return math.Copysign(math.NaN(), -1), true
+			}
+			return math.NaN(), true
+		}
+		x, _ := c.X.Float64()
+		return x, true
+	case *ZeroInitializer:
+		if t, ok := c.Type().(*types.FloatType); ok && isSupportedFloat(t) {
+			return 0, true
+		}
+	}
+	return 0, false
+}
+
+// newFloat returns a new floating-point constant of the given type, rounding x
+// to the precision of the type.
+func newFloat(typ *types.FloatType, x float64) *Float {
+	if math.IsNaN(x) || math.IsInf(x, 0) {
+		return NewFloat(typ, x)
+	}
+	return roundFloat(typ, big.NewFloat(x))
+}
+
+// roundFloat returns a new floating-point constant of the given type, rounding
+// x to the nearest value representable in the type.
+func roundFloat(typ *types.FloatType, x *big.Float) *Float {
+	var r float64
+	switch typ.Kind {
+	case types.FloatKindHalf:
+		h, _ := binary16.NewFromBig(x)
+		r, _ = h.Float64()
+	case types.FloatKindFloat:
+		f, _ := x.Float32()
+		r = float64(f)
+	default:
+		r, _ = x.Float64()
+	}
+	return NewFloat(typ, r)
+}
@@ -0,0 +1,137 @@
+package constant_test
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestFold(t *testing.T) {
+	golden := []struct {
+		// Constant to fold.
+		in string
+		// Expected folded constant.
+		want string
+	}{
+		// Binary expressions.
+		{in: "i32 add (i32 2, i32 3)", want: "i32 5"},
+		{in: "i8 add (i8 127, i8 1)", want: "i8 -128"},
+		{in: "i8 add nsw (i8 127, i8 1)", want: "i8 poison"},
+		{in: "i8 add nuw (i8 255, i8 1)", want: "i8 poison"},
+		{in: "i8 add nuw (i8 -2, i8 1)", want: "i8 -1"},
+		{in: "i32 sub nuw (i32 1, i32 2)", want: "i32 poison"},
+		{in: "i32 sub (i32 1, i32 2)", want: "i32 -1"},
+		{in: "i32 mul (i32 65536, i32 65536)", want: "i32 0"},
+		{in: "i32 mul nsw (i32 65536, i32 32768)", want: "i32 poison"},
+		{in: "i32 add (i32 mul (i32 2, i32 3), i32 1)", want: "i32 7"},
+		{in: "i32 add (i32 undef, i32 1)", want: "i32 undef"},
+		{in: "i32 add (i32 poison, i32 undef)", want: "i32 poison"},
+		{in: "i32 mul (i32 undef, i32 3)", want: "i32 0"},
+		// Bitwise expressions.
+		{in: "i32 shl (i32 1, i32 31)", want: "i32 -2147483648"},
+		{in: "i32 shl (i32 1, i32 32)", want: "i32 poison"},
+		{in: "i8 shl nsw (i8 64, i8 1)", want: "i8 poison"},
+		{in: "i8 shl nuw (i8 64, i8 1)", want: "i8 -128"},
+		{in: "i8 lshr (i8 -1, i8 4)", want: "i8 15"},
+		{in: "i8 lshr exact (i8 3, i8 1)", want: "i8 poison"},
+		{in: "i8 ashr (i8 -128, i8 7)", want: "i8 -1"},
+		{in: "i8 ashr exact (i8 -128, i8 7)", want: "i8 -1"},
+		{in: "i32 and (i32 12, i32 10)", want: "i32 8"},
+		{in: "i32 or (i32 12, i32 10)", want: "i32 14"},
+		{in: "i32 xor (i32 12, i32 10)", want: "i32 6"},
+		{in: "i32 and (i32 undef, i32 5)", want: "i32 0"},
+		{in: "i32 or (i32 undef, i32 5)", want: "i32 -1"},
+		{in: "i32 xor (i32 undef, i32 undef)", want: "i32 0"},
+		{in: "i32 shl (i32 1, i32 undef)", want: "i32 poison"},
+		// Comparison and select expressions.
+		{in: "i1 icmp slt (i32 -1, i32 0)", want: "i1 true"},
+		{in: "i1 icmp ult (i32 -1, i32 0)", want: "i1 false"},
+		{in: "i1 icmp eq (ptr null, ptr null)", want: "i1 true"},
+		{in: "i1 icmp eq (i32 undef, i32 0)", want: "i1 undef"},
+		{in: "i1 icmp ule (i32 undef, i32 0)", want: "i1 true"},
+		{in: "i1 fcmp olt (double 1.0, double 2.0)", want: "i1 true"},
+		{in: "i1 fcmp uno (double 0x7FF8000000000000, double 1.0)", want: "i1 true"},
+		{in: "i1 fcmp oeq (double 0x7FF8000000000000, double 0x7FF8000000000000)", want: "i1 false"},
+		{in: "i1 fcmp olt (double undef, double 1.0)", want: "i1 false"},
+		{in: "i32 select (i1 true, i32 1, i32 2)", want: "i32 1"},
+		{in: "i32 select (i1 false, i32 1, i32 2)", want: "i32 2"},
+		{in: "i32 select (i1 undef, i32 undef, i32 2)", want: "i32 undef"},
+		{in: "i32 select (i1 poison, i32 1, i32 2)", want: "i32 poison"},
+		{in: "<2 x i32> select (<2 x i1> <i1 true, i1 false>, <2 x i32> <i32 1, i32 2>, <2 x i32> <i32 3, i32 4>)", want: "<2 x i32> <i32 1, i32 4>"},
+		// Conversion expressions.
+		{in: "i8 trunc (i32 257 to i8)", want: "i8 1"},
+		{in: "i32 zext (i8 -1 to i32)", want: "i32 255"},
+		{in: "i32 sext (i8 -1 to i32)", want: "i32 -1"},
+		{in: "i32 zext (i8 undef to i32)", want: "i32 0"},
+		{in: "i32 fptosi (double 1.5e10 to i32)", want: "i32 poison"},
+		{in: "i8 fptoui (double 3.9 to i8)", want: "i8 3"},
+		{in: "i8 fptosi (double -3.9 to i8)", want: "i8 -3"},
+		{in: "double sitofp (i32 -3 to double)", want: "double -3.0"},
+		{in: "double uitofp (i32 -1 to double)", want: "double 4.294967295e+09"},
+		{in: "float bitcast (i32 1065353216 to float)", want: "float 1.0"},
+		{in: "i64 bitcast (double 1.0 to i64)", want: "i64 u0x3FF0000000000000"},
+		{in: "float fptrunc (double 0.1 to float)", want: "float 0x3FB99999A0000000"},
+		{in: "double fpext (float 0.5 to double)", want: "double 0.5"},
+		{in: "i64 ptrtoint (ptr null to i64)", want: "i64 0"},
+		{in: "ptr inttoptr (i64 0 to ptr)", want: "ptr null"},
+		{in: "double fneg (double 1.0)", want: "double -1.0"},
+		// Vector expressions.
+		{in: "<2 x i32> add (<2 x i32> <i32 1, i32 2>, <2 x i32> <i32 3, i32 4>)", want: "<2 x i32> <i32 4, i32 6>"},
+		{in: "<2 x i32> shl (<2 x i32> <i32 1, i32 1>, <2 x i32> <i32 1, i32 33>)", want: "<2 x i32> <i32 2, i32 poison>"},
+		{in: "i32 extractelement (<2 x i32> <i32 1, i32 2>, i32 1)", want: "i32 2"},
+		{in: "i32 extractelement (<2 x i32> <i32 1, i32 2>, i32 2)", want: "i32 poison"},
+		{in: "<2 x i32> insertelement (<2 x i32> zeroinitializer, i32 7, i32 1)", want: "<2 x i32> <i32 0, i32 7>"},
+		{in: "<2 x i32> shufflevector (<2 x i32> <i32 1, i32 2>, <2 x i32> <i32 3, i32 4>, <2 x i32> <i32 3, i32 0>)", want: "<2 x i32> <i32 4, i32 1>"},
+		// Aggregate constants.
+		{in: "{ i32, i8 } { i32 add (i32 1, i32 1), i8 3 }", want: "{ i32, i8 } { i32 2, i8 3 }"},
+		// Expressions which cannot be evaluated.
+		{in: "i64 add (i64 ptrtoint (ptr @g to i64), i64 mul (i64 2, i64 3))", want: "i64 add (i64 ptrtoint (ptr @g to i64), i64 6)"},
+		{in: "i64 ptrtoint (ptr @g to i64)", want: "i64 ptrtoint (ptr @g to i64)"},
+	}
+	for _, g := range golden {
+		src := "@g = global i32 0\n@x = global " + g.in + "\n"
+		m, err := asm.ParseString("fold.ll", src)
+		if err != nil {
+			t.Errorf("%q: unable to parse constant; %+v", g.in, err)
+			continue
+		}
+		got := constant.Fold(m.Globals[1].Init).String()
+		if got != g.want {
+			t.Errorf("%q: folded constant mismatch; expected %q, got %q", g.in, g.want, got)
+		}
+	}
+}
+
+func TestFoldInstruction(t *testing.T) {
+	i32 := func(x int64) constant.Constant { return constant.NewInt(types.I32, x) }
+	golden := []struct {
+		name string
+		got  constant.Constant
+		want string
+	}{
+		{name: "udiv", got: constant.FoldUDiv(i32(7), i32(2), false), want: "i32 3"},
+		{name: "udiv exact", got: constant.FoldUDiv(i32(7), i32(2), true), want: "i32 poison"},
+		{name: "udiv by zero", got: constant.FoldUDiv(i32(7), i32(0), false), want: "i32 poison"},
+		{name: "sdiv", got: constant.FoldSDiv(i32(-7), i32(2), false), want: "i32 -3"},
+		{name: "sdiv overflow", got: constant.FoldSDiv(i32(-2147483648), i32(-1), false), want: "i32 poison"},
+		{name: "urem", got: constant.FoldURem(i32(-1), i32(10)), want: "i32 5"},
+		{name: "srem", got: constant.FoldSRem(i32(-7), i32(2)), want: "i32 -1"},
+		{name: "fadd", got: constant.FoldFAdd(constant.NewFloat(types.Double, 0.5), constant.NewFloat(types.Double, 0.25)), want: "double 0.75"},
+		{name: "fdiv", got: constant.FoldFDiv(constant.NewFloat(types.Double, 1), constant.NewFloat(types.Double, 0)), want: "double 0x7FF0000000000000"},
+		{name: "fmul float", got: constant.FoldFMul(constant.NewFloat(types.Float, 3), constant.NewFloat(types.Float, 0.5)), want: "float 1.5"},
+		{name: "frem", got: constant.FoldFRem(constant.NewFloat(types.Double, 5.5), constant.NewFloat(types.Double, 2)), want: "double 1.5"},
+		{name: "extractvalue", got: constant.FoldExtractValue(constant.NewStruct(nil, i32(1), constant.NewArray(nil, i32(2), i32(3))), []uint64{1, 0}), want: "i32 2"},
+		{name: "insertvalue", got: constant.FoldInsertValue(constant.NewZeroInitializer(types.NewArray(2, types.I32)), i32(4), []uint64{1}), want: "[2 x i32] [i32 0, i32 4]"},
+	}
+	for _, g := range golden {
+		if g.got == nil {
+			t.Errorf("%q: unable to fold", g.name)
+			continue
+		}
+		if got := g.got.String(); got != g.want {
+			t.Errorf("%q: folded constant mismatch; expected %q, got %q", g.name, g.want, got)
+		}
+	}
+}
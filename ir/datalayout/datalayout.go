@@ -0,0 +1,241 @@
+// Package datalayout implements parsing of data layout strings, and queries of
+// the sizes and alignments of LLVM IR types in memory.
+//
+// Sizes of types are given in bytes, unless otherwise specified; bit sizes and
+// alignments of specifications are stored as given by the data layout string,
+// with sizes in bits and alignments in bytes.
+//
+// ref: https://llvm.org/docs/LangRef.html#data-layout
+package datalayout
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/llir/llvm/ir/types"
+)
+
+// DataLayout specifies how data is to be laid out in memory.
+//
+// Struct layouts are cached; a DataLayout must not be modified after the first
+// query of a struct layout, and is not safe for concurrent use.
+type DataLayout struct {
+	// Big-endian byte order.
+	BigEndian bool
+	// Pointer specifications by address space. Address spaces without a
+	// specification use the specification of address space 0.
+	Pointers map[types.AddrSpace]PointerSpec
+	// Integer alignment specifications, sorted by bit size.
+	Ints []AlignSpec
+	// Floating-point alignment specifications, sorted by bit size.
+	Floats []AlignSpec
+	// Vector alignment specifications, sorted by bit size.
+	Vectors []AlignSpec
+	// Aggregate (struct) ABI alignment in bytes.
+	AggABIAlign uint64
+	// Aggregate (struct) preferred alignment in bytes.
+	AggPrefAlign uint64
+	// Native integer widths in bits of the target CPU, in order of the data
+	// layout string.
+	NativeInts []uint64
+	// Natural stack alignment in bytes; or 0 if unspecified.
+	StackAlign uint64
+	// Function pointer alignment in bytes; or 0 if unspecified.
+	FuncPtrAlign uint64
+	// Function pointer alignment is a multiple of FuncPtrAlign, independent of
+	// the alignment of functions (Fi); rather than a multiple of the maximum of
+	// FuncPtrAlign and the alignment of functions (Fn).
+	FuncPtrAlignIndependent bool
+	// Address space of program memory (functions).
+	ProgramAddrSpace types.AddrSpace
+	// Default address space of global variables.
+	GlobalsAddrSpace types.AddrSpace
+	// Address space of allocas.
+	AllocaAddrSpace types.AddrSpace
+	// Non-integral pointer address spaces.
+	NonIntegralAddrSpaces []types.AddrSpace
+	// Mangling mode of symbol names.
+	Mangling Mangling
+
+	// Struct layouts, by struct type.
+	structs map[*types.StructType]*StructLayout
+}
+
+// PointerSpec is a pointer specification of an address space.
+type PointerSpec struct {
+	// Size in bits.
+	BitSize uint64
+	// ABI alignment in bytes.
+	ABIAlign uint64
+	// Preferred alignment in bytes.
+	PrefAlign uint64
+	// Size in bits of indices used in address calculations.
+	IndexBitSize uint64
+}
+
+// AlignSpec is an alignment specification of integer, floating-point or vector
+// types of a given bit size.
+type AlignSpec struct {
+	// Size in bits.
+	BitSize uint64
+	// ABI alignment in bytes.
+	ABIAlign uint64
+	// Preferred alignment in bytes.
+	PrefAlign uint64
+}
+
+// Mangling is the mangling mode of symbol names.
+type Mangling uint8
+
+// Mangling modes.
+const (
+	ManglingNone       Mangling = iota // no mangling
+	ManglingELF                        // m:e
+	ManglingGOFF                       // m:l
+	ManglingMachO                      // m:o
+	ManglingMips                       // m:m
+	ManglingWinCOFF                    // m:w
+	ManglingWinCOFFX86                 // m:x
+	ManglingXCOFF                      // m:a
+)
+
+// String returns the data layout string representation of the mangling mode.
+func (m Mangling) String() string {
+	switch m {
+	case ManglingNone:
+		return "none"
+	case ManglingELF:
+		return "e"
+	case ManglingGOFF:
+		return "l"
+	case ManglingMachO:
+		return "o"
+	case ManglingMips:
+		return "m"
+	case ManglingWinCOFF:
+		return "w"
+	case ManglingWinCOFFX86:
+		return "x"
+	case ManglingXCOFF:
+		return "a"
+	}
+	return fmt.Sprintf("Mangling(%d)", uint8(m))
+}
+
+// Default returns the default data layout of LLVM, as used for modules without
+// a data layout string.
+func Default() *DataLayout {
+	return &DataLayout{
+		Pointers: map[types.AddrSpace]PointerSpec{
+			0: {BitSize: 64, ABIAlign: 8, PrefAlign: 8, IndexBitSize: 64},
+		},
+		Ints: []AlignSpec{
+			{BitSize: 1, ABIAlign: 1, PrefAlign: 1},
+			{BitSize: 8, ABIAlign: 1, PrefAlign: 1},
+			{BitSize: 16, ABIAlign: 2, PrefAlign: 2},
+			{BitSize: 32, ABIAlign: 4, PrefAlign: 4},
+			{BitSize: 64, ABIAlign: 4, PrefAlign: 8},
+		},
+		Floats: []AlignSpec{
+			{BitSize: 16, ABIAlign: 2, PrefAlign: 2},
+			{BitSize: 32, ABIAlign: 4, PrefAlign: 4},
+			{BitSize: 64, ABIAlign: 8, PrefAlign: 8},
+			{BitSize: 128, ABIAlign: 16, PrefAlign: 16},
+		},
+		Vectors: []AlignSpec{
+			{BitSize: 64, ABIAlign: 8, PrefAlign: 8},
+			{BitSize: 128, ABIAlign: 16, PrefAlign: 16},
+		},
+		AggABIAlign:  1,
+		AggPrefAlign: 8,
+		structs:      make(map[*types.StructType]*StructLayout),
+	}
+}
+
+// PointerSpec returns the pointer specification of the given address space.
+func (dl *DataLayout) PointerSpec(addrSpace types.AddrSpace) PointerSpec {
+	if spec, ok := dl.Pointers[addrSpace]; ok {
+		return spec
+	}
+	return dl.Pointers[0]
+}
+
+// PointerSize returns the size in bytes of pointers in the given address
+// space.
+func (dl *DataLayout) PointerSize(addrSpace types.AddrSpace) uint64 {
+	return (dl.PointerSpec(addrSpace).BitSize + 7) / 8
+}
+
+// IntPtrType returns the integer type with the same size as pointers in the
+// given address space.
+func (dl *DataLayout) IntPtrType(addrSpace types.AddrSpace) *types.IntType {
+	return types.NewInt(dl.PointerSpec(addrSpace).BitSize)
+}
+
+// IndexType returns the integer type of indices used in address calculations
+// of pointers in the given address space.
+func (dl *DataLayout) IndexType(addrSpace types.AddrSpace) *types.IntType {
+	return types.NewInt(dl.PointerSpec(addrSpace).IndexBitSize)
+}
+
+// IsNonIntegral reports whether pointers in the given address space are
+// non-integral.
+func (dl *DataLayout) IsNonIntegral(addrSpace types.AddrSpace) bool {
+	for _, as := range dl.NonIntegralAddrSpaces {
+		if as == addrSpace {
+			return true
+		}
+	}
+	return false
+}
+
+// IsLegalInt reports whether the given integer bit size is a native integer
+// width of the target CPU.
+func (dl *DataLayout) IsLegalInt(bitSize uint64) bool {
+	for _, n := range dl.NativeInts {
+		if n == bitSize {
+			return true
+		}
+	}
+	return false
+}
+
+// LargestLegalIntBitSize returns the largest native integer width in bits of
+// the target CPU; or 0 if no native integer widths are specified.
+func (dl *DataLayout) LargestLegalIntBitSize() uint64 {
+	max := uint64(0)
+	for _, n := range dl.NativeInts {
+		if n > max {
+			max = n
+		}
+	}
+	return max
+}
+
+// ### [ Helper functions ] ####################################################
+
+// setAlign sets the alignment specification of the given bit size, keeping the
+// specifications sorted by bit size.
+func setAlign(specs []AlignSpec, spec AlignSpec) []AlignSpec {
+	i := sort.Search(len(specs), func(i int) bool {
+		return specs[i].BitSize >= spec.BitSize
+	})
+	if i < len(specs) && specs[i].BitSize == spec.BitSize {
+		specs[i] = spec
+		return specs
+	}
+	specs = append(specs, AlignSpec{})
+	copy(specs[i+1:], specs[i:])
+	specs[i] = spec
+	return specs
+}
+
+// findAlign returns the alignment specification of the given bit size.
+func findAlign(specs []AlignSpec, bitSize uint64) (AlignSpec, bool) {
+	for _, spec := range specs {
+		if spec.BitSize == bitSize {
+			return spec, true
+		}
+	}
+	return AlignSpec{}, false
+}
@@ -0,0 +1,123 @@
+package datalayout_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/llir/llvm/ir/datalayout"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestParse(t *testing.T) {
+	const x86_64 = "e-m:e-p270:32:32-p271:32:32-p272:64:64-i64:64-f80:128-n8:16:32:64-S128"
+	dl, err := datalayout.Parse(x86_64)
+	if err != nil {
+		t.Fatalf("unable to parse data layout %q; %+v", x86_64, err)
+	}
+	if dl.BigEndian {
+		t.Errorf("endianness mismatch; expected little-endian")
+	}
+	if got, want := dl.Mangling, datalayout.ManglingELF; got != want {
+		t.Errorf("mangling mode mismatch; expected %v, got %v", want, got)
+	}
+	if got, want := dl.PointerSpec(270), (datalayout.PointerSpec{BitSize: 32, ABIAlign: 4, PrefAlign: 4, IndexBitSize: 32}); got != want {
+		t.Errorf("pointer specification mismatch; expected %+v, got %+v", want, got)
+	}
+	if got, want := dl.PointerSize(1), uint64(8); got != want {
+		t.Errorf("pointer size mismatch; expected %d, got %d", want, got)
+	}
+	if got, want := dl.NativeInts, []uint64{8, 16, 32, 64}; !reflect.DeepEqual(got, want) {
+		t.Errorf("native integer widths mismatch; expected %v, got %v", want, got)
+	}
+	if !dl.IsLegalInt(32) || dl.IsLegalInt(128) {
+		t.Errorf("legal integer mismatch")
+	}
+	if got, want := dl.StackAlign, uint64(16); got != want {
+		t.Errorf("stack alignment mismatch; expected %d, got %d", want, got)
+	}
+	// Invalid data layout strings.
+	golden := []string{
+		"x",
+		"e-",
+		"p:64",
+		"p:64:12",
+		"p:32:32:32:64",
+		"i8:16",
+		"i32:32:16",
+		"a1:8",
+		"m:q",
+		"S12",
+		"Fq8",
+		"ni:0",
+		"n0",
+	}
+	for _, s := range golden {
+		if _, err := datalayout.Parse(s); err == nil {
+			t.Errorf("%q: expected error, got nil", s)
+		}
+	}
+}
+
+func TestSize(t *testing.T) {
+	// Expected values computed by LLVM 14.
+	const layout = "E-m:e-p:32:32-p1:16:16:16-i64:64-f80:32-v128:64-a:0:32-n32-S64"
+	dl, err := datalayout.Parse(layout)
+	if err != nil {
+		t.Fatalf("unable to parse data layout %q; %+v", layout, err)
+	}
+	s := types.NewStruct(types.I8, types.I32, types.I16, types.Double)
+	p := types.NewStruct(types.I8, types.I32, types.I16)
+	p.Packed = true
+	golden := []struct {
+		typ       types.Type
+		size      uint64
+		allocSize uint64
+		abiAlign  uint64
+		prefAlign uint64
+	}{
+		{typ: types.I1, size: 1, allocSize: 1, abiAlign: 1, prefAlign: 1},
+		{typ: types.NewInt(37), size: 5, allocSize: 8, abiAlign: 8, prefAlign: 8},
+		{typ: types.I128, size: 16, allocSize: 16, abiAlign: 8, prefAlign: 8},
+		{typ: types.X86_FP80, size: 10, allocSize: 12, abiAlign: 4, prefAlign: 4},
+		{typ: types.FP128, size: 16, allocSize: 16, abiAlign: 16, prefAlign: 16},
+		{typ: types.NewPointer(types.I8), size: 4, allocSize: 4, abiAlign: 4, prefAlign: 4},
+		{typ: &types.PointerType{AddrSpace: 1}, size: 2, allocSize: 2, abiAlign: 2, prefAlign: 2},
+		{typ: s, size: 24, allocSize: 24, abiAlign: 8, prefAlign: 8},
+		{typ: p, size: 7, allocSize: 7, abiAlign: 1, prefAlign: 4},
+		{typ: types.NewVector(3, types.I32), size: 12, allocSize: 16, abiAlign: 16, prefAlign: 16},
+		{typ: types.NewVector(4, types.I32), size: 16, allocSize: 16, abiAlign: 8, prefAlign: 8},
+		{typ: types.NewVector(8, types.I1), size: 1, allocSize: 1, abiAlign: 1, prefAlign: 1},
+		{typ: types.NewArray(3, types.NewInt(24)), size: 12, allocSize: 12, abiAlign: 4, prefAlign: 4},
+	}
+	for _, g := range golden {
+		if got := dl.SizeOf(g.typ); got != g.size {
+			t.Errorf("%v: size mismatch; expected %d, got %d", g.typ, g.size, got)
+		}
+		if got := dl.AllocSizeOf(g.typ); got != g.allocSize {
+			t.Errorf("%v: allocation size mismatch; expected %d, got %d", g.typ, g.allocSize, got)
+		}
+		if got := dl.ABIAlignOf(g.typ); got != g.abiAlign {
+			t.Errorf("%v: ABI alignment mismatch; expected %d, got %d", g.typ, g.abiAlign, got)
+		}
+		if got := dl.PrefAlignOf(g.typ); got != g.prefAlign {
+			t.Errorf("%v: preferred alignment mismatch; expected %d, got %d", g.typ, g.prefAlign, got)
+		}
+	}
+	// Struct layouts.
+	sl := dl.StructLayout(s)
+	if got, want := sl.Offsets, []uint64{0, 4, 8, 16}; !reflect.DeepEqual(got, want) {
+		t.Errorf("struct field offsets mismatch; expected %v, got %v", want, got)
+	}
+	if got, want := sl.Padding, []uint64{3, 0, 6, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("struct padding mismatch; expected %v, got %v", want, got)
+	}
+	if got, want := sl.FieldAt(10), 2; got != want {
+		t.Errorf("struct field at offset 10 mismatch; expected %d, got %d", want, got)
+	}
+	if got, want := sl.FieldAt(24), -1; got != want {
+		t.Errorf("struct field at offset 24 mismatch; expected %d, got %d", want, got)
+	}
+	if got, want := dl.StructLayout(p).Offsets, []uint64{0, 1, 5}; !reflect.DeepEqual(got, want) {
+		t.Errorf("packed struct field offsets mismatch; expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,296 @@
+package datalayout
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/llir/llvm/ir/types"
+	"github.com/pkg/errors"
+)
+
+// Parse parses the given data layout string. Properties not specified by the
+// data layout string have the default values of LLVM (see Default).
+func Parse(s string) (*DataLayout, error) {
+	dl := Default()
+	if s == "" {
+		return dl, nil
+	}
+	for _, spec := range strings.Split(s, "-") {
+		if err := dl.parseSpec(spec); err != nil {
+			return nil, errors.Wrapf(err, "invalid data layout specification %q of %q", spec, s)
+		}
+	}
+	return dl, nil
+}
+
+// parseSpec parses the given data layout specification.
+func (dl *DataLayout) parseSpec(spec string) error {
+	if spec == "" {
+		return errors.New("empty specification")
+	}
+	// Specifications with colon-separated fields; the first field contains the
+	// specification kind followed by an optional number.
+	parts := strings.Split(spec, ":")
+	kind, arg := spec[0], parts[0][1:]
+	fields := parts[1:]
+	switch kind {
+	// Endianness.
+	case 'e', 'E':
+		if len(spec) != 1 {
+			return errors.New("unexpected data after endianness")
+		}
+		dl.BigEndian = kind == 'E'
+	// Pointer specification.
+	case 'p':
+		// p[n]:<size>:<abi>[:<pref>][:<idx>]
+		addrSpace, err := parseAddrSpace(arg)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if len(fields) < 2 || len(fields) > 4 {
+			return errors.Errorf("invalid number of pointer fields; expected 2 to 4, got %d", len(fields))
+		}
+		size, err := parseSize(fields[0])
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		abi, pref, err := parseAligns(fields[1:2], fields[2:])
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if abi == 0 {
+			return errors.New("invalid pointer ABI alignment 0")
+		}
+		idx := size
+		if len(fields) == 4 {
+			if idx, err = parseSize(fields[3]); err != nil {
+				return errors.WithStack(err)
+			}
+			if idx > size {
+				return errors.Errorf("index size %d larger than pointer size %d", idx, size)
+			}
+		}
+		dl.Pointers[addrSpace] = PointerSpec{BitSize: size, ABIAlign: abi, PrefAlign: pref, IndexBitSize: idx}
+	// Integer, floating-point and vector alignment specifications.
+	case 'i', 'f', 'v':
+		// i<size>:<abi>[:<pref>]
+		size, err := parseSize(arg)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if len(fields) < 1 || len(fields) > 2 {
+			return errors.Errorf("invalid number of alignment fields; expected 1 or 2, got %d", len(fields))
+		}
+		abi, pref, err := parseAligns(fields[:1], fields[1:])
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if abi == 0 {
+			return errors.New("invalid ABI alignment 0")
+		}
+		a := AlignSpec{BitSize: size, ABIAlign: abi, PrefAlign: pref}
+		switch kind {
+		case 'i':
+			if size == 8 && abi != 1 {
+				return errors.Errorf("invalid ABI alignment of i8; expected 8, got %d", 8*abi)
+			}
+			dl.Ints = setAlign(dl.Ints, a)
+		case 'f':
+			dl.Floats = setAlign(dl.Floats, a)
+		case 'v':
+			dl.Vectors = setAlign(dl.Vectors, a)
+		}
+	// Aggregate alignment specification.
+	case 'a':
+		// a:<abi>[:<pref>]
+		if arg != "" {
+			return errors.New("unexpected size of aggregate alignment")
+		}
+		if len(fields) < 1 || len(fields) > 2 {
+			return errors.Errorf("invalid number of alignment fields; expected 1 or 2, got %d", len(fields))
+		}
+		abi, pref, err := parseAligns(fields[:1], fields[1:])
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		// An ABI alignment of 0 denotes byte alignment.
+		if abi == 0 {
+			abi = 1
+		}
+		dl.AggABIAlign, dl.AggPrefAlign = abi, pref
+	// Native integer widths and non-integral address spaces.
+	case 'n':
+		// n<size>[:<size>]...
+		// ni:<addrspace>[:<addrspace>]...
+		if arg == "i" {
+			for _, field := range fields {
+				addrSpace, err := parseAddrSpace(field)
+				if err != nil {
+					return errors.WithStack(err)
+				}
+				if addrSpace == 0 {
+					return errors.New("address space 0 cannot be non-integral")
+				}
+				dl.NonIntegralAddrSpaces = append(dl.NonIntegralAddrSpaces, addrSpace)
+			}
+			break
+		}
+		dl.NativeInts = nil
+		for _, field := range append([]string{arg}, fields...) {
+			size, err := parseSize(field)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			dl.NativeInts = append(dl.NativeInts, size)
+		}
+	// Stack alignment.
+	case 'S':
+		// S<size>
+		align, err := parseAlign(arg)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		dl.StackAlign = align
+	// Function pointer alignment.
+	case 'F':
+		// F<type><abi>
+		if arg == "" {
+			return errors.New("missing function pointer alignment type")
+		}
+		switch arg[0] {
+		case 'i':
+			dl.FuncPtrAlignIndependent = true
+		case 'n':
+			dl.FuncPtrAlignIndependent = false
+		default:
+			return errors.Errorf("unknown function pointer alignment type %q", arg[0])
+		}
+		align, err := parseAlign(arg[1:])
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		dl.FuncPtrAlign = align
+	// Address spaces of program memory, global variables and allocas.
+	case 'P', 'G', 'A':
+		addrSpace, err := parseAddrSpace(arg)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		switch kind {
+		case 'P':
+			dl.ProgramAddrSpace = addrSpace
+		case 'G':
+			dl.GlobalsAddrSpace = addrSpace
+		case 'A':
+			dl.AllocaAddrSpace = addrSpace
+		}
+	// Mangling mode.
+	case 'm':
+		// m:<mangling>
+		if arg != "" || len(fields) != 1 || len(fields[0]) != 1 {
+			return errors.New("invalid mangling mode specification; expected m:<mangling>")
+		}
+		switch fields[0][0] {
+		case 'e':
+			dl.Mangling = ManglingELF
+		case 'l':
+			dl.Mangling = ManglingGOFF
+		case 'o':
+			dl.Mangling = ManglingMachO
+		case 'm':
+			dl.Mangling = ManglingMips
+		case 'w':
+			dl.Mangling = ManglingWinCOFF
+		case 'x':
+			dl.Mangling = ManglingWinCOFFX86
+		case 'a':
+			dl.Mangling = ManglingXCOFF
+		default:
+			return errors.Errorf("unknown mangling mode %q", fields[0])
+		}
+	default:
+		return errors.Errorf("unknown specifier %q", kind)
+	}
+	return nil
+}
+
+// ### [ Helper functions ] ####################################################
+
+// parseAligns parses the given ABI alignment and optional preferred alignment
+// fields in bits, and returns the alignments in bytes. The preferred alignment
+// defaults to the ABI alignment.
+func parseAligns(abiField, prefField []string) (abi, pref uint64, err error) {
+	if abi, err = parseAlign(abiField[0]); err != nil {
+		return 0, 0, errors.WithStack(err)
+	}
+	pref = abi
+	if len(prefField) > 0 {
+		if pref, err = parseAlign(prefField[0]); err != nil {
+			return 0, 0, errors.WithStack(err)
+		}
+		if pref < abi {
+			return 0, 0, errors.Errorf("preferred alignment %d smaller than ABI alignment %d", 8*pref, 8*abi)
+		}
+	}
+	return abi, pref, nil
+}
+
+// parseAlign parses the given alignment in bits, and returns the alignment in
+// bytes. The alignment must be 0 or a power of two multiple of 8.
+func parseAlign(s string) (uint64, error) {
+	bits, err := parseUint(s)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if bits%8 != 0 {
+		return 0, errors.Errorf("invalid alignment %d; not a multiple of 8", bits)
+	}
+	align := bits / 8
+	if align&(align-1) != 0 {
+		return 0, errors.Errorf("invalid alignment %d; not a power of two", bits)
+	}
+	return align, nil
+}
+
+// parseSize parses the given non-zero size in bits.
+func parseSize(s string) (uint64, error) {
+	size, err := parseUint(s)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if size == 0 {
+		return 0, errors.New("invalid size 0")
+	}
+	if size >= 1<<24 {
+		return 0, errors.Errorf("invalid size %d; too large", size)
+	}
+	return size, nil
+}
+
+// parseAddrSpace parses the given address space; an empty string denotes
+// address space 0.
+func parseAddrSpace(s string) (types.AddrSpace, error) {
+	if s == "" {
+		return 0, nil
+	}
+	x, err := parseUint(s)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if x >= 1<<24 {
+		return 0, errors.Errorf("invalid address space %d; too large", x)
+	}
+	return types.AddrSpace(x), nil
+}
+
+// parseUint parses the given unsigned decimal integer.
+func parseUint(s string) (uint64, error) {
+	if s == "" {
+		return 0, errors.New("missing number")
+	}
+	x, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, errors.Errorf("invalid number %q", s)
+	}
+	return x, nil
+}
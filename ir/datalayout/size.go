@@ -0,0 +1,229 @@
+package datalayout
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/llir/llvm/ir/types"
+)
+
+// StructLayout is the memory layout of a struct type.
+type StructLayout struct {
+	// Size in bytes, including tail padding.
+	Size uint64
+	// Alignment in bytes of the fields of the struct; 1 for packed structs.
+	// The ABI alignment of the struct type is the maximum of Align and the
+	// aggregate ABI alignment of the data layout.
+	Align uint64
+	// Offset in bytes of each field.
+	Offsets []uint64
+	// Padding in bytes following each field; the padding of the last field is
+	// the tail padding of the struct.
+	Padding []uint64
+}
+
+// FieldAt returns the index of the field containing the given byte offset;
+// or -1 if the offset is not within the struct. Offsets within padding
+// belong to the preceding field.
+func (sl *StructLayout) FieldAt(offset uint64) int {
+	if offset >= sl.Size || len(sl.Offsets) == 0 {
+		return -1
+	}
+	i := sort.Search(len(sl.Offsets), func(i int) bool {
+		return sl.Offsets[i] > offset
+	})
+	return i - 1
+}
+
+// BitSizeOf returns the size in bits of the given type; i.e. the number of bits
+// necessary to hold a value of the type.
+func (dl *DataLayout) BitSizeOf(t types.Type) uint64 {
+	switch t := t.(type) {
+	case *types.IntType:
+		return t.BitSize
+	case *types.FloatType:
+		return floatBitSize(t)
+	case *types.MMXType:
+		return 64
+	case *types.PointerType:
+		return dl.PointerSpec(t.AddrSpace).BitSize
+	case *types.LabelType:
+		return dl.PointerSpec(0).BitSize
+	case *types.VectorType:
+		if t.Scalable {
+			panic(fmt.Errorf("unable to compute size of scalable vector type %v", t))
+		}
+		return t.Len * dl.BitSizeOf(t.ElemType)
+	case *types.ArrayType:
+		return 8 * t.Len * dl.AllocSizeOf(t.ElemType)
+	case *types.StructType:
+		return 8 * dl.StructLayout(t).Size
+	}
+	panic(fmt.Errorf("unable to compute size of unsized type %v", t))
+}
+
+// SizeOf returns the store size in bytes of the given type; i.e. the maximum
+// number of bytes that may be overwritten by storing a value of the type.
+func (dl *DataLayout) SizeOf(t types.Type) uint64 {
+	return (dl.BitSizeOf(t) + 7) / 8
+}
+
+// AllocSizeOf returns the allocation size in bytes of the given type; i.e. the
+// offset in bytes between successive values of the type in memory, including
+// alignment padding.
+func (dl *DataLayout) AllocSizeOf(t types.Type) uint64 {
+	return AlignTo(dl.SizeOf(t), dl.ABIAlignOf(t))
+}
+
+// ABIAlignOf returns the ABI alignment in bytes of the given type.
+func (dl *DataLayout) ABIAlignOf(t types.Type) uint64 {
+	return dl.alignOf(t, true)
+}
+
+// PrefAlignOf returns the preferred alignment in bytes of the given type.
+func (dl *DataLayout) PrefAlignOf(t types.Type) uint64 {
+	return dl.alignOf(t, false)
+}
+
+// StructLayout returns the memory layout of the given struct type, which must
+// not be opaque.
+func (dl *DataLayout) StructLayout(t *types.StructType) *StructLayout {
+	if sl, ok := dl.structs[t]; ok {
+		return sl
+	}
+	if t.Opaque {
+		panic(fmt.Errorf("unable to compute layout of opaque struct type %v", t))
+	}
+	sl := &StructLayout{Align: 1}
+	offset := uint64(0)
+	for i, field := range t.Fields {
+		if !t.Packed {
+			align := dl.ABIAlignOf(field)
+			if i > 0 {
+				pad := AlignTo(offset, align) - offset
+				sl.Padding[i-1] = pad
+				offset += pad
+			}
+			if align > sl.Align {
+				sl.Align = align
+			}
+		}
+		sl.Offsets = append(sl.Offsets, offset)
+		sl.Padding = append(sl.Padding, 0)
+		offset += dl.AllocSizeOf(field)
+	}
+	sl.Size = AlignTo(offset, sl.Align)
+	if n := len(sl.Padding); n > 0 {
+		sl.Padding[n-1] = sl.Size - offset
+	}
+	if dl.structs == nil {
+		dl.structs = make(map[*types.StructType]*StructLayout)
+	}
+	dl.structs[t] = sl
+	return sl
+}
+
+// alignOf returns the ABI or preferred alignment in bytes of the given type.
+func (dl *DataLayout) alignOf(t types.Type, abi bool) uint64 {
+	pick := func(a AlignSpec) uint64 {
+		if abi {
+			return a.ABIAlign
+		}
+		return a.PrefAlign
+	}
+	switch t := t.(type) {
+	case *types.IntType:
+		return pick(lookupIntAlign(dl.Ints, t.BitSize))
+	case *types.FloatType:
+		if a, ok := findAlign(dl.Floats, floatBitSize(t)); ok {
+			return pick(a)
+		}
+		return nextPow2(dl.SizeOf(t))
+	case *types.MMXType:
+		if a, ok := findAlign(dl.Vectors, 64); ok {
+			return pick(a)
+		}
+		return 8
+	case *types.PointerType:
+		spec := dl.PointerSpec(t.AddrSpace)
+		if abi {
+			return spec.ABIAlign
+		}
+		return spec.PrefAlign
+	case *types.LabelType:
+		return dl.alignOf(types.NewPointer(types.I8), abi)
+	case *types.VectorType:
+		if a, ok := findAlign(dl.Vectors, dl.BitSizeOf(t)); ok {
+			return pick(a)
+		}
+		// Natural alignment of vector types without an alignment specification.
+		return nextPow2(dl.SizeOf(t))
+	case *types.ArrayType:
+		return dl.alignOf(t.ElemType, abi)
+	case *types.StructType:
+		align := dl.StructLayout(t).Align
+		if t.Packed && abi {
+			return 1
+		}
+		agg := dl.AggABIAlign
+		if !abi {
+			agg = dl.AggPrefAlign
+		}
+		if agg > align {
+			return agg
+		}
+		return align
+	}
+	panic(fmt.Errorf("unable to compute alignment of unsized type %v", t))
+}
+
+// AlignTo rounds x up to the nearest multiple of align.
+func AlignTo(x, align uint64) uint64 {
+	if align <= 1 {
+		return x
+	}
+	return (x + align - 1) / align * align
+}
+
+// ### [ Helper functions ] ####################################################
+
+// floatBitSize returns the bit size of the given floating-point type.
+func floatBitSize(t *types.FloatType) uint64 {
+	switch t.Kind {
+	case types.FloatKindHalf:
+		return 16
+	case types.FloatKindFloat:
+		return 32
+	case types.FloatKindDouble:
+		return 64
+	case types.FloatKindX86_FP80:
+		return 80
+	case types.FloatKindFP128, types.FloatKindPPC_FP128:
+		return 128
+	}
+	panic(fmt.Errorf("support for floating-point kind %v not yet implemented", t.Kind))
+}
+
+// lookupIntAlign returns the integer alignment specification of the given bit
+// size. Bit sizes without an alignment specification use the specification of
+// the next larger bit size; or of the largest bit size if none is larger.
+func lookupIntAlign(specs []AlignSpec, bitSize uint64) AlignSpec {
+	for _, spec := range specs {
+		if spec.BitSize >= bitSize {
+			return spec
+		}
+	}
+	if len(specs) == 0 {
+		return AlignSpec{BitSize: bitSize, ABIAlign: 1, PrefAlign: 1}
+	}
+	return specs[len(specs)-1]
+}
+
+// nextPow2 returns the smallest power of two greater than or equal to x.
+func nextPow2(x uint64) uint64 {
+	p := uint64(1)
+	for p < x {
+		p <<= 1
+	}
+	return p
+}
@@ -0,0 +1,494 @@
+// Package dom implements dominator trees, post-dominator trees and dominance
+// frontiers of LLVM IR functions.
+//
+// The control flow graph of a function is derived from the successor basic
+// blocks of each terminator (see ir.Terminator.Succs). Immediate dominators are
+// computed using the algorithm of Lengauer and Tarjan.
+//
+// Basic blocks not reachable from the entry basic block are not part of the
+// dominator tree; they neither dominate nor are dominated by any basic block.
+// Post-dominator trees are rooted at a virtual exit node joining all exit
+// basic blocks (i.e. basic blocks whose terminator has no successors, such as
+// ret, resume and unreachable); basic blocks from which no exit basic block is
+// reachable (e.g. infinite loops) are not part of the post-dominator tree.
+package dom
+
+import (
+	"fmt"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/value"
+)
+
+// Tree is a dominator tree or post-dominator tree of a function.
+type Tree struct {
+	// Function of the tree.
+	f *ir.Func
+	// Post-dominator tree.
+	post bool
+	// Basic block of each node; the virtual exit node of post-dominator trees
+	// has a nil basic block.
+	blocks []*ir.Block
+	// Node of each basic block.
+	nodes map[*ir.Block]int
+	// Predecessor nodes of each node, in the direction of the tree (i.e.
+	// successor basic blocks in the control flow graph for post-dominator
+	// trees).
+	preds [][]int
+	// Immediate dominator of each node; or -1 if root or unreachable.
+	idom []int
+	// Children of each node in the tree.
+	children [][]int
+	// Pre-order and post-order number of each node in a depth-first traversal
+	// of the tree; or -1 if unreachable.
+	in, out []int
+	// Position of each instruction and terminator of the function.
+	pos map[value.User]pos
+	// Dominance frontier of each node; computed on demand.
+	frontier [][]int
+}
+
+// pos is the position of an instruction or terminator within a function.
+type pos struct {
+	// Basic block containing the instruction or terminator.
+	block *ir.Block
+	// Index of the instruction within the basic block; the index of the
+	// terminator is the number of instructions in the basic block.
+	index int
+}
+
+// New returns the dominator tree of the given function, which must have at
+// least one basic block.
+func New(f *ir.Func) *Tree {
+	if len(f.Blocks) == 0 {
+		panic(fmt.Errorf("unable to compute dominator tree of function %s without basic blocks", f.Ident()))
+	}
+	t := newTree(f, false)
+	n := len(f.Blocks)
+	t.blocks = make([]*ir.Block, n)
+	copy(t.blocks, f.Blocks)
+	for i, block := range t.blocks {
+		t.nodes[block] = i
+	}
+	succs := make([][]int, n)
+	t.preds = make([][]int, n)
+	for i, block := range t.blocks {
+		for _, succ := range blockSuccs(block) {
+			j, ok := t.nodes[succ]
+			if !ok {
+				continue
+			}
+			succs[i] = append(succs[i], j)
+			t.preds[j] = append(t.preds[j], i)
+		}
+	}
+	t.init(succs)
+	return t
+}
+
+// NewPost returns the post-dominator tree of the given function, which must
+// have at least one basic block.
+func NewPost(f *ir.Func) *Tree {
+	if len(f.Blocks) == 0 {
+		panic(fmt.Errorf("unable to compute post-dominator tree of function %s without basic blocks", f.Ident()))
+	}
+	t := newTree(f, true)
+	// Node 0 is the virtual exit node.
+	n := len(f.Blocks) + 1
+	t.blocks = make([]*ir.Block, n)
+	copy(t.blocks[1:], f.Blocks)
+	for i, block := range f.Blocks {
+		t.nodes[block] = i + 1
+	}
+	succs := make([][]int, n)
+	t.preds = make([][]int, n)
+	for i, block := range f.Blocks {
+		from := i + 1
+		bsuccs := blockSuccs(block)
+		if len(bsuccs) == 0 {
+			succs[0] = append(succs[0], from)
+			t.preds[from] = append(t.preds[from], 0)
+			continue
+		}
+		for _, succ := range bsuccs {
+			to, ok := t.nodes[succ]
+			if !ok {
+				continue
+			}
+			// Reverse control flow edge.
+			succs[to] = append(succs[to], from)
+			t.preds[from] = append(t.preds[from], to)
+		}
+	}
+	t.init(succs)
+	return t
+}
+
+// newTree returns a new empty tree of the given function.
+func newTree(f *ir.Func, post bool) *Tree {
+	t := &Tree{
+		f:     f,
+		post:  post,
+		nodes: make(map[*ir.Block]int),
+	}
+	t.UpdatePositions()
+	return t
+}
+
+// UpdatePositions recomputes the positions of the instructions and terminators
+// of the function within their basic blocks. UpdatePositions must be called
+// before querying the dominance of instructions, after instructions have been
+// inserted, removed or reordered without changing the control flow graph.
+func (t *Tree) UpdatePositions() {
+	t.pos = make(map[value.User]pos)
+	for _, block := range t.f.Blocks {
+		for i, inst := range block.Insts {
+			t.pos[inst] = pos{block: block, index: i}
+		}
+		if block.Term != nil {
+			t.pos[block.Term] = pos{block: block, index: len(block.Insts)}
+		}
+	}
+}
+
+// init computes the immediate dominators, children and depth-first numbering
+// of the tree, based on the given successor nodes of each node.
+func (t *Tree) init(succs [][]int) {
+	n := len(t.blocks)
+	t.idom = lengauerTarjan(succs, t.preds)
+	t.children = make([][]int, n)
+	for i := 0; i < n; i++ {
+		if d := t.idom[i]; d != -1 {
+			t.children[d] = append(t.children[d], i)
+		}
+	}
+	t.in = make([]int, n)
+	t.out = make([]int, n)
+	for i := range t.in {
+		t.in[i] = -1
+		t.out[i] = -1
+	}
+	// Depth-first traversal of the tree from the root.
+	type item struct {
+		node int
+		next int // index of next child to visit.
+	}
+	counter := 0
+	t.in[0] = counter
+	counter++
+	stack := []item{{node: 0}}
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if top.next < len(t.children[top.node]) {
+			child := t.children[top.node][top.next]
+			top.next++
+			t.in[child] = counter
+			counter++
+			stack = append(stack, item{node: child})
+			continue
+		}
+		t.out[top.node] = counter
+		counter++
+		stack = stack[:len(stack)-1]
+	}
+}
+
+// Func returns the function of the tree.
+func (t *Tree) Func() *ir.Func {
+	return t.f
+}
+
+// IsPost reports whether t is a post-dominator tree.
+func (t *Tree) IsPost() bool {
+	return t.post
+}
+
+// Roots returns the root basic blocks of the tree. The root of a dominator
+// tree is the entry basic block; the roots of a post-dominator tree are the
+// basic blocks immediately post-dominated by the virtual exit node, which
+// include the exit basic blocks of the function.
+func (t *Tree) Roots() []*ir.Block {
+	if !t.post {
+		return []*ir.Block{t.blocks[0]}
+	}
+	return t.blockList(t.children[0])
+}
+
+// Reachable reports whether the given basic block is part of the tree. For
+// dominator trees, this is the case if the basic block is reachable from the
+// entry basic block. For post-dominator trees, this is the case if an exit
+// basic block is reachable from the basic block.
+func (t *Tree) Reachable(b *ir.Block) bool {
+	i, ok := t.nodes[b]
+	return ok && t.in[i] != -1
+}
+
+// IDom returns the immediate dominator (or immediate post-dominator) of the
+// given basic block; or nil if b is a root or not part of the tree.
+func (t *Tree) IDom(b *ir.Block) *ir.Block {
+	i, ok := t.nodes[b]
+	if !ok {
+		return nil
+	}
+	d := t.idom[i]
+	if d == -1 {
+		return nil
+	}
+	// The immediate post-dominator of the roots of a post-dominator tree is the
+	// virtual exit node, which has a nil basic block.
+	return t.blocks[d]
+}
+
+// Children returns the basic blocks immediately dominated (or immediately
+// post-dominated) by the given basic block, in the order of the basic blocks
+// in the function.
+func (t *Tree) Children(b *ir.Block) []*ir.Block {
+	i, ok := t.nodes[b]
+	if !ok {
+		return nil
+	}
+	return t.blockList(t.children[i])
+}
+
+// Dominates reports whether basic block a dominates (or post-dominates) basic
+// block b. Every basic block part of the tree dominates itself. Basic blocks
+// not part of the tree neither dominate nor are dominated by any basic block.
+func (t *Tree) Dominates(a, b *ir.Block) bool {
+	i, ok := t.nodes[a]
+	if !ok {
+		return false
+	}
+	j, ok := t.nodes[b]
+	if !ok {
+		return false
+	}
+	return t.dominates(i, j)
+}
+
+// StrictlyDominates reports whether basic block a dominates (or
+// post-dominates) basic block b, and a and b are distinct.
+func (t *Tree) StrictlyDominates(a, b *ir.Block) bool {
+	return a != b && t.Dominates(a, b)
+}
+
+// dominates reports whether node i dominates node j.
+func (t *Tree) dominates(i, j int) bool {
+	if t.in[i] == -1 || t.in[j] == -1 {
+		return false
+	}
+	return t.in[i] <= t.in[j] && t.out[j] <= t.out[i]
+}
+
+// DominatesInst reports whether the instruction or terminator a strictly
+// dominates (or strictly post-dominates) the instruction or terminator b. Both
+// a and b must be part of the function of the tree.
+//
+// Within a basic block, an instruction dominates the instructions after it and
+// post-dominates the instructions before it.
+func (t *Tree) DominatesInst(a, b value.User) bool {
+	pa, ok := t.pos[a]
+	if !ok {
+		panic(fmt.Errorf("unable to locate instruction %v in function %s", a, t.f.Ident()))
+	}
+	pb, ok := t.pos[b]
+	if !ok {
+		panic(fmt.Errorf("unable to locate instruction %v in function %s", b, t.f.Ident()))
+	}
+	if pa.block == pb.block {
+		if !t.Reachable(pa.block) {
+			return false
+		}
+		if t.post {
+			return pa.index > pb.index
+		}
+		return pa.index < pb.index
+	}
+	return t.Dominates(pa.block, pb.block)
+}
+
+// DominatesUse reports whether the definition of the value def dominates its
+// use by the instruction or terminator user, which must be part of the
+// function of the tree. Values not defined by an instruction or terminator
+// (e.g. constants, globals and parameters) dominate every use in reachable
+// basic blocks.
+//
+// A use by a phi instruction takes place at the end of the corresponding
+// incoming basic block; if def is used as several incoming values, its
+// definition must dominate the end of each such incoming basic block.
+//
+// DominatesUse panics if t is a post-dominator tree.
+func (t *Tree) DominatesUse(def value.Value, user value.User) bool {
+	if t.post {
+		panic(fmt.Errorf("invalid use of DominatesUse on post-dominator tree of function %s", t.f.Ident()))
+	}
+	pu, ok := t.pos[user]
+	if !ok {
+		panic(fmt.Errorf("unable to locate instruction %v in function %s", user, t.f.Ident()))
+	}
+	if phi, ok := user.(*ir.InstPhi); ok {
+		found := false
+		for _, inc := range phi.Incs {
+			if inc.X != def {
+				continue
+			}
+			found = true
+			pred, ok := inc.Pred.(*ir.Block)
+			if !ok || !t.dominatesEnd(def, pred) {
+				return false
+			}
+		}
+		if found {
+			return true
+		}
+	}
+	d, ok := def.(value.User)
+	if !ok {
+		return t.Reachable(pu.block)
+	}
+	if _, ok := t.pos[d]; !ok {
+		return t.Reachable(pu.block)
+	}
+	return t.DominatesInst(d, user)
+}
+
+// dominatesEnd reports whether the definition of the value def dominates the
+// end of the given basic block.
+func (t *Tree) dominatesEnd(def value.Value, b *ir.Block) bool {
+	if !t.Reachable(b) {
+		return false
+	}
+	d, ok := def.(value.User)
+	if !ok {
+		return true
+	}
+	pd, ok := t.pos[d]
+	if !ok {
+		return true
+	}
+	if pd.block == b {
+		// Values defined by terminators (e.g. invoke) are not available at
+		// the end of their own basic block.
+		return pd.index < len(b.Insts)
+	}
+	return t.Dominates(pd.block, b)
+}
+
+// ~~~ [ Dominance frontiers ] ~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~~
+
+// Frontier returns the dominance frontier (or post-dominance frontier) of the
+// given basic block, in the order of the basic blocks in the function. The
+// dominance frontier of a basic block b is the set of basic blocks d such that
+// b dominates a predecessor of d but does not strictly dominate d.
+func (t *Tree) Frontier(b *ir.Block) []*ir.Block {
+	i, ok := t.nodes[b]
+	if !ok {
+		return nil
+	}
+	t.computeFrontiers()
+	return t.blockList(t.frontier[i])
+}
+
+// IteratedFrontier returns the iterated dominance frontier (or iterated
+// post-dominance frontier) of the given set of basic blocks, in the order of
+// the basic blocks in the function. The iterated dominance frontier of the
+// definition blocks of a variable is the set of basic blocks requiring phi
+// instructions for the variable.
+func (t *Tree) IteratedFrontier(blocks []*ir.Block) []*ir.Block {
+	t.computeFrontiers()
+	in := make([]bool, len(t.blocks))
+	queued := make([]bool, len(t.blocks))
+	var work []int
+	for _, b := range blocks {
+		if i, ok := t.nodes[b]; ok && !queued[i] {
+			queued[i] = true
+			work = append(work, i)
+		}
+	}
+	for len(work) > 0 {
+		i := work[len(work)-1]
+		work = work[:len(work)-1]
+		for _, j := range t.frontier[i] {
+			if in[j] {
+				continue
+			}
+			in[j] = true
+			if !queued[j] {
+				queued[j] = true
+				work = append(work, j)
+			}
+		}
+	}
+	var nodes []int
+	for i, ok := range in {
+		if ok {
+			nodes = append(nodes, i)
+		}
+	}
+	return t.blockList(nodes)
+}
+
+// computeFrontiers computes the dominance frontier of each node of the tree,
+// based on the algorithm of Cooper, Harvey and Kennedy.
+func (t *Tree) computeFrontiers() {
+	if t.frontier != nil {
+		return
+	}
+	n := len(t.blocks)
+	t.frontier = make([][]int, n)
+	seen := make([]map[int]bool, n)
+	for j := 0; j < n; j++ {
+		if t.in[j] == -1 || len(t.preds[j]) < 2 {
+			continue
+		}
+		for _, p := range t.preds[j] {
+			if t.in[p] == -1 {
+				continue
+			}
+			for runner := p; runner != -1 && runner != t.idom[j]; runner = t.idom[runner] {
+				if seen[runner] == nil {
+					seen[runner] = make(map[int]bool)
+				}
+				if seen[runner][j] {
+					continue
+				}
+				seen[runner][j] = true
+				t.frontier[runner] = append(t.frontier[runner], j)
+			}
+		}
+	}
+	for _, f := range t.frontier {
+		sortNodes(f)
+	}
+}
+
+// ### [ Helper functions ] ####################################################
+
+// blockList returns the basic blocks of the given nodes, omitting the virtual
+// exit node.
+func (t *Tree) blockList(nodes []int) []*ir.Block {
+	var blocks []*ir.Block
+	for _, i := range nodes {
+		if b := t.blocks[i]; b != nil {
+			blocks = append(blocks, b)
+		}
+	}
+	return blocks
+}
+
+// blockSuccs returns the successor basic blocks of the given basic block.
+func blockSuccs(block *ir.Block) []*ir.Block {
+	if block.Term == nil {
+		return nil
+	}
+	return block.Term.Succs()
+}
+
+// sortNodes sorts the given nodes in increasing order.
+func sortNodes(nodes []int) {
+	// Insertion sort; dominance frontiers are typically small.
+	for i := 1; i < len(nodes); i++ {
+		for j := i; j > 0 && nodes[j] < nodes[j-1]; j-- {
+			nodes[j], nodes[j-1] = nodes[j-1], nodes[j]
+		}
+	}
+}
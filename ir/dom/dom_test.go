@@ -0,0 +1,283 @@
+package dom_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/dom"
+)
+
+const src = `
+define i32 @f(i1 %c, i1 %d) {
+entry:
+	br i1 %c, label %a, label %b
+
+a:
+	%x = add i32 1, 2
+	br label %loop
+
+b:
+	br i1 %d, label %loop, label %fail
+
+loop:
+	%i = phi i32 [ 0, %a ], [ 0, %b ], [ %j, %latch ]
+	%j = add i32 %i, 1
+	br label %latch
+
+latch:
+	%cmp = icmp ult i32 %j, 10
+	br i1 %cmp, label %loop, label %exit
+
+exit:
+	ret i32 %j
+
+fail:
+	unreachable
+
+dead:
+	%y = add i32 %j, 1
+	br label %exit
+}
+`
+
+// blocks returns the names of the given basic blocks.
+func blocks(bs []*ir.Block) string {
+	var names []string
+	for _, b := range bs {
+		if b == nil {
+			names = append(names, "<exit>")
+			continue
+		}
+		names = append(names, b.LocalName)
+	}
+	return strings.Join(names, ",")
+}
+
+func parseFunc(t *testing.T) (*ir.Func, map[string]*ir.Block) {
+	m, err := asm.ParseString("dom.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	f := m.Funcs[0]
+	bs := make(map[string]*ir.Block)
+	for _, b := range f.Blocks {
+		bs[b.LocalName] = b
+	}
+	return f, bs
+}
+
+func TestTree(t *testing.T) {
+	f, bs := parseFunc(t)
+	tree := dom.New(f)
+	golden := []struct {
+		block    string
+		idom     string
+		children string
+		frontier string
+	}{
+		{block: "entry", idom: "", children: "a,b,loop", frontier: ""},
+		{block: "a", idom: "entry", children: "", frontier: "loop"},
+		{block: "b", idom: "entry", children: "fail", frontier: "loop"},
+		{block: "loop", idom: "entry", children: "latch", frontier: "loop"},
+		{block: "latch", idom: "loop", children: "exit", frontier: "loop"},
+		{block: "exit", idom: "latch", children: "", frontier: ""},
+		{block: "fail", idom: "b", children: "", frontier: ""},
+		{block: "dead", idom: "", children: "", frontier: ""},
+	}
+	for _, g := range golden {
+		b := bs[g.block]
+		var idom string
+		if d := tree.IDom(b); d != nil {
+			idom = d.LocalName
+		}
+		if idom != g.idom {
+			t.Errorf("%s: idom mismatch; expected %q, got %q", g.block, g.idom, idom)
+		}
+		if got := blocks(tree.Children(b)); got != g.children {
+			t.Errorf("%s: children mismatch; expected %q, got %q", g.block, g.children, got)
+		}
+		if got := blocks(tree.Frontier(b)); got != g.frontier {
+			t.Errorf("%s: frontier mismatch; expected %q, got %q", g.block, g.frontier, got)
+		}
+	}
+	if tree.Reachable(bs["dead"]) {
+		t.Errorf("dead: expected unreachable basic block")
+	}
+	if tree.Dominates(bs["dead"], bs["dead"]) || tree.Dominates(bs["entry"], bs["dead"]) {
+		t.Errorf("dead: unreachable basic block part of dominance relation")
+	}
+	if !tree.Dominates(bs["loop"], bs["exit"]) || tree.Dominates(bs["a"], bs["loop"]) {
+		t.Errorf("loop: dominance mismatch")
+	}
+	if !tree.Dominates(bs["exit"], bs["exit"]) || tree.StrictlyDominates(bs["exit"], bs["exit"]) {
+		t.Errorf("exit: reflexive dominance mismatch")
+	}
+	if got, want := blocks(tree.IteratedFrontier([]*ir.Block{bs["a"], bs["latch"]})), "loop"; got != want {
+		t.Errorf("iterated frontier mismatch; expected %q, got %q", want, got)
+	}
+}
+
+func TestPostTree(t *testing.T) {
+	f, bs := parseFunc(t)
+	tree := dom.NewPost(f)
+	golden := []struct {
+		block    string
+		idom     string
+		frontier string
+	}{
+		{block: "entry", idom: "<exit>", frontier: ""},
+		{block: "a", idom: "loop", frontier: "entry"},
+		{block: "b", idom: "<exit>", frontier: "entry"},
+		{block: "loop", idom: "latch", frontier: "entry,b,latch"},
+		{block: "latch", idom: "exit", frontier: "entry,b,latch"},
+		{block: "exit", idom: "<exit>", frontier: "entry,b"},
+		{block: "fail", idom: "<exit>", frontier: "b"},
+		{block: "dead", idom: "exit", frontier: ""},
+	}
+	for _, g := range golden {
+		b := bs[g.block]
+		if got := blocks([]*ir.Block{tree.IDom(b)}); got != g.idom {
+			t.Errorf("%s: ipdom mismatch; expected %q, got %q", g.block, g.idom, got)
+		}
+		if got := blocks(tree.Frontier(b)); got != g.frontier {
+			t.Errorf("%s: frontier mismatch; expected %q, got %q", g.block, g.frontier, got)
+		}
+	}
+	if got, want := blocks(tree.Roots()), "entry,b,exit,fail"; got != want {
+		t.Errorf("roots mismatch; expected %q, got %q", want, got)
+	}
+	if !tree.Dominates(bs["latch"], bs["a"]) || tree.Dominates(bs["exit"], bs["b"]) {
+		t.Errorf("post-dominance mismatch")
+	}
+}
+
+func TestDominatesInst(t *testing.T) {
+	f, bs := parseFunc(t)
+	tree := dom.New(f)
+	x := bs["a"].Insts[0]
+	phi := bs["loop"].Insts[0].(*ir.InstPhi)
+	j := bs["loop"].Insts[1]
+	cmp := bs["latch"].Insts[0]
+	ret := bs["exit"].Term
+	y := bs["dead"].Insts[0]
+	golden := []struct {
+		name      string
+		a, b      ir.Instruction
+		term      ir.Terminator
+		dominates bool
+	}{
+		{name: "same block", a: phi, b: j, dominates: true},
+		{name: "same block reverse", a: j, b: phi, dominates: false},
+		{name: "self", a: j, b: j, dominates: false},
+		{name: "dominating block", a: j, b: cmp, dominates: true},
+		{name: "non-dominating block", a: x, b: cmp, dominates: false},
+		{name: "terminator", a: j, term: ret, dominates: true},
+		{name: "unreachable", a: j, b: y, dominates: false},
+	}
+	for _, g := range golden {
+		var got bool
+		if g.term != nil {
+			got = tree.DominatesInst(g.a, g.term)
+		} else {
+			got = tree.DominatesInst(g.a, g.b)
+		}
+		if got != g.dominates {
+			t.Errorf("%s: dominance mismatch; expected %v, got %v", g.name, g.dominates, got)
+		}
+	}
+	// Use by phi instruction at the end of the incoming basic block.
+	if !tree.DominatesUse(j.(*ir.InstAdd), phi) {
+		t.Errorf("expected definition of %%j to dominate its use by phi instruction")
+	}
+	if !tree.DominatesUse(f.Params[0], j) {
+		t.Errorf("expected parameter to dominate use")
+	}
+	if tree.DominatesUse(x.(*ir.InstAdd), cmp) {
+		t.Errorf("expected definition of %%x not to dominate use in %%latch")
+	}
+}
+
+// TestTreeNaive compares the dominator trees of the functions of the test
+// cases against dominator trees computed using a naive fixed point iteration.
+func TestTreeNaive(t *testing.T) {
+	paths, err := filepath.Glob("../../asm/testdata/*.ll")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range paths {
+		m, err := asm.ParseFile(path)
+		if err != nil {
+			// Skip test cases not supported by the parser.
+			continue
+		}
+		for _, f := range m.Funcs {
+			if len(f.Blocks) == 0 {
+				continue
+			}
+			tree := dom.New(f)
+			want := naiveDominators(f)
+			for _, a := range f.Blocks {
+				for _, b := range f.Blocks {
+					if got := tree.Dominates(a, b); got != want[b][a] {
+						t.Errorf("%s: function %s: dominance of %s over %s mismatch; expected %v, got %v", path, f.Ident(), a.Ident(), b.Ident(), want[b][a], got)
+					}
+				}
+			}
+		}
+	}
+}
+
+// naiveDominators returns the set of dominators of each reachable basic block
+// of the given function.
+func naiveDominators(f *ir.Func) map[*ir.Block]map[*ir.Block]bool {
+	preds := make(map[*ir.Block][]*ir.Block)
+	reachable := map[*ir.Block]bool{f.Blocks[0]: true}
+	work := []*ir.Block{f.Blocks[0]}
+	for len(work) > 0 {
+		b := work[0]
+		work = work[1:]
+		for _, succ := range b.Term.Succs() {
+			preds[succ] = append(preds[succ], b)
+			if !reachable[succ] {
+				reachable[succ] = true
+				work = append(work, succ)
+			}
+		}
+	}
+	doms := make(map[*ir.Block]map[*ir.Block]bool)
+	for _, b := range f.Blocks {
+		if !reachable[b] {
+			continue
+		}
+		doms[b] = make(map[*ir.Block]bool)
+		for _, d := range f.Blocks {
+			if reachable[d] && (b != f.Blocks[0] || d == b) {
+				doms[b][d] = true
+			}
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, b := range f.Blocks[1:] {
+			if !reachable[b] {
+				continue
+			}
+			for d := range doms[b] {
+				if d == b {
+					continue
+				}
+				for _, p := range preds[b] {
+					if !doms[p][d] {
+						delete(doms[b], d)
+						changed = true
+						break
+					}
+				}
+			}
+		}
+	}
+	return doms
+}
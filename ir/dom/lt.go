@@ -0,0 +1,110 @@
+package dom
+
+// lengauerTarjan returns the immediate dominator of each node of a graph rooted
+// at node 0, based on the algorithm of Lengauer and Tarjan (using simple path
+// compression). The immediate dominator of the root and of nodes not reachable
+// from the root is -1.
+//
+// References:
+//
+//   - Thomas Lengauer and Robert Endre Tarjan. 1979. A fast algorithm for
+//     finding dominators in a flowgraph. ACM TOPLAS 1, 1, 121-141.
+func lengauerTarjan(succs, preds [][]int) []int {
+	n := len(succs)
+	// Depth-first pre-order number of each node; or -1 if unreachable.
+	dfnum := make([]int, n)
+	// Node of each depth-first pre-order number.
+	var vertex []int
+	// Parent of each node in the depth-first spanning tree.
+	parent := make([]int, n)
+	// Pre-order number of the semidominator of each node.
+	semi := make([]int, n)
+	// Ancestor of each node in the forest built by link.
+	ancestor := make([]int, n)
+	// Node with minimal semidominator on the path from each node to its
+	// ancestor.
+	label := make([]int, n)
+	// Nodes whose semidominator is the given node.
+	bucket := make([][]int, n)
+	idom := make([]int, n)
+	for i := 0; i < n; i++ {
+		dfnum[i] = -1
+		parent[i] = -1
+		ancestor[i] = -1
+		label[i] = i
+		idom[i] = -1
+	}
+	// Depth-first traversal from the root.
+	type item struct {
+		node, parent int
+	}
+	stack := []item{{node: 0, parent: -1}}
+	for len(stack) > 0 {
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if dfnum[top.node] != -1 {
+			continue
+		}
+		dfnum[top.node] = len(vertex)
+		semi[top.node] = len(vertex)
+		parent[top.node] = top.parent
+		vertex = append(vertex, top.node)
+		// Push successors in reverse order to visit them in order.
+		for i := len(succs[top.node]) - 1; i >= 0; i-- {
+			if succ := succs[top.node][i]; dfnum[succ] == -1 {
+				stack = append(stack, item{node: succ, parent: top.node})
+			}
+		}
+	}
+	var compress func(v int)
+	compress = func(v int) {
+		a := ancestor[v]
+		if ancestor[a] == -1 {
+			return
+		}
+		compress(a)
+		if semi[label[a]] < semi[label[v]] {
+			label[v] = label[a]
+		}
+		ancestor[v] = ancestor[a]
+	}
+	eval := func(v int) int {
+		if ancestor[v] == -1 {
+			return v
+		}
+		compress(v)
+		return label[v]
+	}
+	for i := len(vertex) - 1; i > 0; i-- {
+		w := vertex[i]
+		// Compute semidominator of w.
+		for _, v := range preds[w] {
+			if dfnum[v] == -1 {
+				continue
+			}
+			if u := eval(v); semi[u] < semi[w] {
+				semi[w] = semi[u]
+			}
+		}
+		bucket[vertex[semi[w]]] = append(bucket[vertex[semi[w]]], w)
+		p := parent[w]
+		ancestor[w] = p
+		// Implicitly compute immediate dominators of nodes in the bucket of the
+		// parent of w.
+		for _, v := range bucket[p] {
+			if u := eval(v); semi[u] < semi[v] {
+				idom[v] = u
+			} else {
+				idom[v] = p
+			}
+		}
+		bucket[p] = nil
+	}
+	// Explicitly compute immediate dominators in pre-order.
+	for _, w := range vertex[1:] {
+		if idom[w] != vertex[semi[w]] {
+			idom[w] = idom[idom[w]]
+		}
+	}
+	return idom
+}
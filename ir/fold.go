@@ -0,0 +1,245 @@
+package ir
+
+import (
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/value"
+)
+
+// FoldInst returns the constant value computed by the given instruction, if all
+// of its operands are constants and the instruction can be evaluated at compile
+// time; or nil otherwise.
+//
+// Instructions with a corresponding constant expression (e.g. add, icmp,
+// extractelement) are folded as by constant.Fold, and instructions without a
+// corresponding constant expression (e.g. udiv, fadd, extractvalue) are folded
+// using the exported folding functions of the constant package. Phi
+// instructions with identical constant incoming values fold to that value, and
+// freeze instructions fold to their operand if it is neither undef nor poison.
+//
+// Instructions which only fold to constant expressions (e.g. getelementptr of
+// a global variable) are not folded.
+func FoldInst(inst Instruction) constant.Constant {
+	c := foldInst(inst)
+	if _, ok := c.(constant.Expression); ok {
+		return nil
+	}
+	return c
+}
+
+// foldInst returns the constant value computed by the given instruction, or nil
+// if the instruction cannot be folded.
+func foldInst(inst Instruction) constant.Constant {
+	switch inst := inst.(type) {
+	// Unary instructions.
+	case *InstFNeg:
+		if x, ok := operands(inst.X); ok {
+			return constant.Fold(constant.NewFNeg(x[0]))
+		}
+	// Binary instructions.
+	case *InstAdd:
+		if ops, ok := operands(inst.X, inst.Y); ok {
+			e := constant.NewAdd(ops[0], ops[1])
+			e.OverflowFlags = inst.OverflowFlags
+			return constant.Fold(e)
+		}
+	case *InstFAdd:
+		if ops, ok := operands(inst.X, inst.Y); ok {
+			return constant.FoldFAdd(ops[0], ops[1])
+		}
+	case *InstSub:
+		if ops, ok := operands(inst.X, inst.Y); ok {
+			e := constant.NewSub(ops[0], ops[1])
+			e.OverflowFlags = inst.OverflowFlags
+			return constant.Fold(e)
+		}
+	case *InstFSub:
+		if ops, ok := operands(inst.X, inst.Y); ok {
+			return constant.FoldFSub(ops[0], ops[1])
+		}
+	case *InstMul:
+		if ops, ok := operands(inst.X, inst.Y); ok {
+			e := constant.NewMul(ops[0], ops[1])
+			e.OverflowFlags = inst.OverflowFlags
+			return constant.Fold(e)
+		}
+	case *InstFMul:
+		if ops, ok := operands(inst.X, inst.Y); ok {
+			return constant.FoldFMul(ops[0], ops[1])
+		}
+	case *InstUDiv:
+		if ops, ok := operands(inst.X, inst.Y); ok {
+			return constant.FoldUDiv(ops[0], ops[1], inst.Exact)
+		}
+	case *InstSDiv:
+		if ops, ok := operands(inst.X, inst.Y); ok {
+			return constant.FoldSDiv(ops[0], ops[1], inst.Exact)
+		}
+	case *InstFDiv:
+		if ops, ok := operands(inst.X, inst.Y); ok {
+			return constant.FoldFDiv(ops[0], ops[1])
+		}
+	case *InstURem:
+		if ops, ok := operands(inst.X, inst.Y); ok {
+			return constant.FoldURem(ops[0], ops[1])
+		}
+	case *InstSRem:
+		if ops, ok := operands(inst.X, inst.Y); ok {
+			return constant.FoldSRem(ops[0], ops[1])
+		}
+	case *InstFRem:
+		if ops, ok := operands(inst.X, inst.Y); ok {
+			return constant.FoldFRem(ops[0], ops[1])
+		}
+	// Bitwise instructions.
+	case *InstShl:
+		if ops, ok := operands(inst.X, inst.Y); ok {
+			e := constant.NewShl(ops[0], ops[1])
+			e.OverflowFlags = inst.OverflowFlags
+			return constant.Fold(e)
+		}
+	case *InstLShr:
+		if ops, ok := operands(inst.X, inst.Y); ok {
+			e := constant.NewLShr(ops[0], ops[1])
+			e.Exact = inst.Exact
+			return constant.Fold(e)
+		}
+	case *InstAShr:
+		if ops, ok := operands(inst.X, inst.Y); ok {
+			e := constant.NewAShr(ops[0], ops[1])
+			e.Exact = inst.Exact
+			return constant.Fold(e)
+		}
+	case *InstAnd:
+		if ops, ok := operands(inst.X, inst.Y); ok {
+			return constant.Fold(constant.NewAnd(ops[0], ops[1]))
+		}
+	case *InstOr:
+		if ops, ok := operands(inst.X, inst.Y); ok {
+			return constant.Fold(constant.NewOr(ops[0], ops[1]))
+		}
+	case *InstXor:
+		if ops, ok := operands(inst.X, inst.Y); ok {
+			return constant.Fold(constant.NewXor(ops[0], ops[1]))
+		}
+	// Vector instructions.
+	case *InstExtractElement:
+		if ops, ok := operands(inst.X, inst.Index); ok {
+			return constant.Fold(constant.NewExtractElement(ops[0], ops[1]))
+		}
+	case *InstInsertElement:
+		if ops, ok := operands(inst.X, inst.Elem, inst.Index); ok {
+			return constant.Fold(constant.NewInsertElement(ops[0], ops[1], ops[2]))
+		}
+	case *InstShuffleVector:
+		if ops, ok := operands(inst.X, inst.Y, inst.Mask); ok {
+			return constant.Fold(constant.NewShuffleVector(ops[0], ops[1], ops[2]))
+		}
+	// Aggregate instructions.
+	case *InstExtractValue:
+		if x, ok := operands(inst.X); ok {
+			return constant.FoldExtractValue(x[0], inst.Indices)
+		}
+	case *InstInsertValue:
+		if ops, ok := operands(inst.X, inst.Elem); ok {
+			return constant.FoldInsertValue(ops[0], ops[1], inst.Indices)
+		}
+	// Conversion instructions.
+	case *InstTrunc:
+		if from, ok := operands(inst.From); ok {
+			return constant.Fold(constant.NewTrunc(from[0], inst.To))
+		}
+	case *InstZExt:
+		if from, ok := operands(inst.From); ok {
+			return constant.Fold(constant.NewZExt(from[0], inst.To))
+		}
+	case *InstSExt:
+		if from, ok := operands(inst.From); ok {
+			return constant.Fold(constant.NewSExt(from[0], inst.To))
+		}
+	case *InstFPTrunc:
+		if from, ok := operands(inst.From); ok {
+			return constant.Fold(constant.NewFPTrunc(from[0], inst.To))
+		}
+	case *InstFPExt:
+		if from, ok := operands(inst.From); ok {
+			return constant.Fold(constant.NewFPExt(from[0], inst.To))
+		}
+	case *InstFPToUI:
+		if from, ok := operands(inst.From); ok {
+			return constant.Fold(constant.NewFPToUI(from[0], inst.To))
+		}
+	case *InstFPToSI:
+		if from, ok := operands(inst.From); ok {
+			return constant.Fold(constant.NewFPToSI(from[0], inst.To))
+		}
+	case *InstUIToFP:
+		if from, ok := operands(inst.From); ok {
+			return constant.Fold(constant.NewUIToFP(from[0], inst.To))
+		}
+	case *InstSIToFP:
+		if from, ok := operands(inst.From); ok {
+			return constant.Fold(constant.NewSIToFP(from[0], inst.To))
+		}
+	case *InstPtrToInt:
+		if from, ok := operands(inst.From); ok {
+			return constant.Fold(constant.NewPtrToInt(from[0], inst.To))
+		}
+	case *InstIntToPtr:
+		if from, ok := operands(inst.From); ok {
+			return constant.Fold(constant.NewIntToPtr(from[0], inst.To))
+		}
+	case *InstBitCast:
+		if from, ok := operands(inst.From); ok {
+			return constant.Fold(constant.NewBitCast(from[0], inst.To))
+		}
+	// Other instructions.
+	case *InstICmp:
+		if ops, ok := operands(inst.X, inst.Y); ok {
+			return constant.Fold(constant.NewICmp(inst.Pred, ops[0], ops[1]))
+		}
+	case *InstFCmp:
+		if ops, ok := operands(inst.X, inst.Y); ok {
+			return constant.Fold(constant.NewFCmp(inst.Pred, ops[0], ops[1]))
+		}
+	case *InstSelect:
+		if ops, ok := operands(inst.Cond, inst.ValueTrue, inst.ValueFalse); ok {
+			return constant.Fold(constant.NewSelect(ops[0], ops[1], ops[2]))
+		}
+	case *InstPhi:
+		var c constant.Constant
+		for _, inc := range inst.Incs {
+			x, ok := inc.X.(constant.Constant)
+			if !ok {
+				return nil
+			}
+			x = constant.Fold(x)
+			if c != nil && x.String() != c.String() {
+				return nil
+			}
+			c = x
+		}
+		return c
+	case *InstFreeze:
+		if x, ok := operands(inst.X); ok {
+			switch x := x[0].(type) {
+			case *constant.Int, *constant.Float, *constant.Null, *constant.ZeroInitializer:
+				return x
+			}
+		}
+	}
+	return nil
+}
+
+// operands returns the given operands as constants, and a boolean indicating
+// whether all operands are constants.
+func operands(vals ...value.Value) ([]constant.Constant, bool) {
+	cs := make([]constant.Constant, len(vals))
+	for i, v := range vals {
+		c, ok := v.(constant.Constant)
+		if !ok {
+			return nil, false
+		}
+		cs[i] = c
+	}
+	return cs, true
+}
@@ -0,0 +1,72 @@
+package ir_test
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/value"
+)
+
+func TestFoldInst(t *testing.T) {
+	const src = `
+@g = global i32 0
+
+define void @f(i32 %x, i1 %c) {
+entry:
+	%add = add nsw i32 2, 3
+	%ovf = add nsw i8 127, 1
+	%udiv = udiv i32 7, 2
+	%srem = srem i32 -7, 2
+	%fadd = fadd double 0.5, 0.25
+	%cmp = icmp ult i32 -1, 0
+	%sel = select i1 true, i32 10, i32 20
+	%ext = extractvalue { i32, [2 x i8] } { i32 1, [2 x i8] c"ab" }, 1, 1
+	%ins = insertvalue [2 x i32] zeroinitializer, i32 4, 1
+	%conv = sitofp i32 -3 to double
+	%frz = freeze i32 5
+	%frzu = freeze i32 undef
+	%gep = getelementptr i32, ptr @g, i64 1
+	%var = add i32 %x, 1
+	br i1 %c, label %a, label %b
+
+a:
+	br label %b
+
+b:
+	%phi = phi i32 [ 1, %entry ], [ 1, %a ]
+	%phi2 = phi i32 [ 1, %entry ], [ %x, %a ]
+	ret void
+}
+`
+	m, err := asm.ParseString("fold.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	want := map[string]string{
+		"add":  "i32 5",
+		"ovf":  "i8 poison",
+		"udiv": "i32 3",
+		"srem": "i32 -1",
+		"fadd": "double 0.75",
+		"cmp":  "i1 false",
+		"sel":  "i32 10",
+		"ext":  "i8 98",
+		"ins":  "[2 x i32] [i32 0, i32 4]",
+		"conv": "double -3.0",
+		"frz":  "i32 5",
+		"phi":  "i32 1",
+	}
+	for _, block := range m.Funcs[0].Blocks {
+		for _, inst := range block.Insts {
+			name := inst.(value.Named).Name()
+			var got string
+			if c := ir.FoldInst(inst); c != nil {
+				got = c.String()
+			}
+			if got != want[name] {
+				t.Errorf("%q: folded constant mismatch; expected %q, got %q", name, want[name], got)
+			}
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/llir/llvm/ir/constant"
 	"github.com/llir/llvm/ir/enum"
 	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
 	"github.com/pkg/errors"
 )
 
@@ -76,6 +77,9 @@ type Func struct {
 
 	// mu prevents races on AssignIDs.
 	mu sync.Mutex
+	// uses caches the use lists of values used within the function; computed
+	// on demand by Users.
+	uses map[value.Value][]*Use
 }
 
 // NewFunc returns a new function based on the given function name, return type
@@ -206,6 +210,29 @@ func (f *Func) AssignIDs() error {
 	return nil
 }
 
+// ResetIDs resets the IDs of unnamed local variables, so that they are
+// reassigned by the next invocation of AssignIDs. ResetIDs should be invoked
+// after removing or reordering unnamed local variables of the function.
+func (f *Func) ResetIDs() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	resetID := func(v interface{}) {
+		if n, ok := v.(namedVar); ok && n.IsUnnamed() {
+			n.SetID(0)
+		}
+	}
+	for _, param := range f.Params {
+		resetID(param)
+	}
+	for _, block := range f.Blocks {
+		resetID(block)
+		for _, inst := range block.Insts {
+			resetID(inst)
+		}
+		resetID(block.Term)
+	}
+}
+
 // ### [ Helper functions ] ####################################################
 
 // headerString returns the string representation of the function header.
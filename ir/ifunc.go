@@ -20,6 +20,9 @@ type IFunc struct {
 
 	// Pointer type of resolver.
 	Typ *types.PointerType
+	// (optional) Content type; or nil to use the element type of Typ. Required
+	// if Typ is an opaque pointer type.
+	ContentType types.Type
 	// (optional) Linkage; zero value if not present.
 	Linkage enum.Linkage
 	// (optional) Preemption; zero value if not present.
@@ -89,9 +92,17 @@ func (i *IFunc) LLString() string {
 		fmt.Fprintf(buf, " %s", i.UnnamedAddr)
 	}
 	buf.WriteString(" ifunc")
-	fmt.Fprintf(buf, " %s, %s", i.Typ.ElemType, i.Resolver)
+	fmt.Fprintf(buf, " %s, %s", i.contentType(), i.Resolver)
 	if len(i.Partition) > 0 {
 		fmt.Fprintf(buf, ", partition %s", quote(i.Partition))
 	}
 	return buf.String()
 }
+
+// contentType returns the content type of the IFunc.
+func (i *IFunc) contentType() types.Type {
+	if i.ContentType != nil {
+		return i.ContentType
+	}
+	return i.Typ.ElemType
+}
@@ -218,7 +218,9 @@ func NewStore(src, dst value.Value) *InstStore {
 	if !ok {
 		panic(fmt.Errorf("invalid store dst operand type; expected *types.Pointer, got %T", dst.Type()))
 	}
-	if !src.Type().Equal(dstPtrType.ElemType) {
+	// Note, the type of the source value of stores to opaque pointers is given
+	// by the source value.
+	if dstPtrType.ElemType != nil && !src.Type().Equal(dstPtrType.ElemType) {
 		panic(fmt.Errorf("store operands are not compatible: src=%v; dst=%v", src.Type(), dst.Type()))
 	}
 	return &InstStore{Src: src, Dst: dst}
@@ -437,11 +439,9 @@ func (inst *InstAtomicRMW) String() string {
 func (inst *InstAtomicRMW) Type() types.Type {
 	// Cache type if not present.
 	if inst.Typ == nil {
-		t, ok := inst.Dst.Type().(*types.PointerType)
-		if !ok {
-			panic(fmt.Errorf("invalid destination type; expected *types.PointerType, got %T", inst.Dst.Type()))
-		}
-		inst.Typ = t.ElemType
+		// The result type is given by the type of the operand, as the
+		// destination address may be of opaque pointer type.
+		inst.Typ = inst.X.Type()
 	}
 	return inst.Typ
 }
@@ -401,6 +401,10 @@ type InstCall struct {
 
 	// Type of result produced by the instruction.
 	Typ types.Type
+	// (optional) Function signature of the callee; used instead of the element
+	// type of the callee pointer type if present (e.g. for callees of opaque
+	// pointer type).
+	FuncType *types.FuncType
 	// (optional) Tail; zero if not present.
 	Tail enum.Tail
 	// (optional) Fast math flags.
@@ -514,6 +518,15 @@ func (inst *InstCall) Operands() []*value.Value {
 
 // Sig returns the function signature of the callee.
 func (inst *InstCall) Sig() *types.FuncType {
+	// Use explicit function signature if present.
+	if inst.FuncType != nil {
+		return inst.FuncType
+	}
+	// Use function signature of direct callee, as the type of functions may be
+	// opaque pointer types.
+	if f, ok := inst.Callee.(*Func); ok {
+		return f.Sig
+	}
 	t, ok := inst.Callee.Type().(*types.PointerType)
 	if !ok {
 		panic(fmt.Errorf("invalid callee type; expected *types.PointerType, got %T", inst.Callee.Type()))
@@ -0,0 +1,346 @@
+package link
+
+import (
+	"fmt"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/metadata"
+	"github.com/llir/llvm/ir/types"
+)
+
+// apply applies the resolved actions of the linker, moving the contents of the
+// source module into the destination module.
+func (l *linker) apply() {
+	dst, src := l.dst, l.src
+	// Module-level inline assembly.
+	dst.ModuleAsms = append(dst.ModuleAsms, src.ModuleAsms...)
+	// Type definitions.
+	for _, p := range l.opaques {
+		ds, ss := p.dst.(*types.StructType), p.src.(*types.StructType)
+		ds.Opaque = false
+		ds.Packed = ss.Packed
+		ds.Fields = ss.Fields
+	}
+	dst.TypeDefs = append(dst.TypeDefs, l.typeDefs...)
+	// Comdats.
+	for _, c := range src.ComdatDefs {
+		if res, ok := l.comdats[c]; ok {
+			res.dst.Kind = res.kind
+			continue
+		}
+		dst.ComdatDefs = append(dst.ComdatDefs, c)
+	}
+	for _, g := range l.added {
+		if res, ok := l.comdats[comdat(g)]; ok {
+			setComdat(g, res.dst)
+		}
+	}
+	// Members of replaced comdats of the destination module not replaced by
+	// symbols of the source module.
+	for _, g := range moduleGlobals(dst) {
+		if _, ok := l.repl[g]; !ok && l.replacedComdats[comdat(g)] {
+			makeDecl(g)
+			l.discarded = append(l.discarded, g)
+		}
+	}
+	// Global symbols.
+	for g, name := range l.renames {
+		ident(g).SetName(name)
+	}
+	for g, vis := range l.vis {
+		setVisibility(g, vis)
+	}
+	for _, p := range l.appends {
+		appendInit(p.dst, p.src)
+	}
+	l.moveGlobals()
+	l.replaceUses()
+	// Attribute group definitions.
+	nextID := int64(0)
+	for _, def := range dst.AttrGroupDefs {
+		if def.ID >= nextID {
+			nextID = def.ID + 1
+		}
+	}
+	for _, def := range src.AttrGroupDefs {
+		def.ID = nextID
+		nextID++
+	}
+	dst.AttrGroupDefs = append(dst.AttrGroupDefs, src.AttrGroupDefs...)
+	l.mergeMetadata()
+	// Use lists are invalidated by linking.
+	dst.InvalidateUses()
+	l.removeDiscarded()
+}
+
+// moveGlobals moves the global symbols of the source module into the
+// destination module, replacing selected symbols in place.
+func (l *linker) moveGlobals() {
+	dst := l.dst
+	var globals []global
+	placed := make(map[global]bool)
+	for _, g := range moduleGlobals(dst) {
+		if r, ok := l.repl[g]; ok {
+			globals = append(globals, r)
+			placed[r] = true
+			continue
+		}
+		globals = append(globals, g)
+	}
+	for _, g := range l.added {
+		if !placed[g] {
+			globals = append(globals, g)
+		}
+	}
+	dst.Globals, dst.Funcs, dst.Aliases, dst.IFuncs = nil, nil, nil, nil
+	for _, g := range globals {
+		switch g := g.(type) {
+		case *ir.Global:
+			dst.Globals = append(dst.Globals, g)
+		case *ir.Func:
+			g.Parent = dst
+			dst.Funcs = append(dst.Funcs, g)
+		case *ir.Alias:
+			dst.Aliases = append(dst.Aliases, g)
+		case *ir.IFunc:
+			dst.IFuncs = append(dst.IFuncs, g)
+		}
+	}
+	// Renumber unnamed global symbols, in the same order as
+	// ir.Module.AssignGlobalIDs.
+	id := int64(0)
+	number := func(g global) {
+		if gid := ident(g); gid.IsUnnamed() {
+			gid.SetID(id)
+			id++
+		}
+	}
+	for _, g := range dst.Globals {
+		number(g)
+	}
+	for _, alias := range dst.Aliases {
+		number(alias)
+	}
+	for _, ifunc := range dst.IFuncs {
+		number(ifunc)
+	}
+	for _, f := range dst.Funcs {
+		number(f)
+	}
+}
+
+// removeDiscarded removes the members of discarded comdats which are no longer
+// referenced from the destination module.
+func (l *linker) removeDiscarded() {
+	if len(l.discarded) == 0 {
+		return
+	}
+	dst := l.dst
+	// Global symbols referenced from metadata.
+	mdRefs := make(map[global]bool)
+	visited := make(map[*metadata.Tuple]bool)
+	for _, md := range dst.MetadataDefs {
+		if tuple, ok := md.(*metadata.Tuple); ok {
+			tupleRefs(tuple, mdRefs, visited)
+		}
+	}
+	removed := make(map[global]bool)
+	for _, g := range l.discarded {
+		if !mdRefs[g] && len(dst.Users(g)) == 0 {
+			removed[g] = true
+		}
+	}
+	if len(removed) == 0 {
+		return
+	}
+	globals := dst.Globals[:0]
+	for _, g := range dst.Globals {
+		if !removed[g] {
+			globals = append(globals, g)
+		}
+	}
+	dst.Globals = globals
+	funcs := dst.Funcs[:0]
+	for _, f := range dst.Funcs {
+		if !removed[f] {
+			funcs = append(funcs, f)
+		}
+	}
+	dst.Funcs = funcs
+	dst.InvalidateUses()
+}
+
+// tupleRefs records the global symbols referenced from the given metadata
+// tuple, recursively.
+func tupleRefs(tuple *metadata.Tuple, refs map[global]bool, visited map[*metadata.Tuple]bool) {
+	if visited[tuple] {
+		return
+	}
+	visited[tuple] = true
+	for _, field := range tuple.Fields {
+		switch field := field.(type) {
+		case global:
+			refs[field] = true
+		case *metadata.Tuple:
+			tupleRefs(field, refs, visited)
+		}
+	}
+}
+
+// replaceUses replaces the uses of replaced global symbols in the destination
+// module with their replacement.
+func (l *linker) replaceUses() {
+	dst := l.dst
+	dst.InvalidateUses()
+	for old, new := range l.repl {
+		v := replacement(old, new)
+		for _, use := range dst.Users(old) {
+			use.Set(v)
+		}
+	}
+	dst.InvalidateUses()
+	// Uses in metadata.
+	visited := make(map[*metadata.Tuple]bool)
+	for _, md := range l.mdDefs() {
+		if tuple, ok := md.(*metadata.Tuple); ok {
+			l.replaceTupleUses(tuple, visited)
+		}
+	}
+}
+
+// replaceTupleUses replaces the uses of replaced global symbols in the fields
+// of the given metadata tuple.
+func (l *linker) replaceTupleUses(tuple *metadata.Tuple, visited map[*metadata.Tuple]bool) {
+	if visited[tuple] {
+		return
+	}
+	visited[tuple] = true
+	for i, field := range tuple.Fields {
+		switch field := field.(type) {
+		case global:
+			if new, ok := l.repl[field]; ok {
+				tuple.Fields[i] = replacement(field, new)
+			}
+		case *metadata.Tuple:
+			l.replaceTupleUses(field, visited)
+		}
+	}
+}
+
+// mdDefs returns the metadata definitions of the destination and source
+// module.
+func (l *linker) mdDefs() []metadata.Definition {
+	var defs []metadata.Definition
+	defs = append(defs, l.dst.MetadataDefs...)
+	return append(defs, l.src.MetadataDefs...)
+}
+
+// replacement returns the value replacing uses of the given global symbol,
+// casting the replacing global symbol to the type of the replaced symbol if
+// needed (e.g. common symbols of different types).
+func replacement(old, new global) constant.Constant {
+	if old.Type().Equal(new.Type()) {
+		return new
+	}
+	// Opaque pointers are compatible with pointers of the same address space.
+	ot, nt := old.Type().(*types.PointerType), new.Type().(*types.PointerType)
+	if (ot.ElemType == nil || nt.ElemType == nil) && ot.AddrSpace == nt.AddrSpace {
+		return new
+	}
+	return constant.NewBitCast(new, old.Type())
+}
+
+// mergeMetadata merges the named metadata and metadata definitions of the
+// source module into the destination module.
+func (l *linker) mergeMetadata() {
+	dst, src := l.dst, l.src
+	if dst.NamedMetadataDefs == nil {
+		dst.NamedMetadataDefs = make(map[string]*metadata.NamedDef)
+	}
+	for name, md := range src.NamedMetadataDefs {
+		dmd, ok := dst.NamedMetadataDefs[name]
+		if name == "llvm.module.flags" {
+			if !ok {
+				dmd = &metadata.NamedDef{Name: name}
+				dst.NamedMetadataDefs[name] = dmd
+			}
+			for i, node := range l.flagRepl {
+				dmd.Nodes[i] = node
+			}
+			dmd.Nodes = append(dmd.Nodes, l.flags...)
+			continue
+		}
+		if !ok {
+			dst.NamedMetadataDefs[name] = md
+			continue
+		}
+		dmd.Nodes = append(dmd.Nodes, md.Nodes...)
+	}
+	// Merge metadata definitions, dropping unused module flags.
+	var defs []metadata.Definition
+	for _, md := range l.mdDefs() {
+		if !l.droppedFlags[md] {
+			defs = append(defs, md)
+		}
+	}
+	dst.MetadataDefs = defs
+	// Renumber metadata definitions.
+	id := int64(0)
+	for _, md := range dst.MetadataDefs {
+		if md.ID() != -1 {
+			md.SetID(id)
+			id++
+		}
+	}
+}
+
+// appendInit appends the elements of the initializer of the given appending
+// global variable of the source module to the initializer of the appending
+// global variable of the destination module.
+func appendInit(d, s *ir.Global) {
+	dt := d.ContentType.(*types.ArrayType)
+	elems := append(arrayElems(d.Init, dt), arrayElems(s.Init, s.ContentType.(*types.ArrayType))...)
+	typ := types.NewArray(uint64(len(elems)), dt.ElemType)
+	d.ContentType = typ
+	d.Init = constant.NewArray(typ, elems...)
+	// Update pointer type of typed pointers.
+	if d.Typ != nil && d.Typ.ElemType != nil {
+		d.Typ = nil
+		d.Type()
+	}
+}
+
+// arrayElems returns the elements of the given array constant of the given
+// type; or nil if the constant is nil.
+func arrayElems(c constant.Constant, t *types.ArrayType) []constant.Constant {
+	switch c := c.(type) {
+	case nil:
+		return nil
+	case *constant.Array:
+		return c.Elems
+	case *constant.CharArray:
+		elems := make([]constant.Constant, len(c.X))
+		for i, b := range c.X {
+			elems[i] = constant.NewInt(t.ElemType.(*types.IntType), int64(b))
+		}
+		return elems
+	case *constant.ZeroInitializer:
+		return repeat(constant.NewZeroInitializer(t.ElemType), t.Len)
+	case *constant.Undef:
+		return repeat(constant.NewUndef(t.ElemType), t.Len)
+	case *constant.Poison:
+		return repeat(constant.NewPoison(t.ElemType), t.Len)
+	default:
+		panic(fmt.Errorf("support for array constant %T not yet implemented", c))
+	}
+}
+
+// repeat returns a slice of n copies of the given constant.
+func repeat(c constant.Constant, n uint64) []constant.Constant {
+	elems := make([]constant.Constant, n)
+	for i := range elems {
+		elems[i] = c
+	}
+	return elems
+}
@@ -0,0 +1,237 @@
+package link
+
+import (
+	"fmt"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+)
+
+// global is a global symbol of a module.
+//
+// A global has one of the following underlying types.
+//
+//   - *ir.Global
+//   - *ir.Func
+//   - *ir.Alias
+//   - *ir.IFunc
+type global interface {
+	constant.Constant
+}
+
+// moduleGlobals returns the global symbols of the given module; global
+// variables, functions, aliases and IFuncs in order.
+func moduleGlobals(m *ir.Module) []global {
+	var globals []global
+	for _, g := range m.Globals {
+		globals = append(globals, g)
+	}
+	for _, f := range m.Funcs {
+		globals = append(globals, f)
+	}
+	for _, alias := range m.Aliases {
+		globals = append(globals, alias)
+	}
+	for _, ifunc := range m.IFuncs {
+		globals = append(globals, ifunc)
+	}
+	return globals
+}
+
+// ident returns the global identifier of the given global symbol.
+func ident(g global) *ir.GlobalIdent {
+	switch g := g.(type) {
+	case *ir.Global:
+		return &g.GlobalIdent
+	case *ir.Func:
+		return &g.GlobalIdent
+	case *ir.Alias:
+		return &g.GlobalIdent
+	case *ir.IFunc:
+		return &g.GlobalIdent
+	default:
+		panic(fmt.Errorf("support for global symbol %T not yet implemented", g))
+	}
+}
+
+// linkage returns the linkage of the given global symbol.
+func linkage(g global) enum.Linkage {
+	switch g := g.(type) {
+	case *ir.Global:
+		return g.Linkage
+	case *ir.Func:
+		return g.Linkage
+	case *ir.Alias:
+		return g.Linkage
+	case *ir.IFunc:
+		return g.Linkage
+	default:
+		panic(fmt.Errorf("support for global symbol %T not yet implemented", g))
+	}
+}
+
+// visibility returns the visibility of the given global symbol.
+func visibility(g global) enum.Visibility {
+	switch g := g.(type) {
+	case *ir.Global:
+		return g.Visibility
+	case *ir.Func:
+		return g.Visibility
+	case *ir.Alias:
+		return g.Visibility
+	case *ir.IFunc:
+		return g.Visibility
+	default:
+		panic(fmt.Errorf("support for global symbol %T not yet implemented", g))
+	}
+}
+
+// setVisibility sets the visibility of the given global symbol.
+func setVisibility(g global, vis enum.Visibility) {
+	switch g := g.(type) {
+	case *ir.Global:
+		g.Visibility = vis
+	case *ir.Func:
+		g.Visibility = vis
+	case *ir.Alias:
+		g.Visibility = vis
+	case *ir.IFunc:
+		g.Visibility = vis
+	default:
+		panic(fmt.Errorf("support for global symbol %T not yet implemented", g))
+	}
+}
+
+// dllStorageClass returns the DLL storage class of the given global symbol.
+func dllStorageClass(g global) enum.DLLStorageClass {
+	switch g := g.(type) {
+	case *ir.Global:
+		return g.DLLStorageClass
+	case *ir.Func:
+		return g.DLLStorageClass
+	case *ir.Alias:
+		return g.DLLStorageClass
+	case *ir.IFunc:
+		return g.DLLStorageClass
+	default:
+		panic(fmt.Errorf("support for global symbol %T not yet implemented", g))
+	}
+}
+
+// comdat returns the comdat of the given global symbol; or nil if not
+// present.
+func comdat(g global) *ir.ComdatDef {
+	switch g := g.(type) {
+	case *ir.Global:
+		return g.Comdat
+	case *ir.Func:
+		return g.Comdat
+	}
+	return nil
+}
+
+// setComdat sets the comdat of the given global variable or function.
+func setComdat(g global, c *ir.ComdatDef) {
+	switch g := g.(type) {
+	case *ir.Global:
+		g.Comdat = c
+	case *ir.Func:
+		g.Comdat = c
+	}
+}
+
+// contentType returns the content type of the given global symbol (the
+// function type of functions).
+func contentType(g global) types.Type {
+	switch g := g.(type) {
+	case *ir.Global:
+		return g.ContentType
+	case *ir.Func:
+		return g.Sig
+	case *ir.Alias:
+		if g.ContentType != nil {
+			return g.ContentType
+		}
+		return g.Typ.ElemType
+	case *ir.IFunc:
+		if g.ContentType != nil {
+			return g.ContentType
+		}
+		return g.Typ.ElemType
+	default:
+		panic(fmt.Errorf("support for global symbol %T not yet implemented", g))
+	}
+}
+
+// isDeclaration reports whether the given global symbol is a declaration.
+func isDeclaration(g global) bool {
+	switch g := g.(type) {
+	case *ir.Global:
+		return g.Init == nil
+	case *ir.Func:
+		return len(g.Blocks) == 0
+	}
+	return false
+}
+
+// isDeclForLinker reports whether the given global symbol is a declaration or
+// has available_externally linkage; i.e. whether the definition of the symbol
+// is provided elsewhere.
+func isDeclForLinker(g global) bool {
+	return isDeclaration(g) || linkage(g) == enum.LinkageAvailableExternally
+}
+
+// dropComdatMember drops the given member of a dropped comdat of the source
+// module. Non-local members are turned into declarations, so that references
+// resolve to the members of the selected comdat, and are removed after linking
+// if unreferenced; local members are kept, but removed from the comdat.
+func (l *linker) dropComdatMember(g global) {
+	if isLocal(linkage(g)) {
+		setComdat(g, nil)
+		return
+	}
+	makeDecl(g)
+	l.discarded = append(l.discarded, g)
+}
+
+// makeDecl turns the given global variable or function into a declaration.
+func makeDecl(g global) {
+	switch g := g.(type) {
+	case *ir.Global:
+		g.Init = nil
+		g.Linkage = enum.LinkageExternal
+		g.Comdat = nil
+	case *ir.Func:
+		g.Blocks = nil
+		g.Linkage = enum.LinkageNone
+		g.Comdat = nil
+		g.Prefix = nil
+		g.Prologue = nil
+		g.Personality = nil
+		g.UseListOrders = nil
+		g.Metadata = nil
+	}
+}
+
+// isLocal reports whether the given linkage is local (internal or private).
+func isLocal(linkage enum.Linkage) bool {
+	return linkage == enum.LinkageInternal || linkage == enum.LinkagePrivate
+}
+
+// isLinkOnce reports whether the given linkage is linkonce or linkonce_odr.
+func isLinkOnce(linkage enum.Linkage) bool {
+	return linkage == enum.LinkageLinkOnce || linkage == enum.LinkageLinkOnceODR
+}
+
+// isWeak reports whether the given linkage is weak or weak_odr.
+func isWeak(linkage enum.Linkage) bool {
+	return linkage == enum.LinkageWeak || linkage == enum.LinkageWeakODR
+}
+
+// isWeakForLinker reports whether symbols of the given linkage may be replaced
+// by other symbols of the same name at link time.
+func isWeakForLinker(linkage enum.Linkage) bool {
+	return isLinkOnce(linkage) || isWeak(linkage) || linkage == enum.LinkageCommon || linkage == enum.LinkageExternWeak
+}
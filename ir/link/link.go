@@ -0,0 +1,160 @@
+// Package link implements a linker of LLVM IR modules, which merges modules
+// similar to `llvm-link`.
+//
+// Global symbols of the linked modules are resolved by name, following the
+// linkage rules of LLVM:
+//
+//   - declarations are resolved against definitions,
+//   - strong definitions take precedence over weak and linkonce definitions,
+//   - the largest of two common symbols is selected,
+//   - the initializers of appending global variables are concatenated,
+//   - symbols with internal or private linkage never conflict, and are renamed
+//     on name collision.
+//
+// Identically named type definitions are unified, comdats are resolved based
+// on their selection kinds, and named metadata, metadata definitions and
+// attribute group definitions are merged. All references to replaced global
+// symbols are rewritten to refer to the selected symbols.
+//
+// Conflicts (e.g. two strong definitions of the same symbol or mismatched
+// symbol types) are reported as *Error values in an Errors list.
+package link
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/datalayout"
+	"github.com/pkg/errors"
+)
+
+// Link links the given source modules into the destination module, one at a
+// time and in order. Global symbols, type definitions, comdats and metadata of
+// the source modules are moved into the destination module; as such, source
+// modules must not be used after linking.
+//
+// The symbol conflicts of each source module are detected before the
+// destination module is modified. If Link returns Errors, the destination
+// module contains the source modules linked before the one that failed to
+// link.
+func Link(dst *ir.Module, srcs ...*ir.Module) error {
+	for _, src := range srcs {
+		if err := linkModule(dst, src); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// linkModule links the source module into the destination module.
+func linkModule(dst, src *ir.Module) error {
+	// Use the data layout and target triple of the source module if not
+	// present in the destination module.
+	if dst.DataLayout == "" {
+		dst.DataLayout = src.DataLayout
+	}
+	if dst.TargetTriple == "" {
+		dst.TargetTriple = src.TargetTriple
+	}
+	dl := datalayout.Default()
+	if dst.DataLayout != "" {
+		var err error
+		if dl, err = datalayout.Parse(dst.DataLayout); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	l := newLinker(dst, src, dl)
+	l.linkTypeDefs()
+	l.resolveComdats()
+	l.resolveGlobals()
+	l.resolveModuleFlags()
+	if len(l.errs) > 0 {
+		return l.errs
+	}
+	l.apply()
+	return nil
+}
+
+// === [ Errors ] ==============================================================
+
+// ConflictKind specifies the kind of a link conflict.
+type ConflictKind uint8
+
+// Link conflict kinds.
+const (
+	// Two strong definitions of the same global symbol.
+	ConflictRedefinition ConflictKind = iota + 1
+	// Global symbols of the same name with mismatched types (content type or
+	// address space).
+	ConflictType
+	// Global symbols of the same name with incompatible linkage (e.g. appending
+	// and non-appending linkage).
+	ConflictLinkage
+	// Comdats of the same name with incompatible selection kinds, or a
+	// violation of the comdat selection kind (e.g. nodeduplicate).
+	ConflictComdat
+	// Module flags of the same key with conflicting values.
+	ConflictModuleFlag
+)
+
+// String returns the string representation of the link conflict kind.
+func (kind ConflictKind) String() string {
+	switch kind {
+	case ConflictRedefinition:
+		return "symbol multiply defined"
+	case ConflictType:
+		return "mismatched types"
+	case ConflictLinkage:
+		return "incompatible linkage"
+	case ConflictComdat:
+		return "comdat conflict"
+	case ConflictModuleFlag:
+		return "conflicting module flag"
+	}
+	return fmt.Sprintf("ConflictKind(%d)", uint8(kind))
+}
+
+// Error is a link conflict between the destination and source module.
+type Error struct {
+	// Conflict kind.
+	Kind ConflictKind
+	// Name of the conflicting global symbol (without '@' prefix), comdat
+	// (without '$' prefix) or module flag.
+	Name string
+	// (optional) Conflicting global symbols of the destination and source
+	// module; nil for comdat and module flag conflicts.
+	Dst, Src constant.Constant
+	// (optional) Details of the conflict.
+	Msg string
+}
+
+// Error returns a string representation of the link conflict.
+func (e *Error) Error() string {
+	var what string
+	switch e.Kind {
+	case ConflictComdat:
+		what = "COMDATs"
+	case ConflictModuleFlag:
+		what = "module flags"
+	default:
+		what = "globals"
+	}
+	if e.Msg != "" {
+		return fmt.Sprintf("linking %s named %q: %v; %s", what, e.Name, e.Kind, e.Msg)
+	}
+	return fmt.Sprintf("linking %s named %q: %v", what, e.Name, e.Kind)
+}
+
+// Errors is a list of link conflicts.
+type Errors []*Error
+
+// Error returns a string representation of the link conflicts.
+func (errs Errors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
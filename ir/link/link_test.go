@@ -0,0 +1,412 @@
+package link_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/link"
+	"github.com/llir/llvm/ir/verify"
+)
+
+func TestLink(t *testing.T) {
+	golden := []struct {
+		name string
+		srcs []string
+		want string
+	}{
+		{
+			name: "linkage",
+			srcs: []string{
+				`
+@llvm.global_ctors = appending global [1 x { i32, ptr, ptr }] [{ i32, ptr, ptr } { i32 0, ptr @init_a, ptr null }]
+@c = common global [4 x i8] zeroinitializer
+@w = weak global i32 1
+@l = linkonce_odr hidden global i32 1
+
+declare i32 @f(i32)
+
+define internal void @helper() {
+	ret void
+}
+
+define internal void @init_a() {
+	call void @helper()
+	ret void
+}
+
+define i32 @main() {
+	%x = call i32 @f(i32 1)
+	%w = load i32, ptr @w
+	%y = add i32 %x, %w
+	ret i32 %y
+}
+`,
+				`
+@llvm.global_ctors = appending global [1 x { i32, ptr, ptr }] [{ i32, ptr, ptr } { i32 0, ptr @init_b, ptr null }]
+@c = common global [8 x i8] zeroinitializer
+@w = global i32 2
+@l = weak_odr global i32 1
+@p = global ptr @f
+
+define internal void @helper() {
+	ret void
+}
+
+define internal void @init_b() {
+	call void @helper()
+	ret void
+}
+
+define i32 @f(i32 %x) {
+	ret i32 %x
+}
+`,
+			},
+			want: `
+@llvm.global_ctors = appending global [2 x { i32, ptr, ptr }] [{ i32, ptr, ptr } { i32 0, ptr @init_a, ptr null }, { i32, ptr, ptr } { i32 0, ptr @init_b, ptr null }]
+@c = common global [8 x i8] zeroinitializer
+@w = global i32 2
+@l = weak_odr hidden global i32 1
+@p = global ptr @f
+
+define i32 @f(i32 %x) {
+0:
+	ret i32 %x
+}
+
+define internal void @helper() {
+0:
+	ret void
+}
+
+define internal void @init_a() {
+0:
+	call void @helper()
+	ret void
+}
+
+define i32 @main() {
+0:
+	%x = call i32 @f(i32 1)
+	%w = load i32, ptr @w
+	%y = add i32 %x, %w
+	ret i32 %y
+}
+
+define internal void @helper.1() {
+0:
+	ret void
+}
+
+define internal void @init_b() {
+0:
+	call void @helper.1()
+	ret void
+}`,
+		},
+		{
+			name: "types",
+			srcs: []string{
+				`
+%T = type { i32, ptr }
+%U = type { i32 }
+%O = type opaque
+
+@t = global %T zeroinitializer
+@u = global %U zeroinitializer
+@o = external global %O
+`,
+				`
+%T = type { i32, ptr }
+%U = type { i64 }
+%V = type { %U }
+%O = type { i8 }
+
+@t2 = global %T zeroinitializer
+@v = global %V zeroinitializer
+@o = global %O zeroinitializer
+`,
+			},
+			want: `
+%O = type { i8 }
+%T = type { i32, ptr }
+%U = type { i32 }
+%U.0 = type { i64 }
+%V = type { %U.0 }
+
+@t = global %T zeroinitializer
+@u = global %U zeroinitializer
+@o = global %O zeroinitializer
+@t2 = global %T zeroinitializer
+@v = global %V zeroinitializer`,
+		},
+		{
+			name: "comdat",
+			srcs: []string{
+				`
+$c = comdat any
+$d = comdat largest
+
+@c = linkonce_odr global i32 1, comdat
+@d = linkonce_odr global [4 x i8] zeroinitializer, comdat
+
+define linkonce_odr i32 @c.get() comdat($c) {
+	ret i32 1
+}
+
+define linkonce_odr void @d.init() comdat($d) {
+	ret void
+}
+`,
+				`
+$c = comdat any
+$d = comdat largest
+
+@c = linkonce_odr global i32 2, comdat
+@d = linkonce_odr global [8 x i8] zeroinitializer, comdat
+
+define linkonce_odr i32 @c.get() comdat($c) {
+	ret i32 2
+}
+
+define linkonce_odr void @d.init() comdat($d) {
+	call void @d.extra()
+	ret void
+}
+
+define linkonce_odr void @d.extra() comdat($d) {
+	ret void
+}
+`,
+			},
+			want: `
+$c = comdat any
+$d = comdat largest
+
+@c = linkonce_odr global i32 1, comdat
+@d = linkonce_odr global [8 x i8] zeroinitializer, comdat
+
+define linkonce_odr i32 @c.get() comdat($c) {
+0:
+	ret i32 1
+}
+
+define linkonce_odr void @d.init() comdat($d) {
+0:
+	call void @d.extra()
+	ret void
+}
+
+define linkonce_odr void @d.extra() comdat($d) {
+0:
+	ret void
+}`,
+		},
+		{
+			name: "discarded comdat members",
+			srcs: []string{
+				`
+$c = comdat any
+
+@c = linkonce_odr global i32 1, comdat
+`,
+				`
+$c = comdat any
+
+@c = linkonce_odr global i32 2, comdat
+@cg2 = linkonce_odr global i32 2, comdat($c)
+@cg3 = linkonce_odr global i32 3, comdat($c)
+@p = global ptr @cg3
+`,
+			},
+			want: `
+$c = comdat any
+
+@c = linkonce_odr global i32 1, comdat
+@cg3 = external global i32
+@p = global ptr @cg3`,
+		},
+		{
+			name: "replaced comdat members",
+			srcs: []string{
+				`
+$d = comdat largest
+
+@d = linkonce_odr global [4 x i8] zeroinitializer, comdat
+@d.extra = linkonce_odr global i32 1, comdat($d)
+`,
+				`
+$d = comdat largest
+
+@d = linkonce_odr global [8 x i8] zeroinitializer, comdat
+`,
+			},
+			want: `
+$d = comdat largest
+
+@d = linkonce_odr global [8 x i8] zeroinitializer, comdat`,
+		},
+		{
+			name: "metadata",
+			srcs: []string{
+				`
+@0 = private global i32 1
+@fp = global ptr @f
+
+define void @f() !dbg !2 {
+	ret void
+}
+
+!llvm.module.flags = !{!0, !1}
+!llvm.ident = !{!3}
+
+!0 = !{i32 1, !"wchar_size", i32 4}
+!1 = !{i32 7, !"PIC Level", i32 1}
+!2 = !{!"f"}
+!3 = !{!"a"}
+`,
+				`
+@0 = private global i32 2
+
+declare void @f()
+
+define void @g() !dbg !2 {
+	call void @f()
+	ret void
+}
+
+!llvm.module.flags = !{!0, !1}
+!llvm.ident = !{!3}
+!used = !{!4}
+
+!0 = !{i32 1, !"wchar_size", i32 4}
+!1 = !{i32 7, !"PIC Level", i32 2}
+!2 = !{!"g"}
+!3 = !{!"b"}
+!4 = !{ptr @f}
+`,
+			},
+			want: `
+@0 = private global i32 1
+@fp = global ptr @f
+@1 = private global i32 2
+
+define void @f() !dbg !1 {
+0:
+	ret void
+}
+
+define void @g() !dbg !4 {
+0:
+	call void @f()
+	ret void
+}
+
+!llvm.ident = !{!2, !5}
+!llvm.module.flags = !{!0, !3}
+!used = !{!6}
+
+!0 = !{i32 1, !"wchar_size", i32 4}
+!1 = !{!"f"}
+!2 = !{!"a"}
+!3 = !{i32 7, !"PIC Level", i32 2}
+!4 = !{!"g"}
+!5 = !{!"b"}
+!6 = !{ptr @f}`,
+		},
+	}
+	for _, g := range golden {
+		var ms []*ir.Module
+		for i, src := range g.srcs {
+			m, err := asm.ParseString(fmt.Sprintf("%s_%d.ll", g.name, i), src)
+			if err != nil {
+				t.Fatalf("%q: unable to parse module; %+v", g.name, err)
+			}
+			ms = append(ms, m)
+		}
+		dst := ms[0]
+		if err := link.Link(dst, ms[1:]...); err != nil {
+			t.Errorf("%q: unable to link modules; %v", g.name, err)
+			continue
+		}
+		for _, err := range verify.Module(dst) {
+			t.Errorf("%q: unexpected verification error; %v", g.name, err)
+		}
+		got := strings.TrimSpace(dst.String())
+		want := strings.TrimSpace(g.want)
+		if got != want {
+			t.Errorf("%q: output mismatch; expected\n%s\n\ngot\n%s", g.name, want, got)
+		}
+	}
+}
+
+func TestLinkConflicts(t *testing.T) {
+	golden := []struct {
+		name string
+		dst  string
+		src  string
+		want []link.ConflictKind
+	}{
+		{
+			name: "redefinition",
+			dst:  "define void @f() {\n\tret void\n}",
+			src:  "define void @f() {\n\tret void\n}\n@g = global i32 0",
+			want: []link.ConflictKind{link.ConflictRedefinition},
+		},
+		{
+			name: "type",
+			dst:  "@f = global i32 0\n@g = external global i64",
+			src:  "declare void @f()\n@g = global i32 0",
+			want: []link.ConflictKind{link.ConflictType, link.ConflictType},
+		},
+		{
+			name: "appending",
+			dst:  "@a = appending global [1 x i32] [i32 1]",
+			src:  "@a = global [1 x i32] [i32 2]",
+			want: []link.ConflictKind{link.ConflictLinkage},
+		},
+		{
+			name: "comdat",
+			dst:  "$c = comdat nodeduplicate\n$d = comdat any\n@c = global i32 0, comdat\n@d = global i32 0, comdat",
+			src:  "$c = comdat nodeduplicate\n$d = comdat exactmatch\n@c = global i32 0, comdat\n@d = global i32 0, comdat",
+			want: []link.ConflictKind{link.ConflictComdat, link.ConflictComdat},
+		},
+		{
+			name: "module flag",
+			dst:  "!llvm.module.flags = !{!0}\n!0 = !{i32 1, !\"wchar_size\", i32 4}",
+			src:  "!llvm.module.flags = !{!0}\n!0 = !{i32 1, !\"wchar_size\", i32 2}",
+			want: []link.ConflictKind{link.ConflictModuleFlag},
+		},
+	}
+	for _, g := range golden {
+		dst, err := asm.ParseString(g.name+"_dst.ll", g.dst)
+		if err != nil {
+			t.Fatalf("%q: unable to parse module; %+v", g.name, err)
+		}
+		src, err := asm.ParseString(g.name+"_src.ll", g.src)
+		if err != nil {
+			t.Fatalf("%q: unable to parse module; %+v", g.name, err)
+		}
+		before := dst.String()
+		err = link.Link(dst, src)
+		errs, ok := err.(link.Errors)
+		if !ok {
+			t.Errorf("%q: expected link.Errors, got %v", g.name, err)
+			continue
+		}
+		var got []link.ConflictKind
+		for _, err := range errs {
+			got = append(got, err.Kind)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(g.want) {
+			t.Errorf("%q: conflict kinds mismatch; expected %v, got %v", g.name, g.want, got)
+		}
+		// The destination module is not modified on conflict.
+		if after := dst.String(); after != before {
+			t.Errorf("%q: destination module modified on conflict; expected\n%s\n\ngot\n%s", g.name, before, after)
+		}
+	}
+}
@@ -0,0 +1,627 @@
+package link
+
+import (
+	"fmt"
+
+	"github.com/llir/llvm/internal/enc"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/datalayout"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/metadata"
+	"github.com/llir/llvm/ir/types"
+)
+
+// linker links a source module into a destination module.
+//
+// Linking is performed in two phases. First, the global symbols, comdats and
+// module flags of the source module are resolved against those of the
+// destination module, recording conflicts and the resulting actions. Then, if
+// no conflicts were found, the actions are applied to the destination module.
+type linker struct {
+	// Destination and source module.
+	dst, src *ir.Module
+	// Data layout of the destination module.
+	dl *datalayout.DataLayout
+
+	// Named global symbols of the destination and source module, by
+	// identifier.
+	dstGlobals, srcGlobals map[string]global
+	// Global names in use by the destination or source module.
+	names map[string]bool
+	// Comdat definitions of the destination module, by name.
+	dstComdats map[string]*ir.ComdatDef
+
+	// Resolved comdats of the source module which are also present in the
+	// destination module.
+	comdats map[*ir.ComdatDef]*comdatResult
+	// Comdats of the destination module replaced by comdats of the source
+	// module.
+	replacedComdats map[*ir.ComdatDef]bool
+	// Type definitions of the source module to add to the destination module.
+	typeDefs []types.Type
+	// Opaque struct types of the destination module to define using the body
+	// of struct types of the same name in the source module.
+	opaques []typePair
+	// Global symbols of the source module to add to the destination module.
+	added []global
+	// Members of discarded comdats turned into declarations; removed from the
+	// destination module if no references remain after linking.
+	discarded []global
+	// Replaced global symbols, mapped to their replacement.
+	repl map[global]global
+	// Global symbols of the destination module to rename, mapped to their new
+	// name.
+	renames map[global]string
+	// Merged visibility of selected global symbols.
+	vis map[global]enum.Visibility
+	// Appending global variables of the source module to concatenate with
+	// those of the destination module.
+	appends []appendPair
+	// Module flags of the source module to add to the destination module.
+	flags []metadata.Node
+	// Module flags of the destination module to replace, by index.
+	flagRepl map[int]metadata.Node
+	// Dropped module flags of the destination and source module.
+	droppedFlags map[metadata.Node]bool
+
+	// Link conflicts.
+	errs Errors
+}
+
+// comdatResult is the result of resolving a comdat of the source module
+// against the comdat of the same name in the destination module.
+type comdatResult struct {
+	// Comdat of the destination module.
+	dst *ir.ComdatDef
+	// Resulting selection kind.
+	kind enum.SelectionKind
+	// The comdat members of the source module are selected.
+	fromSrc bool
+}
+
+// typePair is a pair of type definitions of the same name in the destination
+// and source module.
+type typePair struct {
+	dst, src types.Type
+}
+
+// appendPair is a pair of appending global variables of the same name in the
+// destination and source module.
+type appendPair struct {
+	dst, src *ir.Global
+}
+
+// newLinker returns a new linker of the given source module into the given
+// destination module.
+func newLinker(dst, src *ir.Module, dl *datalayout.DataLayout) *linker {
+	l := &linker{
+		dst:             dst,
+		src:             src,
+		dl:              dl,
+		dstGlobals:      make(map[string]global),
+		srcGlobals:      make(map[string]global),
+		names:           make(map[string]bool),
+		dstComdats:      make(map[string]*ir.ComdatDef),
+		comdats:         make(map[*ir.ComdatDef]*comdatResult),
+		replacedComdats: make(map[*ir.ComdatDef]bool),
+		repl:            make(map[global]global),
+		renames:         make(map[global]string),
+		vis:             make(map[global]enum.Visibility),
+		flagRepl:        make(map[int]metadata.Node),
+		droppedFlags:    make(map[metadata.Node]bool),
+	}
+	index := func(m *ir.Module, globals map[string]global) {
+		for _, g := range moduleGlobals(m) {
+			if id := ident(g); !id.IsUnnamed() {
+				globals[g.Ident()] = g
+				l.names[id.GlobalName] = true
+			}
+		}
+	}
+	index(dst, l.dstGlobals)
+	index(src, l.srcGlobals)
+	for _, c := range dst.ComdatDefs {
+		l.dstComdats[c.Name] = c
+	}
+	return l
+}
+
+// errorf records a link conflict of the given kind.
+func (l *linker) errorf(kind ConflictKind, name string, d, s global, format string, args ...interface{}) {
+	err := &Error{Kind: kind, Name: name, Dst: d, Src: s, Msg: fmt.Sprintf(format, args...)}
+	l.errs = append(l.errs, err)
+}
+
+// === [ Type definitions ] ====================================================
+
+// linkTypeDefs resolves the type definitions of the source module against the
+// type definitions of the destination module. Structurally identical type
+// definitions of the same name are unified, and an opaque struct type is
+// unified with a struct type of the same name. Other type definitions of the
+// source module are renamed on name collision (e.g. %T to %T.0).
+func (l *linker) linkTypeDefs() {
+	dstDefs := make(map[string]types.Type)
+	names := make(map[string]bool)
+	for _, t := range l.dst.TypeDefs {
+		dstDefs[t.Name()] = t
+		names[t.Name()] = true
+	}
+	for _, t := range l.src.TypeDefs {
+		names[t.Name()] = true
+	}
+	var pairs []typePair
+	for _, t := range l.src.TypeDefs {
+		if dt, ok := dstDefs[t.Name()]; ok {
+			pairs = append(pairs, typePair{dst: dt, src: t})
+		}
+	}
+	// Rename type definitions of the source module which differ from the type
+	// definition of the same name in the destination module. Repeat until no
+	// more type definitions are renamed, as renaming a type definition changes
+	// the type definitions referring to it.
+	renamed := make(map[types.Type]bool)
+	for changed := true; changed; {
+		changed = false
+		for _, p := range pairs {
+			if renamed[p.src] || sameTypeDef(p.dst, p.src) {
+				continue
+			}
+			p.src.SetName(uniqueName(p.src.Name(), 0, names))
+			renamed[p.src] = true
+			changed = true
+		}
+	}
+	for _, p := range pairs {
+		ds, ok1 := p.dst.(*types.StructType)
+		ss, ok2 := p.src.(*types.StructType)
+		if renamed[p.src] || !ok1 || !ok2 {
+			continue
+		}
+		switch {
+		case ds.Opaque && !ss.Opaque:
+			l.opaques = append(l.opaques, p)
+		case ss.Opaque && !ds.Opaque:
+			// Uses of the opaque struct type in the source module refer to the
+			// struct type of the destination module after linking.
+			ss.Opaque = false
+			ss.Packed = ds.Packed
+			ss.Fields = ds.Fields
+		}
+	}
+	// Add type definitions of the source module not unified with type
+	// definitions of the destination module.
+	for _, t := range l.src.TypeDefs {
+		if _, ok := dstDefs[t.Name()]; !ok || renamed[t] {
+			l.typeDefs = append(l.typeDefs, t)
+		}
+	}
+}
+
+// sameTypeDef reports whether the given type definitions of the same name are
+// structurally identical. Opaque struct types are identical to any struct
+// type.
+func sameTypeDef(d, s types.Type) bool {
+	ds, ok1 := d.(*types.StructType)
+	ss, ok2 := s.(*types.StructType)
+	if !ok1 || !ok2 {
+		return !ok1 && !ok2 && d.Equal(s)
+	}
+	if ds.Opaque || ss.Opaque {
+		return true
+	}
+	if ds.Packed != ss.Packed || len(ds.Fields) != len(ss.Fields) {
+		return false
+	}
+	for i := range ds.Fields {
+		if !ds.Fields[i].Equal(ss.Fields[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// === [ Comdats ] =============================================================
+
+// resolveComdats resolves the comdats of the source module against the
+// comdats of the same name in the destination module, based on their
+// selection kinds.
+func (l *linker) resolveComdats() {
+	for _, sc := range l.src.ComdatDefs {
+		dc, ok := l.dstComdats[sc.Name]
+		if !ok {
+			continue
+		}
+		res, msg := l.resolveComdat(dc, sc)
+		if msg != "" {
+			l.errorf(ConflictComdat, sc.Name, nil, nil, "%s", msg)
+			// Drop the comdat of the source module, to prevent reporting
+			// conflicts of its members.
+			res = &comdatResult{dst: dc, kind: dc.Kind}
+		}
+		l.comdats[sc] = res
+		if res.fromSrc {
+			l.replacedComdats[dc] = true
+		}
+	}
+}
+
+// resolveComdat resolves the given comdat of the source module against the
+// comdat of the same name in the destination module, following the rules of
+// llvm::ModuleLinker::computeResultingSelectionKind and getComdatResult. On
+// conflict, a non-empty error message is returned.
+func (l *linker) resolveComdat(dc, sc *ir.ComdatDef) (*comdatResult, string) {
+	kind, ok := selectionKind(dc.Kind, sc.Kind)
+	if !ok {
+		return nil, fmt.Sprintf("invalid selection kinds %v and %v", dc.Kind, sc.Kind)
+	}
+	res := &comdatResult{dst: dc, kind: kind}
+	switch kind {
+	case enum.SelectionKindAny:
+		// Select the comdat of the destination module.
+		return res, ""
+	case enum.SelectionKindNoDeduplicate:
+		return nil, "nodeduplicate has been violated"
+	}
+	// Data dependent selection, based on the global variable of the comdat
+	// name.
+	d := comdatLeader(l.dstGlobals, sc.Name)
+	s := comdatLeader(l.srcGlobals, sc.Name)
+	if d == nil || s == nil {
+		return nil, "global variable required for data dependent selection"
+	}
+	dsize := l.dl.AllocSizeOf(d.ContentType)
+	ssize := l.dl.AllocSizeOf(s.ContentType)
+	switch kind {
+	case enum.SelectionKindExactMatch:
+		if !d.ContentType.Equal(s.ContentType) || d.Init.Ident() != s.Init.Ident() {
+			return nil, "exactmatch has been violated"
+		}
+	case enum.SelectionKindLargest:
+		res.fromSrc = ssize > dsize
+	case enum.SelectionKindSameSize:
+		if dsize != ssize {
+			return nil, "samesize has been violated"
+		}
+	}
+	return res, ""
+}
+
+// selectionKind returns the resulting selection kind of linking comdats of the
+// given selection kinds. The boolean return value indicates success.
+func selectionKind(d, s enum.SelectionKind) (enum.SelectionKind, bool) {
+	anyOrLargest := func(kind enum.SelectionKind) bool {
+		return kind == enum.SelectionKindAny || kind == enum.SelectionKindLargest
+	}
+	switch {
+	case anyOrLargest(d) && anyOrLargest(s):
+		if d == enum.SelectionKindLargest || s == enum.SelectionKindLargest {
+			return enum.SelectionKindLargest, true
+		}
+		return enum.SelectionKindAny, true
+	case d == s:
+		return d, true
+	}
+	return 0, false
+}
+
+// comdatLeader returns the global variable definition of the given comdat
+// name, looking through aliases; or nil if not present.
+func comdatLeader(globals map[string]global, name string) *ir.Global {
+	var c constant.Constant = globals[enc.GlobalName(name)]
+	for {
+		switch v := c.(type) {
+		case *ir.Global:
+			if v.Init == nil {
+				return nil
+			}
+			return v
+		case *ir.Alias:
+			c = v.Aliasee
+		case *constant.ExprBitCast:
+			c = v.From
+		case *constant.ExprAddrSpaceCast:
+			c = v.From
+		default:
+			return nil
+		}
+	}
+}
+
+// droppedComdat reports whether the given comdat of the source module is
+// dropped in favour of the comdat of the same name in the destination module.
+func (l *linker) droppedComdat(c *ir.ComdatDef) bool {
+	res, ok := l.comdats[c]
+	return ok && !res.fromSrc
+}
+
+// === [ Global symbols ] ======================================================
+
+// resolveGlobals resolves the global symbols of the source module against the
+// global symbols of the destination module.
+func (l *linker) resolveGlobals() {
+	for _, s := range moduleGlobals(l.src) {
+		id := ident(s)
+		if id.IsUnnamed() {
+			// Unnamed global symbols never conflict.
+			l.added = append(l.added, s)
+			continue
+		}
+		dropped := l.droppedComdat(comdat(s))
+		d, ok := l.dstGlobals[s.Ident()]
+		switch {
+		case !ok:
+			if dropped {
+				l.dropComdatMember(s)
+			}
+			l.added = append(l.added, s)
+		case isLocal(linkage(s)):
+			// Rename local symbol of the source module.
+			if dropped {
+				l.dropComdatMember(s)
+			}
+			id.SetName(uniqueName(id.GlobalName, 1, l.names))
+			l.added = append(l.added, s)
+		case isLocal(linkage(d)):
+			// Rename local symbol of the destination module.
+			if dropped {
+				l.dropComdatMember(s)
+			}
+			l.renames[d] = uniqueName(id.GlobalName, 1, l.names)
+			l.added = append(l.added, s)
+		default:
+			l.resolveGlobal(d, s, dropped)
+		}
+	}
+}
+
+// resolveGlobal resolves the given global symbol of the source module against
+// the non-local global symbol of the same name in the destination module.
+// dropped specifies whether the source symbol is a member of a dropped comdat.
+func (l *linker) resolveGlobal(d, s global, dropped bool) {
+	name := ident(s).GlobalName
+	if linkage(d) == enum.LinkageAppending || linkage(s) == enum.LinkageAppending {
+		l.resolveAppending(d, s)
+		return
+	}
+	// Check types.
+	dt := d.Type().(*types.PointerType)
+	st := s.Type().(*types.PointerType)
+	if dt.AddrSpace != st.AddrSpace {
+		l.errorf(ConflictType, name, d, s, "address spaces %d and %d", dt.AddrSpace, st.AddrSpace)
+		return
+	}
+	_, dok := d.(*ir.Global)
+	_, sok := s.(*ir.Global)
+	common := dok && sok && (linkage(d) == enum.LinkageCommon || linkage(s) == enum.LinkageCommon)
+	viaComdat := dropped || l.replacedComdats[comdat(d)]
+	// The content types of common symbols and comdat members may differ, as
+	// the largest common symbol or comdat is selected.
+	if !common && !viaComdat && !contentType(d).Equal(contentType(s)) {
+		l.errorf(ConflictType, name, d, s, "types %v and %v", contentType(d), contentType(s))
+		return
+	}
+	fromSrc, ok := l.linkFromSrc(d, s, dropped)
+	if !ok {
+		l.errorf(ConflictRedefinition, name, d, s, "")
+		return
+	}
+	winner, loser := d, s
+	if fromSrc {
+		winner, loser = s, d
+		l.added = append(l.added, s)
+	}
+	l.repl[loser] = winner
+	// The more constraining visibility of the two symbols is used.
+	if vis := mergeVisibility(visibility(d), visibility(s)); vis != visibility(winner) {
+		l.vis[winner] = vis
+	}
+}
+
+// linkFromSrc reports whether the global symbol of the source module is
+// selected over the global symbol of the same name in the destination module,
+// following the rules of llvm::ModuleLinker::shouldLinkFromSource. The
+// boolean return value is false if both are strong definitions.
+func (l *linker) linkFromSrc(d, s global, dropped bool) (fromSrc, ok bool) {
+	switch {
+	case dropped:
+		return false, true
+	case l.replacedComdats[comdat(d)]:
+		return true, true
+	}
+	dstDecl := isDeclForLinker(d)
+	if isDeclForLinker(s) {
+		if dllStorageClass(s) == enum.DLLStorageClassDLLImport {
+			return dstDecl, true
+		}
+		// Replace extern_weak declaration.
+		if linkage(d) == enum.LinkageExternWeak {
+			return true, true
+		}
+		// Select available_externally definition over declaration.
+		return !isDeclaration(s) && isDeclaration(d), true
+	}
+	if dstDecl {
+		return true, true
+	}
+	dl, sl := linkage(d), linkage(s)
+	if sl == enum.LinkageCommon {
+		switch {
+		case isLinkOnce(dl) || isWeak(dl):
+			return true, true
+		case dl != enum.LinkageCommon:
+			return false, true
+		}
+		// Select the largest common symbol.
+		return l.dl.AllocSizeOf(contentType(s)) > l.dl.AllocSizeOf(contentType(d)), true
+	}
+	if isWeakForLinker(sl) {
+		return isLinkOnce(dl) && isWeak(sl), true
+	}
+	if isWeakForLinker(dl) {
+		return true, true
+	}
+	return false, false
+}
+
+// resolveAppending resolves the given global symbols of the same name, of
+// which at least one has appending linkage.
+func (l *linker) resolveAppending(d, s global) {
+	name := ident(s).GlobalName
+	dg, ok1 := d.(*ir.Global)
+	sg, ok2 := s.(*ir.Global)
+	if !ok1 || !ok2 || dg.Linkage != sg.Linkage {
+		l.errorf(ConflictLinkage, name, d, s, "appending global linked with non-appending global")
+		return
+	}
+	dt, ok1 := dg.ContentType.(*types.ArrayType)
+	st, ok2 := sg.ContentType.(*types.ArrayType)
+	if !ok1 || !ok2 || !dt.ElemType.Equal(st.ElemType) {
+		l.errorf(ConflictType, name, d, s, "appending globals of types %v and %v", dg.ContentType, sg.ContentType)
+		return
+	}
+	if dg.Immutable != sg.Immutable {
+		l.errorf(ConflictLinkage, name, d, s, "appending globals of different constness")
+		return
+	}
+	if dg.Section != sg.Section {
+		l.errorf(ConflictLinkage, name, d, s, "appending globals in different sections")
+		return
+	}
+	l.appends = append(l.appends, appendPair{dst: dg, src: sg})
+	l.repl[s] = d
+}
+
+// === [ Module flags ] ========================================================
+
+// Module flag behaviours.
+const (
+	flagError    = 1
+	flagOverride = 4
+	flagMax      = 7
+	flagMin      = 8
+)
+
+// resolveModuleFlags resolves the module flags (!llvm.module.flags) of the
+// source module against the module flags of the destination module, by flag
+// key. Flags with error behaviour must have the same value, flags with max and
+// min behaviour select the maximum and minimum value respectively, and flags
+// with override behaviour take precedence over other flags. Otherwise, the
+// module flag of the destination module is kept.
+func (l *linker) resolveModuleFlags() {
+	sflags, ok := l.src.NamedMetadataDefs["llvm.module.flags"]
+	if !ok {
+		return
+	}
+	dstIndex := make(map[string]int)
+	var dflags []metadata.Node
+	if md, ok := l.dst.NamedMetadataDefs["llvm.module.flags"]; ok {
+		dflags = md.Nodes
+		for i, node := range dflags {
+			if _, key, _, ok := moduleFlag(node); ok {
+				dstIndex[key] = i
+			}
+		}
+	}
+	for _, node := range sflags.Nodes {
+		behavior, key, val, ok := moduleFlag(node)
+		if !ok {
+			l.flags = append(l.flags, node)
+			continue
+		}
+		i, ok := dstIndex[key]
+		if !ok {
+			l.flags = append(l.flags, node)
+			continue
+		}
+		dbehavior, _, dval, _ := moduleFlag(dflags[i])
+		switch {
+		case dbehavior == flagOverride && behavior == flagOverride:
+			if dval.String() != val.String() {
+				l.errorf(ConflictModuleFlag, key, nil, nil, "conflicting override values %v and %v", dval, val)
+			}
+			continue
+		case dbehavior == flagOverride:
+			continue
+		case behavior == flagOverride:
+			l.replaceFlag(i, dflags[i], node)
+			continue
+		case dbehavior != behavior:
+			l.errorf(ConflictModuleFlag, key, nil, nil, "conflicting behaviours %d and %d", dbehavior, behavior)
+			continue
+		}
+		switch behavior {
+		case flagError:
+			if dval.String() != val.String() {
+				l.errorf(ConflictModuleFlag, key, nil, nil, "conflicting values %v and %v", dval, val)
+			}
+		case flagMax, flagMin:
+			x, ok1 := dval.(*constant.Int)
+			y, ok2 := val.(*constant.Int)
+			if !ok1 || !ok2 {
+				continue
+			}
+			if cmp := y.X.Cmp(x.X); (behavior == flagMax && cmp > 0) || (behavior == flagMin && cmp < 0) {
+				l.replaceFlag(i, dflags[i], node)
+				continue
+			}
+		}
+		l.droppedFlags[node] = true
+	}
+}
+
+// replaceFlag replaces the given module flag of the destination module, at
+// index i, with the given module flag of the source module.
+func (l *linker) replaceFlag(i int, old, new metadata.Node) {
+	l.flagRepl[i] = new
+	l.droppedFlags[old] = true
+}
+
+// moduleFlag returns the behaviour, key and value of the given module flag.
+// The boolean return value indicates success.
+func moduleFlag(node metadata.Node) (behavior int64, key string, val metadata.Field, ok bool) {
+	tuple, ok := node.(*metadata.Tuple)
+	if !ok || len(tuple.Fields) != 3 {
+		return 0, "", nil, false
+	}
+	b, ok := tuple.Fields[0].(*constant.Int)
+	if !ok {
+		return 0, "", nil, false
+	}
+	k, ok := tuple.Fields[1].(*metadata.String)
+	if !ok {
+		return 0, "", nil, false
+	}
+	return b.X.Int64(), k.Value, tuple.Fields[2], true
+}
+
+// ### [ Helper functions ] ####################################################
+
+// uniqueName returns a unique name based on the given name by appending a
+// numeric suffix (starting at start), and marks the name as used.
+func uniqueName(name string, start int, used map[string]bool) string {
+	for i := start; ; i++ {
+		newName := fmt.Sprintf("%s.%d", name, i)
+		if !used[newName] {
+			used[newName] = true
+			return newName
+		}
+	}
+}
+
+// mergeVisibility returns the more constraining of the given visibilities.
+func mergeVisibility(a, b enum.Visibility) enum.Visibility {
+	rank := func(vis enum.Visibility) int {
+		switch vis {
+		case enum.VisibilityHidden:
+			return 2
+		case enum.VisibilityProtected:
+			return 1
+		}
+		return 0
+	}
+	if rank(b) > rank(a) {
+		return b
+	}
+	return a
+}
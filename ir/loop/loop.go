@@ -0,0 +1,437 @@
+// Package loop implements natural loop and loop nest analysis of LLVM IR
+// functions.
+//
+// A natural loop is identified by a header basic block which dominates a set of
+// latch basic blocks, each having a back edge to the header. The loops of a
+// function form a forest, where each loop is nested within the smallest loop
+// containing its header.
+//
+// Cycles of the control flow graph that are not natural loops (i.e. cycles
+// which may be entered through more than one basic block) are reported as
+// irreducible regions. Basic blocks not reachable from the entry basic block
+// are not part of any loop or irreducible region.
+package loop
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/dom"
+)
+
+// Info is the loop forest of a function.
+type Info struct {
+	// Top-level loops of the function, in the order of their headers in the
+	// function.
+	TopLevel []*Loop
+	// Irreducible regions of the function, in the order of their first basic
+	// block in the function.
+	Irreducible []*Region
+
+	// Function of the loop forest.
+	f *ir.Func
+	// Innermost loop of each basic block contained within a loop.
+	loops map[*ir.Block]*Loop
+}
+
+// Loop is a natural loop.
+type Loop struct {
+	// Header basic block of the loop; dominates every basic block of the loop.
+	Header *ir.Block
+	// Basic blocks of the loop, including the header and basic blocks of nested
+	// loops; in the order of the basic blocks in the function.
+	Blocks []*ir.Block
+	// Latch basic blocks of the loop, each having a back edge to the header; in
+	// the order of the basic blocks in the function.
+	Latches []*ir.Block
+	// Parent loop; or nil if top-level loop.
+	Parent *Loop
+	// Loops nested directly within the loop, in the order of their headers in
+	// the function.
+	Children []*Loop
+	// Nesting depth of the loop; top-level loops have depth 1.
+	Depth int
+
+	// Predecessor basic blocks of each basic block of the function.
+	preds map[*ir.Block][]*ir.Block
+	// Set of basic blocks of the loop.
+	set map[*ir.Block]bool
+}
+
+// Region is an irreducible region; a strongly connected component of the
+// control flow graph (after removal of the back edges of natural loops) which
+// is entered through more than one basic block.
+type Region struct {
+	// Entry basic blocks of the region; i.e. basic blocks of the region with
+	// predecessors outside of the region. In the order of the basic blocks in
+	// the function.
+	Entries []*ir.Block
+	// Basic blocks of the region, in the order of the basic blocks in the
+	// function.
+	Blocks []*ir.Block
+}
+
+// New returns the loop forest of the given function, which must have at least
+// one basic block.
+func New(f *ir.Func) *Info {
+	domTree := dom.New(f)
+	info := &Info{
+		f:     f,
+		loops: make(map[*ir.Block]*Loop),
+	}
+	index := make(map[*ir.Block]int)
+	for i, block := range f.Blocks {
+		index[block] = i
+	}
+	// Predecessors of reachable basic blocks, with one entry per distinct
+	// control flow edge.
+	preds := make(map[*ir.Block][]*ir.Block)
+	for _, block := range f.Blocks {
+		if !domTree.Reachable(block) {
+			continue
+		}
+		for _, succ := range distinctSuccs(block) {
+			if _, ok := index[succ]; ok {
+				preds[succ] = append(preds[succ], block)
+			}
+		}
+	}
+	// Identify natural loops based on back edges.
+	var loops []*Loop
+	for _, header := range f.Blocks {
+		var latches []*ir.Block
+		for _, pred := range preds[header] {
+			if domTree.Dominates(header, pred) {
+				latches = append(latches, pred)
+			}
+		}
+		if len(latches) == 0 {
+			continue
+		}
+		l := &Loop{
+			Header:  header,
+			Latches: latches,
+			preds:   preds,
+			set:     map[*ir.Block]bool{header: true},
+		}
+		// Walk predecessors backwards from the latches until the header is
+		// reached.
+		work := append([]*ir.Block(nil), latches...)
+		for len(work) > 0 {
+			block := work[len(work)-1]
+			work = work[:len(work)-1]
+			if l.set[block] {
+				continue
+			}
+			l.set[block] = true
+			work = append(work, preds[block]...)
+		}
+		l.Blocks = sortedBlocks(l.set, index)
+		loops = append(loops, l)
+	}
+	// Build loop nest. Loops with distinct headers are either disjoint or
+	// nested, and nested loops contain strictly fewer basic blocks than their
+	// enclosing loop.
+	sort.SliceStable(loops, func(i, j int) bool {
+		return len(loops[i].Blocks) < len(loops[j].Blocks)
+	})
+	for i, l := range loops {
+		for _, block := range l.Blocks {
+			if _, ok := info.loops[block]; !ok {
+				info.loops[block] = l
+			}
+		}
+		for _, outer := range loops[i+1:] {
+			if outer.set[l.Header] {
+				l.Parent = outer
+				break
+			}
+		}
+	}
+	// Loops in the order of their headers in the function.
+	sort.SliceStable(loops, func(i, j int) bool {
+		return index[loops[i].Header] < index[loops[j].Header]
+	})
+	for _, l := range loops {
+		if l.Parent == nil {
+			info.TopLevel = append(info.TopLevel, l)
+		} else {
+			l.Parent.Children = append(l.Parent.Children, l)
+		}
+	}
+	for _, l := range info.Loops() {
+		l.Depth = 1
+		if l.Parent != nil {
+			l.Depth = l.Parent.Depth + 1
+		}
+	}
+	info.Irreducible = irreducibleRegions(f, domTree, preds, index)
+	return info
+}
+
+// Loops returns the loops of the function in pre-order; i.e. each loop is
+// followed by its nested loops.
+func (info *Info) Loops() []*Loop {
+	var loops []*Loop
+	var visit func(ls []*Loop)
+	visit = func(ls []*Loop) {
+		for _, l := range ls {
+			loops = append(loops, l)
+			visit(l.Children)
+		}
+	}
+	visit(info.TopLevel)
+	return loops
+}
+
+// LoopFor returns the innermost loop containing the given basic block; or nil
+// if the basic block is not part of any loop.
+func (info *Info) LoopFor(block *ir.Block) *Loop {
+	return info.loops[block]
+}
+
+// Depth returns the loop nesting depth of the given basic block; or 0 if the
+// basic block is not part of any loop.
+func (info *Info) Depth(block *ir.Block) int {
+	if l := info.loops[block]; l != nil {
+		return l.Depth
+	}
+	return 0
+}
+
+// IsHeader reports whether the given basic block is the header of a loop.
+func (info *Info) IsHeader(block *ir.Block) bool {
+	l := info.loops[block]
+	return l != nil && l.Header == block
+}
+
+// IsIrreducible reports whether the given basic block is part of an
+// irreducible region.
+func (info *Info) IsIrreducible(block *ir.Block) bool {
+	for _, r := range info.Irreducible {
+		if r.Contains(block) {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns a human-readable representation of the loop forest, with one
+// line per loop and irreducible region.
+func (info *Info) String() string {
+	buf := &strings.Builder{}
+	for _, l := range info.Loops() {
+		fmt.Fprintf(buf, "%s%s\n", strings.Repeat("  ", l.Depth-1), l)
+	}
+	for _, r := range info.Irreducible {
+		fmt.Fprintf(buf, "%s\n", r)
+	}
+	return buf.String()
+}
+
+// --- [ Loop ] ----------------------------------------------------------------
+
+// Contains reports whether the given basic block is part of the loop.
+func (l *Loop) Contains(block *ir.Block) bool {
+	return l.set[block]
+}
+
+// ExitingBlocks returns the basic blocks of the loop which have successors
+// outside of the loop, in the order of the basic blocks in the function.
+func (l *Loop) ExitingBlocks() []*ir.Block {
+	var exiting []*ir.Block
+	for _, block := range l.Blocks {
+		for _, succ := range distinctSuccs(block) {
+			if !l.set[succ] {
+				exiting = append(exiting, block)
+				break
+			}
+		}
+	}
+	return exiting
+}
+
+// ExitBlocks returns the basic blocks outside of the loop which are successors
+// of basic blocks of the loop. Each exit basic block is listed once, in the
+// order in which they are first encountered as successors of the basic blocks
+// of the loop.
+func (l *Loop) ExitBlocks() []*ir.Block {
+	var exits []*ir.Block
+	seen := make(map[*ir.Block]bool)
+	for _, block := range l.Blocks {
+		for _, succ := range distinctSuccs(block) {
+			if !l.set[succ] && !seen[succ] {
+				seen[succ] = true
+				exits = append(exits, succ)
+			}
+		}
+	}
+	return exits
+}
+
+// Preheader returns the preheader of the loop; or nil if the loop has no
+// preheader. The preheader is the unique predecessor of the header outside of
+// the loop, provided that the header is its only successor.
+func (l *Loop) Preheader() *ir.Block {
+	var preheader *ir.Block
+	for _, pred := range l.preds[l.Header] {
+		if l.set[pred] {
+			continue
+		}
+		if preheader != nil {
+			return nil
+		}
+		preheader = pred
+	}
+	if preheader == nil || len(distinctSuccs(preheader)) != 1 {
+		return nil
+	}
+	return preheader
+}
+
+// String returns a human-readable representation of the loop.
+func (l *Loop) String() string {
+	return fmt.Sprintf("loop at depth %d with header %s: %s", l.Depth, l.Header.Ident(), blockList(l.Blocks))
+}
+
+// --- [ Region ] --------------------------------------------------------------
+
+// Contains reports whether the given basic block is part of the irreducible
+// region.
+func (r *Region) Contains(block *ir.Block) bool {
+	for _, b := range r.Blocks {
+		if b == block {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns a human-readable representation of the irreducible region.
+func (r *Region) String() string {
+	return fmt.Sprintf("irreducible region with entries %s: %s", blockList(r.Entries), blockList(r.Blocks))
+}
+
+// ### [ Helper functions ] ####################################################
+
+// irreducibleRegions returns the irreducible regions of the given function; the
+// non-trivial strongly connected components of the control flow graph of
+// reachable basic blocks, after removal of back edges.
+func irreducibleRegions(f *ir.Func, domTree *dom.Tree, preds map[*ir.Block][]*ir.Block, index map[*ir.Block]int) []*Region {
+	// Forward edges; control flow edges which are not back edges.
+	succs := func(block *ir.Block) []*ir.Block {
+		var ss []*ir.Block
+		for _, succ := range distinctSuccs(block) {
+			if _, ok := index[succ]; ok && !domTree.Dominates(succ, block) {
+				ss = append(ss, succ)
+			}
+		}
+		return ss
+	}
+	// Tarjan's strongly connected components algorithm.
+	var (
+		counter int
+		num     = make(map[*ir.Block]int)
+		low     = make(map[*ir.Block]int)
+		onStack = make(map[*ir.Block]bool)
+		stack   []*ir.Block
+		regions []*Region
+	)
+	var visit func(block *ir.Block)
+	visit = func(block *ir.Block) {
+		num[block] = counter
+		low[block] = counter
+		counter++
+		stack = append(stack, block)
+		onStack[block] = true
+		for _, succ := range succs(block) {
+			if _, ok := num[succ]; !ok {
+				visit(succ)
+				if low[succ] < low[block] {
+					low[block] = low[succ]
+				}
+			} else if onStack[succ] && num[succ] < low[block] {
+				low[block] = num[succ]
+			}
+		}
+		if low[block] != num[block] {
+			return
+		}
+		set := make(map[*ir.Block]bool)
+		for {
+			b := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[b] = false
+			set[b] = true
+			if b == block {
+				break
+			}
+		}
+		// Back edges have been removed, so single basic block components
+		// are never cyclic.
+		if len(set) < 2 {
+			return
+		}
+		r := &Region{Blocks: sortedBlocks(set, index)}
+		for _, b := range r.Blocks {
+			for _, pred := range preds[b] {
+				if !set[pred] {
+					r.Entries = append(r.Entries, b)
+					break
+				}
+			}
+		}
+		regions = append(regions, r)
+	}
+	for _, block := range f.Blocks {
+		if _, ok := num[block]; !ok && domTree.Reachable(block) {
+			visit(block)
+		}
+	}
+	sort.Slice(regions, func(i, j int) bool {
+		return index[regions[i].Blocks[0]] < index[regions[j].Blocks[0]]
+	})
+	return regions
+}
+
+// distinctSuccs returns the distinct successor basic blocks of the given basic
+// block.
+func distinctSuccs(block *ir.Block) []*ir.Block {
+	if block.Term == nil {
+		return nil
+	}
+	var succs []*ir.Block
+	seen := make(map[*ir.Block]bool)
+	for _, succ := range block.Term.Succs() {
+		if !seen[succ] {
+			seen[succ] = true
+			succs = append(succs, succ)
+		}
+	}
+	return succs
+}
+
+// sortedBlocks returns the basic blocks of the given set, in the order of the
+// basic blocks in the function.
+func sortedBlocks(set map[*ir.Block]bool, index map[*ir.Block]int) []*ir.Block {
+	blocks := make([]*ir.Block, 0, len(set))
+	for block := range set {
+		blocks = append(blocks, block)
+	}
+	sort.Slice(blocks, func(i, j int) bool {
+		return index[blocks[i]] < index[blocks[j]]
+	})
+	return blocks
+}
+
+// blockList returns a comma-separated list of the identifiers of the given
+// basic blocks.
+func blockList(blocks []*ir.Block) string {
+	names := make([]string, len(blocks))
+	for i, block := range blocks {
+		names[i] = block.Ident()
+	}
+	return strings.Join(names, ", ")
+}
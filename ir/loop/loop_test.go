@@ -0,0 +1,180 @@
+package loop_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/loop"
+)
+
+const src = `
+define void @nested(i1 %c) {
+entry:
+	br label %outer
+
+outer:
+	br label %inner
+
+inner:
+	br i1 %c, label %inner, label %outer.latch
+
+outer.latch:
+	br i1 %c, label %outer, label %exit
+
+exit:
+	ret void
+}
+
+define void @multi(i1 %c, i32 %x) {
+entry:
+	br i1 %c, label %a, label %loop
+
+a:
+	br label %loop
+
+loop:
+	switch i32 %x, label %loop [
+		i32 0, label %exit
+		i32 1, label %body
+	]
+
+body:
+	br i1 %c, label %loop, label %exit2
+
+exit:
+	ret void
+
+exit2:
+	unreachable
+}
+
+define void @irreducible(i1 %c) {
+entry:
+	br i1 %c, label %a, label %b
+
+a:
+	br i1 %c, label %b, label %exit
+
+b:
+	br label %a
+
+exit:
+	ret void
+
+dead:
+	br label %dead
+}
+`
+
+// blocks returns the names of the given basic blocks.
+func blocks(bs []*ir.Block) string {
+	var names []string
+	for _, b := range bs {
+		names = append(names, b.LocalName)
+	}
+	return strings.Join(names, ",")
+}
+
+// loopInfo specifies the expected properties of a loop.
+type loopInfo struct {
+	header, blocks, latches, exiting, exits, preheader string
+	depth                                              int
+}
+
+func TestLoops(t *testing.T) {
+	m, err := asm.ParseString("loop.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	golden := []struct {
+		// Function name.
+		f string
+		// Loops in pre-order.
+		loops []loopInfo
+		// Irreducible regions.
+		irreducible []string
+	}{
+		{
+			f: "nested",
+			loops: []loopInfo{
+				{header: "outer", blocks: "outer,inner,outer.latch", latches: "outer.latch", exiting: "outer.latch", exits: "exit", preheader: "entry", depth: 1},
+				{header: "inner", blocks: "inner", latches: "inner", exiting: "inner", exits: "outer.latch", preheader: "outer", depth: 2},
+			},
+		},
+		{
+			f: "multi",
+			loops: []loopInfo{
+				{header: "loop", blocks: "loop,body", latches: "loop,body", exiting: "loop,body", exits: "exit,exit2", preheader: "", depth: 1},
+			},
+		},
+		{
+			f:           "irreducible",
+			irreducible: []string{"a,b: a,b"},
+		},
+	}
+	for _, g := range golden {
+		f := findFunc(m, g.f)
+		info := loop.New(f)
+		loops := info.Loops()
+		if len(loops) != len(g.loops) {
+			t.Errorf("%s: number of loops mismatch; expected %d, got %d", g.f, len(g.loops), len(loops))
+			continue
+		}
+		for i, l := range loops {
+			want := g.loops[i]
+			var preheader string
+			if p := l.Preheader(); p != nil {
+				preheader = p.LocalName
+			}
+			got := []string{l.Header.LocalName, blocks(l.Blocks), blocks(l.Latches), blocks(l.ExitingBlocks()), blocks(l.ExitBlocks()), preheader}
+			exp := []string{want.header, want.blocks, want.latches, want.exiting, want.exits, want.preheader}
+			if strings.Join(got, "; ") != strings.Join(exp, "; ") {
+				t.Errorf("%s: loop %d mismatch; expected %q, got %q", g.f, i, exp, got)
+			}
+			if l.Depth != want.depth {
+				t.Errorf("%s: loop %d depth mismatch; expected %d, got %d", g.f, i, want.depth, l.Depth)
+			}
+			if info.LoopFor(l.Header) != l || !info.IsHeader(l.Header) {
+				t.Errorf("%s: loop %d not innermost loop of its header", g.f, i)
+			}
+		}
+		var irreducible []string
+		for _, r := range info.Irreducible {
+			irreducible = append(irreducible, blocks(r.Entries)+": "+blocks(r.Blocks))
+		}
+		if strings.Join(irreducible, "\n") != strings.Join(g.irreducible, "\n") {
+			t.Errorf("%s: irreducible regions mismatch; expected %q, got %q", g.f, g.irreducible, irreducible)
+		}
+	}
+}
+
+func TestDepth(t *testing.T) {
+	m, err := asm.ParseString("loop.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	f := findFunc(m, "nested")
+	info := loop.New(f)
+	want := map[string]int{"entry": 0, "outer": 1, "inner": 2, "outer.latch": 1, "exit": 0}
+	for _, block := range f.Blocks {
+		if got := info.Depth(block); got != want[block.LocalName] {
+			t.Errorf("%s: depth mismatch; expected %d, got %d", block.LocalName, want[block.LocalName], got)
+		}
+	}
+	const wantStr = "loop at depth 1 with header %outer: %outer, %inner, %outer.latch\n  loop at depth 2 with header %inner: %inner\n"
+	if got := info.String(); got != wantStr {
+		t.Errorf("string mismatch; expected %q, got %q", wantStr, got)
+	}
+}
+
+// findFunc returns the function with the given name in m.
+func findFunc(m *ir.Module, name string) *ir.Func {
+	for _, f := range m.Funcs {
+		if f.Name() == name {
+			return f
+		}
+	}
+	panic("unable to locate function " + name)
+}
@@ -158,6 +158,13 @@ func (m *Attachment) String() string {
 	return fmt.Sprintf("%s %s", enc.MetadataName(m.Name), m.Node.Ident())
 }
 
+// Attacher is a value with metadata attachments; e.g. a global variable,
+// function or instruction.
+type Attacher interface {
+	// MDAttachments returns the metadata attachments of the value.
+	MDAttachments() []*Attachment
+}
+
 // --- [ Integer literals ] -----------------------------------------------------
 
 // IntLit is an integer literal.
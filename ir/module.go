@@ -56,6 +56,9 @@ type Module struct {
 
 	// mu prevents races on AssignGlobalIDs and AssignMetadataIDs.
 	mu sync.Mutex
+	// uses caches the use lists of values used within the module; computed on
+	// demand by Users.
+	uses map[value.Value][]*Use
 }
 
 // NewModule returns a new LLVM IR module.
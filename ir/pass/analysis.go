@@ -0,0 +1,170 @@
+package pass
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/callgraph"
+	"github.com/llir/llvm/ir/datalayout"
+	"github.com/llir/llvm/ir/dom"
+	"github.com/llir/llvm/ir/loop"
+)
+
+// AnalysisManager caches the analyses of a module and its functions. Analyses
+// are computed on demand, and cached until invalidated by a pass which does
+// not preserve them.
+type AnalysisManager struct {
+	// Module of the analyses.
+	m *ir.Module
+	// Cached function analyses.
+	funcs map[*ir.Func]*funcAnalyses
+	// (optional) Cached call graph of the module; or nil if not computed.
+	callGraph *callgraph.Graph
+	// (optional) Cached data layout of the module; or nil if not computed.
+	dataLayout *datalayout.DataLayout
+	// (optional) Error encountered while computing an analysis; reported by
+	// the pass manager after the pass requesting the analysis.
+	err error
+}
+
+// funcAnalyses holds the cached analyses of a function.
+type funcAnalyses struct {
+	// (optional) Dominator tree; or nil if not computed.
+	domTree *dom.Tree
+	// (optional) Post-dominator tree; or nil if not computed.
+	postDomTree *dom.Tree
+	// (optional) Natural loops; or nil if not computed.
+	loops *loop.Info
+}
+
+// NewAnalysisManager returns a new analysis manager for the given module.
+func NewAnalysisManager(m *ir.Module) *AnalysisManager {
+	return &AnalysisManager{
+		m:     m,
+		funcs: make(map[*ir.Func]*funcAnalyses),
+	}
+}
+
+// Module returns the module of the analysis manager.
+func (am *AnalysisManager) Module() *ir.Module {
+	return am.m
+}
+
+// DataLayout returns the data layout of the module. The default data layout is
+// returned if the module has no data layout. An error is returned if the data
+// layout of the module is invalid; the error is also reported by the pass
+// manager after the current pass.
+func (am *AnalysisManager) DataLayout() (*datalayout.DataLayout, error) {
+	if am.dataLayout == nil {
+		dl, err := datalayout.Parse(am.m.DataLayout)
+		if err != nil {
+			if am.err == nil {
+				am.err = err
+			}
+			return nil, err
+		}
+		am.dataLayout = dl
+	}
+	return am.dataLayout, nil
+}
+
+// DomTree returns the dominator tree of the given function definition.
+func (am *AnalysisManager) DomTree(f *ir.Func) *dom.Tree {
+	fa := am.funcAnalyses(f)
+	if fa.domTree == nil {
+		fa.domTree = dom.New(f)
+	}
+	return fa.domTree
+}
+
+// PostDomTree returns the post-dominator tree of the given function
+// definition.
+func (am *AnalysisManager) PostDomTree(f *ir.Func) *dom.Tree {
+	fa := am.funcAnalyses(f)
+	if fa.postDomTree == nil {
+		fa.postDomTree = dom.NewPost(f)
+	}
+	return fa.postDomTree
+}
+
+// Loops returns the natural loops of the given function definition.
+func (am *AnalysisManager) Loops(f *ir.Func) *loop.Info {
+	fa := am.funcAnalyses(f)
+	if fa.loops == nil {
+		fa.loops = loop.New(f)
+	}
+	return fa.loops
+}
+
+// CallGraph returns the call graph of the module.
+func (am *AnalysisManager) CallGraph() *callgraph.Graph {
+	if am.callGraph == nil {
+		am.callGraph = callgraph.New(am.m)
+	}
+	return am.callGraph
+}
+
+// InvalidateFunc invalidates the cached analyses of the given function not
+// present in the set of preserved analyses. The call graph is invalidated
+// unless preserved.
+func (am *AnalysisManager) InvalidateFunc(f *ir.Func, preserved Analyses) {
+	if fa, ok := am.funcs[f]; ok {
+		fa.invalidate(preserved)
+	}
+	if preserved&CallGraph == 0 {
+		am.callGraph = nil
+	}
+}
+
+// Invalidate invalidates the cached analyses of the module and of its
+// functions not present in the set of preserved analyses. The cached analyses
+// of functions no longer part of the module are dropped.
+func (am *AnalysisManager) Invalidate(preserved Analyses) {
+	live := make(map[*ir.Func]bool)
+	for _, f := range am.m.Funcs {
+		live[f] = true
+	}
+	for f, fa := range am.funcs {
+		if !live[f] {
+			delete(am.funcs, f)
+			continue
+		}
+		fa.invalidate(preserved)
+	}
+	if preserved&CallGraph == 0 {
+		am.callGraph = nil
+	}
+}
+
+// ### [ Helper functions ] ####################################################
+
+// funcAnalyses returns the cached analyses of the given function.
+func (am *AnalysisManager) funcAnalyses(f *ir.Func) *funcAnalyses {
+	fa, ok := am.funcs[f]
+	if !ok {
+		fa = &funcAnalyses{}
+		am.funcs[f] = fa
+	}
+	return fa
+}
+
+// invalidate invalidates the analyses not present in the set of preserved
+// analyses. The instruction positions of preserved dominator trees are
+// updated, as passes preserving the control flow graph may still insert,
+// remove or reorder instructions.
+func (fa *funcAnalyses) invalidate(preserved Analyses) {
+	if preserved == AllAnalyses {
+		return
+	}
+	if preserved&DomTree == 0 {
+		fa.domTree = nil
+	} else if fa.domTree != nil {
+		fa.domTree.UpdatePositions()
+	}
+	if preserved&PostDomTree == 0 {
+		fa.postDomTree = nil
+	} else if fa.postDomTree != nil {
+		fa.postDomTree.UpdatePositions()
+	}
+	if preserved&LoopInfo == 0 {
+		fa.loops = nil
+	}
+}
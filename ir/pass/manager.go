@@ -0,0 +1,101 @@
+package pass
+
+import (
+	"fmt"
+
+	"github.com/llir/llvm/ir"
+	"github.com/pkg/errors"
+)
+
+// Manager is a pass manager, which runs a pipeline of passes over modules.
+type Manager struct {
+	// Pipeline of passes, in order of execution.
+	Passes []Pass
+
+	// extra.
+
+	// (optional) Function invoked before running each pass.
+	BeforePass func(p Pass, m *ir.Module)
+	// (optional) Function invoked after running each pass; e.g. to verify or
+	// print the module. A non-nil error stops the pipeline, and is returned by
+	// Run.
+	AfterPass func(p Pass, m *ir.Module) error
+}
+
+// NewManager returns a new pass manager based on the given pipeline of
+// passes.
+func NewManager(passes ...Pass) *Manager {
+	return &Manager{Passes: passes}
+}
+
+// NewPipeline returns a new pass manager based on the given textual pipeline
+// specification (e.g. "mem2reg,dce,simplifycfg").
+func NewPipeline(spec string) (*Manager, error) {
+	passes, err := Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	return NewManager(passes...), nil
+}
+
+// Add appends the given passes to the pipeline of the pass manager.
+func (pm *Manager) Add(passes ...Pass) {
+	pm.Passes = append(pm.Passes, passes...)
+}
+
+// Run runs the pipeline of passes over the given module. Analyses are cached
+// for the duration of the run. An error is returned if an analysis requested by
+// a pass could not be computed (e.g. an invalid data layout).
+func (pm *Manager) Run(m *ir.Module) error {
+	am := NewAnalysisManager(m)
+	for _, p := range pm.Passes {
+		if pm.BeforePass != nil {
+			pm.BeforePass(p, m)
+		}
+		runPass(p, m, am)
+		if am.err != nil {
+			return errors.Wrapf(am.err, "pass %q", p.Name())
+		}
+		if pm.AfterPass != nil {
+			if err := pm.AfterPass(p, m); err != nil {
+				return errors.Wrapf(err, "after pass %q", p.Name())
+			}
+		}
+	}
+	return nil
+}
+
+// ### [ Helper functions ] ####################################################
+
+// runPass runs the given pass over the module, and invalidates the analyses not
+// preserved by the pass.
+func runPass(p Pass, m *ir.Module, am *AnalysisManager) {
+	switch p := p.(type) {
+	case ModulePass:
+		preserved := p.RunOnModule(m, am)
+		am.Invalidate(preserved)
+	case FuncPass:
+		// Iterate over a copy of the function list, as passes may add functions
+		// to the module.
+		for _, f := range append([]*ir.Func(nil), m.Funcs...) {
+			if len(f.Blocks) == 0 {
+				continue
+			}
+			preserved := p.RunOnFunc(f, am)
+			am.InvalidateFunc(f, preserved)
+		}
+	case BlockPass:
+		for _, f := range append([]*ir.Func(nil), m.Funcs...) {
+			if len(f.Blocks) == 0 {
+				continue
+			}
+			preserved := AllAnalyses
+			for _, block := range append([]*ir.Block(nil), f.Blocks...) {
+				preserved &= p.RunOnBlock(block, am)
+			}
+			am.InvalidateFunc(f, preserved)
+		}
+	default:
+		panic(fmt.Errorf("support for pass %T not yet implemented", p))
+	}
+}
@@ -0,0 +1,179 @@
+// Package pass implements a pass manager for LLVM IR modules.
+//
+// A pass is a module pass, function pass or basic block pass, which transforms
+// (or analyses) the IR in place and reports the analyses it preserves. The pass
+// manager runs pipelines of passes over a module, caching the analyses
+// computed by passes (e.g. dominator trees, loops and the call graph) and
+// invalidating cached analyses not preserved by each pass.
+//
+// Pipelines are specified textually as a comma-separated list of registered
+// pass names, optionally followed by pass parameters within angle brackets;
+// e.g.
+//
+//	mem2reg,dce,simplifycfg
+package pass
+
+import (
+	"strings"
+
+	"github.com/llir/llvm/ir"
+)
+
+// === [ Passes ] ==============================================================
+
+// Pass is a pass over an LLVM IR module.
+//
+// A pass has one of the following underlying types.
+//
+//   - pass.ModulePass
+//   - pass.FuncPass
+//   - pass.BlockPass
+type Pass interface {
+	// Name returns the name of the pass.
+	Name() string
+}
+
+// ModulePass is a pass over a module.
+type ModulePass interface {
+	Pass
+	// RunOnModule runs the pass on the given module, and returns the analyses
+	// preserved by the pass.
+	RunOnModule(m *ir.Module, am *AnalysisManager) Analyses
+}
+
+// FuncPass is a pass over each function definition of a module.
+type FuncPass interface {
+	Pass
+	// RunOnFunc runs the pass on the given function definition, and returns the
+	// analyses preserved by the pass.
+	RunOnFunc(f *ir.Func, am *AnalysisManager) Analyses
+}
+
+// BlockPass is a pass over each basic block of the function definitions of a
+// module. Block passes must not add or remove basic blocks.
+type BlockPass interface {
+	Pass
+	// RunOnBlock runs the pass on the given basic block, and returns the
+	// analyses preserved by the pass.
+	RunOnBlock(block *ir.Block, am *AnalysisManager) Analyses
+}
+
+// NewModulePass returns a new module pass with the given name, which runs the
+// given function on each module.
+func NewModulePass(name string, run func(m *ir.Module, am *AnalysisManager) Analyses) ModulePass {
+	return &modulePass{name: name, run: run}
+}
+
+// NewFuncPass returns a new function pass with the given name, which runs the
+// given function on each function definition.
+func NewFuncPass(name string, run func(f *ir.Func, am *AnalysisManager) Analyses) FuncPass {
+	return &funcPass{name: name, run: run}
+}
+
+// NewBlockPass returns a new basic block pass with the given name, which runs
+// the given function on each basic block.
+func NewBlockPass(name string, run func(block *ir.Block, am *AnalysisManager) Analyses) BlockPass {
+	return &blockPass{name: name, run: run}
+}
+
+// --- [ Analyses ] ------------------------------------------------------------
+
+// Analyses is a set of analyses cached by the pass manager.
+type Analyses uint8
+
+// Analyses.
+const (
+	// Dominator tree of a function (*dom.Tree).
+	DomTree Analyses = 1 << iota
+	// Post-dominator tree of a function (*dom.Tree).
+	PostDomTree
+	// Natural loops of a function (*loop.Info).
+	LoopInfo
+	// Call graph of the module (*callgraph.Graph).
+	CallGraph
+
+	// No analyses; reported by passes which may modify anything.
+	NoAnalyses Analyses = 0
+	// Analyses of the control flow graph of functions; preserved by passes
+	// which do not add, remove or redirect basic blocks.
+	CFGAnalyses = DomTree | PostDomTree | LoopInfo
+	// All analyses; reported by passes which do not modify the IR.
+	AllAnalyses = CFGAnalyses | CallGraph
+)
+
+// String returns the string representation of the set of analyses.
+func (as Analyses) String() string {
+	if as == NoAnalyses {
+		return "none"
+	}
+	var names []string
+	if as&DomTree != 0 {
+		names = append(names, "domtree")
+	}
+	if as&PostDomTree != 0 {
+		names = append(names, "postdomtree")
+	}
+	if as&LoopInfo != 0 {
+		names = append(names, "loops")
+	}
+	if as&CallGraph != 0 {
+		names = append(names, "callgraph")
+	}
+	return strings.Join(names, "|")
+}
+
+// ### [ Helper functions ] ####################################################
+
+// modulePass is a module pass defined by a function.
+type modulePass struct {
+	// Pass name.
+	name string
+	// Pass function.
+	run func(m *ir.Module, am *AnalysisManager) Analyses
+}
+
+// Name returns the name of the pass.
+func (p *modulePass) Name() string {
+	return p.name
+}
+
+// RunOnModule runs the pass on the given module.
+func (p *modulePass) RunOnModule(m *ir.Module, am *AnalysisManager) Analyses {
+	return p.run(m, am)
+}
+
+// funcPass is a function pass defined by a function.
+type funcPass struct {
+	// Pass name.
+	name string
+	// Pass function.
+	run func(f *ir.Func, am *AnalysisManager) Analyses
+}
+
+// Name returns the name of the pass.
+func (p *funcPass) Name() string {
+	return p.name
+}
+
+// RunOnFunc runs the pass on the given function definition.
+func (p *funcPass) RunOnFunc(f *ir.Func, am *AnalysisManager) Analyses {
+	return p.run(f, am)
+}
+
+// blockPass is a basic block pass defined by a function.
+type blockPass struct {
+	// Pass name.
+	name string
+	// Pass function.
+	run func(block *ir.Block, am *AnalysisManager) Analyses
+}
+
+// Name returns the name of the pass.
+func (p *blockPass) Name() string {
+	return p.name
+}
+
+// RunOnBlock runs the pass on the given basic block.
+func (p *blockPass) RunOnBlock(block *ir.Block, am *AnalysisManager) Analyses {
+	return p.run(block, am)
+}
@@ -0,0 +1,268 @@
+package pass_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/callgraph"
+	"github.com/llir/llvm/ir/dom"
+	"github.com/llir/llvm/ir/pass"
+	// Register transformation passes.
+	_ "github.com/llir/llvm/ir/transform"
+)
+
+func TestParse(t *testing.T) {
+	golden := []struct {
+		spec string
+		// Expected pass names; or nil if invalid.
+		want []string
+	}{
+		{spec: "", want: []string{}},
+		{spec: "mem2reg", want: []string{"mem2reg"}},
+		{spec: " mem2reg , mem2reg ", want: []string{"mem2reg", "mem2reg"}},
+		{spec: "mem2reg,unknown"},
+		{spec: "mem2reg,,mem2reg"},
+		{spec: "mem2reg<x=1>"},
+		{spec: "mem2reg<"},
+		{spec: "mem2reg>"},
+		{spec: "mem2reg<>x"},
+		{spec: "early-cse<no-loads>,dce", want: []string{"early-cse", "dce"}},
+		{spec: "early-cse<x>"},
+		{spec: "mem2reg,dce,inline<threshold=100>", want: []string{"mem2reg", "dce", "inline"}},
+		{spec: "inline<threshold=x>"},
+	}
+	for _, g := range golden {
+		passes, err := pass.Parse(g.spec)
+		if g.want == nil {
+			if err == nil {
+				t.Errorf("%q: expected error, got nil", g.spec)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unable to parse pipeline; %v", g.spec, err)
+			continue
+		}
+		got := make([]string, len(passes))
+		for i, p := range passes {
+			got[i] = p.Name()
+		}
+		if strings.Join(got, ",") != strings.Join(g.want, ",") {
+			t.Errorf("%q: pass names mismatch; expected %q, got %q", g.spec, g.want, got)
+		}
+	}
+}
+
+func TestManager(t *testing.T) {
+	const src = `
+define i32 @f(i32 %x) {
+entry:
+	%p = alloca i32
+	store i32 %x, ptr %p
+	%v = load i32, ptr %p
+	ret i32 %v
+}
+
+define void @g() {
+entry:
+	ret void
+}
+
+declare void @h()
+`
+	m, err := asm.ParseString("pass.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	f := m.Funcs[0]
+	// Record the analyses observed by each pass, to check caching and
+	// invalidation.
+	var (
+		domTrees   []*dom.Tree
+		callGraphs []*callgraph.Graph
+		visited    []string
+	)
+	observe := func(name string, preserved pass.Analyses) pass.Pass {
+		return pass.NewFuncPass(name, func(fn *ir.Func, am *pass.AnalysisManager) pass.Analyses {
+			visited = append(visited, name+":"+fn.Name())
+			if fn == f {
+				domTrees = append(domTrees, am.DomTree(fn))
+				callGraphs = append(callGraphs, am.CallGraph())
+			}
+			return preserved
+		})
+	}
+	var blocks int
+	countBlocks := pass.NewBlockPass("blocks", func(block *ir.Block, am *pass.AnalysisManager) pass.Analyses {
+		blocks++
+		return pass.AllAnalyses
+	})
+	var modules int
+	countModules := pass.NewModulePass("modules", func(m *ir.Module, am *pass.AnalysisManager) pass.Analyses {
+		modules++
+		return pass.CFGAnalyses
+	})
+	mem2reg, err := pass.Parse("mem2reg")
+	if err != nil {
+		t.Fatalf("unable to parse pipeline; %v", err)
+	}
+	pm := pass.NewManager(observe("a", pass.AllAnalyses), observe("b", pass.CFGAnalyses), observe("c", pass.AllAnalyses))
+	pm.Add(countBlocks, countModules, observe("d", pass.NoAnalyses))
+	pm.Add(mem2reg...)
+	pm.Add(observe("e", pass.AllAnalyses))
+	if err := pm.Run(m); err != nil {
+		t.Fatalf("unable to run pipeline; %+v", err)
+	}
+	// Function passes run on function definitions only.
+	wantVisited := "a:f a:g b:f b:g c:f c:g d:f d:g e:f e:g"
+	if got := strings.Join(visited, " "); got != wantVisited {
+		t.Errorf("visited functions mismatch; expected %q, got %q", wantVisited, got)
+	}
+	if blocks != 2 || modules != 1 {
+		t.Errorf("number of block and module pass runs mismatch; expected 2 and 1, got %d and %d", blocks, modules)
+	}
+	// a, b, c and d share the dominator tree, as b and the module pass preserve
+	// CFG analyses; d preserves no analyses.
+	for i := 1; i < 4; i++ {
+		if domTrees[i] != domTrees[0] {
+			t.Errorf("dominator tree %d not cached", i)
+		}
+	}
+	if domTrees[4] == domTrees[3] {
+		t.Errorf("dominator tree not invalidated")
+	}
+	// The call graph is invalidated by b (after its run on @f), by the module
+	// pass and by d.
+	if callGraphs[0] != callGraphs[1] {
+		t.Errorf("call graph not cached between a and b")
+	}
+	if callGraphs[1] == callGraphs[2] || callGraphs[2] == callGraphs[3] || callGraphs[3] == callGraphs[4] {
+		t.Errorf("call graph not invalidated")
+	}
+	if len(f.Blocks[0].Insts) != 0 {
+		t.Errorf("alloca not promoted by mem2reg; got\n%s", f.LLString())
+	}
+}
+
+func TestManagerDomTreePositions(t *testing.T) {
+	const src = `
+define i32 @f(i32 %x) {
+entry:
+	ret i32 %x
+}
+`
+	m, err := asm.ParseString("pass.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	var tree *dom.Tree
+	var add *ir.InstAdd
+	// Insert an instruction without changing the control flow graph.
+	insert := pass.NewFuncPass("insert", func(f *ir.Func, am *pass.AnalysisManager) pass.Analyses {
+		tree = am.DomTree(f)
+		entry := f.Blocks[0]
+		add = ir.NewAdd(f.Params[0], f.Params[0])
+		entry.Insts = append(entry.Insts, add)
+		entry.Term.(*ir.TermRet).X = add
+		f.InvalidateUses()
+		return pass.CFGAnalyses
+	})
+	// Query the dominance of the inserted instruction using the preserved
+	// dominator tree.
+	var dominates bool
+	query := pass.NewFuncPass("query", func(f *ir.Func, am *pass.AnalysisManager) pass.Analyses {
+		if am.DomTree(f) != tree {
+			t.Errorf("dominator tree not preserved")
+		}
+		dominates = am.DomTree(f).DominatesInst(add, f.Blocks[0].Term)
+		return pass.AllAnalyses
+	})
+	if err := pass.NewManager(insert, query).Run(m); err != nil {
+		t.Fatalf("unable to run pipeline; %+v", err)
+	}
+	if !dominates {
+		t.Errorf("expected %s to dominate terminator", add.Ident())
+	}
+}
+
+func TestManagerHooks(t *testing.T) {
+	const src = `
+define i32 @f(i32 %x) {
+entry:
+	%p = alloca i32
+	store i32 %x, ptr %p
+	%v = load i32, ptr %p
+	ret i32 %v
+}
+`
+	m, err := asm.ParseString("pass.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	pass.ResetStatistics()
+	pm, err := pass.NewPipeline("mem2reg,mem2reg,mem2reg")
+	if err != nil {
+		t.Fatalf("unable to parse pipeline; %v", err)
+	}
+	var events []string
+	pm.BeforePass = func(p pass.Pass, m *ir.Module) {
+		events = append(events, "before "+p.Name())
+	}
+	pm.AfterPass = func(p pass.Pass, m *ir.Module) error {
+		events = append(events, "after "+p.Name())
+		if len(events) == 4 {
+			return errors.New("stop")
+		}
+		return nil
+	}
+	// The pipeline is stopped by the error of the after pass hook.
+	if err := pm.Run(m); err == nil {
+		t.Errorf("expected error from after pass hook, got nil")
+	}
+	want := "before mem2reg,after mem2reg,before mem2reg,after mem2reg"
+	if got := strings.Join(events, ","); got != want {
+		t.Errorf("hook events mismatch; expected %q, got %q", want, got)
+	}
+	stats := pass.Statistics()
+	if len(stats) != 1 || stats[0].Pass != "mem2reg" || stats[0].Value() != 1 {
+		t.Errorf("statistics mismatch; expected 1 promoted alloca, got %v", stats)
+	}
+	pass.ResetStatistics()
+	if stats := pass.Statistics(); len(stats) != 0 {
+		t.Errorf("statistics not reset; got %v", stats)
+	}
+}
+
+func TestManagerDataLayout(t *testing.T) {
+	const src = `
+target datalayout = "e-x"
+
+define i32 @f() {
+entry:
+	%p = alloca { i32, i32 }
+	%q = getelementptr { i32, i32 }, ptr %p, i32 0, i32 1
+	store i32 1, ptr %q
+	%v = load i32, ptr %q
+	ret i32 %v
+}
+`
+	m, err := asm.ParseString("pass.ll", src)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	before := m.String()
+	pm, err := pass.NewPipeline("sroa")
+	if err != nil {
+		t.Fatalf("unable to parse pipeline; %v", err)
+	}
+	// The invalid data layout is reported, and the module left unchanged.
+	if err := pm.Run(m); err == nil {
+		t.Errorf("expected error for invalid data layout, got nil")
+	}
+	if got := m.String(); got != before {
+		t.Errorf("module modified; expected\n%s\ngot\n%s", before, got)
+	}
+}
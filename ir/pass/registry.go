@@ -0,0 +1,125 @@
+package pass
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Constructor returns a new pass based on the given pass parameters; i.e. the
+// text within angle brackets following the pass name of a pipeline
+// specification, or the empty string if not present.
+type Constructor func(params string) (Pass, error)
+
+// registry maps from pass name to pass constructor.
+var registry = make(map[string]Constructor)
+
+// Register registers the given pass constructor under the given pass name, for
+// use in pipeline specifications. Register panics if a pass of the same name
+// is already registered.
+//
+// The transformation passes of package transform (e.g. "mem2reg" and "dce")
+// are registered when the package is imported.
+func Register(name string, new Constructor) {
+	if _, ok := registry[name]; ok {
+		panic(fmt.Errorf("pass %q already registered", name))
+	}
+	registry[name] = new
+}
+
+// NoParams returns a pass constructor for passes without parameters.
+func NoParams(new func() Pass) Constructor {
+	return func(params string) (Pass, error) {
+		if params != "" {
+			return nil, errors.Errorf("unexpected parameters %q", params)
+		}
+		return new(), nil
+	}
+}
+
+// Names returns the names of the registered passes in alphabetical order.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Parse parses the given textual pipeline specification; a comma-separated
+// list of registered pass names, each optionally followed by pass parameters
+// within angle brackets (e.g. "mem2reg,dce,inline<threshold=100>").
+func Parse(spec string) ([]Pass, error) {
+	var passes []Pass
+	elems, err := splitSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	for _, elem := range elems {
+		name, params := elem, ""
+		if pos := strings.IndexByte(elem, '<'); pos != -1 {
+			if !strings.HasSuffix(elem, ">") {
+				return nil, errors.Errorf("invalid pass %q in pipeline %q; missing '>'", elem, spec)
+			}
+			name, params = elem[:pos], elem[pos+1:len(elem)-1]
+		}
+		new, ok := registry[name]
+		if !ok {
+			return nil, errors.Errorf("unknown pass %q in pipeline %q", name, spec)
+		}
+		p, err := new(params)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid parameters of pass %q", name)
+		}
+		passes = append(passes, p)
+	}
+	return passes, nil
+}
+
+// ### [ Helper functions ] ####################################################
+
+// splitSpec splits the given pipeline specification into comma-separated pass
+// elements, ignoring commas within angle brackets.
+func splitSpec(spec string) ([]string, error) {
+	var elems []string
+	depth, start := 0, 0
+	add := func(end int) error {
+		elem := strings.TrimSpace(spec[start:end])
+		if elem == "" {
+			return errors.Errorf("empty pass in pipeline %q", spec)
+		}
+		elems = append(elems, elem)
+		return nil
+	}
+	for i := 0; i < len(spec); i++ {
+		switch spec[i] {
+		case '<':
+			depth++
+		case '>':
+			depth--
+			if depth < 0 {
+				return nil, errors.Errorf("unbalanced '>' in pipeline %q", spec)
+			}
+		case ',':
+			if depth == 0 {
+				if err := add(i); err != nil {
+					return nil, err
+				}
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, errors.Errorf("unbalanced '<' in pipeline %q", spec)
+	}
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+	if err := add(len(spec)); err != nil {
+		return nil, err
+	}
+	return elems, nil
+}
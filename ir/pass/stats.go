@@ -0,0 +1,78 @@
+package pass
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Statistic is a named counter of a pass; e.g. the number of alloca
+// instructions promoted to SSA registers. Statistics are accumulated across
+// pass manager runs, until reset by ResetStatistics.
+type Statistic struct {
+	// Name of the pass reporting the statistic.
+	Pass string
+	// Name of the statistic.
+	Name string
+	// Description of the statistic.
+	Desc string
+
+	// Value of the counter; accessed atomically.
+	n int64
+}
+
+var (
+	// statsMu protects stats.
+	statsMu sync.Mutex
+	// Registered statistics.
+	stats []*Statistic
+)
+
+// NewStatistic returns a new registered statistic of the given pass, based on
+// the given statistic name and description.
+func NewStatistic(pass, name, desc string) *Statistic {
+	s := &Statistic{Pass: pass, Name: name, Desc: desc}
+	statsMu.Lock()
+	stats = append(stats, s)
+	statsMu.Unlock()
+	return s
+}
+
+// Add adds n to the value of the statistic.
+func (s *Statistic) Add(n int) {
+	atomic.AddInt64(&s.n, int64(n))
+}
+
+// Value returns the value of the statistic.
+func (s *Statistic) Value() int64 {
+	return atomic.LoadInt64(&s.n)
+}
+
+// Statistics returns the registered statistics with a non-zero value, sorted
+// by pass name and statistic name.
+func Statistics() []*Statistic {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	var ss []*Statistic
+	for _, s := range stats {
+		if s.Value() != 0 {
+			ss = append(ss, s)
+		}
+	}
+	sort.SliceStable(ss, func(i, j int) bool {
+		if ss[i].Pass != ss[j].Pass {
+			return ss[i].Pass < ss[j].Pass
+		}
+		return ss[i].Name < ss[j].Name
+	})
+	return ss
+}
+
+// ResetStatistics resets the value of all registered statistics to zero.
+func ResetStatistics() {
+	statsMu.Lock()
+	defer statsMu.Unlock()
+	for _, s := range stats {
+		atomic.StoreInt64(&s.n, 0)
+	}
+}
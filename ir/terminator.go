@@ -374,6 +374,10 @@ type TermInvoke struct {
 
 	// Type of result produced by the terminator.
 	Typ types.Type
+	// (optional) Function signature of the invokee; used instead of the element
+	// type of the invokee pointer type if present (e.g. for invokees of opaque
+	// pointer type).
+	FuncType *types.FuncType
 	// Successor basic blocks of the terminator.
 	Successors []*Block
 	// (optional) Calling convention; zero if not present.
@@ -492,6 +496,15 @@ func (term *TermInvoke) LLString() string {
 
 // Sig returns the function signature of the invokee.
 func (term *TermInvoke) Sig() *types.FuncType {
+	// Use explicit function signature if present.
+	if term.FuncType != nil {
+		return term.FuncType
+	}
+	// Use function signature of direct callee, as the type of functions may be
+	// opaque pointer types.
+	if f, ok := term.Invokee.(*Func); ok {
+		return f.Sig
+	}
 	t, ok := term.Invokee.Type().(*types.PointerType)
 	if !ok {
 		panic(fmt.Errorf("invalid invokee type; expected *types.PointerType, got %T", term.Invokee.Type()))
@@ -530,6 +543,10 @@ type TermCallBr struct {
 
 	// Type of result produced by the terminator.
 	Typ types.Type
+	// (optional) Function signature of the callee; used instead of the element
+	// type of the callee pointer type if present (e.g. for callees of opaque
+	// pointer type).
+	FuncType *types.FuncType
 	// Successor basic blocks of the terminator.
 	Successors []*Block
 	// (optional) Calling convention; zero if not present.
@@ -666,6 +683,15 @@ func (term *TermCallBr) LLString() string {
 
 // Sig returns the function signature of the callee.
 func (term *TermCallBr) Sig() *types.FuncType {
+	// Use explicit function signature if present.
+	if term.FuncType != nil {
+		return term.FuncType
+	}
+	// Use function signature of direct callee, as the type of functions may be
+	// opaque pointer types.
+	if f, ok := term.Callee.(*Func); ok {
+		return f.Sig
+	}
 	t, ok := term.Callee.Type().(*types.PointerType)
 	if !ok {
 		panic(fmt.Errorf("invalid callee type; expected *types.PointerType, got %T", term.Callee.Type()))
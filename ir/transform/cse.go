@@ -0,0 +1,455 @@
+package transform
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/dom"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/value"
+)
+
+// === [ Common subexpression elimination ] ====================================
+
+// EarlyCSE eliminates common subexpressions of the given function, and returns
+// the number of eliminated instructions.
+//
+// The basic blocks of the function are visited in dominator tree order,
+// replacing pure instructions (e.g. binary, bitwise and conversion
+// instructions, getelementptr, icmp and fcmp) by identical instructions
+// available along the dominator path. Operands of commutative instructions are
+// compared in either order, and comparisons with swapped operands and
+// predicates are considered identical. Instructions differing only in
+// poison-generating flags (overflow flags, exact, inbounds and fast-math flags)
+// are considered identical, and the flags of the replacing instruction are set
+// to the intersection of the flags of both instructions.
+//
+// If loads is set, non-volatile loads are also replaced by the value of an
+// available load from, or store to, the same address, if no instruction which
+// may write to memory is executed in between. Basic blocks with multiple
+// predecessors conservatively start with no available loads.
+//
+// domTree is the dominator tree of the function.
+func EarlyCSE(f *ir.Func, domTree *dom.Tree, loads bool) int {
+	if len(f.Blocks) == 0 {
+		return 0
+	}
+	// Operands are identified by name when comparing instructions.
+	f.ResetIDs()
+	if err := f.AssignIDs(); err != nil {
+		return 0
+	}
+	c := &cse{
+		f:       f,
+		domTree: domTree,
+		loads:   loads,
+		npreds:  make(map[*ir.Block]int),
+		exprs:   make(map[string]ir.Instruction),
+		mem:     make(map[string]availValue),
+		repl:    make(map[value.Value]value.Value),
+		dead:    make(map[ir.Instruction]bool),
+	}
+	for _, block := range f.Blocks {
+		for _, succ := range block.Term.Succs() {
+			c.npreds[succ]++
+		}
+	}
+	c.visit(f.Blocks[0])
+	replaceAll(f, c.repl)
+	removeInsts(f, c.dead)
+	return len(c.dead)
+}
+
+// cse tracks the available expressions along the dominator path of a function.
+type cse struct {
+	// Function being transformed.
+	f *ir.Func
+	// Dominator tree of the function.
+	domTree *dom.Tree
+	// Eliminate redundant loads.
+	loads bool
+	// Number of incoming control flow edges of each basic block.
+	npreds map[*ir.Block]int
+	// Available pure instructions, mapped from their key.
+	exprs map[string]ir.Instruction
+	// Available values of memory locations, mapped from their load key.
+	mem map[string]availValue
+	// Memory generation; incremented by each instruction which may write to
+	// memory.
+	gen int
+	// Replacement value of each eliminated instruction.
+	repl map[value.Value]value.Value
+	// Eliminated instructions.
+	dead map[ir.Instruction]bool
+}
+
+// availValue is the available value of a memory location.
+type availValue struct {
+	// Loaded or stored value.
+	v value.Value
+	// Memory generation of the load or store.
+	gen int
+}
+
+// visit eliminates the common subexpressions of the given basic block, and
+// recursively of the basic blocks it immediately dominates. Expressions made
+// available by the basic block are removed when leaving its scope.
+func (c *cse) visit(block *ir.Block) {
+	type undoMem struct {
+		key  string
+		prev availValue
+		ok   bool
+	}
+	var undoExprs []string
+	var undoMems []undoMem
+	if c.npreds[block] != 1 {
+		c.gen++
+	}
+	for _, inst := range block.Insts {
+		if _, ok := inst.(*ir.InstPhi); ok {
+			continue
+		}
+		// Use the replacements of eliminated operands.
+		for _, op := range inst.Operands() {
+			if r, ok := c.repl[*op]; ok {
+				*op = r
+			}
+		}
+		if key, ok := cseKey(inst); ok {
+			if prev, ok := c.exprs[key]; ok {
+				intersectFlags(prev, inst)
+				c.eliminate(inst, prev.(value.Value))
+				continue
+			}
+			undoExprs = append(undoExprs, key)
+			c.exprs[key] = inst
+			continue
+		}
+		if !c.loads {
+			if mayHaveSideEffects(inst) {
+				c.gen++
+			}
+			continue
+		}
+		var key string
+		var v value.Value
+		switch inst := inst.(type) {
+		case *ir.InstLoad:
+			if inst.Volatile || inst.Atomic {
+				c.gen++
+				continue
+			}
+			key = loadKey(inst.ElemType.String(), inst.Src)
+			if avail, ok := c.mem[key]; ok && avail.gen == c.gen {
+				c.eliminate(inst, avail.v)
+				continue
+			}
+			v = inst
+		case *ir.InstStore:
+			c.gen++
+			if inst.Volatile || inst.Atomic {
+				continue
+			}
+			key = loadKey(inst.Src.Type().String(), inst.Dst)
+			v = inst.Src
+		default:
+			if mayHaveSideEffects(inst) {
+				c.gen++
+			}
+			continue
+		}
+		prev, ok := c.mem[key]
+		undoMems = append(undoMems, undoMem{key: key, prev: prev, ok: ok})
+		c.mem[key] = availValue{v: v, gen: c.gen}
+	}
+	gen := c.gen
+	for _, child := range c.domTree.Children(block) {
+		c.visit(child)
+		c.gen = gen
+	}
+	for _, key := range undoExprs {
+		delete(c.exprs, key)
+	}
+	for i := len(undoMems) - 1; i >= 0; i-- {
+		u := undoMems[i]
+		if u.ok {
+			c.mem[u.key] = u.prev
+		} else {
+			delete(c.mem, u.key)
+		}
+	}
+}
+
+// eliminate replaces the given instruction with v.
+func (c *cse) eliminate(inst ir.Instruction, v value.Value) {
+	c.repl[inst.(value.Value)] = v
+	c.dead[inst] = true
+}
+
+// ### [ Helper functions ] ####################################################
+
+// loadKey returns the key of loads of the given type from the given address.
+func loadKey(typ string, addr value.Value) string {
+	return typ + " " + addr.Ident()
+}
+
+// cseKey returns the key of the given instruction, and reports whether the
+// instruction is pure (i.e. may be eliminated if an identical instruction is
+// available). Poison-generating flags are not part of the key, and the
+// operands of commutative instructions are ordered by name.
+func cseKey(inst ir.Instruction) (string, bool) {
+	switch inst := inst.(type) {
+	// Unary instructions.
+	case *ir.InstFNeg:
+		cp := *inst
+		cp.FastMathFlags = nil
+		return instKey(&cp), true
+	// Binary instructions.
+	case *ir.InstAdd:
+		cp := *inst
+		cp.OverflowFlags = nil
+		cp.X, cp.Y = commute(cp.X, cp.Y)
+		return instKey(&cp), true
+	case *ir.InstFAdd:
+		cp := *inst
+		cp.FastMathFlags = nil
+		cp.X, cp.Y = commute(cp.X, cp.Y)
+		return instKey(&cp), true
+	case *ir.InstSub:
+		cp := *inst
+		cp.OverflowFlags = nil
+		return instKey(&cp), true
+	case *ir.InstFSub:
+		cp := *inst
+		cp.FastMathFlags = nil
+		return instKey(&cp), true
+	case *ir.InstMul:
+		cp := *inst
+		cp.OverflowFlags = nil
+		cp.X, cp.Y = commute(cp.X, cp.Y)
+		return instKey(&cp), true
+	case *ir.InstFMul:
+		cp := *inst
+		cp.FastMathFlags = nil
+		cp.X, cp.Y = commute(cp.X, cp.Y)
+		return instKey(&cp), true
+	case *ir.InstUDiv:
+		cp := *inst
+		cp.Exact = false
+		return instKey(&cp), true
+	case *ir.InstSDiv:
+		cp := *inst
+		cp.Exact = false
+		return instKey(&cp), true
+	case *ir.InstFDiv:
+		cp := *inst
+		cp.FastMathFlags = nil
+		return instKey(&cp), true
+	case *ir.InstURem, *ir.InstSRem:
+		return instKey(inst), true
+	case *ir.InstFRem:
+		cp := *inst
+		cp.FastMathFlags = nil
+		return instKey(&cp), true
+	// Bitwise instructions.
+	case *ir.InstShl:
+		cp := *inst
+		cp.OverflowFlags = nil
+		return instKey(&cp), true
+	case *ir.InstLShr:
+		cp := *inst
+		cp.Exact = false
+		return instKey(&cp), true
+	case *ir.InstAShr:
+		cp := *inst
+		cp.Exact = false
+		return instKey(&cp), true
+	case *ir.InstAnd:
+		cp := *inst
+		cp.X, cp.Y = commute(cp.X, cp.Y)
+		return instKey(&cp), true
+	case *ir.InstOr:
+		cp := *inst
+		cp.X, cp.Y = commute(cp.X, cp.Y)
+		return instKey(&cp), true
+	case *ir.InstXor:
+		cp := *inst
+		cp.X, cp.Y = commute(cp.X, cp.Y)
+		return instKey(&cp), true
+	// Vector and aggregate instructions.
+	case *ir.InstExtractElement, *ir.InstInsertElement, *ir.InstShuffleVector:
+		return instKey(inst), true
+	case *ir.InstExtractValue, *ir.InstInsertValue:
+		return instKey(inst), true
+	// Memory instructions.
+	case *ir.InstGetElementPtr:
+		cp := *inst
+		cp.InBounds = false
+		return instKey(&cp), true
+	// Conversion instructions.
+	case *ir.InstTrunc, *ir.InstZExt, *ir.InstSExt, *ir.InstFPTrunc, *ir.InstFPExt, *ir.InstFPToUI, *ir.InstFPToSI, *ir.InstUIToFP, *ir.InstSIToFP, *ir.InstPtrToInt, *ir.InstIntToPtr, *ir.InstBitCast, *ir.InstAddrSpaceCast:
+		return instKey(inst), true
+	// Other instructions.
+	case *ir.InstICmp:
+		cp := *inst
+		if x, y := commute(cp.X, cp.Y); x != cp.X {
+			cp.X, cp.Y = x, y
+			cp.Pred = swapIPred(cp.Pred)
+		}
+		return instKey(&cp), true
+	case *ir.InstFCmp:
+		cp := *inst
+		cp.FastMathFlags = nil
+		if x, y := commute(cp.X, cp.Y); x != cp.X {
+			cp.X, cp.Y = x, y
+			cp.Pred = swapFPred(cp.Pred)
+		}
+		return instKey(&cp), true
+	case *ir.InstSelect:
+		cp := *inst
+		cp.FastMathFlags = nil
+		return instKey(&cp), true
+	}
+	return "", false
+}
+
+// commute returns the given operands of a commutative instruction ordered by
+// name.
+func commute(x, y value.Value) (value.Value, value.Value) {
+	if x.Ident() > y.Ident() {
+		return y, x
+	}
+	return x, y
+}
+
+// swapIPred returns the integer comparison predicate of the comparison with
+// swapped operands.
+func swapIPred(pred enum.IPred) enum.IPred {
+	switch pred {
+	case enum.IPredSGE:
+		return enum.IPredSLE
+	case enum.IPredSGT:
+		return enum.IPredSLT
+	case enum.IPredSLE:
+		return enum.IPredSGE
+	case enum.IPredSLT:
+		return enum.IPredSGT
+	case enum.IPredUGE:
+		return enum.IPredULE
+	case enum.IPredUGT:
+		return enum.IPredULT
+	case enum.IPredULE:
+		return enum.IPredUGE
+	case enum.IPredULT:
+		return enum.IPredUGT
+	}
+	// eq and ne are symmetric.
+	return pred
+}
+
+// swapFPred returns the floating-point comparison predicate of the comparison
+// with swapped operands.
+func swapFPred(pred enum.FPred) enum.FPred {
+	switch pred {
+	case enum.FPredOGE:
+		return enum.FPredOLE
+	case enum.FPredOGT:
+		return enum.FPredOLT
+	case enum.FPredOLE:
+		return enum.FPredOGE
+	case enum.FPredOLT:
+		return enum.FPredOGT
+	case enum.FPredUGE:
+		return enum.FPredULE
+	case enum.FPredUGT:
+		return enum.FPredULT
+	case enum.FPredULE:
+		return enum.FPredUGE
+	case enum.FPredULT:
+		return enum.FPredUGT
+	}
+	// false, oeq, one, ord, true, ueq, une and uno are symmetric.
+	return pred
+}
+
+// intersectFlags sets the poison-generating flags of the instruction x to the
+// intersection of the flags of x and y; x and y are identical instructions
+// apart from their flags.
+func intersectFlags(x, y ir.Instruction) {
+	switch x := x.(type) {
+	case *ir.InstFNeg:
+		x.FastMathFlags = intersectFastMathFlags(x.FastMathFlags, y.(*ir.InstFNeg).FastMathFlags)
+	case *ir.InstAdd:
+		x.OverflowFlags = intersectOverflowFlags(x.OverflowFlags, y.(*ir.InstAdd).OverflowFlags)
+	case *ir.InstFAdd:
+		x.FastMathFlags = intersectFastMathFlags(x.FastMathFlags, y.(*ir.InstFAdd).FastMathFlags)
+	case *ir.InstSub:
+		x.OverflowFlags = intersectOverflowFlags(x.OverflowFlags, y.(*ir.InstSub).OverflowFlags)
+	case *ir.InstFSub:
+		x.FastMathFlags = intersectFastMathFlags(x.FastMathFlags, y.(*ir.InstFSub).FastMathFlags)
+	case *ir.InstMul:
+		x.OverflowFlags = intersectOverflowFlags(x.OverflowFlags, y.(*ir.InstMul).OverflowFlags)
+	case *ir.InstFMul:
+		x.FastMathFlags = intersectFastMathFlags(x.FastMathFlags, y.(*ir.InstFMul).FastMathFlags)
+	case *ir.InstUDiv:
+		x.Exact = x.Exact && y.(*ir.InstUDiv).Exact
+	case *ir.InstSDiv:
+		x.Exact = x.Exact && y.(*ir.InstSDiv).Exact
+	case *ir.InstFDiv:
+		x.FastMathFlags = intersectFastMathFlags(x.FastMathFlags, y.(*ir.InstFDiv).FastMathFlags)
+	case *ir.InstFRem:
+		x.FastMathFlags = intersectFastMathFlags(x.FastMathFlags, y.(*ir.InstFRem).FastMathFlags)
+	case *ir.InstShl:
+		x.OverflowFlags = intersectOverflowFlags(x.OverflowFlags, y.(*ir.InstShl).OverflowFlags)
+	case *ir.InstLShr:
+		x.Exact = x.Exact && y.(*ir.InstLShr).Exact
+	case *ir.InstAShr:
+		x.Exact = x.Exact && y.(*ir.InstAShr).Exact
+	case *ir.InstGetElementPtr:
+		x.InBounds = x.InBounds && y.(*ir.InstGetElementPtr).InBounds
+	case *ir.InstFCmp:
+		x.FastMathFlags = intersectFastMathFlags(x.FastMathFlags, y.(*ir.InstFCmp).FastMathFlags)
+	case *ir.InstSelect:
+		x.FastMathFlags = intersectFastMathFlags(x.FastMathFlags, y.(*ir.InstSelect).FastMathFlags)
+	}
+}
+
+// intersectOverflowFlags returns the overflow flags present in both x and y.
+func intersectOverflowFlags(x, y []enum.OverflowFlag) []enum.OverflowFlag {
+	var flags []enum.OverflowFlag
+	for _, a := range x {
+		for _, b := range y {
+			if a == b {
+				flags = append(flags, a)
+				break
+			}
+		}
+	}
+	return flags
+}
+
+// intersectFastMathFlags returns the fast-math flags present in both x and y.
+// The fast flag implies all other fast-math flags.
+func intersectFastMathFlags(x, y []enum.FastMathFlag) []enum.FastMathFlag {
+	if hasFastMathFlag(y, enum.FastMathFlagFast) {
+		return x
+	}
+	if hasFastMathFlag(x, enum.FastMathFlagFast) {
+		return y
+	}
+	var flags []enum.FastMathFlag
+	for _, flag := range x {
+		if hasFastMathFlag(y, flag) {
+			flags = append(flags, flag)
+		}
+	}
+	return flags
+}
+
+// hasFastMathFlag reports whether flags contains flag.
+func hasFastMathFlag(flags []enum.FastMathFlag, flag enum.FastMathFlag) bool {
+	for _, f := range flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
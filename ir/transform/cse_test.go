@@ -0,0 +1,163 @@
+package transform_test
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/dom"
+	"github.com/llir/llvm/ir/transform"
+)
+
+func TestEarlyCSE(t *testing.T) {
+	golden := []struct {
+		name string
+		in   string
+		// Eliminate redundant loads.
+		loads bool
+		// Expected number of eliminated instructions.
+		n    int
+		want string
+	}{
+		{
+			name: "commutative and flags",
+			in: `
+define i32 @f(i32 %x, i32 %y, ptr %p) {
+entry:
+	%a = add nsw i32 %x, %y
+	%b = add i32 %y, %x
+	%c = icmp sgt i32 %x, %y
+	%d = icmp slt i32 %y, %x
+	%e = sub i32 %x, %y
+	%f = sub i32 %y, %x
+	%g1 = getelementptr inbounds i32, ptr %p, i64 1
+	%g2 = getelementptr inbounds i32, ptr %p, i64 1
+	%g3 = getelementptr i32, ptr %p, i64 1
+	%s1 = select i1 %c, i32 %a, i32 %e
+	%s2 = select i1 %d, i32 %b, i32 %e
+	%r = add i32 %s1, %s2
+	%q = add i32 %r, %f
+	store i32 %q, ptr %g3
+	ret i32 %q
+}
+`,
+			// %b, %d, %g2, %g3 and %s2.
+			n: 5,
+			want: `
+define i32 @f(i32 %x, i32 %y, ptr %p) {
+entry:
+	%a = add i32 %x, %y
+	%c = icmp sgt i32 %x, %y
+	%e = sub i32 %x, %y
+	%f = sub i32 %y, %x
+	%g1 = getelementptr i32, ptr %p, i64 1
+	%s1 = select i1 %c, i32 %a, i32 %e
+	%r = add i32 %s1, %s1
+	%q = add i32 %r, %f
+	store i32 %q, ptr %g1
+	ret i32 %q
+}
+`,
+		},
+		{
+			name: "dominator scope",
+			in: `
+define i32 @f(i32 %x, i1 %c) {
+entry:
+	%a = mul i32 %x, 3
+	br i1 %c, label %then, label %else
+
+then:
+	%b = mul i32 %x, 3
+	%t = add i32 %b, 1
+	br label %join
+
+else:
+	%e = add i32 %x, 1
+	br label %join
+
+join:
+	%p = phi i32 [ %t, %then ], [ %e, %else ]
+	%j = add i32 %x, 1
+	%k = mul i32 3, %x
+	%r = add i32 %j, %k
+	%s = add i32 %r, %p
+	ret i32 %s
+}
+`,
+			// %b and %k; %j is not dominated by %e.
+			n: 2,
+			want: `
+define i32 @f(i32 %x, i1 %c) {
+entry:
+	%a = mul i32 %x, 3
+	br i1 %c, label %then, label %else
+
+then:
+	%t = add i32 %a, 1
+	br label %join
+
+else:
+	%e = add i32 %x, 1
+	br label %join
+
+join:
+	%p = phi i32 [ %t, %then ], [ %e, %else ]
+	%j = add i32 %x, 1
+	%r = add i32 %j, %a
+	%s = add i32 %r, %p
+	ret i32 %s
+}
+`,
+		},
+		{
+			name: "loads",
+			in: `
+define i32 @f(ptr %p, ptr %q, i32 %x) {
+entry:
+	store i32 %x, ptr %p
+	%a = load i32, ptr %p
+	%b = load i32, ptr %q
+	%c = load i32, ptr %q
+	%d = load volatile i32, ptr %q
+	%e = load i32, ptr %q
+	call void @g()
+	%f = load i32, ptr %p
+	%r1 = add i32 %a, %b
+	%r2 = add i32 %c, %d
+	%r3 = add i32 %e, %f
+	%r4 = add i32 %r1, %r2
+	%r = add i32 %r3, %r4
+	ret i32 %r
+}
+
+declare void @g()
+`,
+			loads: true,
+			// %a (forwarded from the store) and %c.
+			n: 2,
+			want: `
+define i32 @f(ptr %p, ptr %q, i32 %x) {
+entry:
+	store i32 %x, ptr %p
+	%b = load i32, ptr %q
+	%d = load volatile i32, ptr %q
+	%e = load i32, ptr %q
+	call void @g()
+	%f = load i32, ptr %p
+	%r1 = add i32 %x, %b
+	%r2 = add i32 %b, %d
+	%r3 = add i32 %e, %f
+	%r4 = add i32 %r1, %r2
+	%r = add i32 %r3, %r4
+	ret i32 %r
+}
+`,
+		},
+	}
+	for _, g := range golden {
+		loads := g.loads
+		runGolden(t, g.name, g.in, g.n, g.want, func(f *ir.Func) int {
+			return transform.EarlyCSE(f, dom.New(f), loads)
+		})
+	}
+}
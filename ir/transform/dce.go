@@ -0,0 +1,313 @@
+package transform
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/dom"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// === [ Dead code elimination ] ===============================================
+
+// DCE removes the dead instructions of the given function, and returns the
+// number of removed instructions.
+//
+// An instruction is dead if it has no side effects and its result is unused,
+// or only used by dead instructions. Instructions with side effects are
+// stores, fences, atomic instructions, volatile and atomic loads, va_arg,
+// exception handling pads, and calls without the readnone or readonly function
+// attribute. Terminators are never removed.
+func DCE(f *ir.Func) int {
+	dead := make(map[ir.Instruction]bool)
+	var work []ir.Instruction
+	for _, block := range f.Blocks {
+		work = append(work, block.Insts...)
+	}
+	f.InvalidateUses()
+	for len(work) > 0 {
+		inst := work[len(work)-1]
+		work = work[:len(work)-1]
+		if dead[inst] || !isTriviallyDead(f, inst, dead) {
+			continue
+		}
+		dead[inst] = true
+		// Operands of the dead instruction may have become dead.
+		for _, op := range inst.Operands() {
+			if def, ok := (*op).(ir.Instruction); ok && !dead[def] {
+				work = append(work, def)
+			}
+		}
+	}
+	removeDeadInsts(f, dead)
+	return len(dead)
+}
+
+// isTriviallyDead reports whether the given instruction has no side effects and
+// is only used by dead instructions.
+func isTriviallyDead(f *ir.Func, inst ir.Instruction, dead map[ir.Instruction]bool) bool {
+	if mayHaveSideEffects(inst) {
+		return false
+	}
+	v, ok := inst.(value.Value)
+	if !ok {
+		return false
+	}
+	for _, use := range f.Users(v) {
+		user, ok := use.User.(ir.Instruction)
+		if !ok || !dead[user] {
+			return false
+		}
+	}
+	return true
+}
+
+// --- [ Aggressive dead code elimination ] ------------------------------------
+
+// ADCE removes the dead instructions and basic blocks of the given function,
+// and returns the number of removed instructions (including the instructions
+// and terminators of removed basic blocks).
+//
+// ADCE assumes instructions are dead until proven live. Instructions with side
+// effects (see DCE) and terminators other than br and switch are live, as are
+// the operands of live instructions. A conditional br or switch terminator is
+// live if a live basic block (i.e. a basic block with live instructions) is
+// control dependent on it. Dead conditional terminators are replaced by
+// unconditional branches to their immediate post-dominator, and basic blocks
+// no longer reachable from the entry basic block are removed, removing the
+// corresponding incoming values of phi instructions in their successors.
+//
+// Loops are preserved; i.e. the terminators of loop back edges and of basic
+// blocks from which the function cannot return (e.g. infinite loops) are live.
+//
+// postDom is the post-dominator tree of the function; it is recomputed if
+// unreachable basic blocks are removed.
+func ADCE(f *ir.Func, postDom *dom.Tree) int {
+	if len(f.Blocks) == 0 {
+		return 0
+	}
+	n := removeUnreachableBlocks(f)
+	if n > 0 {
+		postDom = dom.NewPost(f)
+	}
+	a := &adce{
+		f:          f,
+		postDom:    postDom,
+		live:       make(map[value.User]bool),
+		liveBlocks: make(map[*ir.Block]bool),
+		parent:     make(map[value.User]*ir.Block),
+	}
+	a.markRoots()
+	a.propagate()
+	return n + a.removeDead()
+}
+
+// adce tracks the liveness of instructions and basic blocks of a function.
+type adce struct {
+	// Function being transformed.
+	f *ir.Func
+	// Post-dominator tree of the function.
+	postDom *dom.Tree
+	// Live instructions and terminators.
+	live map[value.User]bool
+	// Live basic blocks.
+	liveBlocks map[*ir.Block]bool
+	// Parent basic block of each instruction and terminator.
+	parent map[value.User]*ir.Block
+	// Live instructions and terminators with unprocessed operands.
+	work []value.User
+}
+
+// markRoots marks the instructions and terminators which are live regardless
+// of their uses.
+func (a *adce) markRoots() {
+	loops := backEdgeSources(a.f)
+	for _, block := range a.f.Blocks {
+		for _, inst := range block.Insts {
+			a.parent[inst] = block
+		}
+		a.parent[block.Term] = block
+	}
+	for _, block := range a.f.Blocks {
+		for _, inst := range block.Insts {
+			if mayHaveSideEffects(inst) {
+				a.markLive(inst)
+			}
+		}
+		switch block.Term.(type) {
+		case *ir.TermBr:
+			// Unconditional branches are live if their basic block is live.
+		case *ir.TermCondBr, *ir.TermSwitch:
+			if loops[block] || a.postDom.IDom(block) == nil {
+				a.markLive(block.Term)
+			}
+		default:
+			a.markLive(block.Term)
+		}
+	}
+}
+
+// propagate propagates liveness from live instructions to their operands, and
+// from live basic blocks to the terminators they are control dependent on.
+func (a *adce) propagate() {
+	for len(a.work) > 0 {
+		user := a.work[len(a.work)-1]
+		a.work = a.work[:len(a.work)-1]
+		a.markBlockLive(a.parent[user])
+		for _, op := range user.Operands() {
+			if def, ok := (*op).(value.User); ok {
+				if _, ok := a.parent[def]; ok {
+					a.markLive(def)
+				}
+			}
+		}
+		// The value of a live phi instruction depends on the control flow edge
+		// taken to reach its basic block.
+		if phi, ok := user.(*ir.InstPhi); ok {
+			for _, inc := range phi.Incs {
+				a.markBlockLive(inc.Pred.(*ir.Block))
+			}
+		}
+	}
+}
+
+// markLive marks the given instruction or terminator as live.
+func (a *adce) markLive(user value.User) {
+	if a.live[user] {
+		return
+	}
+	a.live[user] = true
+	a.work = append(a.work, user)
+}
+
+// markBlockLive marks the given basic block as live, and marks the terminators
+// it is control dependent on as live.
+func (a *adce) markBlockLive(block *ir.Block) {
+	if a.liveBlocks[block] {
+		return
+	}
+	a.liveBlocks[block] = true
+	if _, ok := block.Term.(*ir.TermBr); ok {
+		a.markLive(block.Term)
+	}
+	// Control dependences are given by the post-dominance frontier.
+	for _, dep := range a.postDom.Frontier(block) {
+		a.markLive(dep.Term)
+	}
+}
+
+// removeDead removes the dead instructions of the function, replacing dead
+// terminators with unconditional branches, and returns the number of removed
+// instructions.
+func (a *adce) removeDead() int {
+	n := 0
+	for _, block := range a.f.Blocks {
+		if _, ok := block.Term.(*ir.TermBr); ok || a.live[block.Term] {
+			continue
+		}
+		target := a.postDom.IDom(block)
+		for _, succ := range block.Term.Succs() {
+			if succ != target {
+				removePhiIncs(succ, block)
+			}
+		}
+		dedupPhiIncs(target, block)
+		block.Term = ir.NewBr(target)
+		n++
+	}
+	dead := make(map[ir.Instruction]bool)
+	for _, block := range a.f.Blocks {
+		for _, inst := range block.Insts {
+			if !a.live[inst] {
+				dead[inst] = true
+			}
+		}
+	}
+	removeDeadInsts(a.f, dead)
+	n += len(dead)
+	if n > 0 {
+		invalidate(a.f)
+	}
+	return n + removeUnreachableBlocks(a.f)
+}
+
+// ### [ Helper functions ] ####################################################
+
+// removeDeadInsts removes the given dead instructions from the function. Uses
+// of dead instructions from metadata arguments of function calls (e.g. the
+// value of llvm.dbg.value) are replaced by undef.
+func removeDeadInsts(f *ir.Func, dead map[ir.Instruction]bool) {
+	if len(dead) == 0 {
+		return
+	}
+	repl := make(map[value.Value]value.Value)
+	for inst := range dead {
+		if v, ok := inst.(value.Value); ok && !types.Equal(v.Type(), types.Void) {
+			repl[v] = constant.NewUndef(v.Type())
+		}
+	}
+	replaceAll(f, repl)
+	removeInsts(f, dead)
+}
+
+// dedupPhiIncs removes all but the first incoming value from the predecessor
+// basic block pred of the phi instructions of the given basic block.
+func dedupPhiIncs(block, pred *ir.Block) {
+	for _, inst := range block.Insts {
+		phi, ok := inst.(*ir.InstPhi)
+		if !ok {
+			break
+		}
+		seen := false
+		incs := phi.Incs[:0]
+		for _, inc := range phi.Incs {
+			if inc.Pred == pred {
+				if seen {
+					continue
+				}
+				seen = true
+			}
+			incs = append(incs, inc)
+		}
+		phi.Incs = incs
+	}
+}
+
+// backEdgeSources returns the basic blocks with an outgoing back edge in a
+// depth-first traversal of the control flow graph from the entry basic block;
+// i.e. the latches of loops.
+func backEdgeSources(f *ir.Func) map[*ir.Block]bool {
+	const (
+		unvisited = iota
+		onStack
+		done
+	)
+	state := make(map[*ir.Block]int)
+	sources := make(map[*ir.Block]bool)
+	type item struct {
+		block *ir.Block
+		next  int
+	}
+	entry := f.Blocks[0]
+	state[entry] = onStack
+	stack := []item{{block: entry}}
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		succs := top.block.Term.Succs()
+		if top.next < len(succs) {
+			succ := succs[top.next]
+			top.next++
+			switch state[succ] {
+			case unvisited:
+				state[succ] = onStack
+				stack = append(stack, item{block: succ})
+			case onStack:
+				sources[top.block] = true
+			}
+			continue
+		}
+		state[top.block] = done
+		stack = stack[:len(stack)-1]
+	}
+	return sources
+}
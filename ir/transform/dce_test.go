@@ -0,0 +1,311 @@
+package transform_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/dom"
+	"github.com/llir/llvm/ir/transform"
+	"github.com/llir/llvm/ir/verify"
+)
+
+func TestDCE(t *testing.T) {
+	golden := []struct {
+		name string
+		in   string
+		// Expected number of removed instructions.
+		n    int
+		want string
+	}{
+		{
+			name: "side effects",
+			in: `
+define i32 @f(i32 %x, ptr %p) {
+entry:
+	%a = add i32 %x, 1
+	%b = mul i32 %a, 2
+	%buf = alloca i32
+	%c = load i32, ptr %p
+	%d = load volatile i32, ptr %p
+	%e = load atomic i32, ptr %p seq_cst, align 4
+	store i32 %x, ptr %p
+	%f = call i32 @pure(i32 %x)
+	%g = call i32 @ro(i32 %x)
+	%h = call i32 @impure(i32 %x)
+	%i = call i32 @impure(i32 %x) readnone
+	fence seq_cst
+	%old = atomicrmw add ptr %p, i32 1 seq_cst
+	%pair = cmpxchg ptr %p, i32 0, i32 1 seq_cst seq_cst
+	%r = add i32 %x, %h
+	ret i32 %r
+}
+
+declare i32 @pure(i32 %x) readnone
+
+declare i32 @ro(i32 %x) #0
+
+declare i32 @impure(i32 %x)
+
+attributes #0 = { nounwind readonly }
+`,
+			n: 7,
+			want: `
+define i32 @f(i32 %x, ptr %p) {
+entry:
+	%d = load volatile i32, ptr %p
+	%e = load atomic i32, ptr %p seq_cst, align 4
+	store i32 %x, ptr %p
+	%h = call i32 @impure(i32 %x)
+	fence seq_cst
+	%old = atomicrmw add ptr %p, i32 1 seq_cst
+	%pair = cmpxchg ptr %p, i32 0, i32 1 seq_cst seq_cst
+	%r = add i32 %x, %h
+	ret i32 %r
+}
+`,
+		},
+		{
+			name: "phi cycle",
+			in: `
+define void @f(i32 %n) {
+entry:
+	br label %loop
+
+loop:
+	%i = phi i32 [ 0, %entry ], [ %j, %loop ]
+	%j = add i32 %i, 1
+	%unused = mul i32 %n, 2
+	br label %loop
+}
+`,
+			// The phi cycle is not trivially dead; see ADCE.
+			n: 1,
+			want: `
+define void @f(i32 %n) {
+entry:
+	br label %loop
+
+loop:
+	%i = phi i32 [ 0, %entry ], [ %j, %loop ]
+	%j = add i32 %i, 1
+	br label %loop
+}
+`,
+		},
+	}
+	for _, g := range golden {
+		runGolden(t, g.name, g.in, g.n, g.want, transform.DCE)
+	}
+}
+
+func TestADCE(t *testing.T) {
+	golden := []struct {
+		name string
+		in   string
+		// Expected number of removed instructions.
+		n    int
+		want string
+	}{
+		{
+			name: "dead diamond",
+			in: `
+define i32 @f(i32 %x, i1 %c) {
+entry:
+	br i1 %c, label %then, label %else
+
+then:
+	%a = add i32 %x, 1
+	br label %join
+
+else:
+	%b = mul i32 %x, 2
+	br label %join
+
+join:
+	%p = phi i32 [ %a, %then ], [ %b, %else ], [ %u, %dead ]
+	ret i32 %x
+
+dead:
+	%u = add i32 %x, 3
+	br label %join
+}
+`,
+			// Unreachable block (2), dead branch (1), then (2), else (2) and phi (1).
+			n: 8,
+			want: `
+define i32 @f(i32 %x, i1 %c) {
+entry:
+	br label %join
+
+join:
+	ret i32 %x
+}
+`,
+		},
+		{
+			name: "live diamond",
+			in: `
+define i32 @f(i32 %x, i1 %c) {
+entry:
+	%unused = add i32 %x, 7
+	br i1 %c, label %then, label %else
+
+then:
+	%a = add i32 %x, 1
+	br label %join
+
+else:
+	%b = mul i32 %x, 2
+	br label %join
+
+join:
+	%p = phi i32 [ %a, %then ], [ %b, %else ]
+	ret i32 %p
+}
+`,
+			n: 1,
+			want: `
+define i32 @f(i32 %x, i1 %c) {
+entry:
+	br i1 %c, label %then, label %else
+
+then:
+	%a = add i32 %x, 1
+	br label %join
+
+else:
+	%b = mul i32 %x, 2
+	br label %join
+
+join:
+	%p = phi i32 [ %a, %then ], [ %b, %else ]
+	ret i32 %p
+}
+`,
+		},
+		{
+			name: "switch",
+			in: `
+define void @f(i32 %x, ptr %p, i1 %c) {
+entry:
+	%y = add i32 %x, 1
+	switch i32 %x, label %def [
+		i32 0, label %a
+		i32 1, label %b
+	]
+
+a:
+	store i32 %y, ptr %p
+	br label %exit
+
+b:
+	%z = mul i32 %x, 3
+	br i1 %c, label %b.then, label %exit
+
+b.then:
+	%w = add i32 %z, 1
+	br label %exit
+
+def:
+	br label %exit
+
+exit:
+	%q = phi i32 [ 0, %a ], [ %z, %b ], [ %w, %b.then ], [ 1, %def ]
+	ret void
+}
+`,
+			// Dead branch of b (1), b.then (2), %z (1) and phi (1).
+			n: 5,
+			want: `
+define void @f(i32 %x, ptr %p, i1 %c) {
+entry:
+	%y = add i32 %x, 1
+	switch i32 %x, label %def [
+		i32 0, label %a
+		i32 1, label %b
+	]
+
+a:
+	store i32 %y, ptr %p
+	br label %exit
+
+b:
+	br label %exit
+
+def:
+	br label %exit
+
+exit:
+	ret void
+}
+`,
+		},
+		{
+			name: "loop",
+			in: `
+define void @f(i32 %n) {
+entry:
+	br label %loop
+
+loop:
+	%i = phi i32 [ 0, %entry ], [ %j, %loop ]
+	%k = phi i32 [ 0, %entry ], [ %l, %loop ]
+	%j = add i32 %i, 1
+	%l = add i32 %k, 2
+	%cmp = icmp slt i32 %j, %n
+	br i1 %cmp, label %loop, label %exit
+
+exit:
+	ret void
+}
+`,
+			// Loops are preserved; the phi cycle of %k is dead.
+			n: 2,
+			want: `
+define void @f(i32 %n) {
+entry:
+	br label %loop
+
+loop:
+	%i = phi i32 [ 0, %entry ], [ %j, %loop ]
+	%j = add i32 %i, 1
+	%cmp = icmp slt i32 %j, %n
+	br i1 %cmp, label %loop, label %exit
+
+exit:
+	ret void
+}
+`,
+		},
+	}
+	for _, g := range golden {
+		runGolden(t, g.name, g.in, g.n, g.want, func(f *ir.Func) int {
+			return transform.ADCE(f, dom.NewPost(f))
+		})
+	}
+}
+
+// runGolden runs the given transformation on the first function of the given
+// LLVM IR module, and compares the number of changes and the output against
+// the expected values.
+func runGolden(t *testing.T, name, in string, n int, want string, transform func(f *ir.Func) int) {
+	m, err := asm.ParseString(name+".ll", in)
+	if err != nil {
+		t.Errorf("%q: unable to parse module; %+v", name, err)
+		return
+	}
+	f := m.Funcs[0]
+	if got := transform(f); got != n {
+		t.Errorf("%q: number of changes mismatch; expected %d, got %d", name, n, got)
+	}
+	for _, err := range verify.Module(m) {
+		t.Errorf("%q: unexpected verification error; %v", name, err)
+	}
+	got := f.LLString()
+	want = strings.TrimSpace(want)
+	if got != want {
+		t.Errorf("%q: output mismatch; expected\n%s\n\ngot\n%s", name, want, got)
+	}
+}
@@ -0,0 +1,777 @@
+package transform
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/callgraph"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// === [ Function inlining ] ===================================================
+
+// DefaultInlineThreshold is the default inlining cost threshold; i.e. callees
+// with an inlining cost (see InlineCost) above the threshold are not inlined.
+const DefaultInlineThreshold = 225
+
+// Inlining costs.
+const (
+	// Cost of an instruction or terminator.
+	inlineInstCost = 5
+	// Additional cost of a call instruction.
+	inlineCallPenalty = 25
+)
+
+// Inline inlines the direct call sites of the given module, visiting callers
+// bottom-up in the call graph so that callees are inlined into before their
+// callers. The number of inlined call sites is returned.
+//
+// Call sites of functions with the alwaysinline function attribute are always
+// inlined, and call sites of functions (or call sites) with the noinline
+// function attribute are never inlined. Other call sites are inlined if the
+// inlining cost of the callee is at most threshold. Calls within a strongly
+// connected component of the call graph (i.e. recursive calls) are not inlined.
+//
+// Functions with internal or private linkage which are no longer used after
+// being inlined are removed from the module.
+func Inline(m *ir.Module, cg *callgraph.Graph, threshold int) int {
+	n := 0
+	inlined := make(map[*ir.Func]bool)
+	for _, node := range cg.PostOrder() {
+		caller := node.Func
+		scc := cg.SCCOf(caller)
+		for _, e := range node.Callees {
+			if e.Indirect || cg.SCCOf(e.Callee.Func) == scc {
+				continue
+			}
+			callee := e.Callee.Func
+			if !shouldInline(e.Site, callee, threshold) {
+				continue
+			}
+			if InlineCall(caller, e.Site) {
+				inlined[callee] = true
+				n++
+			}
+		}
+	}
+	// Remove internal functions no longer used.
+	m.InvalidateUses()
+	var funcs []*ir.Func
+	for _, f := range m.Funcs {
+		if inlined[f] && isInternal(f) && len(m.Users(f)) == 0 {
+			continue
+		}
+		funcs = append(funcs, f)
+	}
+	if len(funcs) != len(m.Funcs) {
+		m.Funcs = funcs
+		m.InvalidateUses()
+	}
+	return n
+}
+
+// shouldInline reports whether the given call site of callee should be
+// inlined, based on the inlining attributes and the inlining cost of callee.
+func shouldInline(site value.User, callee *ir.Func, threshold int) bool {
+	var siteAttrs []ir.FuncAttribute
+	switch site := site.(type) {
+	case *ir.InstCall:
+		siteAttrs = site.FuncAttrs
+	case *ir.TermInvoke:
+		siteAttrs = site.FuncAttrs
+	}
+	switch {
+	case hasFuncAttr(siteAttrs, enum.FuncAttrNoInline):
+		return false
+	case hasFuncAttr(siteAttrs, enum.FuncAttrAlwaysInline):
+		return true
+	case hasFuncAttr(callee.FuncAttrs, enum.FuncAttrNoInline):
+		return false
+	case hasFuncAttr(callee.FuncAttrs, enum.FuncAttrAlwaysInline):
+		return true
+	}
+	return InlineCost(callee) <= threshold
+}
+
+// InlineCost returns the cost of inlining the given function; i.e. an estimate
+// of the code size of the function body.
+//
+// Each instruction and terminator costs 5, with an additional cost of 25 for
+// calls. Instructions and terminators which typically generate no code are
+// free; i.e. phi instructions, static alloca instructions of the entry basic
+// block, no-op casts, getelementptr with constant indices, debug and lifetime
+// intrinsics, unconditional br and ret terminators.
+func InlineCost(f *ir.Func) int {
+	cost := 0
+	for i, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			switch inst := inst.(type) {
+			case *ir.InstPhi, *ir.InstBitCast, *ir.InstAddrSpaceCast:
+				continue
+			case *ir.InstAlloca:
+				if i == 0 && isStaticAlloca(inst) {
+					continue
+				}
+			case *ir.InstGetElementPtr:
+				if allConstants(inst.Indices) {
+					continue
+				}
+			case *ir.InstCall:
+				if isIntrinsic(inst, "llvm.dbg.declare", "llvm.dbg.value", "llvm.dbg.assign", "llvm.dbg.label", "llvm.lifetime.start", "llvm.lifetime.end") {
+					continue
+				}
+				cost += inlineCallPenalty
+			}
+			cost += inlineInstCost
+		}
+		switch block.Term.(type) {
+		case *ir.TermBr, *ir.TermRet:
+		case *ir.TermInvoke:
+			cost += inlineInstCost + inlineCallPenalty
+		default:
+			cost += inlineInstCost
+		}
+	}
+	return cost
+}
+
+// InlineCall inlines the callee of the given call site (*ir.InstCall or
+// *ir.TermInvoke) of the caller function, and reports whether the call site
+// was inlined.
+//
+// The basic blocks of the callee are copied into the caller, with parameters
+// remapped to the arguments of the call site. Byval parameters are remapped to
+// a copy of the pointed-to argument, allocated in the caller entry basic block
+// and initialized before the inlined body. The basic block of the call site
+// is split after the call site, and ret terminators of the callee are replaced
+// by branches to the continuation basic block, where the return values are
+// merged by a phi instruction. Static alloca instructions of the callee entry
+// basic block are moved to the caller entry basic block. Local names of the
+// callee already used in the caller are made unique with a ".i" suffix.
+//
+// For invoke call sites, calls of the callee which may unwind are turned into
+// invoke terminators unwinding to the landing pad of the call site, the
+// clauses of the landing pad are added to the landing pads of the callee, and
+// resume terminators of the callee branch to the landing pad of the call site.
+//
+// Call sites are not inlined if the callee is not a function with a body of
+// the same signature as the call site, if the callee is variadic or the caller
+// itself, if arguments are passed with the inalloca or preallocated parameter
+// attributes, or if the callee uses indirectbr, funclet-based exception
+// handling, musttail calls or a personality function different from the
+// caller.
+func InlineCall(caller *ir.Func, site value.User) bool {
+	callee, args, ok := callSiteCallee(site)
+	if !ok || !canInline(caller, callee, site) {
+		return false
+	}
+	block, index := findSite(caller, site)
+	if block == nil {
+		return false
+	}
+	in := &inliner{
+		caller: caller,
+		callee: callee,
+		site:   site,
+		block:  block,
+		names:  localNames(caller),
+		repl:   make(map[value.Value]value.Value),
+	}
+	// Copy the callee, with parameters mapped to arguments. Byval parameters
+	// are mapped to a private copy of the pointed-to argument.
+	vmap := make(map[value.Value]value.Value)
+	var copies []*ir.InstAlloca
+	var copied []value.Value
+	for i, param := range callee.Params {
+		arg := args[i]
+		if a, ok := arg.(*ir.Arg); ok {
+			arg = a.Value
+		}
+		if typ, ok := byvalType(param, args[i]); ok {
+			alloca := ir.NewAlloca(typ)
+			if types.IsOpaquePointer(param.Typ) {
+				alloca.Typ = types.NewOpaquePointer(0)
+			}
+			if !param.IsUnnamed() {
+				alloca.SetName(in.uniqueName(param.Name()))
+			}
+			copies = append(copies, alloca)
+			copied = append(copied, arg)
+			arg = alloca
+		}
+		vmap[param] = arg
+	}
+	clone, _ := ir.CloneFunc(callee, vmap)
+	in.blocks = clone.Blocks
+	for _, b := range in.blocks {
+		b.Parent = caller
+		in.rename(b)
+		for _, inst := range b.Insts {
+			in.rename(inst)
+			// Calls of the callee may access the stack frame of the caller after
+			// inlining (e.g. moved alloca instructions).
+			if call, ok := inst.(*ir.InstCall); ok && call.Tail == enum.TailTail {
+				call.Tail = enum.TailNone
+			}
+		}
+		in.rename(b.Term)
+	}
+	if hasLandingPads(callee) && caller.Personality == nil {
+		caller.Personality = callee.Personality
+	}
+	in.splitSite(index)
+	// Copy byval arguments before entering the callee; the allocas of the
+	// copies are moved to the caller entry basic block with the static allocas
+	// of the callee.
+	entry := in.blocks[0]
+	for i := len(copies) - 1; i >= 0; i-- {
+		entry.Insts = append([]ir.Instruction{copies[i]}, entry.Insts...)
+	}
+	var copyInsts []ir.Instruction
+	for i, alloca := range copies {
+		load := ir.NewLoad(alloca.ElemType, copied[i])
+		copyInsts = append(copyInsts, load, ir.NewStore(load, alloca))
+	}
+	block.Insts = append(block.Insts, copyInsts...)
+	if invoke, ok := site.(*ir.TermInvoke); ok {
+		in.inlineUnwind(invoke)
+	}
+	in.inlineReturns()
+	in.moveAllocas()
+	// Insert the copied basic blocks after the basic block of the call site,
+	// and the split landing pad after the landing pad.
+	var blocks []*ir.Block
+	for _, b := range caller.Blocks {
+		blocks = append(blocks, b)
+		switch {
+		case b == block:
+			blocks = append(blocks, in.blocks...)
+			blocks = append(blocks, in.cont)
+		case b == in.lpad && in.lpadBody != nil:
+			blocks = append(blocks, in.lpadBody)
+		}
+	}
+	caller.Blocks = blocks
+	replaceAll(caller, in.repl)
+	// The phi instructions of the split landing pad merge the values of the
+	// landing pad itself.
+	for inc, x := range in.lpadIncs {
+		inc.X = x
+	}
+	caller.ResetIDs()
+	invalidate(caller)
+	return true
+}
+
+// inliner tracks the state of inlining a call site.
+type inliner struct {
+	// Caller function.
+	caller *ir.Func
+	// Callee function.
+	callee *ir.Func
+	// Call site (*ir.InstCall or *ir.TermInvoke).
+	site value.User
+	// Basic block of the call site.
+	block *ir.Block
+	// Copied basic blocks of the callee.
+	blocks []*ir.Block
+	// Continuation basic block, succeeding the call site.
+	cont *ir.Block
+	// Landing pad basic block of invoke call sites.
+	lpad *ir.Block
+	// Landing pad basic block split after the landingpad instruction, for
+	// resume terminators of the callee; or nil if not split.
+	lpadBody *ir.Block
+	// Original incoming value of each incoming value from the landing pad of
+	// phi instructions in the split landing pad.
+	lpadIncs map[*ir.Incoming]value.Value
+	// Local names of the caller.
+	names map[string]bool
+	// Replacement value of each replaced value of the caller.
+	repl map[value.Value]value.Value
+}
+
+// splitSite splits the basic block of the call site after the call site; the
+// given index is the index of call instructions within the basic block. The
+// basic block branches to the copied entry basic block of the callee.
+func (in *inliner) splitSite(index int) {
+	block := in.block
+	in.cont = ir.NewBlock(in.uniqueName(in.callee.Name() + ".exit"))
+	in.cont.Parent = in.caller
+	switch site := in.site.(type) {
+	case *ir.InstCall:
+		in.cont.Insts = append(in.cont.Insts, block.Insts[index+1:]...)
+		in.cont.Term = block.Term
+		block.Insts = block.Insts[:index:index]
+	case *ir.TermInvoke:
+		in.cont.Term = ir.NewBr(site.NormalRetTarget.(*ir.Block))
+	}
+	for _, succ := range in.cont.Term.Succs() {
+		replacePhiPred(succ, block, in.cont)
+	}
+	block.Term = ir.NewBr(in.blocks[0])
+}
+
+// inlineReturns replaces the ret terminators of the copied basic blocks with
+// branches to the continuation basic block, and replaces the uses of the call
+// site with the returned value.
+func (in *inliner) inlineReturns() {
+	var incs []*ir.Incoming
+	for _, b := range in.blocks {
+		ret, ok := b.Term.(*ir.TermRet)
+		if !ok {
+			continue
+		}
+		if ret.X != nil {
+			incs = append(incs, ir.NewIncoming(ret.X, b))
+		}
+		b.Term = ir.NewBr(in.cont)
+	}
+	v, ok := in.site.(value.Value)
+	if !ok || types.Equal(v.Type(), types.Void) {
+		return
+	}
+	switch len(incs) {
+	case 0:
+		// The callee never returns.
+		in.repl[v] = constant.NewUndef(v.Type())
+	case 1:
+		in.repl[v] = incs[0].X
+	default:
+		phi := ir.NewPhi(incs...)
+		if named, ok := v.(value.Named); ok && !isUnnamed(named) {
+			phi.SetName(named.Name())
+		}
+		in.cont.Insts = append([]ir.Instruction{phi}, in.cont.Insts...)
+		in.repl[v] = phi
+	}
+}
+
+// inlineUnwind redirects the unwinding of the copied basic blocks to the
+// landing pad of the given invoke call site.
+func (in *inliner) inlineUnwind(invoke *ir.TermInvoke) {
+	in.lpad = invoke.ExceptionRetTarget.(*ir.Block)
+	lp := landingPad(in.lpad)
+	// Turn calls which may unwind into invoke terminators. Split basic blocks
+	// are appended to the list of copied basic blocks, and visited in turn.
+	for i := 0; i < len(in.blocks); i++ {
+		b := in.blocks[i]
+		for j, inst := range b.Insts {
+			call, ok := inst.(*ir.InstCall)
+			if !ok || !mayUnwind(call) {
+				continue
+			}
+			in.blocks = append(in.blocks, in.callToInvoke(b, j, call))
+			break
+		}
+	}
+	// Add the clauses of the landing pad to the landing pads of the callee.
+	for _, b := range in.blocks {
+		for _, inst := range b.Insts {
+			if pad, ok := inst.(*ir.InstLandingPad); ok {
+				pad.Cleanup = pad.Cleanup || lp.Cleanup
+				pad.Clauses = append(pad.Clauses, lp.Clauses...)
+			}
+		}
+	}
+	// Branch from resume terminators to the landing pad, split after the
+	// landingpad instruction.
+	var resumes []*ir.Block
+	for _, b := range in.blocks {
+		if _, ok := b.Term.(*ir.TermResume); ok {
+			resumes = append(resumes, b)
+		}
+	}
+	if len(resumes) > 0 {
+		in.splitLandingPad(lp, resumes)
+	}
+	// The basic block of the call site no longer unwinds to the landing pad.
+	removePhiIncs(in.lpad, in.block)
+}
+
+// callToInvoke turns the given call instruction at index j of the basic block
+// b into an invoke terminator unwinding to the landing pad of the call site.
+// The instructions succeeding the call are moved to a new basic block, which is
+// returned.
+func (in *inliner) callToInvoke(b *ir.Block, j int, call *ir.InstCall) *ir.Block {
+	rest := ir.NewBlock(in.uniqueName(b.Name() + ".noexc"))
+	rest.Parent = in.caller
+	rest.Insts = append(rest.Insts, b.Insts[j+1:]...)
+	rest.Term = b.Term
+	for _, succ := range rest.Term.Succs() {
+		replacePhiPred(succ, b, rest)
+	}
+	invoke := ir.NewInvoke(call.Callee, call.Args, rest, in.lpad)
+	invoke.LocalIdent = call.LocalIdent
+	invoke.FuncType = call.FuncType
+	invoke.CallingConv = call.CallingConv
+	invoke.ReturnAttrs = call.ReturnAttrs
+	invoke.AddrSpace = call.AddrSpace
+	invoke.FuncAttrs = call.FuncAttrs
+	invoke.OperandBundles = call.OperandBundles
+	invoke.Metadata = call.Metadata
+	b.Insts = b.Insts[:j:j]
+	b.Term = invoke
+	// The landing pad is reached from b as from the basic block of the call
+	// site.
+	addPhiIncs(in.lpad, in.block, b)
+	if !types.Equal(call.Type(), types.Void) {
+		in.repl[call] = invoke
+	}
+	return rest
+}
+
+// splitLandingPad splits the landing pad of the call site after the given
+// landingpad instruction, and branches from the given basic blocks with resume
+// terminators to the split basic block. The phi instructions of the landing
+// pad, and the landingpad instruction itself, are merged with the values of
+// the call site and the resumed exceptions by phi instructions of the split
+// basic block.
+func (in *inliner) splitLandingPad(lp *ir.InstLandingPad, resumes []*ir.Block) {
+	lpad := in.lpad
+	body := ir.NewBlock(in.uniqueName(lpad.Name() + ".body"))
+	body.Parent = in.caller
+	in.lpadBody = body
+	in.lpadIncs = make(map[*ir.Incoming]value.Value)
+	pos := 0
+	for lpad.Insts[pos] != lp {
+		pos++
+	}
+	for _, inst := range lpad.Insts[:pos+1] {
+		x := inst.(value.Value)
+		lpadInc := ir.NewIncoming(x, lpad)
+		incs := []*ir.Incoming{lpadInc}
+		for _, b := range resumes {
+			switch inst := inst.(type) {
+			case *ir.InstPhi:
+				incs = append(incs, ir.NewIncoming(incValue(inst, in.block), b))
+			case *ir.InstLandingPad:
+				incs = append(incs, ir.NewIncoming(b.Term.(*ir.TermResume).X, b))
+			}
+		}
+		phi := ir.NewPhi(incs...)
+		if named, ok := x.(value.Named); ok && !isUnnamed(named) {
+			phi.SetName(in.uniqueName(named.Name()))
+		}
+		body.Insts = append(body.Insts, phi)
+		in.lpadIncs[lpadInc] = x
+		in.repl[x] = phi
+	}
+	body.Insts = append(body.Insts, lpad.Insts[pos+1:]...)
+	body.Term = lpad.Term
+	for _, succ := range body.Term.Succs() {
+		replacePhiPred(succ, lpad, body)
+	}
+	lpad.Insts = lpad.Insts[: pos+1 : pos+1]
+	lpad.Term = ir.NewBr(body)
+	for _, b := range resumes {
+		b.Term = ir.NewBr(body)
+	}
+}
+
+// moveAllocas moves the static alloca instructions at the top of the copied
+// entry basic block of the callee to the top of the caller entry basic block.
+func (in *inliner) moveAllocas() {
+	entry := in.blocks[0]
+	n := 0
+	for _, inst := range entry.Insts {
+		alloca, ok := inst.(*ir.InstAlloca)
+		if !ok || !isStaticAlloca(alloca) {
+			break
+		}
+		n++
+	}
+	if n == 0 {
+		return
+	}
+	allocas := entry.Insts[:n:n]
+	entry.Insts = entry.Insts[n:]
+	callerEntry := in.caller.Blocks[0]
+	callerEntry.Insts = append(allocas, callerEntry.Insts...)
+}
+
+// rename makes the local name of the given copied value unique within the
+// caller.
+func (in *inliner) rename(v interface{}) {
+	named, ok := v.(value.Named)
+	if !ok {
+		return
+	}
+	if isUnnamed(named) {
+		if id, ok := named.(interface{ SetID(id int64) }); ok {
+			id.SetID(0)
+		}
+		return
+	}
+	name := named.Name()
+	if !in.names[name] {
+		in.names[name] = true
+		return
+	}
+	named.SetName(in.uniqueName(name + ".i"))
+}
+
+// uniqueName returns a unique local name within the caller based on the given
+// name.
+func (in *inliner) uniqueName(name string) string {
+	n := name
+	for i := 1; in.names[n]; i++ {
+		n = fmt.Sprintf("%s%d", name, i)
+	}
+	in.names[n] = true
+	return n
+}
+
+// ### [ Helper functions ] ####################################################
+
+// callSiteCallee returns the callee function and arguments of the given call
+// site, and reports whether the callee is a function.
+func callSiteCallee(site value.User) (*ir.Func, []value.Value, bool) {
+	var callee value.Value
+	var args []value.Value
+	switch site := site.(type) {
+	case *ir.InstCall:
+		callee, args = site.Callee, site.Args
+	case *ir.TermInvoke:
+		callee, args = site.Invokee, site.Args
+	default:
+		return nil, nil, false
+	}
+	f, ok := callee.(*ir.Func)
+	return f, args, ok
+}
+
+// canInline reports whether the given call site of callee may be inlined into
+// caller.
+func canInline(caller, callee *ir.Func, site value.User) bool {
+	if callee == caller || len(callee.Blocks) == 0 || callee.Sig.Variadic {
+		return false
+	}
+	var sig *types.FuncType
+	var args []value.Value
+	switch site := site.(type) {
+	case *ir.InstCall:
+		if site.Tail == enum.TailMustTail {
+			return false
+		}
+		sig, args = site.Sig(), site.Args
+	case *ir.TermInvoke:
+		if landingPad(site.ExceptionRetTarget.(*ir.Block)) == nil {
+			return false
+		}
+		sig, args = site.Sig(), site.Args
+	}
+	if !callee.Sig.Equal(sig) || len(args) != len(callee.Params) {
+		return false
+	}
+	for i, param := range callee.Params {
+		attrs := paramAttrs(param, args[i])
+		for _, attr := range attrs {
+			switch attr.(type) {
+			case ir.InAlloca, ir.Preallocated:
+				return false
+			}
+		}
+		if hasByval(attrs) {
+			// Copies of byval arguments are allocated in the default address
+			// space, with the type of the attribute or pointer.
+			if t, ok := param.Typ.(*types.PointerType); !ok || t.AddrSpace != 0 {
+				return false
+			}
+			if _, ok := byvalType(param, args[i]); !ok {
+				return false
+			}
+		}
+	}
+	for _, block := range callee.Blocks {
+		for _, inst := range block.Insts {
+			switch inst := inst.(type) {
+			case *ir.InstCall:
+				if inst.Tail == enum.TailMustTail {
+					return false
+				}
+			case *ir.InstCatchPad, *ir.InstCleanupPad:
+				return false
+			}
+		}
+		switch block.Term.(type) {
+		case *ir.TermIndirectBr, *ir.TermCatchSwitch, *ir.TermCatchRet, *ir.TermCleanupRet:
+			return false
+		}
+	}
+	if hasLandingPads(callee) && caller.Personality != nil && !constEqual(caller.Personality, callee.Personality) {
+		return false
+	}
+	return true
+}
+
+// findSite returns the basic block of the given call site within f, and the
+// index of the call site within the instructions of the basic block (or the
+// number of instructions for terminators); or nil if not present.
+func findSite(f *ir.Func, site value.User) (*ir.Block, int) {
+	for _, block := range f.Blocks {
+		if block.Term == site {
+			return block, len(block.Insts)
+		}
+		for i, inst := range block.Insts {
+			if inst == site {
+				return block, i
+			}
+		}
+	}
+	return nil, 0
+}
+
+// localNames returns the local names of the given function.
+func localNames(f *ir.Func) map[string]bool {
+	names := make(map[string]bool)
+	add := func(v interface{}) {
+		if named, ok := v.(value.Named); ok && !isUnnamed(named) {
+			names[named.Name()] = true
+		}
+	}
+	for _, param := range f.Params {
+		add(param)
+	}
+	for _, block := range f.Blocks {
+		add(block)
+		for _, inst := range block.Insts {
+			add(inst)
+		}
+		add(block.Term)
+	}
+	return names
+}
+
+// isUnnamed reports whether the given local variable is unnamed.
+func isUnnamed(v value.Named) bool {
+	u, ok := v.(interface{ IsUnnamed() bool })
+	return ok && u.IsUnnamed()
+}
+
+// isInternal reports whether the given function has internal or private
+// linkage.
+func isInternal(f *ir.Func) bool {
+	return f.Linkage == enum.LinkageInternal || f.Linkage == enum.LinkagePrivate
+}
+
+// isStaticAlloca reports whether the given alloca instruction allocates a
+// constant number of elements.
+func isStaticAlloca(alloca *ir.InstAlloca) bool {
+	if alloca.NElems == nil {
+		return true
+	}
+	_, ok := alloca.NElems.(*constant.Int)
+	return ok
+}
+
+// allConstants reports whether the given values are constants.
+func allConstants(vals []value.Value) bool {
+	for _, v := range vals {
+		if _, ok := v.(constant.Constant); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// hasLandingPads reports whether the given function contains landingpad
+// instructions.
+func hasLandingPads(f *ir.Func) bool {
+	for _, block := range f.Blocks {
+		if landingPad(block) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// landingPad returns the landingpad instruction of the given basic block; or
+// nil if not present.
+func landingPad(block *ir.Block) *ir.InstLandingPad {
+	for _, inst := range block.Insts {
+		switch inst := inst.(type) {
+		case *ir.InstPhi:
+			continue
+		case *ir.InstLandingPad:
+			return inst
+		}
+		break
+	}
+	return nil
+}
+
+// mayUnwind reports whether the given call instruction may unwind; i.e. if
+// neither the call site nor the callee has the nounwind function attribute.
+// Intrinsics and inline assembly are assumed not to unwind.
+func mayUnwind(call *ir.InstCall) bool {
+	if _, ok := call.Callee.(*ir.InlineAsm); ok {
+		return false
+	}
+	if strings.HasPrefix(calleeName(call), "llvm.") {
+		return false
+	}
+	attrs := call.FuncAttrs
+	if callee, ok := call.Callee.(*ir.Func); ok {
+		attrs = append(attrs[:len(attrs):len(attrs)], callee.FuncAttrs...)
+	}
+	return !hasFuncAttr(attrs, enum.FuncAttrNoUnwind)
+}
+
+// addPhiIncs adds incoming values from the predecessor basic block pred to the
+// phi instructions of the given basic block, with the same values as the
+// incoming values from the predecessor basic block like.
+func addPhiIncs(block, like, pred *ir.Block) {
+	for _, inst := range block.Insts {
+		phi, ok := inst.(*ir.InstPhi)
+		if !ok {
+			break
+		}
+		phi.Incs = append(phi.Incs, ir.NewIncoming(incValue(phi, like), pred))
+	}
+}
+
+// paramAttrs returns the parameter attributes of the given parameter and the
+// corresponding argument of a call site.
+func paramAttrs(param *ir.Param, arg value.Value) []ir.ParamAttribute {
+	attrs := param.Attrs
+	if a, ok := arg.(*ir.Arg); ok {
+		attrs = append(attrs[:len(attrs):len(attrs)], a.Attrs...)
+	}
+	return attrs
+}
+
+// hasByval reports whether the given parameter attributes contain the byval
+// attribute.
+func hasByval(attrs []ir.ParamAttribute) bool {
+	for _, attr := range attrs {
+		if _, ok := attr.(ir.Byval); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// byvalType returns the type of the value passed by value to the given byval
+// parameter (from the byval attribute of the parameter or argument, or the
+// element type of the parameter pointer type), and reports whether the
+// parameter is a sized byval parameter.
+func byvalType(param *ir.Param, arg value.Value) (types.Type, bool) {
+	attrs := paramAttrs(param, arg)
+	if !hasByval(attrs) {
+		return nil, false
+	}
+	for _, attr := range attrs {
+		if b, ok := attr.(ir.Byval); ok && b.Typ != nil {
+			return b.Typ, isSized(b.Typ)
+		}
+	}
+	if t, ok := param.Typ.(*types.PointerType); ok && t.ElemType != nil {
+		return t.ElemType, isSized(t.ElemType)
+	}
+	return nil, false
+}
@@ -0,0 +1,297 @@
+package transform_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir/callgraph"
+	"github.com/llir/llvm/ir/transform"
+	"github.com/llir/llvm/ir/verify"
+)
+
+func TestInline(t *testing.T) {
+	golden := []struct {
+		name      string
+		in        string
+		threshold int
+		// Expected number of inlined call sites.
+		n    int
+		want string
+	}{
+		{
+			name: "multiple returns",
+			in: `
+define i32 @main(i32 %n) {
+entry:
+	%x = alloca i32
+	%a = call i32 @abs(i32 %n)
+	%b = add i32 %a, 1
+	ret i32 %b
+}
+
+define internal i32 @abs(i32 %x) {
+entry:
+	%p = alloca i32
+	store i32 %x, i32* %p
+	%c = icmp slt i32 %x, 0
+	br i1 %c, label %neg, label %pos
+
+neg:
+	%y = sub i32 0, %x
+	ret i32 %y
+
+pos:
+	ret i32 %x
+}
+`,
+			threshold: transform.DefaultInlineThreshold,
+			n:         1,
+			want: `
+define i32 @main(i32 %n) {
+entry:
+	%p = alloca i32
+	%x = alloca i32
+	br label %entry.i
+
+entry.i:
+	store i32 %n, i32* %p
+	%c = icmp slt i32 %n, 0
+	br i1 %c, label %neg, label %pos
+
+neg:
+	%y = sub i32 0, %n
+	br label %abs.exit
+
+pos:
+	br label %abs.exit
+
+abs.exit:
+	%a = phi i32 [ %y, %neg ], [ %n, %pos ]
+	%b = add i32 %a, 1
+	ret i32 %b
+}
+`,
+		},
+		{
+			name: "invoke",
+			in: `
+declare void @may_throw()
+
+declare i32 @__gxx_personality_v0(...)
+
+define void @main() personality i32 (...)* @__gxx_personality_v0 {
+entry:
+	invoke void @g()
+			to label %cont unwind label %lpad
+
+cont:
+	ret void
+
+lpad:
+	%lp = landingpad { i8*, i32 }
+			catch i8* null
+	resume { i8*, i32 } %lp
+}
+
+define internal void @g() personality i32 (...)* @__gxx_personality_v0 {
+entry:
+	invoke void @may_throw()
+			to label %done unwind label %lpad
+
+done:
+	call void @may_throw()
+	ret void
+
+lpad:
+	%lp = landingpad { i8*, i32 }
+			cleanup
+	call void @may_throw() nounwind
+	resume { i8*, i32 } %lp
+}
+`,
+			threshold: transform.DefaultInlineThreshold,
+			n:         1,
+			want: `
+declare void @may_throw()
+
+declare i32 @__gxx_personality_v0(...)
+
+define void @main() personality i32 (...)* @__gxx_personality_v0 {
+entry:
+	br label %entry.i
+
+entry.i:
+	invoke void @may_throw()
+		to label %done unwind label %lpad.i
+
+done:
+	invoke void @may_throw()
+		to label %done.noexc unwind label %lpad
+
+lpad.i:
+	%lp.i = landingpad { i8*, i32 }
+		cleanup
+		catch i8* null
+	call void @may_throw() nounwind
+	br label %lpad.body
+
+done.noexc:
+	br label %g.exit
+
+g.exit:
+	br label %cont
+
+cont:
+	ret void
+
+lpad:
+	%lp = landingpad { i8*, i32 }
+		catch i8* null
+	br label %lpad.body
+
+lpad.body:
+	%lp1 = phi { i8*, i32 } [ %lp, %lpad ], [ %lp.i, %lpad.i ]
+	resume { i8*, i32 } %lp1
+}
+`,
+		},
+		{
+			name: "cost model",
+			in: `
+define i32 @main(i32 %n) {
+entry:
+	%a = call i32 @small(i32 %n)
+	%b = call i32 @big(i32 %a)
+	%c = call i32 @never(i32 %b)
+	%d = call i32 @small(i32 %c) noinline
+	ret i32 %d
+}
+
+define i32 @small(i32 %x) {
+entry:
+	%y = add i32 %x, 1
+	ret i32 %y
+}
+
+define internal i32 @big(i32 %x) alwaysinline {
+entry:
+	%y = mul i32 %x, %x
+	%z = mul i32 %y, %x
+	ret i32 %z
+}
+
+define internal i32 @never(i32 %x) noinline {
+entry:
+	%y = sub i32 %x, 1
+	ret i32 %y
+}
+`,
+			threshold: 5,
+			// @small within the threshold (1) and @big always inlined (1); @never
+			// and the noinline call site are kept.
+			n: 2,
+			want: `
+define i32 @main(i32 %n) {
+entry:
+	br label %entry.i
+
+entry.i:
+	%y = add i32 %n, 1
+	br label %small.exit
+
+small.exit:
+	br label %entry.i1
+
+entry.i1:
+	%y.i = mul i32 %y, %y
+	%z = mul i32 %y.i, %y
+	br label %big.exit
+
+big.exit:
+	%c = call i32 @never(i32 %z)
+	%d = call i32 @small(i32 %c) noinline
+	ret i32 %d
+}
+
+define i32 @small(i32 %x) {
+entry:
+	%y = add i32 %x, 1
+	ret i32 %y
+}
+
+define internal i32 @never(i32 %x) noinline {
+entry:
+	%y = sub i32 %x, 1
+	ret i32 %y
+}
+`,
+		},
+		{
+			name: "byval",
+			in: `
+%S = type { i32 }
+
+define i32 @main() {
+entry:
+	%s = alloca %S
+	%p = getelementptr %S, ptr %s, i32 0, i32 0
+	store i32 7, ptr %p
+	call void @bump(ptr byval(%S) %s)
+	%v = load i32, ptr %p
+	ret i32 %v
+}
+
+define internal void @bump(ptr byval(%S) %s) {
+entry:
+	%p = getelementptr %S, ptr %s, i32 0, i32 0
+	store i32 99, ptr %p
+	ret void
+}
+`,
+			threshold: transform.DefaultInlineThreshold,
+			// The callee stores to a copy of %s.
+			n: 1,
+			want: `
+%S = type { i32 }
+
+define i32 @main() {
+entry:
+	%s1 = alloca %S
+	%s = alloca %S
+	%p = getelementptr %S, ptr %s, i32 0, i32 0
+	store i32 7, ptr %p
+	%0 = load %S, ptr %s
+	store %S %0, ptr %s1
+	br label %entry.i
+
+entry.i:
+	%p.i = getelementptr %S, ptr %s1, i32 0, i32 0
+	store i32 99, ptr %p.i
+	br label %bump.exit
+
+bump.exit:
+	%v = load i32, ptr %p
+	ret i32 %v
+}
+`,
+		},
+	}
+	for _, g := range golden {
+		m, err := asm.ParseString(g.name+".ll", g.in)
+		if err != nil {
+			t.Errorf("%q: unable to parse module; %+v", g.name, err)
+			continue
+		}
+		if got := transform.Inline(m, callgraph.New(m), g.threshold); got != g.n {
+			t.Errorf("%q: number of inlined call sites mismatch; expected %d, got %d", g.name, g.n, got)
+		}
+		for _, err := range verify.Module(m) {
+			t.Errorf("%q: unexpected verification error; %v", g.name, err)
+		}
+		got := strings.TrimSpace(m.String())
+		if want := strings.TrimSpace(g.want); got != want {
+			t.Errorf("%q: output mismatch; expected\n%s\n\ngot\n%s", g.name, want, got)
+		}
+	}
+}
@@ -0,0 +1,374 @@
+package transform
+
+import (
+	"fmt"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/dom"
+	"github.com/llir/llvm/ir/metadata"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// Mem2Reg promotes the promotable alloca instructions of the given function to
+// SSA registers, and returns the number of promoted alloca instructions.
+//
+// An alloca instruction is promotable if it allocates a single element and is
+// only used as the address of non-volatile load and store instructions of the
+// allocated type (and by llvm.lifetime intrinsics). Loads of promoted alloca
+// instructions are replaced by the reaching stored value, inserting phi
+// instructions where control flow merges. Loads not reached by any store are
+// replaced by undef. Promoted alloca instructions, together with their loads,
+// stores, llvm.lifetime intrinsics and llvm.dbg.declare intrinsics, are
+// removed from the function.
+//
+// domTree is the dominator tree of the function.
+func Mem2Reg(f *ir.Func, domTree *dom.Tree) int {
+	if len(f.Blocks) == 0 {
+		return 0
+	}
+	p := &promoter{
+		f:       f,
+		domTree: domTree,
+		slots:   make(map[*ir.InstAlloca]int),
+		dead:    make(map[ir.Instruction]bool),
+		repl:    make(map[value.Value]value.Value),
+		phis:    make(map[*ir.Block][]*phiSlot),
+		preds:   make(map[*ir.Block][]*ir.Block),
+		names:   make(map[string]bool),
+		defined: make(map[*ir.Block]map[int]bool),
+	}
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			if alloca, ok := inst.(*ir.InstAlloca); ok && p.isPromotable(alloca) {
+				p.slots[alloca] = len(p.allocas)
+				p.allocas = append(p.allocas, alloca)
+			}
+		}
+	}
+	if len(p.allocas) == 0 {
+		return 0
+	}
+	p.promote()
+	return len(p.allocas)
+}
+
+// promoter promotes alloca instructions of a function to SSA registers.
+type promoter struct {
+	// Function being transformed.
+	f *ir.Func
+	// Dominator tree of the function.
+	domTree *dom.Tree
+	// Promoted alloca instructions.
+	allocas []*ir.InstAlloca
+	// Index of each promoted alloca instruction in allocas.
+	slots map[*ir.InstAlloca]int
+	// Instructions to remove.
+	dead map[ir.Instruction]bool
+	// Replacement value of each removed load instruction.
+	repl map[value.Value]value.Value
+	// Phi instructions inserted at the top of each basic block.
+	phis map[*ir.Block][]*phiSlot
+	// Predecessors of each reachable basic block.
+	preds map[*ir.Block][]*ir.Block
+	// Names of local variables of the function.
+	names map[string]bool
+	// Promoted alloca instructions stored to within each basic block.
+	defined map[*ir.Block]map[int]bool
+}
+
+// phiSlot is a phi instruction inserted for a promoted alloca instruction.
+type phiSlot struct {
+	// Inserted phi instruction.
+	phi *ir.InstPhi
+	// Index of the promoted alloca instruction.
+	slot int
+}
+
+// isPromotable reports whether the given alloca instruction is promotable,
+// recording droppable users (e.g. llvm.lifetime intrinsics) for removal.
+func (p *promoter) isPromotable(alloca *ir.InstAlloca) bool {
+	if alloca.InAlloca || alloca.SwiftError {
+		return false
+	}
+	if alloca.NElems != nil {
+		n, ok := alloca.NElems.(*constant.Int)
+		if !ok || !n.X.IsInt64() || n.X.Int64() != 1 {
+			return false
+		}
+	}
+	var droppable []ir.Instruction
+	for _, use := range p.f.Users(alloca) {
+		switch user := use.User.(type) {
+		case *ir.InstLoad:
+			if user.Src != alloca || user.Volatile || !types.Equal(user.ElemType, alloca.ElemType) {
+				return false
+			}
+		case *ir.InstStore:
+			if user.Dst != alloca || user.Src == alloca || user.Volatile || !types.Equal(user.Src.Type(), alloca.ElemType) {
+				return false
+			}
+		case *ir.InstCall:
+			if !isIntrinsic(user, "llvm.lifetime.start", "llvm.lifetime.end") {
+				return false
+			}
+			droppable = append(droppable, user)
+		default:
+			return false
+		}
+	}
+	for _, inst := range droppable {
+		p.dead[inst] = true
+	}
+	return true
+}
+
+// promote promotes the promotable alloca instructions of the function.
+func (p *promoter) promote() {
+	for _, param := range p.f.Params {
+		p.names[param.LocalName] = true
+	}
+	for _, block := range p.f.Blocks {
+		p.names[block.LocalName] = true
+		for _, inst := range block.Insts {
+			if n, ok := inst.(value.Named); ok {
+				p.names[n.Name()] = true
+			}
+		}
+		if !p.domTree.Reachable(block) {
+			continue
+		}
+		for _, succ := range block.Term.Succs() {
+			p.preds[succ] = append(p.preds[succ], block)
+		}
+	}
+	// Insert phi instructions.
+	for slot := range p.allocas {
+		p.insertPhis(slot)
+	}
+	// Rename loads and stores, following the dominator tree.
+	vals := make([]value.Value, len(p.allocas))
+	for i, alloca := range p.allocas {
+		vals[i] = constant.NewUndef(alloca.ElemType)
+	}
+	p.rename(p.f.Blocks[0], vals)
+	// Loads in unreachable basic blocks are replaced by undef, as are the
+	// incoming values of control flow edges from unreachable basic blocks.
+	for _, block := range p.f.Blocks {
+		if p.domTree.Reachable(block) {
+			continue
+		}
+		if block.Term != nil {
+			for _, succ := range block.Term.Succs() {
+				for _, ps := range p.phis[succ] {
+					undef := constant.NewUndef(p.allocas[ps.slot].ElemType)
+					ps.phi.Incs = append(ps.phi.Incs, ir.NewIncoming(undef, block))
+				}
+			}
+		}
+		for _, inst := range block.Insts {
+			switch inst := inst.(type) {
+			case *ir.InstLoad:
+				if alloca, ok := p.promoted(inst.Src); ok {
+					p.repl[inst] = constant.NewUndef(alloca.ElemType)
+					p.dead[inst] = true
+				}
+			case *ir.InstStore:
+				if _, ok := p.promoted(inst.Dst); ok {
+					p.dead[inst] = true
+				}
+			}
+		}
+	}
+	// Remove llvm.dbg.declare intrinsics of promoted alloca instructions.
+	for _, block := range p.f.Blocks {
+		for _, inst := range block.Insts {
+			call, ok := inst.(*ir.InstCall)
+			if !ok || !isIntrinsic(call, "llvm.dbg.declare", "llvm.dbg.addr") || len(call.Args) == 0 {
+				continue
+			}
+			if md, ok := call.Args[0].(*metadata.Value); ok {
+				if v, ok := md.Value.(value.Value); ok {
+					if _, ok := p.promoted(v); ok {
+						p.dead[inst] = true
+					}
+				}
+			}
+		}
+	}
+	for _, alloca := range p.allocas {
+		p.dead[alloca] = true
+	}
+	replaceAll(p.f, p.repl)
+	removeInsts(p.f, p.dead)
+	p.simplifyPhis()
+}
+
+// insertPhis inserts phi instructions for the given promoted alloca instruction
+// at the top of each basic block in the iterated dominance frontier of the
+// basic blocks storing to it, in which the alloca instruction is live.
+func (p *promoter) insertPhis(slot int) {
+	alloca := p.allocas[slot]
+	// Basic blocks storing to the alloca instruction, and basic blocks loading
+	// from the alloca instruction before any store.
+	var defBlocks, liveIn []*ir.Block
+	for _, block := range p.f.Blocks {
+		if !p.domTree.Reachable(block) {
+			continue
+		}
+		stored, exposed := false, false
+		for _, inst := range block.Insts {
+			switch inst := inst.(type) {
+			case *ir.InstLoad:
+				if inst.Src == alloca && !stored {
+					exposed = true
+				}
+			case *ir.InstStore:
+				if inst.Dst == alloca {
+					stored = true
+				}
+			}
+		}
+		if stored {
+			defBlocks = append(defBlocks, block)
+			if p.defined[block] == nil {
+				p.defined[block] = make(map[int]bool)
+			}
+			p.defined[block][slot] = true
+		}
+		if exposed {
+			liveIn = append(liveIn, block)
+		}
+	}
+	// Propagate liveness backwards to predecessors not storing to the alloca
+	// instruction.
+	live := make(map[*ir.Block]bool)
+	for len(liveIn) > 0 {
+		block := liveIn[len(liveIn)-1]
+		liveIn = liveIn[:len(liveIn)-1]
+		if live[block] {
+			continue
+		}
+		live[block] = true
+		for _, pred := range p.preds[block] {
+			if !p.defined[pred][slot] && !live[pred] {
+				liveIn = append(liveIn, pred)
+			}
+		}
+	}
+	for _, block := range p.domTree.IteratedFrontier(defBlocks) {
+		if !live[block] {
+			continue
+		}
+		phi := &ir.InstPhi{Typ: alloca.ElemType}
+		if alloca.LocalName != "" {
+			phi.SetName(p.uniqueName(alloca.LocalName))
+		}
+		block.Insts = append([]ir.Instruction{phi}, block.Insts...)
+		p.phis[block] = append(p.phis[block], &phiSlot{phi: phi, slot: slot})
+	}
+}
+
+// rename replaces the loads of promoted alloca instructions within the given
+// basic block and the basic blocks it dominates by the reaching stored values,
+// and adds incoming values to the inserted phi instructions of successor basic
+// blocks. The values of the promoted alloca instructions on entry to the basic
+// block are specified by vals.
+func (p *promoter) rename(block *ir.Block, vals []value.Value) {
+	vals = append([]value.Value(nil), vals...)
+	for _, ps := range p.phis[block] {
+		vals[ps.slot] = ps.phi
+	}
+	for _, inst := range block.Insts {
+		switch inst := inst.(type) {
+		case *ir.InstLoad:
+			if alloca, ok := p.promoted(inst.Src); ok {
+				p.repl[inst] = vals[p.slots[alloca]]
+				p.dead[inst] = true
+			}
+		case *ir.InstStore:
+			if alloca, ok := p.promoted(inst.Dst); ok {
+				vals[p.slots[alloca]] = inst.Src
+				p.dead[inst] = true
+			}
+		}
+	}
+	// One incoming value per control flow edge.
+	for _, succ := range block.Term.Succs() {
+		for _, ps := range p.phis[succ] {
+			ps.phi.Incs = append(ps.phi.Incs, ir.NewIncoming(vals[ps.slot], block))
+		}
+	}
+	for _, child := range p.domTree.Children(block) {
+		p.rename(child, vals)
+	}
+}
+
+// simplifyPhis removes inserted phi instructions with a single distinct
+// incoming value (ignoring self-references), replacing their uses with the
+// incoming value.
+func (p *promoter) simplifyPhis() {
+	removed := make(map[*ir.InstPhi]bool)
+	for {
+		repl := make(map[value.Value]value.Value)
+		dead := make(map[ir.Instruction]bool)
+		for _, block := range p.f.Blocks {
+			for _, ps := range p.phis[block] {
+				phi := ps.phi
+				if removed[phi] {
+					continue
+				}
+				var x value.Value
+				trivial := true
+				for _, inc := range phi.Incs {
+					if inc.X == phi || inc.X == x {
+						continue
+					}
+					if x != nil {
+						trivial = false
+						break
+					}
+					x = inc.X
+				}
+				if !trivial {
+					continue
+				}
+				if x == nil {
+					x = constant.NewUndef(phi.Typ)
+				}
+				repl[phi] = x
+				dead[phi] = true
+				removed[phi] = true
+			}
+		}
+		if len(repl) == 0 {
+			return
+		}
+		replaceAll(p.f, repl)
+		removeInsts(p.f, dead)
+	}
+}
+
+// promoted returns the promoted alloca instruction of the given address, and a
+// boolean indicating success.
+func (p *promoter) promoted(addr value.Value) (*ir.InstAlloca, bool) {
+	alloca, ok := addr.(*ir.InstAlloca)
+	if !ok {
+		return nil, false
+	}
+	_, ok = p.slots[alloca]
+	return alloca, ok
+}
+
+// uniqueName returns a unique local name for a phi instruction inserted for the
+// alloca instruction with the given name.
+func (p *promoter) uniqueName(name string) string {
+	for i := 0; ; i++ {
+		n := fmt.Sprintf("%s.%d", name, i)
+		if !p.names[n] {
+			p.names[n] = true
+			return n
+		}
+	}
+}
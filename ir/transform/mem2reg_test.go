@@ -0,0 +1,231 @@
+package transform_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir/dom"
+	"github.com/llir/llvm/ir/transform"
+	"github.com/llir/llvm/ir/verify"
+)
+
+func TestMem2Reg(t *testing.T) {
+	golden := []struct {
+		name string
+		in   string
+		// Expected number of promoted alloca instructions.
+		n    int
+		want string
+	}{
+		{
+			name: "diamond",
+			in: `
+define i32 @max(i32 %a, i32 %b) {
+entry:
+	%retval = alloca i32
+	%a.addr = alloca i32
+	%b.addr = alloca i32
+	store i32 %a, ptr %a.addr
+	store i32 %b, ptr %b.addr
+	%0 = load i32, ptr %a.addr
+	%1 = load i32, ptr %b.addr
+	%cmp = icmp sgt i32 %0, %1
+	br i1 %cmp, label %if.then, label %if.else
+
+if.then:
+	%2 = load i32, ptr %a.addr
+	store i32 %2, ptr %retval
+	br label %return
+
+if.else:
+	%3 = load i32, ptr %b.addr
+	store i32 %3, ptr %retval
+	br label %return
+
+return:
+	%4 = load i32, ptr %retval
+	ret i32 %4
+}
+`,
+			n: 3,
+			want: `
+define i32 @max(i32 %a, i32 %b) {
+entry:
+	%cmp = icmp sgt i32 %a, %b
+	br i1 %cmp, label %if.then, label %if.else
+
+if.then:
+	br label %return
+
+if.else:
+	br label %return
+
+return:
+	%retval.0 = phi i32 [ %a, %if.then ], [ %b, %if.else ]
+	ret i32 %retval.0
+}
+`,
+		},
+		{
+			name: "loop",
+			in: `
+define i32 @sum(i32 %n) {
+entry:
+	%i = alloca i32
+	%s = alloca i32
+	%esc = alloca i32
+	call void @llvm.lifetime.start.p0(i64 4, ptr %i)
+	store i32 0, ptr %i
+	store i32 0, ptr %s
+	call void @g(ptr %esc)
+	br label %cond
+
+cond:
+	%0 = load i32, ptr %i
+	%cmp = icmp slt i32 %0, %n
+	br i1 %cmp, label %body, label %end
+
+body:
+	%1 = load i32, ptr %s
+	%2 = load i32, ptr %i
+	%add = add i32 %1, %2
+	store i32 %add, ptr %s
+	%inc = add i32 %2, 1
+	store i32 %inc, ptr %i
+	br label %cond
+
+end:
+	call void @llvm.lifetime.end.p0(i64 4, ptr %i)
+	%3 = load i32, ptr %s
+	%4 = load volatile i32, ptr %esc
+	%r = add i32 %3, %4
+	ret i32 %r
+}
+
+declare void @g(ptr %p)
+
+declare void @llvm.lifetime.start.p0(i64 %size, ptr %p)
+
+declare void @llvm.lifetime.end.p0(i64 %size, ptr %p)
+`,
+			n: 2,
+			want: `
+define i32 @sum(i32 %n) {
+entry:
+	%esc = alloca i32
+	call void @g(ptr %esc)
+	br label %cond
+
+cond:
+	%s.0 = phi i32 [ 0, %entry ], [ %add, %body ]
+	%i.0 = phi i32 [ 0, %entry ], [ %inc, %body ]
+	%cmp = icmp slt i32 %i.0, %n
+	br i1 %cmp, label %body, label %end
+
+body:
+	%add = add i32 %s.0, %i.0
+	%inc = add i32 %i.0, 1
+	br label %cond
+
+end:
+	%0 = load volatile i32, ptr %esc
+	%r = add i32 %s.0, %0
+	ret i32 %r
+}
+`,
+		},
+		{
+			name: "unreachable",
+			in: `
+define i32 @f(i32 %n, i1 %c) {
+entry:
+	%x = alloca i32
+	%y = alloca i32
+	%u = alloca i32
+	%0 = load i32, ptr %u
+	store i32 %n, ptr %x
+	switch i32 %n, label %a [
+		i32 0, label %b
+		i32 1, label %b
+	]
+
+a:
+	store i32 1, ptr %y
+	br label %loop
+
+b:
+	store i32 2, ptr %y
+	br i1 %c, label %loop, label %exit
+
+loop:
+	%1 = load i32, ptr %x
+	%2 = add i32 %1, -1
+	store i32 %2, ptr %x
+	%3 = icmp eq i32 %2, 0
+	br i1 %3, label %exit, label %loop
+
+exit:
+	%4 = load i32, ptr %y
+	%5 = add i32 %4, %0
+	ret i32 %5
+
+dead:
+	store i32 7, ptr %y
+	%6 = load i32, ptr %x
+	br label %exit
+}
+`,
+			n: 3,
+			want: `
+define i32 @f(i32 %n, i1 %c) {
+entry:
+	switch i32 %n, label %a [
+		i32 0, label %b
+		i32 1, label %b
+	]
+
+a:
+	br label %loop
+
+b:
+	br i1 %c, label %loop, label %exit
+
+loop:
+	%y.0 = phi i32 [ 1, %a ], [ 2, %b ], [ %y.0, %loop ]
+	%x.0 = phi i32 [ %n, %a ], [ %n, %b ], [ %0, %loop ]
+	%0 = add i32 %x.0, -1
+	%1 = icmp eq i32 %0, 0
+	br i1 %1, label %exit, label %loop
+
+exit:
+	%y.1 = phi i32 [ 2, %b ], [ %y.0, %loop ], [ undef, %dead ]
+	%2 = add i32 %y.1, undef
+	ret i32 %2
+
+dead:
+	br label %exit
+}
+`,
+		},
+	}
+	for _, g := range golden {
+		m, err := asm.ParseString(g.name+".ll", g.in)
+		if err != nil {
+			t.Errorf("%q: unable to parse module; %+v", g.name, err)
+			continue
+		}
+		f := m.Funcs[0]
+		if n := transform.Mem2Reg(f, dom.New(f)); n != g.n {
+			t.Errorf("%q: number of promoted alloca instructions mismatch; expected %d, got %d", g.name, g.n, n)
+		}
+		for _, err := range verify.Module(m) {
+			t.Errorf("%q: unexpected verification error; %v", g.name, err)
+		}
+		got := f.LLString()
+		want := strings.TrimSpace(g.want)
+		if got != want {
+			t.Errorf("%q: output mismatch; expected\n%s\n\ngot\n%s", g.name, want, got)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package transform
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/pass"
+	"github.com/pkg/errors"
+)
+
+// === [ Passes ] ==============================================================
+
+func init() {
+	pass.Register("adce", pass.NoParams(newADCE))
+	pass.Register("dce", pass.NoParams(newDCE))
+	pass.Register("early-cse", newEarlyCSE)
+	pass.Register("inline", newInline)
+	pass.Register("ipsccp", pass.NoParams(newIPSCCP))
+	pass.Register("mem2reg", pass.NoParams(newMem2Reg))
+	pass.Register("sccp", pass.NoParams(newSCCP))
+	pass.Register("sroa", pass.NoParams(newSROA))
+	pass.Register("simplifycfg", pass.NoParams(newSimplifyCFG))
+}
+
+// Statistics of transformation passes.
+var (
+	numPromoted    = pass.NewStatistic("mem2reg", "NumPromoted", "Number of alloca's promoted")
+	numDCERemoved  = pass.NewStatistic("dce", "NumRemoved", "Number of instructions removed")
+	numADCERemoved = pass.NewStatistic("adce", "NumRemoved", "Number of instructions removed")
+	numSimplified  = pass.NewStatistic("simplifycfg", "NumSimplified", "Number of control flow simplifications")
+	numSCCP        = pass.NewStatistic("sccp", "NumSimplified", "Number of values and branches simplified")
+	numIPSCCP      = pass.NewStatistic("ipsccp", "NumSimplified", "Number of values and branches simplified")
+	numCSE         = pass.NewStatistic("early-cse", "NumCSE", "Number of instructions CSE'd")
+	numInlined     = pass.NewStatistic("inline", "NumInlined", "Number of call sites inlined")
+	numSROA        = pass.NewStatistic("sroa", "NumReplaced", "Number of allocas broken up")
+)
+
+// newMem2Reg returns a new pass promoting alloca instructions to SSA registers
+// (see Mem2Reg).
+func newMem2Reg() pass.Pass {
+	return pass.NewFuncPass("mem2reg", func(f *ir.Func, am *pass.AnalysisManager) pass.Analyses {
+		n := Mem2Reg(f, am.DomTree(f))
+		if n == 0 {
+			return pass.AllAnalyses
+		}
+		numPromoted.Add(n)
+		return pass.CFGAnalyses
+	})
+}
+
+// newDCE returns a new pass removing dead instructions (see DCE).
+func newDCE() pass.Pass {
+	return pass.NewFuncPass("dce", func(f *ir.Func, am *pass.AnalysisManager) pass.Analyses {
+		n := DCE(f)
+		if n == 0 {
+			return pass.AllAnalyses
+		}
+		numDCERemoved.Add(n)
+		return pass.CFGAnalyses
+	})
+}
+
+// newADCE returns a new pass removing dead instructions and basic blocks,
+// assuming instructions are dead until proven live (see ADCE).
+func newADCE() pass.Pass {
+	return pass.NewFuncPass("adce", func(f *ir.Func, am *pass.AnalysisManager) pass.Analyses {
+		n := ADCE(f, am.PostDomTree(f))
+		if n == 0 {
+			return pass.AllAnalyses
+		}
+		numADCERemoved.Add(n)
+		return pass.NoAnalyses
+	})
+}
+
+// newSimplifyCFG returns a new pass simplifying the control flow graph of
+// functions (see SimplifyCFG).
+func newSimplifyCFG() pass.Pass {
+	return pass.NewFuncPass("simplifycfg", func(f *ir.Func, am *pass.AnalysisManager) pass.Analyses {
+		n := SimplifyCFG(f)
+		if n == 0 {
+			return pass.AllAnalyses
+		}
+		numSimplified.Add(n)
+		return pass.NoAnalyses
+	})
+}
+
+// newSCCP returns a new pass performing sparse conditional constant propagation
+// (see SCCP).
+func newSCCP() pass.Pass {
+	return pass.NewFuncPass("sccp", func(f *ir.Func, am *pass.AnalysisManager) pass.Analyses {
+		n := SCCP(f)
+		if n == 0 {
+			return pass.AllAnalyses
+		}
+		numSCCP.Add(n)
+		return pass.NoAnalyses
+	})
+}
+
+// newIPSCCP returns a new pass performing interprocedural sparse conditional
+// constant propagation (see IPSCCP).
+func newIPSCCP() pass.Pass {
+	return pass.NewModulePass("ipsccp", func(m *ir.Module, am *pass.AnalysisManager) pass.Analyses {
+		n := IPSCCP(m, am.CallGraph())
+		if n == 0 {
+			return pass.AllAnalyses
+		}
+		numIPSCCP.Add(n)
+		return pass.NoAnalyses
+	})
+}
+
+// newEarlyCSE returns a new pass eliminating common subexpressions (see
+// EarlyCSE). Redundant loads are eliminated unless the "no-loads" parameter is
+// given (e.g. "early-cse<no-loads>").
+func newEarlyCSE(params string) (pass.Pass, error) {
+	loads := true
+	switch params {
+	case "":
+	case "no-loads":
+		loads = false
+	default:
+		return nil, errors.Errorf("unexpected parameters %q", params)
+	}
+	return pass.NewFuncPass("early-cse", func(f *ir.Func, am *pass.AnalysisManager) pass.Analyses {
+		n := EarlyCSE(f, am.DomTree(f), loads)
+		if n == 0 {
+			return pass.AllAnalyses
+		}
+		numCSE.Add(n)
+		return pass.CFGAnalyses
+	}), nil
+}
+
+// newInline returns a new pass inlining call sites (see Inline). The inlining
+// cost threshold defaults to DefaultInlineThreshold, and may be given by the
+// "threshold=N" parameter (e.g. "inline<threshold=100>").
+func newInline(params string) (pass.Pass, error) {
+	threshold := DefaultInlineThreshold
+	switch {
+	case params == "":
+	case strings.HasPrefix(params, "threshold="):
+		t, err := strconv.Atoi(strings.TrimPrefix(params, "threshold="))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		threshold = t
+	default:
+		return nil, errors.Errorf("unexpected parameters %q", params)
+	}
+	return pass.NewModulePass("inline", func(m *ir.Module, am *pass.AnalysisManager) pass.Analyses {
+		n := Inline(m, am.CallGraph(), threshold)
+		if n == 0 {
+			return pass.AllAnalyses
+		}
+		numInlined.Add(n)
+		return pass.NoAnalyses
+	}), nil
+}
+
+// newSROA returns a new pass splitting aggregate alloca instructions into
+// scalars (see SROA). Functions are left unchanged if the data layout of the
+// module is invalid; the error is reported by the pass manager.
+func newSROA() pass.Pass {
+	return pass.NewFuncPass("sroa", func(f *ir.Func, am *pass.AnalysisManager) pass.Analyses {
+		dl, err := am.DataLayout()
+		if err != nil {
+			return pass.AllAnalyses
+		}
+		n := SROA(f, dl, am.DomTree(f))
+		if n == 0 {
+			return pass.AllAnalyses
+		}
+		numSROA.Add(n)
+		return pass.CFGAnalyses
+	})
+}
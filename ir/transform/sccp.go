@@ -0,0 +1,632 @@
+package transform
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/callgraph"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// === [ Sparse conditional constant propagation ] =============================
+
+// SCCP performs sparse conditional constant propagation on the given function,
+// and returns the number of simplifications (i.e. values replaced by constants
+// and conditional terminators folded into unconditional branches).
+//
+// SCCP propagates lattice values (unknown, constant or overdefined) through
+// instructions and phi instructions, following only the control flow edges
+// found to be executable. Instructions are evaluated as by ir.FoldInst. Uses of
+// values found to be constant are then replaced by the constant, removing the
+// replaced instructions without side effects, and conditional terminators with
+// a single executable successor are folded into unconditional branches.
+// Basic blocks found not to be executable are removed.
+func SCCP(f *ir.Func) int {
+	if len(f.Blocks) == 0 {
+		return 0
+	}
+	s := newSCCPSolver()
+	s.addFunc(f)
+	s.markExecutable(f.Blocks[0])
+	s.run()
+	return s.rewrite(f)
+}
+
+// IPSCCP performs interprocedural sparse conditional constant propagation on
+// the given module, and returns the number of simplifications.
+//
+// IPSCCP extends SCCP across the internal functions of the module found
+// through the call graph. Functions with internal or private linkage, whose
+// address is not taken and which are only called directly by call
+// instructions, are tracked; the lattice values of their parameters are given
+// by the arguments of executable call sites, and the lattice values of call
+// sites by the returned values of the function. Uses of parameters and call
+// sites found to be constant are replaced by the constant.
+func IPSCCP(m *ir.Module, cg *callgraph.Graph) int {
+	s := newSCCPSolver()
+	for _, f := range m.Funcs {
+		if len(f.Blocks) == 0 {
+			continue
+		}
+		s.addFunc(f)
+		if n := cg.Node(f); n != nil && isTrackable(n) {
+			s.tracked[f] = true
+			for _, param := range f.Params {
+				s.params[param] = f
+			}
+		}
+	}
+	for _, f := range m.Funcs {
+		if len(f.Blocks) > 0 && !s.tracked[f] {
+			s.markExecutable(f.Blocks[0])
+		}
+	}
+	s.run()
+	n := 0
+	for _, f := range m.Funcs {
+		if len(f.Blocks) > 0 {
+			n += s.rewrite(f)
+		}
+	}
+	return n
+}
+
+// isTrackable reports whether the parameters and return values of the function
+// of the given call graph node may be tracked across call sites.
+func isTrackable(n *callgraph.Node) bool {
+	f := n.Func
+	switch f.Linkage {
+	case enum.LinkageInternal, enum.LinkagePrivate:
+	default:
+		return false
+	}
+	if n.AddressTaken || f.Sig.Variadic {
+		return false
+	}
+	for _, e := range n.Callers {
+		call, ok := e.Site.(*ir.InstCall)
+		if !ok || e.Indirect || call.Callee != f || len(call.Args) != len(f.Params) {
+			return false
+		}
+	}
+	return true
+}
+
+// --- [ Lattice ] -------------------------------------------------------------
+
+// latticeKind is the kind of a lattice value.
+type latticeKind uint8
+
+// Lattice value kinds.
+const (
+	// The value is not yet known (e.g. not yet reached by propagation).
+	unknown latticeKind = iota
+	// The value is the constant of the lattice value.
+	constantVal
+	// The value may not be constant.
+	overdefined
+)
+
+// lattice is the lattice value of an SSA value.
+type lattice struct {
+	// Lattice value kind.
+	kind latticeKind
+	// Constant value; valid if kind is constantVal.
+	c constant.Constant
+}
+
+// meet returns the meet of the lattice values x and y.
+func meet(x, y lattice) lattice {
+	switch {
+	case x.kind == unknown:
+		return y
+	case y.kind == unknown:
+		return x
+	case x.kind == constantVal && y.kind == constantVal && constEqual(x.c, y.c):
+		return x
+	}
+	return lattice{kind: overdefined}
+}
+
+// equal reports whether the lattice values x and y are equal.
+func (x lattice) equal(y lattice) bool {
+	if x.kind != y.kind {
+		return false
+	}
+	return x.kind != constantVal || constEqual(x.c, y.c)
+}
+
+// constEqual reports whether the constants x and y are equal.
+func constEqual(x, y constant.Constant) bool {
+	if x == y {
+		return true
+	}
+	return types.Equal(x.Type(), y.Type()) && x.Ident() == y.Ident()
+}
+
+// --- [ Solver ] --------------------------------------------------------------
+
+// sccpSolver propagates lattice values through the executable control flow
+// edges of functions.
+type sccpSolver struct {
+	// Lattice values of instructions, terminators and tracked parameters.
+	vals map[value.Value]lattice
+	// Tracked functions, whose parameters and return values are propagated
+	// across call sites.
+	tracked map[*ir.Func]bool
+	// Parent function of each parameter of tracked functions.
+	params map[*ir.Param]*ir.Func
+	// Lattice value of the return value of each tracked function.
+	rets map[*ir.Func]lattice
+	// Executable call sites of each tracked function.
+	calls map[*ir.Func][]*ir.InstCall
+	// Executable basic blocks.
+	executable map[*ir.Block]bool
+	// Executable control flow edges.
+	edges map[cfgEdge]bool
+	// Parent basic block of each instruction and terminator.
+	parent map[value.User]*ir.Block
+	// Parent function of each basic block.
+	funcs map[*ir.Block]*ir.Func
+	// Executable basic blocks with unvisited instructions.
+	blockWork []*ir.Block
+	// Instructions and terminators to visit.
+	userWork []value.User
+}
+
+// cfgEdge is a control flow edge.
+type cfgEdge struct {
+	// Source basic block.
+	from *ir.Block
+	// Target basic block.
+	to *ir.Block
+}
+
+// newSCCPSolver returns a new solver.
+func newSCCPSolver() *sccpSolver {
+	return &sccpSolver{
+		vals:       make(map[value.Value]lattice),
+		tracked:    make(map[*ir.Func]bool),
+		params:     make(map[*ir.Param]*ir.Func),
+		rets:       make(map[*ir.Func]lattice),
+		calls:      make(map[*ir.Func][]*ir.InstCall),
+		executable: make(map[*ir.Block]bool),
+		edges:      make(map[cfgEdge]bool),
+		parent:     make(map[value.User]*ir.Block),
+		funcs:      make(map[*ir.Block]*ir.Func),
+	}
+}
+
+// addFunc records the parent basic blocks of the instructions and terminators
+// of the given function.
+func (s *sccpSolver) addFunc(f *ir.Func) {
+	f.InvalidateUses()
+	for _, block := range f.Blocks {
+		s.funcs[block] = f
+		for _, inst := range block.Insts {
+			s.parent[inst] = block
+		}
+		s.parent[block.Term] = block
+	}
+}
+
+// run propagates lattice values until a fixed point is reached. Conditional
+// terminators of executable basic blocks with an unknown condition (e.g. the
+// result of a function which never returns) are then resolved by marking all
+// their successors executable, and propagation is repeated.
+func (s *sccpSolver) run() {
+	for {
+		s.solve()
+		resolved := false
+		for block := range s.executable {
+			switch term := block.Term.(type) {
+			case *ir.TermCondBr:
+				if s.get(term.Cond).kind == unknown {
+					resolved = s.markSuccs(block) || resolved
+				}
+			case *ir.TermSwitch:
+				if s.get(term.X).kind == unknown {
+					resolved = s.markSuccs(block) || resolved
+				}
+			}
+		}
+		if !resolved {
+			return
+		}
+	}
+}
+
+// solve propagates lattice values until the work lists are empty.
+func (s *sccpSolver) solve() {
+	for len(s.blockWork) > 0 || len(s.userWork) > 0 {
+		for len(s.userWork) > 0 {
+			user := s.userWork[len(s.userWork)-1]
+			s.userWork = s.userWork[:len(s.userWork)-1]
+			if s.executable[s.parent[user]] {
+				s.visit(user)
+			}
+		}
+		for len(s.blockWork) > 0 {
+			block := s.blockWork[len(s.blockWork)-1]
+			s.blockWork = s.blockWork[:len(s.blockWork)-1]
+			for _, inst := range block.Insts {
+				s.visit(inst)
+			}
+			s.visit(block.Term)
+		}
+	}
+}
+
+// markExecutable marks the given basic block as executable.
+func (s *sccpSolver) markExecutable(block *ir.Block) {
+	if s.executable[block] {
+		return
+	}
+	s.executable[block] = true
+	s.blockWork = append(s.blockWork, block)
+}
+
+// markEdge marks the control flow edge from the basic block from to the basic
+// block to as executable, and reports whether the edge was not already marked.
+func (s *sccpSolver) markEdge(from, to *ir.Block) bool {
+	e := cfgEdge{from: from, to: to}
+	if s.edges[e] {
+		return false
+	}
+	s.edges[e] = true
+	if !s.executable[to] {
+		s.markExecutable(to)
+		return true
+	}
+	// Revisit the phi instructions of the already executable target, as the
+	// incoming value from the edge is now live.
+	for _, inst := range to.Insts {
+		if _, ok := inst.(*ir.InstPhi); !ok {
+			break
+		}
+		s.userWork = append(s.userWork, inst)
+	}
+	return true
+}
+
+// markSuccs marks the control flow edges to the successors of the given basic
+// block as executable, and reports whether any edge was not already marked.
+func (s *sccpSolver) markSuccs(block *ir.Block) bool {
+	marked := false
+	for _, succ := range block.Term.Succs() {
+		marked = s.markEdge(block, succ) || marked
+	}
+	return marked
+}
+
+// get returns the lattice value of the given value.
+func (s *sccpSolver) get(v value.Value) lattice {
+	if arg, ok := v.(*ir.Arg); ok {
+		v = arg.Value
+	}
+	switch v := v.(type) {
+	case ir.Instruction, ir.Terminator:
+		return s.vals[v]
+	case *ir.Param:
+		if _, ok := s.params[v]; ok {
+			return s.vals[v]
+		}
+	case *ir.InlineAsm:
+		// Inline assembly is not a first-class constant.
+	case constant.Constant:
+		return lattice{kind: constantVal, c: v}
+	}
+	return lattice{kind: overdefined}
+}
+
+// set merges l into the lattice value of v, and revisits the users of v if the
+// lattice value changed.
+func (s *sccpSolver) set(v value.Value, l lattice) {
+	old := s.vals[v]
+	new := meet(old, l)
+	if new.equal(old) {
+		return
+	}
+	s.vals[v] = new
+	var f *ir.Func
+	switch v := v.(type) {
+	case *ir.Param:
+		f = s.params[v]
+	case value.User:
+		f = s.funcs[s.parent[v]]
+	}
+	if f == nil {
+		return
+	}
+	for _, use := range f.Users(v) {
+		if user, ok := use.User.(value.User); ok {
+			if _, ok := s.parent[user]; ok {
+				s.userWork = append(s.userWork, user)
+			}
+		}
+	}
+}
+
+// visit evaluates the given instruction or terminator of an executable basic
+// block.
+func (s *sccpSolver) visit(user value.User) {
+	switch user := user.(type) {
+	case *ir.InstPhi:
+		s.visitPhi(user)
+	case *ir.InstCall:
+		s.visitCall(user)
+	case *ir.InstSelect:
+		s.visitSelect(user)
+	case ir.Instruction:
+		s.visitInst(user)
+	case ir.Terminator:
+		s.visitTerm(user)
+	}
+}
+
+// visitPhi evaluates the given phi instruction as the meet of its incoming
+// values from executable control flow edges.
+func (s *sccpSolver) visitPhi(phi *ir.InstPhi) {
+	block := s.parent[phi]
+	var l lattice
+	for _, inc := range phi.Incs {
+		pred, ok := inc.Pred.(*ir.Block)
+		if !ok || !s.edges[cfgEdge{from: pred, to: block}] {
+			continue
+		}
+		l = meet(l, s.get(inc.X))
+		if l.kind == overdefined {
+			break
+		}
+	}
+	s.set(phi, l)
+}
+
+// visitCall evaluates the given call instruction. Calls to tracked functions
+// propagate their arguments to the parameters of the callee, and evaluate to
+// the return value of the callee; other calls are overdefined.
+func (s *sccpSolver) visitCall(call *ir.InstCall) {
+	callee, ok := call.Callee.(*ir.Func)
+	if !ok || !s.tracked[callee] {
+		s.setOverdefined(call)
+		return
+	}
+	if !containsCall(s.calls[callee], call) {
+		s.calls[callee] = append(s.calls[callee], call)
+	}
+	for i, arg := range call.Args {
+		if l := s.get(arg); l.kind != unknown {
+			s.set(callee.Params[i], l)
+		}
+	}
+	s.markExecutable(callee.Blocks[0])
+	if !types.Equal(call.Type(), types.Void) {
+		if l := s.rets[callee]; l.kind != unknown {
+			s.set(call, l)
+		}
+	}
+}
+
+// visitSelect evaluates the given select instruction. If the condition is a
+// constant, the select evaluates to the selected operand.
+func (s *sccpSolver) visitSelect(inst *ir.InstSelect) {
+	cond := s.get(inst.Cond)
+	switch cond.kind {
+	case unknown:
+		return
+	case constantVal:
+		if c, ok := cond.c.(*constant.Int); ok {
+			if c.X.Sign() != 0 {
+				s.set(inst, s.get(inst.ValueTrue))
+			} else {
+				s.set(inst, s.get(inst.ValueFalse))
+			}
+			return
+		}
+	}
+	if x, y := s.get(inst.ValueTrue), s.get(inst.ValueFalse); x.kind == constantVal && y.kind == constantVal && constEqual(x.c, y.c) {
+		s.set(inst, x)
+		return
+	}
+	s.visitInst(inst)
+}
+
+// visitInst evaluates the given instruction by constant folding, substituting
+// the lattice values of its operands.
+func (s *sccpSolver) visitInst(inst ir.Instruction) {
+	v, ok := inst.(value.Value)
+	if !ok || types.Equal(v.Type(), types.Void) {
+		return
+	}
+	ops := inst.Operands()
+	consts := make([]constant.Constant, len(ops))
+	for i, op := range ops {
+		l := s.get(*op)
+		switch l.kind {
+		case unknown:
+			return
+		case overdefined:
+			s.setOverdefined(v)
+			return
+		}
+		consts[i] = l.c
+	}
+	// Substitute operands temporarily to fold the instruction.
+	orig := make([]value.Value, len(ops))
+	for i, op := range ops {
+		orig[i] = *op
+		*op = consts[i]
+	}
+	c := ir.FoldInst(inst)
+	for i, op := range ops {
+		*op = orig[i]
+	}
+	if c == nil {
+		s.setOverdefined(v)
+		return
+	}
+	s.set(v, lattice{kind: constantVal, c: c})
+}
+
+// visitTerm evaluates the given terminator, marking its executable successor
+// edges.
+func (s *sccpSolver) visitTerm(term ir.Terminator) {
+	block := s.parent[term]
+	switch term := term.(type) {
+	case *ir.TermRet:
+		f := s.funcs[block]
+		if !s.tracked[f] || term.X == nil {
+			return
+		}
+		l := s.get(term.X)
+		if l.kind == unknown {
+			return
+		}
+		old := s.rets[f]
+		new := meet(old, l)
+		if new.equal(old) {
+			return
+		}
+		s.rets[f] = new
+		for _, call := range s.calls[f] {
+			s.userWork = append(s.userWork, call)
+		}
+	case *ir.TermBr:
+		s.markEdge(block, term.Target.(*ir.Block))
+	case *ir.TermCondBr:
+		l := s.get(term.Cond)
+		switch l.kind {
+		case unknown:
+			return
+		case constantVal:
+			if c, ok := l.c.(*constant.Int); ok {
+				if c.X.Sign() != 0 {
+					s.markEdge(block, term.TargetTrue.(*ir.Block))
+				} else {
+					s.markEdge(block, term.TargetFalse.(*ir.Block))
+				}
+				return
+			}
+		}
+		s.markSuccs(block)
+	case *ir.TermSwitch:
+		l := s.get(term.X)
+		switch l.kind {
+		case unknown:
+			return
+		case constantVal:
+			if x, ok := l.c.(*constant.Int); ok {
+				s.markEdge(block, switchTarget(term, x))
+				return
+			}
+		}
+		s.markSuccs(block)
+	default:
+		if v, ok := term.(value.Value); ok && !types.Equal(v.Type(), types.Void) {
+			s.setOverdefined(v)
+		}
+		s.markSuccs(block)
+	}
+}
+
+// setOverdefined marks the given value as overdefined.
+func (s *sccpSolver) setOverdefined(v value.Value) {
+	s.set(v, lattice{kind: overdefined})
+}
+
+// --- [ Rewriting ] -----------------------------------------------------------
+
+// rewrite replaces the uses of values of the given function found to be
+// constant, folds conditional terminators with a single executable successor,
+// and removes basic blocks found not to be executable. The number of
+// simplifications is returned.
+func (s *sccpSolver) rewrite(f *ir.Func) int {
+	// Functions never found executable (e.g. tracked functions without
+	// executable call sites) are left as is.
+	if !s.executable[f.Blocks[0]] {
+		return 0
+	}
+	n := 0
+	repl := make(map[value.Value]value.Value)
+	dead := make(map[ir.Instruction]bool)
+	if s.tracked[f] {
+		for _, param := range f.Params {
+			if l := s.vals[param]; l.kind == constantVal && len(f.Users(param)) > 0 {
+				repl[param] = l.c
+				n++
+			}
+		}
+	}
+	for _, block := range f.Blocks {
+		if !s.executable[block] {
+			continue
+		}
+		for _, inst := range block.Insts {
+			v, ok := inst.(value.Value)
+			if !ok {
+				continue
+			}
+			if l := s.vals[v]; l.kind == constantVal {
+				if mayHaveSideEffects(inst) && len(f.Users(v)) == 0 {
+					continue
+				}
+				repl[v] = l.c
+				if !mayHaveSideEffects(inst) {
+					dead[inst] = true
+				}
+				n++
+			}
+		}
+	}
+	for _, block := range f.Blocks {
+		if !s.executable[block] {
+			continue
+		}
+		switch block.Term.(type) {
+		case *ir.TermCondBr, *ir.TermSwitch:
+			var target *ir.Block
+			succs := 0
+			for _, succ := range block.Term.Succs() {
+				if succ != target && s.edges[cfgEdge{from: block, to: succ}] {
+					target = succ
+					succs++
+				}
+			}
+			if succs == 1 {
+				foldToBr(block, target)
+				n++
+			}
+		}
+	}
+	replaceAll(f, repl)
+	removeInsts(f, dead)
+	removeUnreachableBlocks(f)
+	if n > 0 {
+		invalidate(f)
+	}
+	return n
+}
+
+// ### [ Helper functions ] ####################################################
+
+// switchTarget returns the target basic block of the given switch terminator
+// for the control variable x.
+func switchTarget(term *ir.TermSwitch, x *constant.Int) *ir.Block {
+	for _, c := range term.Cases {
+		if y, ok := c.X.(*constant.Int); ok && x.X.Cmp(y.X) == 0 {
+			return c.Target.(*ir.Block)
+		}
+	}
+	return term.TargetDefault.(*ir.Block)
+}
+
+// containsCall reports whether calls contains the given call instruction.
+func containsCall(calls []*ir.InstCall, call *ir.InstCall) bool {
+	for _, c := range calls {
+		if c == call {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,180 @@
+package transform_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir/callgraph"
+	"github.com/llir/llvm/ir/transform"
+	"github.com/llir/llvm/ir/verify"
+)
+
+func TestSCCP(t *testing.T) {
+	golden := []struct {
+		name string
+		in   string
+		// Expected number of simplifications.
+		n    int
+		want string
+	}{
+		{
+			name: "constant branch",
+			in: `
+define i32 @f(i32 %n) {
+entry:
+	%x = add i32 1, 2
+	%c = icmp eq i32 %x, 3
+	br i1 %c, label %then, label %else
+
+then:
+	%a = mul i32 %x, %n
+	br label %join
+
+else:
+	%b = sub i32 %x, 1
+	br label %join
+
+join:
+	%p = phi i32 [ %x, %then ], [ %b, %else ]
+	%q = add i32 %p, 1
+	ret i32 %q
+}
+`,
+			// Constant %x, %c, %p and %q (4), and folded branch (1).
+			n: 5,
+			want: `
+define i32 @f(i32 %n) {
+entry:
+	br label %then
+
+then:
+	%a = mul i32 3, %n
+	br label %join
+
+join:
+	ret i32 4
+}
+`,
+		},
+		{
+			name: "loop",
+			in: `
+define i32 @f(i32 %n) {
+entry:
+	br label %loop
+
+loop:
+	%x = phi i32 [ 1, %entry ], [ %y, %latch ]
+	%c = icmp eq i32 %x, 1
+	br i1 %c, label %latch, label %never
+
+never:
+	br label %latch
+
+latch:
+	%y = phi i32 [ %x, %loop ], [ 2, %never ]
+	%cont = icmp slt i32 %n, 10
+	br i1 %cont, label %loop, label %exit
+
+exit:
+	ret i32 %y
+}
+`,
+			// The edge to never is not executable, so %x and %y are constant.
+			n: 4,
+			want: `
+define i32 @f(i32 %n) {
+entry:
+	br label %loop
+
+loop:
+	br label %latch
+
+latch:
+	%cont = icmp slt i32 %n, 10
+	br i1 %cont, label %loop, label %exit
+
+exit:
+	ret i32 1
+}
+`,
+		},
+	}
+	for _, g := range golden {
+		runGolden(t, g.name, g.in, g.n, g.want, transform.SCCP)
+	}
+}
+
+func TestIPSCCP(t *testing.T) {
+	const in = `
+define i32 @main(i32 %n) {
+entry:
+	%a = call i32 @sq(i32 3)
+	%b = call i32 @sq(i32 3)
+	%c = call i32 @id(i32 %n)
+	%d = add i32 %a, %b
+	%e = add i32 %d, %c
+	ret i32 %e
+}
+
+define internal i32 @sq(i32 %x) {
+entry:
+	%y = mul i32 %x, %x
+	%big = icmp sgt i32 %y, 100
+	br i1 %big, label %clamp, label %exit
+
+clamp:
+	br label %exit
+
+exit:
+	%r = phi i32 [ 100, %clamp ], [ %y, %entry ]
+	ret i32 %r
+}
+
+define internal i32 @id(i32 %x) {
+entry:
+	ret i32 %x
+}
+`
+	// Calls to @sq are kept, as @sq is not readnone.
+	const want = `
+define i32 @main(i32 %n) {
+entry:
+	%a = call i32 @sq(i32 3)
+	%b = call i32 @sq(i32 3)
+	%c = call i32 @id(i32 %n)
+	%e = add i32 18, %c
+	ret i32 %e
+}
+
+define internal i32 @sq(i32 %x) {
+entry:
+	br label %exit
+
+exit:
+	ret i32 9
+}
+
+define internal i32 @id(i32 %x) {
+entry:
+	ret i32 %x
+}
+`
+	m, err := asm.ParseString("ipsccp.ll", in)
+	if err != nil {
+		t.Fatalf("unable to parse module; %+v", err)
+	}
+	// Parameter %x of @sq (1), %y, %big and %r (3), folded branch (1), and %a,
+	// %b and %d (3).
+	if got, n := transform.IPSCCP(m, callgraph.New(m)), 8; got != n {
+		t.Errorf("number of changes mismatch; expected %d, got %d", n, got)
+	}
+	for _, err := range verify.Module(m) {
+		t.Errorf("unexpected verification error; %v", err)
+	}
+	got := strings.TrimSpace(m.String())
+	if want := strings.TrimSpace(want); got != want {
+		t.Errorf("output mismatch; expected\n%s\n\ngot\n%s", want, got)
+	}
+}
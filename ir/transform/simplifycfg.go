@@ -0,0 +1,406 @@
+package transform
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/value"
+)
+
+// SimplifyCFG simplifies the control flow graph of the given function, and
+// returns the number of performed simplifications.
+//
+// The following simplifications are performed until a fixed point is reached:
+//
+//   - basic blocks not reachable from the entry basic block are removed;
+//   - conditional br terminators with a constant condition or with identical
+//     targets, and switch terminators with a constant control variable or
+//     without distinct case targets, are folded into unconditional branches;
+//   - switch terminators with a single case are turned into conditional br
+//     terminators;
+//   - basic blocks are merged into their single predecessor, if the predecessor
+//     has a single successor;
+//   - empty basic blocks forwarding control flow to their successor are removed,
+//     redirecting their predecessors to the successor;
+//   - identical instructions at the top of both arms of a diamond are hoisted
+//     into the branching basic block.
+//
+// Incoming values of phi instructions are kept consistent with the control
+// flow edges of the function. Basic blocks with their address taken (i.e.
+// used by a blockaddress constant) are neither merged nor removed.
+func SimplifyCFG(f *ir.Func) int {
+	if len(f.Blocks) == 0 {
+		return 0
+	}
+	total := 0
+	for {
+		s := &cfgSimplifier{
+			f:       f,
+			removed: make(map[*ir.Block]bool),
+		}
+		n := len(f.Blocks)
+		removeUnreachableBlocks(f)
+		n -= len(f.Blocks)
+		n += s.simplify()
+		if n == 0 {
+			break
+		}
+		total += n
+	}
+	if total > 0 {
+		f.ResetIDs()
+		invalidate(f)
+	}
+	return total
+}
+
+// cfgSimplifier performs a round of control flow graph simplifications of a
+// function.
+type cfgSimplifier struct {
+	// Function being transformed.
+	f *ir.Func
+	// Predecessors of each basic block, with one entry per incoming control
+	// flow edge.
+	preds map[*ir.Block][]*ir.Block
+	// Basic blocks removed during the round.
+	removed map[*ir.Block]bool
+}
+
+// simplify performs a round of simplifications, and returns the number of
+// performed simplifications.
+func (s *cfgSimplifier) simplify() int {
+	n := 0
+	for _, block := range s.f.Blocks {
+		if foldTerm(block) {
+			n++
+		}
+	}
+	s.preds = make(map[*ir.Block][]*ir.Block)
+	for _, block := range s.f.Blocks {
+		for _, succ := range block.Term.Succs() {
+			s.preds[succ] = append(s.preds[succ], block)
+		}
+	}
+	entry := s.f.Blocks[0]
+	for _, block := range s.f.Blocks {
+		if block == entry || s.removed[block] {
+			continue
+		}
+		if s.mergeIntoPred(block) || s.removeForwardingBlock(block) {
+			s.removed[block] = true
+			n++
+		}
+	}
+	if len(s.removed) > 0 {
+		var blocks []*ir.Block
+		for _, block := range s.f.Blocks {
+			if !s.removed[block] {
+				blocks = append(blocks, block)
+			}
+		}
+		s.f.Blocks = blocks
+		s.f.ResetIDs()
+		invalidate(s.f)
+	}
+	// Operands are identified by name when comparing instructions for hoisting.
+	s.f.ResetIDs()
+	if err := s.f.AssignIDs(); err != nil {
+		return n
+	}
+	for _, block := range s.f.Blocks {
+		n += s.hoistCommonInsts(block)
+	}
+	return n
+}
+
+// --- [ Terminator folding ] --------------------------------------------------
+
+// foldTerm folds the conditional terminator of the given basic block into an
+// unconditional branch if its target is known, or simplifies a switch
+// terminator with a single case into a conditional br terminator. The incoming
+// values of phi instructions in successors no longer branched to are removed.
+func foldTerm(block *ir.Block) bool {
+	switch term := block.Term.(type) {
+	case *ir.TermCondBr:
+		if term.TargetTrue == term.TargetFalse {
+			return foldToBr(block, term.TargetTrue.(*ir.Block))
+		}
+		if cond, ok := term.Cond.(*constant.Int); ok {
+			if cond.X.Sign() != 0 {
+				return foldToBr(block, term.TargetTrue.(*ir.Block))
+			}
+			return foldToBr(block, term.TargetFalse.(*ir.Block))
+		}
+	case *ir.TermSwitch:
+		if x, ok := term.X.(*constant.Int); ok {
+			return foldToBr(block, switchTarget(term, x))
+		}
+		target := term.TargetDefault.(*ir.Block)
+		distinct := false
+		for _, c := range term.Cases {
+			if c.Target != target {
+				distinct = true
+				break
+			}
+		}
+		if !distinct {
+			return foldToBr(block, target)
+		}
+		if len(term.Cases) == 1 {
+			c := term.Cases[0]
+			cond := ir.NewICmp(enum.IPredEQ, term.X, c.X)
+			block.Insts = append(block.Insts, cond)
+			block.Term = ir.NewCondBr(cond, c.Target.(*ir.Block), target)
+			return true
+		}
+	}
+	return false
+}
+
+// foldToBr replaces the terminator of the given basic block with an
+// unconditional branch to target, removing the incoming values from the basic
+// block of phi instructions in other successors, and all but one incoming value
+// from the basic block of phi instructions in target.
+func foldToBr(block, target *ir.Block) bool {
+	for _, succ := range block.Term.Succs() {
+		if succ != target {
+			removePhiIncs(succ, block)
+		}
+	}
+	dedupPhiIncs(target, block)
+	block.Term = ir.NewBr(target)
+	return true
+}
+
+// --- [ Basic block merging ] -------------------------------------------------
+
+// mergeIntoPred merges the given basic block into its predecessor, if the
+// basic block has a single predecessor which has a single successor.
+func (s *cfgSimplifier) mergeIntoPred(block *ir.Block) bool {
+	preds := s.preds[block]
+	if len(preds) != 1 {
+		return false
+	}
+	pred := preds[0]
+	if pred == block {
+		return false
+	}
+	if _, ok := pred.Term.(*ir.TermBr); !ok {
+		return false
+	}
+	if isAddressTaken(s.f, block) {
+		return false
+	}
+	// Phi instructions of the basic block have a single incoming value.
+	repl := make(map[value.Value]value.Value)
+	insts := block.Insts
+	for len(insts) > 0 {
+		phi, ok := insts[0].(*ir.InstPhi)
+		if !ok {
+			break
+		}
+		repl[phi] = phi.Incs[0].X
+		insts = insts[1:]
+	}
+	pred.Insts = append(pred.Insts, insts...)
+	pred.Term = block.Term
+	block.Insts = nil
+	for _, succ := range block.Term.Succs() {
+		replacePhiPred(succ, block, pred)
+		s.replacePred(succ, block, pred)
+	}
+	delete(s.preds, block)
+	replaceAll(s.f, repl)
+	return true
+}
+
+// --- [ Forwarding block removal ] --------------------------------------------
+
+// removeForwardingBlock removes the given basic block if it is empty and
+// unconditionally branches to a successor, redirecting its predecessors to the
+// successor.
+func (s *cfgSimplifier) removeForwardingBlock(block *ir.Block) bool {
+	if len(block.Insts) > 0 {
+		return false
+	}
+	br, ok := block.Term.(*ir.TermBr)
+	if !ok {
+		return false
+	}
+	succ := br.Target.(*ir.Block)
+	if succ == block {
+		return false
+	}
+	preds := s.preds[block]
+	for _, pred := range preds {
+		switch pred.Term.(type) {
+		case *ir.TermBr, *ir.TermCondBr, *ir.TermSwitch:
+			// Redirectable terminator.
+		default:
+			return false
+		}
+	}
+	if isAddressTaken(s.f, block) {
+		return false
+	}
+	// Predecessors of both the basic block and its successor must have the
+	// same incoming value in the phi instructions of the successor.
+	for _, inst := range succ.Insts {
+		phi, ok := inst.(*ir.InstPhi)
+		if !ok {
+			break
+		}
+		x := incValue(phi, block)
+		for _, pred := range preds {
+			if y := incValue(phi, pred); y != nil && y != x {
+				return false
+			}
+		}
+	}
+	// Record the incoming values of the basic block before removing them from
+	// the phi instructions of the successor.
+	var phis []*ir.InstPhi
+	var xs []value.Value
+	for _, inst := range succ.Insts {
+		phi, ok := inst.(*ir.InstPhi)
+		if !ok {
+			break
+		}
+		phis = append(phis, phi)
+		xs = append(xs, incValue(phi, block))
+	}
+	removePhiIncs(succ, block)
+	for i, phi := range phis {
+		for _, pred := range preds {
+			phi.Incs = append(phi.Incs, ir.NewIncoming(xs[i], pred))
+		}
+	}
+	s.replacePred(succ, block)
+	for _, pred := range preds {
+		replaceSucc(pred.Term, block, succ)
+		s.preds[succ] = append(s.preds[succ], pred)
+	}
+	delete(s.preds, block)
+	return true
+}
+
+// --- [ Hoisting ] ------------------------------------------------------------
+
+// hoistCommonInsts hoists identical instructions at the top of both successors
+// of the conditional br terminator of the given basic block into the basic
+// block, if the basic block is the single predecessor of both successors. The
+// number of hoisted instructions is returned.
+func (s *cfgSimplifier) hoistCommonInsts(block *ir.Block) int {
+	term, ok := block.Term.(*ir.TermCondBr)
+	if !ok {
+		return 0
+	}
+	t, f := term.TargetTrue.(*ir.Block), term.TargetFalse.(*ir.Block)
+	if t == f || t == block || f == block {
+		return 0
+	}
+	if len(s.preds[t]) != 1 || len(s.preds[f]) != 1 {
+		return 0
+	}
+	n := 0
+	for len(t.Insts) > 0 && len(f.Insts) > 0 {
+		x, y := t.Insts[0], f.Insts[0]
+		if !isHoistable(x) || instKey(x) != instKey(y) {
+			break
+		}
+		block.Insts = append(block.Insts, x)
+		t.Insts = t.Insts[1:]
+		f.Insts = f.Insts[1:]
+		if v, ok := x.(value.Value); ok {
+			replaceAll(s.f, map[value.Value]value.Value{y.(value.Value): v})
+		}
+		n++
+	}
+	if n > 0 {
+		invalidate(s.f)
+	}
+	return n
+}
+
+// isHoistable reports whether the given instruction may be hoisted out of its
+// basic block.
+func isHoistable(inst ir.Instruction) bool {
+	switch inst.(type) {
+	case *ir.InstPhi, *ir.InstLandingPad, *ir.InstCatchPad, *ir.InstCleanupPad:
+		return false
+	}
+	return true
+}
+
+// ### [ Helper functions ] ####################################################
+
+// replacePred replaces the predecessor old of the given basic block with new
+// in the predecessor lists of the simplifier; or removes old if new is nil.
+func (s *cfgSimplifier) replacePred(block, old *ir.Block, new ...*ir.Block) {
+	preds := s.preds[block][:0]
+	for _, pred := range s.preds[block] {
+		if pred == old {
+			preds = append(preds, new...)
+			continue
+		}
+		preds = append(preds, pred)
+	}
+	s.preds[block] = preds
+}
+
+// replaceSucc replaces the successor old of the given br, conditional br or
+// switch terminator with new.
+func replaceSucc(term ir.Terminator, old, new *ir.Block) {
+	switch term := term.(type) {
+	case *ir.TermBr:
+		if term.Target == old {
+			term.Target = new
+		}
+		term.Successors = nil
+	case *ir.TermCondBr:
+		if term.TargetTrue == old {
+			term.TargetTrue = new
+		}
+		if term.TargetFalse == old {
+			term.TargetFalse = new
+		}
+		term.Successors = nil
+	case *ir.TermSwitch:
+		if term.TargetDefault == old {
+			term.TargetDefault = new
+		}
+		for _, c := range term.Cases {
+			if c.Target == old {
+				c.Target = new
+			}
+		}
+		term.Successors = nil
+	}
+}
+
+// replacePhiPred replaces the predecessor basic block old of the incoming
+// values of phi instructions in the given basic block with new.
+func replacePhiPred(block, old, new *ir.Block) {
+	for _, inst := range block.Insts {
+		phi, ok := inst.(*ir.InstPhi)
+		if !ok {
+			break
+		}
+		for _, inc := range phi.Incs {
+			if inc.Pred == old {
+				inc.Pred = new
+			}
+		}
+	}
+}
+
+// incValue returns the incoming value from the predecessor basic block pred of
+// the given phi instruction; or nil if not present.
+func incValue(phi *ir.InstPhi, pred *ir.Block) value.Value {
+	for _, inc := range phi.Incs {
+		if inc.Pred == pred {
+			return inc.X
+		}
+	}
+	return nil
+}
@@ -0,0 +1,285 @@
+package transform_test
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/ir/transform"
+)
+
+func TestSimplifyCFG(t *testing.T) {
+	golden := []struct {
+		name string
+		in   string
+		// Expected number of simplifications.
+		n    int
+		want string
+	}{
+		{
+			name: "br chain",
+			in: `
+define i32 @f(i32 %x) {
+entry:
+	%a = add i32 %x, 1
+	br label %bb1
+
+bb1:
+	%b = mul i32 %a, 2
+	br label %bb2
+
+bb2:
+	%p = phi i32 [ %b, %bb1 ]
+	br label %bb3
+
+bb3:
+	ret i32 %p
+}
+`,
+			n: 3,
+			want: `
+define i32 @f(i32 %x) {
+entry:
+	%a = add i32 %x, 1
+	%b = mul i32 %a, 2
+	ret i32 %b
+}
+`,
+		},
+		{
+			name: "constant condbr",
+			in: `
+define i32 @f(i32 %x) {
+entry:
+	br i1 true, label %then, label %else
+
+then:
+	%a = add i32 %x, 1
+	br label %join
+
+else:
+	%b = mul i32 %x, 2
+	br label %join
+
+join:
+	%p = phi i32 [ %a, %then ], [ %b, %else ]
+	ret i32 %p
+}
+`,
+			// Folded branch (1), merged then (1), removed else (1) and merged join (1).
+			n: 4,
+			want: `
+define i32 @f(i32 %x) {
+entry:
+	%a = add i32 %x, 1
+	ret i32 %a
+}
+`,
+		},
+		{
+			name: "switch",
+			in: `
+define i32 @f(i32 %x) {
+entry:
+	switch i32 2, label %def [
+		i32 1, label %one
+		i32 2, label %two
+	]
+
+one:
+	br label %exit
+
+two:
+	switch i32 %x, label %def [
+		i32 7, label %one
+	]
+
+def:
+	br label %exit
+
+exit:
+	%p = phi i32 [ 1, %one ], [ 0, %def ]
+	ret i32 %p
+}
+`,
+			// Folded switch (1), single case switch (1), merged two (1) and
+			// removed one (1). The forwarding block def is kept, as entry already
+			// branches to exit with a different incoming value.
+			n: 4,
+			want: `
+define i32 @f(i32 %x) {
+entry:
+	%0 = icmp eq i32 %x, 7
+	br i1 %0, label %exit, label %def
+
+def:
+	br label %exit
+
+exit:
+	%p = phi i32 [ 0, %def ], [ 1, %entry ]
+	ret i32 %p
+}
+`,
+		},
+		{
+			name: "forwarding block",
+			in: `
+define i32 @f(i32 %x, i1 %c) {
+entry:
+	br i1 %c, label %then, label %fwd
+
+then:
+	%a = add i32 %x, 1
+	br label %exit
+
+fwd:
+	br label %exit
+
+exit:
+	%p = phi i32 [ %a, %then ], [ 0, %fwd ]
+	ret i32 %p
+}
+`,
+			n: 1,
+			want: `
+define i32 @f(i32 %x, i1 %c) {
+entry:
+	br i1 %c, label %then, label %exit
+
+then:
+	%a = add i32 %x, 1
+	br label %exit
+
+exit:
+	%p = phi i32 [ %a, %then ], [ 0, %entry ]
+	ret i32 %p
+}
+`,
+		},
+		{
+			// Each phi instruction of the successor keeps its own incoming value
+			// of the removed forwarding block other.
+			name: "forwarding block with multiple phis",
+			in: `
+define i32 @f(i32 %a, i32 %b, i1 %c) {
+entry:
+	br i1 %c, label %fwd, label %other
+
+other:
+	br label %join
+
+fwd:
+	br label %join
+
+join:
+	%x = phi i32 [ %a, %fwd ], [ %b, %other ]
+	%y = phi i32 [ %b, %fwd ], [ %a, %other ]
+	%z = add i32 %x, %y
+	ret i32 %z
+}
+`,
+			n: 1,
+			want: `
+define i32 @f(i32 %a, i32 %b, i1 %c) {
+entry:
+	br i1 %c, label %fwd, label %join
+
+fwd:
+	br label %join
+
+join:
+	%x = phi i32 [ %a, %fwd ], [ %b, %entry ]
+	%y = phi i32 [ %b, %fwd ], [ %a, %entry ]
+	%z = add i32 %x, %y
+	ret i32 %z
+}
+`,
+		},
+		{
+			name: "conflicting phi",
+			in: `
+define i32 @f(i32 %x, i1 %c, i1 %d) {
+entry:
+	br i1 %c, label %fwd, label %other
+
+other:
+	br i1 %d, label %fwd, label %exit
+
+fwd:
+	br label %exit
+
+exit:
+	%p = phi i32 [ 1, %fwd ], [ 2, %other ]
+	ret i32 %p
+}
+`,
+			n: 0,
+			want: `
+define i32 @f(i32 %x, i1 %c, i1 %d) {
+entry:
+	br i1 %c, label %fwd, label %other
+
+other:
+	br i1 %d, label %fwd, label %exit
+
+fwd:
+	br label %exit
+
+exit:
+	%p = phi i32 [ 1, %fwd ], [ 2, %other ]
+	ret i32 %p
+}
+`,
+		},
+		{
+			name: "hoist",
+			in: `
+define i32 @f(i32 %x, ptr %q, i1 %c) {
+entry:
+	br i1 %c, label %then, label %else
+
+then:
+	%a = add nsw i32 %x, 1
+	%b = mul i32 %a, 3
+	store i32 %b, ptr %q
+	%t = sub i32 %b, 1
+	br label %join
+
+else:
+	%c1 = add nsw i32 %x, 1
+	%d = mul i32 %c1, 3
+	store i32 %d, ptr %q
+	%e = sub i32 %d, 2
+	br label %join
+
+join:
+	%p = phi i32 [ %t, %then ], [ %e, %else ]
+	ret i32 %p
+}
+`,
+			n: 3,
+			want: `
+define i32 @f(i32 %x, ptr %q, i1 %c) {
+entry:
+	%a = add nsw i32 %x, 1
+	%b = mul i32 %a, 3
+	store i32 %b, ptr %q
+	br i1 %c, label %then, label %else
+
+then:
+	%t = sub i32 %b, 1
+	br label %join
+
+else:
+	%e = sub i32 %b, 2
+	br label %join
+
+join:
+	%p = phi i32 [ %t, %then ], [ %e, %else ]
+	ret i32 %p
+}
+`,
+		},
+	}
+	for _, g := range golden {
+		runGolden(t, g.name, g.in, g.n, g.want, transform.SimplifyCFG)
+	}
+}
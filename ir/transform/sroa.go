@@ -0,0 +1,477 @@
+package transform
+
+import (
+	"fmt"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/datalayout"
+	"github.com/llir/llvm/ir/dom"
+	"github.com/llir/llvm/ir/metadata"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// === [ Scalar replacement of aggregates ] ====================================
+
+// sroaMaxScalars is the maximum number of scalars of aggregate alloca
+// instructions split by SROA.
+const sroaMaxScalars = 64
+
+// SROA splits the aggregate alloca instructions of the given function into one
+// alloca instruction per accessed scalar field, and promotes the split alloca
+// instructions to SSA registers (see Mem2Reg). The number of split alloca
+// instructions is returned.
+//
+// An alloca instruction of struct or array type is split if each of its uses
+// may be resolved to a constant byte offset within the allocated aggregate,
+// using the struct layouts of the given data layout. The allocated aggregate
+// may only be accessed through getelementptr instructions with constant
+// indices and bitcast instructions, by non-volatile load and store
+// instructions of either a scalar field or an aggregate of fields at the
+// matching byte offset. Loads and stores of aggregates are split into accesses
+// of each scalar field, using extractvalue and insertvalue instructions.
+//
+// Alloca instructions are not split if their address escapes (e.g. passed to a
+// call, stored to memory or compared), is accessed through a getelementptr
+// instruction with variable indices, or is used by intrinsics other than
+// llvm.lifetime (e.g. llvm.memcpy). The llvm.lifetime and llvm.dbg.declare
+// intrinsics of split alloca instructions are removed.
+//
+// domTree is the dominator tree of the function, which is preserved by SROA.
+func SROA(f *ir.Func, dl *datalayout.DataLayout, domTree *dom.Tree) int {
+	var allocas []*ir.InstAlloca
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			if alloca, ok := inst.(*ir.InstAlloca); ok {
+				allocas = append(allocas, alloca)
+			}
+		}
+	}
+	n := 0
+	for _, alloca := range allocas {
+		if s := newSplitter(f, dl, alloca); s != nil && s.analyze() {
+			s.split()
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	f.ResetIDs()
+	invalidate(f)
+	Mem2Reg(f, domTree)
+	return n
+}
+
+// splitter splits an aggregate alloca instruction into scalars.
+type splitter struct {
+	// Function being transformed.
+	f *ir.Func
+	// Data layout of the module.
+	dl *datalayout.DataLayout
+	// Aggregate alloca instruction.
+	alloca *ir.InstAlloca
+	// Scalar fields of the allocated aggregate, in order of byte offset.
+	scalars []scalar
+	// Index in scalars of the scalar field at each byte offset.
+	index map[int64]int
+	// Split alloca instruction of each accessed scalar field; or nil if not
+	// accessed.
+	allocas []*ir.InstAlloca
+	// Byte offset of each pointer derived from the alloca instruction (including
+	// the alloca instruction itself).
+	ptrs map[value.Value]int64
+	// Loads and stores of the allocated aggregate.
+	accesses []ir.Instruction
+	// Instructions to remove.
+	dead map[ir.Instruction]bool
+}
+
+// scalar is a scalar field of an aggregate.
+type scalar struct {
+	// Byte offset of the scalar within the aggregate.
+	offset int64
+	// Type of the scalar.
+	typ types.Type
+	// Indices of the scalar within the aggregate, as used by extractvalue and
+	// insertvalue instructions.
+	indices []uint64
+}
+
+// newSplitter returns a new splitter of the given alloca instruction; or nil if
+// the alloca instruction does not allocate a single aggregate with at most
+// sroaMaxScalars scalar fields.
+func newSplitter(f *ir.Func, dl *datalayout.DataLayout, alloca *ir.InstAlloca) *splitter {
+	if alloca.InAlloca || alloca.SwiftError {
+		return nil
+	}
+	if alloca.NElems != nil {
+		n, ok := alloca.NElems.(*constant.Int)
+		if !ok || !n.X.IsInt64() || n.X.Int64() != 1 {
+			return nil
+		}
+	}
+	switch t := alloca.ElemType.(type) {
+	case *types.ArrayType:
+	case *types.StructType:
+		if t.Opaque {
+			return nil
+		}
+	default:
+		return nil
+	}
+	scalars, ok := flatten(dl, alloca.ElemType, 0, nil, nil)
+	if !ok || len(scalars) == 0 || len(scalars) > sroaMaxScalars {
+		return nil
+	}
+	s := &splitter{
+		f:       f,
+		dl:      dl,
+		alloca:  alloca,
+		scalars: scalars,
+		index:   make(map[int64]int),
+		allocas: make([]*ir.InstAlloca, len(scalars)),
+		ptrs:    make(map[value.Value]int64),
+		dead:    make(map[ir.Instruction]bool),
+	}
+	for i, sc := range scalars {
+		s.index[sc.offset] = i
+	}
+	return s
+}
+
+// analyze reports whether each use of the alloca instruction may be resolved
+// to accesses of its scalar fields, recording the derived pointers and
+// accesses.
+func (s *splitter) analyze() bool {
+	work := []value.Value{s.alloca}
+	s.ptrs[s.alloca] = 0
+	for len(work) > 0 {
+		ptr := work[len(work)-1]
+		work = work[:len(work)-1]
+		offset := s.ptrs[ptr]
+		for _, use := range s.f.Users(ptr) {
+			switch user := use.User.(type) {
+			case *ir.InstGetElementPtr:
+				if user.Src != ptr || s.isOperand(ptr, user.Indices) {
+					return false
+				}
+				off, ok := gepOffset(s.dl, user.ElemType, user.Indices)
+				if !ok {
+					return false
+				}
+				s.ptrs[user] = offset + off
+				s.dead[user] = true
+				work = append(work, user)
+			case *ir.InstBitCast:
+				if !types.IsPointer(user.To) {
+					return false
+				}
+				s.ptrs[user] = offset
+				s.dead[user] = true
+				work = append(work, user)
+			case *ir.InstLoad:
+				if user.Volatile || user.Atomic || !s.covers(offset, user.ElemType) {
+					return false
+				}
+				s.accesses = append(s.accesses, user)
+			case *ir.InstStore:
+				if user.Dst != ptr || user.Src == ptr || user.Volatile || user.Atomic || !s.covers(offset, user.Src.Type()) {
+					return false
+				}
+				s.accesses = append(s.accesses, user)
+			case *ir.InstCall:
+				if !isIntrinsic(user, "llvm.lifetime.start", "llvm.lifetime.end") {
+					return false
+				}
+				s.dead[user] = true
+			default:
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isOperand reports whether ptr is one of the given operands.
+func (s *splitter) isOperand(ptr value.Value, ops []value.Value) bool {
+	for _, op := range ops {
+		if op == ptr {
+			return true
+		}
+	}
+	return false
+}
+
+// covers reports whether an access of the given type at the given byte offset
+// exactly covers scalar fields of the allocated aggregate.
+func (s *splitter) covers(offset int64, t types.Type) bool {
+	scalars, ok := flatten(s.dl, t, offset, nil, nil)
+	if !ok || len(scalars) == 0 {
+		return false
+	}
+	for _, sc := range scalars {
+		i, ok := s.index[sc.offset]
+		if !ok || !types.Equal(s.scalars[i].typ, sc.typ) {
+			return false
+		}
+	}
+	return true
+}
+
+// split splits the alloca instruction into one alloca instruction per accessed
+// scalar field, and rewrites its accesses.
+func (s *splitter) split() {
+	// Instructions replacing each split access.
+	replaced := make(map[ir.Instruction][]ir.Instruction)
+	repl := make(map[value.Value]value.Value)
+	for _, inst := range s.accesses {
+		switch inst := inst.(type) {
+		case *ir.InstLoad:
+			if insts := s.splitLoad(inst); insts != nil {
+				replaced[inst] = insts
+				repl[inst] = insts[len(insts)-1].(value.Value)
+			}
+		case *ir.InstStore:
+			if insts := s.splitStore(inst); insts != nil {
+				replaced[inst] = insts
+			}
+		}
+	}
+	s.markDebugDeclares()
+	for _, block := range s.f.Blocks {
+		var insts []ir.Instruction
+		for _, inst := range block.Insts {
+			switch {
+			case inst == s.alloca:
+				// Insert split alloca instructions in place of the alloca
+				// instruction.
+				for _, alloca := range s.allocas {
+					if alloca != nil {
+						insts = append(insts, alloca)
+					}
+				}
+			case replaced[inst] != nil:
+				insts = append(insts, replaced[inst]...)
+			case !s.dead[inst]:
+				insts = append(insts, inst)
+			}
+		}
+		block.Insts = insts
+	}
+	replaceAll(s.f, repl)
+}
+
+// splitLoad rewrites the given load of the allocated aggregate. A load of a
+// single scalar field is rewritten in place, and nil is returned. Otherwise,
+// the loads of each scalar field and the insertvalue instructions merging them
+// are returned.
+func (s *splitter) splitLoad(load *ir.InstLoad) []ir.Instruction {
+	offset := s.ptrs[load.Src]
+	scalars, _ := flatten(s.dl, load.ElemType, offset, nil, nil)
+	if len(scalars) == 1 && len(scalars[0].indices) == 0 {
+		load.Src = s.scalarAlloca(offset)
+		return nil
+	}
+	var insts []ir.Instruction
+	var agg value.Value = constant.NewUndef(load.ElemType)
+	for _, sc := range scalars {
+		l := ir.NewLoad(sc.typ, s.scalarAlloca(sc.offset))
+		insertValue := ir.NewInsertValue(agg, l, sc.indices...)
+		insts = append(insts, l, insertValue)
+		agg = insertValue
+	}
+	agg.(*ir.InstInsertValue).LocalIdent = load.LocalIdent
+	return insts
+}
+
+// splitStore rewrites the given store to the allocated aggregate. A store of a
+// single scalar field is rewritten in place, and nil is returned. Otherwise,
+// the extractvalue instructions and stores of each scalar field are returned.
+func (s *splitter) splitStore(store *ir.InstStore) []ir.Instruction {
+	offset := s.ptrs[store.Dst]
+	scalars, _ := flatten(s.dl, store.Src.Type(), offset, nil, nil)
+	if len(scalars) == 1 && len(scalars[0].indices) == 0 {
+		store.Dst = s.scalarAlloca(offset)
+		return nil
+	}
+	var insts []ir.Instruction
+	for _, sc := range scalars {
+		var x value.Value
+		extractValue := ir.NewExtractValue(store.Src, sc.indices...)
+		if c := ir.FoldInst(extractValue); c != nil {
+			x = c
+		} else {
+			insts = append(insts, extractValue)
+			x = extractValue
+		}
+		insts = append(insts, ir.NewStore(x, s.scalarAlloca(sc.offset)))
+	}
+	return insts
+}
+
+// scalarAlloca returns the split alloca instruction of the scalar field at the
+// given byte offset, creating it if not present.
+func (s *splitter) scalarAlloca(offset int64) *ir.InstAlloca {
+	i := s.index[offset]
+	if alloca := s.allocas[i]; alloca != nil {
+		return alloca
+	}
+	alloca := ir.NewAlloca(s.scalars[i].typ)
+	alloca.AddrSpace = s.alloca.AddrSpace
+	if types.IsOpaquePointer(s.alloca.Type()) {
+		alloca.Typ = types.NewOpaquePointer(s.alloca.AddrSpace)
+	} else {
+		alloca.Typ = types.NewPointer(alloca.ElemType)
+		alloca.Typ.AddrSpace = s.alloca.AddrSpace
+	}
+	if !s.alloca.IsUnnamed() {
+		alloca.SetName(fmt.Sprintf("%s.sroa.%d", s.alloca.Name(), i))
+	}
+	s.allocas[i] = alloca
+	return alloca
+}
+
+// markDebugDeclares marks the llvm.dbg.declare intrinsics of the alloca
+// instruction and its derived pointers as dead.
+func (s *splitter) markDebugDeclares() {
+	for _, block := range s.f.Blocks {
+		for _, inst := range block.Insts {
+			call, ok := inst.(*ir.InstCall)
+			if !ok || !isIntrinsic(call, "llvm.dbg.declare", "llvm.dbg.addr") || len(call.Args) == 0 {
+				continue
+			}
+			if md, ok := call.Args[0].(*metadata.Value); ok {
+				if v, ok := md.Value.(value.Value); ok {
+					if _, ok := s.ptrs[v]; ok {
+						s.dead[inst] = true
+					}
+				}
+			}
+		}
+	}
+}
+
+// ### [ Helper functions ] ####################################################
+
+// flatten appends the scalar fields of the given type at the given byte offset
+// to scalars, and returns the result. The given indices are the indices of the
+// type within its enclosing aggregate. The boolean return value indicates
+// success; i.e. the type is sized and has at most sroaMaxScalars scalar fields.
+func flatten(dl *datalayout.DataLayout, t types.Type, offset int64, indices []uint64, scalars []scalar) ([]scalar, bool) {
+	field := func(i uint64) []uint64 {
+		return append(indices[:len(indices):len(indices)], i)
+	}
+	switch t := t.(type) {
+	case *types.IntType, *types.FloatType, *types.PointerType:
+		if len(scalars) >= sroaMaxScalars {
+			return nil, false
+		}
+		return append(scalars, scalar{offset: offset, typ: t, indices: indices}), true
+	case *types.VectorType:
+		if t.Scalable || len(scalars) >= sroaMaxScalars {
+			return nil, false
+		}
+		return append(scalars, scalar{offset: offset, typ: t, indices: indices}), true
+	case *types.ArrayType:
+		if t.Len > sroaMaxScalars {
+			return nil, false
+		}
+		if !isSized(t.ElemType) {
+			return nil, false
+		}
+		size := int64(dl.AllocSizeOf(t.ElemType))
+		for i := uint64(0); i < t.Len; i++ {
+			var ok bool
+			if scalars, ok = flatten(dl, t.ElemType, offset+int64(i)*size, field(i), scalars); !ok {
+				return nil, false
+			}
+		}
+		return scalars, true
+	case *types.StructType:
+		if !isSized(t) {
+			return nil, false
+		}
+		sl := dl.StructLayout(t)
+		for i, ft := range t.Fields {
+			var ok bool
+			if scalars, ok = flatten(dl, ft, offset+int64(sl.Offsets[i]), field(uint64(i)), scalars); !ok {
+				return nil, false
+			}
+		}
+		return scalars, true
+	}
+	return nil, false
+}
+
+// gepOffset returns the byte offset of a getelementptr instruction with the
+// given source element type and indices. The boolean return value indicates
+// success; i.e. the indices are constant and within the bounds of struct
+// types.
+func gepOffset(dl *datalayout.DataLayout, elemType types.Type, indices []value.Value) (int64, bool) {
+	if len(indices) == 0 {
+		return 0, true
+	}
+	if !isSized(elemType) {
+		return 0, false
+	}
+	index := func(v value.Value) (int64, bool) {
+		c, ok := v.(*constant.Int)
+		if !ok || !c.X.IsInt64() {
+			return 0, false
+		}
+		return c.X.Int64(), true
+	}
+	i, ok := index(indices[0])
+	if !ok {
+		return 0, false
+	}
+	offset := i * int64(dl.AllocSizeOf(elemType))
+	t := elemType
+	for _, idx := range indices[1:] {
+		i, ok := index(idx)
+		if !ok {
+			return 0, false
+		}
+		switch tt := t.(type) {
+		case *types.StructType:
+			if i < 0 || i >= int64(len(tt.Fields)) {
+				return 0, false
+			}
+			offset += int64(dl.StructLayout(tt).Offsets[i])
+			t = tt.Fields[i]
+		case *types.ArrayType:
+			offset += i * int64(dl.AllocSizeOf(tt.ElemType))
+			t = tt.ElemType
+		default:
+			// Indexing into vectors is not supported, as vectors are scalar
+			// fields.
+			return 0, false
+		}
+	}
+	return offset, true
+}
+
+// isSized reports whether the given type has a known size.
+func isSized(t types.Type) bool {
+	switch t := t.(type) {
+	case *types.IntType, *types.FloatType, *types.PointerType, *types.MMXType:
+		return true
+	case *types.VectorType:
+		return !t.Scalable && isSized(t.ElemType)
+	case *types.ArrayType:
+		return isSized(t.ElemType)
+	case *types.StructType:
+		if t.Opaque {
+			return false
+		}
+		for _, field := range t.Fields {
+			if !isSized(field) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package transform_test
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/datalayout"
+	"github.com/llir/llvm/ir/dom"
+	"github.com/llir/llvm/ir/transform"
+)
+
+func TestSROA(t *testing.T) {
+	golden := []struct {
+		name string
+		in   string
+		// Expected number of split alloca instructions.
+		n    int
+		want string
+	}{
+		{
+			name: "fields",
+			in: `
+%pair = type { i32, i64 }
+
+define i64 @fields(i1 %c, i32 %a, i64 %b) {
+entry:
+	%p = alloca %pair
+	%x = getelementptr %pair, %pair* %p, i32 0, i32 0
+	%y = getelementptr %pair, %pair* %p, i32 0, i32 1
+	%raw = bitcast %pair* %p to i8*
+	call void @llvm.lifetime.start.p0i8(i64 16, i8* %raw)
+	store i32 %a, i32* %x
+	store i64 %b, i64* %y
+	br i1 %c, label %then, label %join
+
+then:
+	store i64 0, i64* %y
+	br label %join
+
+join:
+	%v = load i64, i64* %y
+	%w = load i32, i32* %x
+	%w64 = zext i32 %w to i64
+	%r = add i64 %v, %w64
+	ret i64 %r
+}
+
+declare void @llvm.lifetime.start.p0i8(i64, i8*)
+
+`,
+			// Split %p (1).
+			n: 1,
+			want: `
+define i64 @fields(i1 %c, i32 %a, i64 %b) {
+entry:
+	br i1 %c, label %then, label %join
+
+then:
+	br label %join
+
+join:
+	%p.sroa.1.0 = phi i64 [ %b, %entry ], [ 0, %then ]
+	%w64 = zext i32 %a to i64
+	%r = add i64 %p.sroa.1.0, %w64
+	ret i64 %r
+}
+`,
+		},
+		{
+			name: "aggregate load and store",
+			in: `
+%pair = type { i32, i64 }
+
+define %pair @whole(i32 %a) {
+entry:
+	%p = alloca %pair
+	%q = alloca [2 x i32]
+	store %pair { i32 1, i64 2 }, %pair* %p
+	%x = getelementptr %pair, %pair* %p, i32 0, i32 0
+	store i32 %a, i32* %x
+	%e = getelementptr [2 x i32], [2 x i32]* %q, i64 0, i64 1
+	store i32 %a, i32* %e
+	%v = load %pair, %pair* %p
+	ret %pair %v
+}
+`,
+			// Split %p and %q (2); the stored constant is folded.
+			n: 2,
+			want: `
+define %pair @whole(i32 %a) {
+entry:
+	%0 = insertvalue %pair undef, i32 %a, 0
+	%v = insertvalue %pair %0, i64 2, 1
+	ret %pair %v
+}
+`,
+		},
+		{
+			name: "escape",
+			in: `
+%pair = type { i32, i64 }
+
+define i32 @escape(i64 %i) {
+entry:
+	%p = alloca %pair
+	%q = alloca [4 x i32]
+	%r = alloca %pair
+	%s = alloca %pair
+	call void @use(%pair* %p)
+	%e = getelementptr [4 x i32], [4 x i32]* %q, i64 0, i64 %i
+	store i32 1, i32* %e
+	%a = bitcast %pair* %r to i8*
+	%b = bitcast %pair* %s to i8*
+	call void @llvm.memcpy.p0i8.p0i8.i64(i8* %a, i8* %b, i64 16, i1 false)
+	%x = getelementptr %pair, %pair* %r, i32 0, i32 0
+	%v = load i32, i32* %x
+	ret i32 %v
+}
+
+declare void @use(%pair*)
+
+declare void @llvm.memcpy.p0i8.p0i8.i64(i8*, i8*, i64, i1)
+`,
+			// Escaping %p, variable index into %q, and memcpy of %r and %s.
+			n: 0,
+			want: `
+define i32 @escape(i64 %i) {
+entry:
+	%p = alloca %pair
+	%q = alloca [4 x i32]
+	%r = alloca %pair
+	%s = alloca %pair
+	call void @use(%pair* %p)
+	%e = getelementptr [4 x i32], [4 x i32]* %q, i64 0, i64 %i
+	store i32 1, i32* %e
+	%a = bitcast %pair* %r to i8*
+	%b = bitcast %pair* %s to i8*
+	call void @llvm.memcpy.p0i8.p0i8.i64(i8* %a, i8* %b, i64 16, i1 false)
+	%x = getelementptr %pair, %pair* %r, i32 0, i32 0
+	%v = load i32, i32* %x
+	ret i32 %v
+}
+`,
+		},
+	}
+	dl := datalayout.Default()
+	for _, g := range golden {
+		runGolden(t, g.name, g.in, g.n, g.want, func(f *ir.Func) int {
+			return transform.SROA(f, dl, dom.New(f))
+		})
+	}
+}
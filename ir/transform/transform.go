@@ -0,0 +1,262 @@
+// Package transform implements transformations of LLVM IR functions.
+//
+// Transformations rewrite functions in place. The use lists and local IDs of
+// transformed functions are invalidated as needed, so that the ir package
+// rewriting API and the printer remain consistent with the rewritten IR.
+package transform
+
+import (
+	"strings"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/metadata"
+	"github.com/llir/llvm/ir/value"
+)
+
+// replaceAll replaces the uses of each key of repl within the given function
+// with the corresponding value. Replacement values are resolved transitively;
+// i.e. if repl maps a to b and b to c, uses of both a and b are replaced with c.
+// Uses from metadata arguments of function calls (e.g. the value of
+// llvm.dbg.value) are replaced as well.
+func replaceAll(f *ir.Func, repl map[value.Value]value.Value) {
+	if len(repl) == 0 {
+		return
+	}
+	resolve := func(v value.Value) value.Value {
+		for {
+			r, ok := repl[v]
+			if !ok {
+				return v
+			}
+			v = r
+		}
+	}
+	f.InvalidateUses()
+	for old := range repl {
+		new := resolve(old)
+		for _, use := range f.Users(old) {
+			use.Set(new)
+		}
+	}
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			call, ok := inst.(*ir.InstCall)
+			if !ok {
+				continue
+			}
+			for _, arg := range call.Args {
+				md, ok := arg.(*metadata.Value)
+				if !ok {
+					continue
+				}
+				if v, ok := md.Value.(value.Value); ok {
+					if _, ok := repl[v]; ok {
+						md.Value = resolve(v)
+					}
+				}
+			}
+		}
+	}
+	invalidate(f)
+}
+
+// removeInsts removes the given instructions from the basic blocks of the
+// function.
+func removeInsts(f *ir.Func, dead map[ir.Instruction]bool) {
+	if len(dead) == 0 {
+		return
+	}
+	for _, block := range f.Blocks {
+		insts := block.Insts[:0]
+		for _, inst := range block.Insts {
+			if !dead[inst] {
+				insts = append(insts, inst)
+			}
+		}
+		// Clear references from the tail of the slice.
+		for i := len(insts); i < len(block.Insts); i++ {
+			block.Insts[i] = nil
+		}
+		block.Insts = insts
+	}
+	f.ResetIDs()
+	invalidate(f)
+}
+
+// invalidate drops the cached use lists of the given function and of its
+// parent module.
+func invalidate(f *ir.Func) {
+	f.InvalidateUses()
+	if f.Parent != nil {
+		f.Parent.InvalidateUses()
+	}
+}
+
+// calleeName returns the name of the function called by the given call
+// instruction; or the empty string if the callee is not a function.
+func calleeName(call *ir.InstCall) string {
+	if callee, ok := call.Callee.(*ir.Func); ok {
+		return callee.Name()
+	}
+	return ""
+}
+
+// isIntrinsic reports whether the given call instruction calls an intrinsic
+// function with one of the given names. Overloaded intrinsics match by prefix
+// (e.g. "llvm.lifetime.start" matches "llvm.lifetime.start.p0").
+func isIntrinsic(call *ir.InstCall, names ...string) bool {
+	name := calleeName(call)
+	for _, n := range names {
+		if name == n || strings.HasPrefix(name, n+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// mayHaveSideEffects reports whether the given instruction may have side
+// effects, and must therefore be preserved even if its result is unused.
+//
+// Stores, fences, atomic instructions, volatile and atomic loads, va_arg and
+// exception handling pads have side effects, as do calls unless the call site
+// or the callee has the readnone or readonly function attribute.
+func mayHaveSideEffects(inst ir.Instruction) bool {
+	switch inst := inst.(type) {
+	case *ir.InstStore, *ir.InstFence, *ir.InstCmpXchg, *ir.InstAtomicRMW:
+		return true
+	case *ir.InstLoad:
+		return inst.Volatile || inst.Atomic
+	case *ir.InstCall:
+		return !onlyReadsMemory(inst)
+	case *ir.InstVAArg:
+		return true
+	case *ir.InstLandingPad, *ir.InstCatchPad, *ir.InstCleanupPad:
+		return true
+	}
+	return false
+}
+
+// onlyReadsMemory reports whether the call site or the callee of the given
+// call instruction has the readnone or readonly function attribute.
+func onlyReadsMemory(call *ir.InstCall) bool {
+	attrs := call.FuncAttrs
+	if callee, ok := call.Callee.(*ir.Func); ok {
+		attrs = append(attrs[:len(attrs):len(attrs)], callee.FuncAttrs...)
+	}
+	return hasFuncAttr(attrs, enum.FuncAttrReadNone) || hasFuncAttr(attrs, enum.FuncAttrReadOnly)
+}
+
+// hasFuncAttr reports whether the given function attributes (including the
+// attributes of referenced attribute groups) contain attr.
+func hasFuncAttr(attrs []ir.FuncAttribute, attr enum.FuncAttr) bool {
+	for _, a := range attrs {
+		switch a := a.(type) {
+		case enum.FuncAttr:
+			if a == attr {
+				return true
+			}
+		case *ir.AttrGroupDef:
+			if hasFuncAttr(a.FuncAttrs, attr) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// removeUnreachableBlocks removes the basic blocks of the function not
+// reachable from the entry basic block, removing the incoming values of phi
+// instructions from removed basic blocks. The number of removed instructions
+// (including terminators) is returned.
+func removeUnreachableBlocks(f *ir.Func) int {
+	if len(f.Blocks) == 0 {
+		return 0
+	}
+	reachable := map[*ir.Block]bool{f.Blocks[0]: true}
+	work := []*ir.Block{f.Blocks[0]}
+	for len(work) > 0 {
+		block := work[len(work)-1]
+		work = work[:len(work)-1]
+		for _, succ := range block.Term.Succs() {
+			if !reachable[succ] {
+				reachable[succ] = true
+				work = append(work, succ)
+			}
+		}
+	}
+	if len(reachable) == len(f.Blocks) {
+		return 0
+	}
+	n := 0
+	var blocks []*ir.Block
+	for _, block := range f.Blocks {
+		if reachable[block] {
+			blocks = append(blocks, block)
+			continue
+		}
+		n += len(block.Insts) + 1
+		for _, succ := range block.Term.Succs() {
+			if reachable[succ] {
+				removePhiIncs(succ, block)
+			}
+		}
+	}
+	f.Blocks = blocks
+	f.ResetIDs()
+	invalidate(f)
+	return n
+}
+
+// removePhiIncs removes the incoming values from the predecessor basic block
+// pred of the phi instructions of the given basic block.
+func removePhiIncs(block, pred *ir.Block) {
+	for _, inst := range block.Insts {
+		phi, ok := inst.(*ir.InstPhi)
+		if !ok {
+			// Phi instructions are grouped at the top of basic blocks.
+			break
+		}
+		incs := phi.Incs[:0]
+		for _, inc := range phi.Incs {
+			if inc.Pred != pred {
+				incs = append(incs, inc)
+			}
+		}
+		phi.Incs = incs
+	}
+}
+
+// instKey returns a textual key of the given instruction, identifying its
+// operation, flags, types and operands, but not its result name or metadata
+// attachments. Operands are identified by name, so the local IDs of the parent
+// function must be assigned (see ir.Func.AssignIDs).
+func instKey(inst ir.Instruction) string {
+	key := inst.LLString()
+	if v, ok := inst.(value.Named); ok {
+		key = strings.TrimPrefix(key, v.Ident()+" = ")
+	}
+	if md, ok := inst.(metadata.Attacher); ok {
+		attachments := md.MDAttachments()
+		for i := len(attachments) - 1; i >= 0; i-- {
+			key = strings.TrimSuffix(key, ", "+attachments[i].String())
+		}
+	}
+	return key
+}
+
+// isAddressTaken reports whether the address of the given basic block is taken
+// by a blockaddress constant.
+func isAddressTaken(f *ir.Func, block *ir.Block) bool {
+	uses := f.Users(block)
+	if f.Parent != nil {
+		uses = append(uses[:len(uses):len(uses)], f.Parent.Users(block)...)
+	}
+	for _, use := range uses {
+		if _, ok := use.User.(*constant.BlockAddress); ok {
+			return true
+		}
+	}
+	return false
+}
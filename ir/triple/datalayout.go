@@ -0,0 +1,181 @@
+package triple
+
+import (
+	"strings"
+)
+
+// DataLayout returns the default data layout string of the target, as used by
+// LLVM for the target; or an empty string if the target is not supported.
+func (t *Triple) DataLayout() string {
+	switch t.Arch {
+	case ArchX86, ArchX86_64:
+		return t.x86DataLayout()
+	case ArchAArch64, ArchAArch64BE, ArchAArch64_32:
+		return t.aarch64DataLayout()
+	case ArchARM, ArchARMEB, ArchThumb, ArchThumbEB:
+		return t.armDataLayout()
+	case ArchRISCV32:
+		return "e-m:e-p:32:32-i64:64-n32-S128"
+	case ArchRISCV64:
+		return "e-m:e-p:64:64-i64:64-i128:128-n64-S128"
+	case ArchWasm32:
+		return "e-m:e-p:32:32-p10:8:8-p20:8:8-i64:64-n32:64-S128-ni:1:10:20"
+	case ArchWasm64:
+		return "e-m:e-p:64:64-p10:8:8-p20:8:8-i64:64-n32:64-S128-ni:1:10:20"
+	case ArchPPC:
+		return "E-m:e-p:32:32-i64:64-n32"
+	case ArchPPC64:
+		return "E-m:e-i64:64-n32:64-S128-v256:256:256-v512:512:512"
+	case ArchPPC64LE:
+		return "e-m:e-i64:64-n32:64-S128-v256:256:256-v512:512:512"
+	case ArchSystemZ:
+		return "E-m:e-i1:8:16-i8:8:16-i64:64-f128:64-a:8:16-n32:64"
+	case ArchMIPS:
+		return "E-m:m-p:32:32-i8:8:32-i16:16:32-i64:64-n32-S64"
+	case ArchMIPSEL:
+		return "e-m:m-p:32:32-i8:8:32-i16:16:32-i64:64-n32-S64"
+	case ArchMIPS64:
+		return "E-m:e-i8:8:32-i16:16:32-i64:64-n32:64-S128"
+	case ArchMIPS64EL:
+		return "e-m:e-i8:8:32-i16:16:32-i64:64-n32:64-S128"
+	case ArchSPARC:
+		return "E-m:e-p:32:32-i64:64-f128:64-n32-S64"
+	case ArchSPARCEL:
+		return "e-m:e-p:32:32-i64:64-f128:64-n32-S64"
+	case ArchSPARCV9:
+		return "E-m:e-i64:64-n32:64-S128"
+	case ArchNVPTX:
+		return "e-p:32:32-i64:64-i128:128-v16:16-v32:32-n16:32:64"
+	case ArchNVPTX64:
+		return "e-i64:64-i128:128-v16:16-v32:32-n16:32:64"
+	case ArchAMDGCN:
+		return "e-p:64:64-p1:64:64-p2:32:32-p3:32:32-p4:64:64-p5:32:32-p6:32:32-i64:64-v16:16-v24:32-v32:32-v48:64-v96:128-v192:256-v256:256-v512:512-v1024:1024-v2048:2048-n32:64-S32-A5-G1-ni:7"
+	case ArchBPFEL:
+		return "e-m:e-p:64:64-i64:64-i128:128-n32:64-S128"
+	case ArchBPFEB:
+		return "E-m:e-p:64:64-i64:64-i128:128-n32:64-S128"
+	case ArchAVR:
+		return "e-P1-p:16:8-i8:8-i16:8-i32:8-i64:8-f32:8-f64:8-n8-a:8"
+	case ArchMSP430:
+		return "e-m:e-p:16:16-i32:16-i64:16-f32:16-f64:16-a:8-n8:16-S16"
+	case ArchHexagon:
+		return "e-m:e-p:32:32:32-a:0-n16:32-i64:64:64-i32:32:32-i16:16:16-i1:8:8-f32:32:32-f64:64:64-v32:32:32-v64:64:64-v512:512:512-v1024:1024:1024-v2048:2048:2048"
+	}
+	return ""
+}
+
+// x86DataLayout returns the default data layout string of x86 targets.
+func (t *Triple) x86DataLayout() string {
+	is64Bit := t.Arch == ArchX86_64
+	specs := []string{"e", t.mangling()}
+	if !is64Bit {
+		specs = append(specs, "p:32:32")
+	}
+	// Address spaces of 32-bit signed, 32-bit unsigned and 64-bit pointers.
+	specs = append(specs, "p270:32:32", "p271:32:32", "p272:64:64")
+	switch {
+	case is64Bit || t.IsOSWindows() || t.OS == OSNaCl:
+		specs = append(specs, "i64:64")
+	case t.OS == OSELFIAMCU:
+		specs = append(specs, "i64:32", "f64:32")
+	default:
+		specs = append(specs, "f64:32:64")
+	}
+	// Alignment of long double.
+	switch {
+	case t.OS == OSNaCl || t.OS == OSELFIAMCU:
+	case is64Bit || t.IsOSDarwin() || t.IsWindowsMSVCEnvironment():
+		specs = append(specs, "f80:128")
+	default:
+		specs = append(specs, "f80:32")
+	}
+	if t.OS == OSELFIAMCU {
+		specs = append(specs, "f128:32")
+	}
+	if is64Bit {
+		specs = append(specs, "n8:16:32:64")
+	} else {
+		specs = append(specs, "n8:16:32")
+	}
+	if (!is64Bit && t.IsOSWindows()) || t.OS == OSELFIAMCU {
+		specs = append(specs, "a:0:32", "S32")
+	} else {
+		specs = append(specs, "S128")
+	}
+	return strings.Join(specs, "-")
+}
+
+// aarch64DataLayout returns the default data layout string of AArch64 targets.
+func (t *Triple) aarch64DataLayout() string {
+	switch {
+	case t.IsOSBinFormatMachO():
+		if t.Arch == ArchAArch64_32 {
+			return "e-m:o-p:32:32-i64:64-i128:128-n32:64-S128"
+		}
+		return "e-m:o-i64:64-i128:128-n32:64-S128"
+	case t.IsOSBinFormatCOFF():
+		return "e-m:w-p:64:64-i32:32-i64:64-i128:128-n32:64-S128"
+	}
+	endian := "e"
+	if t.Arch == ArchAArch64BE {
+		endian = "E"
+	}
+	ptr := ""
+	if t.Env == EnvGNUILP32 {
+		ptr = "-p:32:32"
+	}
+	return endian + "-m:e" + ptr + "-i8:8:32-i16:16:32-i64:64-i128:128-n32:64-S128"
+}
+
+// armDataLayout returns the default data layout string of 32-bit ARM targets.
+func (t *Triple) armDataLayout() string {
+	specs := []string{"e"}
+	if t.Arch == ArchARMEB || t.Arch == ArchThumbEB {
+		specs[0] = "E"
+	}
+	specs = append(specs, t.mangling(), "p:32:32", "Fi8")
+	// Darwin targets use the APCS ABI, except for M-class cores and watchOS
+	// (AAPCS16).
+	mClass := t.SubArch == SubArchARMv6m || t.SubArch == SubArchARMv7m || t.SubArch == SubArchARMv7em || t.SubArch == SubArchARMv8mBaseline || t.SubArch == SubArchARMv8mMainline
+	aapcs16 := t.SubArch == SubArchARMv7k || t.OS == OSWatchOS
+	apcs := t.IsOSDarwin() && !mClass && !aapcs16
+	if apcs {
+		specs = append(specs, "f64:32:64")
+	} else {
+		specs = append(specs, "i64:64")
+	}
+	switch {
+	case apcs:
+		specs = append(specs, "v64:32:64", "v128:32:128")
+	case !aapcs16:
+		specs = append(specs, "v128:64:128")
+	}
+	specs = append(specs, "a:0:32", "n32")
+	switch {
+	case t.OS == OSNaCl || aapcs16:
+		specs = append(specs, "S128")
+	case apcs:
+		specs = append(specs, "S32")
+	default:
+		specs = append(specs, "S64")
+	}
+	return strings.Join(specs, "-")
+}
+
+// mangling returns the mangling mode specification of the target.
+func (t *Triple) mangling() string {
+	switch {
+	case t.IsOSBinFormatMachO():
+		return "m:o"
+	case t.IsOSWindows() && t.IsOSBinFormatCOFF():
+		if t.Arch == ArchX86 {
+			return "m:x"
+		}
+		return "m:w"
+	case t.IsOSBinFormatXCOFF():
+		return "m:a"
+	case t.IsOSBinFormatGOFF():
+		return "m:l"
+	}
+	return "m:e"
+}
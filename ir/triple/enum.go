@@ -0,0 +1,791 @@
+package triple
+
+import (
+	"fmt"
+	"strings"
+)
+
+// === [ Architecture ] ========================================================
+
+// Arch is an architecture of a target triple.
+type Arch uint8
+
+// Architectures.
+const (
+	ArchUnknown     Arch = iota // unknown
+	ArchAArch64                 // aarch64
+	ArchAArch64BE               // aarch64_be
+	ArchAArch64_32              // aarch64_32
+	ArchAMDGCN                  // amdgcn
+	ArchARC                     // arc
+	ArchARM                     // arm
+	ArchARMEB                   // armeb
+	ArchAVR                     // avr
+	ArchBPFEB                   // bpfeb
+	ArchBPFEL                   // bpfel
+	ArchCSKY                    // csky
+	ArchHexagon                 // hexagon
+	ArchLanai                   // lanai
+	ArchLE32                    // le32
+	ArchLE64                    // le64
+	ArchLoongArch32             // loongarch32
+	ArchLoongArch64             // loongarch64
+	ArchM68k                    // m68k
+	ArchMIPS                    // mips
+	ArchMIPSEL                  // mipsel
+	ArchMIPS64                  // mips64
+	ArchMIPS64EL                // mips64el
+	ArchMSP430                  // msp430
+	ArchNVPTX                   // nvptx
+	ArchNVPTX64                 // nvptx64
+	ArchPPC                     // powerpc
+	ArchPPCLE                   // powerpcle
+	ArchPPC64                   // powerpc64
+	ArchPPC64LE                 // powerpc64le
+	ArchR600                    // r600
+	ArchRISCV32                 // riscv32
+	ArchRISCV64                 // riscv64
+	ArchSPARC                   // sparc
+	ArchSPARCEL                 // sparcel
+	ArchSPARCV9                 // sparcv9
+	ArchSPIR                    // spir
+	ArchSPIR64                  // spir64
+	ArchSPIRV32                 // spirv32
+	ArchSPIRV64                 // spirv64
+	ArchSystemZ                 // s390x
+	ArchTCE                     // tce
+	ArchTCELE                   // tcele
+	ArchThumb                   // thumb
+	ArchThumbEB                 // thumbeb
+	ArchVE                      // ve
+	ArchWasm32                  // wasm32
+	ArchWasm64                  // wasm64
+	ArchX86                     // i386
+	ArchX86_64                  // x86_64
+	ArchXCore                   // xcore
+)
+
+// archNames maps from architecture to canonical architecture name.
+var archNames = [...]string{
+	ArchUnknown:     "unknown",
+	ArchAArch64:     "aarch64",
+	ArchAArch64BE:   "aarch64_be",
+	ArchAArch64_32:  "aarch64_32",
+	ArchAMDGCN:      "amdgcn",
+	ArchARC:         "arc",
+	ArchARM:         "arm",
+	ArchARMEB:       "armeb",
+	ArchAVR:         "avr",
+	ArchBPFEB:       "bpfeb",
+	ArchBPFEL:       "bpfel",
+	ArchCSKY:        "csky",
+	ArchHexagon:     "hexagon",
+	ArchLanai:       "lanai",
+	ArchLE32:        "le32",
+	ArchLE64:        "le64",
+	ArchLoongArch32: "loongarch32",
+	ArchLoongArch64: "loongarch64",
+	ArchM68k:        "m68k",
+	ArchMIPS:        "mips",
+	ArchMIPSEL:      "mipsel",
+	ArchMIPS64:      "mips64",
+	ArchMIPS64EL:    "mips64el",
+	ArchMSP430:      "msp430",
+	ArchNVPTX:       "nvptx",
+	ArchNVPTX64:     "nvptx64",
+	ArchPPC:         "powerpc",
+	ArchPPCLE:       "powerpcle",
+	ArchPPC64:       "powerpc64",
+	ArchPPC64LE:     "powerpc64le",
+	ArchR600:        "r600",
+	ArchRISCV32:     "riscv32",
+	ArchRISCV64:     "riscv64",
+	ArchSPARC:       "sparc",
+	ArchSPARCEL:     "sparcel",
+	ArchSPARCV9:     "sparcv9",
+	ArchSPIR:        "spir",
+	ArchSPIR64:      "spir64",
+	ArchSPIRV32:     "spirv32",
+	ArchSPIRV64:     "spirv64",
+	ArchSystemZ:     "s390x",
+	ArchTCE:         "tce",
+	ArchTCELE:       "tcele",
+	ArchThumb:       "thumb",
+	ArchThumbEB:     "thumbeb",
+	ArchVE:          "ve",
+	ArchWasm32:      "wasm32",
+	ArchWasm64:      "wasm64",
+	ArchX86:         "i386",
+	ArchX86_64:      "x86_64",
+	ArchXCore:       "xcore",
+}
+
+// String returns the canonical name of the architecture.
+func (arch Arch) String() string {
+	if int(arch) < len(archNames) {
+		return archNames[arch]
+	}
+	return fmt.Sprintf("Arch(%d)", uint8(arch))
+}
+
+// parseArch parses the given architecture name.
+func parseArch(s string) Arch {
+	switch s {
+	case "i386", "i486", "i586", "i686", "i786", "i886", "i986":
+		return ArchX86
+	case "amd64", "x86_64", "x86_64h":
+		return ArchX86_64
+	case "powerpc", "powerpcspe", "ppc", "ppc32":
+		return ArchPPC
+	case "powerpcle", "ppcle", "ppc32le":
+		return ArchPPCLE
+	case "powerpc64", "ppu", "ppc64":
+		return ArchPPC64
+	case "powerpc64le", "ppc64le":
+		return ArchPPC64LE
+	case "xscale":
+		return ArchARM
+	case "xscaleeb":
+		return ArchARMEB
+	case "aarch64", "arm64", "arm64e":
+		return ArchAArch64
+	case "aarch64_be":
+		return ArchAArch64BE
+	case "aarch64_32", "arm64_32":
+		return ArchAArch64_32
+	case "arc":
+		return ArchARC
+	case "arm":
+		return ArchARM
+	case "armeb":
+		return ArchARMEB
+	case "thumb":
+		return ArchThumb
+	case "thumbeb":
+		return ArchThumbEB
+	case "avr":
+		return ArchAVR
+	case "m68k":
+		return ArchM68k
+	case "msp430":
+		return ArchMSP430
+	case "mips", "mipseb", "mipsallegrex", "mipsisa32r6", "mipsr6":
+		return ArchMIPS
+	case "mipsel", "mipsallegrexel", "mipsisa32r6el", "mipsr6el":
+		return ArchMIPSEL
+	case "mips64", "mips64eb", "mipsn32", "mipsisa64r6", "mips64r6", "mipsn32r6":
+		return ArchMIPS64
+	case "mips64el", "mipsn32el", "mipsisa64r6el", "mips64r6el", "mipsn32r6el":
+		return ArchMIPS64EL
+	case "r600":
+		return ArchR600
+	case "amdgcn":
+		return ArchAMDGCN
+	case "riscv32":
+		return ArchRISCV32
+	case "riscv64":
+		return ArchRISCV64
+	case "hexagon":
+		return ArchHexagon
+	case "s390x", "systemz":
+		return ArchSystemZ
+	case "sparc":
+		return ArchSPARC
+	case "sparcel":
+		return ArchSPARCEL
+	case "sparcv9", "sparc64":
+		return ArchSPARCV9
+	case "tce":
+		return ArchTCE
+	case "tcele":
+		return ArchTCELE
+	case "xcore":
+		return ArchXCore
+	case "nvptx":
+		return ArchNVPTX
+	case "nvptx64":
+		return ArchNVPTX64
+	case "le32":
+		return ArchLE32
+	case "le64":
+		return ArchLE64
+	case "spir":
+		return ArchSPIR
+	case "spir64":
+		return ArchSPIR64
+	case "spirv32":
+		return ArchSPIRV32
+	case "spirv64":
+		return ArchSPIRV64
+	case "lanai":
+		return ArchLanai
+	case "ve":
+		return ArchVE
+	case "wasm32":
+		return ArchWasm32
+	case "wasm64":
+		return ArchWasm64
+	case "csky":
+		return ArchCSKY
+	case "loongarch32":
+		return ArchLoongArch32
+	case "loongarch64":
+		return ArchLoongArch64
+	case "bpf", "bpf_le", "bpfel":
+		return ArchBPFEL
+	case "bpf_be", "bpfeb":
+		return ArchBPFEB
+	}
+	// ARM architectures with sub-architecture (e.g. armv7a, thumbv7em).
+	if strings.HasPrefix(s, "arm") || strings.HasPrefix(s, "thumb") {
+		return parseARMArch(s)
+	}
+	return ArchUnknown
+}
+
+// parseARMArch parses the given ARM architecture name with sub-architecture
+// version (e.g. armv7a, armebv7, thumbv7em).
+func parseARMArch(s string) Arch {
+	thumb := strings.HasPrefix(s, "thumb")
+	rest := strings.TrimPrefix(strings.TrimPrefix(s, "arm"), "thumb")
+	bigEndian := false
+	if strings.HasPrefix(rest, "eb") {
+		bigEndian, rest = true, rest[len("eb"):]
+	} else if strings.HasSuffix(rest, "eb") {
+		bigEndian, rest = true, rest[:len(rest)-len("eb")]
+	}
+	if armSubArch(rest) == SubArchNone {
+		return ArchUnknown
+	}
+	switch {
+	case thumb && bigEndian:
+		return ArchThumbEB
+	case thumb:
+		return ArchThumb
+	case bigEndian:
+		return ArchARMEB
+	default:
+		return ArchARM
+	}
+}
+
+// --- [ Sub-architecture ] ----------------------------------------------------
+
+// SubArch is a sub-architecture of a target triple.
+type SubArch uint8
+
+// Sub-architectures.
+const (
+	SubArchNone           SubArch = iota // none
+	SubArchARMv4t                        // v4t
+	SubArchARMv5                         // v5
+	SubArchARMv5te                       // v5te
+	SubArchARMv6                         // v6
+	SubArchARMv6k                        // v6k
+	SubArchARMv6m                        // v6m
+	SubArchARMv6t2                       // v6t2
+	SubArchARMv7                         // v7
+	SubArchARMv7em                       // v7em
+	SubArchARMv7k                        // v7k
+	SubArchARMv7m                        // v7m
+	SubArchARMv7s                        // v7s
+	SubArchARMv7ve                       // v7ve
+	SubArchARMv8                         // v8
+	SubArchARMv8_1a                      // v8.1a
+	SubArchARMv8_2a                      // v8.2a
+	SubArchARMv8_3a                      // v8.3a
+	SubArchARMv8_4a                      // v8.4a
+	SubArchARMv8_5a                      // v8.5a
+	SubArchARMv8_6a                      // v8.6a
+	SubArchARMv8_7a                      // v8.7a
+	SubArchARMv8mBaseline                // v8m.base
+	SubArchARMv8mMainline                // v8m.main
+	SubArchARMv8r                        // v8r
+	SubArchARMv9                         // v9
+	SubArchARM64E                        // arm64e
+	SubArchMipsR6                        // r6
+)
+
+// subArchNames maps from sub-architecture to sub-architecture name.
+var subArchNames = [...]string{
+	SubArchNone:           "none",
+	SubArchARMv4t:         "v4t",
+	SubArchARMv5:          "v5",
+	SubArchARMv5te:        "v5te",
+	SubArchARMv6:          "v6",
+	SubArchARMv6k:         "v6k",
+	SubArchARMv6m:         "v6m",
+	SubArchARMv6t2:        "v6t2",
+	SubArchARMv7:          "v7",
+	SubArchARMv7em:        "v7em",
+	SubArchARMv7k:         "v7k",
+	SubArchARMv7m:         "v7m",
+	SubArchARMv7s:         "v7s",
+	SubArchARMv7ve:        "v7ve",
+	SubArchARMv8:          "v8",
+	SubArchARMv8_1a:       "v8.1a",
+	SubArchARMv8_2a:       "v8.2a",
+	SubArchARMv8_3a:       "v8.3a",
+	SubArchARMv8_4a:       "v8.4a",
+	SubArchARMv8_5a:       "v8.5a",
+	SubArchARMv8_6a:       "v8.6a",
+	SubArchARMv8_7a:       "v8.7a",
+	SubArchARMv8mBaseline: "v8m.base",
+	SubArchARMv8mMainline: "v8m.main",
+	SubArchARMv8r:         "v8r",
+	SubArchARMv9:          "v9",
+	SubArchARM64E:         "arm64e",
+	SubArchMipsR6:         "r6",
+}
+
+// String returns the name of the sub-architecture.
+func (sub SubArch) String() string {
+	if int(sub) < len(subArchNames) {
+		return subArchNames[sub]
+	}
+	return fmt.Sprintf("SubArch(%d)", uint8(sub))
+}
+
+// parseSubArch parses the sub-architecture of the given architecture name.
+func parseSubArch(s string) SubArch {
+	switch {
+	case s == "arm64e":
+		return SubArchARM64E
+	case s == "xscale" || s == "xscaleeb":
+		return SubArchARMv5te
+	case strings.HasPrefix(s, "mips") && (strings.HasSuffix(s, "r6") || strings.HasSuffix(s, "r6el")):
+		return SubArchMipsR6
+	case strings.HasPrefix(s, "arm") || strings.HasPrefix(s, "thumb"):
+		rest := strings.TrimPrefix(strings.TrimPrefix(s, "arm"), "thumb")
+		rest = strings.TrimSuffix(strings.TrimPrefix(rest, "eb"), "eb")
+		return armSubArch(rest)
+	}
+	return SubArchNone
+}
+
+// armSubArch returns the ARM sub-architecture of the given version suffix of
+// an ARM architecture name (e.g. "v7a"). An empty suffix denotes no
+// sub-architecture; as does an invalid suffix.
+func armSubArch(version string) SubArch {
+	if version == "" {
+		return SubArchNone
+	}
+	switch strings.TrimPrefix(version, "v") {
+	case "4t":
+		return SubArchARMv4t
+	case "5", "5t":
+		return SubArchARMv5
+	case "5e", "5te", "5tej":
+		return SubArchARMv5te
+	case "6", "6j":
+		return SubArchARMv6
+	case "6k", "6kz", "6z", "6zk":
+		return SubArchARMv6k
+	case "6m", "6sm", "6-m":
+		return SubArchARMv6m
+	case "6t2":
+		return SubArchARMv6t2
+	case "7", "7a", "7-a", "7r", "7-r", "7l", "7hl":
+		return SubArchARMv7
+	case "7em", "7e-m":
+		return SubArchARMv7em
+	case "7k":
+		return SubArchARMv7k
+	case "7m", "7-m":
+		return SubArchARMv7m
+	case "7s":
+		return SubArchARMv7s
+	case "7ve":
+		return SubArchARMv7ve
+	case "8", "8a", "8-a", "8l":
+		return SubArchARMv8
+	case "8.1a", "8.1-a":
+		return SubArchARMv8_1a
+	case "8.2a", "8.2-a":
+		return SubArchARMv8_2a
+	case "8.3a", "8.3-a":
+		return SubArchARMv8_3a
+	case "8.4a", "8.4-a":
+		return SubArchARMv8_4a
+	case "8.5a", "8.5-a":
+		return SubArchARMv8_5a
+	case "8.6a", "8.6-a":
+		return SubArchARMv8_6a
+	case "8.7a", "8.7-a":
+		return SubArchARMv8_7a
+	case "8m.base", "8-m.base":
+		return SubArchARMv8mBaseline
+	case "8m.main", "8-m.main":
+		return SubArchARMv8mMainline
+	case "8r", "8-r":
+		return SubArchARMv8r
+	case "9", "9a", "9-a":
+		return SubArchARMv9
+	}
+	return SubArchNone
+}
+
+// === [ Vendor ] ==============================================================
+
+// Vendor is a vendor of a target triple.
+type Vendor uint8
+
+// Vendors.
+const (
+	VendorUnknown                 Vendor = iota // unknown
+	VendorAMD                                   // amd
+	VendorApple                                 // apple
+	VendorCSR                                   // csr
+	VendorFreescale                             // fsl
+	VendorIBM                                   // ibm
+	VendorImaginationTechnologies               // img
+	VendorMesa                                  // mesa
+	VendorMipsTechnologies                      // mti
+	VendorMyriad                                // myriad
+	VendorNVIDIA                                // nvidia
+	VendorOpenEmbedded                          // oe
+	VendorPC                                    // pc
+	VendorSCEI                                  // scei
+	VendorSUSE                                  // suse
+)
+
+// vendorNames maps from vendor to canonical vendor name.
+var vendorNames = [...]string{
+	VendorUnknown:                 "unknown",
+	VendorAMD:                     "amd",
+	VendorApple:                   "apple",
+	VendorCSR:                     "csr",
+	VendorFreescale:               "fsl",
+	VendorIBM:                     "ibm",
+	VendorImaginationTechnologies: "img",
+	VendorMesa:                    "mesa",
+	VendorMipsTechnologies:        "mti",
+	VendorMyriad:                  "myriad",
+	VendorNVIDIA:                  "nvidia",
+	VendorOpenEmbedded:            "oe",
+	VendorPC:                      "pc",
+	VendorSCEI:                    "scei",
+	VendorSUSE:                    "suse",
+}
+
+// String returns the canonical name of the vendor.
+func (vendor Vendor) String() string {
+	if int(vendor) < len(vendorNames) {
+		return vendorNames[vendor]
+	}
+	return fmt.Sprintf("Vendor(%d)", uint8(vendor))
+}
+
+// parseVendor parses the given vendor name.
+func parseVendor(s string) Vendor {
+	if s == "sie" {
+		return VendorSCEI
+	}
+	for vendor, name := range vendorNames {
+		if Vendor(vendor) != VendorUnknown && s == name {
+			return Vendor(vendor)
+		}
+	}
+	return VendorUnknown
+}
+
+// === [ Operating system ] ====================================================
+
+// OS is an operating system of a target triple.
+type OS uint8
+
+// Operating systems.
+const (
+	OSUnknown    OS = iota // unknown
+	OSAIX                  // aix
+	OSAMDHSA               // amdhsa
+	OSAMDPAL               // amdpal
+	OSAnanas               // ananas
+	OSCloudABI             // cloudabi
+	OSContiki              // contiki
+	OSCUDA                 // cuda
+	OSDarwin               // darwin
+	OSDragonFly            // dragonfly
+	OSELFIAMCU             // elfiamcu
+	OSEmscripten           // emscripten
+	OSFreeBSD              // freebsd
+	OSFuchsia              // fuchsia
+	OSHaiku                // haiku
+	OSHermitCore           // hermit
+	OSHurd                 // hurd
+	OSIOS                  // ios
+	OSKFreeBSD             // kfreebsd
+	OSLinux                // linux
+	OSLv2                  // lv2
+	OSMacOSX               // macosx
+	OSMesa3D               // mesa3d
+	OSMinix                // minix
+	OSNaCl                 // nacl
+	OSNetBSD               // netbsd
+	OSNVCL                 // nvcl
+	OSOpenBSD              // openbsd
+	OSPS4                  // ps4
+	OSRTEMS                // rtems
+	OSSolaris              // solaris
+	OSTvOS                 // tvos
+	OSWASI                 // wasi
+	OSWatchOS              // watchos
+	OSWin32                // windows
+	OSZOS                  // zos
+)
+
+// osNames maps from operating system to canonical operating system name.
+var osNames = [...]string{
+	OSUnknown:    "unknown",
+	OSAIX:        "aix",
+	OSAMDHSA:     "amdhsa",
+	OSAMDPAL:     "amdpal",
+	OSAnanas:     "ananas",
+	OSCloudABI:   "cloudabi",
+	OSContiki:    "contiki",
+	OSCUDA:       "cuda",
+	OSDarwin:     "darwin",
+	OSDragonFly:  "dragonfly",
+	OSELFIAMCU:   "elfiamcu",
+	OSEmscripten: "emscripten",
+	OSFreeBSD:    "freebsd",
+	OSFuchsia:    "fuchsia",
+	OSHaiku:      "haiku",
+	OSHermitCore: "hermit",
+	OSHurd:       "hurd",
+	OSIOS:        "ios",
+	OSKFreeBSD:   "kfreebsd",
+	OSLinux:      "linux",
+	OSLv2:        "lv2",
+	OSMacOSX:     "macosx",
+	OSMesa3D:     "mesa3d",
+	OSMinix:      "minix",
+	OSNaCl:       "nacl",
+	OSNetBSD:     "netbsd",
+	OSNVCL:       "nvcl",
+	OSOpenBSD:    "openbsd",
+	OSPS4:        "ps4",
+	OSRTEMS:      "rtems",
+	OSSolaris:    "solaris",
+	OSTvOS:       "tvos",
+	OSWASI:       "wasi",
+	OSWatchOS:    "watchos",
+	OSWin32:      "windows",
+	OSZOS:        "zos",
+}
+
+// String returns the canonical name of the operating system.
+func (os OS) String() string {
+	if int(os) < len(osNames) {
+		return osNames[os]
+	}
+	return fmt.Sprintf("OS(%d)", uint8(os))
+}
+
+// osPrefixes specifies the operating system of operating system names by
+// prefix, in order of precedence. Operating system names may be followed by a
+// version (e.g. macosx10.15).
+var osPrefixes = []struct {
+	prefix string
+	os     OS
+}{
+	{"ananas", OSAnanas},
+	{"cloudabi", OSCloudABI},
+	{"darwin", OSDarwin},
+	{"dragonfly", OSDragonFly},
+	{"freebsd", OSFreeBSD},
+	{"fuchsia", OSFuchsia},
+	{"ios", OSIOS},
+	{"kfreebsd", OSKFreeBSD},
+	{"linux", OSLinux},
+	{"lv2", OSLv2},
+	{"macos", OSMacOSX},
+	{"netbsd", OSNetBSD},
+	{"openbsd", OSOpenBSD},
+	{"solaris", OSSolaris},
+	{"win32", OSWin32},
+	{"windows", OSWin32},
+	{"zos", OSZOS},
+	{"haiku", OSHaiku},
+	{"minix", OSMinix},
+	{"rtems", OSRTEMS},
+	{"nacl", OSNaCl},
+	{"aix", OSAIX},
+	{"cuda", OSCUDA},
+	{"nvcl", OSNVCL},
+	{"amdhsa", OSAMDHSA},
+	{"ps4", OSPS4},
+	{"elfiamcu", OSELFIAMCU},
+	{"tvos", OSTvOS},
+	{"watchos", OSWatchOS},
+	{"mesa3d", OSMesa3D},
+	{"contiki", OSContiki},
+	{"amdpal", OSAMDPAL},
+	{"hermit", OSHermitCore},
+	{"hurd", OSHurd},
+	{"wasi", OSWASI},
+	{"emscripten", OSEmscripten},
+}
+
+// parseOS parses the given operating system name.
+func parseOS(s string) OS {
+	for _, p := range osPrefixes {
+		if strings.HasPrefix(s, p.prefix) {
+			return p.os
+		}
+	}
+	return OSUnknown
+}
+
+// === [ Environment ] =========================================================
+
+// Environment is an environment (ABI) of a target triple.
+type Environment uint8
+
+// Environments.
+const (
+	EnvUnknown    Environment = iota // unknown
+	EnvAndroid                       // android
+	EnvCODE16                        // code16
+	EnvCoreCLR                       // coreclr
+	EnvCygnus                        // cygnus
+	EnvEABI                          // eabi
+	EnvEABIHF                        // eabihf
+	EnvGNU                           // gnu
+	EnvGNUABI64                      // gnuabi64
+	EnvGNUABIN32                     // gnuabin32
+	EnvGNUEABI                       // gnueabi
+	EnvGNUEABIHF                     // gnueabihf
+	EnvGNUILP32                      // gnu_ilp32
+	EnvGNUX32                        // gnux32
+	EnvItanium                       // itanium
+	EnvMacABI                        // macabi
+	EnvMSVC                          // msvc
+	EnvMusl                          // musl
+	EnvMuslEABI                      // musleabi
+	EnvMuslEABIHF                    // musleabihf
+	EnvMuslX32                       // muslx32
+	EnvSimulator                     // simulator
+)
+
+// envNames maps from environment to canonical environment name.
+var envNames = [...]string{
+	EnvUnknown:    "unknown",
+	EnvAndroid:    "android",
+	EnvCODE16:     "code16",
+	EnvCoreCLR:    "coreclr",
+	EnvCygnus:     "cygnus",
+	EnvEABI:       "eabi",
+	EnvEABIHF:     "eabihf",
+	EnvGNU:        "gnu",
+	EnvGNUABI64:   "gnuabi64",
+	EnvGNUABIN32:  "gnuabin32",
+	EnvGNUEABI:    "gnueabi",
+	EnvGNUEABIHF:  "gnueabihf",
+	EnvGNUILP32:   "gnu_ilp32",
+	EnvGNUX32:     "gnux32",
+	EnvItanium:    "itanium",
+	EnvMacABI:     "macabi",
+	EnvMSVC:       "msvc",
+	EnvMusl:       "musl",
+	EnvMuslEABI:   "musleabi",
+	EnvMuslEABIHF: "musleabihf",
+	EnvMuslX32:    "muslx32",
+	EnvSimulator:  "simulator",
+}
+
+// String returns the canonical name of the environment.
+func (env Environment) String() string {
+	if int(env) < len(envNames) {
+		return envNames[env]
+	}
+	return fmt.Sprintf("Environment(%d)", uint8(env))
+}
+
+// envPrefixes specifies the environment of environment names by prefix, in
+// order of precedence. Environment names may be followed by a version (e.g.
+// android21).
+var envPrefixes = []struct {
+	prefix string
+	env    Environment
+}{
+	{"eabihf", EnvEABIHF},
+	{"eabi", EnvEABI},
+	{"gnuabin32", EnvGNUABIN32},
+	{"gnuabi64", EnvGNUABI64},
+	{"gnueabihf", EnvGNUEABIHF},
+	{"gnueabi", EnvGNUEABI},
+	{"gnux32", EnvGNUX32},
+	{"gnu_ilp32", EnvGNUILP32},
+	{"code16", EnvCODE16},
+	{"gnu", EnvGNU},
+	{"android", EnvAndroid},
+	{"musleabihf", EnvMuslEABIHF},
+	{"musleabi", EnvMuslEABI},
+	{"muslx32", EnvMuslX32},
+	{"musl", EnvMusl},
+	{"msvc", EnvMSVC},
+	{"itanium", EnvItanium},
+	{"cygnus", EnvCygnus},
+	{"coreclr", EnvCoreCLR},
+	{"simulator", EnvSimulator},
+	{"macabi", EnvMacABI},
+}
+
+// parseEnv parses the given environment name.
+func parseEnv(s string) Environment {
+	for _, p := range envPrefixes {
+		if strings.HasPrefix(s, p.prefix) {
+			return p.env
+		}
+	}
+	return EnvUnknown
+}
+
+// === [ Object format ] =======================================================
+
+// ObjectFormat is an object file format of a target triple.
+type ObjectFormat uint8
+
+// Object file formats.
+const (
+	ObjectFormatUnknown ObjectFormat = iota // unknown
+	ObjectFormatCOFF                        // coff
+	ObjectFormatELF                         // elf
+	ObjectFormatGOFF                        // goff
+	ObjectFormatMachO                       // macho
+	ObjectFormatWasm                        // wasm
+	ObjectFormatXCOFF                       // xcoff
+)
+
+// objectFormatNames maps from object file format to canonical object file
+// format name.
+var objectFormatNames = [...]string{
+	ObjectFormatUnknown: "",
+	ObjectFormatCOFF:    "coff",
+	ObjectFormatELF:     "elf",
+	ObjectFormatGOFF:    "goff",
+	ObjectFormatMachO:   "macho",
+	ObjectFormatWasm:    "wasm",
+	ObjectFormatXCOFF:   "xcoff",
+}
+
+// String returns the canonical name of the object file format.
+func (format ObjectFormat) String() string {
+	if int(format) < len(objectFormatNames) {
+		return objectFormatNames[format]
+	}
+	return fmt.Sprintf("ObjectFormat(%d)", uint8(format))
+}
+
+// parseObjectFormat parses the object file format suffix of the given
+// environment name (e.g. gnu-elf, msvc-coff).
+func parseObjectFormat(s string) ObjectFormat {
+	// xcoff before coff, as both are suffixes of xcoff.
+	for _, format := range []ObjectFormat{ObjectFormatXCOFF, ObjectFormatCOFF, ObjectFormatELF, ObjectFormatGOFF, ObjectFormatMachO, ObjectFormatWasm} {
+		if strings.HasSuffix(s, objectFormatNames[format]) {
+			return format
+		}
+	}
+	return ObjectFormatUnknown
+}
@@ -0,0 +1,175 @@
+package triple
+
+import (
+	"strings"
+)
+
+// Normalize returns the normalized form of the given target triple, in the
+// same way as llvm::Triple::normalize. Recognized components are moved into
+// their canonical positions, missing components are added as "unknown", and
+// alternate names of Windows environments are canonicalized (e.g.
+// i686-pc-mingw32 is normalized to i686-pc-windows-gnu).
+//
+// Note that the names of recognized components are otherwise kept as is; e.g.
+// the normalized form of "amd64-linux" is "amd64-unknown-linux".
+func Normalize(s string) string {
+	comps := strings.Split(s, "-")
+	// Parse components in their canonical positions.
+	arch := parseArch(comps[0])
+	vendor := VendorUnknown
+	if len(comps) > 1 {
+		vendor = parseVendor(comps[1])
+	}
+	os := OSUnknown
+	cygwin, mingw := false, false
+	if len(comps) > 2 {
+		os = parseOS(comps[2])
+		cygwin = strings.HasPrefix(comps[2], "cygwin")
+		mingw = strings.HasPrefix(comps[2], "mingw")
+	}
+	env := EnvUnknown
+	if len(comps) > 3 {
+		env = parseEnv(comps[3])
+	}
+	format := ObjectFormatUnknown
+	if len(comps) > 4 {
+		format = parseObjectFormat(comps[4])
+	}
+	// Components already in their canonical position; these are not moved.
+	found := [4]bool{
+		arch != ArchUnknown,
+		vendor != VendorUnknown,
+		os != OSUnknown,
+		env != EnvUnknown,
+	}
+	// Move recognized components into their canonical positions.
+	for pos := range found {
+		if found[pos] {
+			continue
+		}
+		for idx := 0; idx < len(comps); idx++ {
+			// Skip components that already matched.
+			if idx < len(found) && found[idx] {
+				continue
+			}
+			comp := comps[idx]
+			valid := false
+			switch pos {
+			case 0:
+				arch = parseArch(comp)
+				valid = arch != ArchUnknown
+			case 1:
+				vendor = parseVendor(comp)
+				valid = vendor != VendorUnknown
+			case 2:
+				os = parseOS(comp)
+				cygwin = strings.HasPrefix(comp, "cygwin")
+				mingw = strings.HasPrefix(comp, "mingw")
+				valid = os != OSUnknown || cygwin || mingw
+			case 3:
+				env = parseEnv(comp)
+				valid = env != EnvUnknown
+				if !valid {
+					format = parseObjectFormat(comp)
+					valid = format != ObjectFormatUnknown
+				}
+			}
+			if !valid {
+				continue
+			}
+			if pos < idx {
+				// Insert left, pushing the existing components to the right; e.g.
+				// a-b-i386 to i386-a-b when moving i386 to the front.
+				cur := ""
+				cur, comps[idx] = comps[idx], cur
+				for i := pos; cur != ""; i++ {
+					// Skip fixed components.
+					for i < len(found) && found[i] {
+						i++
+					}
+					if i >= len(comps) {
+						comps = append(comps, "")
+					}
+					cur, comps[i] = comps[i], cur
+				}
+			} else if pos > idx {
+				// Push right by inserting empty components until the component at
+				// idx reaches pos; e.g. pc-a to -pc-a when moving pc to the second
+				// position.
+				for idx < pos {
+					// Insert one empty component at idx.
+					cur := ""
+					for i := idx; i < len(comps); {
+						cur, comps[i] = comps[i], cur
+						// Done if placed on top of an empty component.
+						if cur == "" {
+							break
+						}
+						// Advance to the next component, skipping fixed components.
+						for i++; i < len(found) && found[i]; i++ {
+						}
+					}
+					// The last component was pushed off the end.
+					if cur != "" {
+						comps = append(comps, cur)
+					}
+					// Advance idx to the new position of the component.
+					for idx++; idx < len(found) && found[idx]; idx++ {
+					}
+				}
+			}
+			found[pos] = true
+			break
+		}
+	}
+	// Replace empty components with "unknown".
+	for i, comp := range comps {
+		if comp == "" {
+			comps[i] = "unknown"
+		}
+	}
+	// Special cases.
+	if env == EnvAndroid && strings.HasPrefix(comps[3], "androideabi") {
+		// Drop the "eabi" suffix of androideabi, keeping any version.
+		comps[3] = "android" + strings.TrimPrefix(comps[3], "androideabi")
+	}
+	// SUSE uses "gnueabi" to mean "gnueabihf".
+	if vendor == VendorSUSE && env == EnvGNUEABI {
+		comps[3] = "gnueabihf"
+	}
+	switch {
+	case os == OSWin32:
+		comps = resize(comps, 4)
+		comps[2] = "windows"
+		if env == EnvUnknown {
+			if format == ObjectFormatUnknown || format == ObjectFormatCOFF {
+				comps[3] = "msvc"
+			} else {
+				comps[3] = format.String()
+			}
+		}
+	case mingw:
+		comps = resize(comps, 4)
+		comps[2] = "windows"
+		comps[3] = "gnu"
+	case cygwin:
+		comps = resize(comps, 4)
+		comps[2] = "windows"
+		comps[3] = "cygnus"
+	}
+	if mingw || cygwin || (os == OSWin32 && env != EnvUnknown) {
+		if format != ObjectFormatUnknown && format != ObjectFormatCOFF {
+			comps = resize(comps, 5)
+			comps[4] = format.String()
+		}
+	}
+	return strings.Join(comps, "-")
+}
+
+// resize resizes the given components to n, padding with "unknown" components.
+func resize(comps []string, n int) []string {
+	for len(comps) < n {
+		comps = append(comps, "unknown")
+	}
+	return comps[:n]
+}
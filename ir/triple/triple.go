@@ -0,0 +1,319 @@
+// Package triple implements parsing and normalization of target triples.
+//
+// A target triple has the general form ARCH-VENDOR-OS-ENVIRONMENT, where the
+// environment may be followed by an object file format (e.g.
+// x86_64-pc-windows-msvc-elf). Components may be omitted or appear out of
+// order in non-normalized target triples (e.g. i386-linux); Normalize returns
+// the canonical form of such target triples.
+//
+// ref: https://llvm.org/docs/LangRef.html#target-triple
+package triple
+
+import (
+	"strings"
+)
+
+// Triple is a parsed target triple.
+type Triple struct {
+	// Architecture.
+	Arch Arch
+	// Sub-architecture.
+	SubArch SubArch
+	// Vendor.
+	Vendor Vendor
+	// Operating system.
+	OS OS
+	// Environment.
+	Env Environment
+	// Object file format.
+	ObjectFormat ObjectFormat
+
+	// Target triple string.
+	data string
+}
+
+// Parse parses the given target triple. The components of the target triple
+// are parsed in position; components which are not recognized are unknown.
+// Use Parse(Normalize(s)) to parse non-normalized target triples.
+func Parse(s string) *Triple {
+	t := &Triple{data: s}
+	comps := strings.Split(s, "-")
+	t.Arch = parseArch(comps[0])
+	t.SubArch = parseSubArch(comps[0])
+	if len(comps) > 1 {
+		t.Vendor = parseVendor(comps[1])
+	}
+	if len(comps) > 2 {
+		t.OS = parseOS(comps[2])
+	}
+	if len(comps) > 3 {
+		t.Env = parseEnv(comps[3])
+		t.ObjectFormat = parseObjectFormat(comps[3])
+	}
+	if len(comps) > 4 {
+		if format := parseObjectFormat(comps[4]); format != ObjectFormatUnknown {
+			t.ObjectFormat = format
+		}
+	}
+	if t.ObjectFormat == ObjectFormatUnknown {
+		t.ObjectFormat = t.defaultObjectFormat()
+	}
+	return t
+}
+
+// String returns the target triple string.
+func (t *Triple) String() string {
+	return t.data
+}
+
+// OSName returns the operating system component of the target triple,
+// including any version (e.g. macosx10.15).
+func (t *Triple) OSName() string {
+	comps := strings.SplitN(t.data, "-", 4)
+	if len(comps) < 3 {
+		return ""
+	}
+	return comps[2]
+}
+
+// --- [ Architecture predicates ] ---------------------------------------------
+
+// PointerBitWidth returns the pointer bit width of the architecture; or 0 if
+// the architecture is unknown.
+func (t *Triple) PointerBitWidth() int {
+	switch t.Arch {
+	case ArchUnknown:
+		return 0
+	case ArchAVR, ArchMSP430:
+		return 16
+	case ArchAArch64_32, ArchARC, ArchARM, ArchARMEB, ArchCSKY, ArchHexagon,
+		ArchLanai, ArchLE32, ArchLoongArch32, ArchM68k, ArchMIPS, ArchMIPSEL,
+		ArchNVPTX, ArchPPC, ArchPPCLE, ArchR600, ArchRISCV32, ArchSPARC,
+		ArchSPARCEL, ArchSPIR, ArchSPIRV32, ArchTCE, ArchTCELE, ArchThumb,
+		ArchThumbEB, ArchWasm32, ArchX86, ArchXCore:
+		return 32
+	}
+	return 64
+}
+
+// Is16Bit reports whether the architecture has 16-bit pointers.
+func (t *Triple) Is16Bit() bool {
+	return t.PointerBitWidth() == 16
+}
+
+// Is32Bit reports whether the architecture has 32-bit pointers.
+func (t *Triple) Is32Bit() bool {
+	return t.PointerBitWidth() == 32
+}
+
+// Is64Bit reports whether the architecture has 64-bit pointers.
+func (t *Triple) Is64Bit() bool {
+	return t.PointerBitWidth() == 64
+}
+
+// IsLittleEndian reports whether the architecture is little-endian.
+func (t *Triple) IsLittleEndian() bool {
+	switch t.Arch {
+	case ArchAArch64, ArchAArch64_32, ArchAMDGCN, ArchARC, ArchARM, ArchAVR,
+		ArchBPFEL, ArchCSKY, ArchHexagon, ArchLE32, ArchLE64, ArchLoongArch32,
+		ArchLoongArch64, ArchMIPSEL, ArchMIPS64EL, ArchMSP430, ArchNVPTX,
+		ArchNVPTX64, ArchPPCLE, ArchPPC64LE, ArchR600, ArchRISCV32,
+		ArchRISCV64, ArchSPARCEL, ArchSPIR, ArchSPIR64, ArchSPIRV32,
+		ArchSPIRV64, ArchTCELE, ArchThumb, ArchVE, ArchWasm32, ArchWasm64,
+		ArchX86, ArchX86_64, ArchXCore:
+		return true
+	}
+	return false
+}
+
+// IsX86 reports whether the architecture is 32-bit or 64-bit x86.
+func (t *Triple) IsX86() bool {
+	return t.Arch == ArchX86 || t.Arch == ArchX86_64
+}
+
+// IsARM reports whether the architecture is 32-bit ARM (including Thumb).
+func (t *Triple) IsARM() bool {
+	switch t.Arch {
+	case ArchARM, ArchARMEB, ArchThumb, ArchThumbEB:
+		return true
+	}
+	return false
+}
+
+// IsAArch64 reports whether the architecture is AArch64.
+func (t *Triple) IsAArch64() bool {
+	switch t.Arch {
+	case ArchAArch64, ArchAArch64BE, ArchAArch64_32:
+		return true
+	}
+	return false
+}
+
+// IsMIPS reports whether the architecture is 32-bit or 64-bit MIPS.
+func (t *Triple) IsMIPS() bool {
+	switch t.Arch {
+	case ArchMIPS, ArchMIPSEL, ArchMIPS64, ArchMIPS64EL:
+		return true
+	}
+	return false
+}
+
+// IsPPC reports whether the architecture is 32-bit or 64-bit PowerPC.
+func (t *Triple) IsPPC() bool {
+	switch t.Arch {
+	case ArchPPC, ArchPPCLE, ArchPPC64, ArchPPC64LE:
+		return true
+	}
+	return false
+}
+
+// IsRISCV reports whether the architecture is 32-bit or 64-bit RISC-V.
+func (t *Triple) IsRISCV() bool {
+	return t.Arch == ArchRISCV32 || t.Arch == ArchRISCV64
+}
+
+// IsWasm reports whether the architecture is 32-bit or 64-bit WebAssembly.
+func (t *Triple) IsWasm() bool {
+	return t.Arch == ArchWasm32 || t.Arch == ArchWasm64
+}
+
+// IsNVPTX reports whether the architecture is 32-bit or 64-bit NVPTX.
+func (t *Triple) IsNVPTX() bool {
+	return t.Arch == ArchNVPTX || t.Arch == ArchNVPTX64
+}
+
+// IsAMDGPU reports whether the architecture is an AMD GPU.
+func (t *Triple) IsAMDGPU() bool {
+	return t.Arch == ArchR600 || t.Arch == ArchAMDGCN
+}
+
+// --- [ Operating system predicates ] -----------------------------------------
+
+// IsOSDarwin reports whether the operating system is a Darwin variant (macOS,
+// iOS, tvOS or watchOS).
+func (t *Triple) IsOSDarwin() bool {
+	switch t.OS {
+	case OSDarwin, OSMacOSX, OSIOS, OSTvOS, OSWatchOS:
+		return true
+	}
+	return false
+}
+
+// IsMacOSX reports whether the operating system is macOS.
+func (t *Triple) IsMacOSX() bool {
+	return t.OS == OSDarwin || t.OS == OSMacOSX
+}
+
+// IsiOS reports whether the operating system is iOS (including tvOS).
+func (t *Triple) IsiOS() bool {
+	return t.OS == OSIOS || t.OS == OSTvOS
+}
+
+// IsOSLinux reports whether the operating system is Linux.
+func (t *Triple) IsOSLinux() bool {
+	return t.OS == OSLinux
+}
+
+// IsOSFreeBSD reports whether the operating system is FreeBSD.
+func (t *Triple) IsOSFreeBSD() bool {
+	return t.OS == OSFreeBSD
+}
+
+// IsOSWindows reports whether the operating system is Windows.
+func (t *Triple) IsOSWindows() bool {
+	return t.OS == OSWin32
+}
+
+// IsWindowsMSVCEnvironment reports whether the target is Windows with the MSVC
+// environment.
+func (t *Triple) IsWindowsMSVCEnvironment() bool {
+	return t.OS == OSWin32 && (t.Env == EnvUnknown || t.Env == EnvMSVC)
+}
+
+// IsWindowsGNUEnvironment reports whether the target is Windows with the GNU
+// environment (MinGW).
+func (t *Triple) IsWindowsGNUEnvironment() bool {
+	return t.OS == OSWin32 && t.Env == EnvGNU
+}
+
+// IsWindowsCygwinEnvironment reports whether the target is Windows with the
+// Cygwin environment.
+func (t *Triple) IsWindowsCygwinEnvironment() bool {
+	return t.OS == OSWin32 && t.Env == EnvCygnus
+}
+
+// --- [ Environment predicates ] ----------------------------------------------
+
+// IsGNUEnvironment reports whether the environment is a GNU environment.
+func (t *Triple) IsGNUEnvironment() bool {
+	switch t.Env {
+	case EnvGNU, EnvGNUABIN32, EnvGNUABI64, EnvGNUEABI, EnvGNUEABIHF, EnvGNUX32, EnvGNUILP32:
+		return true
+	}
+	return false
+}
+
+// IsMusl reports whether the environment is a musl libc environment.
+func (t *Triple) IsMusl() bool {
+	switch t.Env {
+	case EnvMusl, EnvMuslEABI, EnvMuslEABIHF, EnvMuslX32:
+		return true
+	}
+	return false
+}
+
+// IsAndroid reports whether the environment is Android.
+func (t *Triple) IsAndroid() bool {
+	return t.Env == EnvAndroid
+}
+
+// --- [ Object file format predicates ] ---------------------------------------
+
+// IsOSBinFormatELF reports whether the object file format is ELF.
+func (t *Triple) IsOSBinFormatELF() bool {
+	return t.ObjectFormat == ObjectFormatELF
+}
+
+// IsOSBinFormatCOFF reports whether the object file format is COFF.
+func (t *Triple) IsOSBinFormatCOFF() bool {
+	return t.ObjectFormat == ObjectFormatCOFF
+}
+
+// IsOSBinFormatMachO reports whether the object file format is Mach-O.
+func (t *Triple) IsOSBinFormatMachO() bool {
+	return t.ObjectFormat == ObjectFormatMachO
+}
+
+// IsOSBinFormatWasm reports whether the object file format is WebAssembly.
+func (t *Triple) IsOSBinFormatWasm() bool {
+	return t.ObjectFormat == ObjectFormatWasm
+}
+
+// IsOSBinFormatXCOFF reports whether the object file format is XCOFF.
+func (t *Triple) IsOSBinFormatXCOFF() bool {
+	return t.ObjectFormat == ObjectFormatXCOFF
+}
+
+// IsOSBinFormatGOFF reports whether the object file format is GOFF.
+func (t *Triple) IsOSBinFormatGOFF() bool {
+	return t.ObjectFormat == ObjectFormatGOFF
+}
+
+// ### [ Helper functions ] ####################################################
+
+// defaultObjectFormat returns the default object file format of the target.
+func (t *Triple) defaultObjectFormat() ObjectFormat {
+	switch {
+	case t.IsWasm():
+		return ObjectFormatWasm
+	case t.IsOSDarwin():
+		return ObjectFormatMachO
+	case t.IsOSWindows():
+		return ObjectFormatCOFF
+	case t.OS == OSAIX:
+		return ObjectFormatXCOFF
+	case t.OS == OSZOS:
+		return ObjectFormatGOFF
+	}
+	return ObjectFormatELF
+}
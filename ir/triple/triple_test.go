@@ -0,0 +1,164 @@
+package triple_test
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/ir/datalayout"
+	"github.com/llir/llvm/ir/triple"
+)
+
+func TestNormalize(t *testing.T) {
+	// Expected values computed by LLVM 14.
+	golden := []struct {
+		in   string
+		want string
+	}{
+		{in: "i386-linux", want: "i386-unknown-linux"},
+		{in: "x86_64-apple-darwin", want: "x86_64-apple-darwin"},
+		{in: "linux-i386", want: "i386-unknown-linux"},
+		{in: "pc-i386", want: "i386-pc"},
+		{in: "a-b-i386", want: "i386-a-b"},
+		{in: "i386-pc-mingw32", want: "i386-pc-windows-gnu"},
+		{in: "x86_64-w64-mingw32", want: "x86_64-w64-windows-gnu"},
+		{in: "i686-pc-cygwin", want: "i686-pc-windows-cygnus"},
+		{in: "i686-pc-windows-gnu-elf", want: "i686-pc-windows-gnu-elf"},
+		{in: "x86_64-pc-win32", want: "x86_64-pc-windows-msvc"},
+		{in: "x86_64-pc-win32-elf", want: "x86_64-pc-windows-elf"},
+		{in: "x86_64-pc-win32-macho", want: "x86_64-pc-windows-macho"},
+		{in: "i686-unknown-windows-coff", want: "i686-unknown-windows-msvc"},
+		{in: "x86_64-pc-windows-msvc-elf", want: "x86_64-pc-windows-msvc-elf"},
+		{in: "i686-linux-gnu-pc", want: "i686-pc-linux-gnu"},
+		{in: "armv7-linux-androideabi", want: "armv7-unknown-linux-android"},
+		{in: "arm-linux-androideabi21", want: "arm-unknown-linux-android21"},
+		{in: "armv7-suse-linux-gnueabi", want: "armv7-suse-linux-gnueabihf"},
+		{in: "x86_64-gnu-linux", want: "x86_64-unknown-linux-gnu"},
+		{in: "unknown-x86_64", want: "x86_64-unknown"},
+		{in: "gnu-x86_64-linux", want: "x86_64-unknown-linux-gnu"},
+		{in: "amd64", want: "amd64"},
+		{in: "aarch64-none-elf", want: "aarch64-none-unknown-elf"},
+		{in: "mips-mti-linux-gnu", want: "mips-mti-linux-gnu"},
+	}
+	for _, g := range golden {
+		if got := triple.Normalize(g.in); got != g.want {
+			t.Errorf("%q: normalized target triple mismatch; expected %q, got %q", g.in, g.want, got)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	golden := []struct {
+		in     string
+		arch   triple.Arch
+		sub    triple.SubArch
+		vendor triple.Vendor
+		os     triple.OS
+		env    triple.Environment
+		format triple.ObjectFormat
+	}{
+		{in: "x86_64-unknown-linux-gnu", arch: triple.ArchX86_64, os: triple.OSLinux, env: triple.EnvGNU, format: triple.ObjectFormatELF},
+		{in: "i686-pc-windows-msvc", arch: triple.ArchX86, vendor: triple.VendorPC, os: triple.OSWin32, env: triple.EnvMSVC, format: triple.ObjectFormatCOFF},
+		{in: "arm64-apple-macosx11.0.0", arch: triple.ArchAArch64, vendor: triple.VendorApple, os: triple.OSMacOSX, format: triple.ObjectFormatMachO},
+		{in: "arm64e-apple-ios", arch: triple.ArchAArch64, sub: triple.SubArchARM64E, vendor: triple.VendorApple, os: triple.OSIOS, format: triple.ObjectFormatMachO},
+		{in: "thumbv7em-unknown-none-eabi", arch: triple.ArchThumb, sub: triple.SubArchARMv7em, env: triple.EnvEABI, format: triple.ObjectFormatELF},
+		{in: "armebv7-unknown-linux-gnueabihf", arch: triple.ArchARMEB, sub: triple.SubArchARMv7, os: triple.OSLinux, env: triple.EnvGNUEABIHF, format: triple.ObjectFormatELF},
+		{in: "mipsisa64r6el-unknown-linux-gnuabi64", arch: triple.ArchMIPS64EL, sub: triple.SubArchMipsR6, os: triple.OSLinux, env: triple.EnvGNUABI64, format: triple.ObjectFormatELF},
+		{in: "wasm32-unknown-wasi", arch: triple.ArchWasm32, os: triple.OSWASI, format: triple.ObjectFormatWasm},
+		{in: "x86_64-pc-windows-msvc-elf", arch: triple.ArchX86_64, vendor: triple.VendorPC, os: triple.OSWin32, env: triple.EnvMSVC, format: triple.ObjectFormatELF},
+		{in: "armfoo-unknown-linux", os: triple.OSLinux, format: triple.ObjectFormatELF},
+	}
+	for _, g := range golden {
+		tt := triple.Parse(g.in)
+		if tt.Arch != g.arch || tt.SubArch != g.sub || tt.Vendor != g.vendor || tt.OS != g.os || tt.Env != g.env || tt.ObjectFormat != g.format {
+			t.Errorf("%q: target triple mismatch; expected %v %v %v %v %v %q, got %v %v %v %v %v %q", g.in, g.arch, g.sub, g.vendor, g.os, g.env, g.format, tt.Arch, tt.SubArch, tt.Vendor, tt.OS, tt.Env, tt.ObjectFormat)
+		}
+	}
+	// Predicates.
+	win := triple.Parse("x86_64-pc-windows-gnu")
+	if !win.IsOSWindows() || !win.IsWindowsGNUEnvironment() || win.IsWindowsMSVCEnvironment() || !win.IsOSBinFormatCOFF() {
+		t.Errorf("%q: Windows predicates mismatch", win)
+	}
+	if got, want := win.PointerBitWidth(), 64; got != want {
+		t.Errorf("%q: pointer bit width mismatch; expected %d, got %d", win, want, got)
+	}
+	golden2 := []struct {
+		in           string
+		littleEndian bool
+		ptrBits      int
+	}{
+		{in: "avr-unknown-unknown", littleEndian: true, ptrBits: 16},
+		{in: "armv7-unknown-linux-gnueabihf", littleEndian: true, ptrBits: 32},
+		{in: "powerpc64-unknown-linux-gnu", littleEndian: false, ptrBits: 64},
+		{in: "s390x-ibm-linux", littleEndian: false, ptrBits: 64},
+		{in: "aarch64_32-apple-watchos", littleEndian: true, ptrBits: 32},
+		{in: "foo-bar-baz", littleEndian: false, ptrBits: 0},
+	}
+	for _, g := range golden2 {
+		tt := triple.Parse(g.in)
+		if got := tt.IsLittleEndian(); got != g.littleEndian {
+			t.Errorf("%q: endianness mismatch; expected little-endian %v, got %v", g.in, g.littleEndian, got)
+		}
+		if got := tt.PointerBitWidth(); got != g.ptrBits {
+			t.Errorf("%q: pointer bit width mismatch; expected %d, got %d", g.in, g.ptrBits, got)
+		}
+	}
+}
+
+func TestDataLayout(t *testing.T) {
+	// Expected values computed by LLVM 14.
+	golden := []struct {
+		in   string
+		want string
+	}{
+		{in: "x86_64-unknown-linux-gnu", want: "e-m:e-p270:32:32-p271:32:32-p272:64:64-i64:64-f80:128-n8:16:32:64-S128"},
+		{in: "x86_64-apple-macosx", want: "e-m:o-p270:32:32-p271:32:32-p272:64:64-i64:64-f80:128-n8:16:32:64-S128"},
+		{in: "x86_64-pc-windows-msvc", want: "e-m:w-p270:32:32-p271:32:32-p272:64:64-i64:64-f80:128-n8:16:32:64-S128"},
+		{in: "x86_64-pc-windows-elf", want: "e-m:e-p270:32:32-p271:32:32-p272:64:64-i64:64-f80:128-n8:16:32:64-S128"},
+		{in: "i386-unknown-linux-gnu", want: "e-m:e-p:32:32-p270:32:32-p271:32:32-p272:64:64-f64:32:64-f80:32-n8:16:32-S128"},
+		{in: "i686-pc-windows-msvc", want: "e-m:x-p:32:32-p270:32:32-p271:32:32-p272:64:64-i64:64-f80:128-n8:16:32-a:0:32-S32"},
+		{in: "i686-w64-windows-gnu", want: "e-m:x-p:32:32-p270:32:32-p271:32:32-p272:64:64-i64:64-f80:32-n8:16:32-a:0:32-S32"},
+		{in: "i386-apple-macosx", want: "e-m:o-p:32:32-p270:32:32-p271:32:32-p272:64:64-f64:32:64-f80:128-n8:16:32-S128"},
+		{in: "i386-pc-elfiamcu", want: "e-m:e-p:32:32-p270:32:32-p271:32:32-p272:64:64-i64:32-f64:32-f128:32-n8:16:32-a:0:32-S32"},
+		{in: "aarch64-unknown-linux-gnu", want: "e-m:e-i8:8:32-i16:16:32-i64:64-i128:128-n32:64-S128"},
+		{in: "aarch64-unknown-linux-gnu_ilp32", want: "e-m:e-p:32:32-i8:8:32-i16:16:32-i64:64-i128:128-n32:64-S128"},
+		{in: "aarch64_be-unknown-linux-gnu", want: "E-m:e-i8:8:32-i16:16:32-i64:64-i128:128-n32:64-S128"},
+		{in: "arm64-apple-macosx", want: "e-m:o-i64:64-i128:128-n32:64-S128"},
+		{in: "arm64_32-apple-watchos", want: "e-m:o-p:32:32-i64:64-i128:128-n32:64-S128"},
+		{in: "aarch64-pc-windows-msvc", want: "e-m:w-p:64:64-i32:32-i64:64-i128:128-n32:64-S128"},
+		{in: "armv7-unknown-linux-gnueabihf", want: "e-m:e-p:32:32-Fi8-i64:64-v128:64:128-a:0:32-n32-S64"},
+		{in: "armebv7-unknown-linux-gnueabi", want: "E-m:e-p:32:32-Fi8-i64:64-v128:64:128-a:0:32-n32-S64"},
+		{in: "thumbv7em-none-eabi", want: "e-m:e-p:32:32-Fi8-i64:64-v128:64:128-a:0:32-n32-S64"},
+		{in: "thumbv7m-apple-darwin-eabi", want: "e-m:o-p:32:32-Fi8-i64:64-v128:64:128-a:0:32-n32-S64"},
+		{in: "armv7-apple-ios", want: "e-m:o-p:32:32-Fi8-f64:32:64-v64:32:64-v128:32:128-a:0:32-n32-S32"},
+		{in: "armv7k-apple-watchos", want: "e-m:o-p:32:32-Fi8-i64:64-a:0:32-n32-S128"},
+		{in: "armv7-pc-windows-msvc", want: "e-m:w-p:32:32-Fi8-i64:64-v128:64:128-a:0:32-n32-S64"},
+		{in: "riscv32-unknown-elf", want: "e-m:e-p:32:32-i64:64-n32-S128"},
+		{in: "riscv64-unknown-linux-gnu", want: "e-m:e-p:64:64-i64:64-i128:128-n64-S128"},
+		{in: "wasm32-unknown-unknown", want: "e-m:e-p:32:32-p10:8:8-p20:8:8-i64:64-n32:64-S128-ni:1:10:20"},
+		{in: "wasm64-unknown-unknown", want: "e-m:e-p:64:64-p10:8:8-p20:8:8-i64:64-n32:64-S128-ni:1:10:20"},
+		{in: "powerpc64le-unknown-linux-gnu", want: "e-m:e-i64:64-n32:64-S128-v256:256:256-v512:512:512"},
+		{in: "powerpc64-unknown-linux-gnu", want: "E-m:e-i64:64-n32:64-S128-v256:256:256-v512:512:512"},
+		{in: "powerpc-unknown-linux-gnu", want: "E-m:e-p:32:32-i64:64-n32"},
+		{in: "s390x-unknown-linux-gnu", want: "E-m:e-i1:8:16-i8:8:16-i64:64-f128:64-a:8:16-n32:64"},
+		{in: "mips-unknown-linux-gnu", want: "E-m:m-p:32:32-i8:8:32-i16:16:32-i64:64-n32-S64"},
+		{in: "mipsel-unknown-linux-gnu", want: "e-m:m-p:32:32-i8:8:32-i16:16:32-i64:64-n32-S64"},
+		{in: "mips64el-unknown-linux-gnuabi64", want: "e-m:e-i8:8:32-i16:16:32-i64:64-n32:64-S128"},
+		{in: "nvptx64-nvidia-cuda", want: "e-i64:64-i128:128-v16:16-v32:32-n16:32:64"},
+		{in: "amdgcn-amd-amdhsa", want: "e-p:64:64-p1:64:64-p2:32:32-p3:32:32-p4:64:64-p5:32:32-p6:32:32-i64:64-v16:16-v24:32-v32:32-v48:64-v96:128-v192:256-v256:256-v512:512-v1024:1024-v2048:2048-n32:64-S32-A5-G1-ni:7"},
+		{in: "sparc-unknown-linux-gnu", want: "E-m:e-p:32:32-i64:64-f128:64-n32-S64"},
+		{in: "sparcv9-unknown-linux-gnu", want: "E-m:e-i64:64-n32:64-S128"},
+		{in: "avr-unknown-unknown", want: "e-P1-p:16:8-i8:8-i16:8-i32:8-i64:8-f32:8-f64:8-n8-a:8"},
+		{in: "msp430-unknown-unknown", want: "e-m:e-p:16:16-i32:16-i64:16-f32:16-f64:16-a:8-n8:16-S16"},
+		{in: "bpfel", want: "e-m:e-p:64:64-i64:64-i128:128-n32:64-S128"},
+		{in: "foo-bar-baz", want: ""},
+	}
+	for _, g := range golden {
+		got := triple.Parse(g.in).DataLayout()
+		if got != g.want {
+			t.Errorf("%q: data layout mismatch; expected %q, got %q", g.in, g.want, got)
+			continue
+		}
+		if _, err := datalayout.Parse(got); err != nil {
+			t.Errorf("%q: unable to parse data layout %q; %+v", g.in, got, err)
+		}
+	}
+}
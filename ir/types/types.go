@@ -48,6 +48,8 @@ var (
 	I32Ptr  = &PointerType{ElemType: I32}  // i32*
 	I64Ptr  = &PointerType{ElemType: I64}  // i64*
 	I128Ptr = &PointerType{ElemType: I128} // i128*
+	// Opaque pointer types.
+	Ptr = &PointerType{} // ptr
 )
 
 // Convenience functions.
@@ -76,6 +78,15 @@ func IsFloat(t Type) bool {
 	return ok
 }
 
+// IsFloatOrFloatVector reports whether the given type is a floating-point type
+// or a vector of floating-point type.
+func IsFloatOrFloatVector(t Type) bool {
+	if t, ok := t.(*VectorType); ok {
+		return IsFloat(t.ElemType)
+	}
+	return IsFloat(t)
+}
+
 // IsMMX reports whether the given type is an MMX type.
 func IsMMX(t Type) bool {
 	_, ok := t.(*MMXType)
@@ -88,6 +99,12 @@ func IsPointer(t Type) bool {
 	return ok
 }
 
+// IsOpaquePointer reports whether the given type is an opaque pointer type.
+func IsOpaquePointer(t Type) bool {
+	p, ok := t.(*PointerType)
+	return ok && p.ElemType == nil
+}
+
 // IsVector reports whether the given type is a vector type.
 func IsVector(t Type) bool {
 	_, ok := t.(*VectorType)
@@ -438,11 +455,12 @@ func (t *MMXType) SetName(name string) {
 
 // --- [ Pointer types ] -------------------------------------------------------
 
-// PointerType is an LLVM IR pointer type.
+// PointerType is an LLVM IR pointer type. A pointer type without element type
+// is an opaque pointer type (ptr).
 type PointerType struct {
 	// Type name; or empty if not present.
 	TypeName string
-	// Element type.
+	// Element type; or nil if opaque pointer type.
 	ElemType Type
 	// Address space; or zero value for default address space.
 	AddrSpace AddrSpace
@@ -455,6 +473,14 @@ func NewPointer(elemType Type) *PointerType {
 	}
 }
 
+// NewOpaquePointer returns a new opaque pointer type based on the given
+// address space.
+func NewOpaquePointer(addrSpace AddrSpace) *PointerType {
+	return &PointerType{
+		AddrSpace: addrSpace,
+	}
+}
+
 // Equal reports whether t and u are of equal type.
 func (t *PointerType) Equal(u Type) bool {
 	// HACK: to prevent infinite loops (e.g. struct foo containing field of type
@@ -473,6 +499,13 @@ func (t *PointerType) String() string {
 // LLString returns the LLVM syntax representation of the definition of the
 // type.
 func (t *PointerType) LLString() string {
+	// 'ptr' AddrSpaceopt
+	if t.ElemType == nil {
+		if t.AddrSpace != 0 {
+			return fmt.Sprintf("ptr %s", t.AddrSpace)
+		}
+		return "ptr"
+	}
 	// Elem=Type AddrSpaceopt '*'
 	buf := &strings.Builder{}
 	buf.WriteString(t.ElemType.String())
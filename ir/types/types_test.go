@@ -152,6 +152,7 @@ func TestIsPointer(t *testing.T) {
 	}{
 		{t: &PointerType{ElemType: I8}, want: true},
 		{t: NewPointer(I8), want: true},
+		{t: Ptr, want: true},
 		{t: I8, want: false},
 	}
 	for _, g := range golden {
@@ -162,6 +163,43 @@ func TestIsPointer(t *testing.T) {
 	}
 }
 
+func TestIsOpaquePointer(t *testing.T) {
+	golden := []struct {
+		t    Type
+		want bool
+	}{
+		{t: &PointerType{}, want: true},
+		{t: NewOpaquePointer(1), want: true},
+		{t: Ptr, want: true},
+		{t: I8Ptr, want: false},
+		{t: I8, want: false},
+	}
+	for _, g := range golden {
+		got := IsOpaquePointer(g.t)
+		if g.want != got {
+			t.Errorf("check if `%s` is an opaque pointer type mismatch; expected %t, got %t", g.t, g.want, got)
+		}
+	}
+}
+
+func TestPointerTypeString(t *testing.T) {
+	golden := []struct {
+		t    *PointerType
+		want string
+	}{
+		{t: I8Ptr, want: "i8*"},
+		{t: &PointerType{ElemType: I32, AddrSpace: 2}, want: "i32 addrspace(2)*"},
+		{t: Ptr, want: "ptr"},
+		{t: NewOpaquePointer(3), want: "ptr addrspace(3)"},
+	}
+	for _, g := range golden {
+		got := g.t.String()
+		if g.want != got {
+			t.Errorf("pointer type string mismatch; expected %q, got %q", g.want, got)
+		}
+	}
+}
+
 func TestIsVector(t *testing.T) {
 	golden := []struct {
 		t    Type
@@ -0,0 +1,438 @@
+package ir
+
+import (
+	"fmt"
+
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/value"
+)
+
+// === [ Use-def chains ] ======================================================
+
+// Use is a use of a value as an operand of a user.
+type Use struct {
+	// User of the value.
+	//
+	// User has one of the following underlying types.
+	//
+	//   - [ir.Instruction] (operand or operand bundle input)
+	//   - [ir.Terminator] (operand or operand bundle input)
+	//   - [constant.Constant] (operand of constant expression, element of
+	//     aggregate constant or function of blockaddress constant)
+	//   - [*ir.Global] (initializer)
+	//   - [*ir.Func] (prefix, prologue or personality)
+	//   - [*ir.Alias] (aliasee)
+	//   - [*ir.IFunc] (resolver)
+	//
+	// [constant.Constant]: https://pkg.go.dev/github.com/llir/llvm/ir/constant#Constant
+	User interface{}
+	// Operand slot holding the used value; non-nil if User is an instruction or
+	// terminator (value.User).
+	Val *value.Value
+
+	// get returns the used value of the operand slot.
+	get func() value.Value
+	// set replaces the used value of the operand slot.
+	set func(v value.Value)
+}
+
+// Value returns the used value of the operand slot.
+func (u *Use) Value() value.Value {
+	if u.Val != nil {
+		return *u.Val
+	}
+	return u.get()
+}
+
+// Set replaces the used value of the operand slot with v. If the user is not
+// an instruction or terminator, v must be a constant.Constant (or a value.Named
+// basic block when replacing the basic block of a blockaddress constant).
+// Constant users are modified in place, affecting every holder of the same
+// constant object.
+func (u *Use) Set(v value.Value) {
+	if u.Val != nil {
+		*u.Val = v
+		return
+	}
+	u.set(v)
+}
+
+// Users returns the uses of the given value within the function (including
+// uses from constant expressions of operands and from the prefix, prologue and
+// personality of the function).
+//
+// The use lists of the function are computed on demand and cached. Call
+// InvalidateUses after modifying the function by other means than the ir
+// package rewriting API (e.g. ReplaceAllUsesWith and Block.Remove).
+func (f *Func) Users(v value.Value) []*Use {
+	if f.uses == nil {
+		b := newUseBuilder()
+		b.addFunc(f)
+		f.uses = b.uses
+	}
+	return f.uses[v]
+}
+
+// InvalidateUses drops the cached use lists of the function.
+func (f *Func) InvalidateUses() {
+	f.uses = nil
+}
+
+// Users returns the uses of the given value within the module; including uses
+// from instructions and terminators of function bodies, from constant
+// expressions, from global variable initializers, and from aliasees and
+// resolvers of aliases and IFuncs.
+//
+// The use lists of the module are computed on demand and cached. Call
+// InvalidateUses after modifying the module by other means than the ir
+// package rewriting API (e.g. ReplaceAllUsesWith and Block.Remove).
+func (m *Module) Users(v value.Value) []*Use {
+	if m.uses == nil {
+		b := newUseBuilder()
+		for _, g := range m.Globals {
+			if g.Init != nil {
+				b.addConstSlot(g, &g.Init)
+			}
+		}
+		for _, alias := range m.Aliases {
+			b.addConstSlot(alias, &alias.Aliasee)
+		}
+		for _, ifunc := range m.IFuncs {
+			b.addConstSlot(ifunc, &ifunc.Resolver)
+		}
+		for _, f := range m.Funcs {
+			b.addFunc(f)
+		}
+		m.uses = b.uses
+	}
+	return m.uses[v]
+}
+
+// InvalidateUses drops the cached use lists of the module and of its
+// functions.
+func (m *Module) InvalidateUses() {
+	m.uses = nil
+	for _, f := range m.Funcs {
+		f.InvalidateUses()
+	}
+}
+
+// ReplaceAllUsesWith replaces all uses of old within the function with new,
+// and returns the number of replaced uses.
+//
+// The scope of the replacement is given by the receiver, rather than by a
+// package-level ReplaceAllUsesWith(old, new), as instructions and parameters
+// do not reference their parent function. Use Module.ReplaceAllUsesWith to
+// replace uses throughout a module.
+//
+// Uses from operands of constant expressions are replaced within the constant
+// expressions, which are modified in place. Constant expressions are not
+// copied, so the replacement affects every holder of the same constant
+// expression object; including instructions of other functions and global
+// variable initializers if the object is shared (e.g. in programmatically
+// built IR).
+func (f *Func) ReplaceAllUsesWith(old, new value.Value) int {
+	uses := f.Users(old)
+	replaceUses(uses, new)
+	f.InvalidateUses()
+	if f.Parent != nil {
+		f.Parent.uses = nil
+	}
+	return len(uses)
+}
+
+// ReplaceAllUsesWith replaces all uses of old within the module with new, and
+// returns the number of replaced uses.
+func (m *Module) ReplaceAllUsesWith(old, new value.Value) int {
+	uses := m.Users(old)
+	replaceUses(uses, new)
+	m.InvalidateUses()
+	return len(uses)
+}
+
+// ### [ Helper functions ] ####################################################
+
+// useBuilder records the uses of named values.
+type useBuilder struct {
+	// Map from used value to uses.
+	uses map[value.Value][]*Use
+	// Constant users already visited; used to record the operands of constants
+	// shared between several users only once.
+	visited map[constant.Constant]bool
+}
+
+// newUseBuilder returns a new use builder.
+func newUseBuilder() *useBuilder {
+	return &useBuilder{
+		uses:    make(map[value.Value][]*Use),
+		visited: make(map[constant.Constant]bool),
+	}
+}
+
+// addFunc records the uses of the given function.
+func (b *useBuilder) addFunc(f *Func) {
+	if f.Prefix != nil {
+		b.addConstSlot(f, &f.Prefix)
+	}
+	if f.Prologue != nil {
+		b.addConstSlot(f, &f.Prologue)
+	}
+	if f.Personality != nil {
+		b.addConstSlot(f, &f.Personality)
+	}
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			b.addUser(inst)
+		}
+		if block.Term != nil {
+			b.addUser(block.Term)
+		}
+	}
+}
+
+// addUser records the uses of the operands of the given instruction or
+// terminator.
+func (b *useBuilder) addUser(user value.User) {
+	for _, op := range user.Operands() {
+		// Record the use of the argument value of function arguments with
+		// parameter attributes, to preserve the attributes on replacement.
+		if arg, ok := (*op).(*Arg); ok {
+			op = &arg.Value
+		}
+		b.addValueSlot(user, op)
+	}
+	for _, bundle := range operandBundles(user) {
+		for i := range bundle.Inputs {
+			b.addValueSlot(user, &bundle.Inputs[i])
+		}
+	}
+}
+
+// addValueSlot records the use of the value held by the given operand slot of
+// an instruction or terminator.
+func (b *useBuilder) addValueSlot(user value.User, slot *value.Value) {
+	v := *slot
+	if v == nil {
+		return
+	}
+	b.add(v, &Use{User: user, Val: slot})
+	if c, ok := v.(constant.Constant); ok {
+		b.addConstOperands(c)
+	}
+}
+
+// addConstSlot records the use of the constant held by the given operand slot
+// of a constant, global variable, function, alias or IFunc.
+func (b *useBuilder) addConstSlot(user interface{}, slot *constant.Constant) {
+	c := *slot
+	if c == nil {
+		return
+	}
+	use := &Use{
+		User: user,
+		get:  func() value.Value { return *slot },
+		set: func(v value.Value) {
+			c, ok := v.(constant.Constant)
+			if !ok {
+				panic(fmt.Errorf("invalid replacement of constant operand; expected constant.Constant, got %T", v))
+			}
+			*slot = c
+		},
+	}
+	b.add(c, use)
+	b.addConstOperands(c)
+}
+
+// addConstOperands records the uses of the operands of the given constant.
+func (b *useBuilder) addConstOperands(c constant.Constant) {
+	if b.visited[c] {
+		return
+	}
+	b.visited[c] = true
+	switch c := c.(type) {
+	// Aggregate constants.
+	case *constant.Struct:
+		for i := range c.Fields {
+			b.addConstSlot(c, &c.Fields[i])
+		}
+	case *constant.Array:
+		for i := range c.Elems {
+			b.addConstSlot(c, &c.Elems[i])
+		}
+	case *constant.Vector:
+		for i := range c.Elems {
+			b.addConstSlot(c, &c.Elems[i])
+		}
+	// Function and basic block addresses.
+	case *constant.BlockAddress:
+		b.addConstSlot(c, &c.Func)
+		if c.Block != nil {
+			slot := &c.Block
+			use := &Use{
+				User: c,
+				get:  func() value.Value { return *slot },
+				set: func(v value.Value) {
+					block, ok := v.(value.Named)
+					if !ok {
+						panic(fmt.Errorf("invalid replacement of blockaddress basic block; expected value.Named, got %T", v))
+					}
+					*slot = block
+				},
+			}
+			b.add(c.Block, use)
+		}
+	case *constant.DSOLocalEquivalent:
+		b.addConstSlot(c, &c.Func)
+	case *constant.NoCFI:
+		b.addConstSlot(c, &c.Func)
+	// Constant expressions.
+	case constant.Expression:
+		for _, slot := range exprOperands(c) {
+			b.addConstSlot(c, slot)
+		}
+	}
+}
+
+// add records the given use of v. Only uses of named values are recorded.
+func (b *useBuilder) add(v value.Value, use *Use) {
+	if _, ok := v.(value.Named); !ok {
+		return
+	}
+	b.uses[v] = append(b.uses[v], use)
+}
+
+// operandBundles returns the operand bundles of the given instruction or
+// terminator.
+func operandBundles(user value.User) []*OperandBundle {
+	switch user := user.(type) {
+	case *InstCall:
+		return user.OperandBundles
+	case *TermInvoke:
+		return user.OperandBundles
+	case *TermCallBr:
+		return user.OperandBundles
+	}
+	return nil
+}
+
+// exprOperands returns a mutable list of operands of the given constant
+// expression.
+func exprOperands(expr constant.Expression) []*constant.Constant {
+	switch e := expr.(type) {
+	// Unary expressions.
+	case *constant.ExprFNeg:
+		return []*constant.Constant{&e.X}
+	// Binary expressions.
+	case *constant.ExprAdd:
+		return []*constant.Constant{&e.X, &e.Y}
+	case *constant.ExprSub:
+		return []*constant.Constant{&e.X, &e.Y}
+	case *constant.ExprMul:
+		return []*constant.Constant{&e.X, &e.Y}
+	// Bitwise expressions.
+	case *constant.ExprShl:
+		return []*constant.Constant{&e.X, &e.Y}
+	case *constant.ExprLShr:
+		return []*constant.Constant{&e.X, &e.Y}
+	case *constant.ExprAShr:
+		return []*constant.Constant{&e.X, &e.Y}
+	case *constant.ExprAnd:
+		return []*constant.Constant{&e.X, &e.Y}
+	case *constant.ExprOr:
+		return []*constant.Constant{&e.X, &e.Y}
+	case *constant.ExprXor:
+		return []*constant.Constant{&e.X, &e.Y}
+	// Vector expressions.
+	case *constant.ExprExtractElement:
+		return []*constant.Constant{&e.X, &e.Index}
+	case *constant.ExprInsertElement:
+		return []*constant.Constant{&e.X, &e.Elem, &e.Index}
+	case *constant.ExprShuffleVector:
+		return []*constant.Constant{&e.X, &e.Y, &e.Mask}
+	// Memory expressions.
+	case *constant.ExprGetElementPtr:
+		ops := make([]*constant.Constant, 0, 1+len(e.Indices))
+		ops = append(ops, &e.Src)
+		for i := range e.Indices {
+			// Unpack inrange indices.
+			if index, ok := e.Indices[i].(*constant.Index); ok {
+				ops = append(ops, &index.Constant)
+			} else {
+				ops = append(ops, &e.Indices[i])
+			}
+		}
+		return ops
+	// Conversion expressions.
+	case *constant.ExprTrunc:
+		return []*constant.Constant{&e.From}
+	case *constant.ExprZExt:
+		return []*constant.Constant{&e.From}
+	case *constant.ExprSExt:
+		return []*constant.Constant{&e.From}
+	case *constant.ExprFPTrunc:
+		return []*constant.Constant{&e.From}
+	case *constant.ExprFPExt:
+		return []*constant.Constant{&e.From}
+	case *constant.ExprFPToUI:
+		return []*constant.Constant{&e.From}
+	case *constant.ExprFPToSI:
+		return []*constant.Constant{&e.From}
+	case *constant.ExprUIToFP:
+		return []*constant.Constant{&e.From}
+	case *constant.ExprSIToFP:
+		return []*constant.Constant{&e.From}
+	case *constant.ExprPtrToInt:
+		return []*constant.Constant{&e.From}
+	case *constant.ExprIntToPtr:
+		return []*constant.Constant{&e.From}
+	case *constant.ExprBitCast:
+		return []*constant.Constant{&e.From}
+	case *constant.ExprAddrSpaceCast:
+		return []*constant.Constant{&e.From}
+	// Other expressions.
+	case *constant.ExprICmp:
+		return []*constant.Constant{&e.X, &e.Y}
+	case *constant.ExprFCmp:
+		return []*constant.Constant{&e.X, &e.Y}
+	case *constant.ExprSelect:
+		return []*constant.Constant{&e.Cond, &e.X, &e.Y}
+	default:
+		panic(fmt.Errorf("support for constant expression %T not yet implemented", expr))
+	}
+}
+
+// replaceUses replaces the used value of each use with new.
+func replaceUses(uses []*Use, new value.Value) {
+	for _, use := range uses {
+		use.Set(new)
+		// Invalidate cached successors of terminators, as the replaced value may
+		// be a target basic block.
+		if term, ok := use.User.(Terminator); ok {
+			resetSuccs(term)
+		}
+	}
+}
+
+// resetSuccs resets the cached successor basic blocks of the given terminator.
+func resetSuccs(term Terminator) {
+	switch term := term.(type) {
+	case *TermBr:
+		term.Successors = nil
+	case *TermCondBr:
+		term.Successors = nil
+	case *TermSwitch:
+		term.Successors = nil
+	case *TermIndirectBr:
+		term.Successors = nil
+	case *TermInvoke:
+		term.Successors = nil
+	case *TermCallBr:
+		term.Successors = nil
+	case *TermCatchSwitch:
+		term.Successors = nil
+	case *TermCatchRet:
+		term.Successors = nil
+	case *TermCleanupRet:
+		term.Successors = nil
+	}
+}
@@ -0,0 +1,161 @@
+package ir
+
+import (
+	"testing"
+
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+)
+
+func TestFuncUsers(t *testing.T) {
+	m := NewModule()
+	g := m.NewGlobalDef("x", constant.NewInt(types.I32, 0))
+	f := m.NewFunc("f", types.I32)
+	entry := f.NewBlock("entry")
+	exit := f.NewBlock("exit")
+	a := entry.NewAlloca(types.I32)
+	store := entry.NewStore(constant.NewInt(types.I32, 1), a)
+	load := entry.NewLoad(types.I32, a)
+	br := entry.NewBr(exit)
+	phi := exit.NewPhi(NewIncoming(load, entry))
+	add := exit.NewAdd(phi, constant.NewPtrToInt(g, types.I32))
+	exit.NewRet(add)
+
+	if uses := f.Users(a); len(uses) != 2 || uses[0].User != store || uses[1].User != load {
+		t.Errorf("uses of %s mismatch; expected store and load users, got %v", a.Ident(), uses)
+	}
+	if uses := f.Users(phi); len(uses) != 1 || uses[0].User != add {
+		t.Errorf("uses of %s mismatch; expected add user, got %v", phi.Ident(), uses)
+	}
+	if uses := f.Users(load); len(uses) != 1 || uses[0].User != phi {
+		t.Errorf("uses of %s mismatch; expected phi user, got %v", load.Ident(), uses)
+	}
+	// Basic block uses (br and phi incoming predecessor).
+	if uses := f.Users(entry); len(uses) != 1 || uses[0].User != phi {
+		t.Errorf("uses of %s mismatch; expected phi user, got %v", entry.Ident(), uses)
+	}
+	if uses := f.Users(exit); len(uses) != 1 || uses[0].User != br {
+		t.Errorf("uses of %s mismatch; expected br user, got %v", exit.Ident(), uses)
+	}
+	// Use from constant expression.
+	uses := f.Users(g)
+	if len(uses) != 1 {
+		t.Fatalf("number of uses of %s mismatch; expected 1, got %d", g.Ident(), len(uses))
+	}
+	if _, ok := uses[0].User.(*constant.ExprPtrToInt); !ok {
+		t.Errorf("user of %s mismatch; expected *constant.ExprPtrToInt, got %T", g.Ident(), uses[0].User)
+	}
+	if uses[0].Value() != g {
+		t.Errorf("used value mismatch; expected %s, got %s", g.Ident(), uses[0].Value().Ident())
+	}
+}
+
+func TestModuleUsers(t *testing.T) {
+	m := NewModule()
+	x := m.NewGlobalDef("x", constant.NewInt(types.I32, 42))
+	y := m.NewGlobalDef("y", x)
+	alias := m.NewAlias("z", x)
+	f := m.NewFunc("f", types.I32)
+	entry := f.NewBlock("")
+	load := entry.NewLoad(types.I32, x)
+	entry.NewRet(load)
+	want := map[interface{}]bool{y: true, alias: true, load: true}
+	uses := m.Users(x)
+	if len(uses) != len(want) {
+		t.Fatalf("number of uses of %s mismatch; expected %d, got %d", x.Ident(), len(want), len(uses))
+	}
+	for _, use := range uses {
+		if !want[use.User] {
+			t.Errorf("unexpected user %T of %s", use.User, x.Ident())
+		}
+	}
+	// Replace operand of global initializer.
+	for _, use := range uses {
+		if use.User == y {
+			use.Set(constant.NewNull(types.I32Ptr))
+		}
+	}
+	if _, ok := y.Init.(*constant.Null); !ok {
+		t.Errorf("initializer of %s mismatch; expected *constant.Null, got %T", y.Ident(), y.Init)
+	}
+}
+
+func TestReplaceAllUsesWith(t *testing.T) {
+	m := NewModule()
+	g := m.NewGlobalDef("g", constant.NewArray(types.NewArray(2, types.I32), constant.NewInt(types.I32, 1), constant.NewInt(types.I32, 2)))
+	h := m.NewGlobalDef("h", constant.NewArray(types.NewArray(2, types.I32), constant.NewInt(types.I32, 3), constant.NewInt(types.I32, 4)))
+	f := m.NewFunc("f", types.I32, NewParam("x", types.I32))
+	entry := f.NewBlock("")
+	a := f.NewBlock("a")
+	b := f.NewBlock("b")
+	exit := f.NewBlock("exit")
+	entry.NewSwitch(f.Params[0], a, NewCase(constant.NewInt(types.I32, 1), b))
+	a.NewBr(exit)
+	b.NewBr(exit)
+	zero := constant.NewInt(types.I32, 0)
+	elem := constant.NewGetElementPtr(g.ContentType, g, zero, zero)
+	load := exit.NewLoad(types.I32, elem)
+	phi := exit.NewPhi(NewIncoming(load, a), NewIncoming(f.Params[0], b))
+	exit.NewRet(phi)
+
+	// Replace global used in constant expression.
+	if n := m.ReplaceAllUsesWith(g, h); n != 1 {
+		t.Errorf("number of replaced uses of %s mismatch; expected 1, got %d", g.Ident(), n)
+	}
+	if elem.Src != h {
+		t.Errorf("getelementptr source mismatch; expected %s, got %s", h.Ident(), elem.Src.Ident())
+	}
+	// Replace basic block used by switch case and phi incoming.
+	if n := f.ReplaceAllUsesWith(b, a); n != 2 {
+		t.Errorf("number of replaced uses of %s mismatch; expected 2, got %d", b.Ident(), n)
+	}
+	if succs := entry.Term.Succs(); succs[1] != a {
+		t.Errorf("successor of switch case mismatch; expected %s, got %s", a.Ident(), succs[1].Ident())
+	}
+	if phi.Incs[1].Pred != a {
+		t.Errorf("phi predecessor mismatch; expected %s, got %s", a.Ident(), phi.Incs[1].Pred.Ident())
+	}
+}
+
+func TestBlockRemove(t *testing.T) {
+	f := NewFunc("f", types.I32)
+	entry := f.NewBlock("")
+	x := entry.NewAdd(constant.NewInt(types.I32, 1), constant.NewInt(types.I32, 2))
+	y := entry.NewMul(x, constant.NewInt(types.I32, 3))
+	entry.NewRet(x)
+	if err := entry.Remove(x); err == nil {
+		t.Errorf("expected error when removing instruction with users")
+	}
+	if err := entry.Remove(y); err != nil {
+		t.Errorf("unable to remove instruction; %v", err)
+	}
+	if err := entry.Remove(y); err == nil {
+		t.Errorf("expected error when removing instruction not in basic block")
+	}
+	if len(entry.Insts) != 1 || entry.Insts[0] != x {
+		t.Errorf("instructions of basic block mismatch; expected [%s], got %v", x.Ident(), entry.Insts)
+	}
+	want := "define i32 @f() {\n0:\n\t%1 = add i32 1, 2\n\tret i32 %1\n}"
+	if got := f.LLString(); got != want {
+		t.Errorf("function mismatch; expected %q, got %q", want, got)
+	}
+}
+
+func TestReplaceArg(t *testing.T) {
+	m := NewModule()
+	callee := m.NewFunc("g", types.Void, NewParam("p", types.I32Ptr))
+	f := m.NewFunc("f", types.Void)
+	entry := f.NewBlock("entry")
+	a := entry.NewAlloca(types.I32)
+	b := entry.NewAlloca(types.I32)
+	arg := NewArg(a, enum.ParamAttrNoCapture)
+	entry.NewCall(callee, arg)
+	entry.NewRet(nil)
+	if n := f.ReplaceAllUsesWith(a, b); n != 1 {
+		t.Fatalf("number of replaced uses mismatch; expected 1, got %d", n)
+	}
+	if arg.Value != b || len(arg.Attrs) != 1 {
+		t.Errorf("function argument mismatch; expected %s with parameter attributes, got %s", b.Ident(), arg)
+	}
+}
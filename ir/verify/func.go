@@ -0,0 +1,350 @@
+package verify
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/llir/llvm/internal/enc"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/dom"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// funcVerifier verifies the body of a function.
+type funcVerifier struct {
+	*verifier
+	// Function being verified.
+	f *ir.Func
+	// Error context of the function.
+	ctx string
+	// Parameters of the function.
+	params map[*ir.Param]bool
+	// Position of the definition of each local variable, including basic
+	// blocks, of the function.
+	defs map[value.Value]pos
+	// Predecessor basic blocks of each basic block, with one entry per incoming
+	// control flow edge.
+	preds map[*ir.Block][]*ir.Block
+	// Dominator tree of the function.
+	domTree *dom.Tree
+	// Local ID of each unnamed local variable, including basic blocks, of the
+	// function; as assigned by ir.Func.AssignIDs, without modifying the
+	// function.
+	ids map[value.Value]int64
+}
+
+// pos is the position of an instruction or terminator within a function.
+type pos struct {
+	// Basic block containing the instruction or terminator.
+	block *ir.Block
+	// Index of the instruction within the basic block; the index of the
+	// terminator is the number of instructions in the basic block.
+	index int
+}
+
+// verifyFunc verifies the well-formedness of the body of the given function.
+func (v *verifier) verifyFunc(f *ir.Func) {
+	if len(f.Blocks) == 0 {
+		return
+	}
+	ctx := fmt.Sprintf("function %s", f.Ident())
+	// The ir package panics on some malformed IR (e.g. when computing the type
+	// of a call instruction with an invalid callee); report such panics as
+	// verification errors.
+	defer func() {
+		if e := recover(); e != nil {
+			v.errorf("%s: %v", ctx, e)
+		}
+	}()
+	fv := &funcVerifier{
+		verifier: v,
+		f:        f,
+		ctx:      ctx,
+		params:   make(map[*ir.Param]bool),
+		defs:     make(map[value.Value]pos),
+		preds:    make(map[*ir.Block][]*ir.Block),
+		ids:      make(map[value.Value]int64),
+	}
+	if !fv.assignIDs() {
+		return
+	}
+	for _, param := range f.Params {
+		fv.params[param] = true
+	}
+	for _, block := range f.Blocks {
+		fv.defs[block] = pos{block: block}
+		for i, inst := range block.Insts {
+			if inst, ok := inst.(value.Value); ok {
+				fv.defs[inst] = pos{block: block, index: i}
+			}
+		}
+		if block.Term == nil {
+			v.errorf("%s: basic block %s does not end in a terminator", ctx, fv.ident(block))
+			continue
+		}
+		if term, ok := block.Term.(value.Value); ok {
+			fv.defs[term] = pos{block: block, index: len(block.Insts)}
+		}
+	}
+	// Control flow graph.
+	for _, block := range f.Blocks {
+		if block.Term == nil {
+			continue
+		}
+		for _, succ := range block.Term.Succs() {
+			if _, ok := fv.defs[succ]; !ok {
+				v.errorf("%s: successor basic block %s of %s not in function", ctx, fv.ident(succ), fv.ident(block))
+				continue
+			}
+			fv.preds[succ] = append(fv.preds[succ], block)
+		}
+	}
+	entry := f.Blocks[0]
+	if len(fv.preds[entry]) > 0 {
+		v.errorf("%s: entry basic block %s may not have predecessors", ctx, fv.ident(entry))
+	}
+	fv.domTree = dom.New(f)
+	for _, block := range f.Blocks {
+		fv.verifyBlock(block)
+	}
+}
+
+// verifyBlock verifies the well-formedness of the given basic block.
+func (fv *funcVerifier) verifyBlock(block *ir.Block) {
+	ctx := fmt.Sprintf("%s: basic block %s", fv.ctx, fv.ident(block))
+	atTop := true
+	for i, inst := range block.Insts {
+		instCtx := fmt.Sprintf("%s: %s", ctx, fv.describe(inst))
+		if phi, ok := inst.(*ir.InstPhi); ok {
+			if !atTop {
+				fv.errorf("%s: phi instruction not at top of basic block", instCtx)
+			}
+			fv.verifyPhi(instCtx, phi, block)
+		} else {
+			atTop = false
+			fv.verifyUses(instCtx, inst, pos{block: block, index: i})
+		}
+		fv.verifyInst(instCtx, inst)
+	}
+	if block.Term != nil {
+		termCtx := fmt.Sprintf("%s: %s", ctx, fv.describe(block.Term))
+		fv.verifyUses(termCtx, block.Term, pos{block: block, index: len(block.Insts)})
+		fv.verifyTerm(termCtx, block.Term)
+	}
+}
+
+// verifyPhi verifies the well-formedness of the given phi instruction of the
+// basic block.
+func (fv *funcVerifier) verifyPhi(ctx string, phi *ir.InstPhi, block *ir.Block) {
+	// Number of incoming control flow edges of each predecessor basic block.
+	preds := make(map[*ir.Block]int)
+	for _, pred := range fv.preds[block] {
+		preds[pred]++
+	}
+	incs := make(map[*ir.Block]int)
+	incValues := make(map[*ir.Block]value.Value)
+	for _, inc := range phi.Incs {
+		pred, ok := inc.Pred.(*ir.Block)
+		if !ok {
+			fv.errorf("%s: invalid incoming predecessor type; expected *ir.Block, got %T", ctx, inc.Pred)
+			continue
+		}
+		if !inc.X.Type().Equal(phi.Typ) {
+			fv.errorf("%s: type of incoming value %s mismatch; expected %v, got %v", ctx, fv.ident(inc.X), phi.Typ, inc.X.Type())
+		}
+		if prev, ok := incValues[pred]; ok && prev != inc.X {
+			fv.errorf("%s: different incoming values %s and %s for predecessor %s", ctx, fv.ident(prev), fv.ident(inc.X), fv.ident(pred))
+		}
+		incValues[pred] = inc.X
+		incs[pred]++
+		// The incoming value is used at the end of the predecessor basic block.
+		if _, ok := preds[pred]; ok {
+			fv.verifyUse(ctx, inc.X, pos{block: pred, index: len(pred.Insts) + 1})
+		}
+	}
+	for _, pred := range sortedBlocks(fv.f, incs) {
+		if n := incs[pred]; n != preds[pred] {
+			if preds[pred] == 0 {
+				fv.errorf("%s: incoming predecessor %s not a predecessor of basic block", ctx, fv.ident(pred))
+			} else {
+				fv.errorf("%s: number of incoming values for predecessor %s mismatch; expected %d, got %d", ctx, fv.ident(pred), preds[pred], n)
+			}
+		}
+	}
+	for _, pred := range sortedBlocks(fv.f, preds) {
+		if _, ok := incs[pred]; !ok {
+			fv.errorf("%s: missing incoming value for predecessor %s", ctx, fv.ident(pred))
+		}
+	}
+}
+
+// verifyUses verifies that the operands of the given instruction or terminator
+// at the specified position are defined within the function and that their
+// definitions dominate the use.
+func (fv *funcVerifier) verifyUses(ctx string, user value.User, use pos) {
+	for _, op := range user.Operands() {
+		if *op == nil {
+			continue
+		}
+		fv.verifyUse(ctx, *op, use)
+	}
+	for _, bundle := range operandBundles(user) {
+		for _, input := range bundle.Inputs {
+			fv.verifyUse(ctx, input, use)
+		}
+	}
+}
+
+// verifyUse verifies that the given value is defined within the function and
+// that its definition dominates the use at the specified position.
+func (fv *funcVerifier) verifyUse(ctx string, v value.Value, use pos) {
+	// Function arguments with parameter attributes wrap the argument value.
+	if arg, ok := v.(*ir.Arg); ok {
+		v = arg.Value
+	}
+	switch v := v.(type) {
+	case *ir.Param:
+		if !fv.params[v] {
+			fv.errorf("%s: use of parameter %s not in function", ctx, fv.ident(v))
+		}
+		return
+	case *ir.Block:
+		if _, ok := fv.defs[v]; !ok {
+			fv.errorf("%s: use of basic block %s not in function", ctx, fv.ident(v))
+		}
+		return
+	case ir.Instruction, ir.Terminator:
+		// checked below.
+	default:
+		return
+	}
+	def, ok := fv.defs[v]
+	if !ok {
+		fv.errorf("%s: use of instruction %s not in function", ctx, fv.ident(v))
+		return
+	}
+	// Uses in unreachable basic blocks are not checked for dominance.
+	if !fv.domTree.Reachable(use.block) {
+		return
+	}
+	if !fv.dominates(def, use) {
+		fv.errorf("%s: definition of %s does not dominate use", ctx, fv.ident(v))
+	}
+}
+
+// dominates reports whether the definition at position def dominates the use
+// at position use.
+func (fv *funcVerifier) dominates(def, use pos) bool {
+	if def.block == use.block {
+		return def.index < use.index
+	}
+	// Definitions in unreachable basic blocks do not dominate reachable uses.
+	return fv.domTree.Dominates(def.block, use.block)
+}
+
+// ### [ Helper functions ] ####################################################
+
+// sortedBlocks returns the keys of the given map of basic blocks, in the order
+// of the basic blocks in the function; followed by basic blocks not in the
+// function.
+func sortedBlocks(f *ir.Func, m map[*ir.Block]int) []*ir.Block {
+	var blocks []*ir.Block
+	inFunc := make(map[*ir.Block]bool)
+	for _, block := range f.Blocks {
+		inFunc[block] = true
+		if _, ok := m[block]; ok {
+			blocks = append(blocks, block)
+		}
+	}
+	for block := range m {
+		if !inFunc[block] {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// describe returns a description of the given instruction or terminator, for
+// use in error messages (e.g. "add instruction %3" or "ret terminator").
+func (fv *funcVerifier) describe(inst interface{}) string {
+	name := strings.TrimPrefix(fmt.Sprintf("%T", inst), "*ir.")
+	kind := "instruction"
+	if strings.HasPrefix(name, "Term") {
+		kind = "terminator"
+	}
+	name = strings.ToLower(strings.TrimPrefix(strings.TrimPrefix(name, "Inst"), "Term"))
+	if name == "condbr" {
+		name = "br"
+	}
+	if n, ok := inst.(value.Named); ok && !types.Equal(n.Type(), types.Void) {
+		return fmt.Sprintf("%s %s %s", name, kind, fv.ident(n))
+	}
+	return fmt.Sprintf("%s %s", name, kind)
+}
+
+// assignIDs records the local IDs of unnamed local variables of the function,
+// as assigned by ir.Func.AssignIDs, and reports whether the local IDs already
+// assigned are valid.
+func (fv *funcVerifier) assignIDs() bool {
+	id := int64(0)
+	valid := true
+	assign := func(v value.Value) {
+		n, ok := v.(unnamedVar)
+		if !ok || !n.IsUnnamed() || types.Equal(v.Type(), types.Void) {
+			return
+		}
+		if n.ID() != 0 && n.ID() != id {
+			fv.errorf("%s: invalid local ID of %s, expected %s", fv.ctx, v.Ident(), enc.LocalID(id))
+			valid = false
+		}
+		fv.ids[v] = id
+		id++
+	}
+	for _, param := range fv.f.Params {
+		assign(param)
+	}
+	for _, block := range fv.f.Blocks {
+		assign(block)
+		for _, inst := range block.Insts {
+			if v, ok := inst.(value.Value); ok {
+				assign(v)
+			}
+		}
+		if term, ok := block.Term.(value.Value); ok {
+			assign(term)
+		}
+	}
+	return valid
+}
+
+// ident returns the identifier of the given value, using the local IDs
+// recorded for unnamed local variables.
+func (fv *funcVerifier) ident(v value.Value) string {
+	if id, ok := fv.ids[v]; ok {
+		return enc.LocalID(id)
+	}
+	return v.Ident()
+}
+
+// unnamedVar is a local variable which may be unnamed.
+type unnamedVar interface {
+	// IsUnnamed reports whether the local variable is unnamed.
+	IsUnnamed() bool
+	// ID returns the local ID of the local variable; or 0 if not assigned.
+	ID() int64
+}
+
+// operandBundles returns the operand bundles of the given call site; or nil if
+// not a call site.
+func operandBundles(user value.User) []*ir.OperandBundle {
+	switch user := user.(type) {
+	case *ir.InstCall:
+		return user.OperandBundles
+	case *ir.TermInvoke:
+		return user.OperandBundles
+	case *ir.TermCallBr:
+		return user.OperandBundles
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package verify
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+)
+
+// verifyInst verifies the operand types of the given instruction.
+func (fv *funcVerifier) verifyInst(ctx string, inst ir.Instruction) {
+	switch inst := inst.(type) {
+	// Binary instructions.
+	case *ir.InstAdd:
+		fv.verifyBinary(ctx, inst.X, inst.Y, isIntOrIntVector)
+	case *ir.InstFAdd:
+		fv.verifyBinary(ctx, inst.X, inst.Y, types.IsFloatOrFloatVector)
+	case *ir.InstSub:
+		fv.verifyBinary(ctx, inst.X, inst.Y, isIntOrIntVector)
+	case *ir.InstFSub:
+		fv.verifyBinary(ctx, inst.X, inst.Y, types.IsFloatOrFloatVector)
+	case *ir.InstMul:
+		fv.verifyBinary(ctx, inst.X, inst.Y, isIntOrIntVector)
+	case *ir.InstFMul:
+		fv.verifyBinary(ctx, inst.X, inst.Y, types.IsFloatOrFloatVector)
+	case *ir.InstUDiv:
+		fv.verifyBinary(ctx, inst.X, inst.Y, isIntOrIntVector)
+	case *ir.InstSDiv:
+		fv.verifyBinary(ctx, inst.X, inst.Y, isIntOrIntVector)
+	case *ir.InstFDiv:
+		fv.verifyBinary(ctx, inst.X, inst.Y, types.IsFloatOrFloatVector)
+	case *ir.InstURem:
+		fv.verifyBinary(ctx, inst.X, inst.Y, isIntOrIntVector)
+	case *ir.InstSRem:
+		fv.verifyBinary(ctx, inst.X, inst.Y, isIntOrIntVector)
+	case *ir.InstFRem:
+		fv.verifyBinary(ctx, inst.X, inst.Y, types.IsFloatOrFloatVector)
+	// Bitwise instructions.
+	case *ir.InstShl:
+		fv.verifyBinary(ctx, inst.X, inst.Y, isIntOrIntVector)
+	case *ir.InstLShr:
+		fv.verifyBinary(ctx, inst.X, inst.Y, isIntOrIntVector)
+	case *ir.InstAShr:
+		fv.verifyBinary(ctx, inst.X, inst.Y, isIntOrIntVector)
+	case *ir.InstAnd:
+		fv.verifyBinary(ctx, inst.X, inst.Y, isIntOrIntVector)
+	case *ir.InstOr:
+		fv.verifyBinary(ctx, inst.X, inst.Y, isIntOrIntVector)
+	case *ir.InstXor:
+		fv.verifyBinary(ctx, inst.X, inst.Y, isIntOrIntVector)
+	// Memory instructions.
+	case *ir.InstAlloca:
+		fv.verifyAlign(ctx, inst.Align)
+	case *ir.InstLoad:
+		fv.verifyPointee(ctx, "source", inst.Src, inst.ElemType)
+		fv.verifyAlign(ctx, inst.Align)
+	case *ir.InstStore:
+		fv.verifyPointee(ctx, "destination", inst.Dst, inst.Src.Type())
+		fv.verifyAlign(ctx, inst.Align)
+	// Other instructions.
+	case *ir.InstICmp:
+		fv.verifyBinary(ctx, inst.X, inst.Y, isIntOrPtrOrVector)
+	case *ir.InstFCmp:
+		fv.verifyBinary(ctx, inst.X, inst.Y, types.IsFloatOrFloatVector)
+	case *ir.InstCall:
+		fv.verifyCall(ctx, inst.FuncType, inst.Callee, inst.Args)
+	}
+}
+
+// verifyTerm verifies the operand types of the given terminator.
+func (fv *funcVerifier) verifyTerm(ctx string, term ir.Terminator) {
+	switch term := term.(type) {
+	case *ir.TermRet:
+		retType := fv.f.Sig.RetType
+		switch {
+		case term.X == nil && !retType.Equal(types.Void):
+			fv.errorf("%s: missing return value of type %v", ctx, retType)
+		case term.X != nil && !term.X.Type().Equal(retType):
+			fv.errorf("%s: return value type mismatch; expected %v, got %v", ctx, retType, term.X.Type())
+		}
+	case *ir.TermCondBr:
+		if !term.Cond.Type().Equal(types.I1) {
+			fv.errorf("%s: branching condition type mismatch; expected %v, got %v", ctx, types.I1, term.Cond.Type())
+		}
+	case *ir.TermSwitch:
+		if !types.IsInt(term.X.Type()) {
+			fv.errorf("%s: invalid control variable type; expected integer type, got %v", ctx, term.X.Type())
+		}
+		for _, c := range term.Cases {
+			if !c.X.Type().Equal(term.X.Type()) {
+				fv.errorf("%s: case comparand type mismatch; expected %v, got %v", ctx, term.X.Type(), c.X.Type())
+			}
+		}
+	case *ir.TermInvoke:
+		fv.verifyCall(ctx, term.FuncType, term.Invokee, term.Args)
+	case *ir.TermCallBr:
+		fv.verifyCall(ctx, term.FuncType, term.Callee, term.Args)
+	}
+}
+
+// verifyBinary verifies that the operands of a binary instruction have
+// identical types, and that the type satisfies the given predicate.
+func (fv *funcVerifier) verifyBinary(ctx string, x, y value.Value, valid func(t types.Type) bool) {
+	xType, yType := x.Type(), y.Type()
+	if !xType.Equal(yType) {
+		fv.errorf("%s: operand type mismatch; %v and %v", ctx, xType, yType)
+		return
+	}
+	if !valid(xType) {
+		fv.errorf("%s: invalid operand type %v", ctx, xType)
+	}
+}
+
+// verifyPointee verifies that the given address operand is of pointer type
+// with the specified element type.
+func (fv *funcVerifier) verifyPointee(ctx, name string, addr value.Value, elemType types.Type) {
+	t, ok := addr.Type().(*types.PointerType)
+	if !ok {
+		fv.errorf("%s: invalid %s address type; expected pointer type, got %v", ctx, name, addr.Type())
+		return
+	}
+	// The element type of opaque pointer types is unknown.
+	if t.ElemType != nil && !t.ElemType.Equal(elemType) {
+		fv.errorf("%s: %s address type mismatch; expected pointer to %v, got %v", ctx, name, elemType, t)
+	}
+}
+
+// verifyCall verifies that the types of the given callee and function
+// arguments match the function signature of the callee. The explicit function
+// signature funcType, which may be nil, takes precedence over the type of the
+// callee.
+func (fv *funcVerifier) verifyCall(ctx string, funcType *types.FuncType, callee value.Value, args []value.Value) {
+	sig := funcType
+	if sig == nil {
+		if f, ok := callee.(*ir.Func); ok {
+			sig = f.Sig
+		} else if t, ok := callee.Type().(*types.PointerType); ok {
+			sig, _ = t.ElemType.(*types.FuncType)
+		}
+	}
+	if sig == nil {
+		fv.errorf("%s: invalid callee type; expected pointer to function type, got %v", ctx, callee.Type())
+		return
+	}
+	if len(args) < len(sig.Params) || (!sig.Variadic && len(args) != len(sig.Params)) {
+		fv.errorf("%s: number of arguments mismatch; expected %d, got %d", ctx, len(sig.Params), len(args))
+		return
+	}
+	for i, param := range sig.Params {
+		if argType := args[i].Type(); !argType.Equal(param) {
+			fv.errorf("%s: type of argument %d mismatch; expected %v, got %v", ctx, i, param, argType)
+		}
+	}
+}
+
+// ### [ Helper functions ] ####################################################
+
+// isIntOrIntVector reports whether the given type is an integer type or a
+// vector of integers type.
+func isIntOrIntVector(t types.Type) bool {
+	return types.IsInt(elemType(t))
+}
+
+// isIntOrPtrOrVector reports whether the given type is an integer type, a
+// pointer type or a vector of integers or pointers type.
+func isIntOrPtrOrVector(t types.Type) bool {
+	e := elemType(t)
+	return types.IsInt(e) || types.IsPointer(e)
+}
+
+// elemType returns the element type of the given vector type, or t itself if
+// not a vector type.
+func elemType(t types.Type) types.Type {
+	if t, ok := t.(*types.VectorType); ok {
+		return t.ElemType
+	}
+	return t
+}
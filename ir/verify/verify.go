@@ -0,0 +1,255 @@
+// Package verify implements a verifier for LLVM IR modules, which checks the
+// well-formedness of modules similar to `opt -verify`.
+//
+// The verifier checks that
+//
+//   - every basic block ends in a terminator,
+//   - phi instructions are at the top of basic blocks and match the set of
+//     predecessor basic blocks,
+//   - operand types match for binary, comparison, store and call instructions,
+//   - the definition of each value dominates all of its uses,
+//   - declarations, definitions, aliases and IFuncs have valid linkage,
+//   - aliasees and IFunc resolvers are definitions,
+//   - comdats and alignments are valid.
+package verify
+
+import (
+	"fmt"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/pkg/errors"
+)
+
+// maxAlign specifies the maximum alignment supported by LLVM.
+const maxAlign = 1 << 32
+
+// Module verifies the well-formedness of the given LLVM IR module. The returned
+// slice contains one error for each violation found, or is empty if the module
+// is well-formed.
+func Module(m *ir.Module) []error {
+	v := &verifier{m: m}
+	v.verifyModule()
+	return v.errs
+}
+
+// Func verifies the well-formedness of the given LLVM IR function. The returned
+// slice contains one error for each violation found, or is empty if the
+// function is well-formed.
+func Func(f *ir.Func) []error {
+	v := &verifier{m: f.Parent}
+	v.verifyFuncHeader(f, nil)
+	v.verifyFunc(f)
+	return v.errs
+}
+
+// verifier records the errors found during verification.
+type verifier struct {
+	// Module being verified; or nil if verifying a stand-alone function.
+	m *ir.Module
+	// Errors found during verification.
+	errs []error
+}
+
+// errorf records an error with the given format and arguments.
+func (v *verifier) errorf(format string, args ...interface{}) {
+	v.errs = append(v.errs, errors.Errorf(format, args...))
+}
+
+// verifyModule verifies the well-formedness of the module.
+func (v *verifier) verifyModule() {
+	// Comdat definitions.
+	comdats := make(map[string]*ir.ComdatDef)
+	for _, def := range v.m.ComdatDefs {
+		if _, ok := comdats[def.Name]; ok {
+			v.errorf("redefinition of comdat %s", def)
+			continue
+		}
+		comdats[def.Name] = def
+	}
+	// Global identifiers.
+	idents := make(map[string]bool)
+	checkIdent := func(ident string) {
+		if idents[ident] {
+			v.errorf("redefinition of global identifier %s", ident)
+		}
+		idents[ident] = true
+	}
+	// Global variables.
+	for _, g := range v.m.Globals {
+		checkIdent(g.Ident())
+		v.verifyGlobal(g, comdats)
+	}
+	// Functions.
+	for _, f := range v.m.Funcs {
+		checkIdent(f.Ident())
+		v.verifyFuncHeader(f, comdats)
+		v.verifyFunc(f)
+	}
+	// Aliases.
+	for _, a := range v.m.Aliases {
+		checkIdent(a.Ident())
+		v.verifyAlias(a)
+	}
+	// IFuncs.
+	for _, i := range v.m.IFuncs {
+		checkIdent(i.Ident())
+		v.verifyIFunc(i)
+	}
+}
+
+// verifyGlobal verifies the well-formedness of the given global variable.
+func (v *verifier) verifyGlobal(g *ir.Global, comdats map[string]*ir.ComdatDef) {
+	ctx := fmt.Sprintf("global variable %s", g.Ident())
+	isDecl := g.Init == nil
+	v.verifyLinkage(ctx, g.Linkage, isDecl)
+	if g.Linkage == enum.LinkageAppending && !types.IsArray(g.ContentType) {
+		v.errorf("%s: invalid linkage %v; only global arrays may have appending linkage", ctx, g.Linkage)
+	}
+	if g.Init != nil && !g.Init.Type().Equal(g.ContentType) {
+		v.errorf("%s: initializer type mismatch; expected %v, got %v", ctx, g.ContentType, g.Init.Type())
+	}
+	v.verifyComdat(ctx, g.Comdat, isDecl, comdats)
+	v.verifyAlign(ctx, g.Align)
+}
+
+// verifyFuncHeader verifies the well-formedness of the header of the given
+// function.
+func (v *verifier) verifyFuncHeader(f *ir.Func, comdats map[string]*ir.ComdatDef) {
+	ctx := fmt.Sprintf("function %s", f.Ident())
+	isDecl := len(f.Blocks) == 0
+	v.verifyLinkage(ctx, f.Linkage, isDecl)
+	if f.Linkage == enum.LinkageAppending {
+		v.errorf("%s: invalid linkage %v; only global variables may have appending linkage", ctx, f.Linkage)
+	}
+	if len(f.Params) != len(f.Sig.Params) {
+		v.errorf("%s: number of parameters mismatch; expected %d, got %d", ctx, len(f.Sig.Params), len(f.Params))
+	} else {
+		for i, param := range f.Params {
+			if !param.Typ.Equal(f.Sig.Params[i]) {
+				v.errorf("%s: type of parameter %s mismatch; expected %v, got %v", ctx, param.Ident(), f.Sig.Params[i], param.Typ)
+			}
+		}
+	}
+	v.verifyComdat(ctx, f.Comdat, isDecl, comdats)
+	v.verifyAlign(ctx, f.Align)
+}
+
+// verifyAlias verifies the well-formedness of the given alias.
+func (v *verifier) verifyAlias(a *ir.Alias) {
+	ctx := fmt.Sprintf("alias %s", a.Ident())
+	v.verifyIndirectLinkage(ctx, a.Linkage)
+	// Follow the chain of aliases to the aliased object.
+	visited := map[*ir.Alias]bool{a: true}
+	c := a.Aliasee
+	for {
+		switch base := stripCasts(c).(type) {
+		case *ir.Global:
+			if base.Init == nil {
+				v.errorf("%s: aliasee %s must be a definition", ctx, base.Ident())
+			}
+		case *ir.Func:
+			if len(base.Blocks) == 0 {
+				v.errorf("%s: aliasee %s must be a definition", ctx, base.Ident())
+			}
+		case *ir.Alias:
+			if visited[base] {
+				v.errorf("%s: aliases cannot form a cycle", ctx)
+				return
+			}
+			visited[base] = true
+			c = base.Aliasee
+			continue
+		default:
+			v.errorf("%s: invalid aliasee %s; expected global variable, function or alias", ctx, c.Ident())
+		}
+		return
+	}
+}
+
+// verifyIFunc verifies the well-formedness of the given IFunc.
+func (v *verifier) verifyIFunc(i *ir.IFunc) {
+	ctx := fmt.Sprintf("IFunc %s", i.Ident())
+	v.verifyIndirectLinkage(ctx, i.Linkage)
+	resolver, ok := stripCasts(i.Resolver).(*ir.Func)
+	if !ok {
+		v.errorf("%s: invalid resolver %s; expected function", ctx, i.Resolver.Ident())
+		return
+	}
+	if len(resolver.Blocks) == 0 {
+		v.errorf("%s: resolver %s must be a definition", ctx, resolver.Ident())
+	}
+}
+
+// verifyIndirectLinkage verifies the linkage of an alias or IFunc.
+func (v *verifier) verifyIndirectLinkage(ctx string, linkage enum.Linkage) {
+	switch linkage {
+	case enum.LinkageNone, enum.LinkageExternal, enum.LinkageInternal, enum.LinkagePrivate, enum.LinkageLinkOnce, enum.LinkageLinkOnceODR, enum.LinkageWeak, enum.LinkageWeakODR:
+		// valid linkage of alias or IFunc.
+	default:
+		v.errorf("%s: invalid linkage %v; expected private, internal, linkonce, weak, linkonce_odr, weak_odr or external", ctx, linkage)
+	}
+}
+
+// verifyLinkage verifies the linkage of a global declaration or definition.
+func (v *verifier) verifyLinkage(ctx string, linkage enum.Linkage, isDecl bool) {
+	if isDecl {
+		switch linkage {
+		case enum.LinkageNone, enum.LinkageExternal, enum.LinkageExternWeak:
+			// valid linkage of declaration.
+		default:
+			v.errorf("%s: invalid linkage %v of declaration; expected external or extern_weak", ctx, linkage)
+		}
+		return
+	}
+	if linkage == enum.LinkageExternWeak {
+		v.errorf("%s: invalid linkage %v of definition", ctx, linkage)
+	}
+}
+
+// verifyComdat verifies the comdat of a global declaration or definition.
+func (v *verifier) verifyComdat(ctx string, comdat *ir.ComdatDef, isDecl bool, comdats map[string]*ir.ComdatDef) {
+	if comdat == nil {
+		return
+	}
+	if isDecl {
+		v.errorf("%s: declaration may not be in comdat %s", ctx, comdat)
+	}
+	if comdats != nil && comdats[comdat.Name] != comdat {
+		v.errorf("%s: comdat %s not defined in module", ctx, comdat)
+	}
+}
+
+// verifyAlign verifies the given alignment.
+func (v *verifier) verifyAlign(ctx string, align ir.Align) {
+	if align == 0 {
+		return
+	}
+	if align&(align-1) != 0 {
+		v.errorf("%s: invalid alignment %d; not a power of two", ctx, uint64(align))
+	}
+	if align > maxAlign {
+		v.errorf("%s: invalid alignment %d; exceeds maximum alignment %d", ctx, uint64(align), uint64(maxAlign))
+	}
+}
+
+// ### [ Helper functions ] ####################################################
+
+// stripCasts returns the given constant with bitcast, addrspacecast and
+// getelementptr constant expressions stripped.
+func stripCasts(c constant.Constant) constant.Constant {
+	for {
+		switch expr := c.(type) {
+		case *constant.ExprBitCast:
+			c = expr.From
+		case *constant.ExprAddrSpaceCast:
+			c = expr.From
+		case *constant.ExprGetElementPtr:
+			c = expr.Src
+		default:
+			return c
+		}
+	}
+}
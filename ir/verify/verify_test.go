@@ -0,0 +1,322 @@
+package verify_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/asm"
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/verify"
+)
+
+func TestModuleValid(t *testing.T) {
+	golden := []struct {
+		path string
+	}{
+		{path: "../../asm/testdata/inst_binary.ll"},
+		{path: "../../asm/testdata/inst_memory.ll"},
+		{path: "../../asm/testdata/terminator.ll"},
+		{path: "../../asm/testdata/func_align.ll"},
+		{path: "../../asm/testdata/global_align.ll"},
+		{path: "../../asm/testdata/opaque_pointer.ll"},
+	}
+	for _, g := range golden {
+		m, err := asm.ParseFile(g.path)
+		if err != nil {
+			t.Errorf("unable to parse %q; %+v", g.path, err)
+			continue
+		}
+		for _, err := range verify.Module(m) {
+			t.Errorf("%q: unexpected verification error; %v", g.path, err)
+		}
+	}
+}
+
+func TestModuleInvalid(t *testing.T) {
+	i32 := types.I32
+	one := constant.NewInt(i32, 1)
+	golden := []struct {
+		name string
+		// build returns a malformed module.
+		build func() *ir.Module
+		// Expected error message.
+		want string
+	}{
+		{
+			name: "missing terminator",
+			build: func() *ir.Module {
+				m := ir.NewModule()
+				f := m.NewFunc("f", types.Void)
+				f.NewBlock("entry")
+				return m
+			},
+			want: "function @f: basic block %entry does not end in a terminator",
+		},
+		{
+			name: "phi not at top",
+			build: func() *ir.Module {
+				m := ir.NewModule()
+				f := m.NewFunc("f", i32)
+				entry := f.NewBlock("entry")
+				exit := f.NewBlock("exit")
+				entry.NewBr(exit)
+				exit.NewAdd(one, one)
+				phi := exit.NewPhi(ir.NewIncoming(one, entry))
+				exit.NewRet(phi)
+				return m
+			},
+			want: "function @f: basic block %exit: phi instruction %1: phi instruction not at top of basic block",
+		},
+		{
+			name: "phi predecessor mismatch",
+			build: func() *ir.Module {
+				m := ir.NewModule()
+				f := m.NewFunc("f", i32, ir.NewParam("c", types.I1))
+				entry := f.NewBlock("entry")
+				a := f.NewBlock("a")
+				exit := f.NewBlock("exit")
+				entry.NewCondBr(f.Params[0], a, exit)
+				a.NewBr(exit)
+				phi := exit.NewPhi(ir.NewIncoming(one, a))
+				exit.NewRet(phi)
+				return m
+			},
+			want: "function @f: basic block %exit: phi instruction %0: missing incoming value for predecessor %entry",
+		},
+		{
+			name: "binary operand type mismatch",
+			build: func() *ir.Module {
+				m := ir.NewModule()
+				f := m.NewFunc("f", i32)
+				entry := f.NewBlock("entry")
+				x := entry.NewAdd(one, constant.NewInt(types.I64, 2))
+				entry.NewRet(x)
+				return m
+			},
+			want: "function @f: basic block %entry: add instruction %0: operand type mismatch; i32 and i64",
+		},
+		{
+			name: "icmp operand type mismatch",
+			build: func() *ir.Module {
+				m := ir.NewModule()
+				f := m.NewFunc("f", types.I1)
+				entry := f.NewBlock("entry")
+				x := entry.NewICmp(enum.IPredEQ, one, constant.NewInt(types.I8, 2))
+				entry.NewRet(x)
+				return m
+			},
+			want: "function @f: basic block %entry: icmp instruction %0: operand type mismatch; i32 and i8",
+		},
+		{
+			name: "store type mismatch",
+			build: func() *ir.Module {
+				m := ir.NewModule()
+				f := m.NewFunc("f", types.Void)
+				entry := f.NewBlock("entry")
+				a := entry.NewAlloca(types.I64)
+				entry.Insts = append(entry.Insts, &ir.InstStore{Src: one, Dst: a})
+				entry.NewRet(nil)
+				return m
+			},
+			want: "function @f: basic block %entry: store instruction: destination address type mismatch; expected pointer to i32, got i64*",
+		},
+		{
+			name: "call argument type mismatch",
+			build: func() *ir.Module {
+				m := ir.NewModule()
+				g := m.NewFunc("g", types.Void, ir.NewParam("x", types.I64))
+				f := m.NewFunc("f", types.Void)
+				entry := f.NewBlock("entry")
+				entry.NewCall(g, one)
+				entry.NewRet(nil)
+				return m
+			},
+			want: "function @f: basic block %entry: call instruction: type of argument 0 mismatch; expected i64, got i32",
+		},
+		{
+			name: "definition does not dominate use",
+			build: func() *ir.Module {
+				m := ir.NewModule()
+				f := m.NewFunc("f", i32, ir.NewParam("c", types.I1))
+				entry := f.NewBlock("entry")
+				a := f.NewBlock("a")
+				exit := f.NewBlock("exit")
+				entry.NewCondBr(f.Params[0], a, exit)
+				x := a.NewAdd(one, one)
+				a.NewBr(exit)
+				exit.NewRet(x)
+				return m
+			},
+			want: "function @f: basic block %exit: ret terminator: definition of %0 does not dominate use",
+		},
+		{
+			name: "use before definition",
+			build: func() *ir.Module {
+				m := ir.NewModule()
+				f := m.NewFunc("f", i32)
+				entry := f.NewBlock("entry")
+				x := ir.NewAdd(one, one)
+				y := entry.NewMul(x, one)
+				entry.Insts = append(entry.Insts, x)
+				entry.NewRet(y)
+				return m
+			},
+			want: "function @f: basic block %entry: mul instruction %0: definition of %1 does not dominate use",
+		},
+		{
+			name: "argument with parameter attributes used before definition",
+			build: func() *ir.Module {
+				m := ir.NewModule()
+				g := m.NewFunc("g", types.Void, ir.NewParam("x", i32))
+				f := m.NewFunc("f", types.Void)
+				entry := f.NewBlock("entry")
+				x := ir.NewAdd(one, one)
+				entry.NewCall(g, ir.NewArg(x, enum.ParamAttrNoUndef))
+				entry.Insts = append(entry.Insts, x)
+				entry.NewRet(nil)
+				return m
+			},
+			want: "function @f: basic block %entry: call instruction: definition of %0 does not dominate use",
+		},
+		{
+			name: "operand bundle input used before definition",
+			build: func() *ir.Module {
+				m := ir.NewModule()
+				g := m.NewFunc("g", types.Void)
+				f := m.NewFunc("f", types.Void)
+				entry := f.NewBlock("entry")
+				x := ir.NewAdd(one, one)
+				call := entry.NewCall(g)
+				call.OperandBundles = []*ir.OperandBundle{ir.NewOperandBundle("deopt", x)}
+				entry.Insts = append(entry.Insts, x)
+				entry.NewRet(nil)
+				return m
+			},
+			want: "function @f: basic block %entry: call instruction: definition of %0 does not dominate use",
+		},
+		{
+			name: "declaration with internal linkage",
+			build: func() *ir.Module {
+				m := ir.NewModule()
+				f := m.NewFunc("f", types.Void)
+				f.Linkage = enum.LinkageInternal
+				return m
+			},
+			want: "function @f: invalid linkage internal of declaration; expected external or extern_weak",
+		},
+		{
+			name: "declaration in comdat",
+			build: func() *ir.Module {
+				m := ir.NewModule()
+				g := m.NewGlobal("x", i32)
+				g.Comdat = &ir.ComdatDef{Name: "x", Kind: enum.SelectionKindAny}
+				m.ComdatDefs = append(m.ComdatDefs, g.Comdat)
+				return m
+			},
+			want: "global variable @x: declaration may not be in comdat comdat($x)",
+		},
+		{
+			name: "undefined comdat",
+			build: func() *ir.Module {
+				m := ir.NewModule()
+				g := m.NewGlobalDef("x", one)
+				g.Comdat = &ir.ComdatDef{Name: "x", Kind: enum.SelectionKindAny}
+				return m
+			},
+			want: "global variable @x: comdat comdat($x) not defined in module",
+		},
+		{
+			name: "invalid alignment",
+			build: func() *ir.Module {
+				m := ir.NewModule()
+				g := m.NewGlobalDef("x", one)
+				g.Align = 3
+				return m
+			},
+			want: "global variable @x: invalid alignment 3; not a power of two",
+		},
+		{
+			name: "alias with available_externally linkage",
+			build: func() *ir.Module {
+				m := ir.NewModule()
+				x := m.NewGlobalDef("x", one)
+				a := m.NewAlias("a", x)
+				a.Linkage = enum.LinkageAvailableExternally
+				return m
+			},
+			want: "alias @a: invalid linkage available_externally",
+		},
+		{
+			name: "alias of declaration",
+			build: func() *ir.Module {
+				m := ir.NewModule()
+				x := m.NewGlobal("x", i32)
+				m.NewAlias("a", x)
+				return m
+			},
+			want: "alias @a: aliasee @x must be a definition",
+		},
+		{
+			name: "alias cycle",
+			build: func() *ir.Module {
+				m := ir.NewModule()
+				x := m.NewGlobalDef("x", one)
+				a := m.NewAlias("a", x)
+				a.Aliasee = a
+				return m
+			},
+			want: "alias @a: aliases cannot form a cycle",
+		},
+		{
+			name: "IFunc with extern_weak linkage",
+			build: func() *ir.Module {
+				m := ir.NewModule()
+				resolver := m.NewFunc("resolver", types.I8Ptr)
+				entry := resolver.NewBlock("entry")
+				entry.NewRet(constant.NewNull(types.I8Ptr))
+				i := m.NewIFunc("i", resolver)
+				i.Linkage = enum.LinkageExternWeak
+				return m
+			},
+			want: "IFunc @i: invalid linkage extern_weak",
+		},
+		{
+			name: "IFunc resolver declaration",
+			build: func() *ir.Module {
+				m := ir.NewModule()
+				resolver := m.NewFunc("resolver", types.I8Ptr)
+				m.NewIFunc("i", resolver)
+				return m
+			},
+			want: "IFunc @i: resolver @resolver must be a definition",
+		},
+	}
+	for _, g := range golden {
+		errs := verify.Module(g.build())
+		if len(errs) != 1 {
+			t.Errorf("%q: number of verification errors mismatch; expected 1, got %d (%v)", g.name, len(errs), errs)
+			continue
+		}
+		if got := errs[0].Error(); !strings.Contains(got, g.want) {
+			t.Errorf("%q: error mismatch; expected %q, got %q", g.name, g.want, got)
+		}
+	}
+}
+
+func TestModuleUnmodified(t *testing.T) {
+	m := ir.NewModule()
+	f := m.NewFunc("f", types.I32)
+	entry := f.NewBlock("")
+	x := entry.NewAdd(constant.NewInt(types.I32, 1), constant.NewInt(types.I32, 2))
+	entry.NewRet(x)
+	for _, err := range verify.Module(m) {
+		t.Errorf("unexpected verification error; %v", err)
+	}
+	// Local IDs are not assigned by the verifier.
+	if id := x.ID(); id != 0 {
+		t.Errorf("local ID of add instruction mismatch; expected 0, got %d", id)
+	}
+}